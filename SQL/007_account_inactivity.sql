@@ -0,0 +1,15 @@
+ALTER TABLE users ADD COLUMN last_active_at DATETIME;
+ALTER TABLE users ADD COLUMN inactivity_warned_at DATETIME;
+ALTER TABLE users ADD COLUMN frozen_at DATETIME;
+
+CREATE TABLE IF NOT EXISTS account_audit_log (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER,
+    username TEXT NOT NULL,
+    action TEXT NOT NULL,
+    detail TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_account_audit_log_user_id ON account_audit_log(user_id);
+CREATE INDEX IF NOT EXISTS idx_account_audit_log_created_at ON account_audit_log(created_at);
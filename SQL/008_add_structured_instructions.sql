@@ -0,0 +1 @@
+ALTER TABLE recipes ADD COLUMN structured_instructions TEXT;
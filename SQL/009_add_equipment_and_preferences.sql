@@ -0,0 +1,2 @@
+ALTER TABLE recipes ADD COLUMN equipment TEXT;
+ALTER TABLE users ADD COLUMN preferences TEXT;
@@ -0,0 +1,11 @@
+-- Requires SQLite with FTS5; the app binary is built with -tags sqlite_fts5.
+CREATE VIRTUAL TABLE IF NOT EXISTS recipe_search USING fts5(
+    title,
+    ingredients,
+    instructions,
+    tokenize = 'porter unicode61'
+);
+
+INSERT INTO recipe_search(rowid, title, ingredients, instructions)
+SELECT id, title, COALESCE(ingredients, ''), instructions FROM recipes
+WHERE id NOT IN (SELECT rowid FROM recipe_search);
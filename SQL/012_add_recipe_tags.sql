@@ -0,0 +1,4 @@
+ALTER TABLE recipes ADD COLUMN tags TEXT;
+
+CREATE INDEX IF NOT EXISTS idx_recipes_user_total_time ON recipes(user_id, total_time);
+CREATE INDEX IF NOT EXISTS idx_recipes_user_servings ON recipes(user_id, servings);
@@ -0,0 +1 @@
+ALTER TABLE recipes ADD COLUMN last_cooked_at DATETIME;
@@ -0,0 +1,3 @@
+-- Page HTML captured client-side (browser extension); when set the queue
+-- worker extracts from it instead of fetching the URL.
+ALTER TABLE queue ADD COLUMN page_html TEXT;
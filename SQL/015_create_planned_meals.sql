@@ -0,0 +1,18 @@
+CREATE TABLE IF NOT EXISTS planned_meals (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    recipe_id INTEGER NOT NULL,
+    planned_for TEXT NOT NULL,
+    meal TEXT,
+    note TEXT,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_planned_meals_user_date ON planned_meals(user_id, planned_for);
+CREATE INDEX IF NOT EXISTS idx_planned_meals_recipe_id ON planned_meals(recipe_id);
+
+-- Secret for subscribable feeds; only the SHA-256 is stored
+ALTER TABLE users ADD COLUMN feed_token_hash TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_feed_token_hash ON users(feed_token_hash);
@@ -0,0 +1,6 @@
+-- Opt-in public cookbook: a handle on the user and a per-recipe flag
+ALTER TABLE users ADD COLUMN public_handle TEXT;
+CREATE UNIQUE INDEX IF NOT EXISTS idx_users_public_handle ON users(public_handle);
+
+ALTER TABLE recipes ADD COLUMN is_public BOOLEAN NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_recipes_user_public ON recipes(user_id, is_public);
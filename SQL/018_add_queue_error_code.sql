@@ -0,0 +1,3 @@
+-- Machine-readable reason for last_error, e.g. "robots_disallowed", so
+-- clients can tell failures that won't succeed on retry from transient ones.
+ALTER TABLE queue ADD COLUMN error_code TEXT;
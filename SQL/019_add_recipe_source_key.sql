@@ -0,0 +1,3 @@
+-- R2 key of the gzipped HTML the recipe was extracted from, served by
+-- GET /recipes/id/:id/source once the original page is gone.
+ALTER TABLE recipes ADD COLUMN source_key TEXT NOT NULL DEFAULT '';
@@ -0,0 +1,11 @@
+-- AI extraction results keyed by a hash of the model and prompt (which
+-- includes the cleaned page text), so the same page isn't extracted twice
+CREATE TABLE IF NOT EXISTS ai_extractions (
+    content_hash TEXT PRIMARY KEY,
+    model TEXT NOT NULL,
+    response TEXT NOT NULL,
+    hits INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ai_extractions_created_at ON ai_extractions(created_at);
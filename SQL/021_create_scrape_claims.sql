@@ -0,0 +1,7 @@
+-- A URL being scraped, so instances sharing the database don't scrape it
+-- at the same time. Claims expire in case the owner dies mid-scrape.
+CREATE TABLE IF NOT EXISTS scrape_claims (
+    url TEXT PRIMARY KEY,
+    owner TEXT NOT NULL,
+    expires_at DATETIME NOT NULL
+);
@@ -0,0 +1,3 @@
+-- Provider and model an admin picked for this import, e.g.
+-- "anthropic:claude-sonnet-4-5"; NULL uses the configured ones.
+ALTER TABLE queue ADD COLUMN ai_model TEXT;
@@ -0,0 +1,3 @@
+-- Estimated nutrition per serving as JSON: calories, proteinGrams,
+-- carbsGrams and fatGrams.
+ALTER TABLE recipes ADD COLUMN nutrition TEXT;
@@ -0,0 +1,3 @@
+-- Nutrition per serving computed from USDA FoodData Central, as JSON with
+-- a confidence flag; kept apart from the AI estimate in nutrition.
+ALTER TABLE recipes ADD COLUMN usda_nutrition TEXT;
@@ -0,0 +1,3 @@
+-- Diets the ingredients fit, as a JSON array such as ["vegetarian","dairy-free"].
+-- NULL until classified; the app backfills it at startup.
+ALTER TABLE recipes ADD COLUMN diets TEXT;
@@ -0,0 +1,14 @@
+-- Questions asked about a recipe and the answers given, kept per user and
+-- recipe so follow-up questions see the earlier conversation.
+CREATE TABLE IF NOT EXISTS recipe_chat_messages (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    recipe_id INTEGER NOT NULL,
+    role TEXT NOT NULL,
+    content TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_recipe_chat_messages_recipe ON recipe_chat_messages(user_id, recipe_id, id);
@@ -0,0 +1,4 @@
+-- The version of schema.json the AI extracted the recipe with. NULL for
+-- recipes from site extractors, schema.org data, imports and manual entry,
+-- and for AI recipes saved before schemas were versioned.
+ALTER TABLE recipes ADD COLUMN schema_version INTEGER;
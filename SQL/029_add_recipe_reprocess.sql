@@ -0,0 +1,4 @@
+-- Incomplete recipes (placeholders saved when an import failed) are queued
+-- again with backoff: how many times so far, and when the next may be.
+ALTER TABLE recipes ADD COLUMN reprocess_attempts INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE recipes ADD COLUMN reprocess_after DATETIME;
@@ -0,0 +1,3 @@
+-- The recipe a queue item was saved as (possibly a placeholder), so clients
+-- watching the queue can open it once processed.
+ALTER TABLE queue ADD COLUMN recipe_slug TEXT;
@@ -0,0 +1,4 @@
+-- Interactive submissions are processed ahead of bulk imports and
+-- background reprocessing: higher priority first, then oldest first.
+ALTER TABLE queue ADD COLUMN priority INTEGER NOT NULL DEFAULT 0;
+CREATE INDEX IF NOT EXISTS idx_queue_pending_priority ON queue(processed_at, priority DESC, created_at);
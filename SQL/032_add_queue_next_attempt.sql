@@ -0,0 +1,3 @@
+-- Failed queue items wait with exponential backoff before their next
+-- attempt; NULL means as soon as possible.
+ALTER TABLE queue ADD COLUMN next_attempt_at DATETIME;
@@ -0,0 +1,6 @@
+-- Items that used their last attempt, or failed in a way retrying can't fix,
+-- are dead-lettered: failed_at is set alongside processed_at and they stay
+-- listed under /queue/failed until retried.
+ALTER TABLE queue ADD COLUMN failed_at DATETIME;
+UPDATE queue SET failed_at = processed_at WHERE processed_at IS NOT NULL AND last_error IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_queue_failed ON queue(user_id, failed_at);
@@ -0,0 +1,4 @@
+-- Items the user cancelled with DELETE /queue/:id. processed_at is set with
+-- it so the queue processor skips them, and a worker already importing one
+-- abandons it.
+ALTER TABLE queue ADD COLUMN cancelled_at DATETIME;
@@ -0,0 +1,6 @@
+-- Workers claim the items they take so replicas sharing the database don't
+-- import the same item twice. A claim is a lease: once claimed_at is older
+-- than the lease another worker may take the item, so one whose worker died
+-- isn't stuck.
+ALTER TABLE queue ADD COLUMN claimed_by TEXT;
+ALTER TABLE queue ADD COLUMN claimed_at DATETIME;
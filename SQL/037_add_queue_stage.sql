@@ -0,0 +1,12 @@
+-- Where each queue item is: queued, then fetching, extracting and saving
+-- while a worker imports it, and finally done, failed or cancelled.
+-- stage_changed_at shows how long it has been there.
+ALTER TABLE queue ADD COLUMN stage TEXT NOT NULL DEFAULT 'queued';
+ALTER TABLE queue ADD COLUMN stage_changed_at DATETIME;
+UPDATE queue SET stage = CASE
+        WHEN cancelled_at IS NOT NULL THEN 'cancelled'
+        WHEN failed_at IS NOT NULL THEN 'failed'
+        WHEN processed_at IS NOT NULL THEN 'done'
+        ELSE 'queued'
+    END,
+    stage_changed_at = COALESCE(processed_at, created_at);
@@ -0,0 +1,10 @@
+-- The curated recipes every new account starts with, copied to the user
+-- on registration. recipe holds the recipe as JSON, as the API returns
+-- it. Managed with the starters command; see starters.go.
+CREATE TABLE IF NOT EXISTS starter_recipes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL UNIQUE,
+    position INTEGER NOT NULL DEFAULT 0,
+    recipe TEXT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
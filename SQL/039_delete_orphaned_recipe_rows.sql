@@ -0,0 +1,7 @@
+-- Recipe deletes rely on the ON DELETE CASCADE foreign keys to clear a
+-- recipe's rows. SQLite enforced them only once connections turned
+-- foreign_keys on, so remove what deletes before that left behind.
+DELETE FROM favorites WHERE recipe_id NOT IN (SELECT id FROM recipes);
+DELETE FROM planned_meals WHERE recipe_id NOT IN (SELECT id FROM recipes);
+DELETE FROM recipe_chat_messages WHERE recipe_id NOT IN (SELECT id FROM recipes);
+DELETE FROM recipe_ingredients WHERE recipe_id NOT IN (SELECT id FROM recipes);
@@ -0,0 +1,5 @@
+-- When the feed token was last issued or fetched with, so the retention
+-- cleanup can revoke tokens nobody uses (RETENTION_FEED_TOKENS). Tokens
+-- that already exist start counting now.
+ALTER TABLE users ADD COLUMN feed_token_used_at DATETIME;
+UPDATE users SET feed_token_used_at = CURRENT_TIMESTAMP WHERE feed_token_hash IS NOT NULL;
@@ -0,0 +1,224 @@
+-- The schema for MySQL 8 / MariaDB 10.2+ (DB_DRIVER=mysql), matching the
+-- SQLite migrations in SQL/ up to 037. Later migrations there get a twin
+-- here with the same number.
+--
+-- Times are DATETIME(6) in UTC (see openMySQL). Text that is unique or
+-- indexed is VARCHAR; utf8mb4_bin compares it exactly, as SQLite does.
+-- There is no recipe_search table: MySQL has no FTS5, so search uses LIKE.
+
+CREATE TABLE IF NOT EXISTS users (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    username VARCHAR(255) NOT NULL UNIQUE,
+    password_hash TEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    last_active_at DATETIME(6),
+    inactivity_warned_at DATETIME(6),
+    frozen_at DATETIME(6),
+    preferences TEXT,
+    -- Secret for subscribable feeds; only the SHA-256 is stored
+    feed_token_hash VARCHAR(64),
+    public_handle VARCHAR(64),
+    UNIQUE KEY idx_users_feed_token_hash (feed_token_hash),
+    UNIQUE KEY idx_users_public_handle (public_handle)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS recipes (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    slug VARCHAR(255) NOT NULL,
+    title TEXT NOT NULL,
+    category VARCHAR(255),
+    cook_time INT,
+    date TEXT,
+    image TEXT,
+    instructions MEDIUMTEXT NOT NULL,
+    ingredients MEDIUMTEXT,
+    parsed_ingredients MEDIUMTEXT,
+    prep_time INT,
+    servings INT,
+    total_time INT,
+    link TEXT,
+    original_url TEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    structured_instructions MEDIUMTEXT,
+    equipment TEXT,
+    tags TEXT,
+    last_cooked_at DATETIME(6),
+    is_public BOOLEAN NOT NULL DEFAULT FALSE,
+    source_key VARCHAR(255) NOT NULL DEFAULT '',
+    nutrition TEXT,
+    usda_nutrition TEXT,
+    diets TEXT,
+    schema_version INT,
+    reprocess_attempts INT NOT NULL DEFAULT 0,
+    reprocess_after DATETIME(6),
+    UNIQUE KEY idx_recipes_user_slug (user_id, slug),
+    KEY idx_recipes_category (category),
+    KEY idx_recipes_user_total_time (user_id, total_time),
+    KEY idx_recipes_user_servings (user_id, servings),
+    KEY idx_recipes_user_public (user_id, is_public),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS queue (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    url TEXT NOT NULL,
+    attempts INT NOT NULL DEFAULT 0,
+    last_error TEXT,
+    processed_at DATETIME(6),
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    page_html LONGTEXT,
+    error_code VARCHAR(64),
+    ai_model VARCHAR(255),
+    recipe_slug VARCHAR(255),
+    priority INT NOT NULL DEFAULT 0,
+    next_attempt_at DATETIME(6),
+    failed_at DATETIME(6),
+    cancelled_at DATETIME(6),
+    claimed_by VARCHAR(255),
+    claimed_at DATETIME(6),
+    stage VARCHAR(16) NOT NULL DEFAULT 'queued',
+    stage_changed_at DATETIME(6),
+    KEY idx_queue_user_id (user_id),
+    KEY idx_queue_processed (processed_at, created_at),
+    KEY idx_queue_pending_priority (processed_at, priority DESC, created_at),
+    KEY idx_queue_failed (user_id, failed_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS password_resets (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    token_hash VARCHAR(64) NOT NULL,
+    expires_at DATETIME(6) NOT NULL,
+    used_at DATETIME(6),
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    updated_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY idx_password_resets_token_hash (token_hash),
+    KEY idx_password_resets_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS favorites (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    recipe_id BIGINT UNSIGNED NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    UNIQUE KEY idx_favorites_user_recipe (user_id, recipe_id),
+    KEY idx_favorites_recipe_id (recipe_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS account_audit_log (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED,
+    username VARCHAR(255) NOT NULL,
+    action VARCHAR(64) NOT NULL,
+    detail TEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_account_audit_log_user_id (user_id),
+    KEY idx_account_audit_log_created_at (created_at)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS recipe_ingredients (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    recipe_id BIGINT UNSIGNED NOT NULL,
+    user_id BIGINT UNSIGNED NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    KEY idx_recipe_ingredients_recipe_id (recipe_id),
+    KEY idx_recipe_ingredients_user_name (user_id, name),
+    FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE,
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS planned_meals (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    recipe_id BIGINT UNSIGNED NOT NULL,
+    -- YYYY-MM-DD
+    planned_for VARCHAR(10) NOT NULL,
+    meal VARCHAR(32),
+    note TEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_planned_meals_user_date (user_id, planned_for),
+    KEY idx_planned_meals_recipe_id (recipe_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS webhooks (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    url TEXT NOT NULL,
+    secret VARCHAR(255) NOT NULL,
+    -- Comma-separated event names; empty subscribes to every event
+    events VARCHAR(1024) NOT NULL DEFAULT '',
+    last_delivery_at DATETIME(6),
+    last_status INT,
+    last_error TEXT,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_webhooks_user_id (user_id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS ai_extractions (
+    content_hash VARCHAR(64) NOT NULL PRIMARY KEY,
+    model VARCHAR(255) NOT NULL,
+    response MEDIUMTEXT NOT NULL,
+    hits INT NOT NULL DEFAULT 0,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_ai_extractions_created_at (created_at)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+-- Keyed by URL, so one can be at most 768 characters (3072 bytes)
+CREATE TABLE IF NOT EXISTS scrape_claims (
+    url VARCHAR(768) NOT NULL PRIMARY KEY,
+    owner VARCHAR(255) NOT NULL,
+    expires_at DATETIME(6) NOT NULL
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS ai_usage (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED,
+    operation VARCHAR(64) NOT NULL,
+    provider VARCHAR(64) NOT NULL,
+    model VARCHAR(255) NOT NULL,
+    url TEXT,
+    prompt_tokens INT NOT NULL DEFAULT 0,
+    completion_tokens INT NOT NULL DEFAULT 0,
+    total_tokens INT NOT NULL DEFAULT 0,
+    cost_usd DOUBLE NOT NULL DEFAULT 0,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_ai_usage_user_created (user_id, created_at),
+    KEY idx_ai_usage_created_at (created_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE SET NULL
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS recipe_chat_messages (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id BIGINT UNSIGNED NOT NULL,
+    recipe_id BIGINT UNSIGNED NOT NULL,
+    role VARCHAR(16) NOT NULL,
+    content MEDIUMTEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    KEY idx_recipe_chat_messages_recipe (user_id, recipe_id, id),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY (recipe_id) REFERENCES recipes(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    user_id BIGINT UNSIGNED NOT NULL,
+    `key` VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    status INT NOT NULL DEFAULT 0,
+    content_type VARCHAR(255),
+    body LONGBLOB,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6),
+    PRIMARY KEY (user_id, `key`),
+    KEY idx_idempotency_keys_created (created_at),
+    FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
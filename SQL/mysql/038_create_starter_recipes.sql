@@ -0,0 +1,9 @@
+-- The curated recipes every new account starts with; see
+-- SQL/038_create_starter_recipes.sql.
+CREATE TABLE IF NOT EXISTS starter_recipes (
+    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    slug VARCHAR(255) NOT NULL UNIQUE,
+    position INT NOT NULL DEFAULT 0,
+    recipe LONGTEXT NOT NULL,
+    created_at DATETIME(6) NOT NULL DEFAULT CURRENT_TIMESTAMP(6)
+) DEFAULT CHARSET = utf8mb4 COLLATE = utf8mb4_bin;
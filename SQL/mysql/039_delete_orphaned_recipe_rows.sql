@@ -0,0 +1,7 @@
+-- See SQL/039_delete_orphaned_recipe_rows.sql. InnoDB has always enforced
+-- the foreign keys, so this finds nothing unless a dump was loaded with
+-- FOREIGN_KEY_CHECKS off.
+DELETE FROM favorites WHERE recipe_id NOT IN (SELECT id FROM recipes);
+DELETE FROM planned_meals WHERE recipe_id NOT IN (SELECT id FROM recipes);
+DELETE FROM recipe_chat_messages WHERE recipe_id NOT IN (SELECT id FROM recipes);
+DELETE FROM recipe_ingredients WHERE recipe_id NOT IN (SELECT id FROM recipes);
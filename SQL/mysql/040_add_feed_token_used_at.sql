@@ -0,0 +1,3 @@
+-- See SQL/040_add_feed_token_used_at.sql.
+ALTER TABLE users ADD COLUMN feed_token_used_at DATETIME(6);
+UPDATE users SET feed_token_used_at = CURRENT_TIMESTAMP(6) WHERE feed_token_hash IS NOT NULL;
@@ -1,271 +1,406 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
+	_ "embed"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
-	"time"
-
-	"github.com/davecgh/go-spew/spew"
-	"github.com/sashabaranov/go-openai"
+	"strings"
+	"sync"
 )
 
-const defaultEngine = "gpt-5-mini"
+// AIProvider is a backend for the AI features: extracting a recipe from
+// page text into the schema.json shape, generating a photo, checking that
+// an image matches a title, and answering chat messages in plain text.
+// ChatStream is Chat with the reply passed to onDelta piece by piece as it
+// is written (onDelta may be nil); cancelling ctx abandons the request.
+type AIProvider interface {
+	Name() string
+	Model() string
+	ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error)
+	GenerateImage(prompt string) (GeneratedImage, error)
+	Validate(title, image string) (bool, error)
+	Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error)
+	ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error)
+}
 
-type Client struct {
-	client *openai.Client
-	engine string
-	debug  bool
-	format string
-	schema map[string]interface{}
+// ChatReply is the answer to a chat request.
+type ChatReply struct {
+	Content string
+	Model   string
+	Usage   Usage
 }
 
-func NewClient(apiKey, engine, format string, debug bool) *Client {
-	if engine == "" {
-		engine = defaultEngine
-	}
+// GeneratedImage is a generated photo, either as a URL to download or as
+// the image bytes.
+type GeneratedImage struct {
+	URL         string
+	Data        []byte
+	ContentType string
+	// Model is the image model that drew it
+	Model string
+}
 
-	data, err := os.ReadFile("schema.json")
-	if err != nil {
-		log.Fatal(err)
-	}
-	var schema map[string]interface{}
-	if err := json.Unmarshal(data, &schema); err != nil {
-		log.Fatal(err)
+const (
+	aiProviderOpenAI    = "openai"
+	aiProviderAnthropic = "anthropic"
+	aiProviderGemini    = "gemini"
+	aiProviderOllama    = "ollama"
+)
+
+// chatMessage is one message of a chat-style request; Role is "user" or
+// "assistant" (or "system" where a provider takes it inline).
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+var (
+	errAIUnsupported     = errors.New("not supported by this ai provider")
+	errUnknownAIProvider = errors.New("unknown ai provider")
+	errAINoKey           = errors.New("no api key set")
+)
+
+// newAIProvider builds the providers listed in AI_PROVIDERS
+// ("ollama,openai"), in order, skipping any without an API key (ollama and
+// OpenAI-compatible servers at OPENAI_BASE_URL don't need one). With more
+// than one, each call fails over to the next provider when one errors. The
+// default is OpenAI alone.
+func newAIProvider() AIProvider {
+	var providers []AIProvider
+	for _, name := range aiProviderNames() {
+		provider, err := aiProviderByName(name, "")
+		if err != nil {
+			log.Printf("Config: AI provider %s: %v", name, err)
+			continue
+		}
+		providers = append(providers, provider)
 	}
 
-	return &Client{
-		client: openai.NewClient(apiKey),
-		engine: engine,
-		debug:  debug,
-		format: format,
-		schema: schema,
+	switch len(providers) {
+	case 0:
+		return newOpenAIProvider(appConfig.AI.OpenAIKey, aiModelFor(aiProviderOpenAI))
+	case 1:
+		return providers[0]
 	}
+	return failoverProvider(providers)
 }
 
-func (c *Client) RecipePrompt(prompt, systemPrompt string, maxTokens int) (*Response, error) {
-	// Set 60-second timeout for OpenAI API calls
-	ctx, cancel := context.WithTimeout(context.Background(), 240*time.Second)
-	defer cancel()
-
-	schemaJSON, err := json.Marshal(c.schema["schema"])
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal schema: %w", err)
-	}
-
-	req := openai.ChatCompletionRequest{
-		Model: c.engine,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: systemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: prompt,
-			},
-		},
-		MaxCompletionTokens: maxTokens,
-		Temperature:         0,
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
-			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
-				Name:   "recipe_response",
-				Schema: json.RawMessage(schemaJSON),
-				Strict: true,
-			},
-		},
-	}
-
-	if c.debug {
-		log.Printf("Request: %+v\n", req)
-	}
-
-	resp, err := c.client.CreateChatCompletion(ctx, req)
+// newAIProviderFor is newAIProvider, or with an override from an admin the
+// one provider and model it names (see parseAIModelOverride).
+func newAIProviderFor(override string) (AIProvider, error) {
+	if strings.TrimSpace(override) == "" {
+		return newAIProvider(), nil
+	}
+	name, model, err := parseAIModelOverride(override)
 	if err != nil {
 		return nil, err
 	}
+	return aiProviderByName(name, model)
+}
 
-	if c.debug {
-		log.Printf("Response: %+v\n", resp)
+// aiProviderForImport is newAIProviderFor for a queued import of pageURL,
+// metered to its user. The override was checked when it was queued but may
+// have lost its key since.
+func aiProviderForImport(opts importOptions, pageURL string) AIProvider {
+	ai, err := newAIProviderFor(opts.AIModel)
+	if err != nil {
+		log.Printf("Scraper: AI model override %q: %v; using the configured providers", opts.AIModel, err)
+		ai = newAIProvider()
 	}
+	return meterAIUsage(ai, opts.Username, pageURL)
+}
 
-	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
-		return nil, fmt.Errorf("empty OpenAI chat completion response")
-	}
+func aiProviderNames() []string {
+	return appConfig.AI.Providers
+}
 
-	var response Response
-	if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &response); err != nil {
-		return nil, err
+// aiProviderByName builds a provider with model, or its configured model
+// when model is "".
+func aiProviderByName(name, model string) (AIProvider, error) {
+	if model == "" {
+		model = aiModelFor(name)
+	}
+	var key string
+	switch name {
+	case aiProviderOpenAI:
+		key = appConfig.AI.OpenAIKey
+		if appConfig.AI.OpenAIBaseURL != "" {
+			// Local OpenAI-compatible servers rarely want a key
+			return newOpenAIProvider(key, model), nil
+		}
+	case aiProviderAnthropic:
+		key = appConfig.AI.AnthropicKey
+	case aiProviderGemini:
+		key = appConfig.AI.GeminiKey
+	case aiProviderOllama:
+		return newOllamaProvider(appConfig.AI.OllamaURL, model), nil
+	default:
+		return nil, errUnknownAIProvider
+	}
+	if strings.TrimSpace(key) == "" {
+		return nil, errAINoKey
 	}
 
-	response.ID = resp.ID
-	response.Object = resp.Object
-	response.Created = resp.Created
-	response.Model = resp.Model
-	response.SystemFingerprint = resp.SystemFingerprint
-	response.Usage = Usage{
-		PromptTokens:     resp.Usage.PromptTokens,
-		CompletionTokens: resp.Usage.CompletionTokens,
-		TotalTokens:      resp.Usage.TotalTokens,
+	switch name {
+	case aiProviderAnthropic:
+		return newAnthropicProvider(key, model), nil
+	case aiProviderGemini:
+		return newGeminiProvider(key, model), nil
 	}
+	return newOpenAIProvider(key, model), nil
+}
 
-	return &response, nil
+// failoverProvider tries each provider in turn until one succeeds. It is
+// named and cached under the first.
+type failoverProvider []AIProvider
+
+func (f failoverProvider) Name() string {
+	return f[0].Name()
 }
 
-func (c *Client) ValidateImage(title, image string) (bool, error) {
-	// Set 60-second timeout for OpenAI API calls
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+func (f failoverProvider) Model() string {
+	return f[0].Model()
+}
 
-	// Define the JSON schema for enforcing a boolean response with additionalProperties set to false
-	schemaJSON := `{
-		"type": "object",
-		"properties": {
-			"matches": {
-				"type": "boolean"
-			}
-		},
-		"required": ["matches"],
-		"additionalProperties": false
-	}`
-
-	req := openai.ChatCompletionRequest{
-		Model: c.engine,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: "You are an assistant validating if an image title matches its content. Respond only with a JSON object containing a boolean field 'matches'.",
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: fmt.Sprintf(`{"title": %q, "image": %q}`, title, image),
-			},
-		},
-		MaxCompletionTokens: 16000,
-		Temperature:         0,
-		ResponseFormat: &openai.ChatCompletionResponseFormat{
-			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
-			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
-				Name:   "image_validation",
-				Schema: json.RawMessage(schemaJSON),
-				Strict: true,
-			},
-		},
-	}
-
-	// Debug logging
-	//if c.debug {
-	//	log.Printf("Request: %+v\n", req)
-	//}
-
-	// Send the request
-	resp, err := c.client.CreateChatCompletion(ctx, req)
-	if err != nil {
-		return false, err
-	}
+func (f failoverProvider) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	return failover(ctx, f, "extract recipe", func(p AIProvider) (*Response, error) {
+		return p.ExtractRecipe(ctx, prompt, systemPrompt, maxTokens)
+	})
+}
 
-	if c.debug {
-		log.Printf("Response: %+v\n", resp)
-	}
+func (f failoverProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	return failover(context.Background(), f, "generate image", func(p AIProvider) (GeneratedImage, error) {
+		return p.GenerateImage(prompt)
+	})
+}
 
-	// Parse the response into a struct
-	var result struct {
-		Matches bool `json:"matches"`
-	}
+func (f failoverProvider) Validate(title, image string) (bool, error) {
+	return failover(context.Background(), f, "validate image", func(p AIProvider) (bool, error) {
+		return p.Validate(title, image)
+	})
+}
+
+func (f failoverProvider) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	return failover(ctx, f, "chat", func(p AIProvider) (ChatReply, error) {
+		return p.Chat(ctx, systemPrompt, messages, maxTokens)
+	})
+}
 
-	if len(resp.Choices) > 0 {
-		if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
-			return false, fmt.Errorf("failed to parse response: %w", err)
+// ChatStream moves on to the next provider only while nothing has been
+// passed to onDelta, since a reply can't be taken back once it's streaming,
+// and not at all once ctx is done.
+func (f failoverProvider) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	var errs []error
+	for _, provider := range f {
+		started := false
+		reply, err := provider.ChatStream(ctx, systemPrompt, messages, maxTokens, func(delta string) {
+			started = true
+			if onDelta != nil {
+				onDelta(delta)
+			}
+		})
+		if err == nil {
+			return reply, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		if started || ctx.Err() != nil {
+			break
 		}
-		spew.Dump(resp.Choices)
+		log.Printf("AI: chat with %s failed, trying the next provider: %v", provider.Name(), err)
 	}
+	return ChatReply{}, errors.Join(errs...)
+}
 
-	return result.Matches, nil
+// failover stops early once ctx is done, as every provider after would
+// fail the same way.
+func failover[T any](ctx context.Context, providers []AIProvider, what string, call func(AIProvider) (T, error)) (T, error) {
+	var errs []error
+	for _, provider := range providers {
+		result, err := call(provider)
+		if err == nil {
+			return result, nil
+		}
+		if !errors.Is(err, errAIUnsupported) && ctx.Err() == nil {
+			log.Printf("AI: %s with %s failed, trying the next provider: %v", what, provider.Name(), err)
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", provider.Name(), err))
+		if ctx.Err() != nil {
+			break
+		}
+	}
+	var zero T
+	return zero, errors.Join(errs...)
 }
 
-func (c *Client) GenerateImage(prompt string) (string, error) {
-	// Set 60-second timeout for OpenAI API calls
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+// embeddedRecipeSchema is the extraction schema built into the binary.
+// RECIPE_SCHEMA_FILE replaces it with a file, which must carry its own
+// "version"; bump the version whenever the schema changes, since saved
+// recipes record the version they were extracted with.
+//
+//go:embed schema.json
+var embeddedRecipeSchema []byte
+
+var recipeSchema struct {
+	once    sync.Once
+	json    json.RawMessage
+	version int
+	err     error
+}
 
-	req := openai.ImageRequest{
-		Prompt:         prompt,
-		Size:           openai.CreateImageSize1024x1024,
-		N:              1,
-		ResponseFormat: openai.CreateImageResponseFormatURL,
+func loadRecipeSchema() {
+	data := embeddedRecipeSchema
+	source := "embedded schema.json"
+	if path := appConfig.AI.SchemaFile; path != "" {
+		var err error
+		if data, err = os.ReadFile(path); err != nil {
+			recipeSchema.err = fmt.Errorf("read recipe schema: %w", err)
+			return
+		}
+		source = path
 	}
-
-	resp, err := c.client.CreateImage(ctx, req)
-	if err != nil {
-		return "", fmt.Errorf("failed to generate image: %w", err)
+	var file struct {
+		Version int             `json:"version"`
+		Schema  json.RawMessage `json:"schema"`
 	}
-
-	if len(resp.Data) == 0 {
-		return "", fmt.Errorf("no image URL returned")
+	if err := json.Unmarshal(data, &file); err != nil {
+		recipeSchema.err = fmt.Errorf("parse recipe schema %s: %w", source, err)
+		return
 	}
-
-	return resp.Data[0].URL, nil
+	if file.Version <= 0 {
+		recipeSchema.err = fmt.Errorf("recipe schema %s has no version", source)
+		return
+	}
+	if len(file.Schema) == 0 {
+		recipeSchema.err = fmt.Errorf("recipe schema %s has no schema", source)
+		return
+	}
+	recipeSchema.json = file.Schema
+	recipeSchema.version = file.Version
 }
 
-func (c *Client) GenerateEnhancedFoodPrompt(foodItem string, maxTokens int) (*BasicResponse, error) {
-	// Set 60-second timeout for OpenAI API calls
-	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-	defer cancel()
+// recipeResponseSchema is the JSON schema of the recipe response, the
+// "schema" field of schema.json.
+func recipeResponseSchema() (json.RawMessage, error) {
+	recipeSchema.once.Do(loadRecipeSchema)
+	return recipeSchema.json, recipeSchema.err
+}
 
-	// Define the system prompt for generating detailed and visually rich descriptions
-	systemPrompt := "You are a food stylist and photographer specializing in creating vivid, visually appealing descriptions for food items. Your job is to generate enhanced and detailed prompts suitable for creating high-quality images."
+// recipeSchemaVersion is the version of the extraction schema in use, or 0
+// when it couldn't be loaded.
+func recipeSchemaVersion() int {
+	recipeSchema.once.Do(loadRecipeSchema)
+	return recipeSchema.version
+}
 
-	// User prompt for the specific food item and context
-	userPrompt := fmt.Sprintf("Create a visually appealing description for '%s'. Include details about texture, color, lighting, setting, and arrangement. Max characters can not exceed 1000 chars.", foodItem)
+// decodeRecipeResponse reads a provider's JSON recipe output.
+func decodeRecipeResponse(content string) (*Response, error) {
+	var response Response
+	if err := json.Unmarshal([]byte(content), &response); err != nil {
+		return nil, fmt.Errorf("decode recipe response: %w", err)
+	}
+	return &response, nil
+}
 
-	req := openai.ChatCompletionRequest{
-		Model: c.engine,
-		Messages: []openai.ChatCompletionMessage{
-			{
-				Role:    openai.ChatMessageRoleSystem,
-				Content: systemPrompt,
-			},
-			{
-				Role:    openai.ChatMessageRoleUser,
-				Content: userPrompt,
-			},
-		},
-		MaxCompletionTokens: maxTokens,
+// jsonArrayIn returns the JSON array in a free-text chat reply, which may
+// have prose or a code fence around it.
+func jsonArrayIn(content string) ([]byte, error) {
+	start, end := strings.Index(content, "["), strings.LastIndex(content, "]")
+	if start < 0 || end < start {
+		return nil, errors.New("no JSON array in reply")
 	}
+	return []byte(content[start : end+1]), nil
+}
 
-	if c.debug {
-		log.Printf("Request: %+v\n", req)
+// postAIJSON POSTs body as JSON and decodes the JSON reply into out, for the
+// providers without a Go SDK here.
+func postAIJSON(ctx context.Context, endpoint string, headers map[string]string, body, out any) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
 	}
 
-	resp, err := c.client.CreateChatCompletion(ctx, req)
+	resp, err := http.DefaultClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate enhanced prompt: %w", err)
+		return err
 	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("read response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status %s: %s", resp.Status, truncateText(strings.TrimSpace(string(data)), 500))
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("decode response: %w", err)
+	}
+	return nil
+}
 
-	if c.debug {
-		log.Printf("foodResponse: %+v\n", resp)
+// streamAIJSON POSTs body as JSON and calls onLine with each line of the
+// streamed reply, for the server-sent events and newline-delimited JSON of
+// the providers without a Go SDK here. An error from onLine stops reading.
+func streamAIJSON(ctx context.Context, endpoint string, headers map[string]string, body any, onLine func(line string) error) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return fmt.Errorf("marshal request: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range headers {
+		req.Header.Set(name, value)
 	}
 
-	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
-		return nil, fmt.Errorf("empty OpenAI chat completion response")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("status %s: %s", resp.Status, truncateText(strings.TrimSpace(string(data)), 500))
 	}
 
-	var basicResponse BasicResponse
-	basicResponse.ID = resp.ID
-	basicResponse.Object = resp.Object
-	basicResponse.Created = resp.Created
-	basicResponse.Model = resp.Model
-	basicResponse.Usage = Usage{
-		PromptTokens:     resp.Usage.PromptTokens,
-		CompletionTokens: resp.Usage.CompletionTokens,
-		TotalTokens:      resp.Usage.TotalTokens,
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 0, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := onLine(line); err != nil {
+			return err
+		}
 	}
-	basicResponse.EnhancedPrompt = resp.Choices[0].Message.Content
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("read stream: %w", err)
+	}
+	return nil
+}
 
-	return &basicResponse, nil
+// sseData returns the payload of a server-sent event "data:" line.
+func sseData(line string) (string, bool) {
+	data, ok := strings.CutPrefix(line, "data:")
+	return strings.TrimSpace(data), ok
 }
 
 type BasicResponse struct {
@@ -284,24 +419,27 @@ type ImageValidationRequest struct {
 }
 
 type Response struct {
-	ID                string             `json:"id"`
-	Object            string             `json:"object"`
-	Created           int64              `json:"created"`
-	Model             string             `json:"model"`
-	SystemFingerprint string             `json:"system_fingerprint"`
-	Choices           []Choice           `json:"choices"`
-	Usage             Usage              `json:"usage"`
-	Title             string             `json:"title"`
-	Date              string             `json:"date"`
-	Image             string             `json:"image"`
-	PrepTime          int                `json:"prepTime"`
-	CookTime          int                `json:"cookTime"`
-	TotalTime         int                `json:"totalTime"`
-	Servings          int                `json:"servings"`
-	Category          string             `json:"category"`
-	Ingredients       []string           `json:"ingredients"`
-	ParsedIngredients []IngredientDetail `json:"parsedIngredients,omitempty"`
-	Instructions      []string           `json:"instructions"`
+	ID                  string               `json:"id"`
+	Object              string               `json:"object"`
+	Created             int64                `json:"created"`
+	Model               string               `json:"model"`
+	SystemFingerprint   string               `json:"system_fingerprint"`
+	Choices             []Choice             `json:"choices"`
+	Usage               Usage                `json:"usage"`
+	Title               string               `json:"title"`
+	Date                string               `json:"date"`
+	Image               string               `json:"image"`
+	PrepTime            int                  `json:"prepTime"`
+	CookTime            int                  `json:"cookTime"`
+	TotalTime           int                  `json:"totalTime"`
+	Servings            int                  `json:"servings"`
+	Category            string               `json:"category"`
+	Ingredients         []string             `json:"ingredients"`
+	ParsedIngredients   []IngredientDetail   `json:"parsedIngredients,omitempty"`
+	Instructions        []string             `json:"instructions"`
+	InstructionSections []InstructionSection `json:"instructionSections"`
+	Equipment           []string             `json:"equipment"`
+	Nutrition           *Nutrition           `json:"nutrition"`
 }
 
 type Choice struct {
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultAnthropicModel = "claude-haiku-4-5"
+	chatMessagesURL       = "https://api.anthropic.com/v1/messages"
+	anthropicVersion      = "2023-06-01"
+)
+
+// anthropicProvider is the Anthropic Messages API backend, used with
+// ANTHROPIC_API_KEY. Structured output comes from forcing a tool call whose
+// input schema is the recipe schema. Anthropic has no image generation.
+type anthropicProvider struct {
+	apiKey string
+	model  string
+}
+
+func newAnthropicProvider(apiKey, model string) *anthropicProvider {
+	if model == "" {
+		model = defaultAnthropicModel
+	}
+	return &anthropicProvider{apiKey: apiKey, model: model}
+}
+
+func (a *anthropicProvider) Name() string {
+	return aiProviderAnthropic
+}
+
+func (a *anthropicProvider) Model() string {
+	return a.model
+}
+
+type anthropicTool struct {
+	Name        string          `json:"name"`
+	Description string          `json:"description"`
+	InputSchema json.RawMessage `json:"input_schema"`
+}
+
+type anthropicRequest struct {
+	Model      string            `json:"model"`
+	MaxTokens  int               `json:"max_tokens"`
+	System     string            `json:"system,omitempty"`
+	Messages   []chatMessage     `json:"messages"`
+	Tools      []anthropicTool   `json:"tools,omitempty"`
+	ToolChoice map[string]string `json:"tool_choice,omitempty"`
+	Stream     bool              `json:"stream,omitempty"`
+}
+
+type anthropicResponse struct {
+	ID      string `json:"id"`
+	Model   string `json:"model"`
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Usage struct {
+		InputTokens  int `json:"input_tokens"`
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+}
+
+// call sends one user message and returns the input of the forced tool
+// call as JSON.
+func (a *anthropicProvider) call(ctx context.Context, system, prompt string, maxTokens int, tool anthropicTool) (string, anthropicResponse, error) {
+	req := anthropicRequest{
+		Model:      a.model,
+		MaxTokens:  maxTokens,
+		System:     system,
+		Messages:   []chatMessage{{Role: "user", Content: prompt}},
+		Tools:      []anthropicTool{tool},
+		ToolChoice: map[string]string{"type": "tool", "name": tool.Name},
+	}
+
+	var resp anthropicResponse
+	headers := map[string]string{"x-api-key": a.apiKey, "anthropic-version": anthropicVersion}
+	if err := postAIJSON(ctx, chatMessagesURL, headers, req, &resp); err != nil {
+		return "", resp, fmt.Errorf("anthropic messages: %w", err)
+	}
+	for _, block := range resp.Content {
+		if block.Type == "tool_use" && block.Name == tool.Name {
+			return string(block.Input), resp, nil
+		}
+	}
+	return "", resp, fmt.Errorf("empty anthropic response")
+}
+
+func (a *anthropicProvider) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiRecipeTimeout)
+	defer cancel()
+
+	schema, err := recipeResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+	tool := anthropicTool{Name: "recipe_response", Description: "Record the extracted recipe.", InputSchema: schema}
+	content, resp, err := a.call(ctx, systemPrompt, prompt, maxTokens, tool)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeRecipeResponse(content)
+	if err != nil {
+		return nil, err
+	}
+	response.ID = resp.ID
+	response.Model = resp.Model
+	response.Usage = Usage{
+		PromptTokens:     resp.Usage.InputTokens,
+		CompletionTokens: resp.Usage.OutputTokens,
+		TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+	}
+	return response, nil
+}
+
+func (a *anthropicProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	return GeneratedImage{}, fmt.Errorf("generate image: %w", errAIUnsupported)
+}
+
+func (a *anthropicProvider) Validate(title, image string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	tool := anthropicTool{
+		Name:        "image_validation",
+		Description: "Record whether the image matches the title.",
+		InputSchema: json.RawMessage(`{"type":"object","properties":{"matches":{"type":"boolean"}},"required":["matches"]}`),
+	}
+	content, _, err := a.call(ctx,
+		"You are an assistant validating if an image title matches its content.",
+		fmt.Sprintf(`{"title": %q, "image": %q}`, title, image), 1024, tool)
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Matches bool `json:"matches"`
+	}
+	if err := json.Unmarshal([]byte(content), &result); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.Matches, nil
+}
+
+func (a *anthropicProvider) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiRequestTimeout)
+	defer cancel()
+
+	req := anthropicRequest{Model: a.model, MaxTokens: maxTokens, System: systemPrompt, Messages: messages}
+	var resp anthropicResponse
+	headers := map[string]string{"x-api-key": a.apiKey, "anthropic-version": anthropicVersion}
+	if err := postAIJSON(ctx, chatMessagesURL, headers, req, &resp); err != nil {
+		return ChatReply{}, fmt.Errorf("anthropic messages: %w", err)
+	}
+
+	var b strings.Builder
+	for _, block := range resp.Content {
+		if block.Type == "text" {
+			b.WriteString(block.Text)
+		}
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return ChatReply{}, fmt.Errorf("empty anthropic response")
+	}
+	return ChatReply{
+		Content: b.String(),
+		Model:   resp.Model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.InputTokens,
+			CompletionTokens: resp.Usage.OutputTokens,
+			TotalTokens:      resp.Usage.InputTokens + resp.Usage.OutputTokens,
+		},
+	}, nil
+}
+
+// anthropicStreamEvent is the part of a streamed Messages API event used
+// here: the model and prompt tokens arrive in message_start, text in
+// content_block_delta and the output tokens in message_delta.
+type anthropicStreamEvent struct {
+	Type    string `json:"type"`
+	Message struct {
+		Model string `json:"model"`
+		Usage struct {
+			InputTokens int `json:"input_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+	Delta struct {
+		Type string `json:"type"`
+		Text string `json:"text"`
+	} `json:"delta"`
+	Usage struct {
+		OutputTokens int `json:"output_tokens"`
+	} `json:"usage"`
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+func (a *anthropicProvider) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiStreamTimeout)
+	defer cancel()
+
+	req := anthropicRequest{Model: a.model, MaxTokens: maxTokens, System: systemPrompt, Messages: messages, Stream: true}
+	headers := map[string]string{"x-api-key": a.apiKey, "anthropic-version": anthropicVersion}
+	var reply ChatReply
+	var b strings.Builder
+	err := streamAIJSON(ctx, chatMessagesURL, headers, req, func(line string) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var event anthropicStreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		switch event.Type {
+		case "message_start":
+			reply.Model = event.Message.Model
+			reply.Usage.PromptTokens = event.Message.Usage.InputTokens
+		case "content_block_delta":
+			if event.Delta.Type == "text_delta" && event.Delta.Text != "" {
+				b.WriteString(event.Delta.Text)
+				if onDelta != nil {
+					onDelta(event.Delta.Text)
+				}
+			}
+		case "message_delta":
+			reply.Usage.CompletionTokens = event.Usage.OutputTokens
+		case "error":
+			return fmt.Errorf("stream error: %s", event.Error.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatReply{}, fmt.Errorf("anthropic messages: %w", err)
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return ChatReply{}, fmt.Errorf("empty anthropic response")
+	}
+	reply.Content = b.String()
+	reply.Usage.TotalTokens = reply.Usage.PromptTokens + reply.Usage.CompletionTokens
+	return reply, nil
+}
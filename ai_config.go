@@ -0,0 +1,88 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// AI settings come from the environment, through appConfig.AI:
+//
+//	AI_PROVIDERS      provider order, e.g. "anthropic,openai" (default openai)
+//	OPENAI_MODEL, ANTHROPIC_MODEL, GEMINI_MODEL, OLLAMA_MODEL
+//	                  extraction model per provider
+//	OPENAI_IMAGE_MODEL, GEMINI_IMAGE_MODEL
+//	                  image generation models
+//	IMAGE_PROVIDER    who generates missing photos; see image_gen.go
+//	AI_MAX_TOKENS     output token limit for recipe extraction
+//	RECIPE_SCHEMA_FILE
+//	                  extraction schema to use instead of the built-in one
+//
+// Admins can pick the provider and model for a single import; see
+// parseAIModelOverride.
+
+var aiModelEnv = map[string]string{
+	aiProviderOpenAI:    "OPENAI_MODEL",
+	aiProviderAnthropic: "ANTHROPIC_MODEL",
+	aiProviderGemini:    "GEMINI_MODEL",
+	aiProviderOllama:    "OLLAMA_MODEL",
+}
+
+// aiModelFor is the configured extraction model of a provider, or "" for
+// the provider's default.
+func aiModelFor(provider string) string {
+	return appConfig.AI.Models[provider]
+}
+
+// parseAIModelOverride reads "provider:model", "provider", or a bare model
+// for the first configured provider.
+func parseAIModelOverride(override string) (provider, model string, err error) {
+	override = strings.TrimSpace(override)
+	if name, rest, ok := strings.Cut(override, ":"); ok {
+		if _, known := aiModelEnv[strings.ToLower(name)]; known {
+			provider, model = strings.ToLower(name), strings.TrimSpace(rest)
+		}
+	}
+	if provider == "" {
+		if _, known := aiModelEnv[strings.ToLower(override)]; known {
+			return strings.ToLower(override), "", nil
+		}
+		provider, model = aiProviderOpenAI, override
+		if names := aiProviderNames(); len(names) > 0 {
+			provider = names[0]
+		}
+	}
+	if strings.ContainsAny(model, " \t\r\n") || len(model) > 100 {
+		return "", "", fmt.Errorf("invalid model %q", model)
+	}
+	return provider, model, nil
+}
+
+// validateAIConfig checks that the configured providers can be built and
+// the schema loads, at startup so a typo fails the deploy rather than every
+// import; loadAppConfig has checked the values themselves. A provider
+// without a key is only logged, as failover skips it.
+func validateAIConfig() error {
+	var errs []error
+	usable := 0
+	for _, name := range aiProviderNames() {
+		if _, err := aiProviderByName(name, ""); err != nil {
+			if errors.Is(err, errAINoKey) {
+				log.Printf("Config: AI provider %s has no API key and will be skipped", name)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("AI_PROVIDERS: %s: %w", name, err))
+			continue
+		}
+		usable++
+	}
+	if len(aiProviderNames()) > 0 && usable == 0 && len(errs) == 0 {
+		log.Printf("Config: none of AI_PROVIDERS is usable; falling back to OpenAI")
+	}
+
+	if _, err := recipeResponseSchema(); err != nil {
+		errs = append(errs, fmt.Errorf("RECIPE_SCHEMA_FILE: %w", err))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	defaultGeminiModel      = "gemini-2.5-flash"
+	defaultGeminiImageModel = "gemini-2.5-flash-image"
+	geminiAPIBase           = "https://generativelanguage.googleapis.com/v1beta/models/"
+)
+
+// geminiProvider is the Google Gemini API backend, used with
+// GEMINI_API_KEY. Recipes use JSON output constrained to the recipe schema;
+// photos come from the image model.
+type geminiProvider struct {
+	apiKey     string
+	model      string
+	imageModel string
+}
+
+func newGeminiProvider(apiKey, model string) *geminiProvider {
+	if model == "" {
+		model = defaultGeminiModel
+	}
+	imageModel := appConfig.AI.GeminiImageModel
+	if imageModel == "" {
+		imageModel = defaultGeminiImageModel
+	}
+	return &geminiProvider{apiKey: apiKey, model: model, imageModel: imageModel}
+}
+
+func (g *geminiProvider) Name() string {
+	return aiProviderGemini
+}
+
+func (g *geminiProvider) Model() string {
+	return g.model
+}
+
+type geminiPart struct {
+	Text       string `json:"text,omitempty"`
+	InlineData *struct {
+		MimeType string `json:"mimeType"`
+		Data     string `json:"data"`
+	} `json:"inlineData,omitempty"`
+}
+
+type geminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []geminiPart `json:"parts"`
+}
+
+type geminiRequest struct {
+	SystemInstruction *geminiContent  `json:"systemInstruction,omitempty"`
+	Contents          []geminiContent `json:"contents"`
+	GenerationConfig  map[string]any  `json:"generationConfig,omitempty"`
+}
+
+type geminiResponse struct {
+	ResponseID   string `json:"responseId"`
+	ModelVersion string `json:"modelVersion"`
+	Candidates   []struct {
+		Content geminiContent `json:"content"`
+	} `json:"candidates"`
+	UsageMetadata struct {
+		PromptTokenCount     int `json:"promptTokenCount"`
+		CandidatesTokenCount int `json:"candidatesTokenCount"`
+		TotalTokenCount      int `json:"totalTokenCount"`
+	} `json:"usageMetadata"`
+}
+
+func (g *geminiProvider) generate(ctx context.Context, model string, req geminiRequest) (geminiResponse, error) {
+	var resp geminiResponse
+	endpoint := geminiAPIBase + url.PathEscape(model) + ":generateContent"
+	if err := postAIJSON(ctx, endpoint, map[string]string{"x-goog-api-key": g.apiKey}, req, &resp); err != nil {
+		return resp, fmt.Errorf("gemini generate: %w", err)
+	}
+	if len(resp.Candidates) == 0 {
+		return resp, fmt.Errorf("empty gemini response")
+	}
+	return resp, nil
+}
+
+// jsonText returns the text of a reply, JSON for structured output.
+func (r geminiResponse) jsonText() string {
+	var b strings.Builder
+	for _, part := range r.Candidates[0].Content.Parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+func (g *geminiProvider) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiRecipeTimeout)
+	defer cancel()
+
+	schema, err := recipeResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := g.generate(ctx, g.model, geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: systemPrompt}}},
+		Contents:          []geminiContent{{Role: "user", Parts: []geminiPart{{Text: prompt}}}},
+		GenerationConfig: map[string]any{
+			"maxOutputTokens":    maxTokens,
+			"temperature":        0,
+			"responseMimeType":   "application/json",
+			"responseJsonSchema": schema,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeRecipeResponse(resp.jsonText())
+	if err != nil {
+		return nil, err
+	}
+	response.ID = resp.ResponseID
+	response.Model = resp.ModelVersion
+	response.Usage = Usage{
+		PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+		CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+		TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+	}
+	return response, nil
+}
+
+func (g *geminiProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	resp, err := g.generate(ctx, g.imageModel, geminiRequest{
+		Contents:         []geminiContent{{Role: "user", Parts: []geminiPart{{Text: prompt}}}},
+		GenerationConfig: map[string]any{"responseModalities": []string{"IMAGE"}},
+	})
+	if err != nil {
+		return GeneratedImage{}, fmt.Errorf("failed to generate image: %w", err)
+	}
+	for _, part := range resp.Candidates[0].Content.Parts {
+		if part.InlineData == nil {
+			continue
+		}
+		data, err := base64.StdEncoding.DecodeString(part.InlineData.Data)
+		if err != nil {
+			return GeneratedImage{}, fmt.Errorf("decode generated image: %w", err)
+		}
+		return GeneratedImage{Data: data, ContentType: part.InlineData.MimeType, Model: g.imageModel}, nil
+	}
+	return GeneratedImage{}, fmt.Errorf("no image returned")
+}
+
+func (g *geminiProvider) Validate(title, image string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	resp, err := g.generate(ctx, g.model, geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: "You are an assistant validating if an image title matches its content."}}},
+		Contents:          []geminiContent{{Role: "user", Parts: []geminiPart{{Text: fmt.Sprintf(`{"title": %q, "image": %q}`, title, image)}}}},
+		GenerationConfig: map[string]any{
+			"temperature":        0,
+			"responseMimeType":   "application/json",
+			"responseJsonSchema": json.RawMessage(`{"type":"object","properties":{"matches":{"type":"boolean"}},"required":["matches"]}`),
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Matches bool `json:"matches"`
+	}
+	if err := json.Unmarshal([]byte(resp.jsonText()), &result); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.Matches, nil
+}
+
+func (g *geminiProvider) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiRequestTimeout)
+	defer cancel()
+
+	resp, err := g.generate(ctx, g.model, geminiChatRequest(systemPrompt, messages, maxTokens))
+	if err != nil {
+		return ChatReply{}, err
+	}
+	content := resp.jsonText()
+	if strings.TrimSpace(content) == "" {
+		return ChatReply{}, fmt.Errorf("empty gemini response")
+	}
+	return ChatReply{
+		Content: content,
+		Model:   resp.ModelVersion,
+		Usage: Usage{
+			PromptTokens:     resp.UsageMetadata.PromptTokenCount,
+			CompletionTokens: resp.UsageMetadata.CandidatesTokenCount,
+			TotalTokens:      resp.UsageMetadata.TotalTokenCount,
+		},
+	}, nil
+}
+
+// ChatStream reads streamGenerateContent as server-sent events. Each event
+// is a partial response; the usage in the last one covers the whole reply.
+func (g *geminiProvider) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiStreamTimeout)
+	defer cancel()
+
+	endpoint := geminiAPIBase + url.PathEscape(g.model) + ":streamGenerateContent?alt=sse"
+	var reply ChatReply
+	var b strings.Builder
+	err := streamAIJSON(ctx, endpoint, map[string]string{"x-goog-api-key": g.apiKey}, geminiChatRequest(systemPrompt, messages, maxTokens), func(line string) error {
+		data, ok := sseData(line)
+		if !ok {
+			return nil
+		}
+		var chunk geminiResponse
+		if err := json.Unmarshal([]byte(data), &chunk); err != nil {
+			return fmt.Errorf("decode event: %w", err)
+		}
+		if chunk.ModelVersion != "" {
+			reply.Model = chunk.ModelVersion
+		}
+		if chunk.UsageMetadata.TotalTokenCount > 0 {
+			reply.Usage = Usage{
+				PromptTokens:     chunk.UsageMetadata.PromptTokenCount,
+				CompletionTokens: chunk.UsageMetadata.CandidatesTokenCount,
+				TotalTokens:      chunk.UsageMetadata.TotalTokenCount,
+			}
+		}
+		if len(chunk.Candidates) == 0 {
+			return nil
+		}
+		if text := chunk.jsonText(); text != "" {
+			b.WriteString(text)
+			if onDelta != nil {
+				onDelta(text)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatReply{}, fmt.Errorf("gemini generate: %w", err)
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return ChatReply{}, fmt.Errorf("empty gemini response")
+	}
+	reply.Content = b.String()
+	return reply, nil
+}
+
+func geminiChatRequest(systemPrompt string, messages []chatMessage, maxTokens int) geminiRequest {
+	req := geminiRequest{
+		SystemInstruction: &geminiContent{Parts: []geminiPart{{Text: systemPrompt}}},
+		GenerationConfig:  map[string]any{"maxOutputTokens": maxTokens},
+	}
+	for _, message := range messages {
+		// Gemini calls the assistant "model"
+		role := message.Role
+		if role == "assistant" {
+			role = "model"
+		}
+		req.Contents = append(req.Contents, geminiContent{Role: role, Parts: []geminiPart{{Text: message.Content}}})
+	}
+	return req
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultOllamaURL   = "http://localhost:11434"
+	defaultOllamaModel = "llama3.1"
+)
+
+// ollamaProvider runs extraction on a local model through Ollama's native
+// chat API (OLLAMA_URL, OLLAMA_MODEL, OLLAMA_TIMEOUT), so pages never leave
+// the machine.
+// Output is constrained to the recipe schema with Ollama's structured
+// outputs. There is no image generation; with ollama alone, recipes without
+// a photo keep none.
+type ollamaProvider struct {
+	baseURL string
+	model   string
+}
+
+func newOllamaProvider(baseURL, model string) *ollamaProvider {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		baseURL = defaultOllamaURL
+	}
+	if model = strings.TrimSpace(model); model == "" {
+		model = defaultOllamaModel
+	}
+	return &ollamaProvider{baseURL: baseURL, model: model}
+}
+
+func (o *ollamaProvider) Name() string {
+	return aiProviderOllama
+}
+
+func (o *ollamaProvider) Model() string {
+	return o.model
+}
+
+type ollamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []chatMessage   `json:"messages"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Stream   bool            `json:"stream"`
+	Options  map[string]any  `json:"options"`
+}
+
+type ollamaChatResponse struct {
+	Model   string `json:"model"`
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	PromptEvalCount int    `json:"prompt_eval_count"`
+	EvalCount       int    `json:"eval_count"`
+	Error           string `json:"error"`
+}
+
+func (o *ollamaProvider) chat(ctx context.Context, system, prompt string, format json.RawMessage, maxTokens int) (ollamaChatResponse, error) {
+	return o.send(ctx, ollamaChatRequest{
+		Model: o.model,
+		Messages: []chatMessage{
+			{Role: "system", Content: system},
+			{Role: "user", Content: prompt},
+		},
+		Format:  format,
+		Options: map[string]any{"temperature": 0, "num_predict": maxTokens},
+	})
+}
+
+func (o *ollamaProvider) send(ctx context.Context, req ollamaChatRequest) (ollamaChatResponse, error) {
+	var resp ollamaChatResponse
+	if err := postAIJSON(ctx, o.baseURL+"/api/chat", nil, req, &resp); err != nil {
+		return resp, fmt.Errorf("ollama chat: %w", err)
+	}
+	if strings.TrimSpace(resp.Message.Content) == "" {
+		return resp, fmt.Errorf("empty ollama response")
+	}
+	return resp, nil
+}
+
+func (o *ollamaProvider) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	// Local models on modest hardware can take a while
+	ctx, cancel := context.WithTimeout(ctx, appConfig.AI.OllamaTimeout)
+	defer cancel()
+
+	schema, err := recipeResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+	resp, err := o.chat(ctx, systemPrompt, prompt, schema, maxTokens)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := decodeRecipeResponse(resp.Message.Content)
+	if err != nil {
+		return nil, err
+	}
+	response.Model = resp.Model
+	response.Usage = Usage{
+		PromptTokens:     resp.PromptEvalCount,
+		CompletionTokens: resp.EvalCount,
+		TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+	}
+	return response, nil
+}
+
+func (o *ollamaProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	return GeneratedImage{}, fmt.Errorf("generate image: %w", errAIUnsupported)
+}
+
+func (o *ollamaProvider) Validate(title, image string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	resp, err := o.chat(ctx,
+		"You are an assistant validating if an image title matches its content. Respond only with a JSON object containing a boolean field 'matches'.",
+		fmt.Sprintf(`{"title": %q, "image": %q}`, title, image),
+		json.RawMessage(`{"type":"object","properties":{"matches":{"type":"boolean"}},"required":["matches"]}`), 1024)
+	if err != nil {
+		return false, err
+	}
+	var result struct {
+		Matches bool `json:"matches"`
+	}
+	if err := json.Unmarshal([]byte(resp.Message.Content), &result); err != nil {
+		return false, fmt.Errorf("failed to parse response: %w", err)
+	}
+	return result.Matches, nil
+}
+
+func (o *ollamaProvider) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, appConfig.AI.OllamaTimeout)
+	defer cancel()
+
+	resp, err := o.send(ctx, ollamaChatRequest{
+		Model:    o.model,
+		Messages: append([]chatMessage{{Role: "system", Content: systemPrompt}}, messages...),
+		Options:  map[string]any{"num_predict": maxTokens},
+	})
+	if err != nil {
+		return ChatReply{}, err
+	}
+	return ChatReply{
+		Content: resp.Message.Content,
+		Model:   resp.Model,
+		Usage: Usage{
+			PromptTokens:     resp.PromptEvalCount,
+			CompletionTokens: resp.EvalCount,
+			TotalTokens:      resp.PromptEvalCount + resp.EvalCount,
+		},
+	}, nil
+}
+
+// ChatStream reads Ollama's streamed chat, one JSON object per line; the
+// token counts come with the last.
+func (o *ollamaProvider) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, appConfig.AI.OllamaTimeout)
+	defer cancel()
+
+	req := ollamaChatRequest{
+		Model:    o.model,
+		Messages: append([]chatMessage{{Role: "system", Content: systemPrompt}}, messages...),
+		Stream:   true,
+		Options:  map[string]any{"num_predict": maxTokens},
+	}
+	var reply ChatReply
+	var b strings.Builder
+	err := streamAIJSON(ctx, o.baseURL+"/api/chat", nil, req, func(line string) error {
+		var chunk ollamaChatResponse
+		if err := json.Unmarshal([]byte(line), &chunk); err != nil {
+			return fmt.Errorf("decode chunk: %w", err)
+		}
+		if chunk.Error != "" {
+			return fmt.Errorf("stream error: %s", chunk.Error)
+		}
+		reply.Model = chunk.Model
+		if chunk.EvalCount > 0 {
+			reply.Usage = Usage{
+				PromptTokens:     chunk.PromptEvalCount,
+				CompletionTokens: chunk.EvalCount,
+				TotalTokens:      chunk.PromptEvalCount + chunk.EvalCount,
+			}
+		}
+		if chunk.Message.Content != "" {
+			b.WriteString(chunk.Message.Content)
+			if onDelta != nil {
+				onDelta(chunk.Message.Content)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatReply{}, fmt.Errorf("ollama chat: %w", err)
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return ChatReply{}, fmt.Errorf("empty ollama response")
+	}
+	reply.Content = b.String()
+	return reply, nil
+}
@@ -0,0 +1,362 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/davecgh/go-spew/spew"
+	"github.com/sashabaranov/go-openai"
+)
+
+const (
+	defaultOpenAIModel      = "gpt-5-mini"
+	defaultOpenAIImageModel = openai.CreateImageModelDallE2
+)
+
+// openAIProvider is the OpenAI backend, used with OPENAI_KEY. With
+// OPENAI_BASE_URL it talks to any OpenAI-compatible server instead, such as
+// Ollama's /v1, llama.cpp or vLLM, where the key may be empty.
+type openAIProvider struct {
+	client *openai.Client
+	engine string
+	debug  bool
+}
+
+func newOpenAIProvider(apiKey, engine string) *openAIProvider {
+	if engine == "" {
+		engine = defaultOpenAIModel
+	}
+	config := openai.DefaultConfig(apiKey)
+	if baseURL := appConfig.AI.OpenAIBaseURL; baseURL != "" {
+		config.BaseURL = baseURL
+	}
+	return &openAIProvider{
+		client: openai.NewClientWithConfig(config),
+		engine: engine,
+		debug:  appConfig.AI.Debug,
+	}
+}
+
+func (c *openAIProvider) Name() string {
+	return aiProviderOpenAI
+}
+
+func (c *openAIProvider) Model() string {
+	return c.engine
+}
+
+func (c *openAIProvider) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiRecipeTimeout)
+	defer cancel()
+
+	schemaJSON, err := recipeResponseSchema()
+	if err != nil {
+		return nil, err
+	}
+
+	req := openai.ChatCompletionRequest{
+		Model: c.engine,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: prompt,
+			},
+		},
+		MaxCompletionTokens: maxTokens,
+		Temperature:         0,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "recipe_response",
+				Schema: schemaJSON,
+				Strict: true,
+			},
+		},
+	}
+
+	if c.debug {
+		log.Printf("Request: %+v\n", req)
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.debug {
+		log.Printf("Response: %+v\n", resp)
+	}
+
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("empty OpenAI chat completion response")
+	}
+
+	response, err := decodeRecipeResponse(resp.Choices[0].Message.Content)
+	if err != nil {
+		return nil, err
+	}
+
+	response.ID = resp.ID
+	response.Object = resp.Object
+	response.Created = resp.Created
+	response.Model = resp.Model
+	response.SystemFingerprint = resp.SystemFingerprint
+	response.Usage = Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+
+	return response, nil
+}
+
+func (c *openAIProvider) Validate(title, image string) (bool, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	// Define the JSON schema for enforcing a boolean response with additionalProperties set to false
+	schemaJSON := `{
+		"type": "object",
+		"properties": {
+			"matches": {
+				"type": "boolean"
+			}
+		},
+		"required": ["matches"],
+		"additionalProperties": false
+	}`
+
+	req := openai.ChatCompletionRequest{
+		Model: c.engine,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: "You are an assistant validating if an image title matches its content. Respond only with a JSON object containing a boolean field 'matches'.",
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: fmt.Sprintf(`{"title": %q, "image": %q}`, title, image),
+			},
+		},
+		MaxCompletionTokens: 16000,
+		Temperature:         0,
+		ResponseFormat: &openai.ChatCompletionResponseFormat{
+			Type: openai.ChatCompletionResponseFormatTypeJSONSchema,
+			JSONSchema: &openai.ChatCompletionResponseFormatJSONSchema{
+				Name:   "image_validation",
+				Schema: json.RawMessage(schemaJSON),
+				Strict: true,
+			},
+		},
+	}
+
+	// Debug logging
+	//if c.debug {
+	//	log.Printf("Request: %+v\n", req)
+	//}
+
+	// Send the request
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return false, err
+	}
+
+	if c.debug {
+		log.Printf("Response: %+v\n", resp)
+	}
+
+	// Parse the response into a struct
+	var result struct {
+		Matches bool `json:"matches"`
+	}
+
+	if len(resp.Choices) > 0 {
+		if err := json.Unmarshal([]byte(resp.Choices[0].Message.Content), &result); err != nil {
+			return false, fmt.Errorf("failed to parse response: %w", err)
+		}
+		spew.Dump(resp.Choices)
+	}
+
+	return result.Matches, nil
+}
+
+func (c *openAIProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	model := appConfig.AI.OpenAIImageModel
+	if model == "" {
+		model = defaultOpenAIImageModel
+	}
+	req := openai.ImageRequest{
+		Model:          model,
+		Prompt:         prompt,
+		Size:           openai.CreateImageSize1024x1024,
+		N:              1,
+		ResponseFormat: openai.CreateImageResponseFormatURL,
+	}
+
+	resp, err := c.client.CreateImage(ctx, req)
+	if err != nil {
+		return GeneratedImage{}, fmt.Errorf("failed to generate image: %w", err)
+	}
+
+	if len(resp.Data) == 0 {
+		return GeneratedImage{}, fmt.Errorf("no image URL returned")
+	}
+
+	return GeneratedImage{URL: resp.Data[0].URL, Model: model}, nil
+}
+
+func (c *openAIProvider) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiRequestTimeout)
+	defer cancel()
+
+	req := openai.ChatCompletionRequest{
+		Model:               c.engine,
+		Messages:            []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: systemPrompt}},
+		MaxCompletionTokens: maxTokens,
+	}
+	for _, message := range messages {
+		req.Messages = append(req.Messages, openai.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return ChatReply{}, err
+	}
+	if len(resp.Choices) == 0 || strings.TrimSpace(resp.Choices[0].Message.Content) == "" {
+		return ChatReply{}, fmt.Errorf("empty OpenAI chat completion response")
+	}
+	return ChatReply{
+		Content: resp.Choices[0].Message.Content,
+		Model:   resp.Model,
+		Usage: Usage{
+			PromptTokens:     resp.Usage.PromptTokens,
+			CompletionTokens: resp.Usage.CompletionTokens,
+			TotalTokens:      resp.Usage.TotalTokens,
+		},
+	}, nil
+}
+
+func (c *openAIProvider) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	ctx, cancel := context.WithTimeout(ctx, aiStreamTimeout)
+	defer cancel()
+
+	req := openai.ChatCompletionRequest{
+		Model:               c.engine,
+		Messages:            []openai.ChatCompletionMessage{{Role: openai.ChatMessageRoleSystem, Content: systemPrompt}},
+		MaxCompletionTokens: maxTokens,
+		Stream:              true,
+		StreamOptions:       &openai.StreamOptions{IncludeUsage: true},
+	}
+	for _, message := range messages {
+		req.Messages = append(req.Messages, openai.ChatCompletionMessage{Role: message.Role, Content: message.Content})
+	}
+
+	stream, err := c.client.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		return ChatReply{}, err
+	}
+	defer stream.Close()
+
+	var reply ChatReply
+	var b strings.Builder
+	for {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return ChatReply{}, err
+		}
+		if chunk.Model != "" {
+			reply.Model = chunk.Model
+		}
+		if chunk.Usage != nil {
+			reply.Usage = Usage{
+				PromptTokens:     chunk.Usage.PromptTokens,
+				CompletionTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:      chunk.Usage.TotalTokens,
+			}
+		}
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		b.WriteString(chunk.Choices[0].Delta.Content)
+		if onDelta != nil {
+			onDelta(chunk.Choices[0].Delta.Content)
+		}
+	}
+	if strings.TrimSpace(b.String()) == "" {
+		return ChatReply{}, fmt.Errorf("empty OpenAI chat completion response")
+	}
+	reply.Content = b.String()
+	return reply, nil
+}
+
+func (c *openAIProvider) GenerateEnhancedFoodPrompt(foodItem string, maxTokens int) (*BasicResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	// Define the system prompt for generating detailed and visually rich descriptions
+	systemPrompt := "You are a food stylist and photographer specializing in creating vivid, visually appealing descriptions for food items. Your job is to generate enhanced and detailed prompts suitable for creating high-quality images."
+
+	// User prompt for the specific food item and context
+	userPrompt := fmt.Sprintf("Create a visually appealing description for '%s'. Include details about texture, color, lighting, setting, and arrangement. Max characters can not exceed 1000 chars.", foodItem)
+
+	req := openai.ChatCompletionRequest{
+		Model: c.engine,
+		Messages: []openai.ChatCompletionMessage{
+			{
+				Role:    openai.ChatMessageRoleSystem,
+				Content: systemPrompt,
+			},
+			{
+				Role:    openai.ChatMessageRoleUser,
+				Content: userPrompt,
+			},
+		},
+		MaxCompletionTokens: maxTokens,
+	}
+
+	if c.debug {
+		log.Printf("Request: %+v\n", req)
+	}
+
+	resp, err := c.client.CreateChatCompletion(ctx, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate enhanced prompt: %w", err)
+	}
+
+	if c.debug {
+		log.Printf("foodResponse: %+v\n", resp)
+	}
+
+	if len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return nil, fmt.Errorf("empty OpenAI chat completion response")
+	}
+
+	var basicResponse BasicResponse
+	basicResponse.ID = resp.ID
+	basicResponse.Object = resp.Object
+	basicResponse.Created = resp.Created
+	basicResponse.Model = resp.Model
+	basicResponse.Usage = Usage{
+		PromptTokens:     resp.Usage.PromptTokens,
+		CompletionTokens: resp.Usage.CompletionTokens,
+		TotalTokens:      resp.Usage.TotalTokens,
+	}
+	basicResponse.EnhancedPrompt = resp.Choices[0].Message.Content
+
+	return &basicResponse, nil
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Every AI call made for a user is recorded in ai_usage with its tokens and
+// an estimated cost, so the budget can be traced to users and pages. Costs
+// come from list prices per million tokens (or per image), matched on the
+// longest model name prefix since providers answer with dated model names.
+// AI_PRICES adds or overrides prices: "model=input/output" per million
+// tokens, or "model=price" per image. Unknown and local models cost 0.
+
+const (
+	aiOperationExtractRecipe = "extract_recipe"
+	aiOperationGenerateImage = "generate_image"
+	aiOperationValidateImage = "validate_image"
+	aiOperationChat          = "chat"
+)
+
+type aiPrice struct {
+	Input    float64 // USD per million prompt tokens
+	Output   float64 // USD per million completion tokens
+	PerImage float64
+}
+
+var aiModelPrices = map[string]aiPrice{
+	"gpt-5":                  {Input: 1.25, Output: 10},
+	"gpt-5-mini":             {Input: 0.25, Output: 2},
+	"gpt-5-nano":             {Input: 0.05, Output: 0.4},
+	"gpt-4.1":                {Input: 2, Output: 8},
+	"gpt-4.1-mini":           {Input: 0.4, Output: 1.6},
+	"gpt-4o":                 {Input: 2.5, Output: 10},
+	"gpt-4o-mini":            {Input: 0.15, Output: 0.6},
+	"claude-haiku-4-5":       {Input: 1, Output: 5},
+	"claude-sonnet-4":        {Input: 3, Output: 15},
+	"gemini-2.5-flash":       {Input: 0.3, Output: 2.5},
+	"gemini-2.5-flash-lite":  {Input: 0.1, Output: 0.4},
+	"gemini-2.5-pro":         {Input: 1.25, Output: 10},
+	"dall-e-2":               {PerImage: 0.02},
+	"dall-e-3":               {PerImage: 0.04},
+	"gemini-2.5-flash-image": {PerImage: 0.039},
+	"stable-image-core":      {PerImage: 0.03},
+	"stable-image-ultra":     {PerImage: 0.08},
+	"sd3.5-large":            {PerImage: 0.065},
+	"sd3.5-large-turbo":      {PerImage: 0.04},
+	"sd3.5-medium":           {PerImage: 0.035},
+}
+
+// aiPriceFor returns the price of model, preferring AI_PRICES.
+func aiPriceFor(model string) aiPrice {
+	model = strings.ToLower(strings.TrimSpace(model))
+	prices := aiModelPrices
+	if overrides := appConfig.AI.Prices; len(overrides) > 0 {
+		prices = make(map[string]aiPrice, len(aiModelPrices)+len(overrides))
+		for name, price := range aiModelPrices {
+			prices[name] = price
+		}
+		for name, price := range overrides {
+			prices[name] = price
+		}
+	}
+
+	names := make([]string, 0, len(prices))
+	for name := range prices {
+		names = append(names, name)
+	}
+	// Longest first so gpt-4o-mini doesn't match gpt-4o
+	sort.Slice(names, func(i, j int) bool { return len(names[i]) > len(names[j]) })
+	for _, name := range names {
+		if strings.HasPrefix(model, name) {
+			return prices[name]
+		}
+	}
+	return aiPrice{}
+}
+
+// parseAIPrices reads AI_PRICES, reporting each entry it can't.
+func parseAIPrices(raw string) (map[string]aiPrice, error) {
+	prices := map[string]aiPrice{}
+	var errs []error
+	for _, entry := range strings.Split(raw, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !ok || name == "" {
+			errs = append(errs, fmt.Errorf("%q is not model=price", entry))
+			continue
+		}
+		input, output, perToken := strings.Cut(value, "/")
+		in, err := strconv.ParseFloat(strings.TrimSpace(input), 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q has an invalid price", entry))
+			continue
+		}
+		if !perToken {
+			prices[name] = aiPrice{PerImage: in}
+			continue
+		}
+		out, err := strconv.ParseFloat(strings.TrimSpace(output), 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%q has an invalid price", entry))
+			continue
+		}
+		prices[name] = aiPrice{Input: in, Output: out}
+	}
+	return prices, errors.Join(errs...)
+}
+
+// estimateAICost is the list price in USD of a call to model.
+func estimateAICost(model string, usage Usage, images int) float64 {
+	price := aiPriceFor(model)
+	return (float64(usage.PromptTokens)*price.Input+float64(usage.CompletionTokens)*price.Output)/1e6 +
+		float64(images)*price.PerImage
+}
+
+// meteredProvider records each successful call of its provider as usage
+// of username for pageURL, and the latency of every call in the metrics.
+type meteredProvider struct {
+	AIProvider
+	username string
+	pageURL  string
+}
+
+// meterAIUsage wraps ai to record its calls. Failover providers are
+// metered one by one so the provider that answered is the one recorded.
+func meterAIUsage(ai AIProvider, username, pageURL string) AIProvider {
+	if providers, ok := ai.(failoverProvider); ok {
+		metered := make(failoverProvider, len(providers))
+		for i, provider := range providers {
+			metered[i] = meterAIUsage(provider, username, pageURL)
+		}
+		return metered
+	}
+	return meteredProvider{AIProvider: ai, username: username, pageURL: pageURL}
+}
+
+func (m meteredProvider) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	started := time.Now()
+	response, err := m.AIProvider.ExtractRecipe(ctx, prompt, systemPrompt, maxTokens)
+	observeAIRequest(m.Name(), aiOperationExtractRecipe, started, err)
+	if err == nil && response != nil {
+		model := response.Model
+		if model == "" {
+			model = m.Model()
+		}
+		m.record(aiOperationExtractRecipe, model, response.Usage, 0)
+	}
+	return response, err
+}
+
+func (m meteredProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	started := time.Now()
+	image, err := m.AIProvider.GenerateImage(prompt)
+	observeAIRequest(m.Name(), aiOperationGenerateImage, started, err)
+	if err == nil {
+		model := image.Model
+		if model == "" {
+			model = m.Model()
+		}
+		m.record(aiOperationGenerateImage, model, Usage{}, 1)
+	}
+	return image, err
+}
+
+func (m meteredProvider) Validate(title, image string) (bool, error) {
+	started := time.Now()
+	matches, err := m.AIProvider.Validate(title, image)
+	observeAIRequest(m.Name(), aiOperationValidateImage, started, err)
+	if err == nil {
+		m.record(aiOperationValidateImage, m.Model(), Usage{}, 0)
+	}
+	return matches, err
+}
+
+func (m meteredProvider) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	started := time.Now()
+	reply, err := m.AIProvider.Chat(ctx, systemPrompt, messages, maxTokens)
+	observeAIRequest(m.Name(), aiOperationChat, started, err)
+	if err == nil {
+		model := reply.Model
+		if model == "" {
+			model = m.Model()
+		}
+		m.record(aiOperationChat, model, reply.Usage, 0)
+	}
+	return reply, err
+}
+
+func (m meteredProvider) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	started := time.Now()
+	reply, err := m.AIProvider.ChatStream(ctx, systemPrompt, messages, maxTokens, onDelta)
+	observeAIRequest(m.Name(), aiOperationChat, started, err)
+	if err == nil {
+		model := reply.Model
+		if model == "" {
+			model = m.Model()
+		}
+		m.record(aiOperationChat, model, reply.Usage, 0)
+	}
+	return reply, err
+}
+
+func (m meteredProvider) record(operation, model string, usage Usage, images int) {
+	m.recordFor(m.Name(), operation, model, usage, images)
+}
+
+// recordFor records a call made for m's user and page by another provider,
+// such as a separate image generator.
+func (m meteredProvider) recordFor(provider, operation, model string, usage Usage, images int) {
+	observeAIUsage(provider, model, operation, usage)
+	if recipeRepo == nil {
+		return
+	}
+	cost := 0.0
+	if provider != aiProviderOllama {
+		cost = estimateAICost(model, usage, images)
+	}
+	entry := AIUsageEntry{
+		Username:  m.username,
+		Operation: operation,
+		Provider:  provider,
+		Model:     model,
+		URL:       m.pageURL,
+		Usage:     usage,
+		CostUSD:   cost,
+	}
+	if err := recipeRepo.RecordAIUsage(entry); err != nil {
+		log.Printf("AI: failed to record usage: %v", err)
+	}
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// allergenKeywords expands allergen groups into the ingredient words that
+// indicate them. Terms not listed here match only themselves.
+var allergenKeywords = map[string][]string{
+	"shellfish": {"shellfish", "shrimp", "prawn", "crab", "lobster", "crawfish", "crayfish", "scallop", "clam", "mussel", "oyster"},
+	"peanut":    {"peanut"},
+	"tree nut":  {"almond", "walnut", "pecan", "cashew", "pistachio", "hazelnut", "macadamia", "brazil nut", "pine nut"},
+	"tree nuts": {"almond", "walnut", "pecan", "cashew", "pistachio", "hazelnut", "macadamia", "brazil nut", "pine nut"},
+	"dairy":     {"milk", "butter", "buttermilk", "cheese", "cream", "yogurt", "ghee", "parmesan", "mozzarella", "cheddar", "ricotta", "whey"},
+	"egg":       {"egg", "mayonnaise"},
+	"eggs":      {"egg", "mayonnaise"},
+	"fish":      {"fish", "salmon", "tuna", "cod", "tilapia", "halibut", "anchovy", "sardine", "trout", "mackerel"},
+	"gluten":    {"flour", "wheat", "bread", "breadcrumb", "panko", "pasta", "barley", "rye", "couscous", "noodle"},
+	"wheat":     {"wheat", "flour", "bread", "breadcrumb", "panko", "pasta", "couscous"},
+	"soy":       {"soy", "tofu", "edamame", "tempeh", "miso"},
+	"sesame":    {"sesame", "tahini"},
+}
+
+// expandExcludeTerms resolves allergen groups into concrete ingredient keywords.
+func expandExcludeTerms(terms []string) []string {
+	seen := make(map[string]struct{})
+	out := make([]string, 0, len(terms))
+	for _, term := range normalizeTerms(terms) {
+		keywords, ok := allergenKeywords[term]
+		if !ok {
+			keywords = []string{term}
+		}
+		for _, kw := range keywords {
+			if _, dup := seen[kw]; dup {
+				continue
+			}
+			seen[kw] = struct{}{}
+			out = append(out, kw)
+		}
+	}
+	return out
+}
+
+// ingredientText returns a space-padded, punctuation-free lowercase view of
+// all ingredient lines so keywords can be matched on word boundaries.
+func ingredientText(recipe Recipe) string {
+	lines := append([]string(nil), recipe.Ingredients...)
+	for _, d := range recipe.ParsedIngredients {
+		lines = append(lines, d.Description)
+	}
+	text := strings.ToLower(strings.Join(lines, " "))
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	return " " + strings.Join(words, " ") + " "
+}
+
+func containsIngredientKeyword(text, keyword string) bool {
+	keyword = strings.Join(strings.Fields(keyword), " ")
+	for _, form := range []string{keyword, keyword + "s", keyword + "es"} {
+		if strings.Contains(text, " "+form+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// excludeRecipesWithIngredients drops recipes mentioning any excluded ingredient
+// or allergen. Matching errs on the side of excluding.
+func excludeRecipesWithIngredients(recipes []Recipe, terms []string) []Recipe {
+	keywords := expandExcludeTerms(terms)
+	if len(keywords) == 0 {
+		return recipes
+	}
+
+	filtered := make([]Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		text := ingredientText(recipe)
+		excluded := false
+		for _, kw := range keywords {
+			if containsIngredientKeyword(text, kw) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			filtered = append(filtered, recipe)
+		}
+	}
+	return filtered
+}
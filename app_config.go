@@ -0,0 +1,758 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+)
+
+// AppConfig is the deployment: where the server listens, its database, the
+// secrets it signs with and the services it talks to. main loads it once at
+// startup and the code reads appConfig rather than the environment:
+//
+//	PORT, METRICS_PORT      API port (default 8080), worker metrics port (default 9090)
+//	PUBLIC_BASE_URL         origin for links that leave the app; see requestBaseURL
+//	DB_DRIVER, DATABASE_DSN sqlite (default) or mysql; see InitDatabase
+//	JWT_SECRET              signs session tokens (required)
+//	JWT_EXPIRATION          session length, overriding each token's own TTL
+//	ADMIN_USERS             comma separated admin usernames
+//	CLOUDFLARE_ENDPOINT, CLOUDFLARE_ACCESS_KEY, CLOUDFLARE_SECRET_KEY
+//	                        R2 storage for images, archives and backups
+//	MAILGUN_DOMAIN, MAILGUN_API_KEY, MAILGUN_FROM
+//	                        outgoing mail
+//	MAILGUN_WEBHOOK_SIGNING_KEY, PASSWORD_RESET_URL
+//	OPENAI_KEY, OPENAI_BASE_URL, ANTHROPIC_API_KEY, GEMINI_API_KEY,
+//	OLLAMA_URL, STABILITY_API_KEY
+//	                        AI provider credentials; see ai_config.go
+//	USDA_FDC_API_KEY, USDA_FDC_URL
+//	                        nutrition lookups; see usda_fdc.go
+//
+// The tuning knobs (AI_*, SCRAPER_*, QUEUE_*, RETENTION_*, INACTIVITY_*,
+// SENTRY_* and the like) are loaded with it too, starting from defaults
+// that suit a single small instance; each is described where it's used.
+// Nothing else reads the environment, except CONFIG_FILE, which says where
+// to find it, and the OTEL_* variables the trace exporter reads itself.
+type AppConfig struct {
+	Server    ServerConfig
+	Database  DatabaseConfig
+	Auth      AuthConfig
+	Storage   StorageConfig
+	Mail      MailConfig
+	AI        AIConfig
+	Nutrition NutritionConfig
+	Scraper   ScraperConfig
+	Queue     QueueSettings
+	Jobs      JobsConfig
+	Reporting ReportingConfig
+}
+
+type ServerConfig struct {
+	Port          string
+	MetricsPort   string
+	PublicBaseURL string
+	// RunMode is RUN_MODE, or worker or api from WORKER_ONLY and API_ONLY;
+	// see runModeFromArgs
+	RunMode string
+	// CacheWarmUsers is CACHE_WARM_USERS; see warmRecipeCaches
+	CacheWarmUsers int
+	// AllowPrivateWebhooks is WEBHOOK_ALLOW_PRIVATE; see webhookClient
+	AllowPrivateWebhooks bool
+	// RequestTimeout and LongRequestTimeout are REQUEST_TIMEOUT and
+	// REQUEST_TIMEOUT_LONG; see requestTimeout
+	RequestTimeout     time.Duration
+	LongRequestTimeout time.Duration
+}
+
+type DatabaseConfig struct {
+	Driver string
+	DSN    string
+	// MaxOpenConns is DB_MAX_OPEN_CONNS, 0 for the driver's default
+	MaxOpenConns int
+	// SlowQuery is DB_SLOW_QUERY; see gormConfig
+	SlowQuery   time.Duration
+	PrepareStmt bool
+}
+
+type AuthConfig struct {
+	JWTSecret string
+	// JWTExpiry is nil when JWT_EXPIRATION isn't set
+	JWTExpiry  *time.Duration
+	AdminUsers []string
+}
+
+type StorageConfig struct {
+	Endpoint  string
+	AccessKey string
+	SecretKey string
+}
+
+// Enabled reports whether R2 is configured; validate makes sure it is
+// configured completely or not at all.
+func (s StorageConfig) Enabled() bool {
+	return s.Endpoint != ""
+}
+
+type MailConfig struct {
+	Domain            string
+	APIKey            string
+	From              string
+	WebhookSigningKey string
+	PasswordResetURL  string
+}
+
+// Enabled reports whether outgoing mail is configured.
+func (m MailConfig) Enabled() bool {
+	return m.Domain != "" && m.APIKey != "" && m.From != ""
+}
+
+// AIConfig holds the AI provider credentials and the settings ai_config.go
+// describes.
+type AIConfig struct {
+	OpenAIKey     string
+	OpenAIBaseURL string
+	AnthropicKey  string
+	GeminiKey     string
+	OllamaURL     string
+	StabilityKey  string
+
+	Providers []string
+	// Models holds each provider's extraction model, when one is set
+	Models              map[string]string
+	OpenAIImageModel    string
+	GeminiImageModel    string
+	StabilityModel      string
+	ImageProvider       string
+	ImagePlaceholderURL string
+	MaxTokens           int
+	SchemaFile          string
+	// Prices are the AI_PRICES overrides of aiModelPrices
+	Prices        map[string]aiPrice
+	Debug         bool
+	CacheTTL      time.Duration
+	OllamaTimeout time.Duration
+}
+
+type NutritionConfig struct {
+	FDCAPIKey string
+	FDCURL    string
+}
+
+// ScraperConfig holds the SCRAPER_* settings and the helper binaries the
+// scraper runs.
+type ScraperConfig struct {
+	ChromiumBin  string
+	PDFToTextBin string
+	UserAgent    string
+	// BrowserPoolSize is 0 to match QUEUE_CONCURRENCY
+	BrowserPoolSize  int
+	NavTimeout       time.Duration
+	HTTPTimeout      time.Duration
+	FetchMode        string
+	DomainFetchModes map[string]string
+	// Proxy is nil for a direct connection, and so are the DomainProxies
+	// entries set to "direct"
+	Proxy            *url.URL
+	DomainProxies    map[string]*url.URL
+	DomainInterval   time.Duration
+	DomainIntervals  map[string]time.Duration
+	DismissConsent   bool
+	ConsentSelectors []string
+	// MaxRetries counts the retries after the first attempt
+	MaxRetries        int
+	RetryDelay        time.Duration
+	RetryMaxDelay     time.Duration
+	ArchiveSourceHTML bool
+}
+
+// JobsConfig schedules the background jobs a worker runs. An interval of 0
+// turns its job off.
+type JobsConfig struct {
+	BackupInterval    time.Duration
+	BackupKeep        int
+	ReprocessInterval time.Duration
+	RetentionInterval time.Duration
+	Retention         RetentionConfig
+	Inactivity        InactivityPolicy
+}
+
+// RetentionConfig is how long each kind of record is kept; 0 keeps it
+// forever. See loadRetentionPolicies.
+type RetentionConfig struct {
+	Queue                 time.Duration
+	FailedQueue           time.Duration
+	FailedQueueArchive    bool
+	UnusedAccounts        time.Duration
+	UnusedAccountsArchive bool
+	FeedTokens            time.Duration
+	PasswordResets        time.Duration
+}
+
+type ReportingConfig struct {
+	SentryDSN         string
+	SentryEnvironment string
+	SentryRelease     string
+	SentrySampleRate  float64
+	// Tracing is on when an OTLP endpoint is set
+	Tracing bool
+}
+
+// appConfig is the loaded configuration; main replaces the defaults with
+// loadAppConfig at startup.
+var appConfig = defaultAppConfig()
+
+func defaultAppConfig() AppConfig {
+	return AppConfig{
+		Server: ServerConfig{
+			Port:               "8080",
+			MetricsPort:        "9090",
+			RunMode:            runModeAll,
+			CacheWarmUsers:     defaultCacheWarmUsers,
+			RequestTimeout:     defaultRequestTimeout,
+			LongRequestTimeout: defaultLongRequestTimeout,
+		},
+		Database: DatabaseConfig{
+			Driver:      dbDriverSQLite,
+			SlowQuery:   defaultSlowQueryThreshold,
+			PrepareStmt: true,
+		},
+		AI: AIConfig{
+			ImageProvider: imageProviderAI,
+			MaxTokens:     defaultAIMaxTokens,
+			CacheTTL:      defaultAICacheTTL,
+			OllamaTimeout: aiRecipeTimeout,
+		},
+		Nutrition: NutritionConfig{FDCURL: defaultFDCBaseURL},
+		Scraper: ScraperConfig{
+			NavTimeout:        defaultScraperNavTimeout,
+			HTTPTimeout:       defaultScraperHTTPTimeout,
+			FetchMode:         fetchModeBrowser,
+			DomainInterval:    defaultScrapeInterval,
+			DismissConsent:    true,
+			MaxRetries:        defaultScraperRetries,
+			RetryDelay:        defaultScraperRetryDelay,
+			RetryMaxDelay:     defaultScraperRetryMaxDelay,
+			ArchiveSourceHTML: true,
+		},
+		Queue: defaultQueueSettings(),
+		Jobs: JobsConfig{
+			BackupInterval:    defaultBackupInterval,
+			BackupKeep:        defaultBackupKeep,
+			ReprocessInterval: defaultReprocessInterval,
+			RetentionInterval: defaultRetentionInterval,
+			Retention:         RetentionConfig{Queue: defaultQueueRetention},
+			Inactivity: InactivityPolicy{
+				FreezeNotice:      14 * 24 * time.Hour,
+				PurgeNotice:       30 * 24 * time.Hour,
+				ExportBeforePurge: true,
+				CheckInterval:     24 * time.Hour,
+			},
+		},
+		Reporting: ReportingConfig{SentrySampleRate: 1},
+	}
+}
+
+// loadAppConfig reads the configuration from the environment and checks
+// it, reporting every missing or invalid variable at once so a deploy can
+// be fixed in one go.
+func loadAppConfig() (AppConfig, error) {
+	cfg := defaultAppConfig()
+	var errs []error
+
+	cfg.Server.Port = configEnv("PORT", cfg.Server.Port)
+	cfg.Server.MetricsPort = configEnv("METRICS_PORT", cfg.Server.MetricsPort)
+	cfg.Server.PublicBaseURL = strings.TrimRight(configEnv("PUBLIC_BASE_URL", ""), "/")
+	cfg.Server.RunMode = strings.ToLower(configEnv("RUN_MODE", ""))
+	if cfg.Server.RunMode == "" {
+		var workerOnly, apiOnly bool
+		configBool(&errs, "WORKER_ONLY", &workerOnly)
+		configBool(&errs, "API_ONLY", &apiOnly)
+		switch {
+		case workerOnly:
+			cfg.Server.RunMode = runModeWorker
+		case apiOnly:
+			cfg.Server.RunMode = runModeAPI
+		default:
+			cfg.Server.RunMode = runModeAll
+		}
+	}
+	configInt(&errs, "CACHE_WARM_USERS", &cfg.Server.CacheWarmUsers)
+	configBool(&errs, "WEBHOOK_ALLOW_PRIVATE", &cfg.Server.AllowPrivateWebhooks)
+	configDuration(&errs, "REQUEST_TIMEOUT", &cfg.Server.RequestTimeout)
+	configDuration(&errs, "REQUEST_TIMEOUT_LONG", &cfg.Server.LongRequestTimeout)
+
+	cfg.Database.Driver = strings.ToLower(configEnv("DB_DRIVER", cfg.Database.Driver))
+	cfg.Database.DSN = configEnv("DATABASE_DSN", "")
+	configInt(&errs, "DB_MAX_OPEN_CONNS", &cfg.Database.MaxOpenConns)
+	configDuration(&errs, "DB_SLOW_QUERY", &cfg.Database.SlowQuery)
+	configBool(&errs, "DB_PREPARE_STMT", &cfg.Database.PrepareStmt)
+
+	// The secret is used as given; only a blank one is rejected
+	cfg.Auth.JWTSecret = os.Getenv("JWT_SECRET")
+	if raw := configEnv("JWT_EXPIRATION", ""); raw != "" {
+		expiry, err := time.ParseDuration(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("JWT_EXPIRATION: %q is not a duration such as 24h", raw))
+		} else {
+			cfg.Auth.JWTExpiry = &expiry
+		}
+	}
+	cfg.Auth.AdminUsers = configList("ADMIN_USERS")
+
+	cfg.Storage = StorageConfig{
+		Endpoint:  configEnv("CLOUDFLARE_ENDPOINT", ""),
+		AccessKey: configEnv("CLOUDFLARE_ACCESS_KEY", ""),
+		SecretKey: configEnv("CLOUDFLARE_SECRET_KEY", ""),
+	}
+	cfg.Mail = MailConfig{
+		Domain:            configEnv("MAILGUN_DOMAIN", ""),
+		APIKey:            configEnv("MAILGUN_API_KEY", ""),
+		From:              configEnv("MAILGUN_FROM", ""),
+		WebhookSigningKey: configEnv("MAILGUN_WEBHOOK_SIGNING_KEY", ""),
+		PasswordResetURL:  configEnv("PASSWORD_RESET_URL", ""),
+	}
+
+	cfg.AI.OpenAIKey = configEnv("OPENAI_KEY", "")
+	cfg.AI.OpenAIBaseURL = strings.TrimRight(configEnv("OPENAI_BASE_URL", ""), "/")
+	cfg.AI.AnthropicKey = configEnv("ANTHROPIC_API_KEY", "")
+	cfg.AI.GeminiKey = configEnv("GEMINI_API_KEY", "")
+	cfg.AI.OllamaURL = configEnv("OLLAMA_URL", "")
+	cfg.AI.StabilityKey = configEnv("STABILITY_API_KEY", "")
+	for _, name := range configList("AI_PROVIDERS") {
+		cfg.AI.Providers = append(cfg.AI.Providers, strings.ToLower(name))
+	}
+	for provider, name := range aiModelEnv {
+		if model := configEnv(name, ""); model != "" {
+			if cfg.AI.Models == nil {
+				cfg.AI.Models = map[string]string{}
+			}
+			cfg.AI.Models[provider] = model
+		}
+	}
+	cfg.AI.OpenAIImageModel = configEnv("OPENAI_IMAGE_MODEL", "")
+	cfg.AI.GeminiImageModel = configEnv("GEMINI_IMAGE_MODEL", "")
+	cfg.AI.StabilityModel = configEnv("STABILITY_MODEL", "")
+	switch name := strings.ToLower(configEnv("IMAGE_PROVIDER", "")); name {
+	case "":
+	case "none", "off", "false":
+		cfg.AI.ImageProvider = imageProviderDisabled
+	default:
+		cfg.AI.ImageProvider = name
+	}
+	cfg.AI.ImagePlaceholderURL = configEnv("IMAGE_PLACEHOLDER_URL", "")
+	configInt(&errs, "AI_MAX_TOKENS", &cfg.AI.MaxTokens)
+	cfg.AI.SchemaFile = configEnv("RECIPE_SCHEMA_FILE", "")
+	if raw := configEnv("AI_PRICES", ""); raw != "" {
+		prices, err := parseAIPrices(raw)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("AI_PRICES: %w", err))
+		}
+		cfg.AI.Prices = prices
+	}
+	configBool(&errs, "AI_DEBUG", &cfg.AI.Debug)
+	configDuration(&errs, "AI_CACHE_TTL", &cfg.AI.CacheTTL)
+	configDuration(&errs, "OLLAMA_TIMEOUT", &cfg.AI.OllamaTimeout)
+
+	cfg.Nutrition.FDCAPIKey = configEnv("USDA_FDC_API_KEY", "")
+	cfg.Nutrition.FDCURL = strings.TrimRight(configEnv("USDA_FDC_URL", cfg.Nutrition.FDCURL), "/")
+
+	errs = append(errs, loadScraperConfig(&cfg.Scraper)...)
+
+	queue, err := loadQueueSettings()
+	cfg.Queue = queue
+	errs = append(errs, err)
+
+	configDuration(&errs, "BACKUP_INTERVAL", &cfg.Jobs.BackupInterval)
+	configInt(&errs, "BACKUP_KEEP", &cfg.Jobs.BackupKeep)
+	configDuration(&errs, "RECIPE_REPROCESS_INTERVAL", &cfg.Jobs.ReprocessInterval)
+	configDuration(&errs, "RETENTION_INTERVAL", &cfg.Jobs.RetentionInterval)
+	retention := &cfg.Jobs.Retention
+	configDuration(&errs, "QUEUE_RETENTION", &retention.Queue)
+	configDuration(&errs, "RETENTION_FAILED_QUEUE", &retention.FailedQueue)
+	configBool(&errs, "RETENTION_FAILED_QUEUE_ARCHIVE", &retention.FailedQueueArchive)
+	configDuration(&errs, "RETENTION_UNUSED_ACCOUNTS", &retention.UnusedAccounts)
+	configBool(&errs, "RETENTION_UNUSED_ACCOUNTS_ARCHIVE", &retention.UnusedAccountsArchive)
+	configDuration(&errs, "RETENTION_FEED_TOKENS", &retention.FeedTokens)
+	configDuration(&errs, "RETENTION_PASSWORD_RESETS", &retention.PasswordResets)
+	inactivity := &cfg.Jobs.Inactivity
+	configInt(&errs, "INACTIVITY_WARN_MONTHS", &inactivity.WarnAfterMonths)
+	configInt(&errs, "INACTIVITY_FREEZE_MONTHS", &inactivity.FreezeAfterMonths)
+	configInt(&errs, "INACTIVITY_PURGE_MONTHS", &inactivity.PurgeAfterMonths)
+	configDuration(&errs, "INACTIVITY_FREEZE_NOTICE", &inactivity.FreezeNotice)
+	configDuration(&errs, "INACTIVITY_PURGE_NOTICE", &inactivity.PurgeNotice)
+	configBool(&errs, "INACTIVITY_EXPORT_BEFORE_PURGE", &inactivity.ExportBeforePurge)
+	configBool(&errs, "INACTIVITY_DRY_RUN", &inactivity.DryRun)
+	configDuration(&errs, "INACTIVITY_CHECK_INTERVAL", &inactivity.CheckInterval)
+
+	cfg.Reporting.SentryDSN = configEnv("SENTRY_DSN", "")
+	cfg.Reporting.SentryEnvironment = configEnv("SENTRY_ENVIRONMENT", "")
+	cfg.Reporting.SentryRelease = configEnv("SENTRY_RELEASE", "")
+	if raw := configEnv("SENTRY_SAMPLE_RATE", ""); raw != "" {
+		rate, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("SENTRY_SAMPLE_RATE: %q is not a number", raw))
+		} else {
+			cfg.Reporting.SentrySampleRate = rate
+		}
+	}
+	cfg.Reporting.Tracing = configEnv("OTEL_EXPORTER_OTLP_ENDPOINT", "") != "" || configEnv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", "") != ""
+
+	errs = append(errs, cfg.validate())
+	return cfg, errors.Join(errs...)
+}
+
+// loadScraperConfig reads the SCRAPER_* variables into cfg.
+func loadScraperConfig(cfg *ScraperConfig) []error {
+	var errs []error
+	cfg.ChromiumBin = configEnv("CHROMIUM_BIN", "")
+	cfg.PDFToTextBin = configEnv("PDFTOTEXT_BIN", "")
+	cfg.UserAgent = configEnv("SCRAPER_USER_AGENT", "")
+	configInt(&errs, "SCRAPER_BROWSER_POOL_SIZE", &cfg.BrowserPoolSize)
+	configDuration(&errs, "SCRAPER_NAV_TIMEOUT", &cfg.NavTimeout)
+	configDuration(&errs, "SCRAPER_HTTP_TIMEOUT", &cfg.HTTPTimeout)
+	cfg.FetchMode = strings.ToLower(configEnv("SCRAPER_FETCH_MODE", cfg.FetchMode))
+	cfg.DomainFetchModes = configDomainMap(&errs, "SCRAPER_DOMAIN_FETCH_MODES")
+	for domain, mode := range cfg.DomainFetchModes {
+		cfg.DomainFetchModes[domain] = strings.ToLower(mode)
+	}
+
+	var err error
+	if cfg.Proxy, err = parseScraperProxy(configEnv("SCRAPER_PROXY", "")); err != nil {
+		errs = append(errs, fmt.Errorf("SCRAPER_PROXY: %w", err))
+	}
+	if raw := configDomainMap(&errs, "SCRAPER_DOMAIN_PROXIES"); len(raw) > 0 {
+		cfg.DomainProxies = make(map[string]*url.URL, len(raw))
+		for domain, value := range raw {
+			proxy, err := parseScraperProxy(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("SCRAPER_DOMAIN_PROXIES: %s: %w", domain, err))
+				continue
+			}
+			cfg.DomainProxies[domain] = proxy
+		}
+	}
+
+	configDuration(&errs, "SCRAPER_DOMAIN_INTERVAL", &cfg.DomainInterval)
+	if raw := configDomainMap(&errs, "SCRAPER_DOMAIN_INTERVALS"); len(raw) > 0 {
+		cfg.DomainIntervals = make(map[string]time.Duration, len(raw))
+		for domain, value := range raw {
+			interval, err := time.ParseDuration(value)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("SCRAPER_DOMAIN_INTERVALS: %s: %q is not a duration such as 30s", domain, value))
+				continue
+			}
+			cfg.DomainIntervals[domain] = interval
+		}
+	}
+
+	configBool(&errs, "SCRAPER_DISMISS_CONSENT", &cfg.DismissConsent)
+	cfg.ConsentSelectors = configList("SCRAPER_CONSENT_SELECTORS")
+	configInt(&errs, "SCRAPER_MAX_RETRIES", &cfg.MaxRetries)
+	configDuration(&errs, "SCRAPER_RETRY_DELAY", &cfg.RetryDelay)
+	configDuration(&errs, "SCRAPER_RETRY_MAX_DELAY", &cfg.RetryMaxDelay)
+	configBool(&errs, "ARCHIVE_SOURCE_HTML", &cfg.ArchiveSourceHTML)
+	return errs
+}
+
+func (c AppConfig) validate() error {
+	var errs []error
+	for _, port := range []configVar{{"PORT", c.Server.Port}, {"METRICS_PORT", c.Server.MetricsPort}} {
+		if n, err := strconv.Atoi(port.value); err != nil || n < 1 || n > 65535 {
+			errs = append(errs, fmt.Errorf("%s: %q is not a port number", port.name, port.value))
+		}
+	}
+
+	switch c.Database.Driver {
+	case dbDriverSQLite:
+	case dbDriverMySQL, "mariadb":
+		if c.Database.DSN == "" {
+			errs = append(errs, fmt.Errorf("DATABASE_DSN: missing, DB_DRIVER=%s needs it", c.Database.Driver))
+		} else if _, err := mysqldriver.ParseDSN(c.Database.DSN); err != nil {
+			errs = append(errs, fmt.Errorf("DATABASE_DSN: %w", err))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("DB_DRIVER: %q is not sqlite or mysql", c.Database.Driver))
+	}
+
+	if strings.TrimSpace(c.Auth.JWTSecret) == "" {
+		errs = append(errs, errors.New("JWT_SECRET: missing"))
+	}
+	if c.Auth.JWTExpiry != nil && *c.Auth.JWTExpiry <= 0 {
+		errs = append(errs, fmt.Errorf("JWT_EXPIRATION: %s is not positive", *c.Auth.JWTExpiry))
+	}
+
+	errs = append(errs, requireAllOrNone("R2 storage", []configVar{
+		{"CLOUDFLARE_ENDPOINT", c.Storage.Endpoint},
+		{"CLOUDFLARE_ACCESS_KEY", c.Storage.AccessKey},
+		{"CLOUDFLARE_SECRET_KEY", c.Storage.SecretKey},
+	})...)
+	errs = append(errs, requireAllOrNone("mail", []configVar{
+		{"MAILGUN_DOMAIN", c.Mail.Domain},
+		{"MAILGUN_API_KEY", c.Mail.APIKey},
+		{"MAILGUN_FROM", c.Mail.From},
+	})...)
+
+	for _, v := range []configVar{
+		{"PUBLIC_BASE_URL", c.Server.PublicBaseURL},
+		{"CLOUDFLARE_ENDPOINT", c.Storage.Endpoint},
+		{"PASSWORD_RESET_URL", c.Mail.PasswordResetURL},
+		{"OPENAI_BASE_URL", c.AI.OpenAIBaseURL},
+		{"OLLAMA_URL", c.AI.OllamaURL},
+		{"IMAGE_PLACEHOLDER_URL", c.AI.ImagePlaceholderURL},
+		{"USDA_FDC_URL", c.Nutrition.FDCURL},
+	} {
+		if v.value != "" && !isHTTPURL(v.value) {
+			errs = append(errs, fmt.Errorf("%s: %q is not an http(s) URL", v.name, v.value))
+		}
+	}
+
+	switch c.Server.RunMode {
+	case runModeAll, runModeAPI, runModeWorker:
+	default:
+		errs = append(errs, fmt.Errorf("RUN_MODE: %q is not all, api or worker", c.Server.RunMode))
+	}
+
+	errs = append(errs, c.AI.validate())
+	errs = append(errs, c.Scraper.validate())
+
+	for _, v := range []struct {
+		name  string
+		value int
+	}{
+		{"CACHE_WARM_USERS", c.Server.CacheWarmUsers},
+		{"DB_MAX_OPEN_CONNS", c.Database.MaxOpenConns},
+		{"INACTIVITY_WARN_MONTHS", c.Jobs.Inactivity.WarnAfterMonths},
+		{"INACTIVITY_FREEZE_MONTHS", c.Jobs.Inactivity.FreezeAfterMonths},
+		{"INACTIVITY_PURGE_MONTHS", c.Jobs.Inactivity.PurgeAfterMonths},
+	} {
+		if v.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: %d is negative", v.name, v.value))
+		}
+	}
+	for _, v := range []configSpan{
+		{"REQUEST_TIMEOUT", c.Server.RequestTimeout},
+		{"REQUEST_TIMEOUT_LONG", c.Server.LongRequestTimeout},
+		{"DB_SLOW_QUERY", c.Database.SlowQuery},
+		{"BACKUP_INTERVAL", c.Jobs.BackupInterval},
+		{"RECIPE_REPROCESS_INTERVAL", c.Jobs.ReprocessInterval},
+		{"RETENTION_INTERVAL", c.Jobs.RetentionInterval},
+		{"QUEUE_RETENTION", c.Jobs.Retention.Queue},
+		{"RETENTION_FAILED_QUEUE", c.Jobs.Retention.FailedQueue},
+		{"RETENTION_UNUSED_ACCOUNTS", c.Jobs.Retention.UnusedAccounts},
+		{"RETENTION_FEED_TOKENS", c.Jobs.Retention.FeedTokens},
+		{"RETENTION_PASSWORD_RESETS", c.Jobs.Retention.PasswordResets},
+		{"INACTIVITY_FREEZE_NOTICE", c.Jobs.Inactivity.FreezeNotice},
+		{"INACTIVITY_PURGE_NOTICE", c.Jobs.Inactivity.PurgeNotice},
+	} {
+		if v.value < 0 {
+			errs = append(errs, fmt.Errorf("%s: %s is negative", v.name, v.value))
+		}
+	}
+	if c.Jobs.BackupKeep < 1 {
+		errs = append(errs, fmt.Errorf("BACKUP_KEEP: %d is less than 1", c.Jobs.BackupKeep))
+	}
+	if c.Jobs.Inactivity.CheckInterval <= 0 {
+		errs = append(errs, fmt.Errorf("INACTIVITY_CHECK_INTERVAL: %s is not positive", c.Jobs.Inactivity.CheckInterval))
+	}
+	if rate := c.Reporting.SentrySampleRate; rate < 0 || rate > 1 {
+		errs = append(errs, fmt.Errorf("SENTRY_SAMPLE_RATE: %g is not between 0 and 1", rate))
+	}
+	return errors.Join(errs...)
+}
+
+func (c AIConfig) validate() error {
+	var errs []error
+	for _, v := range []configVar{
+		{"OPENAI_MODEL", c.Models[aiProviderOpenAI]},
+		{"ANTHROPIC_MODEL", c.Models[aiProviderAnthropic]},
+		{"GEMINI_MODEL", c.Models[aiProviderGemini]},
+		{"OLLAMA_MODEL", c.Models[aiProviderOllama]},
+		{"OPENAI_IMAGE_MODEL", c.OpenAIImageModel},
+		{"GEMINI_IMAGE_MODEL", c.GeminiImageModel},
+		{"STABILITY_MODEL", c.StabilityModel},
+	} {
+		if strings.ContainsAny(v.value, " \t\r\n") {
+			errs = append(errs, fmt.Errorf("%s: model names can't contain spaces", v.name))
+		}
+	}
+	if c.MaxTokens <= 0 {
+		errs = append(errs, fmt.Errorf("AI_MAX_TOKENS: %d is not positive", c.MaxTokens))
+	}
+	switch c.ImageProvider {
+	case imageProviderAI, imageProviderOpenAI, imageProviderDisabled:
+	case imageProviderStability:
+		if c.StabilityKey == "" {
+			errs = append(errs, errors.New("IMAGE_PROVIDER: stability needs STABILITY_API_KEY"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("IMAGE_PROVIDER: %q is not ai, openai, stability or disabled", c.ImageProvider))
+	}
+	if c.CacheTTL < 0 {
+		errs = append(errs, fmt.Errorf("AI_CACHE_TTL: %s is negative", c.CacheTTL))
+	}
+	if c.OllamaTimeout <= 0 {
+		errs = append(errs, fmt.Errorf("OLLAMA_TIMEOUT: %s is not positive", c.OllamaTimeout))
+	}
+	return errors.Join(errs...)
+}
+
+func (c ScraperConfig) validate() error {
+	var errs []error
+	if c.BrowserPoolSize < 0 {
+		errs = append(errs, fmt.Errorf("SCRAPER_BROWSER_POOL_SIZE: %d is negative", c.BrowserPoolSize))
+	}
+	for _, v := range []configSpan{
+		{"SCRAPER_NAV_TIMEOUT", c.NavTimeout},
+		{"SCRAPER_HTTP_TIMEOUT", c.HTTPTimeout},
+	} {
+		if v.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s: %s is not positive", v.name, v.value))
+		}
+	}
+	if !validFetchMode(c.FetchMode) {
+		errs = append(errs, fmt.Errorf("SCRAPER_FETCH_MODE: %q is not browser or http", c.FetchMode))
+	}
+	for domain, mode := range c.DomainFetchModes {
+		if !validFetchMode(mode) {
+			errs = append(errs, fmt.Errorf("SCRAPER_DOMAIN_FETCH_MODES: %s: %q is not browser or http", domain, mode))
+		}
+	}
+	if c.DomainInterval < 0 {
+		errs = append(errs, fmt.Errorf("SCRAPER_DOMAIN_INTERVAL: %s is negative", c.DomainInterval))
+	}
+	for domain, interval := range c.DomainIntervals {
+		if interval < 0 {
+			errs = append(errs, fmt.Errorf("SCRAPER_DOMAIN_INTERVALS: %s: %s is negative", domain, interval))
+		}
+	}
+	if c.MaxRetries < 0 {
+		errs = append(errs, fmt.Errorf("SCRAPER_MAX_RETRIES: %d is negative", c.MaxRetries))
+	}
+	if c.RetryDelay < 0 {
+		errs = append(errs, fmt.Errorf("SCRAPER_RETRY_DELAY: %s is negative", c.RetryDelay))
+	}
+	if c.RetryMaxDelay < c.RetryDelay {
+		errs = append(errs, fmt.Errorf("SCRAPER_RETRY_MAX_DELAY: %s is shorter than SCRAPER_RETRY_DELAY %s", c.RetryMaxDelay, c.RetryDelay))
+	}
+	return errors.Join(errs...)
+}
+
+// configVar is a variable's name and value, for checks that name it.
+type configVar struct {
+	name, value string
+}
+
+// configSpan is configVar for a duration.
+type configSpan struct {
+	name  string
+	value time.Duration
+}
+
+// requireAllOrNone reports the variables missing from a group that is only
+// partly set, which would otherwise fail on first use rather than at
+// startup.
+func requireAllOrNone(feature string, vars []configVar) []error {
+	var set, missing []string
+	for _, v := range vars {
+		if v.value == "" {
+			missing = append(missing, v.name)
+		} else {
+			set = append(set, v.name)
+		}
+	}
+	if len(set) == 0 || len(missing) == 0 {
+		return nil
+	}
+	errs := make([]error, 0, len(missing))
+	for _, name := range missing {
+		errs = append(errs, fmt.Errorf("%s: missing, %s needs it alongside %s", name, feature, strings.Join(set, ", ")))
+	}
+	return errs
+}
+
+func isHTTPURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// configEnv reads a trimmed environment variable, or def when it's unset
+// or blank.
+func configEnv(name, def string) string {
+	if raw := strings.TrimSpace(os.Getenv(name)); raw != "" {
+		return raw
+	}
+	return def
+}
+
+// configList reads a comma separated variable, leaving out blank entries.
+func configList(name string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(name), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// configDomainMap reads a "domain=value,domain=value" variable. Domains are
+// lowercased without "www.", for lookupDomain.
+func configDomainMap(errs *[]error, name string) map[string]string {
+	values := map[string]string{}
+	for _, entry := range configList(name) {
+		domain, value, ok := strings.Cut(entry, "=")
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s: %q is not domain=value", name, entry))
+			continue
+		}
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "www.")
+		values[domain] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// configInt, configBool and configDuration read a variable into dst when
+// it's set, adding an error naming it when it doesn't parse.
+func configInt(errs *[]error, name string, dst *int) {
+	raw := configEnv(name, "")
+	if raw == "" {
+		return
+	}
+	val, err := strconv.Atoi(raw)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: %q is not an integer", name, raw))
+		return
+	}
+	*dst = val
+}
+
+func configBool(errs *[]error, name string, dst *bool) {
+	raw := configEnv(name, "")
+	if raw == "" {
+		return
+	}
+	val, err := strconv.ParseBool(raw)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: %q is not true or false", name, raw))
+		return
+	}
+	*dst = val
+}
+
+func configDuration(errs *[]error, name string, dst *time.Duration) {
+	raw := configEnv(name, "")
+	if raw == "" {
+		return
+	}
+	val, err := time.ParseDuration(raw)
+	if err != nil {
+		*errs = append(*errs, fmt.Errorf("%s: %q is not a duration such as 30s", name, raw))
+		return
+	}
+	*dst = val
+}
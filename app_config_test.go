@@ -0,0 +1,136 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadAppConfig(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("JWT_EXPIRATION", "24h")
+	t.Setenv("ADMIN_USERS", " chef@example.com, ,cook@example.com")
+	t.Setenv("PUBLIC_BASE_URL", "https://recipes.example.com/")
+	t.Setenv("CLOUDFLARE_ENDPOINT", "https://r2.example.com")
+	t.Setenv("CLOUDFLARE_ACCESS_KEY", "access")
+	t.Setenv("CLOUDFLARE_SECRET_KEY", "secret")
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Port != "8080" || cfg.Server.MetricsPort != "9090" || cfg.Database.Driver != dbDriverSQLite {
+		t.Errorf("defaults = %+v, %+v", cfg.Server, cfg.Database)
+	}
+	if cfg.Server.RequestTimeout != defaultRequestTimeout || cfg.Server.LongRequestTimeout != defaultLongRequestTimeout {
+		t.Errorf("request timeouts = %s, %s", cfg.Server.RequestTimeout, cfg.Server.LongRequestTimeout)
+	}
+	if cfg.Server.PublicBaseURL != "https://recipes.example.com" {
+		t.Errorf("public base URL = %q", cfg.Server.PublicBaseURL)
+	}
+	if cfg.Auth.JWTExpiry == nil || *cfg.Auth.JWTExpiry != 24*time.Hour {
+		t.Errorf("JWT expiry = %v", cfg.Auth.JWTExpiry)
+	}
+	if strings.Join(cfg.Auth.AdminUsers, ",") != "chef@example.com,cook@example.com" {
+		t.Errorf("admin users = %q", cfg.Auth.AdminUsers)
+	}
+	if !cfg.Storage.Enabled() || cfg.Mail.Enabled() {
+		t.Errorf("storage enabled %t, mail enabled %t", cfg.Storage.Enabled(), cfg.Mail.Enabled())
+	}
+}
+
+func TestLoadAppConfigTuning(t *testing.T) {
+	t.Setenv("JWT_SECRET", "secret")
+	t.Setenv("AI_PROVIDERS", "Anthropic, openai")
+	t.Setenv("ANTHROPIC_MODEL", "claude-sonnet-4-5")
+	t.Setenv("IMAGE_PROVIDER", "off")
+	t.Setenv("USDA_FDC_API_KEY", "fdc-key")
+	t.Setenv("SCRAPER_DOMAIN_PROXIES", "www.Example.com=socks5://10.0.0.2:1080,other.com=direct")
+	t.Setenv("SCRAPER_DOMAIN_INTERVALS", "example.com=30s")
+	t.Setenv("SCRAPER_MAX_RETRIES", "0")
+	t.Setenv("WORKER_ONLY", "true")
+	t.Setenv("RETENTION_FEED_TOKENS", "720h")
+
+	cfg, err := loadAppConfig()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if strings.Join(cfg.AI.Providers, ",") != "anthropic,openai" || cfg.AI.Models[aiProviderAnthropic] != "claude-sonnet-4-5" {
+		t.Errorf("AI providers = %q, models = %v", cfg.AI.Providers, cfg.AI.Models)
+	}
+	if cfg.AI.ImageProvider != imageProviderDisabled || cfg.AI.MaxTokens != defaultAIMaxTokens {
+		t.Errorf("image provider = %q, max tokens = %d", cfg.AI.ImageProvider, cfg.AI.MaxTokens)
+	}
+	if cfg.Nutrition.FDCAPIKey != "fdc-key" || cfg.Nutrition.FDCURL != defaultFDCBaseURL {
+		t.Errorf("nutrition = %+v", cfg.Nutrition)
+	}
+	if proxy, ok := cfg.Scraper.DomainProxies["example.com"]; !ok || proxy.String() != "socks5://10.0.0.2:1080" {
+		t.Errorf("example.com proxy = %v", proxy)
+	}
+	if proxy, ok := cfg.Scraper.DomainProxies["other.com"]; !ok || proxy != nil {
+		t.Errorf("other.com proxy = %v, want direct", proxy)
+	}
+	if cfg.Scraper.DomainIntervals["example.com"] != 30*time.Second || cfg.Scraper.MaxRetries != 0 {
+		t.Errorf("scraper = %+v", cfg.Scraper)
+	}
+	if cfg.Server.RunMode != runModeWorker || cfg.Jobs.Retention.FeedTokens != 720*time.Hour {
+		t.Errorf("run mode = %q, feed token retention = %s", cfg.Server.RunMode, cfg.Jobs.Retention.FeedTokens)
+	}
+}
+
+func TestLoadAppConfigListsEveryProblem(t *testing.T) {
+	t.Setenv("JWT_SECRET", " ")
+	t.Setenv("JWT_EXPIRATION", "a day")
+	t.Setenv("PORT", "http")
+	t.Setenv("DB_DRIVER", "mysql")
+	t.Setenv("CLOUDFLARE_ENDPOINT", "r2.example.com")
+	t.Setenv("MAILGUN_DOMAIN", "mg.example.com")
+	t.Setenv("MAILGUN_FROM", "recipes@example.com")
+	t.Setenv("RUN_MODE", "both")
+	t.Setenv("AI_MAX_TOKENS", "0")
+	t.Setenv("IMAGE_PROVIDER", "stability")
+	t.Setenv("USDA_FDC_URL", "fdc.example.com")
+	t.Setenv("SCRAPER_FETCH_MODE", "curl")
+	t.Setenv("SCRAPER_PROXY", "ftp://proxy.example.com")
+	t.Setenv("SCRAPER_DOMAIN_INTERVALS", "example.com")
+	t.Setenv("SCRAPER_RETRY_DELAY", "soon")
+	t.Setenv("ARCHIVE_SOURCE_HTML", "sometimes")
+	t.Setenv("BACKUP_KEEP", "0")
+	t.Setenv("RETENTION_FEED_TOKENS", "-1h")
+	t.Setenv("SENTRY_SAMPLE_RATE", "2")
+	t.Setenv("REQUEST_TIMEOUT", "-5s")
+	t.Setenv("REQUEST_TIMEOUT_LONG", "forever")
+
+	_, err := loadAppConfig()
+	if err == nil {
+		t.Fatal("loaded an invalid configuration")
+	}
+	for _, want := range []string{
+		"JWT_SECRET: missing",
+		"JWT_EXPIRATION: \"a day\" is not a duration",
+		"PORT: \"http\" is not a port number",
+		"DATABASE_DSN: missing, DB_DRIVER=mysql needs it",
+		"CLOUDFLARE_ACCESS_KEY: missing",
+		"CLOUDFLARE_SECRET_KEY: missing",
+		"CLOUDFLARE_ENDPOINT: \"r2.example.com\" is not an http(s) URL",
+		"MAILGUN_API_KEY: missing, mail needs it alongside MAILGUN_DOMAIN, MAILGUN_FROM",
+		"RUN_MODE: \"both\" is not all, api or worker",
+		"AI_MAX_TOKENS: 0 is not positive",
+		"IMAGE_PROVIDER: stability needs STABILITY_API_KEY",
+		"USDA_FDC_URL: \"fdc.example.com\" is not an http(s) URL",
+		"SCRAPER_FETCH_MODE: \"curl\" is not browser or http",
+		"SCRAPER_PROXY: unsupported proxy scheme \"ftp\"",
+		"SCRAPER_DOMAIN_INTERVALS: \"example.com\" is not domain=value",
+		"SCRAPER_RETRY_DELAY: \"soon\" is not a duration",
+		"ARCHIVE_SOURCE_HTML: \"sometimes\" is not true or false",
+		"BACKUP_KEEP: 0 is less than 1",
+		"RETENTION_FEED_TOKENS: -1h0m0s is negative",
+		"SENTRY_SAMPLE_RATE: 2 is not between 0 and 1",
+		"REQUEST_TIMEOUT: -5s is negative",
+		"REQUEST_TIMEOUT_LONG: \"forever\" is not a duration",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error doesn't mention %q:\n%v", want, err)
+		}
+	}
+}
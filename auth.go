@@ -3,7 +3,6 @@ package main
 import (
 	"errors"
 	"fmt"
-	"os"
 	"strings"
 	"time"
 
@@ -13,26 +12,13 @@ import (
 var jwtSecret string
 var jwtExpiry *time.Duration
 
-func initJWTSecret() error {
-	secret := os.Getenv("JWT_SECRET")
-	if strings.TrimSpace(secret) == "" {
-		return errors.New("JWT_SECRET environment variable is not set")
-	}
-	jwtSecret = secret
-
-	expRaw := strings.TrimSpace(os.Getenv("JWT_EXPIRATION"))
-	if expRaw == "" {
-		jwtExpiry = nil
-		return nil
-	}
+// tokenScopeQueueEvents limits a token to opening GET /queue/events.
+const tokenScopeQueueEvents = "queue-events"
 
-	duration, err := time.ParseDuration(expRaw)
-	if err != nil {
-		return fmt.Errorf("invalid JWT_EXPIRATION: %w", err)
-	}
-
-	jwtExpiry = &duration
-	return nil
+// initJWTSecret signs and checks tokens with the validated auth settings.
+func initJWTSecret(auth AuthConfig) {
+	jwtSecret = auth.JWTSecret
+	jwtExpiry = auth.JWTExpiry
 }
 
 func generateToken(username string, ttl time.Duration) (string, error) {
@@ -65,10 +51,56 @@ func generateToken(username string, ttl time.Duration) (string, error) {
 }
 
 func parseToken(tokenString string) (string, error) {
+	claims, err := parseTokenClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
+	// Scoped tokens are only good for the one thing they were issued for
+	if _, scoped := claims["scope"]; scoped {
+		return "", errors.New("invalid token scope")
+	}
+	return tokenSubject(claims)
+}
+
+// generateScopedToken signs a token that only parseScopedToken accepts, for
+// scope, expiring after ttl whatever JWT_EXPIRATION says. Clients that must
+// put a token in a URL use one of these instead of their login token.
+func generateScopedToken(username, scope string, ttl time.Duration) (string, error) {
 	if jwtSecret == "" {
 		return "", errors.New("jwt secret not initialized")
 	}
 
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   username,
+		"scope": scope,
+		"iat":   now.Unix(),
+		"exp":   now.Add(ttl).Unix(),
+	})
+	signed, err := token.SignedString([]byte(jwtSecret))
+	if err != nil {
+		return "", fmt.Errorf("sign token: %w", err)
+	}
+	return signed, nil
+}
+
+// parseScopedToken returns the user of a token issued for scope.
+func parseScopedToken(tokenString, scope string) (string, error) {
+	claims, err := parseTokenClaims(tokenString)
+	if err != nil {
+		return "", err
+	}
+	if claims["scope"] != scope {
+		return "", errors.New("invalid token scope")
+	}
+	return tokenSubject(claims)
+}
+
+func parseTokenClaims(tokenString string) (jwt.MapClaims, error) {
+	if jwtSecret == "" {
+		return nil, errors.New("jwt secret not initialized")
+	}
+
 	parsed, err := jwt.Parse(tokenString, func(token *jwt.Token) (any, error) {
 		if token.Method != jwt.SigningMethodHS256 {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -76,18 +108,21 @@ func parseToken(tokenString string) (string, error) {
 		return []byte(jwtSecret), nil
 	})
 	if err != nil {
-		return "", fmt.Errorf("parse token: %w", err)
+		return nil, fmt.Errorf("parse token: %w", err)
 	}
 
 	if !parsed.Valid {
-		return "", errors.New("invalid token")
+		return nil, errors.New("invalid token")
 	}
 
 	claims, ok := parsed.Claims.(jwt.MapClaims)
 	if !ok {
-		return "", errors.New("invalid token claims")
+		return nil, errors.New("invalid token claims")
 	}
+	return claims, nil
+}
 
+func tokenSubject(claims jwt.MapClaims) (string, error) {
 	username, ok := claims["sub"].(string)
 	if !ok || username == "" {
 		return "", errors.New("invalid token subject")
@@ -108,3 +143,16 @@ func extractUsernameFromBearer(header string) (string, error) {
 
 	return parseToken(parts[1])
 }
+
+// isAdminUser reports whether username is listed in ADMIN_USERS.
+func isAdminUser(username string) bool {
+	if username == "" {
+		return false
+	}
+	for _, admin := range appConfig.Auth.AdminUsers {
+		if strings.EqualFold(admin, username) {
+			return true
+		}
+	}
+	return false
+}
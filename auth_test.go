@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScopedTokensAreNotLoginTokens(t *testing.T) {
+	jwtSecret = "test-secret"
+
+	scoped, err := generateScopedToken("cook@example.com", tokenScopeQueueEvents, time.Minute)
+	if err != nil {
+		t.Fatalf("scoped token: %v", err)
+	}
+	if username, err := parseScopedToken(scoped, tokenScopeQueueEvents); err != nil || username != "cook@example.com" {
+		t.Fatalf("parseScopedToken = %q, %v", username, err)
+	}
+	if _, err := parseToken(scoped); err == nil {
+		t.Fatal("parseToken accepted a scoped token")
+	}
+
+	login, err := generateToken("cook@example.com", tokenTTL)
+	if err != nil {
+		t.Fatalf("login token: %v", err)
+	}
+	if _, err := parseScopedToken(login, tokenScopeQueueEvents); err == nil {
+		t.Fatal("parseScopedToken accepted a login token")
+	}
+
+	expired, err := generateScopedToken("cook@example.com", tokenScopeQueueEvents, -time.Minute)
+	if err != nil {
+		t.Fatalf("expired token: %v", err)
+	}
+	if _, err := parseScopedToken(expired, tokenScopeQueueEvents); err == nil {
+		t.Fatal("parseScopedToken accepted an expired token")
+	}
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The SQLite database is snapshotted, gzipped and uploaded to R2 under
+// backups/db/ every BACKUP_INTERVAL (default daily, 0 turns it off), and
+// all but the newest BACKUP_KEEP (default 14) are deleted. Admins can take
+// one at once with POST /admin/backup. To restore, stop the app, gunzip a
+// backup over data/recipes.db and remove recipes.db-wal and recipes.db-shm.
+
+var errBackupRunning = errors.New("a backup is already running")
+
+var backupLastSuccess = promauto.NewGauge(prometheus.GaugeOpts{
+	Name: "recipes_backup_last_success_timestamp_seconds",
+	Help: "When the last database backup was uploaded.",
+})
+
+// backupMu keeps the scheduled job and the admin endpoint from snapshotting
+// at the same time.
+var backupMu sync.Mutex
+
+// backupBucket is the part of CloudflareS3 backups use.
+type backupBucket interface {
+	UploadReader(key, contentType string, body io.ReadSeeker) error
+	ListObjectKeys(prefix string) ([]string, error)
+	DeleteObject(key string) error
+}
+
+// DatabaseBackup describes an uploaded backup and the old ones rotated out.
+type DatabaseBackup struct {
+	Key     string   `json:"key"`
+	Bytes   int64    `json:"bytes"`
+	Deleted []string `json:"deleted"`
+}
+
+func runDatabaseBackups(ctx context.Context, repo *RecipeRepository) {
+	interval := appConfig.Jobs.BackupInterval
+	switch {
+	case interval <= 0:
+		log.Println("Backup: disabled (BACKUP_INTERVAL=0)")
+		return
+	case isMySQL(repo.db):
+		log.Println("Backup: disabled, the database is MySQL")
+		return
+	case !appConfig.Storage.Enabled():
+		log.Println("Backup: disabled (CLOUDFLARE_ENDPOINT not set)")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if backup, err := backupToR2(repo); err != nil {
+				log.Printf("Backup: %v", err)
+			} else {
+				log.Printf("Backup: uploaded %s (%d bytes), deleted %d old backups", backup.Key, backup.Bytes, len(backup.Deleted))
+			}
+		}
+	}
+}
+
+// backupToR2 takes a backup into the R2 bucket, unless one is under way.
+func backupToR2(repo *RecipeRepository) (DatabaseBackup, error) {
+	if !backupMu.TryLock() {
+		return DatabaseBackup{}, errBackupRunning
+	}
+	defer backupMu.Unlock()
+
+	bucket, err := NewCloudflareS3()
+	if err != nil {
+		return DatabaseBackup{}, fmt.Errorf("initialize S3 client: %w", err)
+	}
+	return backupDatabase(repo, bucket, time.Now(), appConfig.Jobs.BackupKeep)
+}
+
+// backupDatabase uploads a gzipped snapshot of the database and then deletes
+// all but the newest keep backups. A failed rotation is only logged: the
+// backup itself is safe.
+func backupDatabase(repo *RecipeRepository, bucket backupBucket, now time.Time, keep int) (DatabaseBackup, error) {
+	dir, err := os.MkdirTemp("", "recipes-backup-")
+	if err != nil {
+		return DatabaseBackup{}, fmt.Errorf("create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	snapshot := filepath.Join(dir, "recipes.db")
+	if err := repo.SnapshotDatabase(snapshot); err != nil {
+		return DatabaseBackup{}, err
+	}
+	compressed, size, err := gzipFile(snapshot)
+	if err != nil {
+		return DatabaseBackup{}, err
+	}
+	defer compressed.Close()
+
+	backup := DatabaseBackup{Key: backupKey(now), Bytes: size, Deleted: []string{}}
+	if err := bucket.UploadReader(backup.Key, "application/gzip", compressed); err != nil {
+		return DatabaseBackup{}, err
+	}
+	backupLastSuccess.Set(float64(now.Unix()))
+
+	keys, err := bucket.ListObjectKeys(backupKeyPrefix)
+	if err != nil {
+		log.Printf("Backup: rotation skipped: %v", err)
+		return backup, nil
+	}
+	for _, key := range staleBackups(keys, keep) {
+		if err := bucket.DeleteObject(key); err != nil {
+			log.Printf("Backup: %v", err)
+			continue
+		}
+		backup.Deleted = append(backup.Deleted, key)
+	}
+	return backup, nil
+}
+
+// gzipFile compresses path into path.gz and returns it open at the start,
+// with its size.
+func gzipFile(path string) (*os.File, int64, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("open snapshot: %w", err)
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return nil, 0, fmt.Errorf("create backup: %w", err)
+	}
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	var size int64
+	if err == nil {
+		size, err = out.Seek(0, io.SeekCurrent)
+	}
+	if err == nil {
+		_, err = out.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		out.Close()
+		return nil, 0, fmt.Errorf("compress snapshot: %w", err)
+	}
+	return out, size, nil
+}
+
+// backupKey names a backup by its UTC time, so keys sort oldest first.
+func backupKey(now time.Time) string {
+	return backupKeyPrefix + "recipes-" + now.UTC().Format("20060102T150405Z") + ".db.gz"
+}
+
+// staleBackups picks the backups to delete so that the newest keep remain.
+// Other objects under the prefix are left alone.
+func staleBackups(keys []string, keep int) []string {
+	var backups []string
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, backupKeyPrefix)
+		if strings.HasPrefix(name, "recipes-") && strings.HasSuffix(name, ".db.gz") && !strings.Contains(name, "/") {
+			backups = append(backups, key)
+		}
+	}
+	if len(backups) <= keep {
+		return nil
+	}
+	sort.Strings(backups)
+	return backups[:len(backups)-keep]
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// memoryBucket is a backupBucket in a map.
+type memoryBucket map[string][]byte
+
+func (b memoryBucket) UploadReader(key, contentType string, body io.ReadSeeker) error {
+	data, err := io.ReadAll(body)
+	b[key] = data
+	return err
+}
+
+func (b memoryBucket) UploadObject(key, contentType string, content []byte) error {
+	b[key] = content
+	return nil
+}
+
+func (b memoryBucket) ListObjectKeys(prefix string) ([]string, error) {
+	var keys []string
+	for key := range b {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys, nil
+}
+
+func (b memoryBucket) DeleteObject(key string) error {
+	delete(b, key)
+	return nil
+}
+
+func TestBackupDatabaseUploadsSnapshot(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.SaveRecipeForUser("cook@example.com", "toast", Recipe{Title: "Toast", Category: "breakfast"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	bucket := memoryBucket{}
+	now := time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC)
+	backup, err := backupDatabase(repo, bucket, now, 2)
+	if err != nil {
+		t.Fatalf("backup: %v", err)
+	}
+	if backup.Key != "backups/db/recipes-20261017T030000Z.db.gz" || backup.Bytes != int64(len(bucket[backup.Key])) {
+		t.Fatalf("backup = %+v, uploaded %d bytes", backup, len(bucket[backup.Key]))
+	}
+
+	// The upload is a gzipped database holding the recipe
+	zr, err := gzip.NewReader(bytes.NewReader(bucket[backup.Key]))
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	restored := filepath.Join(t.TempDir(), "restored.db")
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("gunzip: %v", err)
+	}
+	if err := os.WriteFile(restored, data, 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	db, err := gorm.Open(sqlite.Open(restored), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open restored: %v", err)
+	}
+	if sqlDB, err := db.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+	var title string
+	if err := db.Raw("SELECT title FROM recipes WHERE slug = ?", "toast").Scan(&title).Error; err != nil || title != "Toast" {
+		t.Fatalf("restored recipe = %q, %v", title, err)
+	}
+}
+
+func TestBackupDatabaseRotates(t *testing.T) {
+	repo := newTestRepo(t)
+	bucket := memoryBucket{"backups/db/notes.txt": nil}
+	start := time.Date(2026, 10, 1, 3, 0, 0, 0, time.UTC)
+	var backup DatabaseBackup
+	for day := range 4 {
+		var err error
+		if backup, err = backupDatabase(repo, bucket, start.AddDate(0, 0, day), 2); err != nil {
+			t.Fatalf("backup %d: %v", day, err)
+		}
+	}
+	if want := []string{"backups/db/recipes-20261002T030000Z.db.gz"}; !slices.Equal(backup.Deleted, want) {
+		t.Fatalf("last backup deleted %v, want %v", backup.Deleted, want)
+	}
+	keys, _ := bucket.ListObjectKeys(backupKeyPrefix)
+	want := []string{
+		"backups/db/notes.txt",
+		"backups/db/recipes-20261003T030000Z.db.gz",
+		"backups/db/recipes-20261004T030000Z.db.gz",
+	}
+	if !slices.Equal(keys, want) {
+		t.Fatalf("bucket = %v, want %v", keys, want)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/go-rod/rod"
+	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
+)
+
+var errNoChromium = errors.New("no Chromium/Chrome binary found; set CHROMIUM_BIN or install chromium")
+
+// browserPool keeps one Chromium process running and hands out up to size
+// tabs at a time, reusing idle ones. The browser is health-checked before
+// each checkout and relaunched if it has crashed or stopped answering.
+type browserPool struct {
+	mu      sync.Mutex
+	launch  *launcher.Launcher
+	browser *rod.Browser
+	idle    []pooledPage
+	slots   chan struct{}
+	// contexts maps tabs opened with their own proxy to the browser context
+	// to dispose when they are released
+	contexts map[proto.TargetTargetID]proto.BrowserBrowserContextID
+	// generation changes on every relaunch so pages of a dead browser are
+	// never handed out again
+	generation int
+}
+
+type pooledPage struct {
+	page       *rod.Page
+	generation int
+}
+
+func newBrowserPool(size int) *browserPool {
+	if size < 1 {
+		size = 1
+	}
+	return &browserPool{
+		slots:    make(chan struct{}, size),
+		contexts: map[proto.TargetTargetID]proto.BrowserBrowserContextID{},
+	}
+}
+
+// Acquire returns a tab, waiting while all of them are in use. Callers must
+// Release it. A non-empty proxyServer opens the tab in a browser context of
+// its own using that proxy instead of the one the browser was launched with;
+// those tabs are not reused.
+func (p *browserPool) Acquire(proxyServer string) (*rod.Page, error) {
+	p.slots <- struct{}{}
+	page, err := p.checkout(proxyServer)
+	if err != nil {
+		<-p.slots
+		return nil, err
+	}
+	return page, nil
+}
+
+func (p *browserPool) checkout(proxyServer string) (*rod.Page, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ensureBrowser(); err != nil {
+		return nil, err
+	}
+
+	if proxyServer != "" {
+		created, err := proto.TargetCreateBrowserContext{ProxyServer: proxyServer}.Call(p.browser)
+		if err != nil {
+			return nil, fmt.Errorf("create proxied browser context: %w", err)
+		}
+		page, err := p.browser.Page(proto.TargetCreateTarget{BrowserContextID: created.BrowserContextID})
+		if err != nil {
+			_ = proto.TargetDisposeBrowserContext{BrowserContextID: created.BrowserContextID}.Call(p.browser)
+			return nil, fmt.Errorf("open browser tab: %w", err)
+		}
+		p.contexts[page.TargetID] = created.BrowserContextID
+		return page, nil
+	}
+
+	for len(p.idle) > 0 {
+		pooled := p.idle[len(p.idle)-1]
+		p.idle = p.idle[:len(p.idle)-1]
+		if pooled.generation == p.generation {
+			return pooled.page, nil
+		}
+	}
+
+	page, err := p.browser.Page(proto.TargetCreateTarget{})
+	if err != nil {
+		return nil, fmt.Errorf("open browser tab: %w", err)
+	}
+	return page, nil
+}
+
+// Release returns a tab to the pool. Tabs that failed are closed rather than
+// reused, since a wedged renderer tends to stay wedged.
+func (p *browserPool) Release(page *rod.Page, healthy bool) {
+	defer func() { <-p.slots }()
+
+	p.mu.Lock()
+	contextID, proxied := p.contexts[page.TargetID]
+	delete(p.contexts, page.TargetID)
+	p.mu.Unlock()
+	if proxied {
+		_ = page.Close()
+		if p.browser != nil {
+			_ = proto.TargetDisposeBrowserContext{BrowserContextID: contextID}.Call(p.browser)
+		}
+		return
+	}
+
+	if healthy {
+		healthy = rod.Try(func() {
+			page.Timeout(5 * time.Second).MustNavigate("about:blank")
+		}) == nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if healthy && p.browser != nil {
+		p.idle = append(p.idle, pooledPage{page: page, generation: p.generation})
+		return
+	}
+	_ = page.Close()
+}
+
+// ensureBrowser launches Chromium, or relaunches it when the health check
+// fails. Callers hold p.mu.
+func (p *browserPool) ensureBrowser() error {
+	if p.browser != nil {
+		_, err := p.browser.Timeout(5 * time.Second).Version()
+		if err == nil {
+			return nil
+		}
+		log.Printf("Scraper: browser health check failed, relaunching: %v", err)
+		p.shutdown()
+	}
+
+	bin := findChromiumBinary()
+	if bin == "" {
+		log.Println("No Chromium/Chrome binary found; set CHROMIUM_BIN or install chromium")
+		return errNoChromium
+	}
+
+	launch := launcher.New().Bin(bin)
+	if proxy := appConfig.Scraper.Proxy; proxy != nil {
+		launch = launch.Proxy(chromeProxyServer(proxy))
+	}
+	controlURL, err := launch.Launch()
+	if err != nil {
+		launch.Cleanup()
+		return fmt.Errorf("launch browser: %w", err)
+	}
+	browser := rod.New().ControlURL(controlURL)
+	if err := browser.Connect(); err != nil {
+		launch.Kill()
+		launch.Cleanup()
+		return fmt.Errorf("connect browser: %w", err)
+	}
+
+	p.launch = launch
+	p.browser = browser
+	p.generation++
+	log.Printf("Scraper: launched browser (pool size %d)", cap(p.slots))
+	return nil
+}
+
+// shutdown stops the browser process; callers hold p.mu.
+func (p *browserPool) shutdown() {
+	if p.browser != nil {
+		_ = p.browser.Close()
+	}
+	if p.launch != nil {
+		p.launch.Kill()
+		p.launch.Cleanup()
+	}
+	p.browser = nil
+	p.launch = nil
+	p.idle = nil
+	clear(p.contexts)
+}
+
+// Close stops the browser at shutdown.
+func (p *browserPool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.shutdown()
+}
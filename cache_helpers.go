@@ -1,12 +1,21 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strings"
 	"time"
+
+	"golang.org/x/sync/singleflight"
 )
 
+// recipeListFills runs one query per list cache key at a time, so when a
+// popular list expires the requests that miss wait for one fill instead of
+// each querying the database.
+var recipeListFills singleflight.Group
+
 func singleRecipeCacheKey(username, slug string) string {
 	return fmt.Sprintf("recipe:%s:%s", username, slug)
 }
@@ -15,13 +24,13 @@ func singleRecipeIDCacheKey(username string, id uint) string {
 	return fmt.Sprintf("recipe:%s:id:%d", username, id)
 }
 
-func recipeListCacheKey(username, category string) string {
-	if category == "" {
-		return fmt.Sprintf("recipes:%s:all", username)
-	}
-	return fmt.Sprintf("recipes:%s:%s", username, category)
+func recipeListCacheKey(username string, filters RecipeFilters) string {
+	return fmt.Sprintf("recipes:%s:%s", username, filters.CacheKey())
 }
 
+// invalidateUserRecipeCaches drops the user's cached lists and single
+// recipes. A recipe is cached under its slug and its id, and a handler
+// knows only one of them, so every single recipe of the user goes.
 func invalidateUserRecipeCaches(username string) {
 	prefix := fmt.Sprintf("recipes:%s:", username)
 	for key := range recipesCache.Items() {
@@ -29,31 +38,52 @@ func invalidateUserRecipeCaches(username string) {
 			recipesCache.Delete(key)
 		}
 	}
+	prefix = fmt.Sprintf("recipe:%s:", username)
+	for key := range recipeCache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			recipeCache.Delete(key)
+		}
+	}
 }
 
-func listRecipes(username, category string, refresh bool) ([]Recipe, error) {
+func listRecipes(repo RecipeStore, username string, filters RecipeFilters, refresh bool) ([]Recipe, error) {
 	if username == "" {
 		return nil, fmt.Errorf("username is required")
 	}
 
-	cacheKey := recipeListCacheKey(username, category)
+	cacheKey := recipeListCacheKey(username, filters)
 	if !refresh {
 		if cachedRecipes, found := recipesCache.Get(cacheKey); found {
 			if recipes, ok := cachedRecipes.([]Recipe); ok {
 				log.Printf("Cache hit for %s", cacheKey)
+				observeCacheLookup(cacheRecipeList, true)
 				return recipes, nil
 			}
 			log.Printf("Invalid cache entry for %s, evicting", cacheKey)
 			recipesCache.Delete(cacheKey)
 		}
+		observeCacheLookup(cacheRecipeList, false)
 	}
 
-	recipes, err := recipeRepo.ListRecipes(username, category)
+	fill := func() ([]Recipe, error) {
+		recipes, err := repo.ListRecipes(username, filters)
+		if err != nil {
+			return nil, err
+		}
+		recipesCache.Set(cacheKey, recipes, 1*time.Hour)
+		return recipes, nil
+	}
+	// A refresh asks for the list as it is now, not one already being read
+	if refresh {
+		return fill()
+	}
+	value, err, shared := recipeListFills.Do(cacheKey, func() (any, error) { return fill() })
 	if err != nil {
+		// The request that ran the query went away; run this one's own
+		if shared && errors.Is(err, context.Canceled) {
+			return fill()
+		}
 		return nil, err
 	}
-
-	recipesCache.Set(cacheKey, recipes, 1*time.Hour)
-
-	return recipes, nil
+	return value.([]Recipe), nil
 }
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// slowListStore counts ListRecipes calls and holds each until release closes.
+type slowListStore struct {
+	RecipeStore
+	calls   atomic.Int32
+	release chan struct{}
+}
+
+func (s *slowListStore) ListRecipes(username string, filters RecipeFilters) ([]Recipe, error) {
+	s.calls.Add(1)
+	<-s.release
+	return s.RecipeStore.ListRecipes(username, filters)
+}
+
+func TestListRecipesFillsTheCacheOnce(t *testing.T) {
+	previous := recipesCache
+	recipesCache = cache.New(time.Hour, time.Hour)
+	t.Cleanup(func() { recipesCache = previous })
+
+	memory := newMemoryRecipeStore()
+	if err := memory.SaveRecipeForUser("cook@example.com", "toast", Recipe{Title: "Toast", Category: "breakfast"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	store := &slowListStore{RecipeStore: memory, release: make(chan struct{})}
+
+	const requests = 8
+	var wg sync.WaitGroup
+	results := make([][]Recipe, requests)
+	for i := range requests {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			recipes, err := listRecipes(store, "cook@example.com", RecipeFilters{}, false)
+			if err != nil {
+				t.Errorf("list: %v", err)
+			}
+			results[i] = recipes
+		}()
+	}
+	// Let the requests pile up behind the first query
+	deadline := time.Now().Add(time.Second)
+	for store.calls.Load() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(store.release)
+	wg.Wait()
+
+	if calls := store.calls.Load(); calls != 1 {
+		t.Fatalf("ListRecipes ran %d times, want 1", calls)
+	}
+	for i, recipes := range results {
+		if len(recipes) != 1 || recipes[0].Title != "Toast" {
+			t.Fatalf("request %d got %v", i, recipeTitles(recipes))
+		}
+	}
+
+	// A refresh reads the database again
+	if _, err := listRecipes(store, "cook@example.com", RecipeFilters{}, true); err != nil || store.calls.Load() != 2 {
+		t.Fatalf("refresh: %d calls, %v", store.calls.Load(), err)
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// calendarEvent is an all-day iCalendar event.
+type calendarEvent struct {
+	UID         string
+	Date        time.Time
+	Summary     string
+	Description string
+	URL         string
+}
+
+// renderICalendar writes an RFC 5545 calendar of all-day events.
+func renderICalendar(name string, events []calendarEvent, now time.Time) string {
+	var b strings.Builder
+	line := func(text string) {
+		b.WriteString(foldICalLine(text))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//cooking.bronson.dev//Meal plan//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escapeICalText(name))
+	// Hint for clients that honour it; Google ignores it and polls on its own
+	line("REFRESH-INTERVAL;VALUE=DURATION:PT6H")
+	line("X-PUBLISHED-TTL:PT6H")
+
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, event := range events {
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + stamp)
+		line("DTSTART;VALUE=DATE:" + event.Date.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + event.Date.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + escapeICalText(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escapeICalText(event.Description))
+		}
+		if event.URL != "" {
+			line("URL:" + event.URL)
+		}
+		line("TRANSP:TRANSPARENT")
+		line("END:VEVENT")
+	}
+
+	line("END:VCALENDAR")
+	return b.String()
+}
+
+func escapeICalText(text string) string {
+	text = strings.ReplaceAll(text, "\\", "\\\\")
+	text = strings.ReplaceAll(text, ";", "\\;")
+	text = strings.ReplaceAll(text, ",", "\\,")
+	text = strings.ReplaceAll(text, "\r\n", "\\n")
+	text = strings.ReplaceAll(text, "\n", "\\n")
+	return text
+}
+
+// foldICalLine splits content lines longer than 75 octets, without breaking
+// UTF-8 sequences, as RFC 5545 section 3.1 requires.
+func foldICalLine(text string) string {
+	const limit = 75
+	if len(text) <= limit {
+		return text
+	}
+
+	var b strings.Builder
+	width := 0
+	for _, r := range text {
+		size := len(string(r))
+		if width+size > limit {
+			b.WriteString("\r\n ")
+			// The leading space counts towards the continuation line
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
+
+func mealEventSummary(meal PlannedMeal) string {
+	if meal.Meal == "" {
+		return meal.Title
+	}
+	return fmt.Sprintf("%s: %s", strings.ToUpper(meal.Meal[:1])+meal.Meal[1:], meal.Title)
+}
@@ -4,7 +4,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
-	"os"
+	"io"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -20,15 +20,15 @@ type CloudflareS3 struct {
 func NewCloudflareS3() (*CloudflareS3, error) {
 	r2Resolver := aws.EndpointResolverWithOptionsFunc(func(service, region string, options ...interface{}) (aws.Endpoint, error) {
 		return aws.Endpoint{
-			URL: os.Getenv("CLOUDFLARE_ENDPOINT"),
+			URL: appConfig.Storage.Endpoint,
 		}, nil
 	})
 
 	cfg, err := config.LoadDefaultConfig(context.TODO(),
 		config.WithEndpointResolverWithOptions(r2Resolver),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
-			os.Getenv("CLOUDFLARE_ACCESS_KEY"),
-			os.Getenv("CLOUDFLARE_SECRET_KEY"),
+			appConfig.Storage.AccessKey,
+			appConfig.Storage.SecretKey,
 			"",
 		)),
 		config.WithRegion("auto"),
@@ -45,14 +45,82 @@ func NewCloudflareS3() (*CloudflareS3, error) {
 }
 
 func (c *CloudflareS3) UploadImage(filename, contentType string, content []byte) error {
+	if err := c.UploadObject(filename, contentType, content); err != nil {
+		return fmt.Errorf("failed to upload image: %w", err)
+	}
+	return nil
+}
+
+func (c *CloudflareS3) UploadObject(key, contentType string, content []byte) error {
+	return c.UploadReader(key, contentType, bytes.NewReader(content))
+}
+
+// UploadReader uploads from body, such as an open file, without reading it
+// all into memory first. It must seek so the SDK can sign and retry.
+func (c *CloudflareS3) UploadReader(key, contentType string, body io.ReadSeeker) error {
 	_, err := c.client.PutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket:      aws.String(c.bucket),
-		Key:         aws.String(filename),
-		Body:        bytes.NewReader(content),
+		Key:         aws.String(key),
+		Body:        body,
 		ContentType: aws.String(contentType),
 	})
 	if err != nil {
-		return fmt.Errorf("failed to upload image: %w", err)
+		return fmt.Errorf("put object %s: %w", key, err)
+	}
+	return nil
+}
+
+// ListObjectKeys lists the keys under prefix, in key order.
+func (c *CloudflareS3) ListObjectKeys(prefix string) ([]string, error) {
+	var keys []string
+	pages := s3.NewListObjectsV2Paginator(c.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(c.bucket),
+		Prefix: aws.String(prefix),
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(context.TODO())
+		if err != nil {
+			return nil, fmt.Errorf("list objects %s: %w", prefix, err)
+		}
+		for _, object := range page.Contents {
+			keys = append(keys, aws.ToString(object.Key))
+		}
+	}
+	return keys, nil
+}
+
+func (c *CloudflareS3) DeleteObject(key string) error {
+	_, err := c.client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("delete object %s: %w", key, err)
+	}
+	return nil
+}
+
+// GetObject reads an object and its content type.
+func (c *CloudflareS3) GetObject(key string) ([]byte, string, error) {
+	out, err := c.client.GetObject(context.TODO(), &s3.GetObjectInput{
+		Bucket: aws.String(c.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("get object %s: %w", key, err)
+	}
+	defer out.Body.Close()
+	data, err := io.ReadAll(out.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("read object %s: %w", key, err)
+	}
+	return data, aws.ToString(out.ContentType), nil
+}
+
+// Ping checks that the bucket is reachable with these credentials.
+func (c *CloudflareS3) Ping(ctx context.Context) error {
+	if _, err := c.client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String(c.bucket)}); err != nil {
+		return fmt.Errorf("head bucket %s: %w", c.bucket, err)
 	}
 	return nil
 }
@@ -1,22 +1,45 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-func InitDatabase() (*gorm.DB, error) {
+// Databases DB_DRIVER can name.
+const (
+	dbDriverSQLite = "sqlite"
+	dbDriverMySQL  = "mysql"
+)
+
+// InitDatabase opens the database DB_DRIVER names: SQLite at
+// data/recipes.db (the default), or MySQL/MariaDB at DATABASE_DSN. The
+// schema comes from SQL/, or SQL/mysql/ for MySQL.
+func InitDatabase(cfg DatabaseConfig) (*gorm.DB, error) {
+	switch driver := cfg.Driver; driver {
+	case "", dbDriverSQLite:
+		return openSQLite(cfg)
+	case dbDriverMySQL, "mariadb":
+		return openMySQL(cfg)
+	default:
+		return nil, fmt.Errorf("DB_DRIVER: %q is not sqlite or mysql", driver)
+	}
+}
+
+func openSQLite(cfg DatabaseConfig) (*gorm.DB, error) {
 	dataDir := filepath.Join(".", "data")
 	if err := os.MkdirAll(dataDir, 0o755); err != nil {
 		return nil, fmt.Errorf("create data dir: %w", err)
 	}
 
-	dbPath := filepath.Join(dataDir, "recipes.db")
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(filepath.Join(dataDir, "recipes.db"))), gormConfig(cfg))
 	if err != nil {
 		return nil, fmt.Errorf("open database: %w", err)
 	}
@@ -25,8 +48,62 @@ func InitDatabase() (*gorm.DB, error) {
 	if err != nil {
 		return nil, fmt.Errorf("db instance: %w", err)
 	}
-	sqlDB.SetMaxOpenConns(1)
-	sqlDB.SetMaxIdleConns(1)
+	// In WAL mode readers don't block the writer or each other, so
+	// requests share a pool; writes still go one at a time
+	conns := cfg.MaxOpenConns
+	if conns < 1 {
+		conns = dbMaxOpenConns
+	}
+	sqlDB.SetMaxOpenConns(conns)
+	sqlDB.SetMaxIdleConns(conns)
+
+	return db, nil
+}
+
+// sqliteDSN opens the database file at path with the pragmas every
+// connection needs. WAL lets reads run alongside a write. A writer waits up
+// to busy_timeout for the lock, held by another connection or by the other
+// process in a split deployment, instead of failing with "database is
+// locked"; transactions take it at BEGIN (_txlock=immediate), as a read
+// lock can't wait to be upgraded. foreign_keys makes SQLite enforce the
+// schema's ON DELETE clauses.
+func sqliteDSN(path string) string {
+	return path + "?_journal_mode=WAL&_synchronous=NORMAL&_busy_timeout=5000&_foreign_keys=on&_txlock=immediate"
+}
+
+// openMySQL connects to MySQL 8 or MariaDB 10.2+ (window functions are
+// needed) at DATABASE_DSN, e.g. recipes:secret@tcp(db:3306)/recipes.
+func openMySQL(dbConfig DatabaseConfig) (*gorm.DB, error) {
+	if dbConfig.DSN == "" {
+		return nil, errors.New("DB_DRIVER=mysql needs DATABASE_DSN")
+	}
+	cfg, err := mysqldriver.ParseDSN(dbConfig.DSN)
+	if err != nil {
+		return nil, fmt.Errorf("DATABASE_DSN: %w", err)
+	}
+	// Times are stored in UTC as on SQLite: the driver sends and reads
+	// time.Time in UTC, and CURRENT_TIMESTAMP is taken in UTC too
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	if cfg.Params == nil {
+		cfg.Params = map[string]string{}
+	}
+	cfg.Params["time_zone"] = "'+00:00'"
 
+	config := gormConfig(dbConfig)
+	config.TranslateError = true
+	db, err := gorm.Open(mysql.Open(cfg.FormatDSN()), config)
+	if err != nil {
+		return nil, fmt.Errorf("open database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, fmt.Errorf("db instance: %w", err)
+	}
+	// The server drops idle connections after wait_timeout
+	sqlDB.SetConnMaxLifetime(5 * time.Minute)
+	if conns := dbConfig.MaxOpenConns; conns > 0 {
+		sqlDB.SetMaxOpenConns(conns)
+	}
 	return db, nil
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings can also come from a YAML or TOML file, for self-hosted
+// deployments that would rather not pass every variable through compose.
+// The file is CONFIG_FILE, or else the first of recipes.yaml, recipes.yml
+// and recipes.toml in the working directory. Each key path names the
+// environment variable it sets, joined with underscores and upper-cased:
+//
+//	port: 8080              # PORT
+//	db:
+//	  driver: mysql         # DB_DRIVER
+//	database:
+//	  dsn: recipes:secret@tcp(db:3306)/recipes
+//	queue:
+//	  concurrency: 4        # QUEUE_CONCURRENCY
+//	admin_users: [chef@example.com, cook@example.com]
+//
+// Lists are joined with commas. Variables already set in the environment or
+// .env win over the file, so one value can be overridden without editing it.
+var configFileNames = []string{"recipes.yaml", "recipes.yml", "recipes.toml"}
+
+// loadConfigFile sets the variables the config file names that aren't
+// already set. It returns the path read, or "" when there is no file.
+func loadConfigFile() (string, error) {
+	path := strings.TrimSpace(os.Getenv("CONFIG_FILE"))
+	if path == "" {
+		for _, name := range configFileNames {
+			if _, err := os.Stat(name); err == nil {
+				path = name
+				break
+			}
+		}
+		if path == "" {
+			return "", nil
+		}
+	}
+
+	vars, err := readConfigFile(path)
+	if err != nil {
+		return path, err
+	}
+	for name, value := range vars {
+		if strings.TrimSpace(os.Getenv(name)) != "" {
+			continue
+		}
+		if err := os.Setenv(name, value); err != nil {
+			return path, fmt.Errorf("set %s: %w", name, err)
+		}
+	}
+	return path, nil
+}
+
+// readConfigFile parses the file at path into environment variables.
+func readConfigFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read config file: %w", err)
+	}
+	var tree map[string]any
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s: %q is not .yaml, .yml or .toml", path, ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+
+	vars := map[string]string{}
+	var errs []error
+	flattenConfig("", tree, vars, &errs)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("config file %s: %w", path, errors.Join(errs...))
+	}
+	return vars, nil
+}
+
+func flattenConfig(name string, value any, vars map[string]string, errs *[]error) {
+	switch value := value.(type) {
+	case nil:
+	case map[string]any:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		// Sorted so errors come out in the same order every time
+		sort.Strings(keys)
+		for _, key := range keys {
+			flattenConfig(configVarName(name, key), value[key], vars, errs)
+		}
+	case []any:
+		items := make([]string, 0, len(value))
+		for _, item := range value {
+			text, ok := configScalar(item)
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("%s: lists can only hold plain values", name))
+				return
+			}
+			items = append(items, text)
+		}
+		vars[name] = strings.Join(items, ",")
+	default:
+		text, ok := configScalar(value)
+		if !ok {
+			*errs = append(*errs, fmt.Errorf("%s: %T is not a string, number or boolean", name, value))
+			return
+		}
+		vars[name] = text
+	}
+}
+
+func configVarName(prefix, key string) string {
+	key = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(strings.TrimSpace(key)))
+	if prefix == "" {
+		return key
+	}
+	return prefix + "_" + key
+}
+
+func configScalar(value any) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case bool:
+		return strconv.FormatBool(value), true
+	case int:
+		return strconv.Itoa(value), true
+	case int64:
+		return strconv.FormatInt(value, 10), true
+	case uint64:
+		return strconv.FormatUint(value, 10), true
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64), true
+	}
+	return "", false
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadConfigFileLetsTheEnvironmentWin(t *testing.T) {
+	for _, tc := range []struct{ name, contents string }{
+		{"recipes.yaml", `
+port: 8081
+database:
+  dsn: recipes:secret@tcp(db:3306)/recipes
+queue:
+  concurrency: 4
+scraper:
+  dismiss-consent: false
+admin_users: [chef@example.com, cook@example.com]
+`},
+		{"recipes.toml", `
+port = 8081
+admin_users = ["chef@example.com", "cook@example.com"]
+
+[database]
+dsn = "recipes:secret@tcp(db:3306)/recipes"
+
+[queue]
+concurrency = 4
+
+[scraper]
+dismiss-consent = false
+`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tc.name)
+			if err := os.WriteFile(path, []byte(tc.contents), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			t.Setenv("CONFIG_FILE", path)
+			t.Setenv("QUEUE_CONCURRENCY", "8")
+			// Blank counts as unset, as compose passes unset variables
+			for _, name := range []string{"PORT", "DATABASE_DSN", "SCRAPER_DISMISS_CONSENT", "ADMIN_USERS"} {
+				t.Setenv(name, "")
+			}
+
+			if got, err := loadConfigFile(); err != nil || got != path {
+				t.Fatalf("load = %q, %v", got, err)
+			}
+			for name, want := range map[string]string{
+				"PORT":                    "8081",
+				"DATABASE_DSN":            "recipes:secret@tcp(db:3306)/recipes",
+				"QUEUE_CONCURRENCY":       "8",
+				"SCRAPER_DISMISS_CONSENT": "false",
+				"ADMIN_USERS":             "chef@example.com,cook@example.com",
+			} {
+				if got := os.Getenv(name); got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadConfigFileRejectsNestedLists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recipes.yaml")
+	if err := os.WriteFile(path, []byte("ai:\n  providers: [[openai]]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readConfigFile(path); err == nil {
+		t.Fatal("read a file with a nested list")
+	}
+}
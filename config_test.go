@@ -0,0 +1,77 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestSQLiteConcurrentWritesWait(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(sqliteDSN(filepath.Join(t.TempDir(), "recipes.db"))), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db instance: %v", err)
+	}
+	sqlDB.SetMaxOpenConns(dbMaxOpenConns)
+	t.Cleanup(func() { sqlDB.Close() })
+
+	var mode string
+	if err := db.Raw("PRAGMA journal_mode").Scan(&mode).Error; err != nil || mode != "wal" {
+		t.Fatalf("journal_mode = %q (err %v), want wal", mode, err)
+	}
+	if err := db.Exec("CREATE TABLE counters (name TEXT PRIMARY KEY, n INTEGER NOT NULL)").Error; err != nil {
+		t.Fatalf("create table: %v", err)
+	}
+
+	// Read-then-write transactions on separate connections; with deferred
+	// locking these fail with "database is locked" rather than waiting
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- db.Transaction(func(tx *gorm.DB) error {
+				var n int64
+				if err := tx.Raw("SELECT COUNT(*) FROM counters").Scan(&n).Error; err != nil {
+					return err
+				}
+				time.Sleep(5 * time.Millisecond)
+				return tx.Exec("INSERT INTO counters (name, n) VALUES (?, ?)", fmt.Sprintf("c%d", i), n).Error
+			})
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("concurrent write: %v", err)
+		}
+	}
+}
+
+func TestSQLiteEnforcesForeignKeys(t *testing.T) {
+	repo := newTestRepo(t)
+	userID := createTestUser(t, repo, "cook@example.com")
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/soup", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := repo.db.Exec("DELETE FROM users WHERE id = ?", userID).Error; err != nil {
+		t.Fatalf("delete user: %v", err)
+	}
+	var left int64
+	if err := repo.db.Model(&QueueModel{}).Count(&left).Error; err != nil || left != 0 {
+		t.Fatalf("queue rows after deleting the user = %d (err %v), want 0", left, err)
+	}
+}
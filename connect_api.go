@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"connectrpc.com/connect"
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	recipesv1 "cooking.bronson.dev/proto/recipes/v1"
+	"cooking.bronson.dev/proto/recipes/v1/recipesv1connect"
+)
+
+// Services for proto/recipes/v1/recipes.proto. The handlers are generated by
+// protoc-gen-connect-go (see buf.gen.yaml), so every call can use the
+// Connect, gRPC or gRPC-Web protocol with the binary or JSON codec; gRPC
+// needs HTTP/2, which the server also speaks in cleartext (h2c).
+
+const maxConnectRequestBytes = 1 << 20
+
+// registerConnectServices mounts the Connect handlers on router.
+func registerConnectServices(router *gin.Engine) {
+	opts := []connect.HandlerOption{connect.WithReadMaxBytes(maxConnectRequestBytes)}
+	mount := func(path string, handler http.Handler) {
+		router.POST(path+":method", gin.WrapH(handler))
+	}
+	mount(recipesv1connect.NewAuthServiceHandler(connectAuthService{}, opts...))
+	mount(recipesv1connect.NewRecipeServiceHandler(connectRecipeService{}, opts...))
+	mount(recipesv1connect.NewQueueServiceHandler(connectQueueService{}, opts...))
+}
+
+func connectUsername(header http.Header) (string, error) {
+	username, err := extractUsernameFromBearer(header.Get("Authorization"))
+	if err != nil {
+		return "", connect.NewError(connect.CodeUnauthenticated, err)
+	}
+	return username, nil
+}
+
+func connectInternal(message string) error {
+	return connect.NewError(connect.CodeInternal, errors.New(message))
+}
+
+func connectInvalid(message string) error {
+	return connect.NewError(connect.CodeInvalidArgument, errors.New(message))
+}
+
+type connectAuthService struct{}
+
+func (connectAuthService) Login(ctx context.Context, req *connect.Request[recipesv1.LoginRequest]) (*connect.Response[recipesv1.LoginResponse], error) {
+	username, password := req.Msg.GetUsername(), req.Msg.GetPassword()
+	if username == "" || password == "" {
+		return nil, connectInvalid("username and password are required")
+	}
+
+	if _, err := recipeRepo.WithContext(ctx).AuthenticateUser(username, password); err != nil {
+		if strings.Contains(err.Error(), "invalid credentials") {
+			return nil, connect.NewError(connect.CodeUnauthenticated, errors.New("invalid credentials"))
+		}
+		log.Printf("Connect login error for username %s: %v", username, err)
+		return nil, connectInternal("failed to authenticate")
+	}
+	if err := recipeRepo.WithContext(ctx).TouchUserActivity(username, true); err != nil {
+		log.Printf("Failed to record login activity for %s: %v", username, err)
+	}
+
+	token, err := generateToken(username, tokenTTL)
+	if err != nil {
+		log.Printf("Error generating token for %s: %v", username, err)
+		return nil, connectInternal("failed to generate token")
+	}
+	return connect.NewResponse(&recipesv1.LoginResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int32(tokenTTL.Seconds()),
+	}), nil
+}
+
+type connectRecipeService struct{}
+
+func (connectRecipeService) ListRecipes(ctx context.Context, req *connect.Request[recipesv1.ListRecipesRequest]) (*connect.Response[recipesv1.ListRecipesResponse], error) {
+	username, err := connectUsername(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	filters, err := connectRecipeFilters(req.Msg.GetFilters())
+	if err != nil {
+		return nil, err
+	}
+
+	recipes, err := listRecipes(recipeRepo.WithContext(ctx), username, filters, false)
+	if err != nil {
+		log.Printf("Connect: error listing recipes for %s: %v", username, err)
+		return nil, connectInternal("failed to list recipes")
+	}
+	if recipes, err = filterRecipesByPreferences(recipeRepo.WithContext(ctx), username, recipes, nil, false, true); err != nil {
+		log.Printf("Connect: error fetching preferences for %s: %v", username, err)
+		return nil, connectInternal("failed to list recipes")
+	}
+	return connectRecipePage(recipes, req.Msg.GetLimit(), req.Msg.GetOffset())
+}
+
+func (connectRecipeService) GetRecipe(ctx context.Context, req *connect.Request[recipesv1.GetRecipeRequest]) (*connect.Response[recipesv1.Recipe], error) {
+	username, err := connectUsername(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	id := uint(req.Msg.GetId())
+	if id == 0 {
+		return nil, connectInvalid("id is required")
+	}
+
+	recipe, err := recipeRepo.WithContext(ctx).GetRecipeByID(username, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, connect.NewError(connect.CodeNotFound, errors.New("recipe not found"))
+		}
+		log.Printf("Connect: error fetching recipe %d for %s: %v", id, username, err)
+		return nil, connectInternal("failed to fetch recipe")
+	}
+	return connect.NewResponse(toPBRecipe(recipe)), nil
+}
+
+func (connectRecipeService) SearchRecipes(ctx context.Context, req *connect.Request[recipesv1.SearchRecipesRequest]) (*connect.Response[recipesv1.ListRecipesResponse], error) {
+	username, err := connectUsername(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	filters, err := connectRecipeFilters(req.Msg.GetFilters())
+	if err != nil {
+		return nil, err
+	}
+
+	opts := RecipeSearchOptions{
+		Term:        req.Msg.GetQuery(),
+		Ingredients: normalizeTerms(req.Msg.GetIngredients()),
+		Filters:     filters,
+	}
+	recipes, _, err := recipeRepo.WithContext(ctx).SearchRecipes(username, opts)
+	if err != nil {
+		log.Printf("Connect: error searching recipes for %s: %v", username, err)
+		return nil, connectInternal("failed to search recipes")
+	}
+	if recipes, err = filterRecipesByPreferences(recipeRepo.WithContext(ctx), username, recipes, nil, false, true); err != nil {
+		log.Printf("Connect: error fetching preferences for %s: %v", username, err)
+		return nil, connectInternal("failed to search recipes")
+	}
+	return connectRecipePage(recipes, req.Msg.GetLimit(), req.Msg.GetOffset())
+}
+
+func (connectRecipeService) ListFavorites(ctx context.Context, req *connect.Request[recipesv1.ListFavoritesRequest]) (*connect.Response[recipesv1.ListRecipesResponse], error) {
+	username, err := connectUsername(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	recipes, err := recipeRepo.WithContext(ctx).ListFavoriteRecipes(username)
+	if err != nil {
+		log.Printf("Connect: error listing favorites for %s: %v", username, err)
+		return nil, connectInternal("failed to list favorites")
+	}
+	return connect.NewResponse(toPBListRecipesResponse(recipes, len(recipes))), nil
+}
+
+type connectQueueService struct{}
+
+func (connectQueueService) EnqueueRecipe(ctx context.Context, req *connect.Request[recipesv1.EnqueueRecipeRequest]) (*connect.Response[recipesv1.EnqueueRecipeResponse], error) {
+	username, err := connectUsername(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(req.Msg.GetUrl()) == "" {
+		return nil, connectInvalid("url is required")
+	}
+	recipeURL := canonicalImportURL(req.Msg.GetUrl())
+
+	if frozen, err := recipeRepo.WithContext(ctx).IsUserFrozen(username); err != nil {
+		log.Printf("Frozen check failed for %s: %v", username, err)
+	} else if frozen {
+		return nil, connect.NewError(connect.CodePermissionDenied, errors.New("account frozen due to inactivity; sign in again to reactivate"))
+	}
+
+	if linked, slug, err := recipeRepo.WithContext(ctx).LinkRecipeIfExists(username, recipeURL); err != nil {
+		log.Printf("Failed to link existing recipe for %s: %v", username, err)
+		return nil, connectInternal("failed to save recipe")
+	} else if linked {
+		recipesSavedTotal.WithLabelValues(recipeSourceLinked).Inc()
+		recipeCache.Delete(singleRecipeCacheKey(username, slug))
+		invalidateUserRecipeCaches(username)
+		return connect.NewResponse(&recipesv1.EnqueueRecipeResponse{Status: "linked"}), nil
+	}
+
+	if err := recipeRepo.WithContext(ctx).EnqueueRecipe(username, recipeURL, queuePriorityInteractive); err != nil {
+		log.Printf("Failed to enqueue recipe for %s: %v", username, err)
+		return nil, connectInternal("failed to queue recipe")
+	}
+	jobQueue.Wake()
+	return connect.NewResponse(&recipesv1.EnqueueRecipeResponse{Status: "queued"}), nil
+}
+
+func (connectQueueService) ListQueue(ctx context.Context, req *connect.Request[recipesv1.ListQueueRequest]) (*connect.Response[recipesv1.ListQueueResponse], error) {
+	username, err := connectUsername(req.Header())
+	if err != nil {
+		return nil, err
+	}
+	if req.Msg.GetLimit() < 0 {
+		return nil, connectInvalid("limit must not be negative")
+	}
+	limit := defaultQueueListSize
+	if req.Msg.GetLimit() > 0 {
+		limit = min(int(req.Msg.GetLimit()), maxPageSize)
+	}
+
+	items, err := recipeRepo.WithContext(ctx).ListQueueItems(username, limit)
+	if err != nil {
+		log.Printf("Connect: error listing queue for %s: %v", username, err)
+		return nil, connectInternal("failed to list queue")
+	}
+
+	response := &recipesv1.ListQueueResponse{Items: make([]*recipesv1.QueueItem, 0, len(items))}
+	for _, item := range items {
+		response.Items = append(response.Items, toPBQueueItem(item))
+	}
+	return connect.NewResponse(response), nil
+}
+
+func connectRecipeFilters(in *recipesv1.RecipeFilters) (RecipeFilters, error) {
+	var filters RecipeFilters
+	if category := strings.TrimSpace(in.GetCategory()); category != "" {
+		norm, ok := normalizeCategoryStrict(category)
+		if !ok {
+			return RecipeFilters{}, connectInvalid("invalid category; allowed: breakfast, dinner, baking, other")
+		}
+		filters.Category = norm
+	}
+	if in.GetMaxTotalTime() < 0 {
+		return RecipeFilters{}, connectInvalid("invalid maxTotalTime")
+	}
+	filters.FavoritesOnly = in.GetFavoritesOnly()
+	filters.Tags = normalizeTerms(in.GetTags())
+	filters.MaxTotalTime = int(in.GetMaxTotalTime())
+	return filters, nil
+}
+
+// connectRecipePage pages recipes; a zero limit means defaultPageSize.
+func connectRecipePage(recipes []Recipe, limit, offset int32) (*connect.Response[recipesv1.ListRecipesResponse], error) {
+	if limit < 0 || offset < 0 {
+		return nil, connectInvalid("limit and offset must not be negative")
+	}
+	size := defaultPageSize
+	if limit > 0 {
+		size = min(int(limit), maxPageSize)
+	}
+	page := paginateRecipes(recipes, Pagination{Limit: size, Offset: int(offset)})
+	return connect.NewResponse(toPBListRecipesResponse(page, len(recipes))), nil
+}
+
+func toPBListRecipesResponse(recipes []Recipe, total int) *recipesv1.ListRecipesResponse {
+	response := &recipesv1.ListRecipesResponse{Recipes: make([]*recipesv1.Recipe, 0, len(recipes)), Total: int32(total)}
+	for _, recipe := range recipes {
+		response.Recipes = append(response.Recipes, toPBRecipe(recipe))
+	}
+	return response
+}
+
+func toPBRecipe(recipe Recipe) *recipesv1.Recipe {
+	return &recipesv1.Recipe{
+		Id:           uint32(recipe.ID),
+		Title:        recipe.Title,
+		Category:     recipe.Category,
+		Image:        recipe.Image,
+		Ingredients:  recipe.Ingredients,
+		Instructions: recipe.Instructions,
+		Tags:         recipe.Tags,
+		Equipment:    recipe.Equipment,
+		PrepTime:     int32(recipe.PrepTime),
+		CookTime:     int32(recipe.CookTime),
+		TotalTime:    int32(recipe.TotalTime),
+		Servings:     int32(recipe.Servings),
+		OriginalUrl:  recipe.OriginalURL,
+		IsFavorite:   recipe.IsFavorite,
+		IsPublic:     recipe.IsPublic,
+		LastCookedAt: pbTimestamp(recipe.LastCookedAt),
+	}
+}
+
+func toPBQueueItem(item QueueItem) *recipesv1.QueueItem {
+	pb := &recipesv1.QueueItem{
+		Id:             uint32(item.ID),
+		Url:            item.URL,
+		Status:         item.Status,
+		Stage:          item.Stage,
+		StageChangedAt: pbTimestamp(item.StageChangedAt),
+		Attempts:       int32(item.Attempts),
+		ErrorCode:      item.ErrorCode,
+		RecipeSlug:     item.RecipeSlug,
+		Priority:       int32(item.Priority),
+		CreatedAt:      timestamppb.New(item.CreatedAt),
+		ProcessedAt:    pbTimestamp(item.ProcessedAt),
+		NextAttemptAt:  pbTimestamp(item.NextAttemptAt),
+		FailedAt:       pbTimestamp(item.FailedAt),
+	}
+	if item.LastError != nil {
+		pb.LastError = *item.LastError
+	}
+	return pb
+}
+
+func pbTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"connectrpc.com/connect"
+	"github.com/gin-gonic/gin"
+
+	recipesv1 "cooking.bronson.dev/proto/recipes/v1"
+	"cooking.bronson.dev/proto/recipes/v1/recipesv1connect"
+)
+
+func TestConnectServicesOverConnectAndGRPC(t *testing.T) {
+	jwtSecret = "test-secret"
+	recipeRepo = newTestRepo(t)
+	createTestUser(t, recipeRepo, "cook@example.com")
+	if err := recipeRepo.EnqueueRecipe("cook@example.com", "https://example.com/cake", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	registerConnectServices(router)
+	server := httptest.NewUnstartedServer(router)
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	for name, opts := range map[string][]connect.ClientOption{
+		"connect+proto": nil,
+		"connect+json":  {connect.WithProtoJSON()},
+		"grpc":          {connect.WithGRPC()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			auth := recipesv1connect.NewAuthServiceClient(server.Client(), server.URL, opts...)
+			login, err := auth.Login(ctx, connect.NewRequest(&recipesv1.LoginRequest{Username: "cook@example.com", Password: "password"}))
+			if err != nil {
+				t.Fatalf("login: %v", err)
+			}
+
+			queue := recipesv1connect.NewQueueServiceClient(server.Client(), server.URL, opts...)
+			if _, err := queue.ListQueue(ctx, connect.NewRequest(&recipesv1.ListQueueRequest{})); connect.CodeOf(err) != connect.CodeUnauthenticated {
+				t.Fatalf("list queue without a token: err = %v, want unauthenticated", err)
+			}
+			req := connect.NewRequest(&recipesv1.ListQueueRequest{Limit: 5})
+			req.Header().Set("Authorization", "Bearer "+login.Msg.GetAccessToken())
+			resp, err := queue.ListQueue(ctx, req)
+			if err != nil {
+				t.Fatalf("list queue: %v", err)
+			}
+			if items := resp.Msg.GetItems(); len(items) != 1 || items[0].GetUrl() != "https://example.com/cake" || items[0].GetStatus() != "pending" {
+				t.Fatalf("queue = %v, want the pending cake", items)
+			}
+		})
+	}
+}
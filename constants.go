@@ -3,9 +3,243 @@ package main
 import "time"
 
 const (
-	tokenTTL          = 8999 * time.Hour
+	tokenTTL         = 8999 * time.Hour
+	passwordResetTTL = 1 * time.Hour
+	// Queue defaults, see QueueSettings
 	queuePollInterval = 1 * time.Minute
 	queueBatchSize    = 5
 	queueConcurrency  = 4
-	passwordResetTTL  = 1 * time.Hour
+	maxQueueAttempts  = 5
+	// Backoff between attempts at a failed queue item, see queueRetryPolicy
+	queueRetryBaseDelay = 2 * time.Minute
+	queueRetryMaxDelay  = 2 * time.Hour
+	// How long a stopping worker waits for in-flight items before
+	// cancelling them back to pending
+	queueDrainTimeout = 25 * time.Second
+	// How long a worker's claim on a queue item lasts; longer than an
+	// import takes, so only a dead worker's items are taken over
+	queueClaimLease = 30 * time.Minute
+	// How long one import may take before it counts as a failed attempt
+	queueItemTimeout = 10 * time.Minute
+
+	// Queue priorities, highest processed first: URLs a user just
+	// submitted, links from emails, then reprocessing jobs
+	queuePriorityInteractive = 20
+	queuePriorityBulk        = 10
+	queuePriorityBackground  = 0
+
+	// SQLite connection pool size, see openSQLite
+	dbMaxOpenConns = 8
+	// Queries slower than this are logged and counted, see gormConfig
+	defaultSlowQueryThreshold = 200 * time.Millisecond
+
+	maxRecipeImageBytes = 10 << 20
+	maxImportBytes      = 50 << 20
+	maxPageHTMLBytes    = 5 << 20
+	maxInboundBytes     = 25 << 20
+
+	// Idempotency-Key: how long a response is kept for replay, and the
+	// longest key accepted
+	idempotencyKeyTTL       = 24 * time.Hour
+	maxIdempotencyKeyLength = 255
+
+	// mailgunSignatureMaxAge bounds how old a signed webhook timestamp may be
+	mailgunSignatureMaxAge = 15 * time.Minute
+	// maxInboundURLs caps how many links one email can queue
+	maxInboundURLs = 5
+
+	// The calendar feed covers recent and upcoming planned meals
+	calendarPastDays      = 14
+	calendarFutureDays    = 90
+	maxCookAgainReminders = 10
+	// maxMealPlanDays bounds a /meal-plan listing range
+	maxMealPlanDays = 366
+
+	// Generated meal plans: the default and longest length in days, how
+	// many recently cooked days are skipped by default, how many recipes
+	// are drawn to choose from, and the AI suggestion reply limit
+	defaultGeneratedPlanDays     = 7
+	maxGeneratedPlanDays         = 28
+	defaultPlanExcludeCookedDays = 14
+	maxMealPlanCandidates        = 200
+	mealSuggestionMaxTokens      = 2048
+
+	// "Cook with what I have": how many ingredients a request may list,
+	// how many suggestions it returns, the share of a saved recipe's
+	// ingredients that must be on hand, and how many recipes the AI
+	// fallback generates
+	maxPantryIngredients      = 100
+	defaultSuggestLimit       = 10
+	maxSuggestLimit           = 50
+	minPantryCoverage         = 0.5
+	maxAIPantrySuggestions    = 3
+	pantrySuggestionMaxTokens = 4096
+
+	defaultFeedItems = 20
+	maxFeedItems     = 100
+
+	maxWebhooksPerUser = 10
+
+	// AI usage reports cover ?days= (default 30), and the admin rollup lists
+	// the costliest users and pages
+	defaultUsageDays = 30
+	maxUsageDays     = 366
+	adminUsageTopN   = 20
+
+	defaultPageSize = 50
+	maxPageSize     = 200
+
+	maxGraphQLQueryBytes = 32 << 10
+	maxGraphQLComplexity = 1000
+	defaultQueueListSize = 20
+
+	// Scraper defaults for SCRAPER_NAV_TIMEOUT, SCRAPER_HTTP_TIMEOUT,
+	// SCRAPER_MAX_RETRIES (fetch retries after the first attempt) and the
+	// backoff between them
+	defaultScraperNavTimeout    = 60 * time.Second
+	defaultScraperHTTPTimeout   = 60 * time.Second
+	defaultScraperRetries       = 2
+	defaultScraperRetryDelay    = 2 * time.Second
+	defaultScraperRetryMaxDelay = 30 * time.Second
+	robotsFetchTimeout          = 10 * time.Second
+	// scrapeClaimTTL outlasts a scrape's navigation retries and AI call, so
+	// a claim only expires when its instance died
+	scrapeClaimTTL = 15 * time.Minute
+	// consentSettleDelay is the pause after clicking a consent button
+	consentSettleDelay = 1 * time.Second
+
+	// Time limits for AI calls: recipe extraction writes a lot of output
+	aiRecipeTimeout  = 240 * time.Second
+	aiRequestTimeout = 60 * time.Second
+	// aiStreamTimeout bounds a streamed chat reply, which the client
+	// watches arrive rather than waiting on
+	aiStreamTimeout = 240 * time.Second
+	// sseHeartbeatInterval is how often an idle event stream sends a
+	// comment so proxies don't close it
+	sseHeartbeatInterval = 15 * time.Second
+	// queueEventsPollInterval is how often /queue/events checks the queue
+	// for finished items
+	queueEventsPollInterval = 2 * time.Second
+	// queueEventsTokenTTL is how long a token from /queue/events/token can
+	// be used to open the stream; an open stream isn't cut off
+	queueEventsTokenTTL = time.Minute
+	// queueCancelPollInterval is how often a worker checks whether the
+	// items it is importing were cancelled
+	queueCancelPollInterval = 2 * time.Second
+	// With QUEUE_BACKEND=redis: the most ready items published to Redis at
+	// a time, how long a worker may spend on one, and how long past the
+	// drain timeout a stopping worker waits for cancelled tasks to return
+	asynqPublishBatch  = 500
+	asynqTaskTimeout   = 15 * time.Minute
+	asynqShutdownGrace = 5 * time.Second
+	// defaultAIMaxTokens is the extraction output limit (AI_MAX_TOKENS)
+	defaultAIMaxTokens = 16384
+
+	// maxRecipeTextChars caps the page, PDF or video text sent to the AI,
+	// and minRecipeCardChars is how much text a recipe plugin block needs
+	// to be used on its own
+	maxRecipeTextChars = 30000
+	minRecipeCardChars = 200
+	// defaultAICacheTTL is how long an AI extraction is reused for the same
+	// text (AI_CACHE_TTL)
+	defaultAICacheTTL = 30 * 24 * time.Hour
+	// aiExtractionCacheVersion is part of the AI cache key; bump it when the
+	// extraction pipeline changes (checks, repair prompt, post-processing)
+	// in a way that should stop earlier answers being reused
+	aiExtractionCacheVersion = 2
+
+	// Checks on AI extractions: the longest plausible ingredient line and
+	// amount, and how much of a bad answer is shown back to the AI when
+	// asking it to fix one
+	maxIngredientLineChars = 300
+	maxIngredientAmount    = 10000
+	maxRepairEchoChars     = 20000
+
+	// maxTranscriptChars bounds how much of a video transcript is sent to
+	// the AI
+	maxTranscriptChars = 60000
+
+	// PDF imports: how long pdftotext may run, and the most a single
+	// compressed stream may inflate to in the built-in reader
+	pdfTextTimeout    = 30 * time.Second
+	maxPDFStreamBytes = 20 << 20
+
+	// Scraper politeness: the default gap between fetches from one domain,
+	// the most of a robots.txt Crawl-delay honored, and how long robots.txt
+	// is cached (briefly when it couldn't be fetched)
+	defaultScrapeInterval = 5 * time.Second
+	maxCrawlDelay         = 1 * time.Minute
+	robotsCacheTTL        = 24 * time.Hour
+	robotsRetryTTL        = 10 * time.Minute
+	maxRobotsBytes        = 500 << 10
+
+	// USDA FoodData Central lookups: the time limit per request and how
+	// long food matches are cached
+	fdcRequestTimeout = 15 * time.Second
+	fdcCacheTTL       = 7 * 24 * time.Hour
+
+	// Questions about a recipe: the longest question taken, how many earlier
+	// messages go to the AI with it, the most kept for display, and the
+	// answer length limit
+	maxRecipeQuestionChars    = 2000
+	recipeChatContextMessages = 20
+	maxRecipeChatHistory      = 200
+	recipeChatMaxTokens       = 1024
+
+	// ingredientParseMaxTokens bounds the AI reply when parsing ingredient
+	// lines
+	ingredientParseMaxTokens = 8192
+
+	// Reprocessing of incomplete recipes: how often the job runs
+	// (RECIPE_REPROCESS_INTERVAL), how many it queues per run, and the
+	// backoff and attempt limit per recipe
+	defaultReprocessInterval = 1 * time.Hour
+	reprocessBatchSize       = 50
+	reprocessBaseDelay       = 1 * time.Hour
+	reprocessMaxDelay        = 7 * 24 * time.Hour
+	maxReprocessAttempts     = 8
+
+	// GET /profile/stats covers this many months of added recipes and
+	// days of imports
+	profileStatsMonths     = 12
+	profileStatsImportDays = 30
+
+	// randomRecipeCandidates is how many random rows are drawn before the
+	// in-memory preference filters pick one; when they drop them all, the
+	// draw grows by randomRecipeGrowth until every recipe has been tried
+	randomRecipeCandidates = 25
+	randomRecipeGrowth     = 4
+
+	// Retention cleanup: how often it runs (RETENTION_INTERVAL), how long
+	// finished queue items are kept (QUEUE_RETENTION), and the rows
+	// deleted per statement so the database lock isn't held for long
+	defaultRetentionInterval = 24 * time.Hour
+	defaultQueueRetention    = 30 * 24 * time.Hour
+	retentionDeleteBatch     = 1000
+	// How often a feed token's last use is written, see UsernameForFeedToken
+	feedTokenUseInterval = 24 * time.Hour
+
+	// Database backups: how often they run (BACKUP_INTERVAL), how many are
+	// kept in R2 (BACKUP_KEEP), and where
+	defaultBackupInterval = 24 * time.Hour
+	defaultBackupKeep     = 14
+	backupKeyPrefix       = "backups/db/"
+
+	// How long each /health and /readyz probe may take
+	healthCheckTimeout = 3 * time.Second
+
+	// How many recently active users' recipe lists are cached at startup
+	// (CACHE_WARM_USERS)
+	defaultCacheWarmUsers = 20
+
+	// How long shutdown waits for buffered spans to reach the collector
+	tracingFlushTimeout = 5 * time.Second
+
+	// How long shutdown waits for queued error reports to reach Sentry
+	errorReportingFlushTimeout = 2 * time.Second
+
+	// How long a request may run (REQUEST_TIMEOUT), and one that imports,
+	// exports or waits on the AI (REQUEST_TIMEOUT_LONG); see requestTimeout
+	defaultRequestTimeout     = 30 * time.Second
+	defaultLongRequestTimeout = 5 * time.Minute
 )
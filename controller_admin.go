@@ -0,0 +1,34 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleAdminBackup backs the database up to R2 now, rotating old backups
+// as the scheduled job does.
+func handleAdminBackup(c *gin.Context) {
+	admin, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	backup, err := backupToR2(recipeRepo)
+	switch {
+	case errors.Is(err, errBackupRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errBackupUnsupported):
+		c.JSON(http.StatusNotImplemented, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Backup by %s failed: %v", admin, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to back up database"})
+		return
+	}
+	log.Printf("Backup by %s uploaded %s (%d bytes)", admin, backup.Key, backup.Bytes)
+	c.JSON(http.StatusOK, backup)
+}
@@ -11,11 +11,14 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// credentialsRequest is the body of /register and /login.
+type credentialsRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 func handleRegister(c *gin.Context) {
-	var request struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var request credentialsRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Printf("Register JSON binding error: %v", err)
@@ -23,7 +26,7 @@ func handleRegister(c *gin.Context) {
 		return
 	}
 
-	if err := recipeRepo.CreateUser(request.Username, request.Password); err != nil {
+	if err := requestRepo(c).CreateUser(request.Username, request.Password); err != nil {
 		status := http.StatusInternalServerError
 		if strings.Contains(err.Error(), "username already exists") {
 			status = http.StatusConflict
@@ -36,11 +39,14 @@ func handleRegister(c *gin.Context) {
 	c.JSON(http.StatusCreated, gin.H{"message": "user registered"})
 }
 
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int    `json:"expires_in"`
+}
+
 func handleLogin(c *gin.Context) {
-	var request struct {
-		Username string `json:"username" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var request credentialsRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Printf("Login JSON binding error: %v, request body: %+v", err, c.Request.Body)
@@ -48,7 +54,7 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
-	if _, err := recipeRepo.AuthenticateUser(request.Username, request.Password); err != nil {
+	if _, err := requestRepo(c).AuthenticateUser(request.Username, request.Password); err != nil {
 		if strings.Contains(err.Error(), "invalid credentials") {
 			log.Printf("Login failed - invalid credentials for username: %s", request.Username)
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
@@ -59,6 +65,10 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
+	if err := requestRepo(c).TouchUserActivity(request.Username, true); err != nil {
+		log.Printf("Failed to record login activity for %s: %v", request.Username, err)
+	}
+
 	token, err := generateToken(request.Username, tokenTTL)
 	if err != nil {
 		log.Printf("Error generating token for %s: %v", request.Username, err)
@@ -66,17 +76,19 @@ func handleLogin(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"access_token": token,
-		"token_type":   "Bearer",
-		"expires_in":   int(tokenTTL.Seconds()),
+	c.JSON(http.StatusOK, tokenResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(tokenTTL.Seconds()),
 	})
 }
 
+type passwordResetRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
 func handlePasswordResetRequest(c *gin.Context) {
-	var request struct {
-		Username string `json:"username" binding:"required"`
-	}
+	var request passwordResetRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Printf("Password reset request JSON binding error: %v", err)
@@ -84,7 +96,7 @@ func handlePasswordResetRequest(c *gin.Context) {
 		return
 	}
 
-	token, err := recipeRepo.CreatePasswordReset(request.Username, passwordResetTTL)
+	token, err := requestRepo(c).CreatePasswordReset(request.Username, passwordResetTTL)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Printf("Password reset requested for non-existent user: %s", request.Username)
@@ -105,11 +117,13 @@ func handlePasswordResetRequest(c *gin.Context) {
 	c.JSON(http.StatusAccepted, gin.H{"message": "password reset email sent"})
 }
 
+type passwordResetConfirmRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
 func handlePasswordResetConfirm(c *gin.Context) {
-	var request struct {
-		Token    string `json:"token" binding:"required"`
-		Password string `json:"password" binding:"required"`
-	}
+	var request passwordResetConfirmRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Printf("Password reset confirm JSON binding error: %v", err)
@@ -117,7 +131,7 @@ func handlePasswordResetConfirm(c *gin.Context) {
 		return
 	}
 
-	if err := recipeRepo.ResetPasswordWithToken(request.Token, request.Password); err != nil {
+	if err := requestRepo(c).ResetPasswordWithToken(request.Token, request.Password); err != nil {
 		if strings.Contains(err.Error(), "invalid or expired token") {
 			log.Printf("Password reset failed - invalid/expired token: %s", request.Token)
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired token"})
@@ -131,6 +145,12 @@ func handlePasswordResetConfirm(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"message": "password reset successful"})
 }
 
+type profileResponse struct {
+	Email        string `json:"email"`
+	CreatedAt    string `json:"createdAt"`
+	PublicHandle string `json:"publicHandle"`
+}
+
 func handleGetProfile(c *gin.Context) {
 	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
 	if err != nil {
@@ -139,7 +159,7 @@ func handleGetProfile(c *gin.Context) {
 		return
 	}
 
-	profile, err := recipeRepo.GetUserProfile(username)
+	profile, err := requestRepo(c).GetUserProfile(username)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Printf("Profile not found for username: %s", username)
@@ -151,8 +171,9 @@ func handleGetProfile(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"email":     profile.Username,
-		"createdAt": profile.CreatedAt.UTC().Format(time.RFC3339),
+	c.JSON(http.StatusOK, profileResponse{
+		Email:        profile.Username,
+		CreatedAt:    profile.CreatedAt.UTC().Format(time.RFC3339),
+		PublicHandle: profile.PublicHandle,
 	})
 }
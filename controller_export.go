@@ -0,0 +1,339 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleExportAccount(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	export, err := requestRepo(c).ExportAccount(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("Error exporting account for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export recipes"})
+		return
+	}
+
+	date := export.ExportedAt.Format("2006-01-02")
+	switch strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "json"))) {
+	case "json":
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "recipes-export-"+date+".json"))
+		c.IndentedJSON(http.StatusOK, export)
+	case "markdown", "md":
+		archive, err := zipRecipesMarkdown(export.Recipes)
+		if err != nil {
+			log.Printf("Error zipping markdown export for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export recipes"})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "recipes-markdown-"+date+".zip"))
+		c.Data(http.StatusOK, "application/zip", archive)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format; allowed: json, markdown"})
+	}
+}
+
+// handleExportRecipe exports a single recipe; ?servings= scales it first.
+func handleExportRecipe(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		log.Printf("Export recipe auth error: %v, Header: %s", err, c.GetHeader("Authorization"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.DefaultQuery("format", "markdown")))
+	if format != "markdown" && format != "md" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported format; allowed: markdown"})
+		return
+	}
+
+	recipe, err := requestRepo(c).GetRecipeByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error fetching recipe id=%d for export by %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to export recipe"})
+		return
+	}
+	scaleRecipeFromQuery(c, &recipe)
+
+	name := slugify(recipe.Title)
+	if name == "" {
+		name = "recipe"
+	}
+	if strings.EqualFold(c.Query("download"), "true") {
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+".md"))
+	}
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", []byte(renderRecipeMarkdown(recipe)))
+}
+
+// handleImportBackup restores a backup produced by GET /export, or a bare
+// JSON array of recipe rows. Sent either as the request body or as a
+// multipart "file" upload.
+func handleImportBackup(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectIfFrozen(c, username) {
+		return
+	}
+
+	data, err := readImportUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := decodeBackup(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := importRecipes(username, items, false)
+	if len(result.Created) > 0 {
+		invalidateUserRecipeCaches(username)
+	}
+	log.Printf("Import for %s: %d created, %d skipped, %d failed", username, len(result.Created), len(result.Skipped), len(result.Failed))
+
+	c.JSON(http.StatusOK, result)
+}
+
+// handleImportFormat imports another app's export file: paprika, mealie,
+// nextcloud (or any schema.org JSON), tandoor, or our own backup. With
+// ?dryRun=true nothing is saved and the response lists what would be created.
+// Photos are uploaded to R2 in the background after the recipes are saved.
+func handleImportFormat(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectIfFrozen(c, username) {
+		return
+	}
+
+	format := strings.ToLower(strings.TrimSpace(c.Param("format")))
+	decode, ok := importFormats[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unsupported import format; allowed: backup, paprika, mealie, nextcloud, schemaorg, tandoor"})
+		return
+	}
+	dryRun := strings.EqualFold(strings.TrimSpace(c.Query("dryRun")), "true")
+
+	data, err := readImportUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := decode(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	result := importRecipes(username, items, dryRun)
+	if len(result.Created) > 0 && !dryRun {
+		invalidateUserRecipeCaches(username)
+	}
+	log.Printf("%s import for %s (dryRun=%t): %d created, %d skipped, %d failed", format, username, dryRun, len(result.Created), len(result.Skipped), len(result.Failed))
+
+	c.JSON(http.StatusOK, result)
+}
+
+// readImportUpload returns the uploaded file from a multipart "file" field,
+// or the raw request body otherwise.
+func readImportUpload(c *gin.Context) ([]byte, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportBytes)
+
+	if strings.HasPrefix(c.ContentType(), "multipart/") {
+		fileHeader, err := c.FormFile("file")
+		if err != nil {
+			return nil, fmt.Errorf("file is required (max %dMB)", maxImportBytes>>20)
+		}
+		file, err := fileHeader.Open()
+		if err != nil {
+			return nil, errors.New("unable to read file")
+		}
+		defer file.Close()
+		return io.ReadAll(file)
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		return nil, fmt.Errorf("import must be at most %dMB", maxImportBytes>>20)
+	}
+	return data, nil
+}
+
+// handlePrintRecipe serves a printable HTML page for a recipe; ?servings=
+// scales it first.
+func handlePrintRecipe(c *gin.Context) {
+	username, err := feedUsernameFromRequest(c)
+	if err != nil {
+		log.Printf("Print recipe auth error: %v, Header: %s", err, c.GetHeader("Authorization"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	recipe, err := requestRepo(c).GetRecipeByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error fetching recipe id=%d for print by %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render recipe"})
+		return
+	}
+	scaleRecipeFromQuery(c, &recipe)
+
+	page, err := renderRecipePrintHTML(recipe)
+	if err != nil {
+		log.Printf("Error rendering print page for recipe id=%d: %v", id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render recipe"})
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// handleRecipeSource serves the archived copy of the page a recipe was
+// extracted from. The page is third-party markup, so it is sandboxed: no
+// scripts run and it gets no access to this origin. ?download=true saves it
+// as a file instead.
+func handleRecipeSource(c *gin.Context) {
+	username, err := feedUsernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	recipe, err := requestRepo(c).GetRecipeByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error fetching recipe id=%d for source by %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load source"})
+		return
+	}
+	if recipe.SourceKey == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no archived source for this recipe"})
+		return
+	}
+
+	page, err := loadSourceHTML(recipe.SourceKey)
+	if err != nil {
+		log.Printf("Error loading source %s for recipe id=%d: %v", recipe.SourceKey, id64, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to load source"})
+		return
+	}
+
+	c.Header("Content-Security-Policy", "sandbox")
+	c.Header("X-Content-Type-Options", "nosniff")
+	if strings.EqualFold(c.Query("download"), "true") {
+		name := slugify(recipe.Title)
+		if name == "" {
+			name = "recipe"
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+"-source.html"))
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// handleRecipeFeed serves the latest saved recipes as RSS. With ?token= (the
+// feed token) items link to the printable page so they open without a login.
+func handleRecipeFeed(c *gin.Context) {
+	username, err := feedUsernameFromRequest(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultFeedItems
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(limit, maxFeedItems)
+	}
+
+	recipes, err := requestRepo(c).RecentRecipes(username, limit)
+	if err != nil {
+		log.Printf("Error listing recent recipes for feed %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build feed"})
+		return
+	}
+
+	base := requestBaseURL(c)
+	token := strings.TrimSpace(c.Query("token"))
+	selfURL := base + "/feed.xml"
+	if token != "" {
+		selfURL += "?token=" + url.QueryEscape(token)
+	}
+	linkFor := func(recipe ExportedRecipe) string {
+		if token == "" && recipe.OriginalURL != "" {
+			return recipe.OriginalURL
+		}
+		link := fmt.Sprintf("%s/recipes/id/%d/print", base, recipe.ID)
+		if token != "" {
+			link += "?token=" + url.QueryEscape(token)
+		}
+		return link
+	}
+
+	feed, err := renderRecipeFeed("Saved recipes", selfURL, recipes, linkFor)
+	if err != nil {
+		log.Printf("Error rendering feed for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build feed"})
+		return
+	}
+
+	c.Header("Cache-Control", "private, max-age=900")
+	c.Data(http.StatusOK, "application/rss+xml; charset=utf-8", feed)
+}
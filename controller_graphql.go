@@ -0,0 +1,223 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/99designs/gqlgen/graphql/handler"
+	"github.com/99designs/gqlgen/graphql/handler/extension"
+	"github.com/99designs/gqlgen/graphql/handler/transport"
+	"github.com/gin-gonic/gin"
+)
+
+type graphQLRequest struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+// graphQLServer executes queries against recipes.graphqls; the executor in
+// graphql_generated.go is generated by gqlgen (see gqlgen.yml).
+var graphQLServer = newGraphQLServer()
+
+func newGraphQLServer() *handler.Server {
+	server := handler.New(NewExecutableSchema(Config{Resolvers: &graphQLResolver{}}))
+	server.AddTransport(transport.GET{})
+	server.AddTransport(transport.POST{})
+	server.Use(extension.Introspection{})
+	server.Use(extension.FixedComplexityLimit(maxGraphQLComplexity))
+	return server
+}
+
+// handleGraphQL serves read-only queries over recipes, favorites, search and
+// the import queue, so clients can ask for just the fields they render:
+//
+//	{ recipes(category: "dinner", limit: 20) { id title image totalTime } }
+//
+// POST takes {query, operationName, variables}; GET takes the same as query
+// parameters with variables JSON-encoded. Introspection is enabled so
+// GraphQL tooling can load the schema. Recipe lists honour the saved
+// allergen preferences like /get-recipes does.
+func handleGraphQL(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"errors": []gin.H{{"message": err.Error()}}})
+		return
+	}
+	if c.Request.ContentLength > maxGraphQLQueryBytes || len(c.Request.URL.RawQuery) > maxGraphQLQueryBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"errors": []gin.H{{"message": "query is too large"}}})
+		return
+	}
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxGraphQLQueryBytes)
+
+	ctx := context.WithValue(c.Request.Context(), graphQLContextKey{}, graphQLCaller{username: username, gin: c})
+	graphQLServer.ServeHTTP(c.Writer, c.Request.WithContext(ctx))
+}
+
+type graphQLContextKey struct{}
+
+// graphQLCaller is the authenticated user of a GraphQL request, with the
+// gin context for the query-string preference filters.
+type graphQLCaller struct {
+	username string
+	gin      *gin.Context
+}
+
+func graphQLCallerFrom(ctx context.Context) graphQLCaller {
+	caller, _ := ctx.Value(graphQLContextKey{}).(graphQLCaller)
+	return caller
+}
+
+// graphQLResolver is the gqlgen resolver root.
+type graphQLResolver struct{}
+
+func (r *graphQLResolver) Query() QueryResolver {
+	return graphQLQueryResolver{}
+}
+
+type graphQLQueryResolver struct{}
+
+func (graphQLQueryResolver) Recipes(ctx context.Context, category *string, favorites *bool, tags []string, maxTotalTime *int, limit *int, offset *int) ([]Recipe, error) {
+	caller := graphQLCallerFrom(ctx)
+	filters, err := graphQLRecipeFilters(category, favorites, tags, maxTotalTime)
+	if err != nil {
+		return nil, err
+	}
+	page, err := graphQLPagination(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	recipes, err := listRecipes(recipeRepo.WithContext(ctx), caller.username, filters, false)
+	if err != nil {
+		log.Printf("GraphQL: error listing recipes for %s: %v", caller.username, err)
+		return nil, errors.New("failed to list recipes")
+	}
+	if recipes, err = applyPreferenceFilters(caller.gin, caller.username, recipes); err != nil {
+		log.Printf("GraphQL: error fetching preferences for %s: %v", caller.username, err)
+		return nil, errors.New("failed to list recipes")
+	}
+	return paginateRecipes(recipes, page), nil
+}
+
+func (graphQLQueryResolver) Recipe(ctx context.Context, id uint) (*Recipe, error) {
+	caller := graphQLCallerFrom(ctx)
+	if id == 0 {
+		return nil, errors.New("invalid id")
+	}
+	recipe, err := recipeRepo.WithContext(ctx).GetRecipeByID(caller.username, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		log.Printf("GraphQL: error fetching recipe %d for %s: %v", id, caller.username, err)
+		return nil, errors.New("failed to fetch recipe")
+	}
+	return &recipe, nil
+}
+
+func (graphQLQueryResolver) Favorites(ctx context.Context) ([]Recipe, error) {
+	caller := graphQLCallerFrom(ctx)
+	recipes, err := recipeRepo.WithContext(ctx).ListFavoriteRecipes(caller.username)
+	if err != nil {
+		log.Printf("GraphQL: error listing favorites for %s: %v", caller.username, err)
+		return nil, errors.New("failed to list favorites")
+	}
+	return recipes, nil
+}
+
+func (graphQLQueryResolver) Search(ctx context.Context, query *string, ingredients []string, category *string, favorites *bool, tags []string, maxTotalTime *int, limit *int, offset *int) ([]Recipe, error) {
+	caller := graphQLCallerFrom(ctx)
+	filters, err := graphQLRecipeFilters(category, favorites, tags, maxTotalTime)
+	if err != nil {
+		return nil, err
+	}
+	page, err := graphQLPagination(limit, offset)
+	if err != nil {
+		return nil, err
+	}
+	opts := RecipeSearchOptions{
+		Ingredients: normalizeTerms(ingredients),
+		Filters:     filters,
+	}
+	if query != nil {
+		opts.Term = *query
+	}
+	recipes, _, err := recipeRepo.WithContext(ctx).SearchRecipes(caller.username, opts)
+	if err != nil {
+		log.Printf("GraphQL: error searching recipes for %s: %v", caller.username, err)
+		return nil, errors.New("failed to search recipes")
+	}
+	if recipes, err = applyPreferenceFilters(caller.gin, caller.username, recipes); err != nil {
+		log.Printf("GraphQL: error fetching preferences for %s: %v", caller.username, err)
+		return nil, errors.New("failed to search recipes")
+	}
+	return paginateRecipes(recipes, page), nil
+}
+
+func (graphQLQueryResolver) Queue(ctx context.Context, limit *int) ([]QueueItem, error) {
+	caller := graphQLCallerFrom(ctx)
+	size := defaultQueueListSize
+	if limit != nil {
+		size = *limit
+	}
+	if size <= 0 {
+		return nil, errors.New("limit must be a positive integer")
+	}
+	items, err := recipeRepo.WithContext(ctx).ListQueueItems(caller.username, min(size, maxPageSize))
+	if err != nil {
+		log.Printf("GraphQL: error listing queue for %s: %v", caller.username, err)
+		return nil, errors.New("failed to list queue")
+	}
+	return items, nil
+}
+
+// graphQLRecipeFilters mirrors parseRecipeFilters for field arguments.
+func graphQLRecipeFilters(category *string, favorites *bool, tags []string, maxTotalTime *int) (RecipeFilters, error) {
+	var filters RecipeFilters
+
+	if category != nil && strings.TrimSpace(*category) != "" {
+		norm, ok := normalizeCategoryStrict(*category)
+		if !ok {
+			return RecipeFilters{}, fmt.Errorf("invalid category; allowed: breakfast, dinner, baking, other")
+		}
+		filters.Category = norm
+	}
+	filters.FavoritesOnly = favorites != nil && *favorites
+	filters.Tags = normalizeTerms(tags)
+
+	if maxTotalTime != nil {
+		if *maxTotalTime < 0 {
+			return RecipeFilters{}, errors.New("invalid maxTotalTime")
+		}
+		filters.MaxTotalTime = *maxTotalTime
+	}
+	return filters, nil
+}
+
+func graphQLPagination(limit, offset *int) (Pagination, error) {
+	page := Pagination{Limit: defaultPageSize}
+	if limit != nil {
+		if *limit <= 0 {
+			return Pagination{}, errors.New("limit must be a positive integer")
+		}
+		page.Limit = min(*limit, maxPageSize)
+	}
+	if offset != nil {
+		if *offset < 0 {
+			return Pagination{}, errors.New("offset must not be negative")
+		}
+		page.Offset = *offset
+	}
+	return page, nil
+}
+
+func paginateRecipes(recipes []Recipe, page Pagination) []Recipe {
+	start := min(page.Offset, len(recipes))
+	end := min(start+page.Limit, len(recipes))
+	return recipes[start:end]
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveGraphQL posts query to /graphql with the given Authorization header.
+func serveGraphQL(t *testing.T, authorization, target, query string) (int, map[string]any) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/graphql", handleGraphQL)
+
+	body, _ := json.Marshal(graphQLRequest{Query: query})
+	req := httptest.NewRequest("POST", target, strings.NewReader(string(body)))
+	req.Header.Set("Content-Type", "application/json")
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	var response map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return w.Code, response
+}
+
+func TestGraphQLRequiresBearerToken(t *testing.T) {
+	code, _ := serveGraphQL(t, "", "/graphql?username=victim@example.com", "{ queue { id url lastError } }")
+	if code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", code)
+	}
+}
+
+func TestGraphQLQueueAndIntrospection(t *testing.T) {
+	jwtSecret = "test-secret"
+	recipeRepo = newTestRepo(t)
+	createTestUser(t, recipeRepo, "cook@example.com")
+	if err := recipeRepo.EnqueueRecipe("cook@example.com", "https://example.com/pie", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	token, err := generateToken("cook@example.com", tokenTTL)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	code, response := serveGraphQL(t, "Bearer "+token, "/graphql", `{ queue(limit: 5) { url status } }`)
+	if code != http.StatusOK || response["errors"] != nil {
+		t.Fatalf("queue query: status %d, response %v", code, response)
+	}
+	queue := response["data"].(map[string]any)["queue"].([]any)
+	if len(queue) != 1 || queue[0].(map[string]any)["status"] != "pending" {
+		t.Fatalf("queue = %v, want one pending item", queue)
+	}
+
+	code, response = serveGraphQL(t, "Bearer "+token, "/graphql", `{ __type(name: "Recipe") { fields { name } } }`)
+	if code != http.StatusOK || response["errors"] != nil {
+		t.Fatalf("introspection: status %d, response %v", code, response)
+	}
+	if !strings.Contains(mustJSON(t, response), `"originalURL"`) {
+		t.Fatalf("introspection is missing Recipe fields: %v", response)
+	}
+}
+
+func mustJSON(t *testing.T, v any) string {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data)
+}
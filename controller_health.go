@@ -0,0 +1,49 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleHealth probes the database, R2 and the queue processor for uptime
+// monitoring. It answers 503 when any of them is down.
+func handleHealth(c *gin.Context) {
+	report := checkHealth(c.Request.Context(), time.Now().UTC())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
+
+// handleLivez answers as long as the process can serve requests. It checks
+// no dependencies: a restart wouldn't fix them.
+func handleLivez(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// handleReadyz answers 503 until the instance can serve traffic: see
+// readinessProbes.
+func handleReadyz(c *gin.Context) {
+	report := checkReadiness(c.Request.Context(), time.Now().UTC())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(status, report)
+}
+
+// isProbePath reports requests from uptime checks and orchestrators, which
+// aren't traced or reported.
+func isProbePath(path string) bool {
+	switch path {
+	case "/health", "/livez", "/readyz":
+		return true
+	}
+	return false
+}
@@ -1,7 +1,15 @@
 package main
 
 import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -9,6 +17,12 @@ import (
 
 var errAuthRequired = errors.New("authorization header is required")
 
+// requestRepo is the repository bound to the request's context, so queries
+// stop when the client goes away or the request times out.
+func requestRepo(c *gin.Context) *RecipeRepository {
+	return recipeRepo.WithContext(c.Request.Context())
+}
+
 func usernameFromRequest(c *gin.Context) (string, error) {
 	if username := c.Query("username"); username != "" {
 		return username, nil
@@ -26,3 +40,219 @@ func usernameFromRequest(c *gin.Context) (string, error) {
 
 	return username, nil
 }
+
+// feedUsernameFromRequest accepts a feed token (?token=) in place of a
+// bearer token, for feed readers and shared links that can't send headers.
+// Unlike usernameFromRequest it never trusts ?username=.
+func feedUsernameFromRequest(c *gin.Context) (string, error) {
+	token := strings.TrimSpace(c.Query("token"))
+	if token == "" {
+		header := c.GetHeader("Authorization")
+		if strings.TrimSpace(header) == "" {
+			return "", errAuthRequired
+		}
+		return extractUsernameFromBearer(header)
+	}
+
+	username, err := requestRepo(c).UsernameForFeedToken(token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", errors.New("invalid feed token")
+	}
+	return username, err
+}
+
+// rejectIfFrozen responds with 403 and returns true when the account has been
+// frozen for inactivity. Import and AI endpoints call this before doing work.
+func rejectIfFrozen(c *gin.Context, username string) bool {
+	return rejectIfFrozenWith(c, username, http.StatusForbidden)
+}
+
+// rejectIfFrozenWith is rejectIfFrozen answering status. A failed check lets
+// the request through: freezing is an inactivity measure rather than access
+// control, and a database hiccup shouldn't turn away every active user.
+func rejectIfFrozenWith(c *gin.Context, username string, status int) bool {
+	frozen, err := requestRepo(c).IsUserFrozen(username)
+	if err != nil {
+		log.Printf("Frozen check failed for %s: %v", username, err)
+		return false
+	}
+	if frozen {
+		c.JSON(status, gin.H{"error": "account frozen due to inactivity; sign in again to reactivate"})
+		return true
+	}
+	return false
+}
+
+// parseRecipeFilters reads the list/search filter query parameters:
+// category, favorites=true, maxTotalTime, tags=a,b, minServings, maxServings.
+func parseRecipeFilters(c *gin.Context) (RecipeFilters, error) {
+	var filters RecipeFilters
+
+	if category := strings.TrimSpace(c.Query("category")); category != "" {
+		norm, ok := normalizeCategoryStrict(category)
+		if !ok {
+			return RecipeFilters{}, fmt.Errorf("invalid category; allowed: breakfast, dinner, baking, other")
+		}
+		filters.Category = norm
+	}
+
+	filters.FavoritesOnly = strings.EqualFold(strings.TrimSpace(c.Query("favorites")), "true")
+	filters.Tags = normalizeTerms(strings.Split(c.Query("tags"), ","))
+	for _, raw := range strings.Split(c.Query("diet"), ",") {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		diet, ok := normalizeDiet(raw)
+		if !ok {
+			return RecipeFilters{}, fmt.Errorf("invalid diet; allowed: %s", strings.Join(knownDiets, ", "))
+		}
+		filters.Diets = append(filters.Diets, diet)
+	}
+
+	for _, param := range []struct {
+		name   string
+		target *int
+	}{
+		{"maxTotalTime", &filters.MaxTotalTime},
+		{"minServings", &filters.MinServings},
+		{"maxServings", &filters.MaxServings},
+	} {
+		raw := strings.TrimSpace(c.Query(param.name))
+		if raw == "" {
+			continue
+		}
+		val, err := strconv.Atoi(raw)
+		if err != nil || val < 0 {
+			return RecipeFilters{}, fmt.Errorf("invalid %s", param.name)
+		}
+		*param.target = val
+	}
+
+	if filters.MinServings > 0 && filters.MaxServings > 0 && filters.MinServings > filters.MaxServings {
+		return RecipeFilters{}, errors.New("minServings must not exceed maxServings")
+	}
+
+	return filters, nil
+}
+
+// Pagination is a limit/offset page request.
+type Pagination struct {
+	Limit  int
+	Offset int
+}
+
+// parsePagination reads ?limit= and ?offset=, defaulting to the first
+// defaultPageSize results and capping limit at maxPageSize.
+func parsePagination(c *gin.Context) (Pagination, error) {
+	page := Pagination{Limit: defaultPageSize}
+
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return Pagination{}, errors.New("limit must be a positive integer")
+		}
+		if limit > maxPageSize {
+			limit = maxPageSize
+		}
+		page.Limit = limit
+	}
+
+	if raw := strings.TrimSpace(c.Query("offset")); raw != "" {
+		offset, err := strconv.Atoi(raw)
+		if err != nil || offset < 0 {
+			return Pagination{}, errors.New("offset must be a non-negative integer")
+		}
+		page.Offset = offset
+	}
+
+	return page, nil
+}
+
+// requestBaseURL is the externally visible origin of the API, for links that
+// leave the app (feeds, calendar subscriptions). PUBLIC_BASE_URL overrides
+// what the proxy headers say.
+func requestBaseURL(c *gin.Context) string {
+	if base := appConfig.Server.PublicBaseURL; base != "" {
+		return base
+	}
+
+	scheme := "http"
+	if c.Request.TLS != nil {
+		scheme = "https"
+	}
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = strings.TrimSpace(strings.Split(proto, ",")[0])
+	}
+	host := c.Request.Host
+	if forwarded := c.GetHeader("X-Forwarded-Host"); forwarded != "" {
+		host = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+	}
+	return scheme + "://" + host
+}
+
+// requireAdmin authenticates the request and responds with 401 or 403
+// unless the user is listed in ADMIN_USERS.
+func requireAdmin(c *gin.Context) (string, bool) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return "", false
+	}
+	if !isAdminUser(username) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		return "", false
+	}
+	return username, true
+}
+
+// respondWithETag sends value as JSON with an ETag hashed from the body, or
+// 304 Not Modified when If-None-Match already names it, so clients can
+// revalidate a large list without downloading it again. The hash covers
+// everything in the response, favorites included.
+func respondWithETag(c *gin.Context, value any) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		log.Printf("Error encoding response for %s: %v", c.Request.URL.Path, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to encode response"})
+		return
+	}
+	sendWithETag(c, contentETag(body), func() { c.Data(http.StatusOK, "application/json; charset=utf-8", body) })
+}
+
+// respondWithRecipe sends the recipe scaled and converted as the query asks,
+// tagged with recipeETag: the stored recipe decides every view of it, and
+// PATCH takes the same tag in If-Match.
+func respondWithRecipe(c *gin.Context, recipe Recipe) {
+	sendWithETag(c, recipeETag(recipe), func() {
+		clone := cloneRecipe(recipe)
+		scaleRecipeFromQuery(c, &clone)
+		c.JSON(http.StatusOK, clone)
+	})
+}
+
+func sendWithETag(c *gin.Context, etag string, send func()) {
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "private, no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	send()
+}
+
+func contentETag(body []byte) string {
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+// etagMatches reports whether an If-None-Match or If-Match header lists
+// etag or is "*". Weak tags compare by their value.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+func TestFeedUsernameFromRequestIgnoresUsernameParam(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "/feed.xml?username=victim@example.com", nil)
+
+	if username, err := feedUsernameFromRequest(c); err == nil {
+		t.Fatalf("feedUsernameFromRequest = %q, want an auth error", username)
+	}
+}
+
+func TestRequestRepoStopsWithTheRequest(t *testing.T) {
+	previous := recipeRepo
+	recipeRepo = newTestRepo(t)
+	t.Cleanup(func() { recipeRepo = previous })
+
+	gin.SetMode(gin.TestMode)
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	c.Request = httptest.NewRequest("GET", "/recipes", nil).WithContext(ctx)
+
+	if _, err := requestRepo(c).ListRecipes("cook@example.com", RecipeFilters{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ListRecipes after the client left = %v, want context.Canceled", err)
+	}
+}
+
+func TestListRecipesHandlerUsesTheRequestContext(t *testing.T) {
+	previousRepo, previousCache := recipeRepo, recipesCache
+	recipeRepo = newTestRepo(t)
+	recipesCache = cache.New(time.Hour, time.Hour)
+	t.Cleanup(func() { recipeRepo, recipesCache = previousRepo, previousCache })
+
+	gin.SetMode(gin.TestMode)
+	jwtSecret = "test-secret"
+	createTestUser(t, recipeRepo, "cook@example.com")
+	token, err := generateToken("cook@example.com", tokenTTL)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	router := gin.New()
+	router.GET("/get-recipes", handleListRecipes)
+	list := func(ctx context.Context) int {
+		req := httptest.NewRequest("GET", "/get-recipes?refresh=true", nil).WithContext(ctx)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := list(context.Background()); code != http.StatusOK {
+		t.Fatalf("live request status = %d, want %d", code, http.StatusOK)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if code := list(ctx); code != http.StatusInternalServerError {
+		t.Fatalf("abandoned request status = %d, want %d", code, http.StatusInternalServerError)
+	}
+}
+
+func TestPrintRecipeRequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/recipes/id/:id/print", handlePrintRecipe)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/recipes/id/1/print?username=victim@example.com", nil))
+	if w.Code != 401 {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+}
+
+func TestRecipeSourceRequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/recipes/id/:id/source", handleRecipeSource)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/recipes/id/1/source?username=victim@example.com", nil))
+	if w.Code != 401 {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+}
+
+func TestExportRecipeRequiresAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/recipes/id/:id/export", handleExportRecipe)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/recipes/id/1/export?username=victim@example.com", nil))
+	if w.Code != 401 {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"html"
+	"log"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+var inboundURLPattern = regexp.MustCompile(`https?://[^\s<>"'()\[\]]+`)
+
+// handleMailgunInbound receives emails forwarded through a Mailgun route.
+// Links written in the message queue those pages; otherwise the email itself
+// (e.g. a newsletter with the recipe inline) is queued as the page content.
+//
+// Mailgun retries anything but 2xx and 406, so requests that can never
+// succeed (unknown sender, empty message, frozen account) answer 406.
+func handleMailgunInbound(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxInboundBytes)
+
+	token := c.PostForm("token")
+	if err := verifyMailgunSignature(c.PostForm("timestamp"), token, c.PostForm("signature")); err != nil {
+		log.Printf("Inbound mail rejected: %v", err)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+	if err := inboundTokenCache.Add(token, true, mailgunSignatureMaxAge); err != nil {
+		log.Printf("Inbound mail rejected: replayed token")
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+		return
+	}
+
+	username, err := inboundSender(requestRepo(c), c.PostForm("from"), c.PostForm("sender"))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Inbound mail from unknown sender %q / %q", c.PostForm("from"), c.PostForm("sender"))
+			c.JSON(http.StatusNotAcceptable, gin.H{"error": "unknown sender"})
+			return
+		}
+		log.Printf("Inbound mail sender lookup failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to look up sender"})
+		return
+	}
+
+	if rejectIfFrozenWith(c, username, http.StatusNotAcceptable) {
+		return
+	}
+
+	subject, bodyHTML, bodyPlain := c.PostForm("subject"), c.PostForm("body-html"), c.PostForm("body-plain")
+	note := c.PostForm("stripped-text")
+	if strings.TrimSpace(note) == "" {
+		note = bodyPlain
+	}
+	// Newsletters are full of unrelated links, so an email that carries the
+	// recipe itself is extracted as-is
+	links := inboundURLs(note)
+	if len(links) > 0 && !looksLikeRecipeText(bodyPlain+bodyHTML) {
+		for _, link := range links {
+			if err := requestRepo(c).EnqueueRecipe(username, canonicalImportURL(link), queuePriorityBulk); err != nil {
+				log.Printf("Failed to enqueue emailed link for %s: %v", username, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+				return
+			}
+		}
+		jobQueue.Wake()
+		log.Printf("Inbound mail: queued %d link(s) for %s", len(links), username)
+		c.JSON(http.StatusOK, gin.H{"message": "recipe queued for processing", "queued": len(links)})
+		return
+	}
+
+	page := inboundPageHTML(subject, bodyHTML, bodyPlain)
+	if page == "" {
+		c.JSON(http.StatusNotAcceptable, gin.H{"error": "email has no links or recipe text"})
+		return
+	}
+
+	messageID := strings.Trim(strings.TrimSpace(c.PostForm("Message-Id")), "<>")
+	if messageID == "" {
+		messageID = token
+	}
+	if err := requestRepo(c).EnqueueRecipeHTML(username, "mid:"+url.PathEscape(messageID), page, queuePriorityBulk); err != nil {
+		log.Printf("Failed to enqueue emailed recipe for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+		return
+	}
+
+	jobQueue.Wake()
+	log.Printf("Inbound mail: queued message text for %s", username)
+	c.JSON(http.StatusOK, gin.H{"message": "recipe queued for processing", "queued": 1})
+}
+
+// inboundSender matches the From header, then the envelope sender, to an
+// account. Forwarding services sometimes rewrite one but not the other.
+func inboundSender(repo *RecipeRepository, candidates ...string) (string, error) {
+	for _, candidate := range candidates {
+		address, err := mail.ParseAddress(candidate)
+		if err != nil {
+			continue
+		}
+		username, err := repo.FindUsernameByEmail(address.Address)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		return username, err
+	}
+	return "", sql.ErrNoRows
+}
+
+// inboundURLs returns the distinct http(s) links in text, in order.
+func inboundURLs(text string) []string {
+	seen := make(map[string]bool)
+	links := make([]string, 0)
+	for _, match := range inboundURLPattern.FindAllString(text, -1) {
+		link := strings.TrimRight(match, ".,;:!?")
+		if seen[link] {
+			continue
+		}
+		if parsed, err := url.Parse(link); err != nil || parsed.Host == "" {
+			continue
+		}
+		seen[link] = true
+		links = append(links, link)
+		if len(links) == maxInboundURLs {
+			break
+		}
+	}
+	return links
+}
+
+// looksLikeRecipeText reports whether an email body contains a recipe rather
+// than just pointing at one.
+func looksLikeRecipeText(body string) bool {
+	lower := strings.ToLower(body)
+	return strings.Contains(lower, "ingredient") &&
+		(strings.Contains(lower, "instruction") || strings.Contains(lower, "method") || strings.Contains(lower, "directions"))
+}
+
+// inboundPageHTML turns an email into a page for the extractor, with the
+// subject as its title. The HTML part is preferred as it keeps list structure.
+func inboundPageHTML(subject, bodyHTML, bodyPlain string) string {
+	title := "<title>" + html.EscapeString(strings.TrimSpace(subject)) + "</title>\n"
+	if strings.TrimSpace(bodyHTML) != "" {
+		return title + bodyHTML
+	}
+	if strings.TrimSpace(bodyPlain) != "" {
+		return title + "<pre>" + html.EscapeString(bodyPlain) + "</pre>"
+	}
+	return ""
+}
@@ -0,0 +1,450 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleListMealPlan returns planned meals between ?from= and ?to=
+// (YYYY-MM-DD, inclusive), defaulting to the coming week.
+func handleListMealPlan(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	from, to := today(), today().AddDate(0, 0, 6)
+	if raw := strings.TrimSpace(c.Query("from")); raw != "" {
+		if from, err = time.Parse(planDateLayout, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date (YYYY-MM-DD)"})
+			return
+		}
+		to = from.AddDate(0, 0, 6)
+	}
+	if raw := strings.TrimSpace(c.Query("to")); raw != "" {
+		if to, err = time.Parse(planDateLayout, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date (YYYY-MM-DD)"})
+			return
+		}
+	}
+	if to.Before(from) || to.Sub(from) > maxMealPlanDays*24*time.Hour {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("to must be on or after from and at most %d days later", maxMealPlanDays)})
+		return
+	}
+
+	meals, err := requestRepo(c).ListPlannedMeals(username, from, to)
+	if err != nil {
+		log.Printf("Error listing meal plan for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch meal plan"})
+		return
+	}
+
+	c.JSON(http.StatusOK, meals)
+}
+
+type addPlannedMealRequest struct {
+	RecipeID uint   `json:"recipeId" binding:"required"`
+	Date     string `json:"date" binding:"required"`
+	Meal     string `json:"meal"`
+	Note     string `json:"note"`
+}
+
+func handleAddPlannedMeal(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request addPlannedMealRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "recipeId and date are required"})
+		return
+	}
+
+	date, err := time.Parse(planDateLayout, strings.TrimSpace(request.Date))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "date must be YYYY-MM-DD"})
+		return
+	}
+	meal, ok := normalizeMealSlot(request.Meal)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "meal must be one of: " + strings.Join(mealSlots, ", ")})
+		return
+	}
+
+	planned, err := requestRepo(c).AddPlannedMeal(username, request.RecipeID, date, meal, request.Note)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error planning meal for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to plan meal"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, planned)
+}
+
+func handleDeletePlannedMeal(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := requestRepo(c).DeletePlannedMeal(username, uint(id64)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "planned meal not found"})
+			return
+		}
+		log.Printf("Error deleting planned meal %d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete planned meal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "planned meal deleted"})
+}
+
+type generateMealPlanRequest struct {
+	// Start is YYYY-MM-DD, today by default
+	Start string `json:"start"`
+	// Days defaults to 7
+	Days int `json:"days"`
+	// Meals are the slots to fill each day, dinner by default
+	Meals        []string `json:"meals"`
+	MaxTotalTime int      `json:"maxTotalTime"`
+	Diets        []string `json:"diets"`
+	// ExcludeCookedDays skips recipes cooked this recently (default 14; 0
+	// keeps them)
+	ExcludeCookedDays *int `json:"excludeCookedDays"`
+	// Exclude lists ingredients to avoid on top of the saved allergens
+	Exclude         []string `json:"exclude"`
+	IgnoreAllergens bool     `json:"ignoreAllergens"`
+	// FillWithAI suggests dishes for slots the collection can't fill
+	FillWithAI bool `json:"fillWithAI"`
+	// Save adds the chosen recipes to the meal plan
+	Save bool `json:"save"`
+}
+
+// handleGenerateMealPlan builds a plan from the user's recipes, balancing
+// categories and skipping recently cooked ones, and optionally fills the
+// gaps with AI suggestions and saves it. When streamed, the plan from the
+// collection is sent as a "plan" event before the suggestions are written.
+func handleGenerateMealPlan(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request generateMealPlanRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+			return
+		}
+	}
+
+	start := today()
+	if raw := strings.TrimSpace(request.Start); raw != "" {
+		if start, err = time.Parse(planDateLayout, raw); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start must be YYYY-MM-DD"})
+			return
+		}
+	}
+	days := request.Days
+	if days == 0 {
+		days = defaultGeneratedPlanDays
+	}
+	if days < 1 || days > maxGeneratedPlanDays {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("days must be between 1 and %d", maxGeneratedPlanDays)})
+		return
+	}
+	var meals []string
+	for _, raw := range request.Meals {
+		meal, ok := normalizeMealSlot(raw)
+		if !ok || meal == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "meals must be from: " + strings.Join(mealSlots, ", ")})
+			return
+		}
+		if !slices.Contains(meals, meal) {
+			meals = append(meals, meal)
+		}
+	}
+	if len(meals) == 0 {
+		meals = []string{"dinner"}
+	}
+	if request.MaxTotalTime < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "maxTotalTime must be a non-negative integer"})
+		return
+	}
+
+	filters := RecipeFilters{MaxTotalTime: request.MaxTotalTime}
+	for _, raw := range request.Diets {
+		diet, ok := normalizeDiet(raw)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "diets must be from: " + strings.Join(knownDiets, ", ")})
+			return
+		}
+		filters.Diets = append(filters.Diets, diet)
+	}
+	excludeCookedDays := defaultPlanExcludeCookedDays
+	if request.ExcludeCookedDays != nil {
+		if *request.ExcludeCookedDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "excludeCookedDays must be a non-negative integer"})
+			return
+		}
+		excludeCookedDays = *request.ExcludeCookedDays
+	}
+	if excludeCookedDays > 0 {
+		filters.NotCookedSince = time.Now().AddDate(0, 0, -excludeCookedDays)
+	}
+
+	avoid := parseIngredientFilter(strings.Join(request.Exclude, ","))
+	if !request.IgnoreAllergens {
+		prefs, err := requestRepo(c).GetUserPreferences(username)
+		if err != nil {
+			log.Printf("Error fetching preferences for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate meal plan"})
+			return
+		}
+		avoid = append(avoid, prefs.Allergens...)
+	}
+
+	recipes, err := requestRepo(c).RandomRecipes(username, filters, maxMealPlanCandidates)
+	if err != nil {
+		log.Printf("Error picking recipes for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate meal plan"})
+		return
+	}
+	recipes = excludeRecipesWithIngredients(recipes, avoid)
+
+	plan := buildMealPlan(recipes, start, days, meals)
+	fill := request.FillWithAI && plan.Unfilled > 0
+	if fill && rejectIfFrozen(c, username) {
+		return
+	}
+
+	var stream *eventStream
+	var onDelta func(string)
+	if wantsEventStream(c) {
+		stream = startEventStream(c)
+		defer stream.close()
+		onDelta = stream.delta
+		// The plan from the collection goes first, before any suggestions
+		stream.send("plan", plan)
+	}
+
+	if fill {
+		known := make([]string, 0, len(recipes))
+		for _, recipe := range recipes {
+			known = append(known, recipe.Title)
+		}
+		constraints := mealSuggestionConstraints{
+			MaxTotalTime: request.MaxTotalTime,
+			Diets:        filters.Diets,
+			Avoid:        avoid,
+			Known:        known,
+		}
+		if err := fillMealPlanGaps(c.Request.Context(), meterAIUsage(newAIProvider(), username, ""), &plan, constraints, onDelta); err != nil {
+			if c.Request.Context().Err() != nil {
+				log.Printf("Meal suggestions for %s abandoned: client went away", username)
+				return
+			}
+			log.Printf("Error suggesting meals for %s: %v", username, err)
+			stream.finish(c, http.StatusBadGateway, gin.H{"error": "failed to suggest meals"})
+			return
+		}
+	}
+
+	if request.Save {
+		for d := range plan.Days {
+			date, _ := time.Parse(planDateLayout, plan.Days[d].Date)
+			for m := range plan.Days[d].Meals {
+				meal := &plan.Days[d].Meals[m]
+				if meal.Recipe == nil {
+					continue
+				}
+				planned, err := requestRepo(c).AddPlannedMeal(username, meal.Recipe.ID, date, meal.Meal, "")
+				if err != nil {
+					log.Printf("Error saving generated plan for %s: %v", username, err)
+					stream.finish(c, http.StatusInternalServerError, gin.H{"error": "failed to save meal plan"})
+					return
+				}
+				meal.PlannedMealID = planned.ID
+			}
+		}
+	}
+
+	stream.finish(c, http.StatusOK, plan)
+}
+
+type feedTokenResponse struct {
+	Token       string `json:"token"`
+	CalendarURL string `json:"calendarUrl"`
+	FeedURL     string `json:"feedUrl"`
+}
+
+// handleRotateFeedToken issues the secret used by subscribable feeds. Calling
+// it again invalidates previously shared feed URLs.
+func handleRotateFeedToken(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := requestRepo(c).RotateFeedToken(username)
+	if err != nil {
+		log.Printf("Error rotating feed token for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create feed token"})
+		return
+	}
+
+	base := requestBaseURL(c)
+	c.JSON(http.StatusOK, feedTokenResponse{
+		Token:       token,
+		CalendarURL: base + "/calendar.ics?token=" + url.QueryEscape(token),
+		FeedURL:     base + "/feed.xml?token=" + url.QueryEscape(token),
+	})
+}
+
+func handleRevokeFeedToken(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := requestRepo(c).RevokeFeedToken(username); err != nil {
+		log.Printf("Error revoking feed token for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke feed token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "feed token revoked"})
+}
+
+// handleCalendarFeed serves the meal plan as iCalendar for calendar apps,
+// which can't send an Authorization header, so the feed token is in the URL.
+// ?cookAgainDays=N adds reminders for favorites last cooked over N days ago.
+func handleCalendarFeed(c *gin.Context) {
+	username, err := requestRepo(c).UsernameForFeedToken(c.Query("token"))
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			log.Printf("Calendar feed token lookup failed: %v", err)
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid feed token"})
+		return
+	}
+
+	cookAgainDays := 0
+	if raw := strings.TrimSpace(c.Query("cookAgainDays")); raw != "" {
+		cookAgainDays, err = strconv.Atoi(raw)
+		if err != nil || cookAgainDays < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cookAgainDays must be a positive integer"})
+			return
+		}
+	}
+
+	start := today()
+	meals, err := requestRepo(c).ListPlannedMeals(username, start.AddDate(0, 0, -calendarPastDays), start.AddDate(0, 0, calendarFutureDays))
+	if err != nil {
+		log.Printf("Error listing meal plan for calendar %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to build calendar"})
+		return
+	}
+
+	events := make([]calendarEvent, 0, len(meals))
+	for _, meal := range meals {
+		date, err := time.Parse(planDateLayout, meal.Date)
+		if err != nil {
+			continue
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("planned-meal-%d@cooking.bronson.dev", meal.ID),
+			Date:        date,
+			Summary:     mealEventSummary(meal),
+			Description: meal.Note,
+			URL:         meal.OriginalURL,
+		})
+	}
+
+	if cookAgainDays > 0 {
+		reminders, err := cookAgainEvents(requestRepo(c), username, cookAgainDays, start)
+		if err != nil {
+			log.Printf("Error building cook-again reminders for %s: %v", username, err)
+		}
+		events = append(events, reminders...)
+	}
+
+	c.Header("Cache-Control", "private, max-age=900")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(renderICalendar("Meal plan", events, time.Now())))
+}
+
+// cookAgainEvents suggests favorites that haven't been cooked for the given
+// number of days, on the day they become due (or today if overdue). Recipes
+// never marked cooked are left out.
+func cookAgainEvents(repo *RecipeRepository, username string, days int, start time.Time) ([]calendarEvent, error) {
+	recipes, err := repo.ListRecipes(username, RecipeFilters{
+		FavoritesOnly:  true,
+		NotCookedSince: time.Now().AddDate(0, 0, -days),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	due := make([]Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		if recipe.LastCookedAt != nil {
+			due = append(due, recipe)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].LastCookedAt.Before(*due[j].LastCookedAt)
+	})
+	if len(due) > maxCookAgainReminders {
+		due = due[:maxCookAgainReminders]
+	}
+
+	events := make([]calendarEvent, 0, len(due))
+	for _, recipe := range due {
+		date := start
+		if next := recipe.LastCookedAt.AddDate(0, 0, days); next.After(start) {
+			date = next
+		}
+		events = append(events, calendarEvent{
+			UID:         fmt.Sprintf("cook-again-%d@cooking.bronson.dev", recipe.ID),
+			Date:        date,
+			Summary:     "Cook again: " + recipe.Title,
+			Description: fmt.Sprintf("Last cooked %s", recipe.LastCookedAt.Format("2 Jan 2006")),
+			URL:         recipe.OriginalURL,
+		})
+	}
+	return events, nil
+}
+
+// today is midnight of the current day in UTC, matching how plan dates parse.
+func today() time.Time {
+	return time.Now().UTC().Truncate(24 * time.Hour)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleGetPreferences(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	prefs, err := requestRepo(c).GetUserPreferences(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("Error fetching preferences for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+func handleUpdatePreferences(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request PreferencesUpdate
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Update preferences JSON binding error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json body"})
+		return
+	}
+
+	prefs, err := requestRepo(c).SaveUserPreferences(username, request)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("Error saving preferences for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save preferences"})
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// handleGetProfileStats returns the numbers for the profile dashboard in
+// one response: recipe and favorite counts, categories, recipes added per
+// month and the import success rate.
+func handleGetProfileStats(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := requestRepo(c).ProfileStats(username, time.Now())
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("Error fetching stats for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch stats"})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
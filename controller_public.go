@@ -0,0 +1,253 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type publicHandleRequest struct {
+	Handle string `json:"handle"`
+}
+
+// handleSetPublicHandle opts in to a public cookbook with {"handle": "..."};
+// an empty handle opts out again. Recipes stay private until published.
+func handleSetPublicHandle(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request publicHandleRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid json body"})
+		return
+	}
+
+	handle, err := requestRepo(c).SetPublicHandle(username, request.Handle)
+	if err != nil {
+		switch {
+		case errors.Is(err, errInvalidHandle):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		case errors.Is(err, errHandleTaken):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		default:
+			log.Printf("Error setting public handle for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save handle"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"publicHandle": handle})
+}
+
+func handlePublishRecipe(c *gin.Context) {
+	setRecipePublic(c, true)
+}
+
+func handleUnpublishRecipe(c *gin.Context) {
+	setRecipePublic(c, false)
+}
+
+func setRecipePublic(c *gin.Context, public bool) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := requestRepo(c).SetRecipePublicByID(username, uint(id64), public); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to set recipe visibility %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update recipe"})
+		return
+	}
+
+	recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
+	invalidateUserRecipeCaches(username)
+
+	c.JSON(http.StatusOK, gin.H{"isPublic": public})
+}
+
+// handleListPublicRecipes is the unauthenticated cookbook listing for a
+// handle. Unknown handles and opted-out users both return 404.
+func handleListPublicRecipes(c *gin.Context) {
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recipes, total, err := requestRepo(c).PublicRecipes(c.Param("handle"), page)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "cookbook not found"})
+			return
+		}
+		log.Printf("Error listing public recipes for %s: %v", c.Param("handle"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recipes"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if end := int64(page.Offset + len(recipes)); end < total {
+		c.Header("X-Next-Offset", strconv.FormatInt(end, 10))
+	}
+	c.JSON(http.StatusOK, recipes)
+}
+
+func handleGetPublicRecipe(c *gin.Context) {
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	recipe, err := requestRepo(c).PublicRecipeByID(c.Param("handle"), uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error fetching public recipe %s/%d: %v", c.Param("handle"), id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch recipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recipe)
+}
+
+var publicRecipePathPattern = regexp.MustCompile(`^/public/([^/]+)/recipes/(\d+)(?:/card)?/?$`)
+
+// loadRecipeCard fetches a published recipe and builds its card links.
+func loadRecipeCard(c *gin.Context, handle string, id uint) (recipeCard, error) {
+	recipe, err := requestRepo(c).PublicRecipeByID(handle, id)
+	if err != nil {
+		return recipeCard{}, err
+	}
+
+	handle = strings.ToLower(handle)
+	base := requestBaseURL(c)
+	pageURL := fmt.Sprintf("%s/public/%s/recipes/%d/card", base, url.PathEscape(handle), id)
+	return recipeCard{
+		Recipe:    recipe,
+		Handle:    handle,
+		PageURL:   pageURL,
+		OEmbedURL: base + "/oembed?format=json&url=" + url.QueryEscape(pageURL),
+		Width:     defaultCardWidth,
+	}, nil
+}
+
+// handleRecipeCardPage is the shareable link for a published recipe.
+func handleRecipeCardPage(c *gin.Context) {
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	card, err := loadRecipeCard(c, c.Param("handle"), uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error loading recipe card %s/%d: %v", c.Param("handle"), id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render recipe"})
+		return
+	}
+
+	page, err := renderRecipeCardPage(card)
+	if err != nil {
+		log.Printf("Error rendering recipe card %s/%d: %v", card.Handle, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render recipe"})
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=3600")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", page)
+}
+
+// handleOEmbed implements the oEmbed provider endpoint for published recipe
+// URLs (/public/:handle/recipes/:id, with or without /card).
+func handleOEmbed(c *gin.Context) {
+	if format := c.DefaultQuery("format", "json"); format != "json" {
+		// Required by the spec for unsupported formats
+		c.JSON(http.StatusNotImplemented, gin.H{"error": "only json is supported"})
+		return
+	}
+
+	target, err := url.Parse(strings.TrimSpace(c.Query("url")))
+	if err != nil || target.Path == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+	match := publicRecipePathPattern.FindStringSubmatch(target.Path)
+	if match == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not a shared recipe url"})
+		return
+	}
+	id64, err := strconv.ParseUint(match[2], 10, 64)
+	if err != nil || id64 == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "not a shared recipe url"})
+		return
+	}
+
+	card, err := loadRecipeCard(c, match[1], uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error loading oEmbed recipe %s: %v", target.Path, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipe"})
+		return
+	}
+	if raw := c.Query("maxwidth"); raw != "" {
+		if maxWidth, err := strconv.Atoi(raw); err == nil && maxWidth > 0 {
+			card.Width = min(card.Width, maxWidth)
+		}
+	}
+
+	snippet, err := renderRecipeCardSnippet(card)
+	if err != nil {
+		log.Printf("Error rendering oEmbed card %s: %v", target.Path, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to render recipe"})
+		return
+	}
+
+	c.JSON(http.StatusOK, oEmbedResponse{
+		Version:      "1.0",
+		Type:         "rich",
+		Title:        card.Title,
+		ProviderName: oEmbedProviderName,
+		ProviderURL:  requestBaseURL(c),
+		AuthorName:   card.Handle,
+		ThumbnailURL: card.Image,
+		HTML:         snippet,
+		Width:        card.Width,
+		CacheAge:     3600,
+		URL:          card.PageURL,
+		PrepTime:     card.PrepTime,
+		CookTime:     card.CookTime,
+		TotalTime:    card.TotalTime,
+		Servings:     card.Servings,
+	})
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// handleQueueEvents streams server-sent events as the user's queued URLs
+// finish, so a client can refresh without polling: "recipe.processed" with
+// the new recipe's id and slug, or "recipe.failed", which names the
+// placeholder when one was saved. The queue table is polled rather than the
+// processor notifying, so events arrive whichever instance ran the import.
+// EventSource can't set headers, so it may pass ?access_token= with a token
+// from POST /queue/events/token instead; login tokens are never accepted in
+// the URL, where access logs would keep them.
+func handleQueueEvents(c *gin.Context) {
+	var username string
+	var err error
+	if token := c.Query("access_token"); c.GetHeader("Authorization") == "" && token != "" {
+		username, err = parseScopedToken(token, tokenScopeQueueEvents)
+	} else {
+		username, err = extractUsernameFromBearer(c.GetHeader("Authorization"))
+	}
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	lastID, pendingIDs, err := requestRepo(c).QueueEventCursor(username)
+	if err != nil {
+		log.Printf("Error reading queue for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch queue"})
+		return
+	}
+	pending := make(map[uint]struct{}, len(pendingIDs))
+	for _, id := range pendingIDs {
+		pending[id] = struct{}{}
+	}
+
+	stream := startEventStream(c)
+	defer stream.close()
+
+	ticker := time.NewTicker(queueEventsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+		}
+
+		watching := make([]uint, 0, len(pending))
+		for id := range pending {
+			watching = append(watching, id)
+		}
+		items, err := requestRepo(c).QueueItemsAfter(username, lastID, watching)
+		if err != nil {
+			log.Printf("Error polling queue for %s: %v", username, err)
+			continue
+		}
+		for _, item := range items {
+			lastID = max(lastID, item.ID)
+			if item.Status == "pending" {
+				pending[item.ID] = struct{}{}
+				continue
+			}
+			delete(pending, item.ID)
+			if item.Status == "cancelled" {
+				continue
+			}
+			event, data := queueItemEvent(requestRepo(c), username, item)
+			stream.send(event, data)
+		}
+	}
+}
+
+// queueItemEvent describes a finished queue item. A placeholder saved for a
+// failed import counts as a failure.
+func queueItemEvent(repo *RecipeRepository, username string, item QueueItem) (string, queueEventData) {
+	data := queueEventData{QueueItemID: item.ID, URL: item.URL, Slug: item.RecipeSlug, ErrorCode: item.ErrorCode}
+	if item.LastError != nil {
+		data.Error = *item.LastError
+	}
+	if item.RecipeSlug != "" {
+		recipe, err := repo.GetRecipe(username, item.RecipeSlug)
+		switch {
+		case err == nil:
+			data.RecipeID = recipe.ID
+			data.Title = recipe.Title
+			if data.Error == "" && !recipeIsComplete(recipe) {
+				data.Error = "recipe incomplete"
+			}
+		case !errors.Is(err, sql.ErrNoRows):
+			log.Printf("Error fetching recipe %s for %s: %v", item.RecipeSlug, username, err)
+		}
+	}
+	if data.Error != "" {
+		return webhookEventRecipeFailed, data
+	}
+	return webhookEventRecipeProcessed, data
+}
+
+// handleQueueEventsToken issues a short-lived token that only opens the
+// queue event stream, for EventSource clients to put in ?access_token=.
+func handleQueueEventsToken(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	token, err := generateScopedToken(username, tokenScopeQueueEvents, queueEventsTokenTTL)
+	if err != nil {
+		log.Printf("Error issuing queue events token for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, scopedTokenResponse{Token: token, ExpiresIn: int(queueEventsTokenTTL.Seconds())})
+}
+
+type scopedTokenResponse struct {
+	Token     string `json:"token"`
+	ExpiresIn int    `json:"expiresIn"`
+}
+
+// handleListFailedQueue lists the user's imports that failed for good, with
+// the classified reason in errorCode.
+func handleListFailedQueue(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	items, err := requestRepo(c).ListFailedQueueItems(username, page.Limit, page.Offset)
+	if err != nil {
+		log.Printf("Error listing failed queue items for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch queue"})
+		return
+	}
+	c.JSON(http.StatusOK, items)
+}
+
+// handleRetryQueueItem queues a failed import again.
+func handleRetryQueueItem(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectIfFrozen(c, username) {
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	item, err := requestRepo(c).RetryQueueItem(username, uint(id64))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "queue item not found"})
+		return
+	case errors.Is(err, errQueueItemNotFailed), errors.Is(err, errQueueItemPending):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Error retrying queue item %d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+		return
+	}
+	jobQueue.Wake()
+	c.JSON(http.StatusAccepted, item)
+}
+
+// handleCancelQueueItem cancels a pending import. An import already under
+// way is abandoned by its worker within a few seconds, without saving.
+func handleCancelQueueItem(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	item, err := requestRepo(c).CancelQueueItem(username, uint(id64))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		c.JSON(http.StatusNotFound, gin.H{"error": "queue item not found"})
+		return
+	case errors.Is(err, errQueueItemFinished):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Error cancelling queue item %d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to cancel queue item"})
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const recipeChatSystemPrompt = `You are a helpful cooking assistant answering questions about one recipe, shown below in Markdown.
+Base your answers on this recipe: its ingredients, quantities, steps and times. When a question needs general cooking knowledge (substitutions, storage, making ahead, equipment), answer in terms of this recipe and say what would change.
+If the question has nothing to do with the recipe or cooking, say so briefly. Keep answers short and practical, in plain text.
+
+`
+
+type askRecipeRequest struct {
+	Question string `json:"question" binding:"required"`
+}
+
+type recipeChatResponse struct {
+	Answer  string              `json:"answer,omitempty"`
+	History []RecipeChatMessage `json:"history"`
+}
+
+// recipeChatID reads the :id of a /recipes/id/:id/ask request, responding
+// 400 and returning false when it isn't a valid id.
+func recipeChatID(c *gin.Context) (uint, bool) {
+	id64, err := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if err != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return 0, false
+	}
+	return uint(id64), true
+}
+
+// handleAskRecipe answers a question about one of the user's recipes, with
+// the earlier questions and answers about it as context. The answer can be
+// streamed (see wantsEventStream); it's saved once complete.
+func handleAskRecipe(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if rejectIfFrozen(c, username) {
+		return
+	}
+	id, ok := recipeChatID(c)
+	if !ok {
+		return
+	}
+
+	var request askRecipeRequest
+	if err := c.ShouldBindJSON(&request); err != nil || strings.TrimSpace(request.Question) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "question is required"})
+		return
+	}
+	question := strings.TrimSpace(request.Question)
+	if len(question) > maxRecipeQuestionChars {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "question is too long"})
+		return
+	}
+
+	recipe, err := requestRepo(c).GetRecipeByID(username, id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to load recipe %s id=%d: %v", username, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipe"})
+		return
+	}
+
+	history, err := requestRepo(c).GetRecipeChat(username, id, recipeChatContextMessages)
+	if err != nil {
+		log.Printf("Failed to load recipe chat %s id=%d: %v", username, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load conversation"})
+		return
+	}
+	messages := make([]chatMessage, 0, len(history)+1)
+	for _, message := range history {
+		messages = append(messages, chatMessage{Role: message.Role, Content: message.Content})
+	}
+	messages = append(messages, chatMessage{Role: "user", Content: question})
+
+	// Once streaming, the answer and any failure are sent as events
+	var stream *eventStream
+	var onDelta func(string)
+	if wantsEventStream(c) {
+		stream = startEventStream(c)
+		defer stream.close()
+		onDelta = stream.delta
+	}
+
+	ai := meterAIUsage(newAIProvider(), username, recipe.OriginalURL)
+	reply, err := ai.ChatStream(c.Request.Context(), recipeChatSystemPrompt+renderRecipeMarkdown(recipe), messages, recipeChatMaxTokens, onDelta)
+	if err != nil {
+		if c.Request.Context().Err() != nil {
+			log.Printf("Recipe question for %s id=%d abandoned: client went away", username, id)
+			return
+		}
+		log.Printf("Recipe question failed for %s id=%d: %v", username, id, err)
+		stream.finish(c, http.StatusBadGateway, gin.H{"error": "failed to answer the question"})
+		return
+	}
+	answer := strings.TrimSpace(reply.Content)
+
+	if err := requestRepo(c).AppendRecipeChat(username, id, question, answer); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			stream.finish(c, http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to save recipe chat %s id=%d: %v", username, id, err)
+		stream.finish(c, http.StatusInternalServerError, gin.H{"error": "failed to save conversation"})
+		return
+	}
+
+	history, err = requestRepo(c).GetRecipeChat(username, id, maxRecipeChatHistory)
+	if err != nil {
+		log.Printf("Failed to load recipe chat %s id=%d: %v", username, id, err)
+		history = nil
+	}
+	stream.finish(c, http.StatusOK, recipeChatResponse{Answer: answer, History: history})
+}
+
+// handleGetRecipeChat returns the conversation about a recipe, oldest first.
+func handleGetRecipeChat(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := recipeChatID(c)
+	if !ok {
+		return
+	}
+
+	if _, err := requestRepo(c).GetRecipeByID(username, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to load recipe %s id=%d: %v", username, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipe"})
+		return
+	}
+
+	history, err := requestRepo(c).GetRecipeChat(username, id, maxRecipeChatHistory)
+	if err != nil {
+		log.Printf("Failed to load recipe chat %s id=%d: %v", username, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load conversation"})
+		return
+	}
+	c.JSON(http.StatusOK, recipeChatResponse{History: history})
+}
+
+func handleClearRecipeChat(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	id, ok := recipeChatID(c)
+	if !ok {
+		return
+	}
+
+	if err := requestRepo(c).ClearRecipeChat(username, id); err != nil {
+		log.Printf("Failed to clear recipe chat %s id=%d: %v", username, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to clear conversation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "conversation cleared"})
+}
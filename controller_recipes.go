@@ -3,9 +3,13 @@ package main
 import (
 	"database/sql"
 	"errors"
+	"fmt"
+	"io"
 	"log"
 	"math"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -13,6 +17,39 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+type saveRecipeRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Model is an admin-only "provider:model" override for this import
+	Model string `json:"model,omitempty"`
+}
+
+// checkAIModelOverride validates a request's model override, answering the
+// request and returning false when it can't be used.
+func checkAIModelOverride(c *gin.Context, username, model string) bool {
+	if strings.TrimSpace(model) == "" {
+		return true
+	}
+	if !isAdminUser(username) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins can choose the model"})
+		return false
+	}
+	if _, err := newAIProviderFor(model); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("model: %v", err)})
+		return false
+	}
+	return true
+}
+
+// queueAIModelOverride records an admin's model override on the queue item
+// just enqueued.
+func queueAIModelOverride(repo *RecipeRepository, username, recipeURL, model string) error {
+	model = strings.TrimSpace(model)
+	if model == "" {
+		return nil
+	}
+	return repo.SetPendingQueueAIModel(username, recipeURL, model)
+}
+
 func handleSaveRecipe(c *gin.Context) {
 	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
 	if err != nil {
@@ -21,9 +58,7 @@ func handleSaveRecipe(c *gin.Context) {
 		return
 	}
 
-	var request struct {
-		URL string `json:"url" binding:"required"`
-	}
+	var request saveRecipeRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Printf("Save recipe JSON binding error: %v", err)
@@ -31,22 +66,134 @@ func handleSaveRecipe(c *gin.Context) {
 		return
 	}
 
-	if linked, slug, err := recipeRepo.LinkRecipeIfExists(username, request.URL); err != nil {
-		log.Printf("Failed to link existing recipe for %s: %v", username, err)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save recipe"})
-		return
-	} else if linked {
-		recipeCache.Delete(singleRecipeCacheKey(username, slug))
-		invalidateUserRecipeCaches(username)
-		c.JSON(http.StatusAccepted, gin.H{"message": "recipe saved successfully"})
+	if rejectIfFrozen(c, username) || !checkAIModelOverride(c, username, request.Model) {
 		return
 	}
 
-	if err := recipeRepo.EnqueueRecipe(username, request.URL); err != nil {
+	// YouTube links are imported from the video's description and captions
+	request.URL = canonicalImportURL(request.URL)
+	// With a model override the recipe is extracted again rather than linked
+	if strings.TrimSpace(request.Model) == "" {
+		if linked, slug, err := requestRepo(c).LinkRecipeIfExists(username, request.URL); err != nil {
+			log.Printf("Failed to link existing recipe for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to save recipe"})
+			return
+		} else if linked {
+			recipesSavedTotal.WithLabelValues(recipeSourceLinked).Inc()
+			recipeCache.Delete(singleRecipeCacheKey(username, slug))
+			invalidateUserRecipeCaches(username)
+			c.JSON(http.StatusAccepted, gin.H{"message": "recipe saved successfully"})
+			return
+		}
+	}
+
+	if err := requestRepo(c).EnqueueRecipe(username, request.URL, queuePriorityInteractive); err != nil {
 		log.Printf("Failed to enqueue recipe for %s: %v", username, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
 		return
 	}
+	if err := queueAIModelOverride(requestRepo(c), username, request.URL, request.Model); err != nil {
+		log.Printf("Failed to set model override for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+		return
+	}
+	jobQueue.Wake()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "recipe queued for processing"})
+}
+
+type saveRecipeHTMLRequest struct {
+	URL  string `json:"url" binding:"required"`
+	HTML string `json:"html" binding:"required"`
+	// Model is an admin-only "provider:model" override for this import
+	Model string `json:"model,omitempty"`
+}
+
+// handleSaveRecipeHTML accepts a page already rendered in the user's browser
+// (the browser extension), for sites that block or confuse the scraper.
+func handleSaveRecipeHTML(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxPageHTMLBytes)
+	var request saveRecipeHTMLRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		log.Printf("Save recipe HTML binding error: %v", err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url and html are required"})
+		return
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(request.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http(s) URL"})
+		return
+	}
+
+	if rejectIfFrozen(c, username) || !checkAIModelOverride(c, username, request.Model) {
+		return
+	}
+
+	if err := requestRepo(c).EnqueueRecipeHTML(username, parsed.String(), request.HTML, queuePriorityInteractive); err != nil {
+		log.Printf("Failed to enqueue recipe HTML for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+		return
+	}
+	if err := queueAIModelOverride(requestRepo(c), username, parsed.String(), request.Model); err != nil {
+		log.Printf("Failed to set model override for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+		return
+	}
+	jobQueue.Wake()
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "recipe queued for processing"})
+}
+
+// handleSaveRecipePDF queues an uploaded PDF, sent as a multipart "file" or
+// as the raw body. Its text is extracted now so a PDF without any is
+// rejected up front; the queue then treats it like emailed recipe text.
+func handleSaveRecipePDF(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	if rejectIfFrozen(c, username) {
+		return
+	}
+
+	data, err := readImportUpload(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !isPDF(data) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is not a PDF"})
+		return
+	}
+
+	name := "recipe.pdf"
+	if fileHeader, err := c.FormFile("file"); err == nil && fileHeader.Filename != "" {
+		name = filepath.Base(fileHeader.Filename)
+	}
+
+	text, err := pdfToText(data)
+	if err != nil {
+		log.Printf("PDF upload from %s: %v", username, err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	}
+
+	title := strings.TrimSuffix(name, filepath.Ext(name))
+	if err := requestRepo(c).EnqueueRecipeHTML(username, "pdf:"+url.PathEscape(name), pdfPageHTML(title, text), queuePriorityInteractive); err != nil {
+		log.Printf("Failed to enqueue recipe PDF for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue recipe"})
+		return
+	}
+	jobQueue.Wake()
 
 	c.JSON(http.StatusAccepted, gin.H{"message": "recipe queued for processing"})
 }
@@ -64,7 +211,7 @@ func handleFavoriteRecipe(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 			return
 		}
-		if err := recipeRepo.SetFavoriteByID(username, uint(id64), true); err != nil {
+		if err := requestStore(c).SetFavoriteByID(username, uint(id64), true); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
 				return
@@ -73,13 +220,14 @@ func handleFavoriteRecipe(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to favorite recipe"})
 			return
 		}
+		recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
 		invalidateUserRecipeCaches(username)
 		c.JSON(http.StatusOK, gin.H{"message": "recipe favorited"})
 		return
 	}
 
 	slug := c.Param("slug")
-	if err := recipeRepo.SetFavorite(username, slug, true); err != nil {
+	if err := requestStore(c).SetFavorite(username, slug, true); err != nil {
 		log.Printf("Failed to favorite recipe %s/%s: %v", username, slug, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to favorite recipe"})
 		return
@@ -104,7 +252,7 @@ func handleUnfavoriteRecipe(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 			return
 		}
-		if err := recipeRepo.SetFavoriteByID(username, uint(id64), false); err != nil {
+		if err := requestStore(c).SetFavoriteByID(username, uint(id64), false); err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
 				return
@@ -113,13 +261,14 @@ func handleUnfavoriteRecipe(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unfavorite recipe"})
 			return
 		}
+		recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
 		invalidateUserRecipeCaches(username)
 		c.JSON(http.StatusOK, gin.H{"message": "recipe unfavorited"})
 		return
 	}
 
 	slug := c.Param("slug")
-	if err := recipeRepo.SetFavorite(username, slug, false); err != nil {
+	if err := requestStore(c).SetFavorite(username, slug, false); err != nil {
 		log.Printf("Failed to unfavorite recipe %s/%s: %v", username, slug, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unfavorite recipe"})
 		return
@@ -151,16 +300,16 @@ func handleGetRecipe(c *gin.Context) {
 		if cachedRecipe, found := recipeCache.Get(cacheKey); found {
 			if recipe, ok := cachedRecipe.(Recipe); ok {
 				log.Printf("Cache hit for %s", cacheKey)
-				clone := cloneRecipe(recipe)
-				scaleRecipeFromQuery(c, &clone)
-				c.JSON(http.StatusOK, clone)
+				observeCacheLookup(cacheRecipe, true)
+				respondWithRecipe(c, recipe)
 				return
 			}
 			log.Printf("Invalid cache entry for %s, evicting", cacheKey)
 			recipeCache.Delete(cacheKey)
 		}
+		observeCacheLookup(cacheRecipe, false)
 
-		recipe, err := recipeRepo.GetRecipeByID(username, uint(id64))
+		recipe, err := requestStore(c).GetRecipeByID(username, uint(id64))
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				log.Printf("Recipe not found for id=%d, user=%s", id64, username)
@@ -173,9 +322,7 @@ func handleGetRecipe(c *gin.Context) {
 		}
 
 		recipeCache.Set(cacheKey, recipe, 30*time.Minute)
-		clone := cloneRecipe(recipe)
-		scaleRecipeFromQuery(c, &clone)
-		c.JSON(http.StatusOK, clone)
+		respondWithRecipe(c, recipe)
 		return
 	}
 
@@ -191,16 +338,16 @@ func handleGetRecipe(c *gin.Context) {
 	if cachedRecipe, found := recipeCache.Get(cacheKey); found {
 		if recipe, ok := cachedRecipe.(Recipe); ok {
 			log.Printf("Cache hit for %s", cacheKey)
-			clone := cloneRecipe(recipe)
-			scaleRecipeFromQuery(c, &clone)
-			c.JSON(http.StatusOK, clone)
+			observeCacheLookup(cacheRecipe, true)
+			respondWithRecipe(c, recipe)
 			return
 		}
 		log.Printf("Invalid cache entry for %s, evicting", cacheKey)
 		recipeCache.Delete(cacheKey)
 	}
+	observeCacheLookup(cacheRecipe, false)
 
-	recipe, err := recipeRepo.GetRecipe(username, slug)
+	recipe, err := requestStore(c).GetRecipe(username, slug)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			log.Printf("Recipe not found for slug=%s, user=%s", slug, username)
@@ -213,9 +360,7 @@ func handleGetRecipe(c *gin.Context) {
 	}
 
 	recipeCache.Set(cacheKey, recipe, 30*time.Minute)
-	clone := cloneRecipe(recipe)
-	scaleRecipeFromQuery(c, &clone)
-	c.JSON(http.StatusOK, clone)
+	respondWithRecipe(c, recipe)
 }
 
 func handleDeleteRecipe(c *gin.Context) {
@@ -233,19 +378,21 @@ func handleDeleteRecipe(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 			return
 		}
-		if err := recipeRepo.DeleteRecipeByID(username, uint(id64)); err != nil {
+		if err := requestStore(c).DeleteRecipeByID(username, uint(id64)); err != nil {
 			log.Printf("Error deleting recipe id=%d for %s: %v", id64, username, err)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete recipe"})
 			return
 		}
+		recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
 		invalidateUserRecipeCaches(username)
+		fireWebhookEvent(username, webhookEventRecipeDeleted, gin.H{"recipeId": id64})
 		c.JSON(http.StatusOK, gin.H{"message": "recipe removed"})
 		return
 	}
 
 	slug := c.Param("slug")
 
-	if err := recipeRepo.DeleteRecipe(username, slug); err != nil {
+	if err := requestStore(c).DeleteRecipe(username, slug); err != nil {
 		log.Printf("Error deleting recipe %s for %s: %v", slug, username, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete recipe"})
 		return
@@ -253,10 +400,20 @@ func handleDeleteRecipe(c *gin.Context) {
 
 	recipeCache.Delete(singleRecipeCacheKey(username, slug))
 	invalidateUserRecipeCaches(username)
+	fireWebhookEvent(username, webhookEventRecipeDeleted, gin.H{"slug": slug})
 
 	c.JSON(http.StatusOK, gin.H{"message": "recipe removed"})
 }
 
+// patchRecipeRequest fields are optional; only those present are changed.
+type patchRecipeRequest struct {
+	Title        *string   `json:"title"`
+	Instructions *[]string `json:"instructions"`
+	Ingredients  *[]string `json:"ingredients"`
+	Category     *string   `json:"category"`
+	Tags         *[]string `json:"tags"`
+}
+
 func handlePatchRecipe(c *gin.Context) {
 	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
 	if err != nil {
@@ -268,11 +425,7 @@ func handlePatchRecipe(c *gin.Context) {
 	slug := c.Param("slug")
 	idStr := strings.TrimSpace(c.Param("id"))
 
-	var request struct {
-		Title        *string   `json:"title"`
-		Instructions *[]string `json:"instructions"`
-		Category     *string   `json:"category"`
-	}
+	var request patchRecipeRequest
 
 	if err := c.ShouldBindJSON(&request); err != nil {
 		log.Printf("Patch recipe JSON binding error: %v", err)
@@ -280,19 +433,28 @@ func handlePatchRecipe(c *gin.Context) {
 		return
 	}
 
-	if request.Title == nil && request.Instructions == nil && request.Category == nil {
+	if request.Title == nil && request.Instructions == nil && request.Ingredients == nil && request.Category == nil && request.Tags == nil {
 		log.Printf("Patch recipe no fields error for user=%s", username)
 		c.JSON(http.StatusBadRequest, gin.H{"error": "no fields to update"})
 		return
 	}
 
+	patch := RecipePatch{
+		Title:        request.Title,
+		Instructions: request.Instructions,
+		Ingredients:  request.Ingredients,
+		Category:     request.Category,
+		Tags:         request.Tags,
+		IfMatch:      strings.TrimSpace(c.GetHeader("If-Match")),
+	}
+
 	if idStr != "" {
 		id64, convErr := strconv.ParseUint(idStr, 10, 64)
 		if convErr != nil || id64 == 0 {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
 			return
 		}
-		updated, err := recipeRepo.UpdateRecipeTitleAndInstructionsByID(username, uint(id64), request.Title, request.Instructions, request.Category)
+		updated, err := requestStore(c).UpdateRecipeTitleAndInstructionsByID(username, uint(id64), patch)
 		if err != nil {
 			if errors.Is(err, sql.ErrNoRows) {
 				c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
@@ -302,15 +464,23 @@ func handlePatchRecipe(c *gin.Context) {
 				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category; allowed: breakfast, dinner, baking, other"})
 				return
 			}
+			if errors.Is(err, ErrRecipeChanged) {
+				// A cached copy may be what the client read; let it reload
+				recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
+				c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				return
+			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update recipe"})
 			return
 		}
+		recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
 		invalidateUserRecipeCaches(username)
+		c.Header("ETag", recipeETag(updated))
 		c.JSON(http.StatusOK, updated)
 		return
 	}
 
-	updated, err := recipeRepo.UpdateRecipeTitleAndInstructions(username, slug, request.Title, request.Instructions, request.Category)
+	updated, err := requestStore(c).UpdateRecipeTitleAndInstructions(username, slug, patch)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
@@ -320,6 +490,11 @@ func handlePatchRecipe(c *gin.Context) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid category; allowed: breakfast, dinner, baking, other"})
 			return
 		}
+		if errors.Is(err, ErrRecipeChanged) {
+			recipeCache.Delete(singleRecipeCacheKey(username, slug))
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update recipe"})
 		return
 	}
@@ -328,6 +503,106 @@ func handlePatchRecipe(c *gin.Context) {
 	recipeCache.Delete(singleRecipeCacheKey(username, slug))
 	invalidateUserRecipeCaches(username)
 
+	c.Header("ETag", recipeETag(updated))
+	c.JSON(http.StatusOK, updated)
+}
+
+func handleUploadRecipeImage(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		log.Printf("Upload image auth error: %v, Header: %s", err, c.GetHeader("Authorization"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	userID, err := requestRepo(c).getUserID(username)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("Upload image user lookup failed for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+
+	if _, err := requestRepo(c).GetRecipeByID(username, uint(id64)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Upload image recipe lookup failed id=%d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to upload image"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxRecipeImageBytes+(1<<20))
+	fileHeader, err := c.FormFile("image")
+	if err != nil {
+		log.Printf("Upload image form error for %s: %v", username, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "image file is required (max 10MB)"})
+		return
+	}
+	if fileHeader.Size > maxRecipeImageBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image must be 10MB or smaller"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		log.Printf("Upload image open error for %s: %v", username, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unable to read image"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxRecipeImageBytes+1))
+	if err != nil {
+		log.Printf("Upload image read error for %s: %v", username, err)
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unable to read image"})
+		return
+	}
+	if len(data) > maxRecipeImageBytes {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "image must be 10MB or smaller"})
+		return
+	}
+
+	// Trust the bytes, not the client-supplied Content-Type
+	contentType := http.DetectContentType(data)
+	ext := extensionForContentType(contentType)
+	if ext == "" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "image must be jpeg, png, webp, or gif"})
+		return
+	}
+
+	key := fmt.Sprintf("images/users/%d/recipe-%d-%d%s", userID, id64, time.Now().Unix(), ext)
+	imageURL, err := uploadImageToStorage(key, contentType, data)
+	if err != nil {
+		log.Printf("Upload image storage error id=%d for %s: %v", id64, username, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to store image"})
+		return
+	}
+
+	updated, err := requestRepo(c).UpdateRecipeImageByID(username, uint(id64), imageURL)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Upload image update error id=%d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update recipe image"})
+		return
+	}
+
+	recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
+	invalidateUserRecipeCaches(username)
+
 	c.JSON(http.StatusOK, updated)
 }
 
@@ -339,16 +614,58 @@ func handleListRecipes(c *gin.Context) {
 		return
 	}
 
-	category := c.Query("category")
+	filters, err := parseRecipeFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	refresh := strings.EqualFold(strings.TrimSpace(c.Query("refresh")), "true")
-	recipes, err := listRecipes(username, category, refresh)
+	recipes, err := listRecipes(requestStore(c), username, filters, refresh)
 	if err != nil {
 		log.Printf("Error listing recipes for %s: %v", username, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recipes"})
 		return
 	}
 
-	c.JSON(http.StatusOK, recipes)
+	recipes, err = applyPreferenceFilters(c, username, recipes)
+	if err != nil {
+		log.Printf("Error fetching preferences for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recipes"})
+		return
+	}
+
+	respondWithETag(c, recipes)
+}
+
+// applyPreferenceFilters applies the per-user filters shared by list and search:
+// equipment=owned, exclude=peanut,shellfish, and the saved allergen list
+// (skipped with ignoreAllergens=true).
+func applyPreferenceFilters(c *gin.Context, username string, recipes []Recipe) ([]Recipe, error) {
+	exclude := parseIngredientFilter(c.Query("exclude"))
+	ownedOnly := strings.EqualFold(strings.TrimSpace(c.Query("equipment")), "owned")
+	useAllergens := !strings.EqualFold(strings.TrimSpace(c.Query("ignoreAllergens")), "true")
+	return filterRecipesByPreferences(requestStore(c), username, recipes, exclude, ownedOnly, useAllergens)
+}
+
+// filterRecipesByPreferences drops recipes with an excluded ingredient and,
+// as asked, those needing equipment the user doesn't own or containing
+// their saved allergens.
+func filterRecipesByPreferences(repo RecipeStore, username string, recipes []Recipe, exclude []string, ownedOnly, useAllergens bool) ([]Recipe, error) {
+	if ownedOnly || useAllergens {
+		prefs, err := repo.GetUserPreferences(username)
+		if err != nil {
+			return nil, err
+		}
+		if ownedOnly {
+			recipes = filterRecipesByEquipment(recipes, prefs.Equipment)
+		}
+		if useAllergens {
+			exclude = append(exclude, prefs.Allergens...)
+		}
+	}
+
+	return excludeRecipesWithIngredients(recipes, exclude), nil
 }
 
 func handleSearchRecipes(c *gin.Context) {
@@ -359,17 +676,338 @@ func handleSearchRecipes(c *gin.Context) {
 		return
 	}
 
-	searchTerm := c.Query("q")
-	recipes, err := recipeRepo.SearchRecipes(username, searchTerm)
+	filters, err := parseRecipeFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	opts := RecipeSearchOptions{
+		Term:        c.Query("q"),
+		Ingredients: parseIngredientFilter(c.Query("ingredient")),
+		Filters:     filters,
+		Page:        page,
+	}
+	recipes, total, err := requestStore(c).SearchRecipes(username, opts)
 	if err != nil {
 		log.Printf("Error searching recipes for %s: %v", username, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search recipes"})
 		return
 	}
 
+	// The preference filters (exclusions, allergens, owned equipment) run
+	// here rather than in SQL, after the page is cut, so they can leave a
+	// page short. X-Total-Count and X-Next-Offset count the matches before
+	// them; keep paging until there's no next offset.
+	recipes, err = applyPreferenceFilters(c, username, recipes)
+	if err != nil {
+		log.Printf("Error fetching preferences for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to search recipes"})
+		return
+	}
+
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+	if end := int64(page.Offset + page.Limit); end < total {
+		c.Header("X-Next-Offset", strconv.FormatInt(end, 10))
+	}
 	c.JSON(http.StatusOK, recipes)
 }
 
+func handleSimilarRecipes(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		log.Printf("Similar recipes auth error: %v, Header: %s", err, c.GetHeader("Authorization"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	limit := defaultSimilarLimit
+	if raw := strings.TrimSpace(c.Query("limit")); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = min(parsed, maxSimilarLimit)
+	}
+
+	recipes, err := requestRepo(c).SimilarRecipes(username, uint(id64), limit)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Error finding recipes similar to %d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to find similar recipes"})
+		return
+	}
+
+	c.JSON(http.StatusOK, recipes)
+}
+
+// handleRandomRecipe suggests one recipe to cook. Accepts the list filters plus
+// excludeCookedDays=N to skip anything cooked in the last N days.
+func handleRandomRecipe(c *gin.Context) {
+	username, err := usernameFromRequest(c)
+	if err != nil {
+		log.Printf("Random recipe auth error: %v, Header: %s", err, c.GetHeader("Authorization"))
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseRecipeFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if raw := strings.TrimSpace(c.Query("excludeCookedDays")); raw != "" {
+		days, err := strconv.Atoi(raw)
+		if err != nil || days < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "excludeCookedDays must be a non-negative integer"})
+			return
+		}
+		if days > 0 {
+			filters.NotCookedSince = time.Now().AddDate(0, 0, -days)
+		}
+	}
+
+	// The preference filters run in Go, so a sample can lose every
+	// candidate; draw a larger one until a recipe survives or the sample
+	// comes back short, which means every match has been tried.
+	for limit := randomRecipeCandidates; ; limit *= randomRecipeGrowth {
+		sample, err := requestStore(c).RandomRecipes(username, filters, limit)
+		if err != nil {
+			log.Printf("Error picking random recipe for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to pick a recipe"})
+			return
+		}
+
+		recipes, err := applyPreferenceFilters(c, username, sample)
+		if err != nil {
+			log.Printf("Error fetching preferences for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to pick a recipe"})
+			return
+		}
+		if len(recipes) > 0 {
+			c.JSON(http.StatusOK, recipes[0])
+			return
+		}
+		if len(sample) < limit {
+			c.JSON(http.StatusNotFound, gin.H{"error": "no recipes match these filters"})
+			return
+		}
+	}
+}
+
+func handleMarkRecipeCooked(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	updated, err := requestStore(c).MarkRecipeCookedByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to mark recipe cooked %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to mark recipe cooked"})
+		return
+	}
+
+	recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
+	invalidateUserRecipeCaches(username)
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// handleRecalculateNutrition computes the recipe's nutrition from its parsed
+// ingredients with USDA FoodData Central and stores it.
+func handleRecalculateNutrition(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	client, err := newFDCClient()
+	if err != nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "nutrition lookup is not configured"})
+		return
+	}
+
+	recipe, err := requestRepo(c).GetRecipeByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to load recipe %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipe"})
+		return
+	}
+	if len(recipe.ParsedIngredients) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "recipe has no parsed ingredients"})
+		return
+	}
+
+	nutrition, err := computeUSDANutrition(c.Request.Context(), client, recipe)
+	if err != nil {
+		log.Printf("Failed to compute nutrition for %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "nutrition lookup failed"})
+		return
+	}
+
+	updated, err := requestRepo(c).SetRecipeUSDANutrition(username, uint(id64), *nutrition)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to store nutrition for %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store nutrition"})
+		return
+	}
+
+	recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
+	invalidateUserRecipeCaches(username)
+
+	scaleRecipeFromQuery(c, &updated)
+	c.JSON(http.StatusOK, updated)
+}
+
+// handleParseIngredients parses a recipe's raw ingredient lines into
+// structured ingredients so it can be scaled, by rule or with ?ai=true.
+func handleParseIngredients(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+	useAI := strings.EqualFold(strings.TrimSpace(c.Query("ai")), "true")
+	if useAI && rejectIfFrozen(c, username) {
+		return
+	}
+
+	recipe, err := requestRepo(c).GetRecipeByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to load recipe %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipe"})
+		return
+	}
+	if len(recipe.Ingredients) == 0 {
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "recipe has no ingredients"})
+		return
+	}
+
+	var parsed []IngredientDetail
+	if useAI {
+		ai := meterAIUsage(newAIProvider(), username, recipe.OriginalURL)
+		if parsed, err = parseIngredientsWithAI(c.Request.Context(), ai, recipe.Ingredients); err != nil {
+			log.Printf("Failed to parse ingredients for %s id=%d: %v", username, id64, err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "failed to parse ingredients"})
+			return
+		}
+	} else {
+		parsed = parseIngredientLines(recipe.Ingredients)
+	}
+
+	updated, err := requestRepo(c).SetRecipeParsedIngredients(username, uint(id64), parsed)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+			return
+		}
+		log.Printf("Failed to store parsed ingredients for %s id=%d: %v", username, id64, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store parsed ingredients"})
+		return
+	}
+
+	recipeCache.Delete(singleRecipeIDCacheKey(username, uint(id64)))
+	invalidateUserRecipeCaches(username)
+
+	scaleRecipeFromQuery(c, &updated)
+	c.JSON(http.StatusOK, updated)
+}
+
+// handleAdminParseIngredients parses the ingredients of every recipe that
+// only has raw lines, by rule.
+func handleAdminParseIngredients(c *gin.Context) {
+	admin, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	updated, err := requestRepo(c).BackfillParsedIngredients()
+	if err != nil {
+		log.Printf("Ingredient backfill by %s failed after %d recipes: %v", admin, updated, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to parse ingredients", "updated": updated})
+		return
+	}
+	log.Printf("Ingredient backfill by %s parsed %d recipes", admin, updated)
+
+	if updated > 0 {
+		recipeCache.Flush()
+		recipesCache.Flush()
+	}
+	c.JSON(http.StatusOK, gin.H{"updated": updated})
+}
+
+// handleAdminReprocessIncomplete runs a pass of the incomplete-recipe job
+// now; ?force=true queues recipes still in their backoff too.
+func handleAdminReprocessIncomplete(c *gin.Context) {
+	admin, ok := requireAdmin(c)
+	if !ok {
+		return
+	}
+
+	force := c.Query("force") == "true"
+	queued, err := reprocessIncompleteRecipes(recipeRepo, force)
+	if err != nil {
+		log.Printf("Reprocess by %s failed after %d recipes: %v", admin, queued, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to queue incomplete recipes", "queued": queued})
+		return
+	}
+	log.Printf("Reprocess by %s queued %d incomplete recipes", admin, queued)
+	c.JSON(http.StatusOK, gin.H{"queued": queued})
+}
+
 func handleGetCategories(c *gin.Context) {
 	username, err := usernameFromRequest(c)
 	if err != nil {
@@ -378,7 +1016,19 @@ func handleGetCategories(c *gin.Context) {
 		return
 	}
 
-	categories, err := recipeRepo.CategoryCounts(username)
+	// ?details=true adds favorites, average time and the newest recipe
+	if strings.EqualFold(strings.TrimSpace(c.Query("details")), "true") {
+		details, err := requestStore(c).CategoryDetails(username)
+		if err != nil {
+			log.Printf("Error fetching category details for %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
+			return
+		}
+		c.JSON(http.StatusOK, details)
+		return
+	}
+
+	categories, err := requestStore(c).CategoryCounts(username)
 	if err != nil {
 		log.Printf("Error fetching categories for %s: %v", username, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch categories"})
@@ -396,7 +1046,7 @@ func handleListFavorites(c *gin.Context) {
 		return
 	}
 
-	recipes, err := recipeRepo.ListFavoriteRecipes(username)
+	recipes, err := requestStore(c).ListFavoriteRecipes(username)
 	if err != nil {
 		log.Printf("Error listing favorites for %s: %v", username, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list favorites"})
@@ -468,6 +1118,18 @@ func scaleRecipeFromQuery(c *gin.Context, recipe *Recipe) {
 	} else {
 		ensureRecipeDisplays(recipe)
 	}
+
+	if system, ok := parseUnitSystem(c.Query("units")); ok {
+		convertRecipeUnits(recipe, system)
+	}
+
+	// Servings scale with the recipe, so only the total changes
+	if original > 0 {
+		recipe.NutritionTotal = scaleNutrition(preferredNutrition(recipe), float64(original)*scale)
+	}
+
+	// Regroup so grouped ingredients reflect the scaled amounts
+	recipe.IngredientGroups = groupIngredients(recipe.ParsedIngredients)
 }
 
 func scaleParsedIngredients(recipe *Recipe, scale float64) {
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+// recipeHandlerTest serves the API's routes with the recipe handlers on an
+// in-memory store.
+type recipeHandlerTest struct {
+	t      *testing.T
+	store  *memoryRecipeStore
+	router *gin.Engine
+	token  string
+}
+
+func newRecipeHandlerTest(t *testing.T) *recipeHandlerTest {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	jwtSecret = "test-secret"
+	store := newMemoryRecipeStore()
+	previousStore, previousRecipe, previousRecipes := recipeStore, recipeCache, recipesCache
+	recipeStore = store
+	recipeCache = cache.New(time.Hour, time.Hour)
+	recipesCache = cache.New(time.Hour, time.Hour)
+	t.Cleanup(func() { recipeStore, recipeCache, recipesCache = previousStore, previousRecipe, previousRecipes })
+
+	token, err := generateToken("cook@example.com", tokenTTL)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+	router := gin.New()
+	registerRoutes(router)
+	return &recipeHandlerTest{t: t, store: store, router: router, token: token}
+}
+
+func (h *recipeHandlerTest) save(slug string, recipe Recipe) uint {
+	h.t.Helper()
+	if err := h.store.SaveRecipeForUser("cook@example.com", slug, recipe); err != nil {
+		h.t.Fatalf("save %s: %v", slug, err)
+	}
+	saved, err := h.store.GetRecipe("cook@example.com", slug)
+	if err != nil {
+		h.t.Fatalf("get %s: %v", slug, err)
+	}
+	return saved.ID
+}
+
+// do sends the request as the test user and decodes a JSON response into out.
+func (h *recipeHandlerTest) do(method, target, body string, out any) int {
+	h.t.Helper()
+	w := h.send(method, target, body, nil)
+	if out != nil && w.Code < 300 {
+		if err := json.Unmarshal(w.Body.Bytes(), out); err != nil {
+			h.t.Fatalf("%s %s: decode %q: %v", method, target, w.Body.String(), err)
+		}
+	}
+	return w.Code
+}
+
+// send sends the request as the test user with the extra headers.
+func (h *recipeHandlerTest) send(method, target, body string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+h.token)
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	h.router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRecipeHandlersListAndFilter(t *testing.T) {
+	h := newRecipeHandlerTest(t)
+	h.save("toast", Recipe{Title: "Toast", Category: "breakfast"})
+	h.save("bread", Recipe{Title: "Bread", Category: "baking"})
+	h.save("rolls", Recipe{Title: "Rolls", Category: "baking"})
+
+	var recipes []Recipe
+	if code := h.do("GET", "/get-recipes", "", &recipes); code != http.StatusOK {
+		t.Fatalf("list: status %d", code)
+	}
+	if len(recipes) != 3 || recipes[0].Title != "Rolls" {
+		t.Fatalf("list = %v, want three recipes, newest first", recipeTitles(recipes))
+	}
+
+	if code := h.do("GET", "/get-recipes?category=baking", "", &recipes); code != http.StatusOK || len(recipes) != 2 {
+		t.Fatalf("baking: status %d, recipes %v", code, recipeTitles(recipes))
+	}
+	if code := h.do("GET", "/get-recipes?category=dessert", "", nil); code != http.StatusBadRequest {
+		t.Fatalf("unknown category: status %d, want 400", code)
+	}
+
+	var categories []CategoryCount
+	if code := h.do("GET", "/categories", "", &categories); code != http.StatusOK {
+		t.Fatalf("categories: status %d", code)
+	}
+	if len(categories) != 2 || categories[0].Category != "baking" || categories[0].Count != 2 {
+		t.Fatalf("categories = %+v, want baking 2 and breakfast 1", categories)
+	}
+	var details []CategoryDetail
+	if code := h.do("GET", "/categories?details=true", "", &details); code != http.StatusOK {
+		t.Fatalf("category details: status %d", code)
+	}
+	if len(details) != 2 || details[0].Latest == nil || details[0].Latest.Title != "Rolls" {
+		t.Fatalf("category details = %+v, want Rolls newest in baking", details)
+	}
+}
+
+func TestRecipeHandlersGetPatchAndDelete(t *testing.T) {
+	h := newRecipeHandlerTest(t)
+	id := h.save("toast", Recipe{Title: "Toast", Category: "breakfast", Instructions: []string{"Toast the bread."}})
+	target := "/recipes/id/" + itoa(id)
+
+	var recipe Recipe
+	if code := h.do("GET", "/get-recipe/x?id="+itoa(id), "", &recipe); code != http.StatusOK || recipe.Title != "Toast" {
+		t.Fatalf("get: status %d, recipe %q", code, recipe.Title)
+	}
+	if code := h.do("GET", "/get-recipe/x?id=999", "", nil); code != http.StatusNotFound {
+		t.Fatalf("get missing: status %d, want 404", code)
+	}
+
+	if code := h.do("PATCH", target, `{"category":"dessert"}`, nil); code != http.StatusBadRequest {
+		t.Fatalf("patch bad category: status %d, want 400", code)
+	}
+	if code := h.do("PATCH", target, `{"title":"Cinnamon Toast"}`, &recipe); code != http.StatusOK || recipe.Title != "Cinnamon Toast" {
+		t.Fatalf("patch: status %d, title %q", code, recipe.Title)
+	}
+	// The edit reaches the list, not a cached copy
+	var recipes []Recipe
+	h.do("GET", "/get-recipes", "", &recipes)
+	if len(recipes) != 1 || recipes[0].Title != "Cinnamon Toast" {
+		t.Fatalf("list after patch = %v", recipeTitles(recipes))
+	}
+
+	if code := h.do("DELETE", target, "", nil); code != http.StatusOK {
+		t.Fatalf("delete: status %d", code)
+	}
+	if code := h.do("GET", "/get-recipe/x?id="+itoa(id), "", nil); code != http.StatusNotFound {
+		t.Fatalf("get after delete: status %d, want 404", code)
+	}
+}
+
+func TestRecipeHandlersFavoritesAndCooked(t *testing.T) {
+	h := newRecipeHandlerTest(t)
+	toast := h.save("toast", Recipe{Title: "Toast", Category: "breakfast"})
+	h.save("bread", Recipe{Title: "Bread", Category: "baking"})
+
+	if code := h.do("POST", "/recipes/id/"+itoa(toast)+"/favorite", "", nil); code != http.StatusOK {
+		t.Fatalf("favorite: status %d", code)
+	}
+	if code := h.do("POST", "/recipes/id/999/favorite", "", nil); code != http.StatusNotFound {
+		t.Fatalf("favorite missing: status %d, want 404", code)
+	}
+	var favorites []Recipe
+	if code := h.do("GET", "/favorites", "", &favorites); code != http.StatusOK {
+		t.Fatalf("favorites: status %d", code)
+	}
+	if len(favorites) != 1 || favorites[0].Title != "Toast" || !favorites[0].IsFavorite {
+		t.Fatalf("favorites = %v, want Toast", recipeTitles(favorites))
+	}
+
+	var cooked Recipe
+	if code := h.do("POST", "/recipes/id/"+itoa(toast)+"/cooked", "", &cooked); code != http.StatusOK || cooked.LastCookedAt == nil {
+		t.Fatalf("cooked: status %d, lastCookedAt %v", code, cooked.LastCookedAt)
+	}
+	// Just cooked, so the random pick skips it
+	var pick Recipe
+	if code := h.do("GET", "/recipes/random?excludeCookedDays=7", "", &pick); code != http.StatusOK || pick.Title != "Bread" {
+		t.Fatalf("random: status %d, pick %q", code, pick.Title)
+	}
+}
+
+func TestRandomRecipeLooksPastExcludedCandidates(t *testing.T) {
+	h := newRecipeHandlerTest(t)
+	h.save("bread", Recipe{Title: "Bread", Category: "baking", Ingredients: []string{"flour", "water"}})
+	// The memory store samples newest first, so the first draw is all cookies
+	for i := range 2 * randomRecipeCandidates {
+		h.save("cookies-"+strconv.Itoa(i), Recipe{Title: "Peanut Cookies", Category: "dessert", Ingredients: []string{"peanuts", "flour"}})
+	}
+
+	var pick Recipe
+	if code := h.do("GET", "/recipes/random?exclude=peanuts", "", &pick); code != http.StatusOK || pick.Title != "Bread" {
+		t.Fatalf("random: status %d, pick %q, want Bread", code, pick.Title)
+	}
+	if code := h.do("GET", "/recipes/random?exclude=flour", "", nil); code != http.StatusNotFound {
+		t.Fatalf("random with everything excluded: status %d, want 404", code)
+	}
+}
+
+func TestRecipeHandlersRevalidateWithETag(t *testing.T) {
+	h := newRecipeHandlerTest(t)
+	id := h.save("toast", Recipe{Title: "Toast", Category: "breakfast", Servings: 2, Ingredients: []string{"2 slices bread"}})
+
+	for _, target := range []string{"/get-recipes", "/get-recipe/x?id=" + itoa(id), "/get-recipe/toast"} {
+		first := h.send("GET", target, "", nil)
+		etag := first.Header().Get("ETag")
+		if first.Code != http.StatusOK || etag == "" {
+			t.Fatalf("%s: status %d, ETag %q", target, first.Code, etag)
+		}
+		// The second request is served from the cache with the same tag
+		again := h.send("GET", target, "", map[string]string{"If-None-Match": `"stale", ` + etag})
+		if again.Code != http.StatusNotModified || again.Body.Len() != 0 || again.Header().Get("ETag") != etag {
+			t.Fatalf("%s revalidated: status %d, %d bytes, ETag %q", target, again.Code, again.Body.Len(), again.Header().Get("ETag"))
+		}
+		if w := h.send("GET", target, "", map[string]string{"If-None-Match": `"stale"`}); w.Code != http.StatusOK {
+			t.Fatalf("%s with a stale tag: status %d", target, w.Code)
+		}
+	}
+
+	list := h.send("GET", "/get-recipes", "", nil).Header().Get("ETag")
+	recipe := h.send("GET", "/get-recipe/x?id="+itoa(id), "", nil).Header().Get("ETag")
+	h.do("POST", "/recipes/id/"+itoa(id)+"/favorite", "", nil)
+	if w := h.send("GET", "/get-recipes", "", map[string]string{"If-None-Match": list}); w.Code != http.StatusOK {
+		t.Fatalf("list after favoriting: status %d, want 200", w.Code)
+	}
+	if w := h.send("GET", "/get-recipe/x?id="+itoa(id), "", map[string]string{"If-None-Match": recipe}); w.Code != http.StatusOK {
+		t.Fatalf("recipe after favoriting: status %d, want 200", w.Code)
+	}
+}
+
+func TestRecipeHandlersPatchIfMatch(t *testing.T) {
+	h := newRecipeHandlerTest(t)
+	id := h.save("toast", Recipe{Title: "Toast", Category: "breakfast"})
+	target := "/recipes/id/" + itoa(id)
+	read := h.send("GET", "/get-recipe/toast", "", nil).Header().Get("ETag")
+
+	// Another member renames the recipe after both read it
+	first := h.send("PATCH", target, `{"title":"French Toast"}`, map[string]string{"If-Match": read})
+	if first.Code != http.StatusOK || first.Header().Get("ETag") == read {
+		t.Fatalf("first patch: status %d, ETag %q", first.Code, first.Header().Get("ETag"))
+	}
+	if w := h.send("PATCH", target, `{"title":"Cinnamon Toast"}`, map[string]string{"If-Match": read}); w.Code != http.StatusConflict {
+		t.Fatalf("stale patch: status %d, want 409", w.Code)
+	}
+
+	// Reading again gives the tag the first patch returned
+	fresh := h.send("GET", "/get-recipe/toast", "", nil)
+	if fresh.Header().Get("ETag") != first.Header().Get("ETag") {
+		t.Fatalf("ETag after patch %q, GET gives %q", first.Header().Get("ETag"), fresh.Header().Get("ETag"))
+	}
+	var recipe Recipe
+	if w := h.send("PATCH", target, `{"title":"Cinnamon Toast"}`, map[string]string{"If-Match": fresh.Header().Get("ETag")}); w.Code != http.StatusOK {
+		t.Fatalf("patch after reload: status %d", w.Code)
+	}
+	// Without If-Match the last write still wins
+	if code := h.do("PATCH", target, `{"title":"Toast"}`, &recipe); code != http.StatusOK || recipe.Title != "Toast" {
+		t.Fatalf("unconditional patch: status %d, title %q", code, recipe.Title)
+	}
+}
+
+func recipeTitles(recipes []Recipe) []string {
+	titles := make([]string, 0, len(recipes))
+	for _, recipe := range recipes {
+		titles = append(titles, recipe.Title)
+	}
+	return titles
+}
+
+func itoa(id uint) string {
+	return strconv.FormatUint(uint64(id), 10)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type suggestRequest struct {
+	// Ingredients are what's on hand, as names or ingredient lines
+	Ingredients []string `json:"ingredients" binding:"required"`
+	Limit       int      `json:"limit"`
+	// AssumeStaples counts salt, pepper, oil and the like as on hand
+	// (default true)
+	AssumeStaples *bool `json:"assumeStaples"`
+	// NoAI skips the AI fallback when no saved recipe matches
+	NoAI bool `json:"noAI"`
+}
+
+type suggestResponse struct {
+	Suggestions []PantrySuggestion `json:"suggestions"`
+}
+
+// handleSuggest finds recipes to cook with the ingredients on hand: the
+// user's own recipes first and, when none of them match well enough,
+// AI-generated ones. Each suggestion lists what's still missing.
+func handleSuggest(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request suggestRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ingredients are required"})
+		return
+	}
+	onHand := make([]string, 0, len(request.Ingredients))
+	for _, ingredient := range request.Ingredients {
+		if ingredient = strings.TrimSpace(ingredient); ingredient != "" {
+			onHand = append(onHand, ingredient)
+		}
+	}
+	if len(onHand) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "ingredients are required"})
+		return
+	}
+	if len(onHand) > maxPantryIngredients {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("at most %d ingredients", maxPantryIngredients)})
+		return
+	}
+	limit := request.Limit
+	if limit == 0 {
+		limit = defaultSuggestLimit
+	}
+	if limit < 1 || limit > maxSuggestLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSuggestLimit)})
+		return
+	}
+	assumeStaples := request.AssumeStaples == nil || *request.AssumeStaples
+
+	p := newPantry(onHand, assumeStaples)
+	suggestions, err := requestRepo(c).PantryRecipes(username, p, minPantryCoverage, limit)
+	if err != nil {
+		log.Printf("Error matching pantry recipes for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to suggest recipes"})
+		return
+	}
+	if len(suggestions) > 0 || request.NoAI {
+		c.JSON(http.StatusOK, suggestResponse{Suggestions: suggestions})
+		return
+	}
+
+	if rejectIfFrozen(c, username) {
+		return
+	}
+	prefs, err := requestRepo(c).GetUserPreferences(username)
+	if err != nil {
+		log.Printf("Error fetching preferences for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to suggest recipes"})
+		return
+	}
+	ai := meterAIUsage(newAIProvider(), username, "")
+	suggestions, err = suggestPantryRecipesWithAI(c.Request.Context(), ai, onHand, prefs.Allergens, p, min(limit, maxAIPantrySuggestions))
+	if err != nil {
+		log.Printf("Error generating pantry suggestions for %s: %v", username, err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "failed to suggest recipes"})
+		return
+	}
+	c.JSON(http.StatusOK, suggestResponse{Suggestions: suggestions})
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type aiUsageResponse struct {
+	Since string `json:"since"`
+	AIUsageSummary
+}
+
+type aiUsageRollupResponse struct {
+	Since string `json:"since"`
+	AIUsageRollup
+}
+
+// usageSince reads ?days=, the number of days of usage to sum.
+func usageSince(c *gin.Context) (time.Time, error) {
+	days := defaultUsageDays
+	if raw := strings.TrimSpace(c.Query("days")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxUsageDays {
+			return time.Time{}, fmt.Errorf("days must be between 1 and %d", maxUsageDays)
+		}
+		days = n
+	}
+	return time.Now().AddDate(0, 0, -days), nil
+}
+
+// handleGetAIUsage returns the caller's AI usage and estimated cost.
+func handleGetAIUsage(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	since, err := usageSince(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	summary, err := requestRepo(c).GetUserAIUsage(username, since)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		log.Printf("Failed to fetch AI usage for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch usage"})
+		return
+	}
+	c.JSON(http.StatusOK, aiUsageResponse{Since: since.UTC().Format(time.RFC3339), AIUsageSummary: summary})
+}
+
+// handleAdminAIUsage returns everyone's AI usage with the costliest users
+// and pages, for admins.
+func handleAdminAIUsage(c *gin.Context) {
+	if _, ok := requireAdmin(c); !ok {
+		return
+	}
+	since, err := usageSince(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rollup, err := requestRepo(c).GetAIUsageRollup(since, adminUsageTopN)
+	if err != nil {
+		log.Printf("Failed to fetch AI usage rollup: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch usage"})
+		return
+	}
+	c.JSON(http.StatusOK, aiUsageRollupResponse{Since: since.UTC().Format(time.RFC3339), AIUsageRollup: rollup})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func handleListWebhooks(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	hooks, err := requestRepo(c).ListWebhooks(username)
+	if err != nil {
+		log.Printf("Error listing webhooks for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+type createWebhookRequest struct {
+	URL    string   `json:"url" binding:"required"`
+	Events []string `json:"events"`
+}
+
+// handleCreateWebhook registers {url, events}. The response carries the
+// signing secret, which is not shown again.
+func handleCreateWebhook(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	var request createWebhookRequest
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url is required"})
+		return
+	}
+
+	parsed, err := url.Parse(strings.TrimSpace(request.URL))
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http(s) URL"})
+		return
+	}
+
+	events := normalizeTerms(request.Events)
+	for _, event := range events {
+		if !slices.Contains(webhookEvents, event) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event " + event + "; allowed: " + strings.Join(webhookEvents, ", ")})
+			return
+		}
+	}
+
+	hook, err := requestRepo(c).CreateWebhook(username, parsed.String(), events)
+	if err != nil {
+		if errors.Is(err, errTooManyWebhooks) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		log.Printf("Error creating webhook for %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, hook)
+}
+
+func handleDeleteWebhook(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	if err := requestRepo(c).DeleteWebhook(username, uint(id64)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		log.Printf("Error deleting webhook %d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+// handlePingWebhook sends a signed "ping" event right away and reports the
+// endpoint's answer, for checking a receiver while setting it up.
+func handlePingWebhook(c *gin.Context) {
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+
+	id64, convErr := strconv.ParseUint(strings.TrimSpace(c.Param("id")), 10, 64)
+	if convErr != nil || id64 == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid id"})
+		return
+	}
+
+	hook, err := requestRepo(c).webhookByID(username, uint(id64))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+			return
+		}
+		log.Printf("Error loading webhook %d for %s: %v", id64, username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load webhook"})
+		return
+	}
+
+	payload, err := newWebhookPayload(webhookEventPing, gin.H{"webhookId": hook.ID})
+	if err != nil {
+		log.Printf("Error encoding webhook ping: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send ping"})
+		return
+	}
+
+	status, deliveryErr := deliverWebhook(hook, webhookEventPing, payload)
+	if err := requestRepo(c).RecordWebhookDelivery(hook.ID, status, deliveryErr); err != nil {
+		log.Printf("Webhooks: %v", err)
+	}
+
+	response := gin.H{"delivered": deliveryErr == nil, "status": status}
+	if deliveryErr != nil {
+		response["error"] = deliveryErr.Error()
+	}
+	c.JSON(http.StatusOK, response)
+}
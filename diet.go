@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Recipes are classified as vegetarian, vegan, gluten-free and dairy-free
+// from their ingredients whenever they are saved or their ingredients are
+// edited, so the flags never go stale. A diet applies when no ingredient
+// names something it rules out; like the allergen filter, matching errs on
+// the side of leaving a diet off.
+
+const (
+	dietVegetarian = "vegetarian"
+	dietVegan      = "vegan"
+	dietGlutenFree = "gluten-free"
+	dietDairyFree  = "dairy-free"
+)
+
+// knownDiets are in display order.
+var knownDiets = []string{dietVegetarian, dietVegan, dietGlutenFree, dietDairyFree}
+
+var (
+	meatKeywords = []string{
+		"meat", "beef", "steak", "veal", "pork", "bacon", "ham", "sausage", "chorizo", "salami", "pepperoni",
+		"prosciutto", "pancetta", "lamb", "mutton", "goat", "venison", "chicken", "turkey", "duck", "goose",
+		"lard", "gelatin", "gelatine", "worcestershire", "fish sauce", "oyster sauce",
+	}
+	animalProductKeywords = []string{"egg", "mayonnaise", "honey"}
+	glutenKeywords        = []string{"soy sauce", "seitan", "semolina", "spelt", "bulgur", "farro", "beer", "cracker", "tortilla"}
+
+	// "peanut butter" and "vegan cheese" mention dairy words without dairy
+	dietNeutralPhrasePattern = regexp.MustCompile(`\b(?:vegan|vegetarian|plant based|meatless|meat free|dairy free|non dairy|gluten free|egg free) \w+|\b(?:peanut|almond|cashew|nut|seed|apple|cocoa|shea|sunflower) butter|\b(?:coconut|almond|oat|soy|rice|cashew|hemp) (?:milk|cream|yogurt)|\bcream of tartar|\b(?:rice|almond|coconut|corn|chickpea|buckwheat|tapioca|potato) (?:flour|noodle|noodles|pasta|tortilla|tortillas)`)
+)
+
+// dietExclusions lists what rules each diet out.
+func dietExclusions(diet string) []string {
+	animal := append(append([]string{}, meatKeywords...), allergenKeywords["fish"]...)
+	animal = append(animal, allergenKeywords["shellfish"]...)
+	switch diet {
+	case dietVegetarian:
+		return animal
+	case dietVegan:
+		vegan := append(animal, allergenKeywords["dairy"]...)
+		return append(vegan, animalProductKeywords...)
+	case dietGlutenFree:
+		return append(append([]string{}, allergenKeywords["gluten"]...), glutenKeywords...)
+	case dietDairyFree:
+		return allergenKeywords["dairy"]
+	}
+	return nil
+}
+
+// classifyDiets returns the diets the recipe's ingredients fit, or nil when
+// it has no ingredients to judge by.
+func classifyDiets(recipe Recipe) []string {
+	text := ingredientText(recipe)
+	if strings.TrimSpace(text) == "" {
+		return nil
+	}
+	text = dietNeutralPhrasePattern.ReplaceAllString(text, " ")
+
+	diets := []string{}
+	for _, diet := range knownDiets {
+		fits := true
+		for _, keyword := range dietExclusions(diet) {
+			if containsIngredientKeyword(text, keyword) {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			diets = append(diets, diet)
+		}
+	}
+	return diets
+}
+
+// recipeDietsJSON is the stored form of classifyDiets.
+func recipeDietsJSON(recipe Recipe) (string, error) {
+	diets := classifyDiets(recipe)
+	if diets == nil {
+		return "", nil
+	}
+	data, err := json.Marshal(diets)
+	if err != nil {
+		return "", fmt.Errorf("marshal diets: %w", err)
+	}
+	return string(data), nil
+}
+
+// normalizeDiet accepts "vegan", "Gluten Free" or "gluten_free".
+func normalizeDiet(diet string) (string, bool) {
+	diet = strings.ToLower(strings.TrimSpace(diet))
+	diet = strings.NewReplacer(" ", "-", "_", "-").Replace(diet)
+	for _, known := range knownDiets {
+		if diet == known {
+			return known, true
+		}
+	}
+	return "", false
+}
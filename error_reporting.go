@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Queue panics and 5xx responses are reported to Sentry, or anything that
+// takes a Sentry DSN, when SENTRY_DSN is set. SENTRY_ENVIRONMENT and
+// SENTRY_RELEASE label the events; SENTRY_SAMPLE_RATE (0 to 1, default 1)
+// keeps a share of them. Without a DSN reporting is off and only the logs
+// have them.
+
+// initErrorReporting connects to Sentry. The returned function sends the
+// events still queued; call it on the way out.
+func initErrorReporting(cfg ReportingConfig) (func(), error) {
+	if cfg.SentryDSN == "" {
+		log.Println("Error reporting: disabled (SENTRY_DSN not set)")
+		return func() {}, nil
+	}
+	err := sentry.Init(sentry.ClientOptions{
+		Dsn:              cfg.SentryDSN,
+		Environment:      cfg.SentryEnvironment,
+		Release:          cfg.SentryRelease,
+		SampleRate:       cfg.SentrySampleRate,
+		AttachStacktrace: true,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sentry: %w", err)
+	}
+	log.Println("Error reporting: sending to Sentry")
+	return func() { sentry.Flush(errorReportingFlushTimeout) }, nil
+}
+
+// reportQueuePanic reports a panic recovered while importing item. item is
+// nil for a panic outside any one item.
+func reportQueuePanic(ctx context.Context, recovered any, item *QueueModel) {
+	hub := sentry.CurrentHub().Clone()
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("component", "queue")
+		tagTrace(ctx, scope)
+		if item != nil {
+			scope.SetUser(sentry.User{ID: strconv.FormatUint(uint64(item.UserID), 10), Username: item.User.Username})
+			scope.SetTag("queue_item_id", strconv.FormatUint(uint64(item.ID), 10))
+			scope.SetContext("queue_item", sentry.Context{
+				"id":       item.ID,
+				"url":      item.URL,
+				"attempts": item.Attempts,
+				"priority": item.Priority,
+			})
+		}
+		hub.RecoverWithContext(ctx, recovered)
+	})
+}
+
+// reportServerErrors reports requests answered with a 5xx, and handler
+// panics before passing them on to gin's recovery. Health checks and
+// metrics scrapes aren't reported.
+func reportServerErrors(c *gin.Context) {
+	if c.Request.URL.Path == "/metrics" || isProbePath(c.Request.URL.Path) {
+		c.Next()
+		return
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			reportRequest(c, http.StatusInternalServerError, func(hub *sentry.Hub) { hub.RecoverWithContext(c.Request.Context(), r) })
+			panic(r)
+		}
+	}()
+	c.Next()
+
+	status := c.Writer.Status()
+	if status < http.StatusInternalServerError {
+		return
+	}
+	reportRequest(c, status, func(hub *sentry.Hub) {
+		if err := c.Errors.Last(); err != nil {
+			hub.CaptureException(err.Err)
+			return
+		}
+		hub.CaptureMessage(fmt.Sprintf("%s %s answered %d", c.Request.Method, requestRoute(c), status))
+	})
+}
+
+// reportRequest captures an event with the request and, when it carried a
+// valid bearer token, the user who made it.
+func reportRequest(c *gin.Context, status int, capture func(hub *sentry.Hub)) {
+	hub := sentry.CurrentHub().Clone()
+	hub.WithScope(func(scope *sentry.Scope) {
+		scope.SetTag("component", "http")
+		scope.SetTag("route", requestRoute(c))
+		scope.SetTag("status", strconv.Itoa(status))
+		scope.SetRequest(c.Request)
+		tagTrace(c.Request.Context(), scope)
+		if header := c.GetHeader("Authorization"); strings.TrimSpace(header) != "" {
+			if username, err := extractUsernameFromBearer(header); err == nil {
+				scope.SetUser(sentry.User{Username: username})
+			}
+		}
+		capture(hub)
+	})
+}
+
+// requestRoute is the route pattern, so events for different recipes
+// group together.
+func requestRoute(c *gin.Context) string {
+	if route := c.FullPath(); route != "" {
+		return route
+	}
+	return c.Request.URL.Path
+}
+
+// tagTrace links an event to the trace it happened in.
+func tagTrace(ctx context.Context, scope *sentry.Scope) {
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		scope.SetTag("trace_id", span.TraceID().String())
+	}
+}
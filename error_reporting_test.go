@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
+)
+
+// eventRecorder is a Sentry transport that keeps the events it's sent.
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []*sentry.Event
+}
+
+func (r *eventRecorder) Configure(sentry.ClientOptions) {}
+func (r *eventRecorder) Flush(time.Duration) bool       { return true }
+func (r *eventRecorder) SendEvent(event *sentry.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) sent() []*sentry.Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]*sentry.Event(nil), r.events...)
+}
+
+// recordErrorReports points the Sentry hub at a recorder for the test.
+func recordErrorReports(t *testing.T) *eventRecorder {
+	t.Helper()
+	recorder := &eventRecorder{}
+	client, err := sentry.NewClient(sentry.ClientOptions{Dsn: "https://key@sentry.example.com/1", Transport: recorder})
+	if err != nil {
+		t.Fatalf("sentry client: %v", err)
+	}
+	hub := sentry.CurrentHub()
+	previous := hub.Client()
+	hub.BindClient(client)
+	t.Cleanup(func() { hub.BindClient(previous) })
+	return recorder
+}
+
+func TestReportServerErrors(t *testing.T) {
+	recorder := recordErrorReports(t)
+	jwtSecret = "test-secret"
+	token, err := generateToken("cook@example.com", tokenTTL)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(gin.Recovery(), reportServerErrors)
+	router.GET("/recipes/:slug", func(c *gin.Context) {
+		switch c.Param("slug") {
+		case "missing":
+			c.JSON(http.StatusNotFound, gin.H{"error": "recipe not found"})
+		case "broken":
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipe"})
+		default:
+			panic("boom")
+		}
+	})
+	serve := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	if code := serve("/recipes/missing"); code != http.StatusNotFound || len(recorder.sent()) != 0 {
+		t.Fatalf("404: status %d, %d events", code, len(recorder.sent()))
+	}
+	if code := serve("/recipes/broken"); code != http.StatusInternalServerError {
+		t.Fatalf("500: status %d", code)
+	}
+	if code := serve("/recipes/panics"); code != http.StatusInternalServerError {
+		t.Fatalf("panic: status %d", code)
+	}
+
+	events := recorder.sent()
+	if len(events) != 2 {
+		t.Fatalf("sent %d events, want 2", len(events))
+	}
+	if events[0].Message != "GET /recipes/:slug answered 500" {
+		t.Errorf("message = %q", events[0].Message)
+	}
+	if events[1].Message != "boom" {
+		t.Errorf("panic message = %q", events[1].Message)
+	}
+	for _, event := range events {
+		if event.User.Username != "cook@example.com" || event.Tags["route"] != "/recipes/:slug" {
+			t.Errorf("event user %q, route %q", event.User.Username, event.Tags["route"])
+		}
+	}
+}
+
+func TestReportQueuePanicCarriesTheItem(t *testing.T) {
+	recorder := recordErrorReports(t)
+	item := QueueModel{ID: 7, UserID: 3, User: UserModel{Username: "cook@example.com"}, URL: "https://example.com/pie", Attempts: 2}
+
+	reportQueuePanic(context.Background(), "boom", &item)
+
+	events := recorder.sent()
+	if len(events) != 1 {
+		t.Fatalf("sent %d events, want 1", len(events))
+	}
+	event := events[0]
+	if event.User.ID != "3" || event.User.Username != "cook@example.com" || event.Tags["queue_item_id"] != "7" {
+		t.Errorf("event user %+v, tags %v", event.User, event.Tags)
+	}
+	if event.Contexts["queue_item"]["url"] != "https://example.com/pie" {
+		t.Errorf("queue item context = %v", event.Contexts["queue_item"])
+	}
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The AI endpoints can stream their reply as server-sent events instead of
+// answering once it's complete, when asked with ?stream=true or an Accept of
+// text/event-stream. Text arrives as "delta" events ({"text": ...}) and the
+// response the endpoint would otherwise return as a final "done" event; a
+// failure after the stream has started is an "error" event. Idle streams get
+// a comment every sseHeartbeatInterval, and the AI request is abandoned when
+// the client disconnects.
+
+// wantsEventStream reports whether the client asked for a streamed reply.
+func wantsEventStream(c *gin.Context) bool {
+	if stream, ok := c.GetQuery("stream"); ok {
+		return stream == "true" || stream == "1"
+	}
+	return strings.Contains(c.GetHeader("Accept"), "text/event-stream")
+}
+
+// eventStream writes server-sent events to a client. Writes are serialized
+// with the heartbeat, which runs until close.
+type eventStream struct {
+	c      *gin.Context
+	mu     sync.Mutex
+	closed bool
+	done   chan struct{}
+}
+
+func startEventStream(c *gin.Context) *eventStream {
+	header := c.Writer.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+	// Stop nginx buffering the stream
+	header.Set("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	c.Writer.WriteHeaderNow()
+	c.Writer.Flush()
+
+	s := &eventStream{c: c, done: make(chan struct{})}
+	go s.heartbeat()
+	return s
+}
+
+func (s *eventStream) heartbeat() {
+	ticker := time.NewTicker(sseHeartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.mu.Lock()
+			if !s.closed {
+				_, _ = s.c.Writer.WriteString(": ping\n\n")
+				s.c.Writer.Flush()
+			}
+			s.mu.Unlock()
+		case <-s.done:
+			return
+		case <-s.c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// send writes one event; data is sent as JSON.
+func (s *eventStream) send(event string, data any) {
+	if s.c.Request.Context().Err() != nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	s.c.SSEvent(event, data)
+	s.c.Writer.Flush()
+}
+
+// delta sends a piece of AI text as it's written.
+func (s *eventStream) delta(text string) {
+	s.send("delta", gin.H{"text": text})
+}
+
+// finish sends the endpoint's response: as the "done" event, or "error"
+// for a failure status, when streaming (s isn't nil), and as JSON otherwise.
+func (s *eventStream) finish(c *gin.Context, status int, body any) {
+	if s == nil {
+		c.JSON(status, body)
+		return
+	}
+	if status >= http.StatusBadRequest {
+		s.send("error", body)
+		return
+	}
+	s.send("done", body)
+}
+
+// close stops the heartbeat; nothing is written after it returns.
+func (s *eventStream) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.closed {
+		s.closed = true
+		close(s.done)
+	}
+}
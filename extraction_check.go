@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// AI extractions are checked before they're used: a recipe needs a title,
+// ingredients and instructions, a category from the allowed set, and
+// ingredient lines and amounts that look like ingredients. A response that
+// fails gets one follow-up prompt listing the problems; whichever of the
+// two answers has fewer problems is kept, and the queue still saves a
+// placeholder for a recipe that's incomplete after that.
+
+// extractionProblems lists what's wrong with an extracted recipe, or nil
+// when it looks usable.
+func extractionProblems(response *Response) []string {
+	var problems []string
+	if strings.TrimSpace(response.Title) == "" {
+		problems = append(problems, "the title is empty")
+	}
+
+	var ingredients []string
+	for _, line := range response.Ingredients {
+		if line = strings.TrimSpace(line); line != "" {
+			ingredients = append(ingredients, line)
+		}
+	}
+	if len(ingredients) == 0 {
+		problems = append(problems, "there are no ingredients")
+	}
+
+	instructions := response.Instructions
+	if len(instructions) == 0 {
+		instructions = flattenInstructionSections(response.InstructionSections)
+	}
+	steps := make(map[string]struct{})
+	for _, step := range instructions {
+		if step = strings.ToLower(strings.TrimSpace(step)); step != "" {
+			steps[step] = struct{}{}
+		}
+	}
+	if len(steps) == 0 {
+		problems = append(problems, "there are no instructions")
+	}
+
+	if _, ok := normalizeCategoryStrict(response.Category); !ok {
+		problems = append(problems, fmt.Sprintf("category %q is not one of breakfast, dinner, baking or other", response.Category))
+	}
+
+	repeated := 0
+	for i, line := range ingredients {
+		if len(line) > maxIngredientLineChars {
+			problems = append(problems, fmt.Sprintf("ingredient %d is a paragraph, not an ingredient line", i+1))
+		}
+		if _, ok := steps[strings.ToLower(line)]; ok {
+			repeated++
+		}
+	}
+	if repeated > 0 && repeated*2 >= len(ingredients) {
+		problems = append(problems, "the ingredients repeat the instruction steps")
+	}
+
+	for _, detail := range response.ParsedIngredients {
+		if detail.AmountValue != nil && (*detail.AmountValue < 0 || *detail.AmountValue > maxIngredientAmount) {
+			problems = append(problems, fmt.Sprintf("the amount of %q (%g) is implausible", strings.TrimSpace(detail.Description), *detail.AmountValue))
+		}
+	}
+	if response.Servings < 0 || response.PrepTime < 0 || response.CookTime < 0 || response.TotalTime < 0 {
+		problems = append(problems, "servings and times can't be negative")
+	}
+	return problems
+}
+
+// extractionEcho is the part of a bad answer shown back to the AI.
+type extractionEcho struct {
+	Title               string               `json:"title"`
+	Category            string               `json:"category"`
+	Servings            int                  `json:"servings"`
+	PrepTime            int                  `json:"prepTime"`
+	CookTime            int                  `json:"cookTime"`
+	TotalTime           int                  `json:"totalTime"`
+	Ingredients         []string             `json:"ingredients"`
+	ParsedIngredients   []IngredientDetail   `json:"parsedIngredients"`
+	Instructions        []string             `json:"instructions"`
+	InstructionSections []InstructionSection `json:"instructionSections"`
+}
+
+const extractionRepairPrompt = `Your previous answer for this recipe had these problems:
+%s
+Your previous answer was:
+%s
+
+Extract the recipe again from the same text with these problems fixed. Use only what the text says; leave a field empty rather than inventing it.
+
+%s`
+
+// extractCheckedRecipe runs the extraction, and once more with the problems
+// spelled out when the first answer fails extractionProblems. The result
+// is recorded in recipes_ai_extractions_total.
+func extractCheckedRecipe(ctx context.Context, ai AIProvider, prompt, system string, maxTokens int) (*Response, error) {
+	started := time.Now()
+	response, err := ai.ExtractRecipe(ctx, prompt, system, maxTokens)
+	if err != nil {
+		observeAIExtraction(aiExtractionError, started)
+		return nil, err
+	}
+	problems := extractionProblems(response)
+	if len(problems) == 0 {
+		observeAIExtraction(aiExtractionClean, started)
+		return response, nil
+	}
+	log.Printf("Scraper: AI extraction of %q has problems, asking again: %s", response.Title, strings.Join(problems, "; "))
+
+	previous, err := json.Marshal(extractionEcho{
+		Title:               response.Title,
+		Category:            response.Category,
+		Servings:            response.Servings,
+		PrepTime:            response.PrepTime,
+		CookTime:            response.CookTime,
+		TotalTime:           response.TotalTime,
+		Ingredients:         response.Ingredients,
+		ParsedIngredients:   response.ParsedIngredients,
+		Instructions:        response.Instructions,
+		InstructionSections: response.InstructionSections,
+	})
+	if err != nil {
+		observeAIExtraction(aiExtractionFlawed, started)
+		return response, nil
+	}
+	repairPrompt := fmt.Sprintf(extractionRepairPrompt,
+		"- "+strings.Join(problems, "\n- "),
+		truncateText(string(previous), maxRepairEchoChars),
+		prompt)
+	repaired, err := ai.ExtractRecipe(ctx, repairPrompt, system, maxTokens)
+	if err != nil {
+		log.Printf("Scraper: AI repair of %q failed: %v", response.Title, err)
+		observeAIExtraction(aiExtractionFlawed, started)
+		return response, nil
+	}
+	remaining := extractionProblems(repaired)
+	if len(remaining) >= len(problems) {
+		log.Printf("Scraper: AI repair of %q didn't help: %s", response.Title, strings.Join(remaining, "; "))
+		observeAIExtraction(aiExtractionFlawed, started)
+		return response, nil
+	}
+	if len(remaining) > 0 {
+		log.Printf("Scraper: AI repair of %q left: %s", repaired.Title, strings.Join(remaining, "; "))
+		observeAIExtraction(aiExtractionFlawed, started)
+		return repaired, nil
+	}
+	observeAIExtraction(aiExtractionRepaired, started)
+	return repaired, nil
+}
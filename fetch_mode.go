@@ -0,0 +1,102 @@
+package main
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// Fetch modes pick how fetchPageHTML loads a page. SCRAPER_FETCH_MODE sets
+// the default and SCRAPER_DOMAIN_FETCH_MODES overrides it per domain, e.g.
+// "allrecipes.com=http,example.com=browser".
+const (
+	// fetchModeBrowser renders every page in headless Chromium
+	fetchModeBrowser = "browser"
+	// fetchModeHTTP fetches with plain HTTP and only uses the browser when
+	// the HTML looks JS-rendered or blocked
+	fetchModeHTTP = "http"
+)
+
+// minStaticTextWords is how much visible text a page without structured
+// recipe data needs before its static HTML is trusted.
+const minStaticTextWords = 150
+
+func fetchModeFor(pageURL string) string {
+	if parsed, err := url.Parse(pageURL); err == nil {
+		if mode, _, ok := lookupDomain(appConfig.Scraper.DomainFetchModes, parsed.Hostname()); ok {
+			return mode
+		}
+	}
+	return appConfig.Scraper.FetchMode
+}
+
+func validFetchMode(mode string) bool {
+	return mode == fetchModeBrowser || mode == fetchModeHTTP
+}
+
+// browserNeededReason explains why HTML fetched over plain HTTP isn't good
+// enough to extract from, or returns "" when it is.
+func browserNeededReason(pageURL, content string) string {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return ""
+	}
+	if _, ok := structuredRecipe(doc); ok {
+		return ""
+	}
+
+	if pageLooksBlocked(doc) {
+		return "looks blocked"
+	}
+
+	body := doc.Find("body").Clone()
+	body.Find("script, style, noscript, template").Remove()
+	if words := len(strings.Fields(body.Text())); words < minStaticTextWords {
+		return "looks JS-rendered"
+	}
+	return ""
+}
+
+// pageLooksBlocked reports a bot check or access-denied page.
+func pageLooksBlocked(doc *goquery.Document) bool {
+	title := strings.ToLower(strings.TrimSpace(doc.Find("title").First().Text()))
+	for _, marker := range []string{"just a moment", "attention required", "access denied", "are you a robot"} {
+		if strings.Contains(title, marker) {
+			return true
+		}
+	}
+	return doc.Find(`#challenge-form, #cf-challenge-running, .g-recaptcha, .h-captcha`).Length() > 0
+}
+
+// pageLooksPaywalled reports a page that marks its content as for
+// subscribers, in schema.org data or with a paywall element. It must be
+// checked before scripts are stripped.
+func pageLooksPaywalled(doc *goquery.Document) bool {
+	locked := false
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(_ int, script *goquery.Selection) bool {
+		text := strings.ToLower(strings.Join(strings.Fields(script.Text()), ""))
+		locked = strings.Contains(text, `"isaccessibleforfree":false`) || strings.Contains(text, `"isaccessibleforfree":"false"`)
+		return !locked
+	})
+	return locked || doc.Find(`#paywall, .paywall, [data-paywall], [class*="paywall-"], [id*="paywall-"]`).Length() > 0
+}
+
+// lookupDomain finds host's entry in a map keyed by domain without "www.",
+// trying parent domains so an entry covers its subdomains. It also returns
+// the domain that matched.
+func lookupDomain[V any](entries map[string]V, host string) (V, string, bool) {
+	domain := strings.TrimPrefix(strings.ToLower(host), "www.")
+	for domain != "" {
+		if value, ok := entries[domain]; ok {
+			return value, domain, true
+		}
+		_, parent, found := strings.Cut(domain, ".")
+		if !found || !strings.Contains(parent, ".") {
+			break
+		}
+		domain = parent
+	}
+	var zero V
+	return zero, "", false
+}
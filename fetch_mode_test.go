@@ -0,0 +1,24 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestFetchWithHTTPCapsBodySize(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.Repeat("a", maxPageHTMLBytes+1024)))
+	}))
+	defer server.Close()
+
+	body, err := fetchWithHTTP(context.Background(), server.URL)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(body) != maxPageHTMLBytes {
+		t.Fatalf("read %d bytes, want %d", len(body), maxPageHTMLBytes)
+	}
+}
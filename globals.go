@@ -5,5 +5,15 @@ import "github.com/patrickmn/go-cache"
 var (
 	recipeCache  *cache.Cache
 	recipesCache *cache.Cache
-	recipeRepo   *RecipeRepository
+	// activityCache throttles last-active writes to once per user per TTL
+	activityCache *cache.Cache
+	// inboundTokenCache remembers recent Mailgun webhook tokens to stop replays
+	inboundTokenCache *cache.Cache
+	// robotsCache keeps parsed robots.txt rules per scheme and host
+	robotsCache *cache.Cache
+	// fdcCache keeps USDA food matches and portion weights
+	fdcCache   *cache.Cache
+	recipeRepo *RecipeRepository
+	// scraperBrowsers is the shared headless Chromium used by fetchPageHTML
+	scraperBrowsers *browserPool
 )
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Component statuses in a health report. A disabled component isn't
+// configured or doesn't run in this process, and doesn't make it unhealthy.
+const (
+	healthUp       = "up"
+	healthDown     = "down"
+	healthDisabled = "disabled"
+)
+
+// HealthReport is the answer to GET /health: ok when no component is down.
+type HealthReport struct {
+	Status     string                     `json:"status"`
+	CheckedAt  time.Time                  `json:"checkedAt"`
+	Components map[string]ComponentHealth `json:"components"`
+}
+
+// ComponentHealth is the result of one dependency probe.
+type ComponentHealth struct {
+	Status    string     `json:"status"`
+	LatencyMs *float64   `json:"latencyMs,omitempty"`
+	LastTick  *time.Time `json:"lastTick,omitempty"`
+	Error     string     `json:"error,omitempty"`
+}
+
+// healthProbes are the components /health checks. Each probe gets
+// healthCheckTimeout.
+var healthProbes = map[string]func(ctx context.Context, now time.Time) ComponentHealth{
+	"database": func(ctx context.Context, now time.Time) ComponentHealth {
+		if recipeRepo == nil {
+			return ComponentHealth{Status: healthDown, Error: "database not opened"}
+		}
+		return timedProbe(ctx, recipeRepo.Ping)
+	},
+	"storage": func(ctx context.Context, now time.Time) ComponentHealth {
+		if !appConfig.Storage.Enabled() {
+			return ComponentHealth{Status: healthDisabled}
+		}
+		return timedProbe(ctx, func(ctx context.Context) error {
+			bucket, err := NewCloudflareS3()
+			if err != nil {
+				return err
+			}
+			return bucket.Ping(ctx)
+		})
+	},
+	"queue": func(ctx context.Context, now time.Time) ComponentHealth {
+		return queueLiveness.health(now, queueSettings.PollInterval)
+	},
+}
+
+// checkHealth runs the probes concurrently.
+func checkHealth(ctx context.Context, now time.Time) HealthReport {
+	return runProbes(ctx, now, healthProbes)
+}
+
+// runProbes runs probes concurrently into one report, which is down when
+// any of them is.
+func runProbes(ctx context.Context, now time.Time, probes map[string]func(ctx context.Context, now time.Time) ComponentHealth) HealthReport {
+	report := HealthReport{Status: "ok", CheckedAt: now, Components: make(map[string]ComponentHealth, len(probes))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, probe := range probes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			probeCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+			result := probe(probeCtx, now)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Components[name] = result
+			if result.Status == healthDown {
+				report.Status = "down"
+			}
+		}()
+	}
+	wg.Wait()
+	return report
+}
+
+// timedProbe runs ping and reports how long it took.
+func timedProbe(ctx context.Context, ping func(ctx context.Context) error) ComponentHealth {
+	started := time.Now()
+	err := ping(ctx)
+	latency := float64(time.Since(started).Microseconds()) / 1000
+	result := ComponentHealth{Status: healthUp, LatencyMs: &latency}
+	if err != nil {
+		result.Status = healthDown
+		result.Error = err.Error()
+		if errors.Is(err, context.DeadlineExceeded) {
+			result.Error = "timed out after " + healthCheckTimeout.String()
+		}
+	}
+	return result
+}
+
+// queueHeartbeat is when this process's queue processor last showed it was
+// alive. main starts it where the queue runs; the processor beats on every
+// poll tick, and is busy rather than late while it works through a batch.
+type queueHeartbeat struct {
+	last atomic.Int64 // unix nanoseconds, 0 where the queue doesn't run
+	busy atomic.Bool
+}
+
+var queueLiveness queueHeartbeat
+
+func (h *queueHeartbeat) beat(now time.Time) {
+	h.last.Store(now.UnixNano())
+}
+
+func (h *queueHeartbeat) setBusy(busy bool) {
+	h.busy.Store(busy)
+}
+
+// health reports the processor down once it has missed two ticks.
+func (h *queueHeartbeat) health(now time.Time, pollInterval time.Duration) ComponentHealth {
+	last := h.last.Load()
+	if last == 0 {
+		return ComponentHealth{Status: healthDisabled}
+	}
+	tick := time.Unix(0, last).UTC()
+	result := ComponentHealth{Status: healthUp, LastTick: &tick}
+	if !h.busy.Load() && now.Sub(tick) > 2*pollInterval {
+		result.Status = healthDown
+		result.Error = "no tick since " + tick.Format(time.RFC3339)
+	}
+	return result
+}
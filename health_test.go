@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/patrickmn/go-cache"
+)
+
+func TestHealthReportsComponents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previousConfig := appConfig
+	appConfig.Storage = StorageConfig{}
+	t.Cleanup(func() { appConfig = previousConfig })
+	previous := recipeRepo
+	recipeRepo = newTestRepo(t)
+	t.Cleanup(func() { recipeRepo = previous })
+	router := gin.New()
+	registerRoutes(router)
+
+	check := func(wantCode int) HealthReport {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		var report HealthReport
+		if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+			t.Fatalf("decode %q: %v", w.Body.String(), err)
+		}
+		if w.Code != wantCode {
+			t.Fatalf("status %d, want %d: %+v", w.Code, wantCode, report)
+		}
+		return report
+	}
+
+	// An API-only instance: no queue processor, no R2
+	queueLiveness = queueHeartbeat{}
+	report := check(http.StatusOK)
+	if report.Components["database"].Status != healthUp || report.Components["database"].LatencyMs == nil ||
+		report.Components["storage"].Status != healthDisabled || report.Components["queue"].Status != healthDisabled {
+		t.Fatalf("components = %+v", report.Components)
+	}
+
+	queueLiveness.beat(time.Now().Add(-3 * queueSettings.PollInterval))
+	t.Cleanup(func() { queueLiveness = queueHeartbeat{} })
+	report = check(http.StatusServiceUnavailable)
+	if report.Status != "down" || report.Components["queue"].Status != healthDown || report.Components["queue"].LastTick == nil {
+		t.Fatalf("stale queue: %+v", report)
+	}
+	// A long batch isn't a missed tick
+	queueLiveness.setBusy(true)
+	check(http.StatusOK)
+	queueLiveness.setBusy(false)
+	queueLiveness.beat(time.Now())
+	check(http.StatusOK)
+}
+
+func TestReadinessWaitsForSecretCachesAndSchema(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	previousRepo, previousSecret, previousRecipes := recipeRepo, jwtSecret, recipesCache
+	recipeRepo, jwtSecret, recipesCache = newTestRepo(t), "", cache.New(time.Hour, time.Hour)
+	t.Cleanup(func() {
+		recipeRepo, jwtSecret, recipesCache = previousRepo, previousSecret, previousRecipes
+		recipeCachesWarm.Store(false)
+		schemaChecked.Store(false)
+	})
+	recipeCachesWarm.Store(false)
+	schemaChecked.Store(false)
+	createTestUser(t, recipeRepo, "cook@example.com")
+	if err := recipeRepo.TouchUserActivity("cook@example.com", false); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	router := gin.New()
+	registerRoutes(router)
+
+	serve := func(path string, wantCode int) HealthReport {
+		t.Helper()
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		if w.Code != wantCode {
+			t.Fatalf("%s: status %d, want %d: %s", path, w.Code, wantCode, w.Body.String())
+		}
+		var report HealthReport
+		json.Unmarshal(w.Body.Bytes(), &report)
+		return report
+	}
+
+	// Alive but not ready while starting up
+	serve("/livez", http.StatusOK)
+	report := serve("/readyz", http.StatusServiceUnavailable)
+	if report.Components["database"].Status != healthUp || report.Components["auth"].Status != healthDown ||
+		report.Components["cache"].Status != healthDown {
+		t.Fatalf("starting up: %+v", report.Components)
+	}
+
+	jwtSecret = "test-secret"
+	warmRecipeCaches(context.Background(), recipeRepo)
+	if _, found := recipesCache.Get(recipeListCacheKey("cook@example.com", RecipeFilters{})); !found {
+		t.Fatal("warm-up didn't cache the active user's recipes")
+	}
+	serve("/readyz", http.StatusOK)
+
+	// A database the migrations haven't reached
+	recipeRepo = newTestRepo(t)
+	schemaChecked.Store(false)
+	if err := recipeRepo.db.Exec("ALTER TABLE users DROP COLUMN feed_token_used_at").Error; err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	report = serve("/readyz", http.StatusServiceUnavailable)
+	if database := report.Components["database"]; database.Status != healthDown || !strings.Contains(database.Error, "feed_token_used_at") {
+		t.Fatalf("stale schema: %+v", database)
+	}
+	serve("/livez", http.StatusOK)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// idempotent lets clients retry a mutating request safely: a request sent
+// again with the same Idempotency-Key header gets the first response back,
+// marked Idempotent-Replayed, instead of saving or queuing a second time.
+// Keys are per user and kept for idempotencyKeyTTL. Server errors aren't
+// stored, so retrying after one runs the request again. Requests without
+// the header, or without a valid login, go straight to the handler.
+func idempotent(c *gin.Context) {
+	key := strings.TrimSpace(c.GetHeader("Idempotency-Key"))
+	if key == "" {
+		c.Next()
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+		return
+	}
+	username, err := extractUsernameFromBearer(c.GetHeader("Authorization"))
+	if err != nil {
+		c.Next()
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxImportBytes+1))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request"})
+		return
+	}
+	if len(body) > maxImportBytes {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "request too large"})
+		return
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	stored, err := recipeRepo.BeginIdempotentRequest(username, key, idempotentRequestHash(c.Request, body), idempotencyKeyTTL)
+	switch {
+	case errors.Is(err, errIdempotencyKeyInProgress):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, errIdempotencyKeyReused):
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		log.Printf("Idempotency: lookup failed for %s: %v", username, err)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to check Idempotency-Key"})
+		return
+	case stored != nil:
+		c.Header("Idempotent-Replayed", "true")
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+		return
+	}
+
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+	c.Next()
+
+	// The key is settled with recipeRepo, not requestRepo: a client that
+	// hung up mustn't leave it in progress until it expires
+	status := recorder.Status()
+	if status >= http.StatusInternalServerError {
+		if err := recipeRepo.ReleaseIdempotencyKey(username, key); err != nil {
+			log.Printf("Idempotency: release failed for %s: %v", username, err)
+		}
+		return
+	}
+	response := IdempotentResponse{
+		Status:      status,
+		ContentType: recorder.Header().Get("Content-Type"),
+		Body:        recorder.body.Bytes(),
+	}
+	if err := recipeRepo.FinishIdempotentRequest(username, key, response); err != nil {
+		log.Printf("Idempotency: store failed for %s: %v", username, err)
+	}
+}
+
+// idempotentRequestHash identifies a request, so a key sent again with a
+// different one is refused rather than answered with the wrong response.
+func idempotentRequestHash(r *http.Request, body []byte) string {
+	sum := sha256.New()
+	io.WriteString(sum, r.Method+" "+r.URL.RequestURI()+"\x00")
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// responseRecorder copies what a handler writes so it can be stored.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
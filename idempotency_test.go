@@ -0,0 +1,65 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestIdempotentReplaysFirstResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	jwtSecret = "test-secret"
+	recipeRepo = newTestRepo(t)
+	createTestUser(t, recipeRepo, "cook@example.com")
+	token, err := generateToken("cook@example.com", tokenTTL)
+	if err != nil {
+		t.Fatalf("token: %v", err)
+	}
+
+	calls := 0
+	router := gin.New()
+	router.POST("/save-recipe", idempotent, func(c *gin.Context) {
+		calls++
+		if strings.Contains(c.Query("fail"), "yes") {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "boom"})
+			return
+		}
+		c.JSON(http.StatusAccepted, gin.H{"call": calls})
+	})
+	send := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+token)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	first := send("/save-recipe", "abc", `{"url":"https://example.com/pie"}`)
+	second := send("/save-recipe", "abc", `{"url":"https://example.com/pie"}`)
+	if calls != 1 {
+		t.Fatalf("handler ran %d times, want once", calls)
+	}
+	if second.Code != first.Code || second.Body.String() != first.Body.String() || second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("replay = %d %s, want %d %s", second.Code, second.Body, first.Code, first.Body)
+	}
+
+	if w := send("/save-recipe", "abc", `{"url":"https://example.com/cake"}`); w.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("reused key with another body: status %d, want 422", w.Code)
+	}
+	if send("/save-recipe", "", `{}`); calls != 2 {
+		t.Fatalf("request without a key wasn't run")
+	}
+
+	// Server errors aren't stored, so the retry runs again
+	send("/save-recipe?fail=yes", "def", `{}`)
+	send("/save-recipe?fail=yes", "def", `{}`)
+	if calls != 4 {
+		t.Fatalf("handler ran %d times, want the failed request retried", calls)
+	}
+}
@@ -0,0 +1,259 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// A recipe's photo is chosen from every image the page offers rather than
+// the first meta tag found: og:image and twitter:image, the schema.org
+// recipe image, and the pictures in the recipe card or article. Each is
+// scored on its size and shape, so logos, icons and banner strips lose to
+// an actual photo of the dish. Site icons are never used; with no good
+// candidate the photo is generated instead.
+
+// Source weights for candidates whose size isn't known. The recipe's own
+// image and the share image are picked by the site for this purpose.
+const (
+	imageSourceSchema  = 1.0
+	imageSourceOG      = 0.9
+	imageSourceCard    = 0.8
+	imageSourceTwitter = 0.7
+	imageSourceArticle = 0.4
+)
+
+// minImageSide is the shortest side a known-size image needs to count as a
+// photo; thumbnails below it are heavily penalized.
+const minImageSide = 200
+
+var (
+	// WordPress names resized copies photo-300x200.jpg
+	imageSizeSuffixPattern = regexp.MustCompile(`-(\d{2,4})x(\d{2,4})\.(?i:jpe?g|png|webp|avif)`)
+	nonPhotoImagePattern   = regexp.MustCompile(`(?i)logo|icon|favicon|avatar|gravatar|sprite|placeholder|blank\.|spacer|pixel|badge|emoji|\.svg|\.gif`)
+)
+
+type imageCandidate struct {
+	URL    string
+	Width  int
+	Height int
+	weight float64
+}
+
+// score rates a candidate from 0 up; higher is a more likely dish photo.
+func (c imageCandidate) score() float64 {
+	score := c.weight
+	width, height := c.Width, c.Height
+	if width == 0 || height == 0 {
+		if m := imageSizeSuffixPattern.FindStringSubmatch(c.URL); m != nil {
+			width, _ = strconv.Atoi(m[1])
+			height, _ = strconv.Atoi(m[2])
+		}
+	}
+
+	if width > 0 && height > 0 {
+		// Bigger is better up to roughly 1200px, beyond which any size will do
+		score *= 0.5 + 0.5*min(math.Sqrt(float64(width*height))/1200, 1)
+		if min(width, height) < minImageSide {
+			score *= 0.1
+		}
+		// Landscape and square photos and 2:3 pins are fine; banners and
+		// long infographics aren't
+		if ratio := float64(width) / float64(height); ratio < 0.5 || ratio > 2.2 {
+			score *= 0.3
+		}
+	} else {
+		score *= 0.6
+	}
+
+	if nonPhotoImagePattern.MatchString(c.URL) {
+		score *= 0.05
+	}
+	return score
+}
+
+// extractImageURL returns the best scoring photo on the page, or "" when
+// there is none.
+func extractImageURL(doc *goquery.Document, pageURL string) string {
+	base, err := url.Parse(pageURL)
+	if err != nil {
+		base = nil
+	}
+
+	best, bestScore := "", 0.0
+	for _, candidate := range collectImageCandidates(doc, base) {
+		if score := candidate.score(); score > bestScore {
+			best, bestScore = candidate.URL, score
+		}
+	}
+	return best
+}
+
+// collectImageCandidates lists the page's images once each, keeping the
+// highest weight and the known size when a URL appears more than once.
+func collectImageCandidates(doc *goquery.Document, base *url.URL) []imageCandidate {
+	var candidates []imageCandidate
+	index := map[string]int{}
+	add := func(raw string, width, height int, weight float64) {
+		raw = strings.TrimSpace(raw)
+		if raw == "" || strings.HasPrefix(raw, "data:") {
+			return
+		}
+		resolved := resolveRelativeURL(base, raw)
+		if resolved == "" {
+			return
+		}
+		if i, ok := index[resolved]; ok {
+			existing := &candidates[i]
+			existing.weight = max(existing.weight, weight)
+			if existing.Width == 0 || existing.Height == 0 {
+				existing.Width, existing.Height = width, height
+			}
+			return
+		}
+		index[resolved] = len(candidates)
+		candidates = append(candidates, imageCandidate{URL: resolved, Width: width, Height: height, weight: weight})
+	}
+
+	for _, schema := range jsonLDRecipes(doc) {
+		for _, image := range schemaImages(schema.Image) {
+			add(image.URL, image.Width, image.Height, imageSourceSchema)
+		}
+	}
+	doc.Find(`[itemtype*="schema.org/Recipe"] [itemprop="image"]`).Each(func(_ int, s *goquery.Selection) {
+		add(microdataValue(s), 0, 0, imageSourceSchema)
+	})
+
+	ogWidth := atoiAttr(doc.Find(`meta[property="og:image:width"]`).First(), "content")
+	ogHeight := atoiAttr(doc.Find(`meta[property="og:image:height"]`).First(), "content")
+	doc.Find(`meta[property="og:image"], meta[property="og:image:url"], meta[property="og:image:secure_url"]`).Each(func(i int, s *goquery.Selection) {
+		// og:image:width/height describe the first image
+		if i == 0 {
+			add(s.AttrOr("content", ""), ogWidth, ogHeight, imageSourceOG)
+		} else {
+			add(s.AttrOr("content", ""), 0, 0, imageSourceOG)
+		}
+	})
+	doc.Find(`meta[name="twitter:image"], meta[name="twitter:image:src"], meta[property="twitter:image"]`).Each(func(_ int, s *goquery.Selection) {
+		add(s.AttrOr("content", ""), 0, 0, imageSourceTwitter)
+	})
+
+	addImg := func(weight float64) func(int, *goquery.Selection) {
+		return func(_ int, s *goquery.Selection) {
+			src, width := largestSrcsetImage(s.AttrOr("srcset", s.AttrOr("data-srcset", "")))
+			height := 0
+			if src == "" {
+				for _, attr := range []string{"data-src", "data-lazy-src", "data-original", "src"} {
+					if src = strings.TrimSpace(s.AttrOr(attr, "")); src != "" && !strings.HasPrefix(src, "data:") {
+						break
+					}
+				}
+			}
+			if w, h := atoiAttr(s, "width"), atoiAttr(s, "height"); w > 0 && h > 0 {
+				if width > w {
+					// srcset picked a larger copy of the same picture
+					height = h * width / w
+				} else {
+					width, height = w, h
+				}
+			} else {
+				width = 0
+			}
+			add(src, width, height, weight)
+		}
+	}
+	doc.Find(recipeCardSelectors).Find("img").Each(addImg(imageSourceCard))
+	doc.Find("article img, main img, .entry-content img, .post-content img").Each(addImg(imageSourceArticle))
+	return candidates
+}
+
+type schemaImage struct {
+	URL    string
+	Width  int
+	Height int
+}
+
+// schemaImages reads a schema.org image value: a URL, an ImageObject, or a
+// list of either.
+func schemaImages(raw json.RawMessage) []schemaImage {
+	if len(raw) == 0 {
+		return nil
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return nil
+	}
+
+	var images []schemaImage
+	var walk func(any, int)
+	walk = func(v any, depth int) {
+		if depth > 2 {
+			return
+		}
+		switch t := v.(type) {
+		case string:
+			images = append(images, schemaImage{URL: t})
+		case []any:
+			for _, item := range t {
+				walk(item, depth+1)
+			}
+		case map[string]any:
+			image := schemaImage{Width: schemaDimension(t["width"]), Height: schemaDimension(t["height"])}
+			for _, key := range []string{"url", "contentUrl", "@id"} {
+				if s, ok := t[key].(string); ok && strings.TrimSpace(s) != "" {
+					image.URL = s
+					break
+				}
+			}
+			if image.URL != "" {
+				images = append(images, image)
+			}
+		}
+	}
+	walk(value, 0)
+	return images
+}
+
+// schemaDimension reads a width or height given as a number, a string
+// ("1200" or "1200px") or a QuantitativeValue.
+func schemaDimension(v any) int {
+	switch t := v.(type) {
+	case float64:
+		return int(t)
+	case string:
+		return parseLeadingInt(t)
+	case map[string]any:
+		return schemaDimension(t["value"])
+	}
+	return 0
+}
+
+// largestSrcsetImage returns the widest "url 800w" entry of a srcset and
+// its width, or "" when the srcset has no width descriptors.
+func largestSrcsetImage(srcset string) (string, int) {
+	best, bestWidth := "", 0
+	for _, entry := range strings.Split(srcset, ",") {
+		fields := strings.Fields(entry)
+		if len(fields) != 2 || !strings.HasSuffix(fields[1], "w") {
+			continue
+		}
+		width, err := strconv.Atoi(strings.TrimSuffix(fields[1], "w"))
+		if err == nil && width > bestWidth {
+			best, bestWidth = fields[0], width
+		}
+	}
+	return best, bestWidth
+}
+
+func atoiAttr(s *goquery.Selection, attr string) int {
+	n, err := strconv.Atoi(strings.TrimSpace(strings.TrimSuffix(s.AttrOr(attr, ""), "px")))
+	if err != nil {
+		return 0
+	}
+	return n
+}
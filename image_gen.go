@@ -0,0 +1,174 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// Recipes without a usable photo get a generated one. IMAGE_PROVIDER picks
+// who draws it:
+//
+//	ai (default)  the AI providers in AI_PROVIDERS order, failing over
+//	openai        OpenAI's image API (OPENAI_KEY, OPENAI_IMAGE_MODEL)
+//	stability     Stability AI (STABILITY_API_KEY, STABILITY_MODEL: core,
+//	              ultra or an sd3 model such as sd3.5-large)
+//	disabled      no generation at all
+//
+// IMAGE_PLACEHOLDER_URL is used instead when generation is disabled or
+// fails; without it such recipes have no image.
+
+const (
+	imageProviderAI        = "ai"
+	imageProviderOpenAI    = "openai"
+	imageProviderStability = "stability"
+	imageProviderDisabled  = "disabled"
+
+	defaultStabilityModel = "core"
+	stabilityAPIBase      = "https://api.stability.ai/v2beta/stable-image/generate/"
+)
+
+var errImageGenerationDisabled = errors.New("image generation is disabled")
+
+// ImageGenerator draws a photo from a prompt. Every AIProvider is one.
+type ImageGenerator interface {
+	Name() string
+	GenerateImage(prompt string) (GeneratedImage, error)
+}
+
+// imageGeneratorFor is the configured image generator, with ai (already
+// metered to the user, when it is) as the default. A separate generator is
+// metered to the same user and page.
+func imageGeneratorFor(ai AIProvider) (ImageGenerator, error) {
+	var generator ImageGenerator
+	switch name := appConfig.AI.ImageProvider; name {
+	case imageProviderAI:
+		return ai, nil
+	case imageProviderDisabled:
+		return nil, errImageGenerationDisabled
+	case imageProviderOpenAI:
+		generator = newOpenAIProvider(appConfig.AI.OpenAIKey, aiModelFor(aiProviderOpenAI))
+	case imageProviderStability:
+		key := appConfig.AI.StabilityKey
+		if key == "" {
+			return nil, fmt.Errorf("stability: %w", errAINoKey)
+		}
+		generator = newStabilityProvider(key, appConfig.AI.StabilityModel)
+	default:
+		return nil, fmt.Errorf("IMAGE_PROVIDER %q: %w", name, errUnknownAIProvider)
+	}
+	meter, ok := ai.(meteredProvider)
+	if providers, failover := ai.(failoverProvider); failover {
+		meter, ok = providers[0].(meteredProvider)
+	}
+	if ok {
+		return meteredImageGenerator{ImageGenerator: generator, meter: meter}, nil
+	}
+	return generator, nil
+}
+
+// imagePlaceholderURL is the image for recipes that get no photo.
+func imagePlaceholderURL() string {
+	return appConfig.AI.ImagePlaceholderURL
+}
+
+// meteredImageGenerator records generated images against the user of the
+// AI provider it came with, under the generator's own name.
+type meteredImageGenerator struct {
+	ImageGenerator
+	meter meteredProvider
+}
+
+func (m meteredImageGenerator) GenerateImage(prompt string) (GeneratedImage, error) {
+	image, err := m.ImageGenerator.GenerateImage(prompt)
+	if err == nil {
+		m.meter.recordFor(m.Name(), aiOperationGenerateImage, image.Model, Usage{}, 1)
+	}
+	return image, err
+}
+
+// stabilityProvider generates photos with Stability AI's Stable Image API.
+type stabilityProvider struct {
+	apiKey string
+	model  string
+}
+
+func newStabilityProvider(apiKey, model string) *stabilityProvider {
+	if model = strings.ToLower(strings.TrimSpace(model)); model == "" {
+		model = defaultStabilityModel
+	}
+	return &stabilityProvider{apiKey: apiKey, model: model}
+}
+
+func (s *stabilityProvider) Name() string {
+	return imageProviderStability
+}
+
+// endpoint returns the API path for the model and the model form field,
+// which only the sd3 endpoint takes.
+func (s *stabilityProvider) endpoint() (string, string) {
+	switch s.model {
+	case "core", "ultra":
+		return stabilityAPIBase + s.model, ""
+	}
+	return stabilityAPIBase + "sd3", s.model
+}
+
+// meteredModel is the model name prices are kept under.
+func (s *stabilityProvider) meteredModel() string {
+	if s.model == "core" || s.model == "ultra" {
+		return "stable-image-" + s.model
+	}
+	return s.model
+}
+
+func (s *stabilityProvider) GenerateImage(prompt string) (GeneratedImage, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), aiRequestTimeout)
+	defer cancel()
+
+	endpoint, model := s.endpoint()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	fields := map[string]string{"prompt": prompt, "output_format": "jpeg", "aspect_ratio": "1:1"}
+	if model != "" {
+		fields["model"] = model
+	}
+	for name, value := range fields {
+		if err := form.WriteField(name, value); err != nil {
+			return GeneratedImage{}, fmt.Errorf("build request: %w", err)
+		}
+	}
+	if err := form.Close(); err != nil {
+		return GeneratedImage{}, fmt.Errorf("build request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, &body)
+	if err != nil {
+		return GeneratedImage{}, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+s.apiKey)
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return GeneratedImage{}, fmt.Errorf("failed to generate image: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxRecipeImageBytes+1))
+	if err != nil {
+		return GeneratedImage{}, fmt.Errorf("read image: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return GeneratedImage{}, fmt.Errorf("failed to generate image: status %s: %s", resp.Status, truncateText(strings.TrimSpace(string(data)), 500))
+	}
+	if len(data) > maxRecipeImageBytes {
+		return GeneratedImage{}, fmt.Errorf("generated image is too large")
+	}
+	return GeneratedImage{Data: data, ContentType: resp.Header.Get("Content-Type"), Model: s.meteredModel()}, nil
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// recipeDecoder turns an uploaded export file into recipes to import.
+type recipeDecoder func(data []byte) ([]importItem, error)
+
+// importFormats are the formats accepted by POST /import/:format.
+var importFormats = map[string]recipeDecoder{
+	"backup":    decodeBackup,
+	"paprika":   decodePaprikaArchive,
+	"mealie":    decodeMealieExport,
+	"nextcloud": decodeNextcloudExport,
+	"schemaorg": decodeNextcloudExport,
+	"tandoor":   decodeTandoorExport,
+}
+
+// importDocument is a JSON file from an upload plus the photo stored next to
+// it, if any.
+type importDocument struct {
+	Name  string
+	JSON  []byte
+	Photo []byte
+}
+
+const maxArchiveEntryBytes = 20 << 20
+
+// importDocuments returns the JSON documents in an upload. A plain JSON body
+// is a single document. For a zip, every .json entry is a document, nested
+// zips are walked too (Tandoor), and each image is attached to the document
+// in the nearest enclosing directory (Nextcloud's full.jpg, Mealie's images/).
+func importDocuments(data []byte) ([]importDocument, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		return []importDocument{{Name: "upload.json", JSON: trimmed}}, nil
+	}
+
+	docs, images, err := walkImportArchive(data, "")
+	if err != nil {
+		return nil, err
+	}
+	if len(docs) == 0 {
+		return nil, errors.New("no recipe files found in upload")
+	}
+
+	for name, photo := range images {
+		best := -1
+		for i, doc := range docs {
+			dir := path.Dir(doc.Name)
+			if dir != "." && !strings.HasPrefix(name, dir+"/") {
+				continue
+			}
+			if best < 0 || len(dir) > len(path.Dir(docs[best].Name)) {
+				best = i
+			}
+		}
+		// Prefer Nextcloud's full-size image over its thumbnails
+		if best >= 0 && (docs[best].Photo == nil || path.Base(name) == "full.jpg") {
+			docs[best].Photo = photo
+		}
+	}
+	return docs, nil
+}
+
+func walkImportArchive(data []byte, prefix string) ([]importDocument, map[string][]byte, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, errors.New("upload must be a JSON file or a zip archive")
+	}
+
+	docs := make([]importDocument, 0)
+	images := make(map[string][]byte)
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() {
+			continue
+		}
+		name := prefix + file.Name
+		ext := strings.ToLower(path.Ext(file.Name))
+		if ext != ".json" && ext != ".zip" && !isImportImageExt(ext) {
+			continue
+		}
+
+		content, err := readArchiveEntry(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s: %w", name, err)
+		}
+
+		switch {
+		case ext == ".json":
+			docs = append(docs, importDocument{Name: name, JSON: content})
+		case ext == ".zip":
+			nestedDocs, nestedImages, err := walkImportArchive(content, name+"/")
+			if err != nil {
+				return nil, nil, fmt.Errorf("%s: %w", name, err)
+			}
+			docs = append(docs, nestedDocs...)
+			for k, v := range nestedImages {
+				images[k] = v
+			}
+		default:
+			images[name] = content
+		}
+	}
+	return docs, images, nil
+}
+
+func readArchiveEntry(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	content, err := io.ReadAll(io.LimitReader(rc, maxArchiveEntryBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxArchiveEntryBytes {
+		return nil, errors.New("file is too large")
+	}
+	return content, nil
+}
+
+func isImportImageExt(ext string) bool {
+	switch ext {
+	case ".jpg", ".jpeg", ".png", ".webp", ".gif":
+		return true
+	}
+	return false
+}
+
+// jsonObjects decodes a document that is either one object or an array of
+// objects, returning each object's raw JSON.
+func jsonObjects(data []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var objects []json.RawMessage
+		if err := json.Unmarshal(trimmed, &objects); err != nil {
+			return nil, err
+		}
+		return objects, nil
+	}
+	return []json.RawMessage{trimmed}, nil
+}
+
+// flexStrings reads a JSON value that may be a string, a list of strings, or
+// a list of objects carrying "text" or "name".
+func flexStrings(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single = strings.TrimSpace(single); single != "" {
+			return []string{single}
+		}
+		return nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		var object struct {
+			Text string `json:"text"`
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		}
+		if err := json.Unmarshal(raw, &object); err == nil {
+			for _, s := range []string{object.Text, object.Name, object.URL} {
+				if s = strings.TrimSpace(s); s != "" {
+					return []string{s}
+				}
+			}
+		}
+		return nil
+	}
+
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		values = append(values, flexStrings(item)...)
+	}
+	return values
+}
+
+// flexString is the first value of flexStrings, or a number as text.
+func flexString(raw json.RawMessage) string {
+	if values := flexStrings(raw); len(values) > 0 {
+		return values[0]
+	}
+	var number float64
+	if err := json.Unmarshal(raw, &number); err == nil {
+		return formatAmount(number)
+	}
+	return ""
+}
+
+var isoDurationPattern = regexp.MustCompile(`^P(?:(\d+)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseAnyDurationMinutes reads ISO 8601 durations ("PT1H30M") as used by
+// schema.org, falling back to free-form text ("1 hour 30 minutes").
+func parseAnyDurationMinutes(text string) int {
+	text = strings.TrimSpace(text)
+	match := isoDurationPattern.FindStringSubmatch(strings.ToUpper(text))
+	if match == nil {
+		return parseDurationMinutes(text)
+	}
+
+	minutes := 0.0
+	for i, scale := range []float64{24 * 60, 60, 1, 1.0 / 60} {
+		if match[i+1] == "" {
+			continue
+		}
+		value, err := strconv.ParseFloat(match[i+1], 64)
+		if err != nil {
+			continue
+		}
+		minutes += value * scale
+	}
+	return int(minutes + 0.5)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// mealieRecipe covers the recipe JSON written by Mealie's export and API.
+// Older versions store ingredients as plain strings, newer ones as objects.
+type mealieRecipe struct {
+	Name               string            `json:"name"`
+	Slug               string            `json:"slug"`
+	RecipeYield        json.RawMessage   `json:"recipeYield"`
+	RecipeServings     float64           `json:"recipeServings"`
+	RecipeIngredient   []json.RawMessage `json:"recipeIngredient"`
+	RecipeInstructions []struct {
+		Title string `json:"title"`
+		Text  string `json:"text"`
+	} `json:"recipeInstructions"`
+	PrepTime       string          `json:"prepTime"`
+	PerformTime    string          `json:"performTime"`
+	CookTime       string          `json:"cookTime"`
+	TotalTime      string          `json:"totalTime"`
+	OrgURL         string          `json:"orgURL"`
+	Tags           json.RawMessage `json:"tags"`
+	RecipeCategory json.RawMessage `json:"recipeCategory"`
+	DateAdded      string          `json:"dateAdded"`
+}
+
+type mealieIngredient struct {
+	Title        string   `json:"title"`
+	Note         string   `json:"note"`
+	Display      string   `json:"display"`
+	OriginalText string   `json:"originalText"`
+	Quantity     *float64 `json:"quantity"`
+	Unit         *struct {
+		Name string `json:"name"`
+	} `json:"unit"`
+	Food *struct {
+		Name string `json:"name"`
+	} `json:"food"`
+}
+
+// mealieList is the paginated shape returned by Mealie's /api/recipes.
+type mealieList struct {
+	Items []json.RawMessage `json:"items"`
+}
+
+func (m mealieRecipe) toRecipe() Recipe {
+	recipe := Recipe{
+		Title:       strings.TrimSpace(m.Name),
+		PrepTime:    parseAnyDurationMinutes(m.PrepTime),
+		CookTime:    parseAnyDurationMinutes(m.PerformTime),
+		TotalTime:   parseAnyDurationMinutes(m.TotalTime),
+		Servings:    int(m.RecipeServings),
+		OriginalURL: strings.TrimSpace(m.OrgURL),
+		Category:    "other",
+	}
+	if recipe.CookTime == 0 {
+		recipe.CookTime = parseAnyDurationMinutes(m.CookTime)
+	}
+	if recipe.TotalTime == 0 {
+		recipe.TotalTime = recipe.PrepTime + recipe.CookTime
+	}
+	if recipe.Servings == 0 {
+		recipe.Servings = parseLeadingInt(flexString(m.RecipeYield))
+	}
+	if len(m.DateAdded) >= len("2006-01-02") {
+		recipe.Date = m.DateAdded[:len("2006-01-02")]
+	}
+
+	recipe.Ingredients = make([]string, 0, len(m.RecipeIngredient))
+	recipe.ParsedIngredients = make([]IngredientDetail, 0, len(m.RecipeIngredient))
+	group := ""
+	for _, raw := range m.RecipeIngredient {
+		var line string
+		if err := json.Unmarshal(raw, &line); err == nil {
+			if line = strings.TrimSpace(line); line != "" {
+				recipe.Ingredients = append(recipe.Ingredients, line)
+			}
+			continue
+		}
+
+		var ing mealieIngredient
+		if err := json.Unmarshal(raw, &ing); err != nil {
+			continue
+		}
+		// A title starts a new ingredient section in Mealie
+		if t := strings.TrimSpace(ing.Title); t != "" {
+			group = t
+		}
+		detail := ing.toDetail(group)
+		if detail.Display == "" {
+			continue
+		}
+		recipe.Ingredients = append(recipe.Ingredients, detail.Display)
+		recipe.ParsedIngredients = append(recipe.ParsedIngredients, detail)
+	}
+	if len(recipe.ParsedIngredients) != len(recipe.Ingredients) {
+		recipe.ParsedIngredients = nil
+	}
+
+	sections := make([]InstructionSection, 0)
+	named := false
+	for _, step := range m.RecipeInstructions {
+		text := strings.TrimSpace(step.Text)
+		if text == "" {
+			continue
+		}
+		if title := strings.TrimSpace(step.Title); title != "" || len(sections) == 0 {
+			named = named || title != ""
+			sections = append(sections, InstructionSection{Name: title})
+		}
+		last := &sections[len(sections)-1]
+		last.Steps = append(last.Steps, InstructionStep{Text: text})
+	}
+	recipe.Instructions = flattenInstructionSections(sections)
+	if named {
+		recipe.InstructionSections = sections
+	}
+
+	categories := flexStrings(m.RecipeCategory)
+	for _, category := range categories {
+		if norm, ok := normalizeCategoryStrict(category); ok {
+			recipe.Category = norm
+			break
+		}
+	}
+	recipe.Tags = normalizeTerms(append(categories, flexStrings(m.Tags)...))
+
+	return recipe
+}
+
+func (ing mealieIngredient) toDetail(group string) IngredientDetail {
+	detail := IngredientDetail{Group: group}
+	if ing.Food == nil || strings.TrimSpace(ing.Food.Name) == "" {
+		// Unparsed ingredient: the note or original text is the whole line
+		text := strings.TrimSpace(ing.Display)
+		if text == "" {
+			text = strings.TrimSpace(ing.OriginalText)
+		}
+		if text == "" {
+			text = strings.TrimSpace(ing.Note)
+		}
+		detail.Description = text
+		detail.Display = text
+		return detail
+	}
+
+	detail.Description = strings.TrimSpace(ing.Food.Name)
+	if note := strings.TrimSpace(ing.Note); note != "" {
+		detail.Description += ", " + note
+	}
+	if ing.Unit != nil {
+		detail.Unit = strings.TrimSpace(ing.Unit.Name)
+	}
+	if ing.Quantity != nil && *ing.Quantity > 0 {
+		amount := *ing.Quantity
+		detail.AmountValue = &amount
+		detail.AmountText = formatAmount(amount)
+	}
+	detail.Display = composeDisplayWithUnit(detail.AmountText, detail.Unit, detail.Description)
+	return detail
+}
+
+// decodeMealieExport reads a Mealie export zip, a single recipe JSON, a list
+// of recipes, or an /api/recipes page.
+func decodeMealieExport(data []byte) ([]importItem, error) {
+	docs, err := importDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]importItem, 0, len(docs))
+	for _, doc := range docs {
+		objects, err := jsonObjects(doc.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid json: %w", doc.Name, err)
+		}
+		if len(objects) == 1 {
+			var list mealieList
+			if err := json.Unmarshal(objects[0], &list); err == nil && len(list.Items) > 0 {
+				objects = list.Items
+			}
+		}
+		for _, object := range objects {
+			var m mealieRecipe
+			if err := json.Unmarshal(object, &m); err != nil {
+				// Full Mealie backups also contain non-recipe JSON
+				continue
+			}
+			if strings.TrimSpace(m.Name) == "" {
+				continue
+			}
+			slug := slugify(m.Slug)
+			if slug == "" {
+				slug = slugify(m.Name)
+			}
+			item := importItem{Slug: slug, Recipe: m.toRecipe()}
+			if len(objects) == 1 {
+				item.Photo = doc.Photo
+			}
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no Mealie recipes found")
+	}
+	return items, nil
+}
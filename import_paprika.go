@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// paprikaRecipe is one entry of a .paprikarecipes export. The archive is a
+// zip of .paprikarecipe files, each a gzipped JSON document.
+type paprikaRecipe struct {
+	UID         string   `json:"uid"`
+	Name        string   `json:"name"`
+	Ingredients string   `json:"ingredients"`
+	Directions  string   `json:"directions"`
+	Servings    string   `json:"servings"`
+	PrepTime    string   `json:"prep_time"`
+	CookTime    string   `json:"cook_time"`
+	TotalTime   string   `json:"total_time"`
+	Categories  []string `json:"categories"`
+	SourceURL   string   `json:"source_url"`
+	ImageURL    string   `json:"image_url"`
+	PhotoData   string   `json:"photo_data"`
+	OnFavorites int      `json:"on_favorites"`
+	Created     string   `json:"created"`
+}
+
+// maxPaprikaEntryBytes caps a single decompressed recipe, photo included.
+const maxPaprikaEntryBytes = 20 << 20
+
+func decodePaprikaArchive(data []byte) ([]importItem, error) {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("not a .paprikarecipes archive: %w", err)
+	}
+
+	items := make([]importItem, 0, len(archive.File))
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || !strings.HasSuffix(strings.ToLower(file.Name), ".paprikarecipe") {
+			continue
+		}
+		entry, err := readPaprikaEntry(file)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Name, err)
+		}
+		items = append(items, entry.toImportItem())
+	}
+	if len(items) == 0 {
+		return nil, errors.New("archive contains no recipes")
+	}
+	return items, nil
+}
+
+func readPaprikaEntry(file *zip.File) (paprikaRecipe, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return paprikaRecipe{}, err
+	}
+	defer rc.Close()
+
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return paprikaRecipe{}, fmt.Errorf("decompress: %w", err)
+	}
+	defer gz.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(gz, maxPaprikaEntryBytes+1))
+	if err != nil {
+		return paprikaRecipe{}, fmt.Errorf("decompress: %w", err)
+	}
+	if len(raw) > maxPaprikaEntryBytes {
+		return paprikaRecipe{}, errors.New("recipe is too large")
+	}
+
+	var recipe paprikaRecipe
+	if err := json.Unmarshal(raw, &recipe); err != nil {
+		return paprikaRecipe{}, fmt.Errorf("invalid recipe json: %w", err)
+	}
+	return recipe, nil
+}
+
+func (p paprikaRecipe) toImportItem() importItem {
+	recipe := Recipe{
+		Title:        strings.TrimSpace(p.Name),
+		Ingredients:  splitLines(p.Ingredients),
+		Instructions: splitLines(p.Directions),
+		Servings:     parseLeadingInt(p.Servings),
+		PrepTime:     parseDurationMinutes(p.PrepTime),
+		CookTime:     parseDurationMinutes(p.CookTime),
+		TotalTime:    parseDurationMinutes(p.TotalTime),
+		OriginalURL:  strings.TrimSpace(p.SourceURL),
+		Image:        strings.TrimSpace(p.ImageURL),
+		IsFavorite:   p.OnFavorites != 0,
+		Category:     "other",
+		Tags:         normalizeTerms(p.Categories),
+	}
+	if recipe.TotalTime == 0 {
+		recipe.TotalTime = recipe.PrepTime + recipe.CookTime
+	}
+	if len(p.Created) >= len("2006-01-02") {
+		recipe.Date = p.Created[:len("2006-01-02")]
+	}
+	// Paprika categories are free-form; use the first one we recognise
+	for _, category := range p.Categories {
+		if norm, ok := normalizeCategoryStrict(category); ok {
+			recipe.Category = norm
+			break
+		}
+	}
+
+	item := importItem{Slug: slugify(recipe.Title), Recipe: recipe}
+	if p.PhotoData != "" {
+		if photo, err := base64.StdEncoding.DecodeString(p.PhotoData); err == nil {
+			item.Photo = photo
+		}
+	}
+	return item
+}
+
+// splitLines splits a multi-line text block into trimmed, non-empty lines.
+func splitLines(text string) []string {
+	lines := make([]string, 0)
+	for _, line := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+var leadingIntPattern = regexp.MustCompile(`\d+`)
+
+// parseLeadingInt returns the first number in text, e.g. "Serves 4-6" -> 4.
+func parseLeadingInt(text string) int {
+	match := leadingIntPattern.FindString(text)
+	if match == "" {
+		return 0
+	}
+	n, _ := strconv.Atoi(match)
+	return n
+}
+
+var durationPartPattern = regexp.MustCompile(`(?i)(\d+(?:\.\d+)?)\s*(h|hr|hrs|hour|hours|m|min|mins|minute|minutes)\b`)
+
+// parseDurationMinutes reads free-form times such as "1 hr 30 mins", "45 min"
+// or a bare "20" (taken as minutes).
+func parseDurationMinutes(text string) int {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return 0
+	}
+	if n, err := strconv.Atoi(text); err == nil {
+		return n
+	}
+
+	total := 0.0
+	for _, part := range durationPartPattern.FindAllStringSubmatch(text, -1) {
+		value, err := strconv.ParseFloat(part[1], 64)
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(strings.ToLower(part[2]), "h") {
+			value *= 60
+		}
+		total += value
+	}
+	return int(total + 0.5)
+}
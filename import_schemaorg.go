@@ -0,0 +1,188 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// schemaRecipe is a schema.org Recipe, as written by Nextcloud Cookbook and
+// embedded as JSON-LD by most recipe sites. Many properties come in several
+// shapes, so they are decoded lazily.
+type schemaRecipe struct {
+	Type               json.RawMessage `json:"@type"`
+	Name               string          `json:"name"`
+	RecipeIngredient   json.RawMessage `json:"recipeIngredient"`
+	Ingredients        json.RawMessage `json:"ingredients"`
+	RecipeInstructions json.RawMessage `json:"recipeInstructions"`
+	RecipeYield        json.RawMessage `json:"recipeYield"`
+	PrepTime           string          `json:"prepTime"`
+	CookTime           string          `json:"cookTime"`
+	TotalTime          string          `json:"totalTime"`
+	RecipeCategory     json.RawMessage `json:"recipeCategory"`
+	Keywords           json.RawMessage `json:"keywords"`
+	URL                string          `json:"url"`
+	Image              json.RawMessage `json:"image"`
+	DateCreated        string          `json:"dateCreated"`
+	Nutrition          json.RawMessage `json:"nutrition"`
+}
+
+// schemaInstruction is a HowToStep or HowToSection.
+type schemaInstruction struct {
+	Type            string          `json:"@type"`
+	Name            string          `json:"name"`
+	Text            string          `json:"text"`
+	ItemListElement json.RawMessage `json:"itemListElement"`
+}
+
+func (s schemaRecipe) isRecipe() bool {
+	for _, t := range flexStrings(s.Type) {
+		if strings.EqualFold(t, "Recipe") {
+			return true
+		}
+	}
+	// Nextcloud files don't always carry @type
+	return len(s.Type) == 0 && strings.TrimSpace(s.Name) != ""
+}
+
+func (s schemaRecipe) toRecipe() Recipe {
+	recipe := Recipe{
+		Title:       strings.TrimSpace(s.Name),
+		PrepTime:    parseAnyDurationMinutes(s.PrepTime),
+		CookTime:    parseAnyDurationMinutes(s.CookTime),
+		TotalTime:   parseAnyDurationMinutes(s.TotalTime),
+		Servings:    parseLeadingInt(flexString(s.RecipeYield)),
+		OriginalURL: strings.TrimSpace(s.URL),
+		Image:       flexString(s.Image),
+		Category:    "other",
+		Nutrition:   schemaNutrition(s.Nutrition),
+	}
+	if recipe.TotalTime == 0 {
+		recipe.TotalTime = recipe.PrepTime + recipe.CookTime
+	}
+	if len(s.DateCreated) >= len("2006-01-02") {
+		recipe.Date = s.DateCreated[:len("2006-01-02")]
+	}
+
+	ingredients := s.RecipeIngredient
+	if len(ingredients) == 0 {
+		ingredients = s.Ingredients
+	}
+	recipe.Ingredients = flexStrings(ingredients)
+	recipe.Instructions, recipe.InstructionSections = schemaInstructions(s.RecipeInstructions)
+
+	categories := flexStrings(s.RecipeCategory)
+	for _, category := range categories {
+		if norm, ok := normalizeCategoryStrict(category); ok {
+			recipe.Category = norm
+			break
+		}
+	}
+	tags := append([]string{}, categories...)
+	for _, keyword := range flexStrings(s.Keywords) {
+		tags = append(tags, strings.Split(keyword, ",")...)
+	}
+	recipe.Tags = normalizeTerms(tags)
+
+	return recipe
+}
+
+// schemaInstructions flattens recipeInstructions, which may be one block of
+// text, a list of strings, HowToSteps, or HowToSections of HowToSteps. Named
+// sections are returned as well so they survive the import.
+func schemaInstructions(raw json.RawMessage) ([]string, []InstructionSection) {
+	if len(raw) == 0 {
+		return []string{}, nil
+	}
+
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return splitLines(text), nil
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return flexStrings(raw), nil
+	}
+
+	sections := make([]InstructionSection, 0)
+	hasNamedSection := false
+	current := InstructionSection{}
+	for _, item := range items {
+		var step schemaInstruction
+		if err := json.Unmarshal(item, &step); err != nil {
+			// Plain string step
+			for _, s := range flexStrings(item) {
+				current.Steps = append(current.Steps, InstructionStep{Text: s})
+			}
+			continue
+		}
+		if strings.EqualFold(step.Type, "HowToSection") {
+			if len(current.Steps) > 0 {
+				sections = append(sections, current)
+			}
+			hasNamedSection = true
+			steps, _ := schemaInstructions(step.ItemListElement)
+			section := InstructionSection{Name: strings.TrimSpace(step.Name)}
+			for _, s := range steps {
+				section.Steps = append(section.Steps, InstructionStep{Text: s})
+			}
+			sections = append(sections, section)
+			current = InstructionSection{}
+			continue
+		}
+		if t := strings.TrimSpace(step.Text); t != "" {
+			current.Steps = append(current.Steps, InstructionStep{Text: t})
+		} else if n := strings.TrimSpace(step.Name); n != "" {
+			current.Steps = append(current.Steps, InstructionStep{Text: n})
+		}
+	}
+	if len(current.Steps) > 0 {
+		sections = append(sections, current)
+	}
+
+	flat := flattenInstructionSections(sections)
+	if !hasNamedSection {
+		return flat, nil
+	}
+	return flat, sections
+}
+
+// decodeNextcloudExport reads Nextcloud Cookbook exports (a zip of one folder
+// per recipe with recipe.json and full.jpg) or any schema.org Recipe JSON.
+func decodeNextcloudExport(data []byte) ([]importItem, error) {
+	docs, err := importDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]importItem, 0, len(docs))
+	for _, doc := range docs {
+		objects, err := jsonObjects(doc.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid json: %w", doc.Name, err)
+		}
+		for _, object := range objects {
+			var schema schemaRecipe
+			if err := json.Unmarshal(object, &schema); err != nil {
+				continue
+			}
+			if !schema.isRecipe() {
+				continue
+			}
+			recipe := schema.toRecipe()
+			item := importItem{Slug: slugify(recipe.Title), Recipe: recipe}
+			if doc.Photo != nil {
+				item.Photo = doc.Photo
+				// Nextcloud image URLs point at the user's own server
+				item.Recipe.Image = ""
+			}
+			items = append(items, item)
+		}
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no schema.org recipes found")
+	}
+	return items, nil
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// tandoorRecipe is the recipe.json inside each per-recipe zip of a Tandoor
+// "Default" export.
+type tandoorRecipe struct {
+	Name     string `json:"name"`
+	Keywords []struct {
+		Name string `json:"name"`
+	} `json:"keywords"`
+	Steps []struct {
+		Name        string              `json:"name"`
+		Instruction string              `json:"instruction"`
+		Ingredients []tandoorIngredient `json:"ingredients"`
+	} `json:"steps"`
+	WorkingTime int    `json:"working_time"`
+	WaitingTime int    `json:"waiting_time"`
+	Servings    int    `json:"servings"`
+	SourceURL   string `json:"source_url"`
+}
+
+type tandoorIngredient struct {
+	Food *struct {
+		Name string `json:"name"`
+	} `json:"food"`
+	Unit *struct {
+		Name string `json:"name"`
+	} `json:"unit"`
+	Amount   float64 `json:"amount"`
+	Note     string  `json:"note"`
+	IsHeader bool    `json:"is_header"`
+	NoAmount bool    `json:"no_amount"`
+}
+
+func (t tandoorRecipe) toRecipe() Recipe {
+	recipe := Recipe{
+		Title:       strings.TrimSpace(t.Name),
+		PrepTime:    t.WorkingTime,
+		CookTime:    t.WaitingTime,
+		TotalTime:   t.WorkingTime + t.WaitingTime,
+		Servings:    t.Servings,
+		OriginalURL: strings.TrimSpace(t.SourceURL),
+		Category:    "other",
+	}
+
+	recipe.Ingredients = make([]string, 0)
+	recipe.ParsedIngredients = make([]IngredientDetail, 0)
+	sections := make([]InstructionSection, 0, len(t.Steps))
+	named := false
+	for _, step := range t.Steps {
+		group := strings.TrimSpace(step.Name)
+		for _, ing := range step.Ingredients {
+			if ing.IsHeader {
+				// Header rows carry the section title in the note
+				group = strings.TrimSpace(ing.Note)
+				continue
+			}
+			detail, ok := ing.toDetail(group)
+			if !ok {
+				continue
+			}
+			recipe.Ingredients = append(recipe.Ingredients, detail.Display)
+			recipe.ParsedIngredients = append(recipe.ParsedIngredients, detail)
+		}
+
+		section := InstructionSection{Name: strings.TrimSpace(step.Name)}
+		for _, line := range splitLines(step.Instruction) {
+			section.Steps = append(section.Steps, InstructionStep{Text: line})
+		}
+		if len(section.Steps) > 0 {
+			named = named || section.Name != ""
+			sections = append(sections, section)
+		}
+	}
+	recipe.Instructions = flattenInstructionSections(sections)
+	if named {
+		recipe.InstructionSections = sections
+	}
+
+	keywords := make([]string, 0, len(t.Keywords))
+	for _, keyword := range t.Keywords {
+		keywords = append(keywords, keyword.Name)
+		if norm, ok := normalizeCategoryStrict(keyword.Name); ok && recipe.Category == "other" {
+			recipe.Category = norm
+		}
+	}
+	recipe.Tags = normalizeTerms(keywords)
+
+	return recipe
+}
+
+func (ing tandoorIngredient) toDetail(group string) (IngredientDetail, bool) {
+	if ing.Food == nil || strings.TrimSpace(ing.Food.Name) == "" {
+		return IngredientDetail{}, false
+	}
+
+	detail := IngredientDetail{Group: group, Description: strings.TrimSpace(ing.Food.Name)}
+	if note := strings.TrimSpace(ing.Note); note != "" {
+		detail.Description += ", " + note
+	}
+	if !ing.NoAmount && ing.Amount > 0 {
+		amount := ing.Amount
+		detail.AmountValue = &amount
+		detail.AmountText = formatAmount(amount)
+		if ing.Unit != nil {
+			detail.Unit = strings.TrimSpace(ing.Unit.Name)
+		}
+	}
+	detail.Display = composeDisplayWithUnit(detail.AmountText, detail.Unit, detail.Description)
+	return detail, true
+}
+
+// decodeTandoorExport reads a Tandoor export: a zip of per-recipe zips, each
+// holding recipe.json and the recipe image. A bare recipe.json also works.
+func decodeTandoorExport(data []byte) ([]importItem, error) {
+	docs, err := importDocuments(data)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]importItem, 0, len(docs))
+	for _, doc := range docs {
+		var t tandoorRecipe
+		if err := json.Unmarshal(doc.JSON, &t); err != nil {
+			continue
+		}
+		if strings.TrimSpace(t.Name) == "" {
+			continue
+		}
+		recipe := t.toRecipe()
+		items = append(items, importItem{Slug: slugify(recipe.Title), Recipe: recipe, Photo: doc.Photo})
+	}
+	if len(items) == 0 {
+		return nil, errors.New("no Tandoor recipes found")
+	}
+	return items, nil
+}
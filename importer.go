@@ -0,0 +1,229 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+)
+
+const recipeImageHost = "cookingimage.bronson.dev"
+
+// ImportResult reports what an import did, or with dry-run what it would do.
+type ImportResult struct {
+	DryRun  bool             `json:"dryRun,omitempty"`
+	Created []ImportedRecipe `json:"created"`
+	Skipped []ImportedRecipe `json:"skipped"`
+	Failed  []ImportFailure  `json:"failed"`
+}
+
+// ImportedRecipe identifies one recipe in an ImportResult. SourceSlug is set
+// when the slug had to change to avoid a conflict.
+type ImportedRecipe struct {
+	Title      string `json:"title"`
+	Slug       string `json:"slug"`
+	SourceSlug string `json:"sourceSlug,omitempty"`
+}
+
+type ImportFailure struct {
+	Title string `json:"title"`
+	Error string `json:"error"`
+}
+
+// importItem is one recipe decoded from any import format. Photo holds image
+// bytes embedded in the file, if the format has them.
+type importItem struct {
+	Slug   string
+	Recipe Recipe
+	Photo  []byte
+}
+
+// pendingImage is an image to copy into R2 after its recipe has been saved.
+type pendingImage struct {
+	slug      string
+	sourceURL string
+	data      []byte
+}
+
+// slugify turns a title into a URL-safe slug: lowercase letters and digits
+// separated by single dashes.
+func slugify(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(strings.TrimSpace(title)) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			dash = false
+			continue
+		}
+		if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "-")
+}
+
+// decodeBackup accepts either an AccountExport document or a bare array of
+// RecipeModel rows (e.g. dumped straight from the recipes table).
+func decodeBackup(data []byte) ([]importItem, error) {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) == 0 {
+		return nil, errors.New("empty import file")
+	}
+
+	if trimmed[0] == '[' {
+		var models []RecipeModel
+		if err := json.Unmarshal(trimmed, &models); err != nil {
+			return nil, fmt.Errorf("invalid recipe array: %w", err)
+		}
+		items := make([]importItem, 0, len(models))
+		for _, model := range models {
+			recipe, err := model.toRecipe()
+			if err != nil {
+				return nil, fmt.Errorf("recipe %q: %w", model.Slug, err)
+			}
+			items = append(items, importItem{Slug: model.Slug, Recipe: recipe})
+		}
+		return items, nil
+	}
+
+	var export AccountExport
+	if err := json.Unmarshal(trimmed, &export); err != nil {
+		return nil, fmt.Errorf("invalid backup: %w", err)
+	}
+	if export.Format != "" && export.Format != accountExportFormat {
+		return nil, fmt.Errorf("unsupported backup format %q", export.Format)
+	}
+	if export.Version > accountExportVersion {
+		return nil, fmt.Errorf("backup version %d is newer than supported version %d", export.Version, accountExportVersion)
+	}
+	items := make([]importItem, 0, len(export.Recipes))
+	for _, recipe := range export.Recipes {
+		items = append(items, importItem{Slug: recipe.Slug, Recipe: recipe.Recipe})
+	}
+	return items, nil
+}
+
+// isStoredImage reports whether an image URL already lives in our bucket.
+func isStoredImage(imageURL string) bool {
+	parsed, err := url.Parse(imageURL)
+	return err == nil && strings.EqualFold(parsed.Host, recipeImageHost)
+}
+
+// importRecipes restores recipes for a user. Each recipe is handled on its
+// own so one bad entry doesn't abort the rest. Images that aren't in R2 yet
+// are copied there in the background once the recipes are saved.
+func importRecipes(username string, items []importItem, dryRun bool) ImportResult {
+	result := ImportResult{
+		DryRun:  dryRun,
+		Created: []ImportedRecipe{},
+		Skipped: []ImportedRecipe{},
+		Failed:  []ImportFailure{},
+	}
+	images := make([]pendingImage, 0)
+
+	for _, item := range items {
+		recipe := item.Recipe
+		recipe.ID = 0
+		recipe.Title = strings.TrimSpace(recipe.Title)
+		if recipe.Title == "" {
+			result.Failed = append(result.Failed, ImportFailure{Title: item.Slug, Error: "title is required"})
+			continue
+		}
+		recipe.Category = normalizeCategoryOrOther(recipe.Category)
+		recipe.Score = 0
+		recipe.Match = nil
+
+		sourceSlug := slugify(item.Slug)
+		if sourceSlug == "" {
+			sourceSlug = slugify(recipe.Title)
+		}
+
+		slug, isNew, err := recipeRepo.ResolveImportSlug(username, sourceSlug, recipe)
+		if err != nil {
+			log.Printf("Import: failed to resolve %q for %s: %v", recipe.Title, username, err)
+			result.Failed = append(result.Failed, ImportFailure{Title: recipe.Title, Error: "failed to check existing recipes"})
+			continue
+		}
+
+		if isNew && !dryRun {
+			if err := recipeRepo.SaveImportedRecipe(username, slug, recipe); err != nil {
+				log.Printf("Import: failed to save %q for %s: %v", recipe.Title, username, err)
+				result.Failed = append(result.Failed, ImportFailure{Title: recipe.Title, Error: "failed to save recipe"})
+				continue
+			}
+			recipesSavedTotal.WithLabelValues(recipeSourceImported).Inc()
+			if len(item.Photo) > 0 {
+				images = append(images, pendingImage{slug: slug, data: item.Photo})
+			} else if recipe.Image != "" && !isStoredImage(recipe.Image) {
+				images = append(images, pendingImage{slug: slug, sourceURL: recipe.Image})
+			}
+		}
+
+		entry := ImportedRecipe{Title: recipe.Title, Slug: slug}
+		if slug != sourceSlug {
+			entry.SourceSlug = sourceSlug
+		}
+		if isNew {
+			result.Created = append(result.Created, entry)
+		} else {
+			result.Skipped = append(result.Skipped, entry)
+		}
+	}
+
+	if len(images) > 0 {
+		go storeImportedImages(username, images)
+	}
+
+	return result
+}
+
+// storeImportedImages copies imported images into R2 one at a time and points
+// the recipes at them. A failed copy leaves the recipe's original image URL.
+func storeImportedImages(username string, images []pendingImage) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Import: image worker panic for %s: %v", username, r)
+		}
+	}()
+
+	stored := 0
+	for _, image := range images {
+		var imageURL string
+		var err error
+		if len(image.data) > 0 {
+			contentType := http.DetectContentType(image.data)
+			ext := extensionForContentType(contentType)
+			if ext == "" {
+				ext = ".jpg"
+			}
+			key := fmt.Sprintf("images/%s-%d%s", image.slug, time.Now().UnixNano(), ext)
+			imageURL, err = uploadImageToStorage(key, contentType, image.data)
+		} else {
+			imageURL, err = storeImageFromURL(image.sourceURL, image.slug)
+		}
+		if err != nil {
+			log.Printf("Import: failed to store image for %s/%s: %v", username, image.slug, err)
+			continue
+		}
+
+		if err := recipeRepo.UpdateRecipeImage(username, image.slug, imageURL); err != nil {
+			log.Printf("Import: failed to update image for %s/%s: %v", username, image.slug, err)
+			continue
+		}
+		recipeCache.Delete(singleRecipeCacheKey(username, image.slug))
+		stored++
+	}
+
+	if stored > 0 {
+		invalidateUserRecipeCaches(username)
+	}
+	log.Printf("Import: stored %d/%d images for %s", stored, len(images), username)
+}
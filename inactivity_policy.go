@@ -0,0 +1,205 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// InactivityPolicy controls how long-idle accounts are handled. A zero month
+// value disables that stage; warnings must be enabled for any stage to run.
+// It comes from the INACTIVITY_* variables, e.g. INACTIVITY_WARN_MONTHS.
+type InactivityPolicy struct {
+	WarnAfterMonths   int
+	FreezeAfterMonths int
+	PurgeAfterMonths  int
+	// FreezeNotice and PurgeNotice are how long before the stage a
+	// warning must have gone out; unwarned users are left alone
+	FreezeNotice      time.Duration
+	PurgeNotice       time.Duration
+	ExportBeforePurge bool
+	DryRun            bool
+	CheckInterval     time.Duration
+}
+
+func (p InactivityPolicy) Enabled() bool {
+	return p.WarnAfterMonths > 0
+}
+
+func runInactivityPolicy(ctx context.Context, repo *RecipeRepository, policy InactivityPolicy) {
+	if !policy.Enabled() {
+		log.Println("inactivity policy disabled (INACTIVITY_WARN_MONTHS not set)")
+		return
+	}
+
+	log.Printf("inactivity policy started: warn=%dmo freeze=%dmo purge=%dmo dryRun=%t",
+		policy.WarnAfterMonths, policy.FreezeAfterMonths, policy.PurgeAfterMonths, policy.DryRun)
+	safeApplyInactivityPolicy(repo, policy)
+	ticker := time.NewTicker(policy.CheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("inactivity policy stopping")
+			return
+		case <-ticker.C:
+			safeApplyInactivityPolicy(repo, policy)
+		}
+	}
+}
+
+func safeApplyInactivityPolicy(repo *RecipeRepository, policy InactivityPolicy) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("inactivity policy recovered from panic: %v", r)
+		}
+	}()
+
+	applyInactivityPolicy(repo, policy)
+}
+
+func applyInactivityPolicy(repo *RecipeRepository, policy InactivityPolicy) {
+	now := time.Now()
+
+	warnCutoff := now.AddDate(0, -policy.WarnAfterMonths, 0)
+	users, err := repo.UsersToWarnForInactivity(warnCutoff)
+	if err != nil {
+		log.Printf("Inactivity: warn lookup failed: %v", err)
+	}
+	for _, user := range users {
+		warnInactiveUser(repo, policy, user)
+	}
+
+	if policy.FreezeAfterMonths > 0 {
+		freezeCutoff := now.AddDate(0, -policy.FreezeAfterMonths, 0)
+		users, err := repo.UsersToFreezeForInactivity(freezeCutoff, now.Add(-policy.FreezeNotice))
+		if err != nil {
+			log.Printf("Inactivity: freeze lookup failed: %v", err)
+		}
+		for _, user := range users {
+			freezeInactiveUser(repo, policy, user)
+		}
+	}
+
+	if policy.PurgeAfterMonths > 0 {
+		purgeCutoff := now.AddDate(0, -policy.PurgeAfterMonths, 0)
+		users, err := repo.UsersToPurgeForInactivity(purgeCutoff, now.Add(-policy.PurgeNotice))
+		if err != nil {
+			log.Printf("Inactivity: purge lookup failed: %v", err)
+		}
+		for _, user := range users {
+			purgeInactiveUser(repo, policy, user)
+		}
+	}
+}
+
+func auditInactivity(repo *RecipeRepository, user UserModel, action, detail string) {
+	if err := repo.RecordAccountAudit(user.ID, user.Username, action, detail); err != nil {
+		log.Printf("Inactivity: failed to audit %s for %s: %v", action, user.Username, err)
+	}
+}
+
+func warnInactiveUser(repo *RecipeRepository, policy InactivityPolicy, user UserModel) {
+	if policy.DryRun {
+		auditInactivity(repo, user, "dry_run_warning", fmt.Sprintf("inactive for %d months", policy.WarnAfterMonths))
+		return
+	}
+
+	if err := sendInactivityWarningEmail(user.Username, policy.WarnAfterMonths, policy.PurgeAfterMonths); err != nil {
+		auditInactivity(repo, user, "inactivity_warning_failed", err.Error())
+		return
+	}
+	if err := repo.MarkInactivityWarned(user.ID); err != nil {
+		log.Printf("Inactivity: failed to mark %s warned: %v", user.Username, err)
+		return
+	}
+	auditInactivity(repo, user, "inactivity_warning_sent", fmt.Sprintf("inactive for %d months", policy.WarnAfterMonths))
+}
+
+func freezeInactiveUser(repo *RecipeRepository, policy InactivityPolicy, user UserModel) {
+	if policy.DryRun {
+		auditInactivity(repo, user, "dry_run_freeze", fmt.Sprintf("inactive for %d months", policy.FreezeAfterMonths))
+		return
+	}
+
+	if err := repo.FreezeUser(user.ID); err != nil {
+		log.Printf("Inactivity: failed to freeze %s: %v", user.Username, err)
+		return
+	}
+	auditInactivity(repo, user, "account_frozen", fmt.Sprintf("inactive for %d months", policy.FreezeAfterMonths))
+}
+
+func purgeInactiveUser(repo *RecipeRepository, policy InactivityPolicy, user UserModel) {
+	if policy.DryRun {
+		auditInactivity(repo, user, "dry_run_purge", fmt.Sprintf("inactive for %d months", policy.PurgeAfterMonths))
+		return
+	}
+
+	if policy.ExportBeforePurge {
+		key, err := exportInactiveUser(repo, user)
+		if err != nil {
+			// Never delete data we failed to archive
+			auditInactivity(repo, user, "purge_skipped", fmt.Sprintf("export failed: %v", err))
+			return
+		}
+		auditInactivity(repo, user, "account_exported", key)
+	}
+
+	if err := repo.PurgeUser(user.ID); err != nil {
+		auditInactivity(repo, user, "purge_failed", err.Error())
+		return
+	}
+	invalidateUserRecipeCaches(user.Username)
+	auditInactivity(repo, user, "account_purged", fmt.Sprintf("inactive for %d months", policy.PurgeAfterMonths))
+}
+
+// exportInactiveUser uploads a JSON archive of the user's recipes to R2 and returns its key.
+func exportInactiveUser(repo *RecipeRepository, user UserModel) (string, error) {
+	export, err := repo.ExportAccount(user.Username)
+	if err != nil {
+		return "", fmt.Errorf("export account: %w", err)
+	}
+
+	data, err := json.Marshal(export)
+	if err != nil {
+		return "", fmt.Errorf("marshal export: %w", err)
+	}
+
+	s3Client, err := NewCloudflareS3()
+	if err != nil {
+		return "", fmt.Errorf("initialize S3 client: %w", err)
+	}
+
+	key := fmt.Sprintf("exports/inactive/%d-%d.json", user.ID, time.Now().Unix())
+	if err := s3Client.UploadObject(key, "application/json", data); err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// trackUserActivity records authenticated activity at most once per hour per user.
+func trackUserActivity(c *gin.Context) {
+	c.Next()
+
+	header := c.GetHeader("Authorization")
+	if strings.TrimSpace(header) == "" || c.Writer.Status() == http.StatusUnauthorized {
+		return
+	}
+	username, err := extractUsernameFromBearer(header)
+	if err != nil {
+		return
+	}
+	if _, found := activityCache.Get(username); found {
+		return
+	}
+	activityCache.SetDefault(username, struct{}{})
+	if err := recipeRepo.TouchUserActivity(username, false); err != nil {
+		log.Printf("Failed to record activity for %s: %v", username, err)
+	}
+}
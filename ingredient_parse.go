@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Recipes saved before ingredients were parsed, or imported without
+// structured ingredients, only have their raw lines, so they can't be
+// scaled or converted. These turn the lines into IngredientDetail, by rule
+// or with the AI.
+
+// parseIngredientLines parses raw ingredient lines by rule. A line ending
+// in a colon with no amount ("For the sauce:") names the group of the
+// lines after it.
+func parseIngredientLines(lines []string) []IngredientDetail {
+	details := make([]IngredientDetail, 0, len(lines))
+	group := ""
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		value, amountText, rest := parseIngredientString(line)
+		if value == nil && strings.HasSuffix(line, ":") {
+			group = strings.TrimSpace(strings.TrimSuffix(line, ":"))
+			continue
+		}
+		detail := IngredientDetail{AmountValue: value, AmountText: amountText, Description: rest, Group: group}
+		if value != nil {
+			if unit, remain := extractUnitFromDescription(rest); unit != "" {
+				detail.Unit, detail.Description = unit, remain
+			}
+		}
+		detail.Display = composeDisplayWithUnit(detail.AmountText, detail.Unit, detail.Description)
+		details = append(details, detail)
+	}
+	return details
+}
+
+// parsedAmountCount counts the details with a numeric amount.
+func parsedAmountCount(details []IngredientDetail) int {
+	n := 0
+	for _, detail := range details {
+		if detail.AmountValue != nil {
+			n++
+		}
+	}
+	return n
+}
+
+const ingredientParseSystemPrompt = `You split recipe ingredient lines into their parts. For each line, in order, give:
+amountValue: the amount as a number (1 1/2 is 1.5; use 0 when there is none or it is a range),
+amountText: the amount as written ("" when there is none),
+unit: the unit as written, lowercase ("" when there is none),
+description: the rest of the line,
+group: the section the line belongs to, from a heading line such as "For the sauce:" ("" when there is none).
+Heading lines themselves are not ingredients. Reply with only a JSON array of these objects.`
+
+// parseIngredientsWithAI parses raw ingredient lines with ai, for lines
+// the rules get wrong ("a handful of basil", "2 to 3 cups").
+func parseIngredientsWithAI(ctx context.Context, ai AIProvider, lines []string) ([]IngredientDetail, error) {
+	reply, err := ai.Chat(ctx, ingredientParseSystemPrompt, []chatMessage{{Role: "user", Content: strings.Join(lines, "\n")}}, ingredientParseMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := jsonArrayIn(reply.Content)
+	if err != nil {
+		return nil, err
+	}
+	var parsed []IngredientDetail
+	if err := json.Unmarshal(raw, &parsed); err != nil {
+		return nil, fmt.Errorf("decode parsed ingredients: %w", err)
+	}
+
+	details := make([]IngredientDetail, 0, len(parsed))
+	for _, detail := range parsed {
+		detail.Description = strings.TrimSpace(detail.Description)
+		detail.AmountText = strings.TrimSpace(detail.AmountText)
+		detail.Unit = strings.ToLower(strings.TrimSpace(detail.Unit))
+		detail.Group = strings.TrimSpace(detail.Group)
+		if detail.AmountValue != nil && *detail.AmountValue <= 0 {
+			detail.AmountValue = nil
+		}
+		if detail.Description == "" && detail.AmountText == "" {
+			continue
+		}
+		detail.Display = composeDisplayWithUnit(detail.AmountText, detail.Unit, detail.Description)
+		details = append(details, detail)
+	}
+	return details, nil
+}
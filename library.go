@@ -6,19 +6,15 @@ import (
 	"io"
 	"log"
 	"net/http"
-	"os"
 	"time"
 )
 
 func matchImage(title string, imageData []byte) bool {
-
-	openaiKey := os.Getenv("OPENAI_KEY")
-	format := "text"
-	ai := NewClient(openaiKey, "gpt-5-mini", format, false)
+	ai := newAIProvider()
 	// Encode the image data to base64
 	imageBase64 := base64.StdEncoding.EncodeToString(imageData)
 	promptWithImage := fmt.Sprintf(" Image Data (base64): %s ", imageBase64)
-	response, err := ai.ValidateImage(title, promptWithImage)
+	response, err := ai.Validate(title, promptWithImage)
 	if err != nil {
 		log.Println(err.Error())
 	}
@@ -2,22 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"log"
 	"net/url"
-	"os"
+	"strconv"
 	"time"
 
 	mailgun "github.com/mailgun/mailgun-go/v4"
 )
 
 func sendPasswordResetEmail(toEmail, token string) error {
-	domain := os.Getenv("MAILGUN_DOMAIN")
-	apiKey := os.Getenv("MAILGUN_API_KEY")
-	from := os.Getenv("MAILGUN_FROM")
-	resetBase := os.Getenv("PASSWORD_RESET_URL")
-
-	if domain == "" || apiKey == "" || from == "" || resetBase == "" {
+	resetBase := appConfig.Mail.PasswordResetURL
+	if resetBase == "" {
 		return fmt.Errorf("mailgun environment variables are not fully configured")
 	}
 
@@ -26,20 +25,78 @@ func sendPasswordResetEmail(toEmail, token string) error {
 		return err
 	}
 
-	mg := mailgun.NewMailgun(domain, apiKey)
 	body := fmt.Sprintf("Please reset your password by visiting %s", resetURL)
-	message := mg.NewMessage(from, "Password reset request", body, toEmail)
-	message.SetHtml(fmt.Sprintf("<p>Please reset your password by clicking <a href=\"%s\">this link</a>.</p>", resetURL))
+	html := fmt.Sprintf("<p>Please reset your password by clicking <a href=\"%s\">this link</a>.</p>", resetURL)
+	if err := sendMailgunMessage(toEmail, "Password reset request", body, html); err != nil {
+		return err
+	}
+
+	log.Printf("Password reset email sent to %s", toEmail)
+	return nil
+}
+
+func sendInactivityWarningEmail(toEmail string, inactiveMonths, purgeMonths int) error {
+	body := fmt.Sprintf("You haven't used your recipes account in %d months. Sign in to keep your account and saved recipes.", inactiveMonths)
+	if purgeMonths > 0 {
+		body += fmt.Sprintf(" Accounts inactive for %d months are exported and deleted.", purgeMonths)
+	}
+	html := fmt.Sprintf("<p>%s</p>", body)
+
+	if err := sendMailgunMessage(toEmail, "Your recipes account is inactive", body, html); err != nil {
+		return err
+	}
+
+	log.Printf("Inactivity warning email sent to %s", toEmail)
+	return nil
+}
+
+func sendMailgunMessage(toEmail, subject, text, html string) error {
+	mail := appConfig.Mail
+	if !mail.Enabled() {
+		return fmt.Errorf("mailgun environment variables are not fully configured")
+	}
+
+	mg := mailgun.NewMailgun(mail.Domain, mail.APIKey)
+	message := mg.NewMessage(mail.From, subject, text, toEmail)
+	if html != "" {
+		message.SetHtml(html)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	_, _, err = mg.Send(ctx, message)
-	if err != nil {
+	if _, _, err := mg.Send(ctx, message); err != nil {
 		return fmt.Errorf("send mailgun message: %w", err)
 	}
+	return nil
+}
 
-	log.Printf("Password reset email sent to %s", toEmail)
+// verifyMailgunSignature checks a webhook's HMAC-SHA256 of timestamp+token
+// against MAILGUN_WEBHOOK_SIGNING_KEY and rejects stale timestamps.
+func verifyMailgunSignature(timestamp, token, signature string) error {
+	key := appConfig.Mail.WebhookSigningKey
+	if key == "" {
+		return fmt.Errorf("MAILGUN_WEBHOOK_SIGNING_KEY is not configured")
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid timestamp")
+	}
+	age := time.Since(time.Unix(seconds, 0))
+	if age > mailgunSignatureMaxAge || age < -mailgunSignatureMaxAge {
+		return fmt.Errorf("timestamp outside allowed window")
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("invalid signature encoding")
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(timestamp + token))
+	if !hmac.Equal(mac.Sum(nil), expected) {
+		return fmt.Errorf("signature mismatch")
+	}
 	return nil
 }
 
@@ -2,21 +2,67 @@ package main
 
 import (
 	"context"
+	"errors"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"github.com/patrickmn/go-cache"
 	ginprometheus "github.com/zsais/go-gin-prometheus"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 func main() {
 	recipeCache = cache.New(30*24*time.Hour, 1*time.Hour)
 	recipesCache = cache.New(1*time.Hour, 10*time.Minute)
+	activityCache = cache.New(1*time.Hour, 10*time.Minute)
+	inboundTokenCache = cache.New(mailgunSignatureMaxAge, 10*time.Minute)
+	robotsCache = cache.New(robotsCacheTTL, 1*time.Hour)
+	fdcCache = cache.New(fdcCacheTTL, 1*time.Hour)
 
-	db, err := InitDatabase()
+	if err := godotenv.Load(); err != nil {
+		log.Println("Info: No .env file found, using environment variables only")
+	}
+
+	configFile, err := loadConfigFile()
+	if err != nil {
+		log.Fatalf("failed to load config file: %v", err)
+	}
+	if configFile != "" {
+		log.Printf("Config: read %s; environment variables override it", configFile)
+	}
+	if appConfig, err = loadAppConfig(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+
+	mode := runModeFromArgs(os.Args[1:], appConfig.Server.RunMode)
+	log.Printf("Run mode: %s", mode)
+
+	flushErrorReports, err := initErrorReporting(appConfig.Reporting)
+	if err != nil {
+		log.Fatalf("failed to initialize error reporting: %v", err)
+	}
+	defer flushErrorReports()
+
+	shutdownTracing, err := initTracing(context.Background(), appConfig.Reporting)
+	if err != nil {
+		log.Fatalf("failed to initialize tracing: %v", err)
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), tracingFlushTimeout)
+		defer cancel()
+		if err := shutdownTracing(flushCtx); err != nil {
+			log.Printf("error flushing traces: %v", err)
+		}
+	}()
+
+	db, err := InitDatabase(appConfig.Database)
 	if err != nil {
 		log.Fatalf("failed to initialize database: %v", err)
 	}
@@ -31,34 +77,112 @@ func main() {
 	}()
 
 	recipeRepo = NewRecipeRepository(db)
+	if len(os.Args) > 1 && os.Args[1] == startersCommand {
+		if err := runStartersCommand(recipeRepo, os.Stdout, os.Args[2:]); err != nil {
+			log.Fatalf("starters: %v", err)
+		}
+		return
+	}
+	// One-off maintenance runs wherever the queue runs, so a split
+	// deployment doesn't rebuild the index from two processes at once
+	if mode != runModeAPI {
+		if err := recipeRepo.EnsureSearchIndex(); err != nil {
+			log.Printf("failed to build search index: %v", err)
+		}
+		if err := recipeRepo.BackfillRecipeDiets(); err != nil {
+			log.Printf("failed to classify recipe diets: %v", err)
+		}
+	}
 
-	if err := godotenv.Load(); err != nil {
-		log.Println("Info: No .env file found, using environment variables only")
+	initJWTSecret(appConfig.Auth)
+
+	if err := validateAIConfig(); err != nil {
+		log.Fatalf("invalid AI configuration: %v", err)
 	}
 
-	if err := initJWTSecret(); err != nil {
-		log.Fatalf("failed to load JWT secret: %v", err)
+	queueSettings = appConfig.Queue
+	if jobQueue, err = newJobQueue(queueSettings); err != nil {
+		log.Fatalf("invalid queue configuration: %v", err)
 	}
+	defer jobQueue.Close()
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	go runQueueProcessor(ctx, recipeRepo)
+	poolSize := appConfig.Scraper.BrowserPoolSize
+	if poolSize == 0 {
+		poolSize = queueSettings.Concurrency
+	}
+	scraperBrowsers = newBrowserPool(poolSize)
+	defer scraperBrowsers.Close()
+
+	// SIGTERM stops new work; in-flight queue items get the drain timeout
+	// to finish, and are left pending for the next worker if they don't
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if mode == runModeWorker {
+		// Background jobs live with the queue so an API-only instance
+		// alongside doesn't run them a second time
+		go serveWorkerMetrics()
+		go runInactivityPolicy(ctx, recipeRepo, appConfig.Jobs.Inactivity)
+		go runRecipeReprocessor(ctx, recipeRepo)
+		go runRetentionCleanup(ctx, recipeRepo)
+		go runDatabaseBackups(ctx, recipeRepo)
+		jobQueue.Run(ctx, recipeRepo)
+		log.Println("worker stopped")
+		return
+	}
+
+	queueDone := make(chan struct{})
+	if mode == runModeAll {
+		go func() {
+			defer close(queueDone)
+			jobQueue.Run(ctx, recipeRepo)
+		}()
+		go runInactivityPolicy(ctx, recipeRepo, appConfig.Jobs.Inactivity)
+		go runRecipeReprocessor(ctx, recipeRepo)
+		go runRetentionCleanup(ctx, recipeRepo)
+		go runDatabaseBackups(ctx, recipeRepo)
+	} else {
+		close(queueDone)
+	}
+	go warmRecipeCaches(ctx, recipeRepo)
 
 	router := gin.Default()
 	attachMiddleware(router)
 	registerRoutes(router)
 
-	// Get port from environment variable, default to 8080 for local development
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
-	}
+	port := appConfig.Server.Port
 
+	// h2c lets gRPC clients reach the Connect services without TLS
+	server := &http.Server{
+		Addr:    ":" + port,
+		Handler: h2c.NewHandler(router, &http2.Server{}),
+	}
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		<-ctx.Done()
+		log.Println("Shutting down: no longer accepting requests")
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), queueSettings.DrainTimeout)
+		defer cancel()
+		// Open event streams never go idle, so close whatever is left
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			server.Close()
+		}
+	}()
 	log.Printf("Starting server on port %s", port)
-	router.Run(":" + port)
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("server stopped: %v", err)
+	}
+	// ListenAndServe returns as soon as shutdown starts; wait for the
+	// requests and queue items still in flight
+	<-serverDone
+	<-queueDone
+	log.Println("server stopped")
 }
 
 func attachMiddleware(router *gin.Engine) {
+	router.Use(tracingMiddleware())
+	router.Use(reportServerErrors)
 	router.Use(func(c *gin.Context) {
 		if c.Request.URL.Path == "/metrics" {
 			c.Next()
@@ -66,7 +190,8 @@ func attachMiddleware(router *gin.Engine) {
 		}
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, Idempotency-Key, If-None-Match, If-Match")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "ETag")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -75,36 +200,104 @@ func attachMiddleware(router *gin.Engine) {
 
 		c.Next()
 	})
+	router.Use(requestTimeout())
 
 	p := ginprometheus.NewPrometheus("gin")
 	p.Use(router)
+
+	router.Use(trackUserActivity)
 }
 
 func registerRoutes(router *gin.Engine) {
 	router.GET("/", func(c *gin.Context) {
 		c.JSON(200, gin.H{"message": "Pong"})
 	})
+	router.GET("/health", handleHealth)
+	router.GET("/livez", handleLivez)
+	router.GET("/readyz", handleReadyz)
 
 	router.POST("/register", handleRegister)
 	router.POST("/login", handleLogin)
 	router.POST("/password-reset/request", handlePasswordResetRequest)
 	router.POST("/password-reset/confirm", handlePasswordResetConfirm)
 	router.GET("/profile", handleGetProfile)
+	router.GET("/profile/preferences", handleGetPreferences)
+	router.GET("/profile/stats", handleGetProfileStats)
+	router.GET("/export", handleExportAccount)
+	router.POST("/import", idempotent, handleImportBackup)
+	router.POST("/import/:format", idempotent, handleImportFormat)
+	router.PUT("/profile/preferences", handleUpdatePreferences)
+	router.POST("/profile/feed-token", handleRotateFeedToken)
+	router.DELETE("/profile/feed-token", handleRevokeFeedToken)
+	router.PUT("/profile/public-handle", handleSetPublicHandle)
+	router.GET("/profile/usage", handleGetAIUsage)
+	router.GET("/admin/usage", handleAdminAIUsage)
+	router.POST("/admin/parse-ingredients", handleAdminParseIngredients)
+	router.POST("/admin/reprocess-incomplete", handleAdminReprocessIncomplete)
+	router.POST("/admin/backup", handleAdminBackup)
+
+	router.GET("/meal-plan", handleListMealPlan)
+	router.POST("/meal-plan", idempotent, handleAddPlannedMeal)
+	router.DELETE("/meal-plan/:id", handleDeletePlannedMeal)
+	router.POST("/meal-plans/generate", handleGenerateMealPlan)
+	router.GET("/calendar.ics", handleCalendarFeed)
+	router.GET("/feed.xml", handleRecipeFeed)
 
-	router.POST("/save-recipe", handleSaveRecipe)
+	router.POST("/save-recipe", idempotent, handleSaveRecipe)
+	router.POST("/save-recipe/html", idempotent, handleSaveRecipeHTML)
+	router.POST("/save-recipe/pdf", idempotent, handleSaveRecipePDF)
+	router.GET("/queue/events", handleQueueEvents)
+	router.POST("/queue/events/token", handleQueueEventsToken)
+	router.GET("/queue/failed", handleListFailedQueue)
+	router.POST("/queue/:id/retry", handleRetryQueueItem)
+	router.DELETE("/queue/:id", handleCancelQueueItem)
+	router.POST("/inbound/mailgun", handleMailgunInbound)
 	router.GET("/get-recipe/:name", handleGetRecipe)
 	router.DELETE("/recipes/:slug", handleDeleteRecipe)
 
 	// edit recipes
 	router.DELETE("/recipes/id/:id", handleDeleteRecipe)
 	router.PATCH("/recipes/id/:id", handlePatchRecipe)
+	router.PUT("/recipes/id/:id/image", handleUploadRecipeImage)
+	router.GET("/recipes/id/:id/similar", handleSimilarRecipes)
+	router.GET("/recipes/id/:id/export", handleExportRecipe)
+	router.GET("/recipes/id/:id/print", handlePrintRecipe)
+	router.GET("/recipes/id/:id/source", handleRecipeSource)
+	router.POST("/recipes/id/:id/cooked", handleMarkRecipeCooked)
+	router.POST("/recipes/id/:id/nutrition/recalculate", handleRecalculateNutrition)
+	router.POST("/recipes/id/:id/parse-ingredients", handleParseIngredients)
+	router.POST("/recipes/id/:id/ask", handleAskRecipe)
+	router.GET("/recipes/id/:id/ask", handleGetRecipeChat)
+	router.DELETE("/recipes/id/:id/ask", handleClearRecipeChat)
+	router.GET("/recipes/random", handleRandomRecipe)
+	router.POST("/suggest", handleSuggest)
 
 	// edit favorites
 	router.POST("/recipes/id/:id/favorite", handleFavoriteRecipe)
 	router.DELETE("/recipes/id/:id/favorite", handleUnfavoriteRecipe)
 
+	// public cookbook
+	router.POST("/recipes/id/:id/public", handlePublishRecipe)
+	router.DELETE("/recipes/id/:id/public", handleUnpublishRecipe)
+	router.GET("/public/:handle/recipes", handleListPublicRecipes)
+	router.GET("/public/:handle/recipes/:id", handleGetPublicRecipe)
+	router.GET("/public/:handle/recipes/:id/card", handleRecipeCardPage)
+	router.GET("/oembed", handleOEmbed)
+
+	router.GET("/webhooks", handleListWebhooks)
+	router.POST("/webhooks", idempotent, handleCreateWebhook)
+	router.DELETE("/webhooks/:id", handleDeleteWebhook)
+	router.POST("/webhooks/:id/ping", handlePingWebhook)
+
 	router.GET("/get-recipes", handleListRecipes)
 	router.GET("/search-recipes", handleSearchRecipes)
 	router.GET("/categories", handleGetCategories)
 	router.GET("/favorites", handleListFavorites)
+	router.GET("/graphql", handleGraphQL)
+	router.POST("/graphql", handleGraphQL)
+	registerConnectServices(router)
+
+	router.GET("/openapi.json", openAPIHandler(router))
+	router.GET("/docs", handleDocs)
+	router.GET("/docs/assets/*file", handleDocsAsset)
 }
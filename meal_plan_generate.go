@@ -0,0 +1,235 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+)
+
+// A generated meal plan fills each day's meal slots from the user's own
+// recipes, taking categories in turn so one category doesn't fill the week.
+// A recipe only goes in the slots its category suits (categorySlots).
+// Slots left over can be filled with AI suggestions for dishes not in the
+// collection.
+
+// PlanRecipe is a recipe from the collection placed in a generated plan.
+type PlanRecipe struct {
+	ID        uint   `json:"id"`
+	Title     string `json:"title"`
+	Image     string `json:"image,omitempty"`
+	Category  string `json:"category,omitempty"`
+	TotalTime int    `json:"totalTime,omitempty"`
+}
+
+// MealSuggestion is a dish the AI proposed for a slot the collection
+// couldn't fill.
+type MealSuggestion struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Category    string `json:"category,omitempty"`
+	TotalTime   int    `json:"totalTime,omitempty"`
+}
+
+// GeneratedMeal is one slot of a generated plan: a recipe, a suggestion, or
+// neither when nothing fit.
+type GeneratedMeal struct {
+	Meal       string          `json:"meal"`
+	Recipe     *PlanRecipe     `json:"recipe,omitempty"`
+	Suggestion *MealSuggestion `json:"suggestion,omitempty"`
+	// PlannedMealID is set when the plan was saved to the meal plan
+	PlannedMealID uint `json:"plannedMealId,omitempty"`
+}
+
+type GeneratedPlanDay struct {
+	Date  string          `json:"date"`
+	Meals []GeneratedMeal `json:"meals"`
+}
+
+type GeneratedMealPlan struct {
+	Start string             `json:"start"`
+	End   string             `json:"end"`
+	Days  []GeneratedPlanDay `json:"days"`
+	// Unfilled counts the slots with neither a recipe nor a suggestion
+	Unfilled int `json:"unfilled"`
+}
+
+// categorySlots are the meal slots each recipe category suits.
+var categorySlots = map[string][]string{
+	"breakfast": {"breakfast"},
+	"dinner":    {"lunch", "dinner"},
+	"baking":    {"breakfast", "snack"},
+	"other":     {"lunch", "dinner", "snack"},
+}
+
+func recipeFitsSlot(recipe Recipe, slot string) bool {
+	return slices.Contains(categorySlots[normalizeCategoryOrOther(recipe.Category)], slot)
+}
+
+// recipeRotation hands out recipes a category at a time, in the order the
+// categories first appear, so consecutive picks differ in category where
+// the collection allows.
+type recipeRotation struct {
+	categories []string
+	recipes    map[string][]Recipe
+	next       int
+}
+
+func newRecipeRotation(recipes []Recipe) *recipeRotation {
+	rotation := &recipeRotation{recipes: map[string][]Recipe{}}
+	for _, recipe := range recipes {
+		category := strings.ToLower(strings.TrimSpace(recipe.Category))
+		if _, ok := rotation.recipes[category]; !ok {
+			rotation.categories = append(rotation.categories, category)
+		}
+		rotation.recipes[category] = append(rotation.recipes[category], recipe)
+	}
+	return rotation
+}
+
+// take removes and returns the first recipe for slot, starting from the
+// category after the last one used.
+func (r *recipeRotation) take(slot string) (Recipe, bool) {
+	for i := range r.categories {
+		index := (r.next + i) % len(r.categories)
+		category := r.categories[index]
+		for j, recipe := range r.recipes[category] {
+			if !recipeFitsSlot(recipe, slot) {
+				continue
+			}
+			r.recipes[category] = append(r.recipes[category][:j], r.recipes[category][j+1:]...)
+			r.next = index + 1
+			return recipe, true
+		}
+	}
+	return Recipe{}, false
+}
+
+// buildMealPlan lays out days from start with the given slots, filled from
+// recipes (already shuffled) without repeats.
+func buildMealPlan(recipes []Recipe, start time.Time, days int, meals []string) GeneratedMealPlan {
+	rotation := newRecipeRotation(recipes)
+	plan := GeneratedMealPlan{
+		Start: start.Format(planDateLayout),
+		End:   start.AddDate(0, 0, days-1).Format(planDateLayout),
+		Days:  make([]GeneratedPlanDay, 0, days),
+	}
+	for d := 0; d < days; d++ {
+		day := GeneratedPlanDay{Date: start.AddDate(0, 0, d).Format(planDateLayout)}
+		for _, meal := range meals {
+			slot := GeneratedMeal{Meal: meal}
+			if recipe, ok := rotation.take(meal); ok {
+				slot.Recipe = &PlanRecipe{
+					ID:        recipe.ID,
+					Title:     recipe.Title,
+					Image:     recipe.Image,
+					Category:  recipe.Category,
+					TotalTime: recipe.TotalTime,
+				}
+			} else {
+				plan.Unfilled++
+			}
+			day.Meals = append(day.Meals, slot)
+		}
+		plan.Days = append(plan.Days, day)
+	}
+	return plan
+}
+
+// mealPlanGaps returns the empty slots of a plan, in order.
+func mealPlanGaps(plan *GeneratedMealPlan) []*GeneratedMeal {
+	var gaps []*GeneratedMeal
+	for d := range plan.Days {
+		for m := range plan.Days[d].Meals {
+			if meal := &plan.Days[d].Meals[m]; meal.Recipe == nil && meal.Suggestion == nil {
+				gaps = append(gaps, meal)
+			}
+		}
+	}
+	return gaps
+}
+
+// mealSuggestionConstraints are what the AI is told to respect when
+// suggesting dishes.
+type mealSuggestionConstraints struct {
+	MaxTotalTime int
+	Diets        []string
+	Avoid        []string
+	// Known are titles already in the plan or collection, not to repeat
+	Known []string
+}
+
+const mealSuggestionSystemPrompt = `You plan home-cooked meals. Suggest one dish for each numbered meal slot the user lists, all different from each other and from the dishes they already have.
+Reply with only a JSON array, one object per slot in the same order: {"title": string, "description": one short sentence, "category": string, "totalTime": minutes as an integer}.`
+
+// fillMealPlanGaps asks ai for a dish for each empty slot of plan, passing
+// the reply to onDelta as it's written when onDelta isn't nil.
+func fillMealPlanGaps(ctx context.Context, ai AIProvider, plan *GeneratedMealPlan, constraints mealSuggestionConstraints, onDelta func(string)) error {
+	gaps := mealPlanGaps(plan)
+	if len(gaps) == 0 {
+		return nil
+	}
+
+	var b strings.Builder
+	b.WriteString("Meal slots:\n")
+	for i, gap := range gaps {
+		fmt.Fprintf(&b, "%d. %s\n", i+1, gap.Meal)
+	}
+	if constraints.MaxTotalTime > 0 {
+		fmt.Fprintf(&b, "\nEach dish must take at most %d minutes in total.\n", constraints.MaxTotalTime)
+	}
+	if len(constraints.Diets) > 0 {
+		fmt.Fprintf(&b, "\nEvery dish must be %s.\n", strings.Join(constraints.Diets, " and "))
+	}
+	if len(constraints.Avoid) > 0 {
+		fmt.Fprintf(&b, "\nDon't use these ingredients: %s.\n", strings.Join(constraints.Avoid, ", "))
+	}
+	if len(constraints.Known) > 0 {
+		fmt.Fprintf(&b, "\nDishes they already have: %s.\n", strings.Join(constraints.Known, "; "))
+	}
+
+	reply, err := ai.ChatStream(ctx, mealSuggestionSystemPrompt, []chatMessage{{Role: "user", Content: b.String()}}, mealSuggestionMaxTokens, onDelta)
+	if err != nil {
+		return err
+	}
+	suggestions, err := parseMealSuggestions(reply.Content)
+	if err != nil {
+		return err
+	}
+
+	for i, gap := range gaps {
+		if i >= len(suggestions) {
+			break
+		}
+		suggestion := suggestions[i]
+		gap.Suggestion = &suggestion
+		plan.Unfilled--
+	}
+	return nil
+}
+
+// parseMealSuggestions reads the JSON array of a suggestion reply.
+func parseMealSuggestions(content string) ([]MealSuggestion, error) {
+	array, err := jsonArrayIn(content)
+	if err != nil {
+		return nil, err
+	}
+	var raw []MealSuggestion
+	if err := json.Unmarshal(array, &raw); err != nil {
+		return nil, fmt.Errorf("decode suggestions: %w", err)
+	}
+	suggestions := make([]MealSuggestion, 0, len(raw))
+	for _, suggestion := range raw {
+		suggestion.Title = strings.TrimSpace(suggestion.Title)
+		if suggestion.Title == "" {
+			continue
+		}
+		suggestion.Description = strings.TrimSpace(suggestion.Description)
+		suggestion.Category = strings.TrimSpace(suggestion.Category)
+		suggestion.TotalTime = max(suggestion.TotalTime, 0)
+		suggestions = append(suggestions, suggestion)
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Metrics for the queue, scraper and AI calls, next to the per-route HTTP
+// metrics ginprometheus records. All are registered with the default
+// registry, which /metrics serves; a worker serves it on METRICS_PORT.
+//
+// The business metrics below them track what imports produce: a rising
+// share of placeholder results, flawed AI extractions or placeholder
+// images means extraction quality is slipping.
+
+// Outcomes of processing one queue item.
+const (
+	queueOutcomeSaved     = "saved"     // the recipe (or an existing one) was saved
+	queueOutcomeFailed    = "failed"    // finished with a placeholder or none
+	queueOutcomeError     = "error"     // left for a retry, or dead-lettered if out of attempts
+	queueOutcomeDeferred  = "deferred"  // left pending without using an attempt
+	queueOutcomeCancelled = "cancelled" // cancelled by the user while importing
+)
+
+var (
+	queueBatchItems = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "recipes_queue_batch_items",
+		Help:    "Queue items picked up per processor tick.",
+		Buckets: prometheus.LinearBuckets(0, 1, 11),
+	})
+	queueItemsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_queue_items_total",
+		Help: "Queue items processed, by outcome.",
+	}, []string{"outcome"})
+	queueItemDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipes_queue_item_duration_seconds",
+		Help:    "Time to process one queue item, by outcome.",
+		Buckets: []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+	}, []string{"outcome"})
+	scrapeFetchesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_scrape_fetches_total",
+		Help: "Page fetches by method (http or browser) and result (ok or error).",
+	}, []string{"method", "result"})
+	aiRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipes_ai_request_duration_seconds",
+		Help:    "Latency of AI provider calls, by provider, operation and result.",
+		Buckets: []float64{0.5, 1, 2.5, 5, 10, 20, 40, 80, 160},
+	}, []string{"provider", "operation", "result"})
+	aiTokensTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_ai_tokens_total",
+		Help: "Tokens used by AI calls, by provider, model, operation and kind (prompt or completion).",
+	}, []string{"provider", "model", "operation", "kind"})
+)
+
+// Business metrics.
+var (
+	recipesSavedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_saved_total",
+		Help: "Recipes added to an account, by source (scraped, linked to an existing import, or imported from a file).",
+	}, []string{"source"})
+	scrapeResultsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_scrape_results_total",
+		Help: "Finished page imports, by result (complete, incomplete or placeholder).",
+	}, []string{"result"})
+	aiExtractionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_ai_extractions_total",
+		Help: "AI recipe extractions, by result (clean, repaired, flawed or error).",
+	}, []string{"result"})
+	aiExtractionDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipes_ai_extraction_duration_seconds",
+		Help:    "Time to extract a recipe with AI, repair included, by result.",
+		Buckets: []float64{1, 2.5, 5, 10, 20, 40, 80, 160},
+	}, []string{"result"})
+	recipeImagesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_recipe_images_total",
+		Help: "Images given to imported recipes, by source (page, generated or placeholder).",
+	}, []string{"source"})
+	imageUploadsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_image_uploads_total",
+		Help: "Image uploads to R2, by result.",
+	}, []string{"result"})
+	imageUploadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "recipes_image_upload_bytes_total",
+		Help: "Bytes of images uploaded to R2.",
+	})
+	cacheLookupsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_cache_lookups_total",
+		Help: "Cache lookups, by cache and result (hit or miss).",
+	}, []string{"cache", "result"})
+)
+
+// Sources of saved recipes.
+const (
+	recipeSourceScraped  = "scraped"
+	recipeSourceLinked   = "linked"
+	recipeSourceImported = "imported"
+)
+
+// Results of a finished page import.
+const (
+	scrapeResultComplete    = "complete"
+	scrapeResultIncomplete  = "incomplete"  // saved as a minimal placeholder
+	scrapeResultPlaceholder = "placeholder" // the page couldn't be imported
+)
+
+// Results of an AI extraction.
+const (
+	aiExtractionClean    = "clean"
+	aiExtractionRepaired = "repaired"
+	aiExtractionFlawed   = "flawed" // problems left after asking again
+	aiExtractionError    = "error"
+)
+
+// Sources of an imported recipe's image.
+const (
+	recipeImagePage        = "page"
+	recipeImageGenerated   = "generated"
+	recipeImagePlaceholder = "placeholder"
+)
+
+// Caches whose hit ratio is tracked.
+const (
+	cacheRecipeList   = "recipe_list"
+	cacheRecipe       = "recipe"
+	cacheAIExtraction = "ai_extraction"
+	cacheRobots       = "robots"
+	cacheFDC          = "fdc"
+)
+
+func init() {
+	prometheus.MustRegister(queueDepthCollector{})
+}
+
+// queueDepthCollector reports the unprocessed queue items when scraped,
+// split into those ready to run and those waiting out a retry backoff.
+type queueDepthCollector struct{}
+
+var queueDepthDesc = prometheus.NewDesc(
+	"recipes_queue_depth",
+	"Unprocessed queue items, by state (ready or backoff).",
+	[]string{"state"}, nil,
+)
+
+func (queueDepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- queueDepthDesc
+}
+
+func (queueDepthCollector) Collect(ch chan<- prometheus.Metric) {
+	if recipeRepo == nil {
+		return
+	}
+	ready, backoff, err := recipeRepo.QueueDepth()
+	if err != nil {
+		log.Printf("Metrics: queue depth: %v", err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(ready), "ready")
+	ch <- prometheus.MustNewConstMetric(queueDepthDesc, prometheus.GaugeValue, float64(backoff), "backoff")
+}
+
+// observeQueueItem records a processed queue item.
+func observeQueueItem(outcome string, started time.Time) {
+	queueItemsTotal.WithLabelValues(outcome).Inc()
+	queueItemDuration.WithLabelValues(outcome).Observe(time.Since(started).Seconds())
+}
+
+// observeScrapeFetch records a page fetch made with method.
+func observeScrapeFetch(method string, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	scrapeFetchesTotal.WithLabelValues(method, result).Inc()
+}
+
+// observeAIRequest records the latency of an AI call.
+func observeAIRequest(provider, operation string, started time.Time, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+	aiRequestDuration.WithLabelValues(provider, operation, result).Observe(time.Since(started).Seconds())
+}
+
+// observeAIUsage records the tokens an AI call used.
+func observeAIUsage(provider, model, operation string, usage Usage) {
+	if usage.PromptTokens > 0 {
+		aiTokensTotal.WithLabelValues(provider, model, operation, "prompt").Add(float64(usage.PromptTokens))
+	}
+	if usage.CompletionTokens > 0 {
+		aiTokensTotal.WithLabelValues(provider, model, operation, "completion").Add(float64(usage.CompletionTokens))
+	}
+}
+
+// observeAIExtraction records an AI extraction and how long it took.
+func observeAIExtraction(result string, started time.Time) {
+	aiExtractionsTotal.WithLabelValues(result).Inc()
+	aiExtractionDuration.WithLabelValues(result).Observe(time.Since(started).Seconds())
+}
+
+// observeImageUpload records an upload of size bytes to R2.
+func observeImageUpload(size int, err error) {
+	if err != nil {
+		imageUploadsTotal.WithLabelValues("error").Inc()
+		return
+	}
+	imageUploadsTotal.WithLabelValues("ok").Inc()
+	imageUploadBytes.Add(float64(size))
+}
+
+// observeCacheLookup records a lookup in cache.
+func observeCacheLookup(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheLookupsTotal.WithLabelValues(cache, result).Inc()
+}
+
+// serveWorkerMetrics serves /metrics on METRICS_PORT (default 9090) for a
+// worker, which has no API server to carry it.
+func serveWorkerMetrics() {
+	port := appConfig.Server.MetricsPort
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	log.Printf("Serving worker metrics on port %s", port)
+	if err := http.ListenAndServe(":"+port, mux); err != nil {
+		log.Printf("worker metrics server stopped: %v", err)
+	}
+}
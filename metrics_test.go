@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/gorm/logger"
+)
+
+func TestQueueDepthCollector(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	for _, url := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"} {
+		if err := repo.EnqueueRecipe("cook@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	items, err := repo.FetchPendingQueue(10)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	// One waits out a backoff, one is finished
+	if err := repo.MarkQueueItemResult(items[0].ID, errAIExtraction); err != nil {
+		t.Fatalf("mark failure: %v", err)
+	}
+	if err := repo.MarkQueueItemSaved(items[1].ID, "b"); err != nil {
+		t.Fatalf("mark saved: %v", err)
+	}
+
+	previous := recipeRepo
+	recipeRepo = repo
+	t.Cleanup(func() { recipeRepo = previous })
+
+	expected := `
+# HELP recipes_queue_depth Unprocessed queue items, by state (ready or backoff).
+# TYPE recipes_queue_depth gauge
+recipes_queue_depth{state="backoff"} 1
+recipes_queue_depth{state="ready"} 1
+`
+	if err := testutil.CollectAndCompare(queueDepthCollector{}, strings.NewReader(expected)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestObserveQueueItemCountsOutcomes(t *testing.T) {
+	before := testutil.ToFloat64(queueItemsTotal.WithLabelValues(queueOutcomeCancelled))
+	observeQueueItem(queueOutcomeCancelled, time.Now())
+	if got := testutil.ToFloat64(queueItemsTotal.WithLabelValues(queueOutcomeCancelled)); got != before+1 {
+		t.Fatalf("cancelled items = %v, want %v", got, before+1)
+	}
+}
+
+func TestExtractionMetricsTrackQualityAndCacheHits(t *testing.T) {
+	previous := recipeRepo
+	recipeRepo = newTestRepo(t)
+	t.Cleanup(func() { recipeRepo = previous })
+	previousConfig := appConfig
+	appConfig.AI.CacheTTL = time.Hour
+	t.Cleanup(func() { appConfig = previousConfig })
+
+	count := func(vec *prometheus.CounterVec, labels ...string) float64 {
+		return testutil.ToFloat64(vec.WithLabelValues(labels...))
+	}
+	flawed, clean := count(aiExtractionsTotal, aiExtractionFlawed), count(aiExtractionsTotal, aiExtractionClean)
+	hits, misses := count(cacheLookupsTotal, cacheAIExtraction, "hit"), count(cacheLookupsTotal, cacheAIExtraction, "miss")
+
+	// Asked again, the stub gives the same incomplete answer
+	bad := &stubAI{response: Response{Title: "Soup", Category: "dinner"}}
+	if _, err := cachedRecipePrompt(context.Background(), bad, "metrics page", "system", 100); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	good := &stubAI{response: Response{
+		Title:        "Soup",
+		Category:     "dinner",
+		Ingredients:  []string{"1 onion", "1 l stock"},
+		Instructions: []string{"Chop the onion.", "Simmer in the stock."},
+	}}
+	for range 2 {
+		if _, err := cachedRecipePrompt(context.Background(), good, "other metrics page", "system", 100); err != nil {
+			t.Fatalf("extract good: %v", err)
+		}
+	}
+
+	if got := count(aiExtractionsTotal, aiExtractionFlawed) - flawed; got != 1 {
+		t.Errorf("flawed extractions = %v, want 1", got)
+	}
+	if got := count(aiExtractionsTotal, aiExtractionClean) - clean; got != 1 {
+		t.Errorf("clean extractions = %v, want 1", got)
+	}
+	if gotHits, gotMisses := count(cacheLookupsTotal, cacheAIExtraction, "hit")-hits, count(cacheLookupsTotal, cacheAIExtraction, "miss")-misses; gotHits != 1 || gotMisses != 2 {
+		t.Errorf("AI cache hits %v, misses %v; want 1 and 2", gotHits, gotMisses)
+	}
+}
+
+func TestObserveAIUsageCountsTokens(t *testing.T) {
+	prompt := testutil.ToFloat64(aiTokensTotal.WithLabelValues("stub", "stub-model", aiOperationExtractRecipe, "prompt"))
+	observeAIUsage("stub", "stub-model", aiOperationExtractRecipe, Usage{PromptTokens: 120, CompletionTokens: 30})
+	if got := testutil.ToFloat64(aiTokensTotal.WithLabelValues("stub", "stub-model", aiOperationExtractRecipe, "prompt")); got != prompt+120 {
+		t.Fatalf("prompt tokens = %v, want %v", got, prompt+120)
+	}
+	if got := testutil.ToFloat64(aiTokensTotal.WithLabelValues("stub", "stub-model", aiOperationExtractRecipe, "completion")); got < 30 {
+		t.Fatalf("completion tokens = %v, want at least 30", got)
+	}
+}
+
+func TestQueryLoggerTimesRepositoryCalls(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	repo.db.Logger = newQueryLogger(time.Nanosecond).LogMode(logger.Silent)
+
+	slow := testutil.ToFloat64(dbSlowQueriesTotal.WithLabelValues("ListRecipes"))
+	if _, err := repo.ListRecipes("cook@example.com", RecipeFilters{}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got := testutil.ToFloat64(dbSlowQueriesTotal.WithLabelValues("ListRecipes")); got <= slow {
+		t.Fatalf("slow ListRecipes queries = %v, want more than %v", got, slow)
+	}
+	if testutil.CollectAndCount(dbQueryDuration) == 0 {
+		t.Fatal("no query durations recorded")
+	}
+}
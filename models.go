@@ -1,5 +1,10 @@
 package main
 
+import (
+	"strings"
+	"time"
+)
+
 type Recipe struct {
 	ID                uint               `json:"id"`
 	Category          string             `json:"category"`
@@ -8,15 +13,49 @@ type Recipe struct {
 	Image             string             `json:"image"`
 	Ingredients       []string           `json:"ingredients"`
 	ParsedIngredients []IngredientDetail `json:"parsedIngredients,omitempty"`
+	IngredientGroups  []IngredientGroup  `json:"ingredientGroups,omitempty"`
 	Instructions      []string           `json:"instructions"`
-	PrepTime          int                `json:"prepTime"`
-	Servings          int                `json:"servings"`
-	OriginalServings  int                `json:"originalServings,omitempty"`
-	Title             string             `json:"title"`
-	TotalTime         int                `json:"totalTime"`
-	Link              string             `json:"link"`
-	OriginalURL       string             `json:"originalURL"`
-	IsFavorite        bool               `json:"isFavorite"`
+	Equipment         []string           `json:"equipment,omitempty"`
+	Tags              []string           `json:"tags,omitempty"`
+	// Diets are classified from the ingredients: vegetarian, vegan,
+	// gluten-free, dairy-free
+	Diets []string `json:"diets,omitempty"`
+	// Nutrition is estimated per serving and USDANutrition computed from
+	// USDA data on request. NutritionTotal is for the whole recipe at the
+	// servings shown, so it follows ?servings= and ?scale=
+	Nutrition      *Nutrition     `json:"nutrition,omitempty"`
+	USDANutrition  *USDANutrition `json:"usdaNutrition,omitempty"`
+	NutritionTotal *Nutrition     `json:"nutritionTotal,omitempty"`
+	// InstructionSections holds the structured steps; Instructions stays the flat view
+	InstructionSections []InstructionSection `json:"instructionSections,omitempty"`
+	PrepTime            int                  `json:"prepTime"`
+	Servings            int                  `json:"servings"`
+	OriginalServings    int                  `json:"originalServings,omitempty"`
+	Title               string               `json:"title"`
+	TotalTime           int                  `json:"totalTime"`
+	Link                string               `json:"link"`
+	OriginalURL         string               `json:"originalURL"`
+	IsFavorite          bool                 `json:"isFavorite"`
+	IsPublic            bool                 `json:"isPublic"`
+	LastCookedAt        *time.Time           `json:"lastCookedAt,omitempty"`
+	// SourceKey locates the archived page in storage; HasSource tells
+	// clients GET /recipes/id/:id/source will find it
+	SourceKey string `json:"-"`
+	HasSource bool   `json:"hasSource,omitempty"`
+	// SchemaVersion is the schema.json version the AI extracted the recipe
+	// with, 0 when it didn't come from AI extraction
+	SchemaVersion int `json:"schemaVersion,omitempty"`
+	// Score is set on search and similar-recipe results, Match on search only
+	Score float64      `json:"score,omitempty"`
+	Match *SearchMatch `json:"match,omitempty"`
+}
+
+// Nutrition holds estimated nutrition facts.
+type Nutrition struct {
+	Calories     float64 `json:"calories"`
+	ProteinGrams float64 `json:"proteinGrams"`
+	CarbsGrams   float64 `json:"carbsGrams"`
+	FatGrams     float64 `json:"fatGrams"`
 }
 
 type IngredientDetail struct {
@@ -27,4 +66,64 @@ type IngredientDetail struct {
 	Unit            string   `json:"unit,omitempty"`
 	Description     string   `json:"description"`
 	Display         string   `json:"display"`
+	Group           string   `json:"group,omitempty"`
+}
+
+// IngredientGroup is a named ingredient section such as "For the sauce".
+type IngredientGroup struct {
+	Name        string             `json:"name"`
+	Ingredients []IngredientDetail `json:"ingredients"`
+}
+
+// groupIngredients splits parsed ingredients into groups in order of first
+// appearance. It returns nil when no ingredient carries a group.
+func groupIngredients(details []IngredientDetail) []IngredientGroup {
+	grouped := false
+	for _, d := range details {
+		if strings.TrimSpace(d.Group) != "" {
+			grouped = true
+			break
+		}
+	}
+	if !grouped {
+		return nil
+	}
+
+	groups := make([]IngredientGroup, 0)
+	index := make(map[string]int)
+	for _, d := range details {
+		name := strings.TrimSpace(d.Group)
+		i, ok := index[name]
+		if !ok {
+			i = len(groups)
+			index[name] = i
+			groups = append(groups, IngredientGroup{Name: name})
+		}
+		groups[i].Ingredients = append(groups[i].Ingredients, d)
+	}
+	return groups
+}
+
+type InstructionSection struct {
+	Name  string            `json:"name,omitempty"`
+	Steps []InstructionStep `json:"steps"`
+}
+
+type InstructionStep struct {
+	Text            string `json:"text"`
+	DurationMinutes int    `json:"durationMinutes,omitempty"`
+	Image           string `json:"image,omitempty"`
+}
+
+// flattenInstructionSections returns the step texts of all sections in order.
+func flattenInstructionSections(sections []InstructionSection) []string {
+	steps := make([]string, 0)
+	for _, section := range sections {
+		for _, step := range section.Steps {
+			if text := strings.TrimSpace(step.Text); text != "" {
+				steps = append(steps, text)
+			}
+		}
+	}
+	return steps
 }
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// cleanNutrition rounds estimated nutrition to one decimal and drops
+// negative values, returning nil when nothing is known.
+func cleanNutrition(n *Nutrition) *Nutrition {
+	if n == nil {
+		return nil
+	}
+	clean := Nutrition{
+		Calories:     roundNutrition(n.Calories),
+		ProteinGrams: roundNutrition(n.ProteinGrams),
+		CarbsGrams:   roundNutrition(n.CarbsGrams),
+		FatGrams:     roundNutrition(n.FatGrams),
+	}
+	if clean == (Nutrition{}) {
+		return nil
+	}
+	return &clean
+}
+
+func roundNutrition(value float64) float64 {
+	if value <= 0 || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+	return math.Round(value*10) / 10
+}
+
+// scaleNutrition multiplies every value by factor.
+func scaleNutrition(n *Nutrition, factor float64) *Nutrition {
+	if n == nil || factor <= 0 {
+		return nil
+	}
+	return cleanNutrition(&Nutrition{
+		Calories:     n.Calories * factor,
+		ProteinGrams: n.ProteinGrams * factor,
+		CarbsGrams:   n.CarbsGrams * factor,
+		FatGrams:     n.FatGrams * factor,
+	})
+}
+
+var (
+	leadingNumberPattern = regexp.MustCompile(`\d+(?:[.,]\d+)?`)
+	thousandsPattern     = regexp.MustCompile(`^\d{1,3},\d{3}$`)
+)
+
+// schemaNutrition reads a schema.org NutritionInformation, whose values are
+// text such as "320 calories" or "12 g".
+func schemaNutrition(raw json.RawMessage) *Nutrition {
+	if len(raw) == 0 {
+		return nil
+	}
+	var info struct {
+		Calories            json.RawMessage `json:"calories"`
+		ProteinContent      json.RawMessage `json:"proteinContent"`
+		CarbohydrateContent json.RawMessage `json:"carbohydrateContent"`
+		FatContent          json.RawMessage `json:"fatContent"`
+	}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		return nil
+	}
+	return cleanNutrition(&Nutrition{
+		Calories:     leadingNumber(flexString(info.Calories)),
+		ProteinGrams: leadingNumber(flexString(info.ProteinContent)),
+		CarbsGrams:   leadingNumber(flexString(info.CarbohydrateContent)),
+		FatGrams:     leadingNumber(flexString(info.FatContent)),
+	})
+}
+
+func leadingNumber(text string) float64 {
+	match := leadingNumberPattern.FindString(text)
+	if match == "" {
+		return 0
+	}
+	// "1,200 kcal" but "12,5 g"
+	if thousandsPattern.MatchString(match) {
+		match = strings.ReplaceAll(match, ",", "")
+	}
+	value, _ := strconv.ParseFloat(strings.ReplaceAll(match, ",", "."), 64)
+	return value
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"embed"
+	"log"
+	"mime"
+	"net/http"
+	"path"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// The OpenAPI document is assembled at runtime from the router's registered
+// routes, the apiRoutes annotations and the Go request/response types, so a
+// route or field can't be added without it showing up in /openapi.json.
+
+// apiRoute describes one "METHOD /path" route for the spec.
+type apiRoute struct {
+	Summary string
+	Tag     string
+	// Auth marks routes that need a bearer token
+	Auth bool
+	// Idempotent marks routes that accept an Idempotency-Key header
+	Idempotent bool
+	Query      []apiParam
+	// Request and Response are zero values of the JSON body types; nil
+	// means no JSON body
+	Request  any
+	Response any
+	// Status is the success status, 200 when zero
+	Status int
+	// ContentType is the success media type for non-JSON responses
+	ContentType string
+}
+
+type apiParam struct {
+	Name        string
+	Type        string
+	Description string
+}
+
+// apiMessage and apiError are the {"message"} and {"error"} bodies most
+// handlers answer with.
+type apiMessage struct {
+	Message string `json:"message"`
+}
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+var timeReflectType = reflect.TypeOf(time.Time{})
+
+// openAPIHandler serves the spec for router's routes, built on first use.
+func openAPIHandler(router *gin.Engine) gin.HandlerFunc {
+	var (
+		once sync.Once
+		spec map[string]any
+	)
+	return func(c *gin.Context) {
+		once.Do(func() {
+			spec = buildOpenAPISpec(router.Routes())
+		})
+		c.JSON(http.StatusOK, spec)
+	}
+}
+
+func buildOpenAPISpec(routes gin.RoutesInfo) map[string]any {
+	schemas := openAPISchemas{components: map[string]any{}}
+	paths := map[string]map[string]any{}
+	var undocumented []string
+
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		doc, ok := apiRoutes[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+		}
+
+		path, params := openAPIPath(route.Path)
+		for _, query := range doc.Query {
+			param := map[string]any{
+				"name":   query.Name,
+				"in":     "query",
+				"schema": map[string]any{"type": query.Type},
+			}
+			if query.Description != "" {
+				param["description"] = query.Description
+			}
+			params = append(params, param)
+		}
+		if doc.Idempotent {
+			params = append(params, map[string]any{
+				"name":        "Idempotency-Key",
+				"in":          "header",
+				"schema":      map[string]any{"type": "string", "maxLength": maxIdempotencyKeyLength},
+				"description": "retrying with the same key replays the first response instead of repeating the request",
+			})
+		}
+
+		op := map[string]any{
+			"operationId": openAPIOperationID(route.Method, route.Path),
+			"responses":   openAPIResponses(doc, &schemas),
+		}
+		if doc.Summary != "" {
+			op["summary"] = doc.Summary
+		}
+		if doc.Tag != "" {
+			op["tags"] = []string{doc.Tag}
+		}
+		if len(params) > 0 {
+			op["parameters"] = params
+		}
+		if doc.Auth {
+			op["security"] = []map[string][]string{{"bearerAuth": {}}}
+		}
+		if doc.Request != nil {
+			op["requestBody"] = map[string]any{
+				"required": true,
+				"content": map[string]any{
+					"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(doc.Request))},
+				},
+			}
+		}
+
+		if paths[path] == nil {
+			paths[path] = map[string]any{}
+		}
+		paths[path][strings.ToLower(route.Method)] = op
+	}
+
+	if len(undocumented) > 0 {
+		sort.Strings(undocumented)
+		log.Printf("OpenAPI: routes without apiRoutes entries: %s", strings.Join(undocumented, ", "))
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Recipes API",
+			"version": "1.0.0",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+	}
+}
+
+// openAPIPath converts gin's :param and *param segments to {param} and
+// returns the matching path parameters.
+func openAPIPath(ginPath string) (string, []map[string]any) {
+	segments := strings.Split(ginPath, "/")
+	var params []map[string]any
+	for i, segment := range segments {
+		if segment == "" || (segment[0] != ':' && segment[0] != '*') {
+			continue
+		}
+		name := segment[1:]
+		segments[i] = "{" + name + "}"
+		params = append(params, map[string]any{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]any{"type": "string"},
+		})
+	}
+	return strings.Join(segments, "/"), params
+}
+
+// openAPIOperationID turns "GET /recipes/id/:id" into "getRecipesIdId".
+func openAPIOperationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9')
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+func openAPIResponses(doc apiRoute, schemas *openAPISchemas) map[string]any {
+	status := doc.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	success := map[string]any{"description": http.StatusText(status)}
+	switch {
+	case doc.ContentType != "":
+		success["content"] = map[string]any{doc.ContentType: map[string]any{}}
+	case doc.Response != nil:
+		success["content"] = map[string]any{
+			"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(doc.Response))},
+		}
+	}
+
+	errorBody := map[string]any{
+		"description": "Error",
+		"content": map[string]any{
+			"application/json": map[string]any{"schema": schemas.schemaFor(reflect.TypeOf(apiError{}))},
+		},
+	}
+	return map[string]any{strconv.Itoa(status): success, "default": errorBody}
+}
+
+type openAPISchemas struct {
+	components map[string]any
+}
+
+// schemaFor maps a Go type to a JSON schema the way encoding/json would
+// serialize it. Named structs become components referenced by $ref.
+func (s *openAPISchemas) schemaFor(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch {
+	case t == timeReflectType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case t.Kind() == reflect.Struct:
+		if t.Name() == "" {
+			return s.objectSchema(t)
+		}
+		name := t.Name()
+		if _, exists := s.components[name]; !exists {
+			// Placeholder first so self-referencing types terminate
+			s.components[name] = map[string]any{}
+			s.components[name] = s.objectSchema(t)
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + name}
+	case t.Kind() == reflect.Slice && t.Elem().Kind() == reflect.Uint8:
+		return map[string]any{"type": "string", "format": "byte"}
+	case t.Kind() == reflect.Slice || t.Kind() == reflect.Array:
+		return map[string]any{"type": "array", "items": s.schemaFor(t.Elem())}
+	case t.Kind() == reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": s.schemaFor(t.Elem())}
+	case t.Kind() == reflect.String:
+		return map[string]any{"type": "string"}
+	case t.Kind() == reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		return map[string]any{"type": "number"}
+	}
+	return map[string]any{}
+}
+
+func (s *openAPISchemas) objectSchema(t reflect.Type) map[string]any {
+	properties := map[string]any{}
+	var required []string
+	s.collectFields(t, properties, &required)
+
+	schema := map[string]any{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// collectFields follows encoding/json's rules: "-" is skipped, embedded
+// structs without a tag are flattened. binding:"required" marks required
+// request fields.
+func (s *openAPISchemas) collectFields(t reflect.Type, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.collectFields(field.Type, properties, required)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = s.schemaFor(field.Type)
+		if strings.Contains(field.Tag.Get("binding"), "required") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// swaggerUIAssets is the Swagger UI build /docs runs, served from the
+// binary so the docs page loads no third-party scripts.
+//
+//go:embed swagger-ui/swagger-ui.css swagger-ui/swagger-ui-bundle.js swagger-ui/favicon-32x32.png
+var swaggerUIAssets embed.FS
+
+// handleDocs serves Swagger UI for /openapi.json.
+func handleDocs(c *gin.Context) {
+	c.Header("Content-Security-Policy", "default-src 'self'; img-src 'self' data:; style-src 'self' 'unsafe-inline'; script-src 'self' 'unsafe-inline'")
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(docsPageHTML))
+}
+
+// handleDocsAsset serves one of swaggerUIAssets; they change only with the
+// binary, so clients may cache them.
+func handleDocsAsset(c *gin.Context) {
+	name := path.Clean("swagger-ui/" + strings.TrimPrefix(c.Param("file"), "/"))
+	data, err := swaggerUIAssets.ReadFile(name)
+	if err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(name))
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+const docsPageHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Recipes API</title>
+<link rel="icon" type="image/png" href="docs/assets/favicon-32x32.png">
+<link rel="stylesheet" href="docs/assets/swagger-ui.css">
+</head>
+<body>
+<div id="swagger-ui"></div>
+<script src="docs/assets/swagger-ui-bundle.js"></script>
+<script>
+window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+</script>
+</body>
+</html>
+`
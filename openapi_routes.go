@@ -0,0 +1,128 @@
+package main
+
+import "net/http"
+
+var (
+	recipeFilterParams = []apiParam{
+		{"category", "string", "breakfast, dinner, baking or other"},
+		{"favorites", "boolean", "only favorites"},
+		{"tags", "string", "comma-separated tags, all required"},
+		{"diet", "string", "comma-separated diets, all required: vegetarian, vegan, gluten-free, dairy-free"},
+		{"maxTotalTime", "integer", "minutes"},
+		{"minServings", "integer", ""},
+		{"maxServings", "integer", ""},
+		{"exclude", "string", "comma-separated ingredients to leave out"},
+		{"equipment", "string", "\"owned\" keeps recipes the saved equipment can make"},
+		{"ignoreAllergens", "boolean", "skip the saved allergen filter"},
+	}
+	paginationParams = []apiParam{
+		{"limit", "integer", "page size, default 50, max 200"},
+		{"offset", "integer", ""},
+	}
+	scaleParams = []apiParam{
+		{"servings", "integer", "scale to this many servings"},
+		{"units", "string", "metric or imperial"},
+	}
+	feedTokenParams = []apiParam{
+		{"token", "string", "feed token, in place of the bearer token"},
+	}
+	streamParams = []apiParam{
+		{"stream", "boolean", "stream the AI reply as server-sent events"},
+	}
+)
+
+func withParams(groups ...[]apiParam) []apiParam {
+	var params []apiParam
+	for _, group := range groups {
+		params = append(params, group...)
+	}
+	return params
+}
+
+// apiRoutes annotates the routes registered in registerRoutes, keyed by
+// method and gin path. Routes missing here are still listed in the spec
+// and logged when it is built.
+var apiRoutes = map[string]apiRoute{
+	"GET /":                  {Summary: "Health check", Tag: "meta", Response: apiMessage{}},
+	"GET /health":            {Summary: "Database, R2 and queue processor status; 503 when one is down", Tag: "meta", Response: HealthReport{}},
+	"GET /livez":             {Summary: "Liveness probe: 200 while the process can answer", Tag: "meta", Response: map[string]any{}},
+	"GET /readyz":            {Summary: "Readiness probe: 503 until the schema is current, the JWT secret is loaded and caches are warm", Tag: "meta", Response: HealthReport{}},
+	"GET /openapi.json":      {Summary: "This OpenAPI document", Tag: "meta", Response: map[string]any{}},
+	"GET /docs":              {Summary: "Swagger UI", Tag: "meta", ContentType: "text/html"},
+	"GET /docs/assets/*file": {Summary: "Swagger UI assets", Tag: "meta"},
+	"GET /metrics":           {Summary: "Prometheus metrics", Tag: "meta", ContentType: "text/plain"},
+
+	"POST /register":               {Summary: "Create an account", Tag: "auth", Request: credentialsRequest{}, Response: apiMessage{}, Status: http.StatusCreated},
+	"POST /login":                  {Summary: "Exchange credentials for a bearer token", Tag: "auth", Request: credentialsRequest{}, Response: tokenResponse{}},
+	"POST /password-reset/request": {Summary: "Email a password reset link", Tag: "auth", Request: passwordResetRequest{}, Response: apiMessage{}, Status: http.StatusAccepted},
+	"POST /password-reset/confirm": {Summary: "Set a new password with a reset token", Tag: "auth", Request: passwordResetConfirmRequest{}, Response: apiMessage{}},
+
+	"GET /profile":                               {Summary: "Current account", Tag: "profile", Auth: true, Response: profileResponse{}},
+	"GET /profile/preferences":                   {Summary: "Saved equipment and allergens", Tag: "profile", Auth: true, Response: UserPreferences{}},
+	"GET /profile/stats":                         {Summary: "Dashboard numbers: recipes, favorites, categories, recipes added per month, import success rate", Tag: "profile", Auth: true, Response: ProfileStats{}},
+	"PUT /profile/preferences":                   {Summary: "Update saved preferences; fields left out keep their saved value", Tag: "profile", Auth: true, Request: PreferencesUpdate{}, Response: UserPreferences{}},
+	"POST /profile/feed-token":                   {Summary: "Issue a feed token, invalidating the previous one", Tag: "profile", Auth: true, Response: feedTokenResponse{}},
+	"DELETE /profile/feed-token":                 {Summary: "Revoke the feed token", Tag: "profile", Auth: true, Response: apiMessage{}},
+	"PUT /profile/public-handle":                 {Summary: "Set or clear the public cookbook handle", Tag: "profile", Auth: true, Request: publicHandleRequest{}, Response: map[string]string{}},
+	"GET /profile/usage":                         {Summary: "AI calls, tokens and estimated cost for this account", Tag: "profile", Auth: true, Query: []apiParam{{"days", "integer", "Days to cover (default 30)"}}, Response: aiUsageResponse{}},
+	"GET /admin/usage":                           {Summary: "AI usage of every account, costliest users and pages first (admins only)", Tag: "admin", Auth: true, Query: []apiParam{{"days", "integer", "Days to cover (default 30)"}}, Response: aiUsageRollupResponse{}},
+	"POST /admin/reprocess-incomplete":           {Summary: "Queue incomplete recipes to be imported again from their pages (admins only)", Tag: "admin", Auth: true, Query: []apiParam{{"force", "boolean", "ignore each recipe's backoff"}}, Response: map[string]int{}},
+	"POST /admin/parse-ingredients":              {Summary: "Parse the ingredients of every recipe that only has raw lines (admins only)", Tag: "admin", Auth: true, Response: map[string]int{}},
+	"POST /admin/backup":                         {Summary: "Back the database up to R2 now and rotate old backups (admins only, SQLite)", Tag: "admin", Auth: true, Response: DatabaseBackup{}},
+	"GET /export":                                {Summary: "Download a backup of the account", Tag: "backup", Auth: true, Query: []apiParam{{"format", "string", "json (default) or markdown (zip)"}}, Response: AccountExport{}},
+	"POST /import":                               {Summary: "Restore a backup from the body or a multipart \"file\"", Tag: "backup", Auth: true, Idempotent: true, Response: ImportResult{}},
+	"POST /import/:format":                       {Summary: "Import another app's export file", Tag: "backup", Auth: true, Idempotent: true, Query: []apiParam{{"dryRun", "boolean", "report without saving"}}, Response: ImportResult{}},
+	"GET /meal-plan":                             {Summary: "Planned meals in a date range", Tag: "meal plan", Auth: true, Query: []apiParam{{"from", "string", "YYYY-MM-DD"}, {"to", "string", "YYYY-MM-DD"}}, Response: []PlannedMeal{}},
+	"POST /meal-plan":                            {Summary: "Plan a recipe for a day", Tag: "meal plan", Auth: true, Idempotent: true, Request: addPlannedMealRequest{}, Response: PlannedMeal{}, Status: http.StatusCreated},
+	"DELETE /meal-plan/:id":                      {Summary: "Remove a planned meal", Tag: "meal plan", Auth: true, Response: apiMessage{}},
+	"POST /meal-plans/generate":                  {Summary: "Build a meal plan from your recipes, optionally filling gaps with AI suggestions", Tag: "meal plan", Auth: true, Query: streamParams, Request: generateMealPlanRequest{}, Response: GeneratedMealPlan{}},
+	"GET /calendar.ics":                          {Summary: "Meal plan as iCalendar", Tag: "feeds", Query: []apiParam{{"token", "string", "feed token"}, {"cookAgainDays", "integer", "remind about favorites not cooked for N days"}}, ContentType: "text/calendar"},
+	"GET /feed.xml":                              {Summary: "Recently saved recipes as RSS", Tag: "feeds", Auth: true, Query: []apiParam{{"token", "string", "feed token, in place of the bearer token"}, {"limit", "integer", ""}}, ContentType: "application/rss+xml"},
+	"POST /save-recipe":                          {Summary: "Queue a recipe page or YouTube video for import", Tag: "import", Auth: true, Idempotent: true, Request: saveRecipeRequest{}, Response: apiMessage{}, Status: http.StatusAccepted},
+	"POST /save-recipe/html":                     {Summary: "Queue a page already rendered in the browser", Tag: "import", Auth: true, Idempotent: true, Request: saveRecipeHTMLRequest{}, Response: apiMessage{}, Status: http.StatusAccepted},
+	"POST /save-recipe/pdf":                      {Summary: "Queue an uploaded PDF (multipart \"file\" or raw body)", Tag: "import", Auth: true, Idempotent: true, Response: apiMessage{}, Status: http.StatusAccepted},
+	"GET /queue/events":                          {Summary: "Server-sent events as queued imports finish (recipe.processed or recipe.failed)", Tag: "import", Auth: true, Query: []apiParam{{"access_token", "string", "token from POST /queue/events/token, for clients that can't set headers"}}, ContentType: "text/event-stream"},
+	"POST /queue/events/token":                   {Summary: "Short-lived token for opening /queue/events with ?access_token=", Tag: "import", Auth: true, Response: scopedTokenResponse{}},
+	"GET /queue/failed":                          {Summary: "Imports that failed for good, with the reason in errorCode (blocked, paywall, ai_error, ...)", Tag: "import", Auth: true, Query: paginationParams, Response: []QueueItem{}},
+	"POST /queue/:id/retry":                      {Summary: "Queue a failed import again", Tag: "import", Auth: true, Response: QueueItem{}, Status: http.StatusAccepted},
+	"DELETE /queue/:id":                          {Summary: "Cancel a pending import, stopping it if a worker has started", Tag: "import", Auth: true, Response: QueueItem{}},
+	"POST /inbound/mailgun":                      {Summary: "Mailgun inbound email webhook", Tag: "import", Response: apiMessage{}},
+	"GET /get-recipe/:name":                      {Summary: "Recipe by slug (or ?id=)", Tag: "recipes", Query: withParams([]apiParam{{"id", "integer", ""}}, scaleParams), Response: Recipe{}},
+	"DELETE /recipes/:slug":                      {Summary: "Remove a recipe by slug", Tag: "recipes", Auth: true, Response: apiMessage{}},
+	"DELETE /recipes/id/:id":                     {Summary: "Remove a recipe", Tag: "recipes", Auth: true, Response: apiMessage{}},
+	"PATCH /recipes/id/:id":                      {Summary: "Edit a recipe; with If-Match set to the recipe's ETag, 409 if it changed since", Tag: "recipes", Auth: true, Request: patchRecipeRequest{}, Response: Recipe{}},
+	"PUT /recipes/id/:id/image":                  {Summary: "Upload a replacement photo", Tag: "recipes", Auth: true, Response: Recipe{}},
+	"GET /recipes/id/:id/similar":                {Summary: "Recipes similar to this one", Tag: "recipes", Query: []apiParam{{"limit", "integer", ""}}, Response: []Recipe{}},
+	"GET /recipes/id/:id/export":                 {Summary: "Recipe as Markdown", Tag: "recipes", Auth: true, Query: withParams(scaleParams, []apiParam{{"download", "boolean", ""}}), ContentType: "text/markdown"},
+	"GET /recipes/id/:id/print":                  {Summary: "Printable recipe page", Tag: "recipes", Auth: true, Query: withParams(scaleParams, feedTokenParams), ContentType: "text/html"},
+	"GET /recipes/id/:id/source":                 {Summary: "Archived copy of the page the recipe came from", Tag: "recipes", Auth: true, Query: withParams(feedTokenParams, []apiParam{{"download", "boolean", ""}}), ContentType: "text/html"},
+	"POST /recipes/id/:id/cooked":                {Summary: "Mark a recipe cooked today", Tag: "recipes", Auth: true, Response: Recipe{}},
+	"POST /recipes/id/:id/nutrition/recalculate": {Summary: "Compute nutrition from the parsed ingredients with USDA FoodData Central", Tag: "recipes", Auth: true, Response: Recipe{}},
+	"POST /recipes/id/:id/parse-ingredients":     {Summary: "Parse the raw ingredient lines so the recipe can be scaled", Tag: "recipes", Auth: true, Query: withParams([]apiParam{{"ai", "boolean", "parse with the AI instead of by rule"}}, scaleParams), Response: Recipe{}},
+	"POST /recipes/id/:id/ask":                   {Summary: "Ask a question about a recipe; the conversation is kept", Tag: "recipes", Auth: true, Query: streamParams, Request: askRecipeRequest{}, Response: recipeChatResponse{}},
+	"GET /recipes/id/:id/ask":                    {Summary: "Earlier questions and answers about a recipe", Tag: "recipes", Auth: true, Response: recipeChatResponse{}},
+	"DELETE /recipes/id/:id/ask":                 {Summary: "Forget the conversation about a recipe", Tag: "recipes", Auth: true, Response: apiMessage{}},
+	"GET /recipes/random":                        {Summary: "Pick a recipe to cook", Tag: "recipes", Query: withParams(recipeFilterParams, []apiParam{{"excludeCookedDays", "integer", ""}}), Response: Recipe{}},
+	"POST /suggest":                              {Summary: "Recipes to cook with the ingredients on hand, with what each is missing", Tag: "recipes", Auth: true, Request: suggestRequest{}, Response: suggestResponse{}},
+	"POST /recipes/id/:id/favorite":              {Summary: "Favorite a recipe", Tag: "favorites", Auth: true, Response: apiMessage{}},
+	"DELETE /recipes/id/:id/favorite":            {Summary: "Unfavorite a recipe", Tag: "favorites", Auth: true, Response: apiMessage{}},
+	"POST /recipes/id/:id/public":                {Summary: "Publish a recipe to the public cookbook", Tag: "public", Auth: true, Response: map[string]bool{}},
+	"DELETE /recipes/id/:id/public":              {Summary: "Unpublish a recipe", Tag: "public", Auth: true, Response: map[string]bool{}},
+	"GET /public/:handle/recipes":                {Summary: "A public cookbook", Tag: "public", Query: paginationParams, Response: []Recipe{}},
+	"GET /public/:handle/recipes/:id":            {Summary: "A published recipe", Tag: "public", Response: Recipe{}},
+	"GET /public/:handle/recipes/:id/card":       {Summary: "Shareable card page with OpenGraph tags", Tag: "public", ContentType: "text/html"},
+	"GET /oembed":                                {Summary: "oEmbed for public recipe URLs", Tag: "public", Query: []apiParam{{"url", "string", ""}, {"maxwidth", "integer", ""}, {"format", "string", "json"}}, Response: oEmbedResponse{}},
+	"GET /webhooks":                              {Summary: "Registered webhooks", Tag: "webhooks", Auth: true, Response: []Webhook{}},
+	"POST /webhooks":                             {Summary: "Register a webhook; the response shows its secret once", Tag: "webhooks", Auth: true, Idempotent: true, Request: createWebhookRequest{}, Response: Webhook{}, Status: http.StatusCreated},
+	"DELETE /webhooks/:id":                       {Summary: "Remove a webhook", Tag: "webhooks", Auth: true, Response: apiMessage{}},
+	"POST /webhooks/:id/ping":                    {Summary: "Send a test delivery", Tag: "webhooks", Auth: true, Response: map[string]any{}},
+	"GET /get-recipes":                           {Summary: "List recipes", Tag: "recipes", Query: withParams(recipeFilterParams, []apiParam{{"refresh", "boolean", "bypass the cache"}}), Response: []Recipe{}},
+	"GET /search-recipes":                        {Summary: "Full-text search", Tag: "recipes", Query: withParams([]apiParam{{"q", "string", ""}, {"ingredient", "string", "comma-separated ingredients"}}, recipeFilterParams, paginationParams), Response: []Recipe{}},
+	"GET /categories":                            {Summary: "Recipe counts by category; with details=true also favorites, average total time and the newest recipe (as CategoryDetail)", Tag: "recipes", Query: []apiParam{{"details", "boolean", "return CategoryDetail objects"}}, Response: []CategoryCount{}},
+	"GET /favorites":                             {Summary: "Favorite recipes", Tag: "favorites", Response: []Recipe{}},
+	"GET /graphql":                               {Summary: "GraphQL query (schema in recipes.graphqls, introspection enabled)", Tag: "graphql", Auth: true, Query: []apiParam{{"query", "string", ""}, {"operationName", "string", ""}, {"variables", "string", "JSON object"}}, Response: map[string]any{}},
+	"POST /graphql":                              {Summary: "GraphQL query (schema in recipes.graphqls, introspection enabled)", Tag: "graphql", Auth: true, Request: graphQLRequest{}, Response: map[string]any{}},
+	"POST /recipes.v1.AuthService/:method":       {Summary: "Connect, gRPC or gRPC-Web call, see proto/recipes/v1/recipes.proto", Tag: "connect", Request: map[string]any{}, Response: map[string]any{}},
+	"POST /recipes.v1.RecipeService/:method":     {Summary: "Connect, gRPC or gRPC-Web call, see proto/recipes/v1/recipes.proto", Tag: "connect", Auth: true, Request: map[string]any{}, Response: map[string]any{}},
+	"POST /recipes.v1.QueueService/:method":      {Summary: "Connect, gRPC or gRPC-Web call, see proto/recipes/v1/recipes.proto", Tag: "connect", Auth: true, Request: map[string]any{}, Response: map[string]any{}},
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestDocsServesEmbeddedSwaggerUI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/docs", handleDocs)
+	router.GET("/docs/assets/*file", handleDocsAsset)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/docs", nil))
+	if w.Code != 200 || strings.Contains(w.Body.String(), "https://") {
+		t.Fatalf("/docs = %d, loads remote assets: %s", w.Code, w.Body.String())
+	}
+
+	for _, asset := range []string{"swagger-ui.css", "swagger-ui-bundle.js", "favicon-32x32.png"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "/docs/assets/"+asset, nil))
+		if w.Code != 200 || w.Body.Len() == 0 {
+			t.Errorf("%s = %d with %d bytes", asset, w.Code, w.Body.Len())
+		}
+	}
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/docs/assets/../openapi.go", nil))
+	if w.Code != 404 {
+		t.Fatalf("asset outside swagger-ui = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// "Cook with what I have" matches the ingredients someone has on hand
+// against each recipe's normalized ingredient names. An on-hand term covers
+// an ingredient when all of its words appear in it, so "onion" covers "red
+// onion" but "red onion" doesn't cover "onion powder". Pantry staples are
+// assumed to be on hand unless the caller says otherwise.
+
+// pantryStaples are taken as on hand by default.
+var pantryStaples = []string{"salt", "pepper", "black pepper", "water", "oil", "olive oil", "vegetable oil", "sugar"}
+
+// PantrySuggestion is a recipe that can be made, or nearly made, from the
+// ingredients on hand. Source is "collection" for the user's own recipes
+// and "ai" for generated ones, which carry their ingredients and steps.
+type PantrySuggestion struct {
+	Source       string   `json:"source"`
+	RecipeID     uint     `json:"recipeId,omitempty"`
+	Title        string   `json:"title"`
+	Image        string   `json:"image,omitempty"`
+	Description  string   `json:"description,omitempty"`
+	Ingredients  []string `json:"ingredients,omitempty"`
+	Instructions []string `json:"instructions,omitempty"`
+	// Matched and Missing are normalized ingredient names; staples count
+	// as matched but aren't listed
+	Matched []string `json:"matched"`
+	Missing []string `json:"missing"`
+	// Coverage is the share of the recipe's ingredients on hand, 0 to 1
+	Coverage float64 `json:"coverage"`
+}
+
+// singularIngredientWord undoes the common English plurals so "tomatoes"
+// and "tomato" match.
+func singularIngredientWord(word string) string {
+	switch {
+	case len(word) > 4 && strings.HasSuffix(word, "ies"):
+		return strings.TrimSuffix(word, "ies") + "y"
+	case strings.HasSuffix(word, "oes"), strings.HasSuffix(word, "ches"), strings.HasSuffix(word, "shes"), strings.HasSuffix(word, "sses"):
+		return strings.TrimSuffix(word, "es")
+	case strings.HasSuffix(word, "s") && !strings.HasSuffix(word, "ss"):
+		return strings.TrimSuffix(word, "s")
+	}
+	return word
+}
+
+// pantryWords are the identifying words of a normalized ingredient name.
+func pantryWords(name string) []string {
+	var words []string
+	for _, word := range strings.Fields(name) {
+		word = strings.Trim(word, "'-.")
+		if len(word) < 3 {
+			continue
+		}
+		if _, skip := ingredientDescriptors[word]; skip {
+			continue
+		}
+		words = append(words, singularIngredientWord(word))
+	}
+	return words
+}
+
+// pantry is a set of on-hand ingredients ready for matching.
+type pantry struct {
+	items   [][]string
+	staples [][]string
+}
+
+func newPantry(onHand []string, assumeStaples bool) pantry {
+	var p pantry
+	for _, item := range onHand {
+		if words := pantryWords(normalizeIngredientName(item)); len(words) > 0 {
+			p.items = append(p.items, words)
+		}
+	}
+	if assumeStaples {
+		for _, staple := range pantryStaples {
+			p.staples = append(p.staples, pantryWords(staple))
+		}
+	}
+	return p
+}
+
+func coversIngredient(terms [][]string, words map[string]struct{}) bool {
+	for _, term := range terms {
+		covered := true
+		for _, word := range term {
+			if _, ok := words[word]; !ok {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+// match splits a recipe's normalized ingredient names into those on hand
+// and those missing. Staples count towards coverage without being listed.
+func (p pantry) match(names []string) PantrySuggestion {
+	suggestion := PantrySuggestion{Matched: []string{}, Missing: []string{}}
+	if len(names) == 0 {
+		return suggestion
+	}
+	have := 0
+	for _, name := range names {
+		words := make(map[string]struct{})
+		for _, word := range pantryWords(name) {
+			words[word] = struct{}{}
+		}
+		switch {
+		case len(words) == 0:
+			have++
+		case coversIngredient(p.items, words):
+			suggestion.Matched = append(suggestion.Matched, name)
+			have++
+		case coversIngredient(p.staples, words):
+			have++
+		default:
+			suggestion.Missing = append(suggestion.Missing, name)
+		}
+	}
+	suggestion.Coverage = float64(have) / float64(len(names))
+	return suggestion
+}
+
+const pantrySuggestionSystemPrompt = `You suggest home-cooked recipes that use what someone already has. Prefer recipes that need few or no extra ingredients.
+Reply with only a JSON array of recipes: {"title": string, "description": one short sentence, "ingredients": [ingredient lines with quantities], "instructions": [steps]}.`
+
+// suggestPantryRecipesWithAI asks ai for up to count recipes built around
+// the ingredients on hand, then works out what each is missing the same way
+// as for saved recipes.
+func suggestPantryRecipesWithAI(ctx context.Context, ai AIProvider, onHand, avoid []string, p pantry, count int) ([]PantrySuggestion, error) {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Ingredients on hand: %s.\n", strings.Join(onHand, ", "))
+	fmt.Fprintf(&b, "\nSuggest up to %d recipes.\n", count)
+	if len(avoid) > 0 {
+		fmt.Fprintf(&b, "\nDon't use these ingredients: %s.\n", strings.Join(avoid, ", "))
+	}
+
+	reply, err := ai.Chat(ctx, pantrySuggestionSystemPrompt, []chatMessage{{Role: "user", Content: b.String()}}, pantrySuggestionMaxTokens)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := jsonArrayIn(reply.Content)
+	if err != nil {
+		return nil, err
+	}
+	var generated []struct {
+		Title        string   `json:"title"`
+		Description  string   `json:"description"`
+		Ingredients  []string `json:"ingredients"`
+		Instructions []string `json:"instructions"`
+	}
+	if err := json.Unmarshal(raw, &generated); err != nil {
+		return nil, fmt.Errorf("decode suggestions: %w", err)
+	}
+
+	suggestions := make([]PantrySuggestion, 0, len(generated))
+	for _, recipe := range generated {
+		title := strings.TrimSpace(recipe.Title)
+		if title == "" || len(recipe.Ingredients) == 0 {
+			continue
+		}
+		names := make([]string, 0, len(recipe.Ingredients))
+		for _, line := range recipe.Ingredients {
+			if name := normalizeIngredientName(line); name != "" {
+				names = append(names, name)
+			}
+		}
+		suggestion := p.match(names)
+		suggestion.Source = "ai"
+		suggestion.Title = title
+		suggestion.Description = strings.TrimSpace(recipe.Description)
+		suggestion.Ingredients = recipe.Ingredients
+		suggestion.Instructions = recipe.Instructions
+		suggestions = append(suggestions, suggestion)
+		if len(suggestions) == count {
+			break
+		}
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,318 @@
+package main
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"errors"
+	"html"
+	"io"
+	"log"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+var errPDFNoText = errors.New("pdf has no extractable text; scanned pages need OCR")
+
+// isPDF reports whether data starts with the PDF header.
+func isPDF(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(data, "\x00\t\r\n "), []byte("%PDF-"))
+}
+
+// pdfToText extracts a PDF's text with poppler's pdftotext (PDFTOTEXT_BIN
+// or on PATH), which handles embedded fonts properly. Without it, a simple
+// built-in reader covers PDFs using standard fonts.
+func pdfToText(data []byte) (string, error) {
+	var text string
+	if bin := findPDFToText(); bin != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), pdfTextTimeout)
+		defer cancel()
+		cmd := exec.CommandContext(ctx, bin, "-layout", "-enc", "UTF-8", "-", "-")
+		cmd.Stdin = bytes.NewReader(data)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		out, err := cmd.Output()
+		if err != nil {
+			log.Printf("PDF: pdftotext failed, using built-in reader: %v %s", err, strings.TrimSpace(stderr.String()))
+		} else {
+			text = string(out)
+		}
+	}
+	if strings.TrimSpace(text) == "" {
+		text = simplePDFText(data)
+	}
+
+	text = strings.TrimSpace(strings.ReplaceAll(text, "\f", "\n"))
+	if text == "" {
+		return "", errPDFNoText
+	}
+	return text, nil
+}
+
+func findPDFToText() string {
+	if custom := appConfig.Scraper.PDFToTextBin; fileExists(custom) {
+		return custom
+	}
+	if path, err := exec.LookPath("pdftotext"); err == nil {
+		return path
+	}
+	return ""
+}
+
+// pdfPageHTML wraps extracted PDF text as a page for the queue, the way
+// emailed recipe text is queued.
+func pdfPageHTML(title, text string) string {
+	return "<title>" + html.EscapeString(strings.TrimSpace(title)) + "</title>\n<pre>" + html.EscapeString(text) + "</pre>"
+}
+
+var pdfStreamPattern = regexp.MustCompile(`(?s)<<(.*?)>>\s*stream\r?\n`)
+
+// simplePDFText pulls the strings shown by text operators out of each
+// content stream. Glyphs are read as Latin-1, so fonts with custom
+// encodings come out garbled; pdftotext is the real answer for those.
+func simplePDFText(data []byte) string {
+	var out strings.Builder
+	for _, loc := range pdfStreamPattern.FindAllSubmatchIndex(data, -1) {
+		dict := string(data[loc[2]:loc[3]])
+		start := loc[1]
+		end := bytes.Index(data[start:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		if strings.Contains(dict, "/Image") || strings.Contains(dict, "/FontFile") || strings.Contains(dict, "/Length1") {
+			continue
+		}
+
+		stream := data[start : start+end]
+		if strings.Contains(dict, "/FlateDecode") {
+			reader, err := zlib.NewReader(bytes.NewReader(stream))
+			if err != nil {
+				continue
+			}
+			// Streams are often truncated by a byte or two; keep what decoded
+			stream, _ = io.ReadAll(io.LimitReader(reader, maxPDFStreamBytes))
+			reader.Close()
+		} else if strings.Contains(dict, "/Filter") {
+			continue
+		}
+		if bytes.Contains(stream, []byte("BT")) {
+			out.WriteString(pdfContentText(stream))
+		}
+	}
+	return out.String()
+}
+
+// pdfContentText interprets the text operators of one content stream.
+func pdfContentText(stream []byte) string {
+	var out strings.Builder
+	var operands []string
+	inText := false
+	newline := func() {
+		if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") {
+			out.WriteByte('\n')
+		}
+	}
+
+	for i := 0; i < len(stream); {
+		c := stream[i]
+		switch {
+		case c == '(':
+			s, next := pdfLiteralString(stream, i)
+			operands = append(operands, s)
+			i = next
+		case c == '<' && i+1 < len(stream) && stream[i+1] != '<':
+			s, next := pdfHexString(stream, i)
+			operands = append(operands, s)
+			i = next
+		case c == '[':
+			operands = append(operands, "[")
+			i++
+		case c == ']':
+			// Collapse the array into one operand; large negative kerning
+			// between strings is how many PDFs draw a space
+			var b strings.Builder
+			j := len(operands) - 1
+			for j >= 0 && operands[j] != "[" {
+				j--
+			}
+			if j >= 0 {
+				for _, item := range operands[j+1:] {
+					if strings.HasPrefix(item, "\x00") {
+						b.WriteString(item[1:])
+					} else if n := pdfNumber(item); n < -200 {
+						b.WriteByte(' ')
+					}
+				}
+				operands = append(operands[:j], "\x00"+b.String())
+			}
+			i++
+		case c == '%':
+			for i < len(stream) && stream[i] != '\n' && stream[i] != '\r' {
+				i++
+			}
+		case c == ' ' || c == '\n' || c == '\r' || c == '\t' || c == '\f' || c == 0:
+			i++
+		default:
+			j := i
+			for j < len(stream) && !bytes.ContainsRune([]byte(" \n\r\t\f\x00()<>[]/%"), rune(stream[j])) {
+				j++
+			}
+			if j == i {
+				// A name such as /F1: skip the slash, the rest is read next
+				j = i + 1
+			}
+			token := string(stream[i:j])
+			i = j
+
+			switch token {
+			case "BT":
+				inText = true
+				operands = operands[:0]
+			case "ET":
+				inText = false
+				newline()
+				operands = operands[:0]
+			case "Tj", "TJ", "'", "\"":
+				if token == "'" || token == "\"" {
+					newline()
+				}
+				if inText && len(operands) > 0 {
+					if last := operands[len(operands)-1]; strings.HasPrefix(last, "\x00") {
+						out.WriteString(last[1:])
+					}
+				}
+				operands = operands[:0]
+			case "T*":
+				newline()
+				operands = operands[:0]
+			case "Td", "TD":
+				if len(operands) >= 2 && pdfNumber(operands[len(operands)-1]) != 0 {
+					newline()
+				} else if s := out.String(); s != "" && !strings.HasSuffix(s, "\n") && !strings.HasSuffix(s, " ") {
+					out.WriteByte(' ')
+				}
+				operands = operands[:0]
+			case "Tm":
+				newline()
+				operands = operands[:0]
+			default:
+				if len(token) > 0 && (token[0] == '-' || token[0] == '.' || (token[0] >= '0' && token[0] <= '9')) {
+					operands = append(operands, token)
+				} else {
+					operands = operands[:0]
+				}
+			}
+		}
+	}
+	return out.String()
+}
+
+// pdfLiteralString decodes a (...) string starting at i, returning it
+// marked with a leading NUL and the index after the closing paren.
+func pdfLiteralString(stream []byte, i int) (string, int) {
+	var b []byte
+	depth := 0
+	for i++; i < len(stream); i++ {
+		c := stream[i]
+		switch c {
+		case '\\':
+			i++
+			if i >= len(stream) {
+				break
+			}
+			switch e := stream[i]; e {
+			case 'n':
+				b = append(b, '\n')
+			case 'r', 't', 'b', 'f':
+				b = append(b, ' ')
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					v, n := 0, 0
+					for n < 3 && i < len(stream) && stream[i] >= '0' && stream[i] <= '7' {
+						v = v*8 + int(stream[i]-'0')
+						i++
+						n++
+					}
+					i--
+					b = append(b, byte(v))
+				} else {
+					b = append(b, e)
+				}
+			}
+		case '(':
+			depth++
+			b = append(b, c)
+		case ')':
+			if depth == 0 {
+				return "\x00" + pdfDecodeBytes(b), i + 1
+			}
+			depth--
+			b = append(b, c)
+		default:
+			b = append(b, c)
+		}
+	}
+	return "\x00" + pdfDecodeBytes(b), i
+}
+
+// pdfHexString decodes a <...> string starting at i.
+func pdfHexString(stream []byte, i int) (string, int) {
+	end := bytes.IndexByte(stream[i:], '>')
+	if end < 0 {
+		return "\x00", len(stream)
+	}
+	var digits []byte
+	for _, c := range stream[i+1 : i+end] {
+		if (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F') {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	b, _ := hex.DecodeString(string(digits))
+	return "\x00" + pdfDecodeBytes(b), i + end + 1
+}
+
+// pdfDecodeBytes reads UTF-16BE strings (with BOM or two-byte codes) and
+// treats everything else as Latin-1.
+func pdfDecodeBytes(b []byte) string {
+	if len(b) >= 2 && b[0] == 0xFE && b[1] == 0xFF {
+		return pdfUTF16(b[2:])
+	}
+	if len(b) >= 2 && len(b)%2 == 0 && b[0] == 0 {
+		return pdfUTF16(b)
+	}
+	var s strings.Builder
+	for _, c := range b {
+		if c >= 0x20 || c == '\n' {
+			s.WriteRune(rune(c))
+		}
+	}
+	return s.String()
+}
+
+func pdfUTF16(b []byte) string {
+	var s strings.Builder
+	for k := 0; k+1 < len(b); k += 2 {
+		r := rune(b[k])<<8 | rune(b[k+1])
+		if r >= 0x20 && utf8.ValidRune(r) {
+			s.WriteRune(r)
+		}
+	}
+	return s.String()
+}
+
+func pdfNumber(token string) float64 {
+	n, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return 0
+	}
+	return n
+}
@@ -0,0 +1,388 @@
+// Typed API for scripted clients and other services, served alongside the
+// REST routes at
+//   POST /recipes.v1.<Service>/<Method>
+// over the Connect, gRPC and gRPC-Web protocols with the binary or JSON
+// codec. Requests other than AuthService.Login need
+// "Authorization: Bearer <token>". Go code is generated with buf generate
+// (see buf.gen.yaml).
+
+// Code generated by protoc-gen-connect-go. DO NOT EDIT.
+//
+// Source: recipes/v1/recipes.proto
+
+package recipesv1connect
+
+import (
+	connect "connectrpc.com/connect"
+	context "context"
+	v1 "cooking.bronson.dev/proto/recipes/v1"
+	errors "errors"
+	http "net/http"
+	strings "strings"
+)
+
+// This is a compile-time assertion to ensure that this generated file and the connect package are
+// compatible. If you get a compiler error that this constant is not defined, this code was
+// generated with a version of connect newer than the one compiled into your binary. You can fix the
+// problem by either regenerating this code with an older version of connect or updating the connect
+// version compiled into your binary.
+const _ = connect.IsAtLeastVersion1_13_0
+
+const (
+	// AuthServiceName is the fully-qualified name of the AuthService service.
+	AuthServiceName = "recipes.v1.AuthService"
+	// RecipeServiceName is the fully-qualified name of the RecipeService service.
+	RecipeServiceName = "recipes.v1.RecipeService"
+	// QueueServiceName is the fully-qualified name of the QueueService service.
+	QueueServiceName = "recipes.v1.QueueService"
+)
+
+// These constants are the fully-qualified names of the RPCs defined in this package. They're
+// exposed at runtime as Spec.Procedure and as the final two segments of the HTTP route.
+//
+// Note that these are different from the fully-qualified method names used by
+// google.golang.org/protobuf/reflect/protoreflect. To convert from these constants to
+// reflection-formatted method names, remove the leading slash and convert the remaining slash to a
+// period.
+const (
+	// AuthServiceLoginProcedure is the fully-qualified name of the AuthService's Login RPC.
+	AuthServiceLoginProcedure = "/recipes.v1.AuthService/Login"
+	// RecipeServiceListRecipesProcedure is the fully-qualified name of the RecipeService's ListRecipes
+	// RPC.
+	RecipeServiceListRecipesProcedure = "/recipes.v1.RecipeService/ListRecipes"
+	// RecipeServiceGetRecipeProcedure is the fully-qualified name of the RecipeService's GetRecipe RPC.
+	RecipeServiceGetRecipeProcedure = "/recipes.v1.RecipeService/GetRecipe"
+	// RecipeServiceSearchRecipesProcedure is the fully-qualified name of the RecipeService's
+	// SearchRecipes RPC.
+	RecipeServiceSearchRecipesProcedure = "/recipes.v1.RecipeService/SearchRecipes"
+	// RecipeServiceListFavoritesProcedure is the fully-qualified name of the RecipeService's
+	// ListFavorites RPC.
+	RecipeServiceListFavoritesProcedure = "/recipes.v1.RecipeService/ListFavorites"
+	// QueueServiceEnqueueRecipeProcedure is the fully-qualified name of the QueueService's
+	// EnqueueRecipe RPC.
+	QueueServiceEnqueueRecipeProcedure = "/recipes.v1.QueueService/EnqueueRecipe"
+	// QueueServiceListQueueProcedure is the fully-qualified name of the QueueService's ListQueue RPC.
+	QueueServiceListQueueProcedure = "/recipes.v1.QueueService/ListQueue"
+)
+
+// These variables are the protoreflect.Descriptor objects for the RPCs defined in this package.
+var (
+	authServiceServiceDescriptor               = v1.File_recipes_v1_recipes_proto.Services().ByName("AuthService")
+	authServiceLoginMethodDescriptor           = authServiceServiceDescriptor.Methods().ByName("Login")
+	recipeServiceServiceDescriptor             = v1.File_recipes_v1_recipes_proto.Services().ByName("RecipeService")
+	recipeServiceListRecipesMethodDescriptor   = recipeServiceServiceDescriptor.Methods().ByName("ListRecipes")
+	recipeServiceGetRecipeMethodDescriptor     = recipeServiceServiceDescriptor.Methods().ByName("GetRecipe")
+	recipeServiceSearchRecipesMethodDescriptor = recipeServiceServiceDescriptor.Methods().ByName("SearchRecipes")
+	recipeServiceListFavoritesMethodDescriptor = recipeServiceServiceDescriptor.Methods().ByName("ListFavorites")
+	queueServiceServiceDescriptor              = v1.File_recipes_v1_recipes_proto.Services().ByName("QueueService")
+	queueServiceEnqueueRecipeMethodDescriptor  = queueServiceServiceDescriptor.Methods().ByName("EnqueueRecipe")
+	queueServiceListQueueMethodDescriptor      = queueServiceServiceDescriptor.Methods().ByName("ListQueue")
+)
+
+// AuthServiceClient is a client for the recipes.v1.AuthService service.
+type AuthServiceClient interface {
+	Login(context.Context, *connect.Request[v1.LoginRequest]) (*connect.Response[v1.LoginResponse], error)
+}
+
+// NewAuthServiceClient constructs a client for the recipes.v1.AuthService service. By default, it
+// uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewAuthServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) AuthServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &authServiceClient{
+		login: connect.NewClient[v1.LoginRequest, v1.LoginResponse](
+			httpClient,
+			baseURL+AuthServiceLoginProcedure,
+			connect.WithSchema(authServiceLoginMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// authServiceClient implements AuthServiceClient.
+type authServiceClient struct {
+	login *connect.Client[v1.LoginRequest, v1.LoginResponse]
+}
+
+// Login calls recipes.v1.AuthService.Login.
+func (c *authServiceClient) Login(ctx context.Context, req *connect.Request[v1.LoginRequest]) (*connect.Response[v1.LoginResponse], error) {
+	return c.login.CallUnary(ctx, req)
+}
+
+// AuthServiceHandler is an implementation of the recipes.v1.AuthService service.
+type AuthServiceHandler interface {
+	Login(context.Context, *connect.Request[v1.LoginRequest]) (*connect.Response[v1.LoginResponse], error)
+}
+
+// NewAuthServiceHandler builds an HTTP handler from the service implementation. It returns the path
+// on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewAuthServiceHandler(svc AuthServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	authServiceLoginHandler := connect.NewUnaryHandler(
+		AuthServiceLoginProcedure,
+		svc.Login,
+		connect.WithSchema(authServiceLoginMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/recipes.v1.AuthService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case AuthServiceLoginProcedure:
+			authServiceLoginHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedAuthServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedAuthServiceHandler struct{}
+
+func (UnimplementedAuthServiceHandler) Login(context.Context, *connect.Request[v1.LoginRequest]) (*connect.Response[v1.LoginResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.AuthService.Login is not implemented"))
+}
+
+// RecipeServiceClient is a client for the recipes.v1.RecipeService service.
+type RecipeServiceClient interface {
+	ListRecipes(context.Context, *connect.Request[v1.ListRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error)
+	GetRecipe(context.Context, *connect.Request[v1.GetRecipeRequest]) (*connect.Response[v1.Recipe], error)
+	SearchRecipes(context.Context, *connect.Request[v1.SearchRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error)
+	ListFavorites(context.Context, *connect.Request[v1.ListFavoritesRequest]) (*connect.Response[v1.ListRecipesResponse], error)
+}
+
+// NewRecipeServiceClient constructs a client for the recipes.v1.RecipeService service. By default,
+// it uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and
+// sends uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC()
+// or connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewRecipeServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) RecipeServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &recipeServiceClient{
+		listRecipes: connect.NewClient[v1.ListRecipesRequest, v1.ListRecipesResponse](
+			httpClient,
+			baseURL+RecipeServiceListRecipesProcedure,
+			connect.WithSchema(recipeServiceListRecipesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		getRecipe: connect.NewClient[v1.GetRecipeRequest, v1.Recipe](
+			httpClient,
+			baseURL+RecipeServiceGetRecipeProcedure,
+			connect.WithSchema(recipeServiceGetRecipeMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		searchRecipes: connect.NewClient[v1.SearchRecipesRequest, v1.ListRecipesResponse](
+			httpClient,
+			baseURL+RecipeServiceSearchRecipesProcedure,
+			connect.WithSchema(recipeServiceSearchRecipesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		listFavorites: connect.NewClient[v1.ListFavoritesRequest, v1.ListRecipesResponse](
+			httpClient,
+			baseURL+RecipeServiceListFavoritesProcedure,
+			connect.WithSchema(recipeServiceListFavoritesMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// recipeServiceClient implements RecipeServiceClient.
+type recipeServiceClient struct {
+	listRecipes   *connect.Client[v1.ListRecipesRequest, v1.ListRecipesResponse]
+	getRecipe     *connect.Client[v1.GetRecipeRequest, v1.Recipe]
+	searchRecipes *connect.Client[v1.SearchRecipesRequest, v1.ListRecipesResponse]
+	listFavorites *connect.Client[v1.ListFavoritesRequest, v1.ListRecipesResponse]
+}
+
+// ListRecipes calls recipes.v1.RecipeService.ListRecipes.
+func (c *recipeServiceClient) ListRecipes(ctx context.Context, req *connect.Request[v1.ListRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	return c.listRecipes.CallUnary(ctx, req)
+}
+
+// GetRecipe calls recipes.v1.RecipeService.GetRecipe.
+func (c *recipeServiceClient) GetRecipe(ctx context.Context, req *connect.Request[v1.GetRecipeRequest]) (*connect.Response[v1.Recipe], error) {
+	return c.getRecipe.CallUnary(ctx, req)
+}
+
+// SearchRecipes calls recipes.v1.RecipeService.SearchRecipes.
+func (c *recipeServiceClient) SearchRecipes(ctx context.Context, req *connect.Request[v1.SearchRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	return c.searchRecipes.CallUnary(ctx, req)
+}
+
+// ListFavorites calls recipes.v1.RecipeService.ListFavorites.
+func (c *recipeServiceClient) ListFavorites(ctx context.Context, req *connect.Request[v1.ListFavoritesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	return c.listFavorites.CallUnary(ctx, req)
+}
+
+// RecipeServiceHandler is an implementation of the recipes.v1.RecipeService service.
+type RecipeServiceHandler interface {
+	ListRecipes(context.Context, *connect.Request[v1.ListRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error)
+	GetRecipe(context.Context, *connect.Request[v1.GetRecipeRequest]) (*connect.Response[v1.Recipe], error)
+	SearchRecipes(context.Context, *connect.Request[v1.SearchRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error)
+	ListFavorites(context.Context, *connect.Request[v1.ListFavoritesRequest]) (*connect.Response[v1.ListRecipesResponse], error)
+}
+
+// NewRecipeServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewRecipeServiceHandler(svc RecipeServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	recipeServiceListRecipesHandler := connect.NewUnaryHandler(
+		RecipeServiceListRecipesProcedure,
+		svc.ListRecipes,
+		connect.WithSchema(recipeServiceListRecipesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	recipeServiceGetRecipeHandler := connect.NewUnaryHandler(
+		RecipeServiceGetRecipeProcedure,
+		svc.GetRecipe,
+		connect.WithSchema(recipeServiceGetRecipeMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	recipeServiceSearchRecipesHandler := connect.NewUnaryHandler(
+		RecipeServiceSearchRecipesProcedure,
+		svc.SearchRecipes,
+		connect.WithSchema(recipeServiceSearchRecipesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	recipeServiceListFavoritesHandler := connect.NewUnaryHandler(
+		RecipeServiceListFavoritesProcedure,
+		svc.ListFavorites,
+		connect.WithSchema(recipeServiceListFavoritesMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/recipes.v1.RecipeService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case RecipeServiceListRecipesProcedure:
+			recipeServiceListRecipesHandler.ServeHTTP(w, r)
+		case RecipeServiceGetRecipeProcedure:
+			recipeServiceGetRecipeHandler.ServeHTTP(w, r)
+		case RecipeServiceSearchRecipesProcedure:
+			recipeServiceSearchRecipesHandler.ServeHTTP(w, r)
+		case RecipeServiceListFavoritesProcedure:
+			recipeServiceListFavoritesHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedRecipeServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedRecipeServiceHandler struct{}
+
+func (UnimplementedRecipeServiceHandler) ListRecipes(context.Context, *connect.Request[v1.ListRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.RecipeService.ListRecipes is not implemented"))
+}
+
+func (UnimplementedRecipeServiceHandler) GetRecipe(context.Context, *connect.Request[v1.GetRecipeRequest]) (*connect.Response[v1.Recipe], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.RecipeService.GetRecipe is not implemented"))
+}
+
+func (UnimplementedRecipeServiceHandler) SearchRecipes(context.Context, *connect.Request[v1.SearchRecipesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.RecipeService.SearchRecipes is not implemented"))
+}
+
+func (UnimplementedRecipeServiceHandler) ListFavorites(context.Context, *connect.Request[v1.ListFavoritesRequest]) (*connect.Response[v1.ListRecipesResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.RecipeService.ListFavorites is not implemented"))
+}
+
+// QueueServiceClient is a client for the recipes.v1.QueueService service.
+type QueueServiceClient interface {
+	EnqueueRecipe(context.Context, *connect.Request[v1.EnqueueRecipeRequest]) (*connect.Response[v1.EnqueueRecipeResponse], error)
+	ListQueue(context.Context, *connect.Request[v1.ListQueueRequest]) (*connect.Response[v1.ListQueueResponse], error)
+}
+
+// NewQueueServiceClient constructs a client for the recipes.v1.QueueService service. By default, it
+// uses the Connect protocol with the binary Protobuf Codec, asks for gzipped responses, and sends
+// uncompressed requests. To use the gRPC or gRPC-Web protocols, supply the connect.WithGRPC() or
+// connect.WithGRPCWeb() options.
+//
+// The URL supplied here should be the base URL for the Connect or gRPC server (for example,
+// http://api.acme.com or https://acme.com/grpc).
+func NewQueueServiceClient(httpClient connect.HTTPClient, baseURL string, opts ...connect.ClientOption) QueueServiceClient {
+	baseURL = strings.TrimRight(baseURL, "/")
+	return &queueServiceClient{
+		enqueueRecipe: connect.NewClient[v1.EnqueueRecipeRequest, v1.EnqueueRecipeResponse](
+			httpClient,
+			baseURL+QueueServiceEnqueueRecipeProcedure,
+			connect.WithSchema(queueServiceEnqueueRecipeMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+		listQueue: connect.NewClient[v1.ListQueueRequest, v1.ListQueueResponse](
+			httpClient,
+			baseURL+QueueServiceListQueueProcedure,
+			connect.WithSchema(queueServiceListQueueMethodDescriptor),
+			connect.WithClientOptions(opts...),
+		),
+	}
+}
+
+// queueServiceClient implements QueueServiceClient.
+type queueServiceClient struct {
+	enqueueRecipe *connect.Client[v1.EnqueueRecipeRequest, v1.EnqueueRecipeResponse]
+	listQueue     *connect.Client[v1.ListQueueRequest, v1.ListQueueResponse]
+}
+
+// EnqueueRecipe calls recipes.v1.QueueService.EnqueueRecipe.
+func (c *queueServiceClient) EnqueueRecipe(ctx context.Context, req *connect.Request[v1.EnqueueRecipeRequest]) (*connect.Response[v1.EnqueueRecipeResponse], error) {
+	return c.enqueueRecipe.CallUnary(ctx, req)
+}
+
+// ListQueue calls recipes.v1.QueueService.ListQueue.
+func (c *queueServiceClient) ListQueue(ctx context.Context, req *connect.Request[v1.ListQueueRequest]) (*connect.Response[v1.ListQueueResponse], error) {
+	return c.listQueue.CallUnary(ctx, req)
+}
+
+// QueueServiceHandler is an implementation of the recipes.v1.QueueService service.
+type QueueServiceHandler interface {
+	EnqueueRecipe(context.Context, *connect.Request[v1.EnqueueRecipeRequest]) (*connect.Response[v1.EnqueueRecipeResponse], error)
+	ListQueue(context.Context, *connect.Request[v1.ListQueueRequest]) (*connect.Response[v1.ListQueueResponse], error)
+}
+
+// NewQueueServiceHandler builds an HTTP handler from the service implementation. It returns the
+// path on which to mount the handler and the handler itself.
+//
+// By default, handlers support the Connect, gRPC, and gRPC-Web protocols with the binary Protobuf
+// and JSON codecs. They also support gzip compression.
+func NewQueueServiceHandler(svc QueueServiceHandler, opts ...connect.HandlerOption) (string, http.Handler) {
+	queueServiceEnqueueRecipeHandler := connect.NewUnaryHandler(
+		QueueServiceEnqueueRecipeProcedure,
+		svc.EnqueueRecipe,
+		connect.WithSchema(queueServiceEnqueueRecipeMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	queueServiceListQueueHandler := connect.NewUnaryHandler(
+		QueueServiceListQueueProcedure,
+		svc.ListQueue,
+		connect.WithSchema(queueServiceListQueueMethodDescriptor),
+		connect.WithHandlerOptions(opts...),
+	)
+	return "/recipes.v1.QueueService/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case QueueServiceEnqueueRecipeProcedure:
+			queueServiceEnqueueRecipeHandler.ServeHTTP(w, r)
+		case QueueServiceListQueueProcedure:
+			queueServiceListQueueHandler.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// UnimplementedQueueServiceHandler returns CodeUnimplemented from all methods.
+type UnimplementedQueueServiceHandler struct{}
+
+func (UnimplementedQueueServiceHandler) EnqueueRecipe(context.Context, *connect.Request[v1.EnqueueRecipeRequest]) (*connect.Response[v1.EnqueueRecipeResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.QueueService.EnqueueRecipe is not implemented"))
+}
+
+func (UnimplementedQueueServiceHandler) ListQueue(context.Context, *connect.Request[v1.ListQueueRequest]) (*connect.Response[v1.ListQueueResponse], error) {
+	return nil, connect.NewError(connect.CodeUnimplemented, errors.New("recipes.v1.QueueService.ListQueue is not implemented"))
+}
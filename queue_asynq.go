@@ -0,0 +1,180 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/hibiken/asynq"
+)
+
+// asynqTaskQueueItem is the task that imports one queue item.
+const asynqTaskQueueItem = "queue:item"
+
+// Queue priorities map to weighted asynq queues, so bulk imports still
+// progress while interactive ones go first.
+var asynqQueues = map[string]int{
+	"interactive": 6,
+	"bulk":        3,
+	"background":  1,
+}
+
+func asynqQueueFor(priority int) string {
+	switch {
+	case priority >= queuePriorityInteractive:
+		return "interactive"
+	case priority >= queuePriorityBulk:
+		return "bulk"
+	default:
+		return "background"
+	}
+}
+
+// asynqJobQueue dispatches queue items through Redis. Every instance
+// publishes the table's ready items when woken and every poll interval;
+// the task id names the item and attempt, so an item already in Redis
+// isn't published twice. Workers take tasks from Redis, so each attempt
+// runs once however many workers there are. A failed attempt isn't
+// retried by asynq: the item is published again once its backoff passes.
+type asynqJobQueue struct {
+	redis  asynq.RedisConnOpt
+	client *asynq.Client
+	wake   chan struct{}
+}
+
+func newAsynqJobQueue(redisURL string) (*asynqJobQueue, error) {
+	opt, err := asynq.ParseRedisURI(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("REDIS_URL: %w", err)
+	}
+	return &asynqJobQueue{
+		redis:  opt,
+		client: asynq.NewClient(opt),
+		wake:   make(chan struct{}, 1),
+	}, nil
+}
+
+type asynqQueueItemPayload struct {
+	ID uint `json:"id"`
+}
+
+// Wake publishes the ready items in the background.
+func (q *asynqJobQueue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+		return
+	}
+	go func() {
+		<-q.wake
+		if recipeRepo != nil {
+			q.publishReady(context.Background(), recipeRepo)
+		}
+	}()
+}
+
+// publishReady sends the table's ready items to Redis.
+func (q *asynqJobQueue) publishReady(ctx context.Context, repo *RecipeRepository) {
+	items, err := repo.FetchPendingQueue(asynqPublishBatch)
+	if err != nil {
+		log.Printf("Queue: fetch error: %v", err)
+		return
+	}
+	published := 0
+	for _, item := range items {
+		payload, err := json.Marshal(asynqQueueItemPayload{ID: item.ID})
+		if err != nil {
+			continue
+		}
+		task := asynq.NewTask(asynqTaskQueueItem, payload,
+			asynq.TaskID(fmt.Sprintf("queue-item-%d-%d", item.ID, item.Attempts)),
+			asynq.Queue(asynqQueueFor(item.Priority)),
+			asynq.MaxRetry(0),
+			asynq.Timeout(asynqTaskTimeout),
+		)
+		switch _, err := q.client.EnqueueContext(ctx, task); {
+		case err == nil:
+			published++
+		case errors.Is(err, asynq.ErrTaskIDConflict):
+			// Already waiting in Redis
+		default:
+			log.Printf("Queue: publish item %d: %v", item.ID, err)
+			return
+		}
+	}
+	if published > 0 {
+		log.Printf("Queue: published %d item(s) to Redis", published)
+	}
+}
+
+// Run works tasks with QUEUE_CONCURRENCY workers, publishing the table's
+// ready items every poll interval, until ctx is done. Tasks in flight then
+// get the drain timeout to finish before they are cancelled; their items
+// stay pending and are published again by whichever instance is left.
+func (q *asynqJobQueue) Run(ctx context.Context, repo *RecipeRepository) {
+	work, stopWork := drainContext(ctx, queueSettings.DrainTimeout)
+	defer stopWork()
+	inFlight := newQueueCancellations()
+	go inFlight.watch(work, repo)
+
+	server := asynq.NewServer(q.redis, asynq.Config{
+		Concurrency: queueSettings.Concurrency,
+		Queues:      asynqQueues,
+		LogLevel:    asynq.WarnLevel,
+		// Past the drain timeout, so cancelled items can return first
+		ShutdownTimeout: queueSettings.DrainTimeout + asynqShutdownGrace,
+	})
+	mux := asynq.NewServeMux()
+	mux.HandleFunc(asynqTaskQueueItem, func(taskCtx context.Context, task *asynq.Task) error {
+		var payload asynqQueueItemPayload
+		if err := json.Unmarshal(task.Payload(), &payload); err != nil {
+			return fmt.Errorf("%w: %w", asynq.SkipRetry, err)
+		}
+		item, err := repo.GetQueueItem(payload.ID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		// Published before an earlier attempt finished, or cancelled since
+		if item.ProcessedAt != nil || (item.NextAttempt != nil && item.NextAttempt.After(time.Now())) {
+			return nil
+		}
+		itemCtx, cancel := context.WithCancelCause(taskCtx)
+		defer cancel(nil)
+		stop := context.AfterFunc(work, func() { cancel(context.Cause(work)) })
+		defer stop()
+		runQueueItem(itemCtx, repo, inFlight, item)
+		return nil
+	})
+	if err := server.Start(mux); err != nil {
+		log.Printf("Queue: start Redis worker: %v", err)
+		return
+	}
+	log.Println("queue processor started (redis)")
+	queueLiveness.beat(time.Now())
+
+	q.publishReady(ctx, repo)
+	ticker := time.NewTicker(queueSettings.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("queue processor stopping")
+			server.Shutdown()
+			return
+		case <-ticker.C:
+			queueLiveness.beat(time.Now())
+			q.publishReady(ctx, repo)
+		}
+	}
+}
+
+func (q *asynqJobQueue) Close() error {
+	return q.client.Close()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+)
+
+// JobQueue hands queued items to the queue processor. The queue table is
+// always the record of each item and its state; a JobQueue only decides
+// which worker imports what, and when.
+//
+// The default polls the table, claiming the items it takes so several
+// workers sharing the database each import different items. With many
+// workers, QUEUE_BACKEND=redis dispatches items through Redis (asynq) so
+// each is taken by exactly one worker without every instance polling.
+type JobQueue interface {
+	// Wake is called after items are queued so they needn't wait for the
+	// next poll. It doesn't block.
+	Wake()
+	// Run processes queued items until ctx is done.
+	Run(ctx context.Context, repo *RecipeRepository)
+	Close() error
+}
+
+const (
+	queueBackendSQLite = "sqlite"
+	queueBackendRedis  = "redis"
+)
+
+// jobQueue is the configured backend; main replaces the default with
+// newJobQueue at startup.
+var jobQueue JobQueue = newPollingJobQueue()
+
+// newJobQueue builds the backend settings.Backend names (sqlite or redis).
+// Redis is reached at REDIS_URL, e.g. redis://localhost:6379/0.
+func newJobQueue(settings QueueSettings) (JobQueue, error) {
+	switch settings.Backend {
+	case "", queueBackendSQLite:
+		return newPollingJobQueue(), nil
+	case queueBackendRedis:
+		if settings.RedisURL == "" {
+			return nil, fmt.Errorf("QUEUE_BACKEND=redis needs REDIS_URL")
+		}
+		return newAsynqJobQueue(settings.RedisURL)
+	default:
+		return nil, fmt.Errorf("QUEUE_BACKEND: %q is not sqlite or redis", settings.Backend)
+	}
+}
+
+// pollingJobQueue checks the queue table every poll interval and claims
+// what it takes. Waking it only reaches a processor in the same process.
+type pollingJobQueue struct {
+	wake chan struct{}
+}
+
+func newPollingJobQueue() *pollingJobQueue {
+	return &pollingJobQueue{wake: make(chan struct{}, 1)}
+}
+
+func (q *pollingJobQueue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (q *pollingJobQueue) Run(ctx context.Context, repo *RecipeRepository) {
+	runQueueProcessor(ctx, repo, q.wake)
+}
+
+func (q *pollingJobQueue) Close() error {
+	return nil
+}
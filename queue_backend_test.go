@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestNewJobQueue(t *testing.T) {
+	for _, tc := range []struct {
+		backend, redisURL string
+		wantErr           bool
+	}{
+		{"", "", false},
+		{"sqlite", "", false},
+		{"redis", "", true},
+		{"redis", "redis://localhost:6379/0", false},
+		{"kafka", "", true},
+	} {
+		t.Setenv("QUEUE_BACKEND", tc.backend)
+		t.Setenv("REDIS_URL", tc.redisURL)
+		var queue JobQueue
+		settings, err := loadQueueSettings()
+		if err == nil {
+			queue, err = newJobQueue(settings)
+		}
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("QUEUE_BACKEND=%q: err = %v, want error %t", tc.backend, err, tc.wantErr)
+		}
+		if queue != nil {
+			queue.Close()
+		}
+	}
+}
+
+func TestPollingJobQueueWakeDoesNotBlock(t *testing.T) {
+	queue := newPollingJobQueue()
+	queue.Wake()
+	queue.Wake()
+	select {
+	case <-queue.wake:
+	default:
+		t.Fatal("wake wasn't signalled")
+	}
+}
+
+func TestAsynqJobQueuePublishesEachAttemptOnce(t *testing.T) {
+	redis := miniredis.RunT(t)
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/soup", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	queue, err := newAsynqJobQueue("redis://" + redis.Addr())
+	if err != nil {
+		t.Fatalf("new queue: %v", err)
+	}
+	defer queue.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	queue.publishReady(ctx, repo)
+	queue.publishReady(ctx, repo)
+
+	pending, err := redis.List("asynq:{interactive}:pending")
+	if err != nil {
+		t.Fatalf("read pending tasks: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("pending tasks = %v, want the item once", pending)
+	}
+}
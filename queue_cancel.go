@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// queueCancellations tracks the contexts of the items a batch is importing,
+// so DELETE /queue/:id can stop one. The API may run in another process, so
+// cancellation goes through the queue table and the worker polls for it.
+type queueCancellations struct {
+	mu      sync.Mutex
+	cancels map[uint]context.CancelCauseFunc
+}
+
+// errQueueItemCancelled is the cause of an item context the user cancelled;
+// any other cause, such as a worker shutting down, leaves the item pending.
+var errQueueItemCancelled = errors.New("queue item cancelled")
+
+func newQueueCancellations() *queueCancellations {
+	return &queueCancellations{cancels: map[uint]context.CancelCauseFunc{}}
+}
+
+// start returns the context item id is imported under.
+func (q *queueCancellations) start(parent context.Context, id uint) context.Context {
+	ctx, cancel := context.WithCancelCause(parent)
+	q.mu.Lock()
+	q.cancels[id] = cancel
+	q.mu.Unlock()
+	return ctx
+}
+
+// finish releases item id's context once its import has returned.
+func (q *queueCancellations) finish(id uint) {
+	q.mu.Lock()
+	cancel, ok := q.cancels[id]
+	delete(q.cancels, id)
+	q.mu.Unlock()
+	if ok {
+		cancel(nil)
+	}
+}
+
+// watch cancels the context of any in-flight item the user cancels, until
+// ctx is done.
+func (q *queueCancellations) watch(ctx context.Context, repo *RecipeRepository) {
+	ticker := time.NewTicker(queueCancelPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		q.mu.Lock()
+		ids := make([]uint, 0, len(q.cancels))
+		for id := range q.cancels {
+			ids = append(ids, id)
+		}
+		q.mu.Unlock()
+
+		cancelled, err := repo.CancelledQueueItems(ids)
+		if err != nil {
+			log.Printf("Queue: cancellation check failed: %v", err)
+			continue
+		}
+		q.mu.Lock()
+		for _, id := range cancelled {
+			if cancel, ok := q.cancels[id]; ok {
+				log.Printf("Queue: cancelling item %d", id)
+				cancel(errQueueItemCancelled)
+			}
+		}
+		q.mu.Unlock()
+	}
+}
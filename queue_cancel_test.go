@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestQueueCancellationsCancelInFlightItem(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	for _, url := range []string{"https://example.com/a", "https://example.com/b"} {
+		if err := repo.EnqueueRecipe("cook@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	items, err := repo.FetchPendingQueue(10)
+	if err != nil || len(items) != 2 {
+		t.Fatalf("pending = %d items (err %v), want 2", len(items), err)
+	}
+
+	inFlight := newQueueCancellations()
+	ctx, stop := context.WithCancel(context.Background())
+	defer stop()
+	go inFlight.watch(ctx, repo)
+	cancelledCtx := inFlight.start(ctx, items[0].ID)
+	keptCtx := inFlight.start(ctx, items[1].ID)
+	defer inFlight.finish(items[1].ID)
+
+	if _, err := repo.CancelQueueItem("cook@example.com", items[0].ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	select {
+	case <-cancelledCtx.Done():
+		if cause := context.Cause(cancelledCtx); cause != errQueueItemCancelled {
+			t.Fatalf("cause = %v, want errQueueItemCancelled", cause)
+		}
+	case <-time.After(3 * queueCancelPollInterval):
+		t.Fatal("in-flight item's context wasn't cancelled")
+	}
+	if keptCtx.Err() != nil {
+		t.Fatal("other item's context was cancelled")
+	}
+}
@@ -2,16 +2,23 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net/http"
 	"sync"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
-func runQueueProcessor(ctx context.Context, repo *RecipeRepository) {
+// runQueueProcessor polls the queue table for ready items, every poll
+// interval or sooner when woken.
+func runQueueProcessor(ctx context.Context, repo *RecipeRepository, wake <-chan struct{}) {
 	log.Println("queue processor started")
-	safeProcessQueueBatch(repo)
-	ticker := time.NewTicker(queuePollInterval)
+	queueLiveness.beat(time.Now())
+	safeProcessQueueBatch(ctx, repo)
+	ticker := time.NewTicker(queueSettings.PollInterval)
 	defer ticker.Stop()
 	for {
 		select {
@@ -20,23 +27,34 @@ func runQueueProcessor(ctx context.Context, repo *RecipeRepository) {
 			return
 		case <-ticker.C:
 			log.Println("queue processor tick")
-			safeProcessQueueBatch(repo)
+		case <-wake:
+			log.Println("queue processor woken")
 		}
+		safeProcessQueueBatch(ctx, repo)
 	}
 }
 
-func safeProcessQueueBatch(repo *RecipeRepository) {
+func safeProcessQueueBatch(ctx context.Context, repo *RecipeRepository) {
+	queueLiveness.setBusy(true)
+	defer func() {
+		queueLiveness.setBusy(false)
+		queueLiveness.beat(time.Now())
+	}()
 	defer func() {
 		if r := recover(); r != nil {
 			log.Printf("queue processor recovered from panic: %v", r)
+			reportQueuePanic(ctx, r, nil)
 		}
 	}()
 
-	processQueueBatch(repo)
+	processQueueBatch(ctx, repo)
 }
 
-func processQueueBatch(repo *RecipeRepository) {
-	items, err := repo.FetchPendingQueue(queueBatchSize)
+// processQueueBatch imports a batch of ready items. Once ctx is done no more
+// items are started, and those in flight get the drain timeout to finish
+// before they are cancelled back to pending.
+func processQueueBatch(ctx context.Context, repo *RecipeRepository) {
+	items, err := repo.ClaimPendingQueue(scrapeInstanceID, queueSettings.BatchSize)
 	if err != nil {
 		log.Printf("Queue: fetch error: %v", err)
 		return
@@ -44,35 +62,128 @@ func processQueueBatch(repo *RecipeRepository) {
 
 	if len(items) == 0 {
 		log.Println("Queue: empty")
+		queueBatchItems.Observe(0)
 		return
 	}
 
-	log.Printf("Queue: processing %d item(s) with concurrency=%d", len(items), queueConcurrency)
+	log.Printf("Queue: processing %d item(s) with concurrency=%d", len(items), queueSettings.Concurrency)
+	queueBatchItems.Observe(float64(len(items)))
+
+	work, stopWork := drainContext(ctx, queueSettings.DrainTimeout)
+	defer stopWork()
+	inFlight := newQueueCancellations()
+	go inFlight.watch(work, repo)
 
 	// Concurrency limiter
-	workerSlots := make(chan struct{}, queueConcurrency)
+	workerSlots := make(chan struct{}, queueSettings.Concurrency)
 	var wg sync.WaitGroup
 
+	started := 0
+launch:
 	for _, item := range items {
-		workerSlots <- struct{}{}
+		select {
+		case workerSlots <- struct{}{}:
+		case <-ctx.Done():
+			break launch
+		}
+		// A slot freeing up and ctx ending together pick a case at random
+		if ctx.Err() != nil {
+			<-workerSlots
+			break launch
+		}
+		started++
 		wg.Add(1)
 		go func(itm QueueModel) {
 			defer func() {
 				<-workerSlots
 				wg.Done()
 			}()
-			processQueueItem(repo, itm)
+			defer releaseQueueClaim(repo, itm.ID)
+			runQueueItem(work, repo, inFlight, itm)
 		}(item)
 	}
+	if started < len(items) {
+		log.Printf("Queue: shutting down; left %d item(s) pending", len(items)-started)
+		for _, item := range items[started:] {
+			releaseQueueClaim(repo, item.ID)
+		}
+	}
 
 	wg.Wait()
 }
 
-func processQueueItem(repo *RecipeRepository, item QueueModel) {
+// releaseQueueClaim hands item id back, so an item left pending can be
+// taken straight away rather than once the claim lapses.
+func releaseQueueClaim(repo *RecipeRepository, id uint) {
+	if err := repo.ReleaseQueueClaim(id, scrapeInstanceID); err != nil {
+		log.Printf("Queue: item %d: %v", id, err)
+	}
+}
+
+// errQueueItemTimeout fails an attempt that ran past QUEUE_ITEM_TIMEOUT.
+var errQueueItemTimeout = errors.New("queue item timed out")
+
+// errQueueShutdown is the cause of an in-flight item's context when the
+// drain timeout runs out.
+var errQueueShutdown = errors.New("queue shutting down")
+
+// drainContext returns a context for in-flight work that outlives ctx by up
+// to timeout: once ctx is done the work has that long to finish before it
+// is cancelled with errQueueShutdown. The returned func releases it.
+func drainContext(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	work, cancel := context.WithCancelCause(context.WithoutCancel(ctx))
+	go func() {
+		select {
+		case <-work.Done():
+			return
+		case <-ctx.Done():
+		}
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		select {
+		case <-work.Done():
+		case <-timer.C:
+			log.Printf("Queue: in-flight items still running after %s; cancelling them", timeout)
+			cancel(errQueueShutdown)
+		}
+	}()
+	return work, func() { cancel(nil) }
+}
+
+// runQueueItem processes item under a context inFlight can cancel, and
+// records it in the queue metrics.
+func runQueueItem(ctx context.Context, repo *RecipeRepository, inFlight *queueCancellations, item QueueModel) {
+	itemCtx := inFlight.start(ctx, item.ID)
+	defer inFlight.finish(item.ID)
+	started := time.Now()
+	outcome := processQueueItem(itemCtx, repo, item)
+	if outcome == queueOutcomeDeferred {
+		// Left pending mid-import; it waits in the queue again
+		if err := repo.SetQueueItemStage(item.ID, queueStageQueued); err != nil {
+			log.Printf("Queue: item %d: %v", item.ID, err)
+		}
+	}
+	observeQueueItem(outcome, started)
+}
+
+// processQueueItem imports one queue item and reports the outcome for the
+// queue metrics.
+func processQueueItem(ctx context.Context, repo *RecipeRepository, item QueueModel) (outcome string) {
+	ctx, span := startSpan(ctx, "queue.item",
+		attribute.Int64("queue.item_id", int64(item.ID)),
+		attribute.Int("queue.attempts", item.Attempts),
+		attribute.String("url.full", item.URL),
+	)
+	defer func() {
+		span.SetAttributes(attribute.String("queue.outcome", outcome))
+		span.End()
+	}()
 	defer func() {
 		if r := recover(); r != nil {
+			outcome = queueOutcomeError
 			err := fmt.Errorf("queue item %d panic: %v", item.ID, r)
 			log.Println(err)
+			reportQueuePanic(ctx, r, &item)
 			if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
 				log.Printf("failed to mark queue item %d after panic: %v", item.ID, markErr)
 			}
@@ -86,26 +197,108 @@ func processQueueItem(repo *RecipeRepository, item QueueModel) {
 		if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
 			log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
 		}
-		return
+		return queueOutcomeError
 	}
 
 	log.Printf("Queue: processing item %d for user %s", item.ID, username)
-	if linked, slug, err := repo.LinkRecipeIfExists(username, item.URL); err != nil {
-		log.Printf("Queue: item %d failed linking existing recipe: %v", item.ID, err)
+	// Queue bookkeeping goes through repo and still lands once ctx is done;
+	// the import's own queries use itemRepo and stop with it
+	ctx, cancel := context.WithTimeoutCause(ctx, queueSettings.ItemTimeout, errQueueItemTimeout)
+	defer cancel()
+	itemRepo := repo.WithContext(ctx)
+	hasPageHTML := item.PageHTML != nil && *item.PageHTML != ""
+	opts := importOptions{Username: username, ctx: ctx}
+	opts.onStage = func(stage string) {
+		if err := repo.SetQueueItemStage(item.ID, stage); err != nil {
+			log.Printf("Queue: item %d: %v", item.ID, err)
+		}
+	}
+	if item.AIModel != nil {
+		opts.AIModel = *item.AIModel
+	}
+	// Captured HTML is usually sent because scraping failed, and a model
+	// override asks for a fresh extraction, so neither links an existing
+	// (possibly placeholder) recipe
+	if !hasPageHTML && opts.AIModel == "" {
+		linked, slug, err := itemRepo.LinkRecipeIfExists(username, item.URL)
+		if err != nil {
+			log.Printf("Queue: item %d failed linking existing recipe: %v", item.ID, err)
+			if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
+				log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
+			}
+			notifyQueueFailure(username, item, err)
+			return queueOutcomeError
+		}
+		if linked {
+			recipesSavedTotal.WithLabelValues(recipeSourceLinked).Inc()
+			recipeCache.Delete(singleRecipeCacheKey(username, slug))
+			invalidateUserRecipeCaches(username)
+			if err := repo.MarkQueueItemSaved(item.ID, slug); err != nil {
+				log.Printf("Queue: failed to finalize item %d: %v", item.ID, err)
+			}
+			fireWebhookEvent(username, webhookEventRecipeProcessed, queueEventData{QueueItemID: item.ID, URL: item.URL, Slug: slug})
+			return queueOutcomeSaved
+		}
+	}
+
+	var (
+		recipe Recipe
+		slug   string
+		err    error
+	)
+	if hasPageHTML {
+		recipe, slug, err = extractRecipeFromHTML(item.URL, *item.PageHTML, opts)
+	} else {
+		opts.setStage(queueStageFetching)
+		recipe, slug, err = scrapeRecipeOnce(repo, item.URL, opts)
+	}
+	if ctx.Err() != nil && errors.Is(context.Cause(ctx), errQueueItemCancelled) {
+		// Cancelled by the user, who has already been told; nothing is saved
+		log.Printf("Queue: item %d cancelled", item.ID)
+		return queueOutcomeCancelled
+	}
+	if ctx.Err() != nil && errors.Is(context.Cause(ctx), errQueueItemTimeout) {
+		log.Printf("Queue: item %d ran past %s", item.ID, queueSettings.ItemTimeout)
+		err = errQueueItemTimeout
+	} else if ctx.Err() != nil {
+		// The worker is shutting down: nothing is saved and the item stays
+		// pending, without using an attempt, for the next worker
+		log.Printf("Queue: item %d interrupted by shutdown; left pending", item.ID)
+		return queueOutcomeDeferred
+	}
+	if errors.Is(err, context.Canceled) {
+		// Another user's import of the page was cancelled while this one
+		// waited on it
+		log.Printf("Queue: item %d deferred: shared scrape was cancelled", item.ID)
+		return queueOutcomeDeferred
+	}
+	if errors.Is(err, errScrapeInProgress) {
+		// Not the item's fault: leave it pending without using an attempt
+		// and pick it up on a later poll, by when its AI extraction is cached
+		log.Printf("Queue: item %d deferred: %v", item.ID, err)
+		return queueOutcomeDeferred
+	}
+	if errors.Is(err, errDisallowedByRobots) {
+		// A placeholder would hide why; the user can still send the page
+		// from the browser extension
+		log.Printf("Queue: item %d %v", item.ID, err)
 		if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
 			log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
 		}
-		return
-	} else if linked {
-		recipeCache.Delete(singleRecipeCacheKey(username, slug))
-		invalidateUserRecipeCaches(username)
-		if err := repo.MarkQueueItemResult(item.ID, nil); err != nil {
-			log.Printf("Queue: failed to finalize item %d: %v", item.ID, err)
+		fireWebhookEvent(username, webhookEventRecipeFailed, queueEventData{
+			QueueItemID: item.ID, URL: item.URL, Error: err.Error(), ErrorCode: queueErrorCode(err),
+		})
+		return queueOutcomeFailed
+	}
+	if err != nil && !queueErrorIsPermanent(err) && item.Attempts+1 < queueSettings.MaxAttempts {
+		// Leave transient failures for a later poll; the placeholder below
+		// is for pages that will never come back
+		log.Printf("Queue: item %d failed, will retry: %v", item.ID, err)
+		if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
+			log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
 		}
-		return
+		return queueOutcomeError
 	}
-
-	recipe, slug, err := getRecipe(item.URL)
 	if err != nil {
 		log.Printf("Queue: item %d failed to fetch recipe: %v", item.ID, err)
 		// Fallback: create a placeholder recipe so the user can see the item
@@ -122,17 +315,24 @@ func processQueueItem(repo *RecipeRepository, item QueueModel) {
 			if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
 				log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
 			}
-			return
+			notifyQueueFailure(username, item, err)
+			return queueOutcomeError
 		}
-		// Mark processed since we stored a placeholder successfully
+		// The placeholder lets the user see the item; the failure is kept
+		// for /queue/failed
+		scrapeResultsTotal.WithLabelValues(scrapeResultPlaceholder).Inc()
 		recipeCache.Delete(singleRecipeCacheKey(username, fallbackSlug))
 		invalidateUserRecipeCaches(username)
-		if markErr := repo.MarkQueueItemResult(item.ID, nil); markErr != nil {
+		if markErr := repo.MarkQueueItemFailed(item.ID, fallbackSlug, err); markErr != nil {
 			log.Printf("Queue: failed to finalize item %d after placeholder save: %v", item.ID, markErr)
 		}
-		return
+		fireWebhookEvent(username, webhookEventRecipeFailed, queueEventData{
+			QueueItemID: item.ID, URL: item.URL, Slug: fallbackSlug, Title: title, Error: err.Error(), ErrorCode: queueErrorCode(err),
+		})
+		return queueOutcomeFailed
 	}
 	recipe.Link = fmt.Sprintf("/recipes/%s/%s", recipe.Category, slug)
+	opts.setStage(queueStageSaving)
 
 	if !recipeIsComplete(recipe) {
 		// Save a minimal placeholder so the user has something (title/image/original URL)
@@ -154,33 +354,116 @@ func processQueueItem(repo *RecipeRepository, item QueueModel) {
 			Ingredients:  []string{},
 			Instructions: []string{},
 		}
-		if saveErr := repo.SaveRecipeForUser(username, minimalSlug, placeholder); saveErr != nil {
+		if saveErr := itemRepo.SaveRecipeForUser(username, minimalSlug, placeholder); saveErr != nil {
 			log.Printf("Queue: item %d failed to save minimal placeholder: %v", item.ID, saveErr)
 			if markErr := repo.MarkQueueItemResult(item.ID, saveErr); markErr != nil {
 				log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
 			}
-			return
+			notifyQueueFailure(username, item, saveErr)
+			return queueOutcomeError
 		}
+		scrapeResultsTotal.WithLabelValues(scrapeResultIncomplete).Inc()
 		recipeCache.Delete(singleRecipeCacheKey(username, minimalSlug))
 		invalidateUserRecipeCaches(username)
-		if markErr := repo.MarkQueueItemResult(item.ID, nil); markErr != nil {
+		if markErr := repo.MarkQueueItemFailed(item.ID, minimalSlug, errRecipeIncomplete); markErr != nil {
 			log.Printf("Queue: failed to finalize item %d after minimal placeholder save: %v", item.ID, markErr)
 		}
-		return
+		fireWebhookEvent(username, webhookEventRecipeFailed, queueEventData{
+			QueueItemID: item.ID, URL: item.URL, Slug: minimalSlug, Title: minimalTitle, Error: errRecipeIncomplete.Error(), ErrorCode: queueErrorIncomplete,
+		})
+		return queueOutcomeFailed
 	}
 
-	if err := repo.SaveRecipeForUser(username, slug, recipe); err != nil {
+	if err := itemRepo.SaveRecipeForUser(username, slug, recipe); err != nil {
 		log.Printf("Queue: item %d failed to save recipe: %v", item.ID, err)
 		if markErr := repo.MarkQueueItemResult(item.ID, err); markErr != nil {
 			log.Printf("failed to mark queue item %d: %v", item.ID, markErr)
 		}
-		return
+		notifyQueueFailure(username, item, err)
+		return queueOutcomeError
+	}
+
+	scrapeResultsTotal.WithLabelValues(scrapeResultComplete).Inc()
+	recipesSavedTotal.WithLabelValues(recipeSourceScraped).Inc()
+
+	// A placeholder from an earlier failed import of the page may be under
+	// another slug
+	if removed, err := itemRepo.DeleteIncompleteRecipesForURL(username, item.URL, slug); err != nil {
+		log.Printf("Queue: item %d failed to remove placeholders: %v", item.ID, err)
+	} else if removed > 0 {
+		log.Printf("Queue: item %d replaced %d placeholder recipes", item.ID, removed)
 	}
 
 	recipeCache.Delete(singleRecipeCacheKey(username, slug))
 	invalidateUserRecipeCaches(username)
 
-	if err := repo.MarkQueueItemResult(item.ID, nil); err != nil {
+	if err := repo.MarkQueueItemSaved(item.ID, slug); err != nil {
 		log.Printf("Queue: failed to finalize item %d: %v", item.ID, err)
 	}
+	fireWebhookEvent(username, webhookEventRecipeProcessed, queueEventData{
+		QueueItemID: item.ID, URL: item.URL, Slug: slug, Title: recipe.Title,
+	})
+	return queueOutcomeSaved
+}
+
+// Queue error codes stored with last_error.
+const (
+	queueErrorRobotsDisallowed = "robots_disallowed"
+	queueErrorBlocked          = "blocked"
+	queueErrorPaywall          = "paywall"
+	queueErrorNotFound         = "not_found"
+	queueErrorAI               = "ai_error"
+	queueErrorIncomplete       = "incomplete"
+)
+
+// errRecipeIncomplete fails an item whose page gave only part of a recipe.
+var errRecipeIncomplete = errors.New("recipe incomplete")
+
+// queueErrorCode classifies a processing error for API clients, or returns
+// "" for ordinary failures.
+func queueErrorCode(err error) string {
+	var statusErr *httpStatusError
+	switch {
+	case errors.Is(err, errDisallowedByRobots):
+		return queueErrorRobotsDisallowed
+	case errors.Is(err, errPaywalled):
+		return queueErrorPaywall
+	case errors.Is(err, errPageBlocked):
+		return queueErrorBlocked
+	case errors.Is(err, errAIExtraction):
+		return queueErrorAI
+	case errors.Is(err, errRecipeIncomplete):
+		return queueErrorIncomplete
+	case errors.As(err, &statusErr):
+		switch statusErr.StatusCode {
+		case http.StatusPaymentRequired:
+			return queueErrorPaywall
+		case http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests, http.StatusUnavailableForLegalReasons:
+			return queueErrorBlocked
+		case http.StatusNotFound, http.StatusGone:
+			return queueErrorNotFound
+		}
+	}
+	return ""
+}
+
+// queueErrorIsPermanent reports errors that retrying can't fix, so the item
+// is finished on the first attempt.
+func queueErrorIsPermanent(err error) bool {
+	var scrapeErr *ScrapeError
+	if errors.As(err, &scrapeErr) {
+		return scrapeErr.Permanent
+	}
+	return errors.Is(err, errDisallowedByRobots) || errors.Is(err, errPaywalled)
+}
+
+// notifyQueueFailure fires recipe.failed once an item has used its last
+// attempt; earlier failures are retried silently.
+func notifyQueueFailure(username string, item QueueModel, err error) {
+	if item.Attempts+1 < queueSettings.MaxAttempts {
+		return
+	}
+	fireWebhookEvent(username, webhookEventRecipeFailed, queueEventData{
+		QueueItemID: item.ID, URL: item.URL, Error: err.Error(), ErrorCode: queueErrorCode(err),
+	})
 }
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestQueueErrorCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want string
+	}{
+		{fmt.Errorf("fetch: %w", errDisallowedByRobots), queueErrorRobotsDisallowed},
+		{errPageBlocked, queueErrorBlocked},
+		{errPaywalled, queueErrorPaywall},
+		{fmt.Errorf("%w: timeout", errAIExtraction), queueErrorAI},
+		{errRecipeIncomplete, queueErrorIncomplete},
+		{&httpStatusError{StatusCode: http.StatusForbidden}, queueErrorBlocked},
+		{&httpStatusError{StatusCode: http.StatusPaymentRequired}, queueErrorPaywall},
+		{&httpStatusError{StatusCode: http.StatusNotFound}, queueErrorNotFound},
+		{&httpStatusError{StatusCode: http.StatusBadGateway}, ""},
+		{fmt.Errorf("connection reset"), ""},
+	}
+	for _, tc := range cases {
+		if got := queueErrorCode(tc.err); got != tc.want {
+			t.Errorf("queueErrorCode(%v) = %q, want %q", tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestDrainContextOutlivesParentUntilTimeout(t *testing.T) {
+	ctx, stop := context.WithCancel(context.Background())
+	work, release := drainContext(ctx, 50*time.Millisecond)
+	defer release()
+
+	stop()
+	select {
+	case <-work.Done():
+		t.Fatal("work was cancelled as soon as the parent was")
+	case <-time.After(10 * time.Millisecond):
+	}
+	select {
+	case <-work.Done():
+	case <-time.After(time.Second):
+		t.Fatal("work wasn't cancelled after the drain timeout")
+	}
+	if cause := context.Cause(work); cause != errQueueShutdown {
+		t.Fatalf("cause = %v, want errQueueShutdown", cause)
+	}
+}
+
+func TestProcessQueueItemInterruptedByShutdownStaysPending(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	page := `<html><head><script type="application/ld+json">
+{"@type":"Recipe","name":"Toast","recipeIngredient":["bread"],"recipeInstructions":["Toast it"]}
+</script></head></html>`
+	if err := repo.EnqueueRecipeHTML("cook@example.com", "https://example.com/toast", page, queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	items, err := repo.FetchPendingQueue(10)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("pending = %d items (err %v), want 1", len(items), err)
+	}
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errQueueShutdown)
+	if outcome := processQueueItem(ctx, repo, items[0]); outcome != queueOutcomeDeferred {
+		t.Fatalf("outcome = %q, want %q", outcome, queueOutcomeDeferred)
+	}
+
+	item, err := repo.GetQueueItem(items[0].ID)
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if item.ProcessedAt != nil || item.Attempts != 0 || item.LastError != nil {
+		t.Fatalf("item = processed %v, attempts %d, error %v; want untouched", item.ProcessedAt, item.Attempts, item.LastError)
+	}
+}
+
+func TestProcessQueueItemTimesOut(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			http.NotFound(w, r)
+			return
+		}
+		// Hang until the fetch gives up
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	previousConfig := appConfig
+	appConfig.Scraper.MaxRetries = 0
+	t.Cleanup(func() { appConfig = previousConfig })
+	previousRobots := robotsCache
+	robotsCache = cache.New(time.Minute, time.Minute)
+	t.Cleanup(func() { robotsCache = previousRobots })
+	previous := queueSettings
+	queueSettings.ItemTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { queueSettings = previous })
+
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.EnqueueRecipe("cook@example.com", server.URL+"/menu.pdf", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	items, err := repo.FetchPendingQueue(10)
+	if err != nil || len(items) != 1 {
+		t.Fatalf("pending = %d items (err %v), want 1", len(items), err)
+	}
+
+	started := time.Now()
+	if outcome := processQueueItem(context.Background(), repo, items[0]); outcome != queueOutcomeError {
+		t.Fatalf("outcome = %q, want %q", outcome, queueOutcomeError)
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Fatalf("took %s, want the 200ms deadline to stop the fetch", elapsed)
+	}
+
+	item, err := repo.GetQueueItem(items[0].ID)
+	if err != nil {
+		t.Fatalf("get item: %v", err)
+	}
+	if item.LastError == nil || !strings.Contains(*item.LastError, errQueueItemTimeout.Error()) {
+		t.Fatalf("last error = %v, want the timeout", item.LastError)
+	}
+	if item.ProcessedAt != nil || item.Attempts != 1 {
+		t.Fatalf("item = processed %v, attempts %d; want a failed attempt left to retry", item.ProcessedAt, item.Attempts)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// QueueSettings tune the queue processor. They default to the constants
+// and can be set per deployment:
+//
+//	QUEUE_POLL_INTERVAL       how often the queue is checked (>= 1s)
+//	QUEUE_BATCH_SIZE          items taken per check (1-1000)
+//	QUEUE_CONCURRENCY         items imported at once (1-64, at most the batch size)
+//	QUEUE_MAX_ATTEMPTS        attempts before an item is dead-lettered (1-100)
+//	QUEUE_RETRY_BASE_DELAY    backoff after the first failed attempt
+//	QUEUE_RETRY_MAX_DELAY     longest backoff (>= the base delay)
+//	QUEUE_DRAIN_TIMEOUT       how long shutdown waits for in-flight items
+//	QUEUE_ITEM_TIMEOUT        longest one import may take (under the 30m claim lease)
+//	QUEUE_BACKEND, REDIS_URL  where items wait; see newJobQueue
+type QueueSettings struct {
+	PollInterval   time.Duration
+	BatchSize      int
+	Concurrency    int
+	MaxAttempts    int
+	RetryBaseDelay time.Duration
+	RetryMaxDelay  time.Duration
+	DrainTimeout   time.Duration
+	ItemTimeout    time.Duration
+	Backend        string
+	RedisURL       string
+}
+
+// queueSettings is what the processor and repository use; main replaces the
+// defaults with appConfig.Queue at startup.
+var queueSettings = defaultQueueSettings()
+
+func defaultQueueSettings() QueueSettings {
+	return QueueSettings{
+		PollInterval:   queuePollInterval,
+		BatchSize:      queueBatchSize,
+		Concurrency:    queueConcurrency,
+		MaxAttempts:    maxQueueAttempts,
+		RetryBaseDelay: queueRetryBaseDelay,
+		RetryMaxDelay:  queueRetryMaxDelay,
+		DrainTimeout:   queueDrainTimeout,
+		ItemTimeout:    queueItemTimeout,
+		Backend:        queueBackendSQLite,
+	}
+}
+
+// loadQueueSettings reads the QUEUE_* variables for loadAppConfig.
+func loadQueueSettings() (QueueSettings, error) {
+	settings := defaultQueueSettings()
+	var errs []error
+	configDuration(&errs, "QUEUE_POLL_INTERVAL", &settings.PollInterval)
+	configInt(&errs, "QUEUE_BATCH_SIZE", &settings.BatchSize)
+	configInt(&errs, "QUEUE_CONCURRENCY", &settings.Concurrency)
+	configInt(&errs, "QUEUE_MAX_ATTEMPTS", &settings.MaxAttempts)
+	configDuration(&errs, "QUEUE_RETRY_BASE_DELAY", &settings.RetryBaseDelay)
+	configDuration(&errs, "QUEUE_RETRY_MAX_DELAY", &settings.RetryMaxDelay)
+	configDuration(&errs, "QUEUE_DRAIN_TIMEOUT", &settings.DrainTimeout)
+	configDuration(&errs, "QUEUE_ITEM_TIMEOUT", &settings.ItemTimeout)
+	settings.Backend = strings.ToLower(configEnv("QUEUE_BACKEND", settings.Backend))
+	settings.RedisURL = configEnv("REDIS_URL", "")
+	if len(errs) > 0 {
+		return settings, errors.Join(errs...)
+	}
+	return settings, settings.validate()
+}
+
+func (s QueueSettings) validate() error {
+	var errs []error
+	if s.PollInterval < time.Second {
+		errs = append(errs, fmt.Errorf("QUEUE_POLL_INTERVAL: %s is shorter than 1s", s.PollInterval))
+	}
+	if s.BatchSize < 1 || s.BatchSize > 1000 {
+		errs = append(errs, fmt.Errorf("QUEUE_BATCH_SIZE: %d is not between 1 and 1000", s.BatchSize))
+	}
+	if s.Concurrency < 1 || s.Concurrency > 64 {
+		errs = append(errs, fmt.Errorf("QUEUE_CONCURRENCY: %d is not between 1 and 64", s.Concurrency))
+	} else if s.Concurrency > s.BatchSize {
+		errs = append(errs, fmt.Errorf("QUEUE_CONCURRENCY: %d is more than QUEUE_BATCH_SIZE %d", s.Concurrency, s.BatchSize))
+	}
+	if s.MaxAttempts < 1 || s.MaxAttempts > 100 {
+		errs = append(errs, fmt.Errorf("QUEUE_MAX_ATTEMPTS: %d is not between 1 and 100", s.MaxAttempts))
+	}
+	if s.RetryBaseDelay < 0 {
+		errs = append(errs, fmt.Errorf("QUEUE_RETRY_BASE_DELAY: %s is negative", s.RetryBaseDelay))
+	}
+	if s.RetryMaxDelay < s.RetryBaseDelay {
+		errs = append(errs, fmt.Errorf("QUEUE_RETRY_MAX_DELAY: %s is shorter than QUEUE_RETRY_BASE_DELAY %s", s.RetryMaxDelay, s.RetryBaseDelay))
+	}
+	if s.DrainTimeout < 0 {
+		errs = append(errs, fmt.Errorf("QUEUE_DRAIN_TIMEOUT: %s is negative", s.DrainTimeout))
+	}
+	if s.ItemTimeout < time.Second || s.ItemTimeout >= queueClaimLease {
+		// A worker still importing an item past its lease would see it
+		// taken over by another
+		errs = append(errs, fmt.Errorf("QUEUE_ITEM_TIMEOUT: %s is not between 1s and the %s claim lease", s.ItemTimeout, queueClaimLease))
+	}
+	switch s.Backend {
+	case queueBackendSQLite:
+	case queueBackendRedis:
+		if s.RedisURL == "" {
+			errs = append(errs, errors.New("REDIS_URL: missing, QUEUE_BACKEND=redis needs it"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("QUEUE_BACKEND: %q is not sqlite or redis", s.Backend))
+	}
+	return errors.Join(errs...)
+}
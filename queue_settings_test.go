@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadQueueSettings(t *testing.T) {
+	t.Setenv("QUEUE_POLL_INTERVAL", "10s")
+	t.Setenv("QUEUE_BATCH_SIZE", "20")
+	t.Setenv("QUEUE_CONCURRENCY", "8")
+	t.Setenv("QUEUE_MAX_ATTEMPTS", "3")
+	t.Setenv("QUEUE_DRAIN_TIMEOUT", "2m")
+	t.Setenv("QUEUE_ITEM_TIMEOUT", "5m")
+
+	settings, err := loadQueueSettings()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	want := defaultQueueSettings()
+	want.PollInterval = 10 * time.Second
+	want.BatchSize = 20
+	want.Concurrency = 8
+	want.MaxAttempts = 3
+	want.DrainTimeout = 2 * time.Minute
+	want.ItemTimeout = 5 * time.Minute
+	if settings != want {
+		t.Fatalf("settings = %+v, want %+v", settings, want)
+	}
+}
+
+func TestLoadQueueSettingsRejectsBadValues(t *testing.T) {
+	for _, tc := range []struct {
+		name, value, want string
+	}{
+		{"QUEUE_BATCH_SIZE", "lots", "not an integer"},
+		{"QUEUE_POLL_INTERVAL", "100ms", "shorter than 1s"},
+		{"QUEUE_POLL_INTERVAL", "5", "not a duration"},
+		{"QUEUE_CONCURRENCY", "0", "not between 1 and 64"},
+		{"QUEUE_CONCURRENCY", "10", "more than QUEUE_BATCH_SIZE"},
+		{"QUEUE_MAX_ATTEMPTS", "0", "not between 1 and 100"},
+		{"QUEUE_RETRY_MAX_DELAY", "1s", "shorter than QUEUE_RETRY_BASE_DELAY"},
+		{"QUEUE_DRAIN_TIMEOUT", "-1s", "negative"},
+		{"QUEUE_ITEM_TIMEOUT", "45m", "claim lease"},
+	} {
+		t.Run(tc.name+"="+tc.value, func(t *testing.T) {
+			t.Setenv(tc.name, tc.value)
+			_, err := loadQueueSettings()
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("err = %v, want it to mention %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+// Before the AI sees a page it is cut down to the part that holds the
+// recipe: chrome, comments and ads are dropped, then a recipe plugin card or
+// the highest scoring article block is kept. This mostly follows Mozilla
+// Readability's paragraph scoring, with list items counted too since
+// ingredients live in them.
+
+// boilerplateSelectors never hold the recipe.
+const boilerplateSelectors = `script, style, noscript, template, svg, iframe, form, button, select, input,
+	nav, header, footer, aside, [role="navigation"], [role="banner"], [role="contentinfo"], [role="complementary"],
+	[aria-hidden="true"], [hidden],
+	#comments, .comments, #respond, .comment-respond, .comment-list, .commentlist, [id^="comment-"], [class*="comments-area"],
+	.ad, .ads, .advert, .advertisement, [id^="ad-"], [id^="ad_"], [class^="ad-"], [class*=" ad-"], [id^="google_ads"], .adsbygoogle,
+	.sidebar, #sidebar, .widget, .related, .related-posts, .share, .sharing, .social, .social-share, .newsletter, .subscribe, .breadcrumbs, .pagination`
+
+// recipeCardSelectors match the recipe blocks of common WordPress recipe
+// plugins and schema.org microdata.
+const recipeCardSelectors = `.wprm-recipe-container, .wprm-recipe, .tasty-recipes, .mv-create-card, .easyrecipe, .recipe-card,
+	[class*="recipe-card"], .jetpack-recipe, .zrdn-recipe-container, [itemtype*="schema.org/Recipe"]`
+
+var (
+	positiveClassPattern = regexp.MustCompile(`(?i)recipe|ingredient|instruction|direction|method|article|content|entry|post|main|body|text`)
+	negativeClassPattern = regexp.MustCompile(`(?i)comment|sidebar|footer|promo|sponsor|widget|related|share|social|banner|popup|modal|newsletter|subscribe|menu|nav`)
+)
+
+// readableRecipeText returns the recipe-relevant text of a page, headed by
+// its title, one block per line.
+func readableRecipeText(doc *goquery.Document) string {
+	body := doc.Find("body").First().Clone()
+	if body.Length() == 0 {
+		body = doc.Selection.Clone()
+	}
+	body.Find(boilerplateSelectors).Remove()
+
+	main := recipePluginBlock(body)
+	if main == nil {
+		main = bestContentBlock(body)
+	}
+
+	var b strings.Builder
+	title := strings.TrimSpace(doc.Find("h1").First().Text())
+	if title == "" {
+		title = strings.TrimSpace(doc.Find("title").First().Text())
+	}
+	if title != "" {
+		b.WriteString(strings.Join(strings.Fields(title), " "))
+		b.WriteString("\n\n")
+	}
+	b.WriteString(blockText(main))
+	return strings.TrimSpace(b.String())
+}
+
+// recipePluginBlock returns the largest recipe plugin block with enough text
+// to be the real card rather than a jump-to-recipe button.
+func recipePluginBlock(body *goquery.Selection) *goquery.Selection {
+	var best *goquery.Selection
+	bestLen := minRecipeCardChars
+	body.Find(recipeCardSelectors).Each(func(_ int, s *goquery.Selection) {
+		if n := len(strings.TrimSpace(s.Text())); n >= bestLen {
+			best, bestLen = s, n
+		}
+	})
+	return best
+}
+
+// bestContentBlock scores each paragraph-like element and credits its
+// parent and grandparent; the container with the best score, discounted by
+// how much of its text is links, wins. The whole body is the fallback.
+func bestContentBlock(body *goquery.Selection) *goquery.Selection {
+	scores := map[*html.Node]float64{}
+	body.Find("p, li, pre, td, h2, h3").Each(func(_ int, s *goquery.Selection) {
+		text := strings.TrimSpace(s.Text())
+		if len(text) < 20 {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + min(float64(len(text))/100, 3)
+		parent := s.Parent()
+		if parent.Length() == 0 {
+			return
+		}
+		scores[parent.Get(0)] += score
+		if grand := parent.Parent(); grand.Length() > 0 {
+			scores[grand.Get(0)] += score / 2
+		}
+	})
+
+	var best *html.Node
+	bestScore := 0.0
+	for node, score := range scores {
+		s := goquery.NewDocumentFromNode(node).Selection
+		class := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if positiveClassPattern.MatchString(class) {
+			score *= 1.25
+		}
+		if negativeClassPattern.MatchString(class) {
+			score *= 0.5
+		}
+		score *= 1 - linkDensity(s)
+		if score > bestScore {
+			best, bestScore = node, score
+		}
+	}
+	if best == nil {
+		return body
+	}
+	return goquery.NewDocumentFromNode(best).Selection
+}
+
+func linkDensity(s *goquery.Selection) float64 {
+	total := len(strings.TrimSpace(s.Text()))
+	if total == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(_ int, a *goquery.Selection) {
+		links += len(strings.TrimSpace(a.Text()))
+	})
+	return min(float64(links)/float64(total), 1)
+}
+
+var blockElements = map[string]bool{
+	"address": true, "article": true, "blockquote": true, "br": true, "dd": true, "div": true, "dl": true,
+	"dt": true, "figcaption": true, "figure": true, "h1": true, "h2": true, "h3": true, "h4": true,
+	"h5": true, "h6": true, "hr": true, "li": true, "main": true, "ol": true, "p": true, "pre": true,
+	"section": true, "table": true, "td": true, "th": true, "tr": true, "ul": true,
+}
+
+// blockText renders a selection as text with a line per block element, so
+// list items don't run together the way Selection.Text does.
+func blockText(s *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch n.Type {
+		case html.TextNode:
+			b.WriteString(n.Data)
+			return
+		case html.ElementNode:
+			if blockElements[n.Data] {
+				b.WriteByte('\n')
+				defer b.WriteByte('\n')
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	for _, node := range s.Nodes {
+		walk(node)
+	}
+
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		line = strings.Join(strings.Fields(line), " ")
+		if line == "" || (len(lines) > 0 && lines[len(lines)-1] == line) {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncateText cuts text to at most limit bytes on a rune boundary.
+func truncateText(text string, limit int) string {
+	if len(text) <= limit {
+		return text
+	}
+	for limit > 0 && !utf8.RuneStart(text[limit]) {
+		limit--
+	}
+	return text[:limit]
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync/atomic"
+	"time"
+)
+
+// An instance is ready for traffic (GET /readyz) once its database answers
+// with the current schema, the JWT secret is loaded and the recipe caches
+// are warm. It is alive (GET /livez) as long as it can answer at all, so a
+// slow dependency takes it out of rotation without getting it restarted.
+
+// readinessProbes are the checks /readyz runs. Each gets
+// healthCheckTimeout.
+var readinessProbes = map[string]func(ctx context.Context, now time.Time) ComponentHealth{
+	"database": func(ctx context.Context, now time.Time) ComponentHealth {
+		if recipeRepo == nil {
+			return ComponentHealth{Status: healthDown, Error: "database not opened"}
+		}
+		return timedProbe(ctx, func(ctx context.Context) error {
+			if err := recipeRepo.Ping(ctx); err != nil {
+				return err
+			}
+			return checkSchemaOnce(ctx, recipeRepo)
+		})
+	},
+	"auth": func(ctx context.Context, now time.Time) ComponentHealth {
+		if jwtSecret == "" {
+			return ComponentHealth{Status: healthDown, Error: "JWT secret not loaded"}
+		}
+		return ComponentHealth{Status: healthUp}
+	},
+	"cache": func(ctx context.Context, now time.Time) ComponentHealth {
+		if !recipeCachesWarm.Load() {
+			return ComponentHealth{Status: healthDown, Error: "warming recipe caches"}
+		}
+		return ComponentHealth{Status: healthUp}
+	},
+}
+
+// checkReadiness runs the readiness probes concurrently.
+func checkReadiness(ctx context.Context, now time.Time) HealthReport {
+	return runProbes(ctx, now, readinessProbes)
+}
+
+// schemaChecked is set once the schema has been found current; migrations
+// aren't undone under a running instance, so it isn't checked again.
+var schemaChecked atomic.Bool
+
+func checkSchemaOnce(ctx context.Context, repo *RecipeRepository) error {
+	if schemaChecked.Load() {
+		return nil
+	}
+	if err := repo.SchemaCurrent(ctx); err != nil {
+		return err
+	}
+	schemaChecked.Store(true)
+	return nil
+}
+
+// recipeCachesWarm is set once warmRecipeCaches has finished.
+var recipeCachesWarm atomic.Bool
+
+// warmRecipeCaches fills the recipe list cache for the most recently active
+// users (CACHE_WARM_USERS, default 20; 0 skips it), so the first requests
+// after a deploy don't all miss. Failures are logged and don't hold
+// readiness back.
+func warmRecipeCaches(ctx context.Context, repo *RecipeRepository) {
+	defer recipeCachesWarm.Store(true)
+	limit := appConfig.Server.CacheWarmUsers
+	if limit <= 0 {
+		return
+	}
+	started := time.Now()
+	repo = repo.WithContext(ctx)
+	usernames, err := repo.RecentlyActiveUsernames(limit)
+	if err != nil {
+		log.Printf("Cache warm-up: %v", err)
+		return
+	}
+	for _, username := range usernames {
+		if _, err := listRecipes(repo, username, RecipeFilters{}, false); err != nil {
+			if errors.Is(err, context.Canceled) {
+				return
+			}
+			log.Printf("Cache warm-up: %s: %v", username, err)
+		}
+	}
+	log.Printf("Cache warm-up: %d recipe lists in %s", len(usernames), time.Since(started).Round(time.Millisecond))
+}
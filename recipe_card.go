@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"strconv"
+	"strings"
+)
+
+const (
+	oEmbedProviderName = "Recipes"
+	defaultCardWidth   = 400
+)
+
+// oEmbedResponse is an oEmbed 1.0 "rich" response. The recipe fields are
+// extensions that consumers which know about them can use for their own card.
+type oEmbedResponse struct {
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Title        string `json:"title"`
+	ProviderName string `json:"provider_name"`
+	ProviderURL  string `json:"provider_url"`
+	AuthorName   string `json:"author_name,omitempty"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	HTML         string `json:"html"`
+	Width        int    `json:"width"`
+	// Height is null: the card's height depends on the image
+	Height    *int   `json:"height"`
+	CacheAge  int    `json:"cache_age"`
+	URL       string `json:"url"`
+	PrepTime  int    `json:"prep_time,omitempty"`
+	CookTime  int    `json:"cook_time,omitempty"`
+	TotalTime int    `json:"total_time,omitempty"`
+	Servings  int    `json:"servings,omitempty"`
+}
+
+type recipeCard struct {
+	Recipe
+	Handle    string
+	PageURL   string
+	OEmbedURL string
+	Width     int
+}
+
+func (c recipeCard) Summary() string {
+	parts := make([]string, 0, 3)
+	if total := formatMinutes(c.TotalTime); total != "" {
+		parts = append(parts, total)
+	}
+	if c.Servings > 0 {
+		parts = append(parts, "serves "+servingsText(c.Servings))
+	}
+	if n := len(c.Ingredients); n > 0 {
+		parts = append(parts, strconv.Itoa(n)+" ingredients")
+	}
+	return strings.Join(parts, " · ")
+}
+
+// cardSnippetTemplate is the embeddable card returned as oEmbed html.
+var cardSnippetTemplate = template.Must(template.New("card").Parse(`<a href="{{.PageURL}}" style="display:block;max-width:{{.Width}}px;border:1px solid #ddd;border-radius:8px;overflow:hidden;font-family:sans-serif;color:#111;text-decoration:none">
+{{- if .Image}}<img src="{{.Image}}" alt="" style="display:block;width:100%;max-height:200px;object-fit:cover">{{end -}}
+<div style="padding:10px 12px"><strong style="font-size:16px">{{.Title}}</strong>
+{{- with .Summary}}<div style="color:#555;font-size:13px;margin-top:4px">{{.}}</div>{{end -}}
+</div></a>`))
+
+// cardPageTemplate is the page crawlers fetch when a shared link is pasted:
+// OpenGraph tags for Slack/Discord/iMessage and oEmbed discovery.
+var cardPageTemplate = template.Must(template.New("card-page").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Card.Title}}</title>
+<meta property="og:type" content="article">
+<meta property="og:site_name" content="` + oEmbedProviderName + `">
+<meta property="og:title" content="{{.Card.Title}}">
+<meta property="og:url" content="{{.Card.PageURL}}">
+{{- with .Card.Summary}}
+<meta property="og:description" content="{{.}}">
+<meta name="description" content="{{.}}">
+{{- end}}
+{{- if .Card.Image}}
+<meta property="og:image" content="{{.Card.Image}}">
+<meta name="twitter:card" content="summary_large_image">
+{{- end}}
+<link rel="alternate" type="application/json+oembed" href="{{.Card.OEmbedURL}}" title="{{.Card.Title}}">
+</head>
+<body style="margin:2rem">
+{{.Snippet}}
+</body>
+</html>
+`))
+
+func renderRecipeCardSnippet(card recipeCard) (string, error) {
+	var buf bytes.Buffer
+	if err := cardSnippetTemplate.Execute(&buf, card); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func renderRecipeCardPage(card recipeCard) ([]byte, error) {
+	snippet, err := renderRecipeCardSnippet(card)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	data := struct {
+		Card    recipeCard
+		Snippet template.HTML
+	}{Card: card, Snippet: template.HTML(snippet)}
+	if err := cardPageTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+)
+
+// RSS 2.0 with the Atom self link and Media RSS thumbnails that feed readers
+// use for previews.
+type rssDocument struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	AtomNS  string     `xml:"xmlns:atom,attr"`
+	MediaNS string     `xml:"xmlns:media,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate"`
+	Self          rssLink   `xml:"atom:link"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr"`
+	Type string `xml:"type,attr"`
+}
+
+type rssItem struct {
+	Title       string        `xml:"title"`
+	Link        string        `xml:"link"`
+	GUID        rssGUID       `xml:"guid"`
+	PubDate     string        `xml:"pubDate"`
+	Category    string        `xml:"category,omitempty"`
+	Description string        `xml:"description"`
+	Thumbnail   *rssThumbnail `xml:"media:thumbnail,omitempty"`
+}
+
+type rssGUID struct {
+	Value       string `xml:",chardata"`
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+}
+
+type rssThumbnail struct {
+	URL string `xml:"url,attr"`
+}
+
+// renderRecipeFeed builds the feed; linkFor gives each recipe's page.
+func renderRecipeFeed(title, selfURL string, recipes []ExportedRecipe, linkFor func(ExportedRecipe) string) ([]byte, error) {
+	doc := rssDocument{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		MediaNS: "http://search.yahoo.com/mrss/",
+		Channel: rssChannel{
+			Title:         title,
+			Link:          selfURL,
+			Description:   "Recently saved recipes",
+			LastBuildDate: time.Now().UTC().Format(time.RFC1123Z),
+			Self:          rssLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+			Items:         make([]rssItem, 0, len(recipes)),
+		},
+	}
+
+	for _, recipe := range recipes {
+		item := rssItem{
+			Title:       recipe.Title,
+			Link:        linkFor(recipe),
+			GUID:        rssGUID{Value: fmt.Sprintf("cooking.bronson.dev:recipe:%d", recipe.ID)},
+			PubDate:     recipe.CreatedAt.UTC().Format(time.RFC1123Z),
+			Category:    recipe.Category,
+			Description: feedItemSummary(recipe.Recipe),
+		}
+		if recipe.Image != "" {
+			item.Thumbnail = &rssThumbnail{URL: recipe.Image}
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("encode feed: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// feedItemSummary is the HTML shown by readers: the photo, timings and
+// ingredient list.
+func feedItemSummary(recipe Recipe) string {
+	var b strings.Builder
+	if recipe.Image != "" {
+		fmt.Fprintf(&b, `<p><img src="%s" alt=""></p>`, html.EscapeString(recipe.Image))
+	}
+
+	meta := make([]string, 0, 3)
+	if recipe.Servings > 0 {
+		meta = append(meta, fmt.Sprintf("Serves %d", recipe.Servings))
+	}
+	if total := formatMinutes(recipe.TotalTime); total != "" {
+		meta = append(meta, "Total "+total)
+	}
+	if len(meta) > 0 {
+		fmt.Fprintf(&b, "<p>%s</p>", html.EscapeString(strings.Join(meta, " · ")))
+	}
+
+	if len(recipe.Ingredients) > 0 {
+		b.WriteString("<ul>")
+		for _, line := range recipe.Ingredients {
+			fmt.Fprintf(&b, "<li>%s</li>", html.EscapeString(strings.TrimSpace(line)))
+		}
+		b.WriteString("</ul>")
+	}
+	return b.String()
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// formatMinutes renders a duration for people: "45 min", "1 h 30 min".
+func formatMinutes(minutes int) string {
+	if minutes <= 0 {
+		return ""
+	}
+	h, m := minutes/60, minutes%60
+	switch {
+	case h == 0:
+		return fmt.Sprintf("%d min", m)
+	case m == 0:
+		return fmt.Sprintf("%d h", h)
+	default:
+		return fmt.Sprintf("%d h %d min", h, m)
+	}
+}
+
+// markdownCell escapes text for use inside a Markdown table cell.
+func markdownCell(text string) string {
+	text = strings.ReplaceAll(text, "|", "\\|")
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// renderRecipeMarkdown renders a recipe as plain Markdown: title, metadata
+// table, ingredients (grouped when the recipe has groups) and numbered steps.
+func renderRecipeMarkdown(recipe Recipe) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "# %s\n\n", strings.TrimSpace(recipe.Title))
+	if recipe.Image != "" {
+		fmt.Fprintf(&b, "![%s](%s)\n\n", markdownCell(recipe.Title), recipe.Image)
+	}
+
+	rows := [][2]string{
+		{"Category", recipe.Category},
+		{"Servings", servingsText(recipe.Servings)},
+		{"Prep time", formatMinutes(recipe.PrepTime)},
+		{"Cook time", formatMinutes(recipe.CookTime)},
+		{"Total time", formatMinutes(recipe.TotalTime)},
+		{"Tags", strings.Join(recipe.Tags, ", ")},
+		{"Source", recipe.OriginalURL},
+	}
+	b.WriteString("| | |\n|---|---|\n")
+	for _, row := range rows {
+		if strings.TrimSpace(row[1]) == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "| %s | %s |\n", row[0], markdownCell(row[1]))
+	}
+
+	b.WriteString("\n## Ingredients\n\n")
+	if len(recipe.IngredientGroups) > 0 {
+		for _, group := range recipe.IngredientGroups {
+			if group.Name != "" {
+				fmt.Fprintf(&b, "### %s\n\n", group.Name)
+			}
+			for _, ing := range group.Ingredients {
+				fmt.Fprintf(&b, "- %s\n", ingredientLine(ing))
+			}
+			b.WriteString("\n")
+		}
+	} else if len(recipe.ParsedIngredients) > 0 {
+		for _, ing := range recipe.ParsedIngredients {
+			fmt.Fprintf(&b, "- %s\n", ingredientLine(ing))
+		}
+		b.WriteString("\n")
+	} else {
+		for _, line := range recipe.Ingredients {
+			fmt.Fprintf(&b, "- %s\n", strings.TrimSpace(line))
+		}
+		b.WriteString("\n")
+	}
+
+	if len(recipe.Equipment) > 0 {
+		b.WriteString("## Equipment\n\n")
+		for _, item := range recipe.Equipment {
+			fmt.Fprintf(&b, "- %s\n", item)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("## Instructions\n\n")
+	if len(recipe.InstructionSections) > 0 {
+		for _, section := range recipe.InstructionSections {
+			if section.Name != "" {
+				fmt.Fprintf(&b, "### %s\n\n", section.Name)
+			}
+			for i, step := range section.Steps {
+				fmt.Fprintf(&b, "%d. %s\n", i+1, strings.TrimSpace(step.Text))
+			}
+			b.WriteString("\n")
+		}
+	} else {
+		for i, step := range recipe.Instructions {
+			fmt.Fprintf(&b, "%d. %s\n", i+1, strings.TrimSpace(step))
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n") + "\n"
+}
+
+func servingsText(servings int) string {
+	if servings <= 0 {
+		return ""
+	}
+	return fmt.Sprintf("%d", servings)
+}
+
+func ingredientLine(ing IngredientDetail) string {
+	if display := strings.TrimSpace(ing.Display); display != "" {
+		return display
+	}
+	return composeDisplayWithUnit(ing.AmountText, ing.Unit, ing.Description)
+}
+
+// zipRecipesMarkdown bundles the recipes as one .md file each, named by slug.
+func zipRecipesMarkdown(recipes []ExportedRecipe) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+
+	used := make(map[string]int, len(recipes))
+	for _, recipe := range recipes {
+		name := slugify(recipe.Slug)
+		if name == "" {
+			name = slugify(recipe.Title)
+		}
+		if name == "" {
+			name = "recipe"
+		}
+		used[name]++
+		if n := used[name]; n > 1 {
+			name = fmt.Sprintf("%s-%d", name, n)
+		}
+
+		w, err := zw.Create(name + ".md")
+		if err != nil {
+			return nil, fmt.Errorf("add %s: %w", name, err)
+		}
+		if _, err := w.Write([]byte(renderRecipeMarkdown(recipe.Recipe))); err != nil {
+			return nil, fmt.Errorf("write %s: %w", name, err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, fmt.Errorf("finish zip: %w", err)
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+)
+
+// printTemplate is a self-contained page (inline CSS, no scripts) for
+// printing a recipe without the frontend.
+var printTemplate = template.Must(template.New("print").Funcs(template.FuncMap{
+	"minutes":    formatMinutes,
+	"ingredient": ingredientLine,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: Georgia, "Times New Roman", serif; max-width: 46rem; margin: 2rem auto; padding: 0 1rem; color: #111; line-height: 1.45; }
+h1 { margin-bottom: .25rem; }
+h2 { border-bottom: 1px solid #ccc; padding-bottom: .2rem; margin-top: 1.6rem; }
+h3 { margin-bottom: .3rem; }
+.meta { color: #444; font-size: .95rem; }
+.meta span { margin-right: 1.2rem; white-space: nowrap; }
+img.hero { max-width: 100%; max-height: 18rem; object-fit: cover; margin: 1rem 0; }
+ol li { margin-bottom: .5rem; }
+.source { margin-top: 2rem; font-size: .85rem; color: #555; word-break: break-all; }
+@media print {
+  body { margin: 0; max-width: none; font-size: 11pt; }
+  img.hero { max-height: 12rem; }
+  a { color: inherit; text-decoration: none; }
+  h2, h3 { break-after: avoid; }
+  li { break-inside: avoid; }
+}
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<p class="meta">
+{{- if .Servings}}<span>Serves {{.Servings}}</span>{{end}}
+{{- with minutes .PrepTime}}<span>Prep {{.}}</span>{{end}}
+{{- with minutes .CookTime}}<span>Cook {{.}}</span>{{end}}
+{{- with minutes .TotalTime}}<span>Total {{.}}</span>{{end}}
+</p>
+{{- if .Image}}
+<img class="hero" src="{{.Image}}" alt="">
+{{- end}}
+
+<h2>Ingredients</h2>
+{{- if .IngredientGroups}}
+{{- range .IngredientGroups}}
+{{- if .Name}}<h3>{{.Name}}</h3>{{end}}
+<ul>{{range .Ingredients}}<li>{{ingredient .}}</li>{{end}}</ul>
+{{- end}}
+{{- else if .ParsedIngredients}}
+<ul>{{range .ParsedIngredients}}<li>{{ingredient .}}</li>{{end}}</ul>
+{{- else}}
+<ul>{{range .Ingredients}}<li>{{.}}</li>{{end}}</ul>
+{{- end}}
+
+{{- if .Equipment}}
+<h2>Equipment</h2>
+<ul>{{range .Equipment}}<li>{{.}}</li>{{end}}</ul>
+{{- end}}
+
+<h2>Instructions</h2>
+{{- if .InstructionSections}}
+{{- range .InstructionSections}}
+{{- if .Name}}<h3>{{.Name}}</h3>{{end}}
+<ol>{{range .Steps}}<li>{{.Text}}</li>{{end}}</ol>
+{{- end}}
+{{- else}}
+<ol>{{range .Instructions}}<li>{{.}}</li>{{end}}</ol>
+{{- end}}
+
+{{- if .OriginalURL}}
+<p class="source">Source: <a href="{{.OriginalURL}}">{{.OriginalURL}}</a></p>
+{{- end}}
+</body>
+</html>
+`))
+
+func renderRecipePrintHTML(recipe Recipe) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := printTemplate.Execute(&buf, recipe); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
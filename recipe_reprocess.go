@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Recipes saved as placeholders when an import failed are queued again
+// from their original URL every RECIPE_REPROCESS_INTERVAL (default hourly,
+// 0 turns it off), so they heal once the site or the scraper is fixed. Each
+// recipe waits longer between attempts, from reprocessBaseDelay doubling to
+// reprocessMaxDelay, and is left alone after maxReprocessAttempts. Admins
+// can run a pass at once with POST /admin/reprocess-incomplete.
+
+func runRecipeReprocessor(ctx context.Context, repo *RecipeRepository) {
+	interval := appConfig.Jobs.ReprocessInterval
+	if interval <= 0 {
+		log.Println("Reprocess: disabled (RECIPE_REPROCESS_INTERVAL=0)")
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if queued, err := reprocessIncompleteRecipes(repo, false); err != nil {
+				log.Printf("Reprocess: %v", err)
+			} else if queued > 0 {
+				log.Printf("Reprocess: queued %d incomplete recipes", queued)
+			}
+		}
+	}
+}
+
+// reprocessIncompleteRecipes queues the incomplete recipes that are due, or
+// all under the attempt limit with force, and returns how many it queued.
+func reprocessIncompleteRecipes(repo *RecipeRepository, force bool) (int, error) {
+	now := time.Now()
+	due, err := repo.IncompleteRecipesDue(now, maxReprocessAttempts, reprocessBatchSize, force)
+	if err != nil {
+		return 0, err
+	}
+	queued := 0
+	for _, recipe := range due {
+		if err := repo.EnqueueRecipe(recipe.Username, recipe.URL, queuePriorityBackground); err != nil {
+			log.Printf("Reprocess: failed to queue recipe %d (%s): %v", recipe.ID, recipe.URL, err)
+			continue
+		}
+		attempts := recipe.Attempts + 1
+		if err := repo.ScheduleRecipeReprocess(recipe.ID, attempts, now.Add(reprocessBackoff(attempts))); err != nil {
+			return queued, err
+		}
+		queued++
+	}
+	return queued, nil
+}
+
+// reprocessBackoff is the wait after the given number of attempts.
+func reprocessBackoff(attempts int) time.Duration {
+	delay := reprocessBaseDelay
+	for i := 1; i < attempts && delay < reprocessMaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, reprocessMaxDelay)
+}
@@ -0,0 +1,43 @@
+package main
+
+import "github.com/gin-gonic/gin"
+
+// RecipeStore is what the recipe collection handlers (list, search, get,
+// favorite, edit, delete) need from storage. RecipeRepository implements it
+// over the database; memoryRecipeStore, in the tests, keeps recipes in maps
+// so the handlers can be tested without SQLite. Lookups that find nothing
+// return sql.ErrNoRows.
+type RecipeStore interface {
+	ListRecipes(username string, filters RecipeFilters) ([]Recipe, error)
+	SearchRecipes(username string, opts RecipeSearchOptions) ([]Recipe, int64, error)
+	RandomRecipes(username string, filters RecipeFilters, limit int) ([]Recipe, error)
+	ListFavoriteRecipes(username string) ([]Recipe, error)
+	CategoryCounts(username string) ([]CategoryCount, error)
+	CategoryDetails(username string) ([]CategoryDetail, error)
+	GetRecipe(username, slug string) (Recipe, error)
+	GetRecipeByID(username string, recipeID uint) (Recipe, error)
+	SaveRecipeForUser(username, slug string, recipe Recipe) error
+	UpdateRecipeTitleAndInstructions(username, slug string, patch RecipePatch) (Recipe, error)
+	UpdateRecipeTitleAndInstructionsByID(username string, recipeID uint, patch RecipePatch) (Recipe, error)
+	MarkRecipeCookedByID(username string, recipeID uint) (Recipe, error)
+	SetFavorite(username, slug string, favorite bool) error
+	SetFavoriteByID(username string, recipeID uint, favorite bool) error
+	DeleteRecipe(username, slug string) error
+	DeleteRecipeByID(username string, recipeID uint) error
+	GetUserPreferences(username string) (UserPreferences, error)
+}
+
+var _ RecipeStore = (*RecipeRepository)(nil)
+
+// recipeStore, when set, replaces the database behind the recipe handlers;
+// tests set it to run them against an in-memory store.
+var recipeStore RecipeStore
+
+// requestStore is the store for a recipe handler: recipeStore, or the
+// database bound to the request's context.
+func requestStore(c *gin.Context) RecipeStore {
+	if recipeStore != nil {
+		return recipeStore
+	}
+	return requestRepo(c)
+}
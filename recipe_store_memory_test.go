@@ -0,0 +1,344 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryRecipeStore is a RecipeStore in maps, for handler tests that don't
+// need SQLite. It keeps the database's behaviour where the handlers can see
+// it: newest recipes first, sql.ErrNoRows for missing recipes, categories
+// checked on edit, and favorites per user.
+type memoryRecipeStore struct {
+	mu          sync.Mutex
+	nextID      uint
+	recipes     map[uint]*memoryRecipe
+	preferences map[string]UserPreferences
+}
+
+type memoryRecipe struct {
+	username string
+	slug     string
+	recipe   Recipe
+	favorite bool
+}
+
+var _ RecipeStore = (*memoryRecipeStore)(nil)
+
+func newMemoryRecipeStore() *memoryRecipeStore {
+	return &memoryRecipeStore{recipes: map[uint]*memoryRecipe{}, preferences: map[string]UserPreferences{}}
+}
+
+// userRecipes returns the user's recipes, newest first. The caller holds mu.
+func (s *memoryRecipeStore) userRecipes(username string) []*memoryRecipe {
+	var out []*memoryRecipe
+	for _, stored := range s.recipes {
+		if stored.username == username {
+			out = append(out, stored)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].recipe.ID > out[j].recipe.ID })
+	return out
+}
+
+func (s *memoryRecipeStore) find(username, slug string) *memoryRecipe {
+	for _, stored := range s.recipes {
+		if stored.username == username && stored.slug == slug {
+			return stored
+		}
+	}
+	return nil
+}
+
+func (s *memoryRecipeStore) findByID(username string, recipeID uint) *memoryRecipe {
+	if stored, ok := s.recipes[recipeID]; ok && stored.username == username {
+		return stored
+	}
+	return nil
+}
+
+func (m *memoryRecipe) view() Recipe {
+	recipe := cloneRecipe(m.recipe)
+	recipe.IsFavorite = m.favorite
+	return recipe
+}
+
+func (m *memoryRecipe) matches(f RecipeFilters) bool {
+	r := m.recipe
+	switch {
+	case f.Category != "" && r.Category != f.Category,
+		f.FavoritesOnly && !m.favorite,
+		f.MaxTotalTime > 0 && (r.TotalTime <= 0 || r.TotalTime > f.MaxTotalTime),
+		f.MinServings > 0 && r.Servings < f.MinServings,
+		f.MaxServings > 0 && r.Servings > f.MaxServings,
+		!f.NotCookedSince.IsZero() && r.LastCookedAt != nil && !r.LastCookedAt.Before(f.NotCookedSince):
+		return false
+	}
+	for _, tag := range f.Tags {
+		if !slices.Contains(r.Tags, tag) {
+			return false
+		}
+	}
+	for _, diet := range f.Diets {
+		if !slices.Contains(r.Diets, diet) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *memoryRecipeStore) ListRecipes(username string, filters RecipeFilters) ([]Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipes := []Recipe{}
+	for _, stored := range s.userRecipes(username) {
+		if stored.matches(filters) {
+			recipes = append(recipes, stored.view())
+		}
+	}
+	return recipes, nil
+}
+
+// SearchRecipes matches words literally, as the LIKE fallback does.
+func (s *memoryRecipeStore) SearchRecipes(username string, opts RecipeSearchOptions) ([]Recipe, int64, error) {
+	recipes, err := s.ListRecipes(username, opts.Filters)
+	if err != nil {
+		return nil, 0, err
+	}
+	found := []Recipe{}
+	for _, recipe := range recipes {
+		if opts.Term == "" && len(opts.Ingredients) == 0 {
+			found = append(found, recipe)
+			continue
+		}
+		if match := findSearchMatch(recipe, opts); match != nil {
+			recipe.Match = match
+			found = append(found, recipe)
+		}
+	}
+	total := int64(len(found))
+	if opts.Page.Limit > 0 {
+		start := min(opts.Page.Offset, len(found))
+		found = found[start:min(start+opts.Page.Limit, len(found))]
+	}
+	return found, total, nil
+}
+
+// RandomRecipes returns the newest matches; tests want a stable order.
+func (s *memoryRecipeStore) RandomRecipes(username string, filters RecipeFilters, limit int) ([]Recipe, error) {
+	recipes, err := s.ListRecipes(username, filters)
+	if err != nil {
+		return nil, err
+	}
+	return recipes[:min(limit, len(recipes))], nil
+}
+
+func (s *memoryRecipeStore) ListFavoriteRecipes(username string) ([]Recipe, error) {
+	return s.ListRecipes(username, RecipeFilters{FavoritesOnly: true})
+}
+
+func (s *memoryRecipeStore) CategoryCounts(username string) ([]CategoryCount, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := map[string]int64{}
+	for _, stored := range s.userRecipes(username) {
+		counts[stored.recipe.Category]++
+	}
+	results := make([]CategoryCount, 0, len(counts))
+	for category, count := range counts {
+		results = append(results, CategoryCount{Category: category, Count: count})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return strings.ToLower(results[i].Category) < strings.ToLower(results[j].Category)
+	})
+	return results, nil
+}
+
+func (s *memoryRecipeStore) CategoryDetails(username string) ([]CategoryDetail, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	byCategory := map[string]*CategoryDetail{}
+	totals := map[string][2]int{}
+	var details []*CategoryDetail
+	for _, stored := range s.userRecipes(username) {
+		r := stored.recipe
+		detail := byCategory[r.Category]
+		if detail == nil {
+			// Newest first, so the first seen is the latest
+			detail = &CategoryDetail{Category: r.Category, Latest: &CategoryRecipe{ID: r.ID, Slug: stored.slug, Title: r.Title, Image: r.Image}}
+			byCategory[r.Category] = detail
+			details = append(details, detail)
+		}
+		detail.Count++
+		if stored.favorite {
+			detail.Favorites++
+		}
+		if r.TotalTime > 0 {
+			total := totals[r.Category]
+			totals[r.Category] = [2]int{total[0] + r.TotalTime, total[1] + 1}
+		}
+	}
+	results := make([]CategoryDetail, 0, len(details))
+	for _, detail := range details {
+		if total := totals[detail.Category]; total[1] > 0 {
+			average := float64(total[0]) / float64(total[1])
+			detail.AverageTotalTime = &average
+		}
+		results = append(results, *detail)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return strings.ToLower(results[i].Category) < strings.ToLower(results[j].Category)
+	})
+	return results, nil
+}
+
+func (s *memoryRecipeStore) GetRecipe(username, slug string) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.find(username, slug)
+	if stored == nil {
+		return Recipe{}, sql.ErrNoRows
+	}
+	return stored.view(), nil
+}
+
+func (s *memoryRecipeStore) GetRecipeByID(username string, recipeID uint) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findByID(username, recipeID)
+	if stored == nil {
+		return Recipe{}, sql.ErrNoRows
+	}
+	return stored.view(), nil
+}
+
+func (s *memoryRecipeStore) SaveRecipeForUser(username, slug string, recipe Recipe) error {
+	if username == "" || slug == "" {
+		return errors.New("username and slug are required")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	recipe = cloneRecipe(recipe)
+	recipe.Category = normalizeCategoryOrOther(recipe.Category)
+	recipe.IsFavorite = false
+	if stored := s.find(username, slug); stored != nil {
+		recipe.ID = stored.recipe.ID
+		stored.recipe = recipe
+		return nil
+	}
+	s.nextID++
+	recipe.ID = s.nextID
+	s.recipes[recipe.ID] = &memoryRecipe{username: username, slug: slug, recipe: recipe}
+	return nil
+}
+
+func (s *memoryRecipeStore) UpdateRecipeTitleAndInstructions(username, slug string, patch RecipePatch) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyPatch(s.find(username, slug), patch)
+}
+
+func (s *memoryRecipeStore) UpdateRecipeTitleAndInstructionsByID(username string, recipeID uint, patch RecipePatch) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.applyPatch(s.findByID(username, recipeID), patch)
+}
+
+// applyPatch mirrors recipePatchUpdates. The caller holds mu.
+func (s *memoryRecipeStore) applyPatch(stored *memoryRecipe, patch RecipePatch) (Recipe, error) {
+	if stored == nil {
+		return Recipe{}, sql.ErrNoRows
+	}
+	if patch.IfMatch != "" && !etagMatches(patch.IfMatch, recipeETag(stored.view())) {
+		return Recipe{}, ErrRecipeChanged
+	}
+	updated := cloneRecipe(stored.recipe)
+	if patch.Category != nil {
+		category, ok := normalizeCategoryStrict(*patch.Category)
+		if !ok {
+			return Recipe{}, ErrInvalidCategory
+		}
+		updated.Category = category
+	}
+	if patch.Title != nil {
+		updated.Title = strings.TrimSpace(*patch.Title)
+	}
+	if patch.Instructions != nil {
+		updated.Instructions = slices.Clone(*patch.Instructions)
+		updated.InstructionSections = nil
+	}
+	if patch.Ingredients != nil {
+		updated.Ingredients = slices.Clone(*patch.Ingredients)
+		updated.ParsedIngredients = nil
+		updated.Diets = classifyDiets(updated)
+	}
+	if patch.Tags != nil {
+		updated.Tags = normalizeTerms(*patch.Tags)
+	}
+	stored.recipe = updated
+	return stored.view(), nil
+}
+
+func (s *memoryRecipeStore) MarkRecipeCookedByID(username string, recipeID uint) (Recipe, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findByID(username, recipeID)
+	if stored == nil {
+		return Recipe{}, sql.ErrNoRows
+	}
+	now := time.Now().UTC()
+	stored.recipe.LastCookedAt = &now
+	return stored.view(), nil
+}
+
+func (s *memoryRecipeStore) SetFavorite(username, slug string, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.find(username, slug)
+	if stored == nil {
+		return sql.ErrNoRows
+	}
+	stored.favorite = favorite
+	return nil
+}
+
+func (s *memoryRecipeStore) SetFavoriteByID(username string, recipeID uint, favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored := s.findByID(username, recipeID)
+	if stored == nil {
+		return sql.ErrNoRows
+	}
+	stored.favorite = favorite
+	return nil
+}
+
+// DeleteRecipe, like the database, succeeds when there is nothing to delete.
+func (s *memoryRecipeStore) DeleteRecipe(username, slug string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored := s.find(username, slug); stored != nil {
+		delete(s.recipes, stored.recipe.ID)
+	}
+	return nil
+}
+
+func (s *memoryRecipeStore) DeleteRecipeByID(username string, recipeID uint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if stored := s.findByID(username, recipeID); stored != nil {
+		delete(s.recipes, recipeID)
+	}
+	return nil
+}
+
+func (s *memoryRecipeStore) GetUserPreferences(username string) (UserPreferences, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.preferences[username], nil
+}
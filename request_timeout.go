@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Every request runs under a deadline, so a slow query or AI call can't
+// hold a goroutine (and a database connection) indefinitely: the request
+// context is cancelled, which stops the repository (see requestRepo) and AI
+// work started from it, and the client gets a 503. Most routes get
+// REQUEST_TIMEOUT (default 30s); those in longRequestRoutes get
+// REQUEST_TIMEOUT_LONG (default 5m). 0 turns either off.
+
+// longRequestRoutes import, export, or wait on the AI.
+var longRequestRoutes = map[string]bool{
+	"POST /import":                               true,
+	"POST /import/:format":                       true,
+	"GET /export":                                true,
+	"POST /save-recipe":                          true,
+	"POST /save-recipe/html":                     true,
+	"POST /save-recipe/pdf":                      true,
+	"POST /inbound/mailgun":                      true,
+	"PUT /recipes/id/:id/image":                  true,
+	"GET /recipes/id/:id/export":                 true,
+	"POST /recipes/id/:id/nutrition/recalculate": true,
+	"POST /recipes/id/:id/parse-ingredients":     true,
+	"POST /recipes/id/:id/ask":                   true,
+	"POST /meal-plans/generate":                  true,
+	"POST /suggest":                              true,
+	"POST /admin/parse-ingredients":              true,
+	"POST /admin/reprocess-incomplete":           true,
+	"POST /admin/backup":                         true,
+}
+
+// untimedRoutes stream for as long as the client stays connected.
+var untimedRoutes = map[string]bool{
+	"GET /queue/events": true,
+}
+
+var requestTimeoutsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "recipes_request_timeouts_total",
+	Help: "Requests answered 503 because they ran past their timeout, by route.",
+}, []string{"route"})
+
+// requestTimeout returns the middleware applying REQUEST_TIMEOUT and
+// REQUEST_TIMEOUT_LONG.
+func requestTimeout() gin.HandlerFunc {
+	short, long := appConfig.Server.RequestTimeout, appConfig.Server.LongRequestTimeout
+	return func(c *gin.Context) {
+		route := c.Request.Method + " " + c.FullPath()
+		timeout := short
+		switch {
+		case untimedRoutes[route], c.Request.URL.Path == "/metrics", isProbePath(c.Request.URL.Path):
+			// Probes have their own healthCheckTimeout
+			timeout = 0
+		case longRequestRoutes[route]:
+			timeout = long
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+		withRequestTimeout(c, timeout)
+	}
+}
+
+func withRequestTimeout(c *gin.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	c.Request = c.Request.WithContext(ctx)
+	writer := &timeoutWriter{ResponseWriter: c.Writer, ctx: ctx}
+	c.Writer = writer
+	c.Next()
+
+	writer.mu.Lock()
+	defer writer.mu.Unlock()
+	// A handler that gave up without answering gets the 503 too
+	if writer.takeOver() {
+		requestTimeoutsTotal.WithLabelValues(requestRoute(c)).Inc()
+		c.Error(fmt.Errorf("%s %s timed out after %s", c.Request.Method, requestRoute(c), timeout))
+	}
+}
+
+// timeoutWriter answers 503 in place of whatever the handler writes first
+// once the deadline has passed, typically an error from the cancelled
+// work. A response already under way when the deadline passes is left to
+// finish.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	ctx context.Context
+
+	mu       sync.Mutex
+	timedOut bool
+}
+
+var requestTimedOutBody = []byte(`{"error":"request timed out"}`)
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.takeOver() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.takeOver() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.takeOver() {
+		// Reported as written so the handler doesn't treat it as a
+		// broken connection
+		return len(data), nil
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// takeOver writes the 503 if the deadline has passed before anything was
+// sent, and reports whether the handler's output is to be dropped.
+func (w *timeoutWriter) takeOver() bool {
+	if w.timedOut {
+		return true
+	}
+	if w.ResponseWriter.Written() || !errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		return false
+	}
+	w.timedOut = true
+	header := w.ResponseWriter.Header()
+	header.Del("Content-Length")
+	header.Del("ETag")
+	header.Set("Content-Type", "application/json; charset=utf-8")
+	header.Set("Cache-Control", "no-store")
+	w.ResponseWriter.WriteHeader(http.StatusServiceUnavailable)
+	w.ResponseWriter.Write(requestTimedOutBody)
+	return true
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestTimeoutAnswers503AndCancelsTheWork(t *testing.T) {
+	previousConfig := appConfig
+	appConfig.Server.RequestTimeout = 20 * time.Millisecond
+	appConfig.Server.LongRequestTimeout = time.Second
+	t.Cleanup(func() { appConfig = previousConfig })
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(requestTimeout())
+
+	cancelled := make(chan bool, 1)
+	router.GET("/get-recipes", func(c *gin.Context) {
+		select {
+		case <-c.Request.Context().Done():
+			cancelled <- true
+		case <-time.After(time.Second):
+			cancelled <- false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list recipes"})
+	})
+	router.GET("/categories", func(c *gin.Context) {
+		// Gives up without answering
+		<-c.Request.Context().Done()
+	})
+	router.POST("/save-recipe", func(c *gin.Context) {
+		time.Sleep(50 * time.Millisecond)
+		c.JSON(http.StatusAccepted, gin.H{"status": "queued"})
+	})
+	router.GET("/queue/events", func(c *gin.Context) {
+		if _, ok := c.Request.Context().Deadline(); ok {
+			t.Error("event stream has a deadline")
+		}
+		c.Status(http.StatusOK)
+	})
+
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+		return w
+	}
+
+	w := serve("GET", "/get-recipes")
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != `{"error":"request timed out"}` {
+		t.Errorf("slow read: %d %s", w.Code, w.Body)
+	}
+	if !<-cancelled {
+		t.Error("the slow read's context wasn't cancelled")
+	}
+	if w := serve("GET", "/categories"); w.Code != http.StatusServiceUnavailable {
+		t.Errorf("unanswered read: %d %s", w.Code, w.Body)
+	}
+	if w := serve("POST", "/save-recipe"); w.Code != http.StatusAccepted {
+		t.Errorf("import under the long timeout: %d %s", w.Code, w.Body)
+	}
+	if w := serve("GET", "/queue/events"); w.Code != http.StatusOK {
+		t.Errorf("event stream: %d", w.Code)
+	}
+}
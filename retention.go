@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Stale rows are cleaned up every RETENTION_INTERVAL (default daily, 0
+// turns it off) by retention policies, each configured on its own with the
+// age past which its rows go:
+//
+//   - queue (QUEUE_RETENTION, 30 days): queue items imported or cancelled
+//   - failed_queue (RETENTION_FAILED_QUEUE, off): items that failed for good
+//   - unused_accounts (RETENTION_UNUSED_ACCOUNTS, off): accounts never
+//     signed in to since they registered
+//   - feed_tokens (RETENTION_FEED_TOKENS, off): feed tokens nobody fetched
+//     with, which are revoked
+//   - password_resets (RETENTION_PASSWORD_RESETS, 0): reset tokens used or
+//     expired that long ago, and those of deleted accounts
+//   - idempotency_keys: Idempotency-Key responses past their replay window
+//
+// An age of 0 turns the first four off. Setting RETENTION_FAILED_QUEUE_ARCHIVE
+// or RETENTION_UNUSED_ACCOUNTS_ARCHIVE uploads what those policies delete to
+// R2 first, under archive/; nothing is deleted that wasn't uploaded.
+
+var retentionDeletedRows = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "recipes_retention_deleted_rows_total",
+	Help: "Rows deleted by the retention cleanup, by table.",
+}, []string{"table"})
+
+const retentionArchivePrefix = "archive/"
+
+var errRetentionNoArchive = errors.New("archiving needs R2 (CLOUDFLARE_ENDPOINT)")
+
+// retentionArchive is where archiving policies upload rows before deleting
+// them; CloudflareS3 in production.
+type retentionArchive interface {
+	UploadObject(key, contentType string, content []byte) error
+}
+
+// RetentionPolicy is one kind of stale row and how long it is kept.
+type RetentionPolicy struct {
+	Name   string
+	MaxAge time.Duration
+	// Optional policies are off while MaxAge is 0
+	Optional bool
+	Archive  bool
+	prune    func(run retentionRun, policy RetentionPolicy, cutoff time.Time) (int64, error)
+}
+
+// retentionRun is what a policy's prune step works with.
+type retentionRun struct {
+	repo    *RecipeRepository
+	archive retentionArchive
+	now     time.Time
+}
+
+func (p RetentionPolicy) enabled() bool {
+	return !p.Optional || p.MaxAge > 0
+}
+
+func loadRetentionPolicies() []RetentionPolicy {
+	cfg := appConfig.Jobs.Retention
+	return []RetentionPolicy{
+		{
+			Name:     "queue",
+			MaxAge:   cfg.Queue,
+			Optional: true,
+			prune: func(run retentionRun, _ RetentionPolicy, cutoff time.Time) (int64, error) {
+				return run.repo.PruneFinishedQueue(cutoff)
+			},
+		},
+		{
+			Name:     "failed_queue",
+			MaxAge:   cfg.FailedQueue,
+			Optional: true,
+			Archive:  cfg.FailedQueueArchive,
+			prune:    pruneFailedQueue,
+		},
+		{
+			Name:     "unused_accounts",
+			MaxAge:   cfg.UnusedAccounts,
+			Optional: true,
+			Archive:  cfg.UnusedAccountsArchive,
+			prune:    pruneUnusedAccounts,
+		},
+		{
+			Name:     "feed_tokens",
+			MaxAge:   cfg.FeedTokens,
+			Optional: true,
+			prune: func(run retentionRun, _ RetentionPolicy, cutoff time.Time) (int64, error) {
+				return run.repo.RevokeUnusedFeedTokens(cutoff)
+			},
+		},
+		{
+			Name:   "password_resets",
+			MaxAge: cfg.PasswordResets,
+			prune: func(run retentionRun, _ RetentionPolicy, cutoff time.Time) (int64, error) {
+				return run.repo.PruneExpiredPasswordResets(cutoff)
+			},
+		},
+		{
+			Name:   "idempotency_keys",
+			MaxAge: idempotencyKeyTTL,
+			prune: func(run retentionRun, _ RetentionPolicy, cutoff time.Time) (int64, error) {
+				return run.repo.PruneExpiredIdempotencyKeys(cutoff)
+			},
+		},
+	}
+}
+
+func runRetentionCleanup(ctx context.Context, repo *RecipeRepository) {
+	interval := appConfig.Jobs.RetentionInterval
+	if interval <= 0 {
+		log.Println("Retention: disabled (RETENTION_INTERVAL=0)")
+		return
+	}
+
+	policies := loadRetentionPolicies()
+	for _, policy := range policies {
+		if policy.enabled() {
+			log.Printf("Retention: %s after %s (archive=%t)", policy.Name, policy.MaxAge, policy.Archive)
+		}
+	}
+	safeCleanupRetention(repo, policies)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			safeCleanupRetention(repo, policies)
+		}
+	}
+}
+
+func safeCleanupRetention(repo *RecipeRepository, policies []RetentionPolicy) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Retention: recovered from panic: %v", r)
+		}
+	}()
+	cleanupRetention(repo, policies, retentionArchiveFor(policies), time.Now())
+}
+
+// retentionArchiveFor connects to R2 when an enabled policy archives.
+func retentionArchiveFor(policies []RetentionPolicy) retentionArchive {
+	for _, policy := range policies {
+		if !policy.enabled() || !policy.Archive {
+			continue
+		}
+		bucket, err := NewCloudflareS3()
+		if err != nil {
+			log.Printf("Retention: %v", err)
+			return nil
+		}
+		return bucket
+	}
+	return nil
+}
+
+// cleanupRetention applies each enabled policy, carrying on past failures.
+func cleanupRetention(repo *RecipeRepository, policies []RetentionPolicy, archive retentionArchive, now time.Time) {
+	run := retentionRun{repo: repo, archive: archive, now: now}
+	for _, policy := range policies {
+		if !policy.enabled() {
+			continue
+		}
+		deleted, err := policy.prune(run, policy, now.Add(-policy.MaxAge))
+		retentionDeletedRows.WithLabelValues(policy.Name).Add(float64(deleted))
+		if err != nil {
+			log.Printf("Retention: %s: %v", policy.Name, err)
+			continue
+		}
+		if deleted > 0 {
+			log.Printf("Retention: deleted %d %s rows", deleted, policy.Name)
+		}
+	}
+}
+
+// archivedQueueItem is a failed queue item as uploaded before it's deleted.
+type archivedQueueItem struct {
+	UserID uint `json:"userId"`
+	QueueItem
+}
+
+func pruneFailedQueue(run retentionRun, policy RetentionPolicy, cutoff time.Time) (int64, error) {
+	if !policy.Archive {
+		return run.repo.PruneFailedQueue(cutoff)
+	}
+	if run.archive == nil {
+		return 0, errRetentionNoArchive
+	}
+	var total int64
+	for {
+		items, err := run.repo.FailedQueueBefore(cutoff, retentionDeleteBatch)
+		if err != nil || len(items) == 0 {
+			return total, err
+		}
+		archived := make([]archivedQueueItem, 0, len(items))
+		ids := make([]uint, 0, len(items))
+		for _, item := range items {
+			archived = append(archived, archivedQueueItem{UserID: item.UserID, QueueItem: item.toQueueItem()})
+			ids = append(ids, item.ID)
+		}
+		key := fmt.Sprintf("%sfailed_queue/%s-%d.json", retentionArchivePrefix, run.now.UTC().Format("20060102T150405Z"), ids[0])
+		if err := uploadRetentionJSON(run.archive, key, archived); err != nil {
+			return total, err
+		}
+		deleted, err := run.repo.DeleteQueueItems(ids)
+		total += deleted
+		if err != nil {
+			return total, err
+		}
+		if len(items) < retentionDeleteBatch {
+			return total, nil
+		}
+	}
+}
+
+// pruneUnusedAccounts purges the accounts nobody signed in to, as the
+// inactivity policy purges idle ones, and counts them.
+func pruneUnusedAccounts(run retentionRun, policy RetentionPolicy, cutoff time.Time) (int64, error) {
+	if policy.Archive && run.archive == nil {
+		return 0, errRetentionNoArchive
+	}
+	users, err := run.repo.UnusedAccounts(cutoff)
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	for _, user := range users {
+		if isAdminUser(user.Username) {
+			continue
+		}
+		if policy.Archive {
+			export, err := run.repo.ExportAccount(user.Username)
+			if err != nil {
+				return purged, fmt.Errorf("export %s: %w", user.Username, err)
+			}
+			key := fmt.Sprintf("%sunused_accounts/%d-%d.json", retentionArchivePrefix, user.ID, run.now.Unix())
+			if err := uploadRetentionJSON(run.archive, key, export); err != nil {
+				return purged, err
+			}
+			auditInactivity(run.repo, user, "account_exported", key)
+		}
+		if err := run.repo.PurgeUser(user.ID); err != nil {
+			auditInactivity(run.repo, user, "purge_failed", err.Error())
+			return purged, err
+		}
+		invalidateUserRecipeCaches(user.Username)
+		auditInactivity(run.repo, user, "account_purged", "never signed in")
+		purged++
+	}
+	return purged, nil
+}
+
+func uploadRetentionJSON(archive retentionArchive, key string, value any) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("marshal %s: %w", key, err)
+	}
+	if err := archive.UploadObject(key, "application/json", data); err != nil {
+		return fmt.Errorf("archive %s: %w", key, err)
+	}
+	return nil
+}
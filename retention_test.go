@@ -0,0 +1,158 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+func TestCleanupRetention(t *testing.T) {
+	repo := newTestRepo(t)
+	userID := createTestUser(t, repo, "cook@example.com")
+	for _, url := range []string{"https://example.com/old", "https://example.com/failed", "https://example.com/new", "https://example.com/pending"} {
+		if err := repo.EnqueueRecipe("cook@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	old := time.Now().Add(-60 * 24 * time.Hour).UTC()
+	for url, updates := range map[string]map[string]any{
+		"https://example.com/old":    {"processed_at": old},
+		"https://example.com/failed": {"processed_at": old, "failed_at": old},
+		"https://example.com/new":    {"processed_at": time.Now().UTC()},
+	} {
+		if err := repo.db.Model(&QueueModel{}).Where("url = ?", url).Updates(updates).Error; err != nil {
+			t.Fatalf("update %s: %v", url, err)
+		}
+	}
+	resets := []PasswordResetModel{
+		{UserID: userID, TokenHash: "expired", ExpiresAt: time.Now().Add(-time.Hour)},
+		{UserID: userID, TokenHash: "used", ExpiresAt: time.Now().Add(time.Hour), UsedAt: &old},
+		{UserID: userID, TokenHash: "live", ExpiresAt: time.Now().Add(time.Hour)},
+	}
+	if err := repo.db.Create(&resets).Error; err != nil {
+		t.Fatalf("create resets: %v", err)
+	}
+
+	cleanupRetention(repo, loadRetentionPolicies(), nil, time.Now())
+
+	var urls []string
+	if err := repo.db.Model(&QueueModel{}).Order("url").Pluck("url", &urls).Error; err != nil {
+		t.Fatalf("list queue: %v", err)
+	}
+	want := []string{"https://example.com/failed", "https://example.com/new", "https://example.com/pending"}
+	if len(urls) != len(want) {
+		t.Fatalf("queue after cleanup = %v, want %v", urls, want)
+	}
+	for i := range want {
+		if urls[i] != want[i] {
+			t.Fatalf("queue after cleanup = %v, want %v", urls, want)
+		}
+	}
+
+	var hashes []string
+	if err := repo.db.Model(&PasswordResetModel{}).Pluck("token_hash", &hashes).Error; err != nil {
+		t.Fatalf("list resets: %v", err)
+	}
+	if len(hashes) != 1 || hashes[0] != "live" {
+		t.Fatalf("resets after cleanup = %v, want only the live one", hashes)
+	}
+}
+
+func TestRetentionPolicies(t *testing.T) {
+	previousConfig := appConfig
+	appConfig.Jobs.Retention = RetentionConfig{
+		Queue:                 defaultQueueRetention,
+		FailedQueue:           720 * time.Hour,
+		FailedQueueArchive:    true,
+		UnusedAccounts:        720 * time.Hour,
+		UnusedAccountsArchive: true,
+		FeedTokens:            720 * time.Hour,
+	}
+	t.Cleanup(func() { appConfig = previousConfig })
+	repo := newTestRepo(t)
+	previousRecipe, previousRecipes := recipeCache, recipesCache
+	recipeCache, recipesCache = cache.New(time.Hour, time.Hour), cache.New(time.Hour, time.Hour)
+	t.Cleanup(func() { recipeCache, recipesCache = previousRecipe, previousRecipes })
+
+	old := time.Now().Add(-60 * 24 * time.Hour).UTC()
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.TouchUserActivity("cook@example.com", false); err != nil {
+		t.Fatalf("touch: %v", err)
+	}
+	for _, username := range []string{"idle@example.com", "new@example.com"} {
+		createTestUser(t, repo, username)
+	}
+	if err := repo.db.Model(&UserModel{}).Where("username IN ?", []string{"cook@example.com", "idle@example.com"}).
+		Update("created_at", old).Error; err != nil {
+		t.Fatalf("age users: %v", err)
+	}
+
+	for _, url := range []string{"https://example.com/old", "https://example.com/recent"} {
+		if err := repo.EnqueueRecipe("cook@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	for url, failedAt := range map[string]time.Time{"https://example.com/old": old, "https://example.com/recent": time.Now().UTC()} {
+		if err := repo.db.Model(&QueueModel{}).Where("url = ?", url).
+			Updates(map[string]any{"processed_at": failedAt, "failed_at": failedAt}).Error; err != nil {
+			t.Fatalf("fail %s: %v", url, err)
+		}
+	}
+
+	if _, err := repo.RotateFeedToken("cook@example.com"); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	token, err := repo.RotateFeedToken("new@example.com")
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	if err := repo.db.Model(&UserModel{}).Where("username = ?", "cook@example.com").Update("feed_token_used_at", old).Error; err != nil {
+		t.Fatalf("age token: %v", err)
+	}
+
+	archive := memoryBucket{}
+	cleanupRetention(repo, loadRetentionPolicies(), archive, time.Now())
+
+	var urls []string
+	repo.db.Model(&QueueModel{}).Pluck("url", &urls)
+	if len(urls) != 1 || urls[0] != "https://example.com/recent" {
+		t.Errorf("queue after cleanup = %v, want only the recent failure", urls)
+	}
+	var usernames []string
+	repo.db.Model(&UserModel{}).Order("username").Pluck("username", &usernames)
+	if len(usernames) != 2 || usernames[0] != "cook@example.com" || usernames[1] != "new@example.com" {
+		t.Errorf("users after cleanup = %v, want the idle account purged", usernames)
+	}
+	var hashes []sql.NullString
+	repo.db.Model(&UserModel{}).Order("username").Pluck("feed_token_hash", &hashes)
+	if len(hashes) != 2 || hashes[0].Valid || !hashes[1].Valid {
+		t.Errorf("feed tokens after cleanup = %v, want cook's revoked", hashes)
+	}
+	if username, err := repo.UsernameForFeedToken(token); err != nil || username != "new@example.com" {
+		t.Errorf("recent token: %q, %v", username, err)
+	}
+
+	keys, _ := archive.ListObjectKeys(retentionArchivePrefix)
+	if len(keys) != 2 || !strings.HasPrefix(keys[0], "archive/failed_queue/") || !strings.HasPrefix(keys[1], "archive/unused_accounts/") {
+		t.Fatalf("archived = %v, want the failed item and the idle account", keys)
+	}
+	var items []archivedQueueItem
+	if err := json.Unmarshal(archive[keys[0]], &items); err != nil || len(items) != 1 || items[0].URL != "https://example.com/old" {
+		t.Errorf("archived queue items = %+v, %v", items, err)
+	}
+
+	// Without R2 an archiving policy deletes nothing
+	if err := repo.db.Model(&QueueModel{}).Where("url = ?", "https://example.com/recent").Update("failed_at", old).Error; err != nil {
+		t.Fatalf("age failure: %v", err)
+	}
+	cleanupRetention(repo, loadRetentionPolicies(), nil, time.Now())
+	var count int64
+	repo.db.Model(&QueueModel{}).Count(&count)
+	if count != 1 {
+		t.Errorf("queue after cleanup without R2 = %d rows, want 1", count)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"flag"
+	"log"
+)
+
+// The server normally answers the API and works the queue in one process.
+// Scraping needs Chromium and most of the memory, so either half can run on
+// its own: a worker processes the queue without serving HTTP, and an API
+// instance serves requests and leaves queued URLs to the workers.
+//
+// Both halves open the same SQLite file, data/recipes.db, so a split
+// deployment only works when the processes share that disk: the same host,
+// or containers mounting one local volume. SQLite's file locking isn't safe
+// over NFS or other network filesystems, so a worker on a separate machine
+// needs its own copy of the data and won't see the API's queue. Writers from
+// the two processes take turns on the database lock; see InitDatabase.
+const (
+	runModeAll    = "all"
+	runModeAPI    = "api"
+	runModeWorker = "worker"
+)
+
+// runModeFromArgs reads --worker or --api, falling back to configured,
+// the RUN_MODE (all, api or worker), WORKER_ONLY or API_ONLY setting.
+func runModeFromArgs(args []string, configured string) string {
+	flags := flag.NewFlagSet("recipes-api", flag.ExitOnError)
+	worker := flags.Bool("worker", false, "only process the queue; don't serve HTTP")
+	apiOnly := flags.Bool("api", false, "only serve HTTP; leave the queue to workers")
+	_ = flags.Parse(args)
+
+	switch {
+	case *worker && *apiOnly:
+		log.Fatal("--worker and --api can't be combined")
+	case *worker:
+		return runModeWorker
+	case *apiOnly:
+		return runModeAPI
+	}
+	return configured
+}
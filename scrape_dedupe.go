@@ -0,0 +1,90 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+)
+
+// Two queue items for the same URL, from one user or several, would each
+// launch a browser and call the AI. Within a process concurrent scrapes of a
+// URL share one result; across processes a claim row in the database lets
+// only one instance scrape it at a time.
+
+var errScrapeInProgress = errors.New("url is being scraped by another instance")
+
+// scrapeInstanceID identifies this process as the owner of scrape claims
+// and of the queue items it claims.
+var scrapeInstanceID = newScrapeInstanceID()
+
+func newScrapeInstanceID() string {
+	host, _ := os.Hostname()
+	buf := make([]byte, 4)
+	_, _ = rand.Read(buf)
+	return fmt.Sprintf("%s-%d-%s", host, os.Getpid(), hex.EncodeToString(buf))
+}
+
+type scrapeResult struct {
+	recipe Recipe
+	slug   string
+	err    error
+}
+
+type scrapeCall struct {
+	done   chan struct{}
+	result scrapeResult
+}
+
+// scrapeFlights tracks the scrapes running in this process by URL.
+var scrapeFlights = struct {
+	mu    sync.Mutex
+	calls map[string]*scrapeCall
+}{calls: make(map[string]*scrapeCall)}
+
+// scrapeRecipeOnce runs getRecipe for pageURL, joining a scrape of the same
+// URL and AI model already running here, and returns errScrapeInProgress
+// when another instance holds the URL's claim. The AI usage of a joined
+// scrape is charged to whoever started it.
+func scrapeRecipeOnce(repo *RecipeRepository, pageURL string, opts importOptions) (Recipe, string, error) {
+	flightKey := pageURL + "\x00" + opts.AIModel
+	scrapeFlights.mu.Lock()
+	if call, ok := scrapeFlights.calls[flightKey]; ok {
+		scrapeFlights.mu.Unlock()
+		log.Printf("Scraper: waiting for in-flight scrape of %s", pageURL)
+		<-call.done
+		return call.result.recipe, call.result.slug, call.result.err
+	}
+	call := &scrapeCall{done: make(chan struct{})}
+	scrapeFlights.calls[flightKey] = call
+	scrapeFlights.mu.Unlock()
+
+	defer func() {
+		scrapeFlights.mu.Lock()
+		delete(scrapeFlights.calls, flightKey)
+		scrapeFlights.mu.Unlock()
+		close(call.done)
+	}()
+
+	claimed, err := repo.ClaimScrape(pageURL, scrapeInstanceID, scrapeClaimTTL)
+	if err != nil {
+		// The claim only saves duplicate work, so scrape anyway
+		log.Printf("Scraper: %v", err)
+	} else if !claimed {
+		call.result = scrapeResult{err: errScrapeInProgress}
+		return Recipe{}, "", errScrapeInProgress
+	} else {
+		defer func() {
+			if err := repo.ReleaseScrape(pageURL, scrapeInstanceID); err != nil {
+				log.Printf("Scraper: %v", err)
+			}
+		}()
+	}
+
+	recipe, slug, err := getRecipe(pageURL, opts)
+	call.result = scrapeResult{recipe: recipe, slug: slug, err: err}
+	return recipe, slug, err
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ScrapeError is how getRecipe reports a page it couldn't fetch. Permanent
+// failures (robots.txt, a 404, an unknown host) won't change on a retry, so
+// the queue gives up on them; the rest are retried on later polls.
+type ScrapeError struct {
+	URL       string
+	Attempts  int
+	Permanent bool
+	Err       error
+}
+
+func (e *ScrapeError) Error() string {
+	return fmt.Sprintf("fetch %s (%d attempt(s)): %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *ScrapeError) Unwrap() error {
+	return e.Err
+}
+
+// httpStatusError is a response other than 200 OK.
+type httpStatusError struct {
+	StatusCode int
+	Status     string
+}
+
+func (e *httpStatusError) Error() string {
+	return "http status: " + e.Status
+}
+
+var errInvalidScrapeURL = errors.New("invalid url")
+
+// Pages fetched fine that hold no recipe to extract: a bot check in place of
+// the page, and a recipe only subscribers can see.
+var (
+	errPageBlocked = errors.New("page is blocked by a bot check")
+	errPaywalled   = errors.New("recipe is behind a paywall")
+)
+
+// isPermanentScrapeError reports fetch errors that retrying can't fix.
+func isPermanentScrapeError(err error) bool {
+	if errors.Is(err, errDisallowedByRobots) || errors.Is(err, errInvalidScrapeURL) || errors.Is(err, errPDFNoText) {
+		return true
+	}
+	var statusErr *httpStatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusForbidden, http.StatusRequestTimeout, http.StatusTooEarly, http.StatusTooManyRequests:
+			// Bot checks and rate limits clear up given time
+			return false
+		}
+		return statusErr.StatusCode >= 400 && statusErr.StatusCode < 500
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return true
+	}
+	var certErr *tls.CertificateVerificationError
+	return errors.As(err, &certErr)
+}
+
+// retryPolicy retries a fetch with exponential backoff: the wait before
+// retry n is BaseDelay*2^(n-1), capped at MaxDelay, less up to half of it
+// at random so retries from several workers spread out.
+type retryPolicy struct {
+	Attempts  int
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// scrapeRetryPolicy follows SCRAPER_MAX_RETRIES (retries after the first
+// attempt), SCRAPER_RETRY_DELAY and SCRAPER_RETRY_MAX_DELAY.
+func scrapeRetryPolicy() retryPolicy {
+	cfg := appConfig.Scraper
+	return retryPolicy{
+		Attempts:  1 + cfg.MaxRetries,
+		BaseDelay: cfg.RetryDelay,
+		MaxDelay:  cfg.RetryMaxDelay,
+	}
+}
+
+// queueRetryPolicy spaces out the attempts at a failed queue item, which is
+// picked up again by the first poll after its delay.
+func queueRetryPolicy() retryPolicy {
+	return retryPolicy{Attempts: queueSettings.MaxAttempts, BaseDelay: queueSettings.RetryBaseDelay, MaxDelay: queueSettings.RetryMaxDelay}
+}
+
+func (p retryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && d < p.MaxDelay; i++ {
+		d *= 2
+	}
+	d = min(d, p.MaxDelay)
+	if d <= 0 {
+		return 0
+	}
+	return d - time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// run calls fn until it succeeds, fails permanently, runs out of attempts
+// or ctx is done, returning the attempts made and the last error.
+func (p retryPolicy) run(ctx context.Context, what string, fn func() error) (int, error) {
+	var err error
+	attempt := 1
+	for ; ; attempt++ {
+		if err = fn(); err == nil || isPermanentScrapeError(err) || attempt >= p.Attempts {
+			break
+		}
+		wait := p.delay(attempt)
+		log.Printf("Scraper: %s attempt %d failed, retrying in %s: %v", what, attempt, wait.Round(time.Millisecond), err)
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return attempt, err
+		}
+	}
+	return attempt, err
+}
+
+// fetchWithRetry fetches pageURL under the retry policy, checking robots.txt
+// and waiting for the domain's turn before each attempt.
+func fetchWithRetry[T any](ctx context.Context, pageURL string, fetch func(context.Context, string) (T, error)) (T, error) {
+	var result T
+	attempts, err := scrapeRetryPolicy().run(ctx, pageURL, func() error {
+		release, err := awaitScrapeTurn(pageURL)
+		if err != nil {
+			return err
+		}
+		defer release()
+		result, err = fetch(ctx, pageURL)
+		return err
+	})
+	if err != nil {
+		var zero T
+		return zero, &ScrapeError{URL: pageURL, Attempts: attempts, Permanent: isPermanentScrapeError(err), Err: err}
+	}
+	return result, nil
+}
@@ -2,6 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"io"
@@ -10,14 +13,16 @@ import (
 	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/go-rod/rod"
-	"github.com/go-rod/rod/lib/launcher"
+	"github.com/go-rod/rod/lib/proto"
 	"github.com/jinzhu/copier"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 func fileExists(path string) bool {
@@ -31,7 +36,7 @@ func fileExists(path string) bool {
 }
 
 func findChromiumBinary() string {
-	if custom := os.Getenv("CHROMIUM_BIN"); fileExists(custom) {
+	if custom := appConfig.Scraper.ChromiumBin; fileExists(custom) {
 		return custom
 	}
 
@@ -69,192 +74,469 @@ func resolveRelativeURL(base *url.URL, raw string) string {
 	return base.ResolveReference(parsed).String()
 }
 
-func extractImageURL(doc *goquery.Document, pageURL string) string {
-	base, err := url.Parse(pageURL)
+// scraperUserAgent is SCRAPER_USER_AGENT, sent by the browser and plain
+// HTTP fetches; empty keeps each client's default.
+func scraperUserAgent() string {
+	return appConfig.Scraper.UserAgent
+}
+
+// newScraperRequest builds a GET carrying the scraper's user agent.
+func newScraperRequest(ctx context.Context, target string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
 	if err != nil {
-		base = nil
+		return nil, err
+	}
+	if userAgent := scraperUserAgent(); userAgent != "" {
+		req.Header.Set("User-Agent", userAgent)
 	}
+	return req, nil
+}
 
-	type candidate struct {
-		selector string
-		attr     string
+// importOptions says who an import is for and how to extract it.
+type importOptions struct {
+	// Username is charged with the import's AI usage
+	Username string
+	// AIModel is an admin's "provider:model" override, or "" for the
+	// configured providers
+	AIModel string
+	// ctx is the queue item's context, done when the user cancels the item,
+	// the worker stops or the item's deadline passes; the page fetches run
+	// under it. nil for imports that can't be cancelled
+	ctx context.Context
+	// onStage records the queue item's progress; nil outside the queue
+	onStage func(stage string)
+}
+
+// setStage reports that the import has reached stage.
+func (o importOptions) setStage(stage string) {
+	if o.onStage != nil {
+		o.onStage(stage)
 	}
+}
 
-	selectors := []candidate{
-		{selector: "meta[property='og:image']", attr: "content"},
-		{selector: "meta[name='twitter:image']", attr: "content"},
-		{selector: "link[rel='apple-touch-icon']", attr: "href"},
-		{selector: "link[rel='icon']", attr: "href"},
+// importContext is the context the import's fetches run under.
+func (o importOptions) importContext() context.Context {
+	if o.ctx == nil {
+		return context.Background()
 	}
+	return o.ctx
+}
 
-	for _, item := range selectors {
-		selection := doc.Find(item.selector)
-		if selection.Length() == 0 {
-			continue
+// cancelled returns the context's error once the import was cancelled. The
+// pipeline checks it between its slow steps, such as AI extraction, that
+// don't take the context.
+func (o importOptions) cancelled() error {
+	if o.ctx == nil {
+		return nil
+	}
+	return o.ctx.Err()
+}
+
+func getRecipe(pageURL string, opts importOptions) (recipe Recipe, slug string, err error) {
+	ctx, span := startSpan(opts.importContext(), "scraper.getRecipe", attribute.String("url.full", pageURL))
+	defer func() { endSpan(span, err) }()
+	opts.ctx = ctx
+
+	if videoID := youTubeVideoID(pageURL); videoID != "" {
+		return getYouTubeRecipe(videoID, opts)
+	}
+	if isPDFURL(pageURL) {
+		// Chromium downloads PDFs instead of rendering them
+		content, err := fetchWithRetry(opts.importContext(), pageURL, fetchWithHTTP)
+		if err != nil {
+			return Recipe{}, "", err
 		}
-		found := ""
-		selection.EachWithBreak(func(i int, s *goquery.Selection) bool {
-			val, exists := s.Attr(item.attr)
-			if !exists {
-				return true
-			}
-			val = strings.TrimSpace(val)
-			if val == "" {
-				return true
-			}
-			resolved := resolveRelativeURL(base, val)
-			if resolved != "" {
-				found = resolved
-				return false
-			}
-			return true
-		})
-		if found != "" {
-			return found
+		if err := opts.cancelled(); err != nil {
+			return Recipe{}, "", err
 		}
+		return extractRecipeFromPDF(pageURL, []byte(content), opts)
 	}
 
-	if base != nil {
-		return base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String()
+	content, err := fetchWithRetry(opts.importContext(), pageURL, fetchPageHTML)
+	if err != nil {
+		return Recipe{}, "", err
 	}
+	if err := opts.cancelled(); err != nil {
+		return Recipe{}, "", err
+	}
+	// A PDF served without a .pdf URL arrives through the HTTP fallback
+	if isPDF([]byte(content)) {
+		return extractRecipeFromPDF(pageURL, []byte(content), opts)
+	}
+	return extractRecipeFromHTML(pageURL, content, opts)
+}
 
-	return ""
+func isPDFURL(pageURL string) bool {
+	parsed, err := url.Parse(pageURL)
+	return err == nil && strings.HasSuffix(strings.ToLower(parsed.Path), ".pdf")
 }
 
-func getRecipe(pageURL string) (Recipe, string, error) {
-	launch := launcher.New()
-	bin := findChromiumBinary()
-	if bin == "" {
-		log.Println("No Chromium/Chrome binary found; set CHROMIUM_BIN or install chromium")
-		return Recipe{}, "", errors.New("no Chromium/Chrome binary found; set CHROMIUM_BIN or install chromium")
+// extractRecipeFromPDF runs the AI extraction on a PDF's text. PDFs carry
+// no usable metadata image, so the photo is generated.
+func extractRecipeFromPDF(pageURL string, data []byte, opts importOptions) (Recipe, string, error) {
+	if !isPDF(data) {
+		return Recipe{}, "", fmt.Errorf("%s is not a pdf", pageURL)
+	}
+	opts.setStage(queueStageExtracting)
+	text, err := pdfToText(data)
+	if err != nil {
+		return Recipe{}, "", err
 	}
-	launch = launch.Bin(bin)
 
-	u, err := launch.Launch()
+	before := time.Now()
+	ai := aiProviderForImport(opts, pageURL)
+	recipe, err := aiExtractRecipe(opts.importContext(), ai, text)
 	if err != nil {
-		return Recipe{}, "", fmt.Errorf("launch browser: %w", err)
+		return Recipe{}, "", err
+	}
+	log.Println("Time to extract recipe: ", time.Since(before).String())
+	if err := opts.cancelled(); err != nil {
+		return Recipe{}, "", err
 	}
 
-	browser := rod.New().ControlURL(u)
-	if err := browser.Connect(); err != nil {
-		return Recipe{}, "", fmt.Errorf("connect browser: %w", err)
+	if recipe.Title == "" {
+		recipe.Title, _ = FallbackTitleAndSlug(pageURL)
+	}
+	slug := strings.ToLower(strings.ReplaceAll(recipe.Title, " ", "-"))
+	if storedImage := storeRecipeImage(ai, "", recipe.Title, slug); storedImage != "" {
+		recipe.Image = storedImage
 	}
-	defer browser.MustClose()
+	recipe.SourceKey = archiveSourceHTML(slug, pdfPageHTML(recipe.Title, text))
+	recipe.OriginalURL = pageURL
+	return recipe, slug, nil
+}
 
-	page := browser.MustPage().Timeout(60 * time.Second)
+// fetchPageHTML loads a page the way fetchModeFor(pageURL) says: in the
+// browser, or over plain HTTP with the browser only for pages that need it.
+func fetchPageHTML(ctx context.Context, pageURL string) (string, error) {
+	if fetchModeFor(pageURL) == fetchModeBrowser {
+		return fetchWithBrowser(ctx, pageURL)
+	}
 
-	// Try navigating with retries to mitigate transient "Execution context was destroyed" errors
-	var content string
-	var navErr error
-	for attempt := 1; attempt <= 2; attempt++ {
-		err = rod.Try(func() {
-			page.MustNavigate(pageURL).MustWaitLoad()
-		})
-		if err == nil {
-			content = page.MustHTML()
-			break
+	content, err := fetchWithHTTP(ctx, pageURL)
+	if err == nil {
+		reason := browserNeededReason(pageURL, content)
+		if reason == "" {
+			return content, nil
 		}
-		navErr = err
-		log.Printf("Scraper: navigation attempt %d failed: %v", attempt, err)
-		// Open a fresh page for the next attempt
-		page = browser.MustPage().Timeout(60 * time.Second)
-		time.Sleep(500 * time.Millisecond)
+		log.Printf("Scraper: %s %s over HTTP, retrying in the browser", pageURL, reason)
+	} else {
+		log.Printf("Scraper: HTTP fetch of %s failed, retrying in the browser: %v", pageURL, err)
+	}
+
+	rendered, browserErr := fetchWithBrowser(ctx, pageURL)
+	if browserErr != nil {
+		if content != "" {
+			// Better to try extracting from the static HTML than to fail
+			log.Printf("Scraper: browser fetch of %s failed, using the HTTP response: %v", pageURL, browserErr)
+			return content, nil
+		}
+		return "", browserErr
+	}
+	return rendered, nil
+}
+
+// fetchWithBrowser loads a page in a pooled headless Chromium tab, falling
+// back to a plain HTTP GET when navigation fails. Retries are left to the
+// caller's retryPolicy.
+func fetchWithBrowser(ctx context.Context, pageURL string) (string, error) {
+	var proxyServer string
+	if parsed, err := url.Parse(pageURL); err == nil {
+		if proxy, perDomain := scraperProxyFor(parsed.Hostname()); perDomain {
+			proxyServer = chromeProxyServer(proxy)
+		}
+	}
+	page, err := scraperBrowsers.Acquire(proxyServer)
+	if err != nil {
+		observeScrapeFetch("browser", err)
+		return "", err
+	}
+	healthy := true
+	defer func() { scraperBrowsers.Release(page, healthy) }()
+
+	if userAgent := scraperUserAgent(); userAgent != "" {
+		if err := page.SetUserAgent(&proto.NetworkSetUserAgentOverride{UserAgent: userAgent}); err != nil {
+			log.Printf("Scraper: set user agent: %v", err)
+		}
+	}
+
+	var content string
+	timeout := appConfig.Scraper.NavTimeout
+	navErr := rod.Try(func() {
+		nav := page.Context(ctx).Timeout(timeout)
+		nav.MustNavigate(pageURL).MustWaitLoad()
+		dismissConsent(nav, pageURL)
+		content = nav.MustHTML()
+	})
+	observeScrapeFetch("browser", navErr)
+	if navErr != nil {
+		healthy = false
+		log.Printf("Scraper: navigation of %s failed: %v", pageURL, navErr)
 	}
 
 	// If navigation failed, fall back to direct HTTP fetch of the page HTML
 	if strings.TrimSpace(content) == "" {
 		log.Printf("Scraper: falling back to HTTP fetch for %s", pageURL)
-		ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancel()
-		req, reqErr := http.NewRequestWithContext(ctx, http.MethodGet, pageURL, nil)
-		if reqErr != nil {
-			return Recipe{}, "", fmt.Errorf("build http request: %w", reqErr)
-		}
-		client := &http.Client{Timeout: 60 * time.Second}
-		resp, httpErr := client.Do(req)
+		body, httpErr := fetchWithHTTP(ctx, pageURL)
 		if httpErr != nil {
-			return Recipe{}, "", fmt.Errorf("page navigation timeout: %w; http fallback failed: %w", navErr, httpErr)
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != http.StatusOK {
-			return Recipe{}, "", fmt.Errorf("page navigation timeout: %w; http fallback status: %s", navErr, resp.Status)
+			return "", fmt.Errorf("page navigation timeout: %w; http fallback failed: %w", navErr, httpErr)
 		}
-		body, readErr := io.ReadAll(resp.Body)
-		if readErr != nil {
-			return Recipe{}, "", fmt.Errorf("http fallback read body: %w", readErr)
-		}
-		content = string(body)
+		content = body
+	}
+
+	return content, nil
+}
+
+// fetchWithHTTP GETs a page without a browser.
+func fetchWithHTTP(ctx context.Context, pageURL string) (content string, err error) {
+	defer func() { observeScrapeFetch("http", err) }()
+	timeout := appConfig.Scraper.HTTPTimeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := newScraperRequest(ctx, pageURL)
+	if err != nil {
+		return "", fmt.Errorf("build http request: %w", err)
+	}
+	client := scraperHTTPClient(pageURL, timeout)
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageHTMLBytes))
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+	return string(body), nil
+}
 
+// extractRecipeFromHTML tries the site's registered extractor and the page's
+// schema.org recipe data, falling back to AI extraction, and stores the image. The HTML may
+// come from the scraper or from the browser extension.
+func extractRecipeFromHTML(pageURL, content string, opts importOptions) (Recipe, string, error) {
+	opts.setStage(queueStageExtracting)
 	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
 	if err != nil {
 		return Recipe{}, "", err
 	}
 
-	doc.Find("script, style").Remove()
-	cleanedText := strings.TrimSpace(doc.Text())
+	before := time.Now()
+	ai := aiProviderForImport(opts, pageURL)
 
-	prompt := fmt.Sprintf("Extract the recipe details from the provided text, including name/title, description, instructions, ingredients, original_url, featuredImage, and category. Category must be one of: breakfast, dinner, baking, other. Choose the most appropriate one. Ensure all steps and ingredients are fully covered. %v", cleanedText)
+	// Collected before the AI branch strips the JSON-LD scripts
+	metadataImage := extractImageURL(doc, pageURL)
+
+	// Site-specific extractors first, then the schema.org data most recipe
+	// sites embed; only ask the AI when neither finds a recipe
+	responseRecipe, found := siteRecipe(doc, pageURL)
+	if found {
+		log.Printf("Scraper: using site extractor for %s", pageURL)
+		if responseRecipe.Image != "" {
+			metadataImage = responseRecipe.Image
+		}
+	} else if responseRecipe, found = structuredRecipe(doc); found {
+		log.Printf("Scraper: using structured recipe data for %s", pageURL)
+	} else {
+		// A bot check has nothing to extract; a paywalled page is only
+		// given up on when the AI can't find the whole recipe either
+		if pageLooksBlocked(doc) {
+			return Recipe{}, "", errPageBlocked
+		}
+		if err := opts.cancelled(); err != nil {
+			return Recipe{}, "", err
+		}
+		paywalled := pageLooksPaywalled(doc)
+		doc.Find("script, style").Remove()
+		responseRecipe, err = aiExtractRecipe(opts.importContext(), ai, readableRecipeText(doc))
+		if err != nil {
+			return Recipe{}, "", err
+		}
+		if paywalled && !recipeIsComplete(responseRecipe) {
+			return Recipe{}, "", errPaywalled
+		}
+	}
+	log.Println("Time to extract recipe: ", time.Since(before).String())
+	if err := opts.cancelled(); err != nil {
+		return Recipe{}, "", err
+	}
+
+	title := responseRecipe.Title
+	slug := strings.ToLower(strings.ReplaceAll(title, " ", "-"))
+	log.Printf("Slug for recipe: %s", slug)
+
+	if storedImage := storeRecipeImage(ai, metadataImage, title, slug); storedImage != "" {
+		responseRecipe.Image = storedImage
+	}
+	responseRecipe.SourceKey = archiveSourceHTML(slug, content)
+
+	// Emailed recipes are queued under a mid: URL that isn't worth linking
+	if strings.HasPrefix(pageURL, "http://") || strings.HasPrefix(pageURL, "https://") {
+		responseRecipe.OriginalURL = pageURL
+	}
+	return responseRecipe, slug, nil
+}
+
+var errAIExtraction = errors.New("ai recipe prompt failed")
+
+// aiExtractRecipe asks the AI for a recipe in text scraped from a page or
+// gathered from a video.
+func aiExtractRecipe(ctx context.Context, ai AIProvider, text string) (Recipe, error) {
+	if len(text) > maxRecipeTextChars {
+		log.Printf("Scraper: truncating %d chars of recipe text to %d", len(text), maxRecipeTextChars)
+		text = truncateText(text, maxRecipeTextChars)
+	}
+	prompt := fmt.Sprintf("Extract the recipe details from the provided text, including name/title, description, instructions, ingredients, original_url, featuredImage, and category. Category must be one of: breakfast, dinner, baking, other. Choose the most appropriate one. Put the ingredient section heading (e.g. 'For the sauce') in each parsed ingredient's group, or an empty string when the recipe has no sections. Also group the instructions into instructionSections (use the section headings from the page, or a single section with an empty name), with durationMinutes for steps that state a time and the step image URL when one is shown. List the required equipment (e.g. stand mixer, dutch oven) in equipment. Estimate the nutrition per serving (calories, and protein, carbs and fat in grams) from the ingredients and servings, using 0 for any value you can't estimate. Ensure all steps and ingredients are fully covered. %v", text)
 	system := "You assist in extracting recipe data from web pages and output in json format."
-	maxTokens := 16384
-	format := "text"
-	before := time.Now()
-	openaiKey := os.Getenv("OPENAI_KEY")
-	ai := NewClient(openaiKey, "gpt-5-mini", format, false)
-	response, err := ai.RecipePrompt(prompt, system, maxTokens)
+	response, err := cachedRecipePrompt(ctx, ai, prompt, system, appConfig.AI.MaxTokens)
 	if err != nil {
 		log.Println(err.Error())
-		return Recipe{}, "", fmt.Errorf("ai recipe prompt failed: %w", err)
+		return Recipe{}, fmt.Errorf("%w: %w", errAIExtraction, err)
 	}
 	if response == nil {
-		return Recipe{}, "", fmt.Errorf("ai recipe prompt returned nil response")
+		return Recipe{}, fmt.Errorf("%w: nil response", errAIExtraction)
 	}
-	spew.Dump(response)
 
-	responseRecipe := Recipe{}
-	if err := copier.Copy(&responseRecipe, &response); err != nil {
-		return Recipe{}, "", fmt.Errorf("copy ai response: %w", err)
+	var recipe Recipe
+	if err := copier.Copy(&recipe, &response); err != nil {
+		return Recipe{}, fmt.Errorf("copy ai response: %w", err)
 	}
-	log.Println("Time to call getting recipe AI: ", time.Since(before).String())
+	recipe.Nutrition = cleanNutrition(response.Nutrition)
+	recipe.SchemaVersion = recipeSchemaVersion()
 	log.Println(response.Category)
+	return recipe, nil
+}
 
-	title := response.Title
-	slug := strings.ToLower(strings.ReplaceAll(title, " ", "-"))
-	log.Printf("Slug for recipe: %s", slug)
+// cachedRecipePrompt answers a recipe prompt from earlier extractions of the
+// same text with the same model when it can, so re-saving a page or retrying
+// a failed save doesn't call the AI again. AI_CACHE_TTL=0 turns this off.
+// What's cached is the checked (and perhaps repaired) extraction, and only
+// when it passed: a bad answer cached for the page would be served to every
+// retry and reprocessing of it until the entry expired.
+func cachedRecipePrompt(ctx context.Context, ai AIProvider, prompt, system string, maxTokens int) (response *Response, err error) {
+	ctx, span := startSpan(ctx, "ai.RecipePrompt",
+		attribute.String("ai.provider", ai.Name()),
+		attribute.String("ai.model", ai.Model()),
+		attribute.Int("ai.prompt_chars", len(prompt)),
+	)
+	defer func() { endSpan(span, err) }()
+
+	ttl := appConfig.AI.CacheTTL
+	if ttl <= 0 || recipeRepo == nil {
+		return extractCheckedRecipe(ctx, ai, prompt, system, maxTokens)
+	}
+
+	sum := sha256.Sum256([]byte(aiExtractionCacheKey(ai, prompt, system)))
+	hash := hex.EncodeToString(sum[:])
+	cached, err := recipeRepo.GetCachedExtraction(hash, ttl)
+	if err == nil {
+		log.Printf("Scraper: reusing cached AI extraction %s", hash[:12])
+		observeCacheLookup(cacheAIExtraction, true)
+		span.SetAttributes(attribute.Bool("ai.cached", true))
+		return cached, nil
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		observeCacheLookup(cacheAIExtraction, false)
+	} else {
+		log.Printf("Scraper: AI cache lookup failed: %v", err)
+	}
+
+	response, err = extractCheckedRecipe(ctx, ai, prompt, system, maxTokens)
+	if err != nil {
+		return nil, err
+	}
+	spew.Dump(response)
+	if !extractionIsCacheable(response) {
+		log.Printf("Scraper: not caching AI extraction %s of %q, it has problems", hash[:12], response.Title)
+		return response, nil
+	}
+	if err := recipeRepo.SaveCachedExtraction(hash, ai.Model(), response); err != nil {
+		log.Printf("Scraper: AI cache store failed: %v", err)
+	}
+	return response, nil
+}
 
-	storedImage := ""
-	metadataImage := extractImageURL(doc, pageURL)
-	if metadataImage != "" {
-		url, err := storeImageFromURL(metadataImage, slug)
-		if err != nil {
-			log.Printf("Failed to store metadata image: %v", err)
-		} else {
-			storedImage = url
+// aiExtractionCacheKey is what a cached extraction is stored under: the
+// provider and model that answered, the schema version and extraction
+// pipeline version it was asked for, and the full prompt, which includes
+// the page text. Changing any of them misses the cache.
+func aiExtractionCacheKey(ai AIProvider, prompt, system string) string {
+	return strings.Join([]string{
+		ai.Name(),
+		ai.Model(),
+		strconv.Itoa(recipeSchemaVersion()),
+		strconv.Itoa(aiExtractionCacheVersion),
+		system,
+		prompt,
+	}, "\x00")
+}
+
+// extractionIsCacheable reports an extraction with no problems that makes a
+// complete recipe.
+func extractionIsCacheable(response *Response) bool {
+	if len(extractionProblems(response)) > 0 {
+		return false
+	}
+	instructions := response.Instructions
+	if len(instructions) == 0 {
+		instructions = flattenInstructionSections(response.InstructionSections)
+	}
+	return recipeIsComplete(Recipe{
+		Title:             response.Title,
+		Ingredients:       response.Ingredients,
+		ParsedIngredients: response.ParsedIngredients,
+		Instructions:      instructions,
+	})
+}
+
+// storeRecipeImage copies sourceImage to storage, generating a photo of
+// title when there is none or it can't be downloaded (see image_gen.go). It
+// returns the stored URL, else IMAGE_PLACEHOLDER_URL, which may be "".
+func storeRecipeImage(ai AIProvider, sourceImage, title, slug string) string {
+	if sourceImage != "" {
+		url, err := storeImageFromURL(sourceImage, slug)
+		if err == nil {
+			recipeImagesTotal.WithLabelValues(recipeImagePage).Inc()
+			return url
 		}
+		log.Printf("Failed to store metadata image: %v", err)
 	}
 
-	if storedImage == "" {
-		promptText := fmt.Sprintf("High quality food photography of %s, plated, natural lighting", title)
-		imageURL, err := ai.GenerateImage(promptText)
-		if err != nil {
+	generator, err := imageGeneratorFor(ai)
+	if err != nil {
+		if !errors.Is(err, errImageGenerationDisabled) {
 			log.Printf("Error generating image: %v", err)
-		} else {
-			log.Printf("Image URL: %s", imageURL)
-			url, err := storeImageFromURL(imageURL, slug)
-			if err != nil {
-				log.Printf("Failed to store generated image: %v", err)
-			} else {
-				storedImage = url
-			}
 		}
+		return recipeImagePlaceholderURL()
 	}
-
-	if storedImage != "" {
-		responseRecipe.Image = storedImage
+	promptText := fmt.Sprintf("High quality food photography of %s, plated, natural lighting", title)
+	image, err := generator.GenerateImage(promptText)
+	if err != nil {
+		log.Printf("Error generating image: %v", err)
+		return recipeImagePlaceholderURL()
 	}
+	var url string
+	if len(image.Data) > 0 {
+		url, err = storeImageData(image.Data, image.ContentType, slug, "")
+	} else {
+		log.Printf("Image URL: %s", image.URL)
+		url, err = storeImageFromURL(image.URL, slug)
+	}
+	if err != nil {
+		log.Printf("Failed to store generated image: %v", err)
+		return recipeImagePlaceholderURL()
+	}
+	recipeImagesTotal.WithLabelValues(recipeImageGenerated).Inc()
+	return url
+}
 
-	responseRecipe.OriginalURL = pageURL
-	return responseRecipe, slug, nil
+// recipeImagePlaceholderURL is imagePlaceholderURL for a recipe that got no
+// photo, counted in the metrics.
+func recipeImagePlaceholderURL() string {
+	recipeImagesTotal.WithLabelValues(recipeImagePlaceholder).Inc()
+	return imagePlaceholderURL()
 }
 
 func storeImageFromURL(imageURL, slug string) (string, error) {
@@ -262,12 +544,13 @@ func storeImageFromURL(imageURL, slug string) (string, error) {
 		return "", errors.New("image url is empty")
 	}
 
-	// Create HTTP client with 60-second timeout
-	client := &http.Client{
-		Timeout: 60 * time.Second,
-	}
+	client := scraperHTTPClient(imageURL, 60*time.Second)
 
-	resp, err := client.Get(imageURL)
+	req, err := newScraperRequest(context.Background(), imageURL)
+	if err != nil {
+		return "", fmt.Errorf("build image request: %w", err)
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return "", fmt.Errorf("download image: %w", err)
 	}
@@ -282,14 +565,19 @@ func storeImageFromURL(imageURL, slug string) (string, error) {
 		return "", fmt.Errorf("read image: %w", err)
 	}
 
-	contentType := resp.Header.Get("Content-Type")
+	return storeImageData(data, resp.Header.Get("Content-Type"), slug, filepath.Ext(imageURL))
+}
+
+// storeImageData uploads image bytes for slug, naming the file by its
+// content type, else fallbackExt, else .jpg.
+func storeImageData(data []byte, contentType, slug, fallbackExt string) (string, error) {
 	if contentType == "" {
 		contentType = http.DetectContentType(data)
 	}
 
 	ext := extensionForContentType(contentType)
 	if ext == "" {
-		ext = filepath.Ext(imageURL)
+		ext = fallbackExt
 	}
 	if ext == "" {
 		ext = ".jpg"
@@ -297,12 +585,19 @@ func storeImageFromURL(imageURL, slug string) (string, error) {
 
 	key := fmt.Sprintf("images/%s-%d%s", slug, time.Now().Unix(), ext)
 
+	return uploadImageToStorage(key, contentType, data)
+}
+
+// uploadImageToStorage uploads image bytes to R2 under key and returns the public URL.
+func uploadImageToStorage(key, contentType string, data []byte) (string, error) {
 	s3Client, err := NewCloudflareS3()
 	if err != nil {
 		return "", fmt.Errorf("initialize S3 client: %w", err)
 	}
 
-	if err := s3Client.UploadImage(key, contentType, data); err != nil {
+	err = s3Client.UploadImage(key, contentType, data)
+	observeImageUpload(len(data), err)
+	if err != nil {
 		return "", fmt.Errorf("upload image: %w", err)
 	}
 
@@ -326,8 +621,12 @@ func extensionForContentType(contentType string) string {
 
 // fetchTitleViaHTTP attempts a lightweight fetch of the page and returns the best-effort title.
 func fetchTitleViaHTTP(pageURL string) string {
-	client := &http.Client{Timeout: 15 * time.Second}
-	resp, err := client.Get(pageURL)
+	client := scraperHTTPClient(pageURL, 15*time.Second)
+	req, err := newScraperRequest(context.Background(), pageURL)
+	if err != nil {
+		return ""
+	}
+	resp, err := client.Do(req)
 	if err != nil {
 		return ""
 	}
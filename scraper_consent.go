@@ -0,0 +1,102 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"github.com/go-rod/rod"
+)
+
+// Consent walls: after a page loads in the browser, the first visible
+// "accept" button of a known consent manager is clicked and any consent
+// overlay left in the DOM is removed, so the captured HTML is the recipe
+// rather than GDPR boilerplate. SCRAPER_CONSENT_SELECTORS adds
+// comma-separated accept-button selectors; SCRAPER_DISMISS_CONSENT=false
+// turns the step off.
+
+var defaultConsentSelectors = []string{
+	"#onetrust-accept-btn-handler",
+	"#didomi-notice-agree-button",
+	"#CybotCookiebotDialogBodyLevelButtonLevelOptinAllowAll",
+	"#CybotCookiebotDialogBodyButtonAccept",
+	"#truste-consent-button",
+	".qc-cmp2-summary-buttons button[mode='primary']",
+	".fc-cta-consent",
+	".cmpboxbtnyes",
+	"#cookie-law-info-bar #cookie_action_close_header",
+	".cky-btn-accept",
+	"[data-testid='uc-accept-all-button']",
+}
+
+// consentContainerSelectors are overlays removed whether or not a button
+// was clicked; some (Sourcepoint) live in iframes that can't be clicked.
+var consentContainerSelectors = []string{
+	"#onetrust-consent-sdk",
+	"#didomi-host",
+	"#CybotCookiebotDialog",
+	".qc-cmp2-container",
+	".fc-consent-root",
+	"[id^='sp_message_container']",
+	"#truste-consent-track",
+	"#cmpbox",
+	"#cmpbox2",
+	"#usercentrics-root",
+}
+
+// consentButtonTexts matches accept buttons by label inside elements whose
+// id or class mentions consent or cookies.
+var consentButtonTexts = []string{
+	"accept all", "accept", "i agree", "agree", "allow all", "got it",
+	"alle akzeptieren", "akzeptieren", "tout accepter", "accepter",
+	"accetta tutto", "accetta", "aceptar todo", "aceptar", "alles accepteren", "accepteren",
+}
+
+const dismissConsentScript = `(selectors, containers, texts) => {
+	const visible = (el) => !!(el.offsetWidth || el.offsetHeight || el.getClientRects().length);
+	let clicked = false;
+	for (const selector of selectors) {
+		let el = null;
+		try { el = document.querySelector(selector); } catch (e) { continue; }
+		if (el && visible(el)) { el.click(); clicked = true; break; }
+	}
+	if (!clicked) {
+		const scopes = document.querySelectorAll('[id*="consent" i], [class*="consent" i], [id*="cookie" i], [class*="cookie" i], [id*="gdpr" i], [class*="cmp" i]');
+		outer: for (const scope of scopes) {
+			for (const el of scope.querySelectorAll('button, a[role="button"], [role="button"]')) {
+				const label = (el.innerText || el.textContent || '').trim().toLowerCase();
+				if (label && texts.some((t) => label === t || label.startsWith(t + ' ')) && visible(el)) { el.click(); clicked = true; break outer; }
+			}
+		}
+	}
+	for (const selector of containers) {
+		document.querySelectorAll(selector).forEach((el) => el.remove());
+	}
+	// Overlays often lock scrolling, which some lazy content waits on
+	document.documentElement.style.overflow = '';
+	document.body && (document.body.style.overflow = '');
+	return clicked;
+}`
+
+// dismissConsent runs the consent step on a loaded page. It never fails the
+// scrape; a page without a consent wall is left as it is.
+func dismissConsent(page *rod.Page, pageURL string) {
+	if !appConfig.Scraper.DismissConsent {
+		return
+	}
+
+	selectors := append(append([]string{}, defaultConsentSelectors...), appConfig.Scraper.ConsentSelectors...)
+
+	result, err := page.Eval(dismissConsentScript, selectors, consentContainerSelectors, consentButtonTexts)
+	if err != nil {
+		log.Printf("Scraper: consent check on %s failed: %v", pageURL, err)
+		return
+	}
+	if result.Value.Bool() {
+		log.Printf("Scraper: dismissed consent dialog on %s", pageURL)
+		// Give the site a moment to reveal or re-render the content
+		time.Sleep(consentSettleDelay)
+		if err := page.WaitLoad(); err != nil {
+			log.Printf("Scraper: wait after consent on %s: %v", pageURL, err)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/patrickmn/go-cache"
+)
+
+// errDisallowedByRobots fails a scrape the site's robots.txt forbids.
+// Retrying won't help, so the queue gives up on the item right away.
+var errDisallowedByRobots = errors.New("disallowed by robots.txt")
+
+// scraperRobotsAgent is the product token matched against robots.txt
+// User-agent lines, along with SCRAPER_USER_AGENT; groups for "*" apply
+// when none names either.
+const scraperRobotsAgent = "recipes-api"
+
+// scrapeThrottle allows one fetch at a time per domain, spaced at least the
+// domain's interval apart.
+var scrapeThrottle = &domainThrottle{domains: map[string]*domainSlot{}}
+
+// awaitScrapeTurn checks robots.txt for pageURL and blocks until its domain
+// may be fetched from. Callers make their requests, then call release.
+func awaitScrapeTurn(pageURL string) (release func(), err error) {
+	parsed, err := url.Parse(pageURL)
+	if err != nil || parsed.Hostname() == "" {
+		return nil, fmt.Errorf("%w %q", errInvalidScrapeURL, pageURL)
+	}
+
+	robots := robotsFor(parsed)
+	if !robots.allowed(parsed.RequestURI()) {
+		return nil, fmt.Errorf("%w: %s", errDisallowedByRobots, parsed.RequestURI())
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+	return scrapeThrottle.wait(domain, scrapeInterval(parsed.Hostname(), robots)), nil
+}
+
+// scrapeInterval is the minimum gap between fetches from host:
+// SCRAPER_DOMAIN_INTERVALS ("example.com=30s") or SCRAPER_DOMAIN_INTERVAL,
+// raised to the robots.txt Crawl-delay.
+func scrapeInterval(host string, robots robotsRules) time.Duration {
+	interval := appConfig.Scraper.DomainInterval
+	if perDomain, _, ok := lookupDomain(appConfig.Scraper.DomainIntervals, host); ok {
+		interval = perDomain
+	}
+	return max(interval, min(robots.crawlDelay, maxCrawlDelay))
+}
+
+type domainThrottle struct {
+	mu      sync.Mutex
+	domains map[string]*domainSlot
+}
+
+type domainSlot struct {
+	// busy holds one token while a fetch runs
+	busy chan struct{}
+	// users counts fetches holding or waiting for the slot; guarded by mu
+	users int
+	// next is when the following fetch may start; guarded by mu
+	next time.Time
+}
+
+// wait blocks until domain is free and its interval has passed. The
+// returned func ends the fetch and starts the next interval.
+func (t *domainThrottle) wait(domain string, interval time.Duration) func() {
+	t.mu.Lock()
+	slot, ok := t.domains[domain]
+	if !ok {
+		t.sweep(time.Now())
+		slot = &domainSlot{busy: make(chan struct{}, 1)}
+		t.domains[domain] = slot
+	}
+	slot.users++
+	t.mu.Unlock()
+
+	slot.busy <- struct{}{}
+	t.mu.Lock()
+	next := slot.next
+	t.mu.Unlock()
+	if delay := time.Until(next); delay > 0 {
+		log.Printf("Scraper: waiting %s before fetching from %s", delay.Round(time.Millisecond), domain)
+		time.Sleep(delay)
+	}
+	return func() {
+		t.mu.Lock()
+		slot.next = time.Now().Add(interval)
+		slot.users--
+		t.mu.Unlock()
+		<-slot.busy
+	}
+}
+
+// sweep forgets domains nobody is fetching from whose interval has passed,
+// so a long-running worker doesn't keep a slot for every site it has seen.
+// A forgotten domain behaves exactly like one never fetched. Callers hold mu.
+func (t *domainThrottle) sweep(now time.Time) {
+	for domain, slot := range t.domains {
+		if slot.users == 0 && !now.Before(slot.next) {
+			delete(t.domains, domain)
+		}
+	}
+}
+
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+}
+
+type robotsRule struct {
+	allow   bool
+	pattern string
+	match   *regexp.Regexp
+}
+
+// robotsFor returns the site's rules for scraperRobotsAgent. A missing
+// robots.txt allows everything. So does one that can't be fetched, which is
+// laxer than RFC 9309, but it is only cached briefly so a flaky site doesn't
+// fail imports for a day.
+func robotsFor(pageURL *url.URL) robotsRules {
+	origin := pageURL.Scheme + "://" + pageURL.Host
+	if cached, ok := robotsCache.Get(origin); ok {
+		observeCacheLookup(cacheRobots, true)
+		return cached.(robotsRules)
+	}
+	observeCacheLookup(cacheRobots, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), robotsFetchTimeout)
+	defer cancel()
+	req, err := newScraperRequest(ctx, origin+"/robots.txt")
+	if err != nil {
+		return robotsRules{}
+	}
+	resp, err := scraperHTTPClient(origin, robotsFetchTimeout).Do(req)
+	if err != nil {
+		log.Printf("Scraper: fetch robots.txt for %s: %v", origin, err)
+		robotsCache.Set(origin, robotsRules{}, robotsRetryTTL)
+		return robotsRules{}
+	}
+	defer resp.Body.Close()
+
+	var rules robotsRules
+	switch {
+	case resp.StatusCode >= 500:
+		log.Printf("Scraper: robots.txt for %s: %s", origin, resp.Status)
+		robotsCache.Set(origin, rules, robotsRetryTTL)
+		return rules
+	case resp.StatusCode == http.StatusOK:
+		rules = parseRobots(io.LimitReader(resp.Body, maxRobotsBytes), scraperRobotsAgent+" "+scraperUserAgent())
+	}
+	robotsCache.Set(origin, rules, cache.DefaultExpiration)
+	return rules
+}
+
+// parseRobots reads the group for agent, or the "*" group when no group
+// names it. Groups naming the same agent are merged.
+func parseRobots(r io.Reader, agent string) robotsRules {
+	agent = strings.ToLower(agent)
+	groups := map[string]*robotsRules{}
+	var current []string
+	inAgents := false
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		if key == "user-agent" {
+			if !inAgents {
+				current = nil
+			}
+			inAgents = true
+			name := strings.ToLower(value)
+			current = append(current, name)
+			if groups[name] == nil {
+				groups[name] = &robotsRules{}
+			}
+			continue
+		}
+		inAgents = false
+
+		for _, name := range current {
+			group := groups[name]
+			switch key {
+			case "allow", "disallow":
+				if value == "" {
+					// "Disallow:" with no path allows everything
+					continue
+				}
+				group.rules = append(group.rules, robotsRule{
+					allow:   key == "allow",
+					pattern: value,
+					match:   robotsPattern(value),
+				})
+			case "crawl-delay":
+				if seconds, err := strconv.ParseFloat(value, 64); err == nil && seconds > 0 {
+					group.crawlDelay = time.Duration(seconds * float64(time.Second))
+				}
+			}
+		}
+	}
+
+	// The most specific User-agent that is part of our token wins
+	best := ""
+	for name := range groups {
+		if name != "*" && strings.Contains(agent, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		best = "*"
+	}
+	if group, ok := groups[best]; ok {
+		return *group
+	}
+	return robotsRules{}
+}
+
+// robotsPattern compiles a path pattern where * matches anything and a
+// trailing $ anchors the end.
+func robotsPattern(pattern string) *regexp.Regexp {
+	anchored := strings.HasSuffix(pattern, "$")
+	pattern = strings.TrimSuffix(pattern, "$")
+	expr := "^" + strings.ReplaceAll(regexp.QuoteMeta(pattern), `\*`, ".*")
+	if anchored {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
+
+// allowed applies the longest rule matching path (with its query),
+// preferring allow on ties.
+func (r robotsRules) allowed(path string) bool {
+	if path == "/robots.txt" {
+		return true
+	}
+	allow, longest := true, -1
+	for _, rule := range r.rules {
+		if !rule.match.MatchString(path) {
+			continue
+		}
+		if len(rule.pattern) > longest || (len(rule.pattern) == longest && rule.allow) {
+			allow, longest = rule.allow, len(rule.pattern)
+		}
+	}
+	return allow
+}
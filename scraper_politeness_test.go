@@ -0,0 +1,26 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDomainThrottleForgetsIdleDomains(t *testing.T) {
+	throttle := &domainThrottle{domains: map[string]*domainSlot{}}
+
+	throttle.wait("old.example", 0)()
+	release := throttle.wait("busy.example", 0)
+	throttle.wait("spaced.example", time.Hour)()
+
+	throttle.wait("new.example", 0)()
+	if _, ok := throttle.domains["old.example"]; ok {
+		t.Error("idle domain past its interval was kept")
+	}
+	if _, ok := throttle.domains["busy.example"]; !ok {
+		t.Error("domain with a fetch in progress was forgotten")
+	}
+	if _, ok := throttle.domains["spaced.example"]; !ok {
+		t.Error("domain still inside its interval was forgotten")
+	}
+	release()
+}
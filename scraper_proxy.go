@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scraper proxies: SCRAPER_PROXY routes every fetch through an http, https
+// or socks5 proxy, and SCRAPER_DOMAIN_PROXIES overrides it per domain
+// ("example.com=socks5://10.0.0.2:1080,other.com=direct"). Credentials in
+// the URL work for plain HTTP fetches; Chromium can't send them, so the
+// browser needs a proxy that allows the server's IP.
+
+// scraperProxyFor returns the proxy for fetches from host, nil meaning a
+// direct connection. perDomain reports whether a SCRAPER_DOMAIN_PROXIES
+// entry decided it rather than SCRAPER_PROXY.
+func scraperProxyFor(host string) (proxy *url.URL, perDomain bool) {
+	if proxy, _, ok := lookupDomain(appConfig.Scraper.DomainProxies, host); ok {
+		return proxy, true
+	}
+	return appConfig.Scraper.Proxy, false
+}
+
+// parseScraperProxy reads a proxy URL; empty and "direct" mean no proxy.
+func parseScraperProxy(raw string) (*url.URL, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" || strings.EqualFold(raw, "direct") {
+		return nil, nil
+	}
+	proxy, err := url.Parse(raw)
+	if err != nil || proxy.Host == "" {
+		return nil, fmt.Errorf("%q is not a proxy URL", raw)
+	}
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+		return proxy, nil
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q", proxy.Scheme)
+	}
+}
+
+// chromeProxyServer formats proxy for --proxy-server and browser contexts.
+func chromeProxyServer(proxy *url.URL) string {
+	if proxy == nil {
+		return "direct://"
+	}
+	if proxy.User != nil {
+		log.Printf("Scraper: browser can't authenticate to proxy %s, sending no credentials", proxy.Host)
+	}
+	scheme := proxy.Scheme
+	if scheme == "socks5h" {
+		// Chromium always resolves names through a socks5 proxy
+		scheme = "socks5"
+	}
+	return scheme + "://" + proxy.Host
+}
+
+// scraperTransports holds one transport per proxy so connections are reused.
+var scraperTransports sync.Map
+
+// scraperHTTPClient returns a client for fetching target through its
+// configured proxy. Without one it uses the default transport, which still
+// honors HTTP_PROXY, unless a domain entry says "direct".
+func scraperHTTPClient(target string, timeout time.Duration) *http.Client {
+	client := &http.Client{Timeout: timeout}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return client
+	}
+	proxy, perDomain := scraperProxyFor(parsed.Hostname())
+	if proxy == nil && !perDomain {
+		return client
+	}
+
+	key := "direct"
+	if proxy != nil {
+		key = proxy.String()
+	}
+	if cached, ok := scraperTransports.Load(key); ok {
+		client.Transport = cached.(*http.Transport)
+		return client
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	if proxy != nil {
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	actual, _ := scraperTransports.LoadOrStore(key, transport)
+	client.Transport = actual.(*http.Transport)
+	return client
+}
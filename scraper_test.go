@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// stubAI answers every extraction with response.
+type stubAI struct {
+	response Response
+	calls    int
+}
+
+func (s *stubAI) Name() string  { return "stub" }
+func (s *stubAI) Model() string { return "stub-model" }
+func (s *stubAI) ExtractRecipe(ctx context.Context, prompt, systemPrompt string, maxTokens int) (*Response, error) {
+	s.calls++
+	response := s.response
+	return &response, nil
+}
+func (s *stubAI) GenerateImage(prompt string) (GeneratedImage, error) {
+	return GeneratedImage{}, errAIUnsupported
+}
+func (s *stubAI) Validate(title, image string) (bool, error) { return true, nil }
+func (s *stubAI) Chat(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int) (ChatReply, error) {
+	return ChatReply{}, errAIUnsupported
+}
+func (s *stubAI) ChatStream(ctx context.Context, systemPrompt string, messages []chatMessage, maxTokens int, onDelta func(string)) (ChatReply, error) {
+	return ChatReply{}, errAIUnsupported
+}
+
+func countCachedExtractions(t *testing.T, repo *RecipeRepository) int64 {
+	t.Helper()
+	var count int64
+	if err := repo.db.Model(&AIExtractionModel{}).Count(&count).Error; err != nil {
+		t.Fatalf("count ai extractions: %v", err)
+	}
+	return count
+}
+
+func TestCachedRecipePromptSkipsBadExtractions(t *testing.T) {
+	recipeRepo = newTestRepo(t)
+	previousConfig := appConfig
+	appConfig.AI.CacheTTL = time.Hour
+	t.Cleanup(func() { appConfig = previousConfig })
+
+	bad := &stubAI{response: Response{Title: "Soup", Category: "dinner"}}
+	if _, err := cachedRecipePrompt(context.Background(), bad, "page text", "system", 100); err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if _, err := cachedRecipePrompt(context.Background(), bad, "page text", "system", 100); err != nil {
+		t.Fatalf("extract again: %v", err)
+	}
+	if n := countCachedExtractions(t, recipeRepo); n != 0 {
+		t.Fatalf("cached %d bad extractions, want 0", n)
+	}
+	// Each call asks once and repairs once, nothing comes from the cache
+	if bad.calls != 4 {
+		t.Fatalf("AI calls = %d, want 4", bad.calls)
+	}
+
+	good := &stubAI{response: Response{
+		Title:        "Soup",
+		Category:     "dinner",
+		Ingredients:  []string{"1 onion", "1 l stock"},
+		Instructions: []string{"Chop the onion.", "Simmer in the stock."},
+	}}
+	if _, err := cachedRecipePrompt(context.Background(), good, "other page text", "system", 100); err != nil {
+		t.Fatalf("extract good: %v", err)
+	}
+	if _, err := cachedRecipePrompt(context.Background(), good, "other page text", "system", 100); err != nil {
+		t.Fatalf("extract good again: %v", err)
+	}
+	if n := countCachedExtractions(t, recipeRepo); n != 1 || good.calls != 1 {
+		t.Fatalf("cached %d extractions after %d AI calls, want 1 after 1", n, good.calls)
+	}
+}
+
+func TestAIExtractionCacheKeyIncludesProviderAndModel(t *testing.T) {
+	a := &stubAI{}
+	b := &otherModelAI{stubAI{}}
+	if aiExtractionCacheKey(a, "text", "system") == aiExtractionCacheKey(b, "text", "system") {
+		t.Fatal("extractions by different models share a cache key")
+	}
+	if aiExtractionCacheKey(a, "text", "system") == aiExtractionCacheKey(a, "other text", "system") {
+		t.Fatal("extractions of different text share a cache key")
+	}
+}
+
+type otherModelAI struct{ stubAI }
+
+func (otherModelAI) Model() string { return "stub-model-2" }
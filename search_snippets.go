@@ -0,0 +1,203 @@
+package main
+
+import (
+	"html"
+	"strings"
+	"unicode/utf8"
+)
+
+const maxSnippetRunes = 160
+
+// SearchMatch explains why a recipe came back from search. Snippet is HTML
+// escaped with the matching words wrapped in <mark>.
+type SearchMatch struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+type snippetCandidate struct {
+	field string
+	text  string
+}
+
+// findSearchMatch picks the title, ingredient line or instruction sentence
+// that matches the most search words. Earlier fields win ties, mirroring the
+// bm25 weighting. Returns nil when nothing matches literally (e.g. a stemmed
+// FTS hit).
+func findSearchMatch(recipe Recipe, opts RecipeSearchOptions) *SearchMatch {
+	tokens := searchTokens(opts.Term)
+	for _, ingredient := range opts.Ingredients {
+		tokens = append(tokens, searchTokens(ingredient)...)
+	}
+	if len(tokens) == 0 {
+		return nil
+	}
+
+	candidates := []snippetCandidate{{field: "title", text: recipe.Title}}
+	for _, ingredient := range recipe.Ingredients {
+		candidates = append(candidates, snippetCandidate{field: "ingredients", text: ingredient})
+	}
+	for _, step := range recipe.Instructions {
+		for _, sentence := range splitSentences(step) {
+			candidates = append(candidates, snippetCandidate{field: "instructions", text: sentence})
+		}
+	}
+
+	var best *snippetCandidate
+	bestHits := 0
+	for i := range candidates {
+		hits := countTokenHits(candidates[i].text, tokens)
+		if hits > bestHits {
+			best = &candidates[i]
+			bestHits = hits
+		}
+	}
+	if best == nil {
+		return nil
+	}
+
+	return &SearchMatch{
+		Field:   best.field,
+		Snippet: highlightSnippet(best.text, tokens),
+	}
+}
+
+// splitSentences breaks an instruction step on sentence-ending punctuation.
+func splitSentences(text string) []string {
+	var sentences []string
+	start := 0
+	for i, r := range text {
+		if r != '.' && r != '!' && r != '?' {
+			continue
+		}
+		next := i + 1
+		if next < len(text) && text[next] != ' ' {
+			continue // "1.5", "approx.5"
+		}
+		if sentence := strings.TrimSpace(text[start:next]); sentence != "" {
+			sentences = append(sentences, sentence)
+		}
+		start = next
+	}
+	if sentence := strings.TrimSpace(text[start:]); sentence != "" {
+		sentences = append(sentences, sentence)
+	}
+	return sentences
+}
+
+// searchWord is a word in a snippet candidate, by byte offsets.
+type searchWord struct {
+	start, end int
+}
+
+func splitSearchWords(text string) []searchWord {
+	var words []searchWord
+	start := -1
+	for i, r := range text {
+		isWord := isSearchWordRune(r) || (r >= 'A' && r <= 'Z')
+		switch {
+		case isWord && start < 0:
+			start = i
+		case !isWord && start >= 0:
+			words = append(words, searchWord{start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		words = append(words, searchWord{start, len(text)})
+	}
+	return words
+}
+
+// wordMatches uses the same prefix semantics as the FTS query.
+func wordMatches(word string, tokens []string) bool {
+	word = strings.Trim(strings.ToLower(word), "'-")
+	for _, token := range tokens {
+		if strings.HasPrefix(word, token) {
+			return true
+		}
+	}
+	return false
+}
+
+func countTokenHits(text string, tokens []string) int {
+	seen := make(map[string]bool)
+	for _, w := range splitSearchWords(text) {
+		word := strings.Trim(strings.ToLower(text[w.start:w.end]), "'-")
+		for _, token := range tokens {
+			if strings.HasPrefix(word, token) {
+				seen[token] = true
+			}
+		}
+	}
+	return len(seen)
+}
+
+// highlightSnippet trims text to a window around the first match and marks
+// every matching word.
+func highlightSnippet(text string, tokens []string) string {
+	words := splitSearchWords(text)
+
+	windowStart, windowEnd := 0, len(text)
+	if utf8.RuneCountInString(text) > maxSnippetRunes {
+		first := 0
+		for _, w := range words {
+			if wordMatches(text[w.start:w.end], tokens) {
+				first = w.start
+				break
+			}
+		}
+		windowStart = first
+		// Keep a little leading context, starting on a word boundary
+		for _, w := range words {
+			if w.start >= first {
+				break
+			}
+			if first-w.start <= maxSnippetRunes/4 {
+				windowStart = w.start
+				break
+			}
+		}
+		windowEnd = len(text)
+		count := 0
+		for i := range text[windowStart:] {
+			if count == maxSnippetRunes {
+				windowEnd = windowStart + i
+				break
+			}
+			count++
+		}
+		for j := len(words) - 1; j >= 0; j-- {
+			if words[j].start < windowEnd && words[j].end > windowEnd {
+				if words[j].start > windowStart {
+					windowEnd = words[j].start
+				}
+				break
+			}
+		}
+	}
+
+	var b strings.Builder
+	if windowStart > 0 {
+		b.WriteString("…")
+	}
+	pos := windowStart
+	for _, w := range words {
+		if w.start < windowStart || w.end > windowEnd {
+			continue
+		}
+		if !wordMatches(text[w.start:w.end], tokens) {
+			continue
+		}
+		b.WriteString(html.EscapeString(text[pos:w.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(text[w.start:w.end]))
+		b.WriteString("</mark>")
+		pos = w.end
+	}
+	b.WriteString(html.EscapeString(strings.TrimRight(text[pos:windowEnd], " ")))
+	if windowEnd < len(text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"log"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// siteExtractor pulls a recipe out of one site's markup. It reports false
+// when the page doesn't look as expected, and extraction falls back to the
+// generic schema.org and AI path.
+type siteExtractor interface {
+	Extract(doc *goquery.Document, pageURL *url.URL) (Recipe, bool)
+}
+
+// siteExtractorFunc adapts a function to siteExtractor for sites that need
+// more than selectors.
+type siteExtractorFunc func(doc *goquery.Document, pageURL *url.URL) (Recipe, bool)
+
+func (f siteExtractorFunc) Extract(doc *goquery.Document, pageURL *url.URL) (Recipe, bool) {
+	return f(doc, pageURL)
+}
+
+// siteExtractors is keyed by domain without "www."; a domain also covers its
+// subdomains unless a more specific one is registered.
+var siteExtractors = map[string]siteExtractor{}
+
+func registerSiteExtractor(domain string, extractor siteExtractor) {
+	siteExtractors[strings.TrimPrefix(strings.ToLower(domain), "www.")] = extractor
+}
+
+func init() {
+	registerSiteExtractor("seriouseats.com", selectorExtractor{
+		Title:        "h1.heading__title",
+		Ingredients:  ".structured-ingredients__list-item",
+		Instructions: ".structured-project__steps ol > li > p",
+		Image:        ".primary-image__image, .figure-article img",
+		Servings:     ".recipe-serving .meta-text__data, .recipe-yield .meta-text__data",
+		PrepTime:     ".prep-time .meta-text__data",
+		CookTime:     ".cook-time .meta-text__data",
+		TotalTime:    ".total-time .meta-text__data",
+	})
+	registerSiteExtractor("cooking.nytimes.com", selectorExtractor{
+		Title:        "h1",
+		Ingredients:  `li[class*="ingredient_ingredient"]`,
+		Instructions: `li[class*="preparation_step"] p`,
+		Image:        `meta[property="og:image"]`,
+		Servings:     `[class*="ingredients_recipeYield"]`,
+		TotalTime:    `[class*="stats_cookingTimeTable"] dd`,
+	})
+}
+
+// siteRecipe runs the registered extractor for pageURL's host, if any.
+func siteRecipe(doc *goquery.Document, pageURL string) (Recipe, bool) {
+	parsed, err := url.Parse(pageURL)
+	if err != nil || parsed.Hostname() == "" {
+		return Recipe{}, false
+	}
+
+	extractor, domain, ok := lookupDomain(siteExtractors, parsed.Hostname())
+	if !ok {
+		return Recipe{}, false
+	}
+	recipe, ok := extractor.Extract(doc, parsed)
+	if !ok {
+		log.Printf("Scraper: %s extractor found no recipe on %s", domain, pageURL)
+	}
+	return recipe, ok
+}
+
+// selectorExtractor reads a recipe with CSS selectors, which covers most
+// site-specific fixes. Empty selectors are skipped; Title, Ingredients and
+// Instructions must match for the result to count.
+type selectorExtractor struct {
+	Title        string
+	Ingredients  string
+	Instructions string
+	// Image matches img (src, data-src) or meta (content) elements
+	Image     string
+	Servings  string
+	PrepTime  string
+	CookTime  string
+	TotalTime string
+	Category  string
+	Tags      string
+}
+
+func (e selectorExtractor) Extract(doc *goquery.Document, pageURL *url.URL) (Recipe, bool) {
+	recipe := Recipe{
+		Title:        selectorText(doc, e.Title),
+		Ingredients:  selectorTexts(doc, e.Ingredients),
+		Instructions: selectorTexts(doc, e.Instructions),
+		Servings:     parseLeadingInt(selectorText(doc, e.Servings)),
+		PrepTime:     parseAnyDurationMinutes(selectorText(doc, e.PrepTime)),
+		CookTime:     parseAnyDurationMinutes(selectorText(doc, e.CookTime)),
+		TotalTime:    parseAnyDurationMinutes(selectorText(doc, e.TotalTime)),
+		Tags:         normalizeTerms(selectorTexts(doc, e.Tags)),
+		Category:     "other",
+	}
+	if recipe.Title == "" || len(recipe.Ingredients) == 0 || len(recipe.Instructions) == 0 {
+		return Recipe{}, false
+	}
+	if recipe.TotalTime == 0 {
+		recipe.TotalTime = recipe.PrepTime + recipe.CookTime
+	}
+	if norm, ok := normalizeCategoryStrict(selectorText(doc, e.Category)); ok {
+		recipe.Category = norm
+	}
+
+	if e.Image != "" {
+		doc.Find(e.Image).EachWithBreak(func(_ int, s *goquery.Selection) bool {
+			for _, attr := range []string{"content", "data-src", "src"} {
+				if value := strings.TrimSpace(s.AttrOr(attr, "")); value != "" {
+					recipe.Image = resolveRelativeURL(pageURL, value)
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return recipe, true
+}
+
+func selectorText(doc *goquery.Document, selector string) string {
+	if selector == "" {
+		return ""
+	}
+	return strings.Join(strings.Fields(doc.Find(selector).First().Text()), " ")
+}
+
+func selectorTexts(doc *goquery.Document, selector string) []string {
+	values := make([]string, 0)
+	if selector == "" {
+		return values
+	}
+	doc.Find(selector).Each(func(_ int, s *goquery.Selection) {
+		if text := strings.Join(strings.Fields(s.Text()), " "); text != "" {
+			values = append(values, text)
+		}
+	})
+	return values
+}
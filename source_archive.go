@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"time"
+)
+
+// archiveSourceHTML gzips the page a recipe was extracted from and stores it
+// in R2, returning the key, or "" when ARCHIVE_SOURCE_HTML is off or the
+// upload fails. Archiving never fails an import.
+func archiveSourceHTML(slug, content string) string {
+	if content == "" || !appConfig.Scraper.ArchiveSourceHTML {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := io.WriteString(zw, content); err != nil {
+		log.Printf("Archive source for %s: compress: %v", slug, err)
+		return ""
+	}
+	if err := zw.Close(); err != nil {
+		log.Printf("Archive source for %s: compress: %v", slug, err)
+		return ""
+	}
+
+	s3Client, err := NewCloudflareS3()
+	if err != nil {
+		log.Printf("Archive source for %s: initialize S3 client: %v", slug, err)
+		return ""
+	}
+	key := fmt.Sprintf("sources/%s-%d.html.gz", slug, time.Now().Unix())
+	if err := s3Client.UploadObject(key, "application/gzip", buf.Bytes()); err != nil {
+		log.Printf("Archive source for %s: %v", slug, err)
+		return ""
+	}
+	return key
+}
+
+// loadSourceHTML fetches and decompresses an archived page.
+func loadSourceHTML(key string) ([]byte, error) {
+	s3Client, err := NewCloudflareS3()
+	if err != nil {
+		return nil, fmt.Errorf("initialize S3 client: %w", err)
+	}
+	data, _, err := s3Client.GetObject(key)
+	if err != nil {
+		return nil, err
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("decompress source: %w", err)
+	}
+	defer zr.Close()
+	page, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("decompress source: %w", err)
+	}
+	return page, nil
+}
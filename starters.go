@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// New accounts start with a copy of the starter recipes, a curated set kept
+// in starter_recipes. The set is managed from the command line with the
+// server binary, against the database the server uses:
+//
+//	recipes-api starters list
+//	recipes-api starters load FILE       replace the set from a JSON file
+//	recipes-api starters from-user NAME  replace the set with NAME's recipes
+//	recipes-api starters clear
+//
+// FILE is either an account export from GET /export or a JSON array of
+// recipes; a recipe without a slug gets one from its title. from-user lets
+// a curator's account be kept as the source. Accounts already registered
+// keep what they were given.
+
+const startersCommand = "starters"
+
+var errNoStarterRecipes = errors.New("no recipes to use as starters")
+
+func runStartersCommand(repo *RecipeRepository, out io.Writer, args []string) error {
+	if len(args) == 0 {
+		return errors.New("usage: starters list | load FILE | from-user USERNAME | clear")
+	}
+
+	var starters []StarterRecipe
+	switch command := args[0]; {
+	case command == "list" && len(args) == 1:
+		list, err := repo.StarterRecipes()
+		if err != nil {
+			return err
+		}
+		for i, starter := range list {
+			fmt.Fprintf(out, "%d\t%s\t%s\n", i+1, starter.Slug, starter.Title)
+		}
+		return nil
+	case command == "load" && len(args) == 2:
+		data, err := os.ReadFile(args[1])
+		if err != nil {
+			return err
+		}
+		if starters, err = parseStarterRecipes(data); err != nil {
+			return fmt.Errorf("%s: %w", args[1], err)
+		}
+	case command == "from-user" && len(args) == 2:
+		export, err := repo.ExportAccount(args[1])
+		if err != nil {
+			return err
+		}
+		for _, recipe := range export.Recipes {
+			starters = append(starters, StarterRecipe{Slug: recipe.Slug, Recipe: recipe.Recipe})
+		}
+	case command == "clear" && len(args) == 1:
+	default:
+		return fmt.Errorf("unknown starters command %q", strings.Join(args, " "))
+	}
+
+	if len(starters) == 0 && args[0] != "clear" {
+		return errNoStarterRecipes
+	}
+	if err := repo.ReplaceStarterRecipes(starters); err != nil {
+		return err
+	}
+	fmt.Fprintf(out, "%d starter recipes\n", len(starters))
+	return nil
+}
+
+// parseStarterRecipes reads an account export or an array of recipes.
+func parseStarterRecipes(data []byte) ([]StarterRecipe, error) {
+	var export AccountExport
+	if err := json.Unmarshal(data, &export); err == nil && export.Recipes != nil {
+		starters := make([]StarterRecipe, 0, len(export.Recipes))
+		for _, recipe := range export.Recipes {
+			starters = append(starters, StarterRecipe{Slug: recipe.Slug, Recipe: recipe.Recipe})
+		}
+		return starters, nil
+	}
+	var starters []StarterRecipe
+	if err := json.Unmarshal(data, &starters); err != nil {
+		return nil, errors.New("not an account export or a JSON array of recipes")
+	}
+	return starters, nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRegistrationCopiesStarterRecipes(t *testing.T) {
+	repo := newTestRepo(t)
+	// The oldest recipes in the database aren't starters any more
+	createTestUser(t, repo, "first@example.com")
+	if err := repo.SaveRecipeForUser("first@example.com", "private", Recipe{Title: "Private Stew"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	file := filepath.Join(t.TempDir(), "starters.json")
+	if err := os.WriteFile(file, []byte(`[
+		{"title": "Pancakes", "category": "breakfast", "ingredients": ["2 eggs"], "instructions": ["Whisk.", "Fry."], "isFavorite": true},
+		{"slug": "bread", "title": "Plain Bread", "category": "baking", "instructions": ["Bake."]}
+	]`), 0o600); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	var out bytes.Buffer
+	if err := runStartersCommand(repo, &out, []string{"load", file}); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	out.Reset()
+	if err := runStartersCommand(repo, &out, []string{"list"}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if got, want := out.String(), "1\tpancakes\tPancakes\n2\tbread\tPlain Bread\n"; got != want {
+		t.Fatalf("list = %q, want %q", got, want)
+	}
+
+	createTestUser(t, repo, "new@example.com")
+	recipes, err := repo.ListRecipes("new@example.com", RecipeFilters{})
+	if err != nil {
+		t.Fatalf("list recipes: %v", err)
+	}
+	if len(recipes) != 2 || recipes[0].IsFavorite || recipes[1].IsFavorite {
+		t.Fatalf("new user's recipes = %+v, want the two starters, not favorited", recipes)
+	}
+	if _, err := repo.GetRecipe("new@example.com", "bread"); err != nil {
+		t.Fatalf("get bread: %v", err)
+	}
+	pancakes, err := repo.GetRecipe("new@example.com", "pancakes")
+	if err != nil || !slices.Equal(pancakes.Instructions, []string{"Whisk.", "Fry."}) {
+		t.Fatalf("pancakes = %+v, %v", pancakes, err)
+	}
+}
+
+func TestStartersFromUser(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "curator@example.com")
+	for _, title := range []string{"Soup", "Salad"} {
+		if err := repo.SaveRecipeForUser("curator@example.com", slugify(title), Recipe{Title: title}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+	}
+
+	var out bytes.Buffer
+	if err := runStartersCommand(repo, &out, []string{"from-user", "curator@example.com"}); err != nil {
+		t.Fatalf("from-user: %v", err)
+	}
+	starters, err := repo.StarterRecipes()
+	if err != nil || len(starters) != 2 {
+		t.Fatalf("starters = %+v, %v", starters, err)
+	}
+
+	if err := runStartersCommand(repo, &out, []string{"clear"}); err != nil {
+		t.Fatalf("clear: %v", err)
+	}
+	createTestUser(t, repo, "late@example.com")
+	if recipes, _ := repo.ListRecipes("late@example.com", RecipeFilters{}); len(recipes) != 0 {
+		t.Fatalf("after clear a new user got %d recipes", len(recipes))
+	}
+	if err := runStartersCommand(repo, &out, []string{"from-user", "late@example.com"}); err != errNoStarterRecipes {
+		t.Fatalf("from-user with no recipes = %v, want %v", err, errNoStarterRecipes)
+	}
+	if err := runStartersCommand(repo, &out, []string{"load"}); err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("load without a file = %v", err)
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"database/sql"
@@ -14,6 +15,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
@@ -24,11 +26,23 @@ type RecipeRepository struct {
 	db *gorm.DB
 }
 
+// WithContext returns the repository with its queries bound to ctx, so they
+// stop when ctx is cancelled or its deadline passes.
+func (r *RecipeRepository) WithContext(ctx context.Context) *RecipeRepository {
+	return &RecipeRepository{db: r.db.WithContext(ctx)}
+}
+
 type UserModel struct {
-	ID           uint      `gorm:"primaryKey"`
-	Username     string    `gorm:"column:username;uniqueIndex;size:255;not null"`
-	PasswordHash *string   `gorm:"column:password_hash"`
-	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime"`
+	ID                 uint       `gorm:"primaryKey"`
+	Username           string     `gorm:"column:username;uniqueIndex;size:255;not null"`
+	PasswordHash       *string    `gorm:"column:password_hash"`
+	PreferencesJSON    string     `gorm:"column:preferences"`
+	LastActiveAt       *time.Time `gorm:"column:last_active_at"`
+	InactivityWarnedAt *time.Time `gorm:"column:inactivity_warned_at"`
+	FrozenAt           *time.Time `gorm:"column:frozen_at"`
+	PublicHandle       *string    `gorm:"column:public_handle"`
+	FeedTokenUsedAt    *time.Time `gorm:"column:feed_token_used_at"`
+	CreatedAt          time.Time  `gorm:"column:created_at;autoCreateTime"`
 }
 
 func (UserModel) TableName() string {
@@ -36,24 +50,37 @@ func (UserModel) TableName() string {
 }
 
 type RecipeModel struct {
-	ID           uint      `gorm:"primaryKey"`
-	UserID       uint      `gorm:"column:user_id;not null;index;uniqueIndex:uid_slug"`
-	Slug         string    `gorm:"column:slug;not null;size:255;uniqueIndex:uid_slug"`
-	Title        string    `gorm:"column:title;not null"`
-	Category     string    `gorm:"column:category"`
-	CookTime     int       `gorm:"column:cook_time"`
-	Date         string    `gorm:"column:date"`
-	Image        string    `gorm:"column:image"`
-	Instructions string    `gorm:"column:instructions;not null"`
-	Ingredients  string    `gorm:"column:ingredients"`
-	ParsedJSON   string    `gorm:"column:parsed_ingredients"`
-	PrepTime     int       `gorm:"column:prep_time"`
-	Servings     int       `gorm:"column:servings"`
-	TotalTime    int       `gorm:"column:total_time"`
-	Link         string    `gorm:"column:link"`
-	OriginalURL  string    `gorm:"column:original_url"`
-	CreatedAt    time.Time `gorm:"column:created_at;autoCreateTime"`
-	UpdatedAt    time.Time `gorm:"column:updated_at;autoUpdateTime"`
+	ID             uint       `gorm:"primaryKey"`
+	UserID         uint       `gorm:"column:user_id;not null;index;uniqueIndex:uid_slug"`
+	Slug           string     `gorm:"column:slug;not null;size:255;uniqueIndex:uid_slug"`
+	Title          string     `gorm:"column:title;not null"`
+	Category       string     `gorm:"column:category"`
+	CookTime       int        `gorm:"column:cook_time"`
+	Date           string     `gorm:"column:date"`
+	Image          string     `gorm:"column:image"`
+	Instructions   string     `gorm:"column:instructions;not null"`
+	StructuredJSON string     `gorm:"column:structured_instructions"`
+	Ingredients    string     `gorm:"column:ingredients"`
+	ParsedJSON     string     `gorm:"column:parsed_ingredients"`
+	EquipmentJSON  string     `gorm:"column:equipment"`
+	TagsJSON       string     `gorm:"column:tags"`
+	NutritionJSON  string     `gorm:"column:nutrition"`
+	USDAJSON       string     `gorm:"column:usda_nutrition"`
+	DietsJSON      *string    `gorm:"column:diets"`
+	PrepTime       int        `gorm:"column:prep_time"`
+	Servings       int        `gorm:"column:servings"`
+	TotalTime      int        `gorm:"column:total_time"`
+	Link           string     `gorm:"column:link"`
+	OriginalURL    string     `gorm:"column:original_url"`
+	LastCookedAt   *time.Time `gorm:"column:last_cooked_at"`
+	IsPublic       bool       `gorm:"column:is_public"`
+	SourceKey      string     `gorm:"column:source_key"`
+	SchemaVersion  *int       `gorm:"column:schema_version"`
+	// Reprocessing of incomplete recipes; see recipe_reprocess.go
+	ReprocessAttempts int        `gorm:"column:reprocess_attempts"`
+	ReprocessAfter    *time.Time `gorm:"column:reprocess_after"`
+	CreatedAt         time.Time  `gorm:"column:created_at;autoCreateTime"`
+	UpdatedAt         time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 }
 
 func (RecipeModel) TableName() string {
@@ -65,9 +92,21 @@ type QueueModel struct {
 	UserID      uint       `gorm:"column:user_id;index;not null"`
 	User        UserModel  `gorm:"foreignKey:UserID"`
 	URL         string     `gorm:"column:url;not null"`
+	PageHTML    *string    `gorm:"column:page_html"`
 	Attempts    int        `gorm:"column:attempts"`
 	LastError   *string    `gorm:"column:last_error"`
+	ErrorCode   *string    `gorm:"column:error_code"`
+	AIModel     *string    `gorm:"column:ai_model"`
+	RecipeSlug  *string    `gorm:"column:recipe_slug"`
+	Priority    int        `gorm:"column:priority"`
+	NextAttempt *time.Time `gorm:"column:next_attempt_at"`
 	ProcessedAt *time.Time `gorm:"column:processed_at"`
+	FailedAt    *time.Time `gorm:"column:failed_at"`
+	CancelledAt *time.Time `gorm:"column:cancelled_at"`
+	ClaimedBy   *string    `gorm:"column:claimed_by"`
+	ClaimedAt   *time.Time `gorm:"column:claimed_at"`
+	Stage       string     `gorm:"column:stage;default:queued"`
+	StageAt     *time.Time `gorm:"column:stage_changed_at"`
 	CreatedAt   time.Time  `gorm:"column:created_at;autoCreateTime"`
 	UpdatedAt   time.Time  `gorm:"column:updated_at;autoUpdateTime"`
 }
@@ -82,8 +121,9 @@ type CategoryCount struct {
 }
 
 type UserProfile struct {
-	Username  string
-	CreatedAt time.Time
+	Username     string
+	CreatedAt    time.Time
+	PublicHandle string
 }
 
 type FavoriteModel struct {
@@ -101,7 +141,9 @@ func isNoSuchTableError(err error) bool {
 	if err == nil {
 		return false
 	}
-	return strings.Contains(strings.ToLower(err.Error()), "no such table")
+	// SQLite, then MySQL's error 1146
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "no such table") || strings.Contains(msg, "doesn't exist")
 }
 
 func floatPtr(v float64) *float64 {
@@ -132,6 +174,9 @@ var (
 		"other":     {},
 	}
 	ErrInvalidCategory = errors.New("invalid category")
+	// ErrRecipeChanged refuses a patch whose If-Match names an older
+	// version of the recipe
+	ErrRecipeChanged = errors.New("recipe was changed since it was read")
 )
 
 func normalizeCategoryOrOther(category string) string {
@@ -277,6 +322,26 @@ func (r *RecipeRepository) getUserID(username string) (uint, error) {
 	return user.ID, nil
 }
 
+// FindUsernameByEmail resolves an email address to an account. Usernames are
+// email addresses, compared case-insensitively here because mail clients
+// don't preserve case.
+func (r *RecipeRepository) FindUsernameByEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", errors.New("email is required")
+	}
+
+	var user UserModel
+	if err := r.db.Where("LOWER(username) = LOWER(?)", email).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", sql.ErrNoRows
+		}
+		return "", fmt.Errorf("lookup user by email: %w", err)
+	}
+
+	return user.Username, nil
+}
+
 func (r *RecipeRepository) CreateUser(username, password string) error {
 	if username == "" || password == "" {
 		return errors.New("username and password are required")
@@ -288,7 +353,7 @@ func (r *RecipeRepository) CreateUser(username, password string) error {
 	}
 	hashStr := string(hash)
 
-	// Create user and assign default recipes in a single transaction
+	// Create user and copy the starter recipes in a single transaction
 	tx := r.db.Begin()
 	if err := tx.Error; err != nil {
 		return err
@@ -312,42 +377,11 @@ func (r *RecipeRepository) CreateUser(username, password string) error {
 		return fmt.Errorf("create user: %w", err)
 	}
 
-	// Assign the first 4 recipes (by oldest created) to the new user (copy to user-owned)
-	var defaultRecipes []RecipeModel
-	if err = tx.Order("created_at ASC").Limit(4).Find(&defaultRecipes).Error; err != nil {
-		// Do not fail registration if recipes table missing; commit user creation
-		if isNoSuchTableError(err) {
-			log.Println("[Registration] recipes table missing; skipping default recipe assignment")
-			return nil
-		}
-		return fmt.Errorf("fetch default recipes: %w", err)
-	}
-
-	for _, rec := range defaultRecipes {
-		// Create a user-owned copy with same slug (if conflict, append suffix)
-		copy := rec
-		copy.ID = 0
-		copy.UserID = user.ID
-		// Ensure unique (user_id, slug)
-		trySlug := copy.Slug
-		for attempt := 0; attempt < 3; attempt++ {
-			copy.Slug = trySlug
-			if err := tx.Create(&copy).Error; err != nil {
-				if strings.Contains(strings.ToLower(err.Error()), "unique") {
-					trySlug = fmt.Sprintf("%s-%d", rec.Slug, attempt+2)
-					continue
-				}
-				return err
-			}
-			break
-		}
-	}
-
-	if len(defaultRecipes) > 0 {
-		log.Printf("[Registration] assigned %d default recipes to user %s", len(defaultRecipes), username)
-	} else {
-		log.Printf("[Registration] no recipes found to assign to user %s", username)
+	var copied int
+	if copied, err = copyStarterRecipes(tx, user.ID); err != nil {
+		return err
 	}
+	log.Printf("[Registration] assigned %d starter recipes to user %s", copied, username)
 
 	return nil
 }
@@ -389,7 +423,11 @@ func (r *RecipeRepository) GetUserProfile(username string) (UserProfile, error)
 		return UserProfile{}, fmt.Errorf("lookup user: %w", err)
 	}
 
-	return UserProfile{Username: user.Username, CreatedAt: user.CreatedAt}, nil
+	profile := UserProfile{Username: user.Username, CreatedAt: user.CreatedAt}
+	if user.PublicHandle != nil {
+		profile.PublicHandle = *user.PublicHandle
+	}
+	return profile, nil
 }
 
 func (r *RecipeRepository) findRecipeByOriginalURL(originalURL string) (*RecipeModel, error) {
@@ -478,6 +516,36 @@ func (r *RecipeRepository) isFavorite(userID, recipeID uint) (bool, error) {
 	return true, nil
 }
 
+// favoriteRecipeIDs returns which of recipeIDs the user has favorited, in
+// one query, for marking a page of recipes.
+func (r *RecipeRepository) favoriteRecipeIDs(userID uint, recipeIDs []uint) (map[uint]bool, error) {
+	favorites := make(map[uint]bool)
+	if len(recipeIDs) == 0 {
+		return favorites, nil
+	}
+	var ids []uint
+	if err := r.db.Model(&FavoriteModel{}).
+		Where("user_id = ? AND recipe_id IN ?", userID, recipeIDs).
+		Pluck("recipe_id", &ids).Error; err != nil {
+		if isNoSuchTableError(err) {
+			return favorites, nil
+		}
+		return nil, fmt.Errorf("list favorites: %w", err)
+	}
+	for _, id := range ids {
+		favorites[id] = true
+	}
+	return favorites, nil
+}
+
+func recipeModelIDs(models []RecipeModel) []uint {
+	ids := make([]uint, 0, len(models))
+	for _, model := range models {
+		ids = append(ids, model.ID)
+	}
+	return ids
+}
+
 func (r *RecipeRepository) SetFavorite(username, slug string, favorite bool) error {
 	userID, err := r.getUserID(username)
 	if err != nil {
@@ -590,69 +658,176 @@ func (r *RecipeRepository) ResetPasswordWithToken(token, newPassword string) err
 	return nil
 }
 
-// UpdateRecipeTitleAndInstructions updates only the title and/or instructions
-// for a recipe identified by slug, limited to recipes linked to the username.
-// If both fields are empty/nil, it is a no-op. Returns the updated recipe.
-func (r *RecipeRepository) UpdateRecipeTitleAndInstructions(username, slug string, title *string, instructions *[]string, category *string) (Recipe, error) {
-	if strings.TrimSpace(username) == "" || strings.TrimSpace(slug) == "" {
-		return Recipe{}, errors.New("username and slug are required")
-	}
+// RecipePatch holds the user-editable recipe fields; nil fields are left unchanged.
+// IfMatch, an If-Match header, makes the patch apply only while the stored
+// recipe still has one of the ETags it lists.
+type RecipePatch struct {
+	Title        *string
+	Instructions *[]string
+	Ingredients  *[]string
+	Category     *string
+	Tags         *[]string
+	IfMatch      string
+}
 
-	// Ensure user has access and get recipe model
-	var model RecipeModel
-	if err := r.db.Table("recipes").
-		Select("recipes.*").
-		Joins("JOIN users u ON u.id = recipes.user_id").
-		Where("u.username = ? AND recipes.slug = ?", username, slug).
-		First(&model).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return Recipe{}, sql.ErrNoRows
-		}
-		return Recipe{}, fmt.Errorf("get recipe for update: %w", err)
-	}
+// recipeETag versions a recipe as GetRecipe returns it, favorite flag
+// included. Every view of the recipe (scaled, converted) derives from it.
+func recipeETag(recipe Recipe) string {
+	data, _ := json.Marshal(recipe)
+	return contentETag(data)
+}
 
+// recipePatchUpdates converts a patch into column updates, always touching updated_at.
+func recipePatchUpdates(patch RecipePatch) (map[string]any, error) {
 	updates := map[string]any{
 		"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
 	}
-	if title != nil {
-		updates["title"] = strings.TrimSpace(*title)
+	if patch.Title != nil {
+		updates["title"] = strings.TrimSpace(*patch.Title)
 	}
-	if instructions != nil {
+	if patch.Instructions != nil {
 		// Marshal instructions array to JSON string as stored in DB
-		data, err := json.Marshal(*instructions)
+		data, err := json.Marshal(*patch.Instructions)
 		if err != nil {
-			return Recipe{}, fmt.Errorf("marshal instructions: %w", err)
+			return nil, fmt.Errorf("marshal instructions: %w", err)
 		}
 		updates["instructions"] = string(data)
+		// A flat edit supersedes the structured steps
+		updates["structured_instructions"] = ""
 	}
-	if category != nil {
-		if norm, ok := normalizeCategoryStrict(*category); ok {
-			updates["category"] = norm
-		} else {
-			return Recipe{}, ErrInvalidCategory
+	if patch.Ingredients != nil {
+		data, err := json.Marshal(*patch.Ingredients)
+		if err != nil {
+			return nil, fmt.Errorf("marshal ingredients: %w", err)
+		}
+		updates["ingredients"] = string(data)
+		// The parsed amounts no longer match, and the diets may not either
+		updates["parsed_ingredients"] = "[]"
+		diets, err := recipeDietsJSON(Recipe{Ingredients: *patch.Ingredients})
+		if err != nil {
+			return nil, err
 		}
+		updates["diets"] = diets
 	}
-
-	if len(updates) > 1 { // more than just updated_at
-		if err := r.db.Model(&RecipeModel{}).Where("id = ?", model.ID).Updates(updates).Error; err != nil {
-			return Recipe{}, fmt.Errorf("update recipe: %w", err)
+	if patch.Category != nil {
+		norm, ok := normalizeCategoryStrict(*patch.Category)
+		if !ok {
+			return nil, ErrInvalidCategory
 		}
+		updates["category"] = norm
 	}
+	if patch.Tags != nil {
+		data, err := json.Marshal(normalizeTerms(*patch.Tags))
+		if err != nil {
+			return nil, fmt.Errorf("marshal tags: %w", err)
+		}
+		updates["tags"] = string(data)
+	}
+	return updates, nil
+}
 
-	// Re-fetch and return updated recipe
-	var refreshed RecipeModel
-	if err := r.db.First(&refreshed, model.ID).Error; err != nil {
-		return Recipe{}, fmt.Errorf("reload recipe: %w", err)
+// UpdateRecipeTitleAndInstructions applies a patch to a recipe identified by
+// slug, limited to recipes linked to the username. If every field is nil,
+// it is a no-op. Returns the updated recipe.
+func (r *RecipeRepository) UpdateRecipeTitleAndInstructions(username, slug string, patch RecipePatch) (Recipe, error) {
+	if strings.TrimSpace(username) == "" || strings.TrimSpace(slug) == "" {
+		return Recipe{}, errors.New("username and slug are required")
 	}
-	recipe, err := refreshed.toRecipe()
+	return r.patchRecipe(username, "u.username = ? AND recipes.slug = ?", []any{username, slug}, patch)
+}
+
+// UpdateRecipeTitleAndInstructionsByID applies a patch by recipe ID for the given user
+func (r *RecipeRepository) UpdateRecipeTitleAndInstructionsByID(username string, recipeID uint, patch RecipePatch) (Recipe, error) {
+	if strings.TrimSpace(username) == "" || recipeID == 0 {
+		return Recipe{}, errors.New("username and id are required")
+	}
+	return r.patchRecipe(username, "u.username = ? AND recipes.id = ?", []any{username, recipeID}, patch)
+}
+
+// patchRecipe applies patch to the user's recipe matching where. The row is
+// read, checked against patch.IfMatch and written in one transaction, so a
+// concurrent edit can't slip in between; MySQL needs the row locked for
+// that, SQLite's transactions already hold the write lock.
+func (r *RecipeRepository) patchRecipe(username, where string, args []any, patch RecipePatch) (Recipe, error) {
+	var recipe Recipe
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		query := tx.Table("recipes").
+			Select("recipes.*").
+			Joins("JOIN users u ON u.id = recipes.user_id").
+			Where(where, args...)
+		if isMySQL(tx) {
+			query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+		}
+		var model RecipeModel
+		if err := query.First(&model).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return sql.ErrNoRows
+			}
+			return fmt.Errorf("get recipe for update: %w", err)
+		}
+
+		txRepo := &RecipeRepository{db: tx}
+		if patch.IfMatch != "" {
+			current, err := txRepo.GetRecipeByID(username, model.ID)
+			if err != nil {
+				return err
+			}
+			if !etagMatches(patch.IfMatch, recipeETag(current)) {
+				return ErrRecipeChanged
+			}
+		}
+
+		updates, err := recipePatchUpdates(patch)
+		if err != nil {
+			return err
+		}
+		if len(updates) > 1 { // more than just updated_at
+			if err := tx.Model(&RecipeModel{}).Where("id = ?", model.ID).Updates(updates).Error; err != nil {
+				return fmt.Errorf("update recipe: %w", err)
+			}
+		}
+
+		// Re-fetch and return updated recipe
+		var refreshed RecipeModel
+		if err := tx.First(&refreshed, model.ID).Error; err != nil {
+			return fmt.Errorf("reload recipe: %w", err)
+		}
+		if err := indexRecipe(tx, refreshed); err != nil {
+			return err
+		}
+		if recipe, err = refreshed.toRecipe(); err != nil {
+			return err
+		}
+		recipe.IsFavorite, err = txRepo.isFavorite(model.UserID, model.ID)
+		return err
+	})
 	if err != nil {
 		return Recipe{}, err
 	}
 	return recipe, nil
 }
 
-// UpdateRecipeTitleAndInstructionsByID updates title and/or instructions by recipe ID for the given user
-func (r *RecipeRepository) UpdateRecipeTitleAndInstructionsByID(username string, recipeID uint, title *string, instructions *[]string, category *string) (Recipe, error) {
+// UpdateRecipeImage replaces the image URL of the user's recipe with slug.
+func (r *RecipeRepository) UpdateRecipeImage(username, slug, image string) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	result := r.db.Model(&RecipeModel{}).
+		Where("user_id = ? AND slug = ?", userID, slug).
+		UpdateColumn("image", image)
+	if result.Error != nil {
+		return fmt.Errorf("update recipe image: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// UpdateRecipeImageByID replaces the image URL of a recipe owned by the given user.
+func (r *RecipeRepository) UpdateRecipeImageByID(username string, recipeID uint, image string) (Recipe, error) {
 	if strings.TrimSpace(username) == "" || recipeID == 0 {
 		return Recipe{}, errors.New("username and id are required")
 	}
@@ -662,54 +837,205 @@ func (r *RecipeRepository) UpdateRecipeTitleAndInstructionsByID(username string,
 		return Recipe{}, err
 	}
 
-	// Verify ownership/access
-	var model RecipeModel
-	if err := r.db.Table("recipes").
-		Select("recipes.*").
-		Joins("JOIN users u ON u.id = recipes.user_id").
-		Where("u.id = ? AND recipes.id = ?", userID, recipeID).
-		First(&model).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return Recipe{}, sql.ErrNoRows
-		}
-		return Recipe{}, fmt.Errorf("get recipe for update: %w", err)
+	result := r.db.Model(&RecipeModel{}).
+		Where("id = ? AND user_id = ?", recipeID, userID).
+		Updates(map[string]any{
+			"image":      image,
+			"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		})
+	if result.Error != nil {
+		return Recipe{}, fmt.Errorf("update recipe image: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return Recipe{}, sql.ErrNoRows
 	}
 
-	updates := map[string]any{
-		"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+	return r.GetRecipeByID(username, recipeID)
+}
+
+// MarkRecipeCookedByID records that the user cooked the recipe just now.
+func (r *RecipeRepository) MarkRecipeCookedByID(username string, recipeID uint) (Recipe, error) {
+	if strings.TrimSpace(username) == "" || recipeID == 0 {
+		return Recipe{}, errors.New("username and id are required")
 	}
-	if title != nil {
-		updates["title"] = strings.TrimSpace(*title)
+
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return Recipe{}, err
 	}
-	if instructions != nil {
-		data, err := json.Marshal(*instructions)
-		if err != nil {
-			return Recipe{}, fmt.Errorf("marshal instructions: %w", err)
-		}
-		updates["instructions"] = string(data)
+
+	result := r.db.Model(&RecipeModel{}).
+		Where("id = ? AND user_id = ?", recipeID, userID).
+		UpdateColumn("last_cooked_at", time.Now().UTC())
+	if result.Error != nil {
+		return Recipe{}, fmt.Errorf("mark recipe cooked: %w", result.Error)
 	}
-	if category != nil {
-		if norm, ok := normalizeCategoryStrict(*category); ok {
-			updates["category"] = norm
-		} else {
-			return Recipe{}, ErrInvalidCategory
-		}
+	if result.RowsAffected == 0 {
+		return Recipe{}, sql.ErrNoRows
 	}
-	if len(updates) > 1 {
-		if err := r.db.Model(&RecipeModel{}).Where("id = ?", model.ID).Updates(updates).Error; err != nil {
-			return Recipe{}, fmt.Errorf("update recipe: %w", err)
-		}
+
+	return r.GetRecipeByID(username, recipeID)
+}
+
+// SetRecipeUSDANutrition stores nutrition computed from USDA data for one
+// of the user's recipes.
+func (r *RecipeRepository) SetRecipeUSDANutrition(username string, recipeID uint, nutrition USDANutrition) (Recipe, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return Recipe{}, err
+	}
+	data, err := json.Marshal(nutrition)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("marshal usda nutrition: %w", err)
 	}
 
-	var refreshed RecipeModel
-	if err := r.db.First(&refreshed, model.ID).Error; err != nil {
-		return Recipe{}, fmt.Errorf("reload recipe: %w", err)
+	result := r.db.Model(&RecipeModel{}).
+		Where("id = ? AND user_id = ?", recipeID, userID).
+		UpdateColumn("usda_nutrition", string(data))
+	if result.Error != nil {
+		return Recipe{}, fmt.Errorf("set usda nutrition: %w", result.Error)
 	}
-	recipe, err := refreshed.toRecipe()
+	if result.RowsAffected == 0 {
+		return Recipe{}, sql.ErrNoRows
+	}
+
+	return r.GetRecipeByID(username, recipeID)
+}
+
+// SetRecipeParsedIngredients replaces the parsed ingredients of the user's
+// recipe and reindexes its ingredient names.
+func (r *RecipeRepository) SetRecipeParsedIngredients(username string, recipeID uint, parsed []IngredientDetail) (Recipe, error) {
+	userID, err := r.getUserID(username)
 	if err != nil {
 		return Recipe{}, err
 	}
-	return recipe, nil
+	data, err := json.Marshal(parsed)
+	if err != nil {
+		return Recipe{}, fmt.Errorf("marshal parsed ingredients: %w", err)
+	}
+
+	err = r.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&RecipeModel{}).
+			Where("id = ? AND user_id = ?", recipeID, userID).
+			UpdateColumn("parsed_ingredients", string(data))
+		if result.Error != nil {
+			return fmt.Errorf("set parsed ingredients: %w", result.Error)
+		}
+		if result.RowsAffected == 0 {
+			return sql.ErrNoRows
+		}
+
+		var refreshed RecipeModel
+		if err := tx.First(&refreshed, recipeID).Error; err != nil {
+			return fmt.Errorf("reload recipe: %w", err)
+		}
+		return indexRecipeIngredients(tx, refreshed)
+	})
+	if err != nil {
+		return Recipe{}, err
+	}
+	return r.GetRecipeByID(username, recipeID)
+}
+
+// BackfillParsedIngredients parses the raw ingredient lines of every recipe
+// without parsed ingredients, by rule, and returns how many it updated.
+// Recipes where no line has an amount are left for the AI.
+func (r *RecipeRepository) BackfillParsedIngredients() (int, error) {
+	updated := 0
+	var models []RecipeModel
+	err := r.db.Select("id", "user_id", "ingredients", "parsed_ingredients").
+		Where("(parsed_ingredients IS NULL OR parsed_ingredients IN ('', '[]', 'null')) AND ingredients IS NOT NULL AND ingredients NOT IN ('', '[]', 'null')").
+		FindInBatches(&models, 200, func(tx *gorm.DB, batch int) error {
+			for _, model := range models {
+				var lines []string
+				if err := json.Unmarshal([]byte(model.Ingredients), &lines); err != nil {
+					continue
+				}
+				parsed := parseIngredientLines(lines)
+				if parsedAmountCount(parsed) == 0 {
+					continue
+				}
+				data, err := json.Marshal(parsed)
+				if err != nil {
+					return fmt.Errorf("marshal parsed ingredients: %w", err)
+				}
+				if err := r.db.Model(&RecipeModel{}).Where("id = ?", model.ID).
+					UpdateColumn("parsed_ingredients", string(data)).Error; err != nil {
+					return fmt.Errorf("set parsed ingredients for recipe %d: %w", model.ID, err)
+				}
+				model.ParsedJSON = string(data)
+				if err := indexRecipeIngredients(r.db, model); err != nil {
+					log.Printf("Backfill: %v", err)
+				}
+				updated++
+			}
+			return nil
+		}).Error
+	return updated, err
+}
+
+// BackfillRecipeDiets classifies the recipes saved before diets were.
+func (r *RecipeRepository) BackfillRecipeDiets() error {
+	var models []RecipeModel
+	return r.db.Select("id", "ingredients", "parsed_ingredients").
+		Where("diets IS NULL").
+		FindInBatches(&models, 200, func(tx *gorm.DB, batch int) error {
+			for _, model := range models {
+				recipe, err := model.toRecipe()
+				if err != nil {
+					return err
+				}
+				diets, err := recipeDietsJSON(recipe)
+				if err != nil {
+					return err
+				}
+				if err := r.db.Model(&RecipeModel{}).Where("id = ?", model.ID).
+					UpdateColumn("diets", diets).Error; err != nil {
+					return fmt.Errorf("set diets for recipe %d: %w", model.ID, err)
+				}
+			}
+			return nil
+		}).Error
+}
+
+// RandomRecipes returns up to limit of the user's recipes matching filters,
+// in random order.
+func (r *RecipeRepository) RandomRecipes(username string, filters RecipeFilters, limit int) ([]Recipe, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	query := r.db.Table("recipes").
+		Select("recipes.*").
+		Where("recipes.user_id = ?", userID)
+	query = applyRecipeFilters(query, userID, filters)
+
+	var models []RecipeModel
+	if err := query.Order(randomOrder(r.db)).Limit(limit).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("random recipes: %w", err)
+	}
+
+	favorites, err := r.favoriteRecipeIDs(userID, recipeModelIDs(models))
+	if err != nil {
+		return nil, err
+	}
+
+	recipes := make([]Recipe, 0, len(models))
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err != nil {
+			return nil, err
+		}
+		recipe.IsFavorite = favorites[model.ID]
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, nil
 }
 
 func (r *RecipeRepository) updateUserPassword(userID uint, newPassword string) error {
@@ -730,7 +1056,10 @@ func (r *RecipeRepository) updateUserPassword(userID uint, newPassword string) e
 	return nil
 }
 
-func (r *RecipeRepository) EnqueueRecipe(username, recipeURL string) error {
+// EnqueueRecipe queues a URL at the given priority (queuePriorityInteractive,
+// queuePriorityBulk or queuePriorityBackground). A pending item for the same
+// URL is kept, raised to priority if it was lower.
+func (r *RecipeRepository) EnqueueRecipe(username, recipeURL string, priority int) error {
 	if strings.TrimSpace(recipeURL) == "" {
 		return errors.New("url is required")
 	}
@@ -743,16 +1072,66 @@ func (r *RecipeRepository) EnqueueRecipe(username, recipeURL string) error {
 	var existing QueueModel
 	if err := r.db.Where("user_id = ? AND url = ? AND processed_at IS NULL", userID, recipeURL).
 		First(&existing).Error; err == nil {
+		if existing.Priority < priority {
+			if err := r.db.Model(&existing).Update("priority", priority).Error; err != nil {
+				return fmt.Errorf("raise queue priority: %w", err)
+			}
+		}
 		return nil
 	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
 		return fmt.Errorf("check pending queue item: %w", err)
 	}
 
 	item := QueueModel{
-		UserID: userID,
-		URL:    recipeURL,
+		UserID:   userID,
+		URL:      recipeURL,
+		Priority: priority,
+	}
+
+	if err := r.db.Create(&item).Error; err != nil {
+		return fmt.Errorf("enqueue recipe: %w", err)
+	}
+
+	return nil
+}
+
+// EnqueueRecipeHTML queues a URL together with page HTML captured by the
+// client. A pending item for the same URL gets the new HTML, and priority if
+// higher, instead of a duplicate row.
+func (r *RecipeRepository) EnqueueRecipeHTML(username, recipeURL, pageHTML string, priority int) error {
+	if strings.TrimSpace(recipeURL) == "" || strings.TrimSpace(pageHTML) == "" {
+		return errors.New("url and html are required")
+	}
+
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	result := r.db.Model(&QueueModel{}).
+		Where("user_id = ? AND url = ? AND processed_at IS NULL", userID, recipeURL).
+		Updates(map[string]any{
+			"page_html":  pageHTML,
+			"updated_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("update pending queue item: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		if err := r.db.Model(&QueueModel{}).
+			Where("user_id = ? AND url = ? AND processed_at IS NULL AND priority < ?", userID, recipeURL, priority).
+			Update("priority", priority).Error; err != nil {
+			return fmt.Errorf("raise queue priority: %w", err)
+		}
+		return nil
 	}
 
+	item := QueueModel{
+		UserID:   userID,
+		URL:      recipeURL,
+		PageHTML: &pageHTML,
+		Priority: priority,
+	}
 	if err := r.db.Create(&item).Error; err != nil {
 		return fmt.Errorf("enqueue recipe: %w", err)
 	}
@@ -760,10 +1139,42 @@ func (r *RecipeRepository) EnqueueRecipe(username, recipeURL string) error {
 	return nil
 }
 
+// SetPendingQueueAIModel sets the AI model override of a user's pending
+// queue item for recipeURL.
+func (r *RecipeRepository) SetPendingQueueAIModel(username, recipeURL, model string) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+	var value *string
+	if model != "" {
+		value = &model
+	}
+	if err := r.db.Model(&QueueModel{}).
+		Where("user_id = ? AND url = ? AND processed_at IS NULL", userID, recipeURL).
+		Update("ai_model", value).Error; err != nil {
+		return fmt.Errorf("set queue ai model: %w", err)
+	}
+	return nil
+}
+
+// FetchPendingQueue returns up to limit unprocessed items whose retry
+// backoff has passed and that no worker has claimed, highest priority first. Within a priority users take
+// turns, oldest item first, so one user's bulk import doesn't hold up
+// everyone else's.
 func (r *RecipeRepository) FetchPendingQueue(limit int) ([]QueueModel, error) {
-	query := r.db.Preload("User").
+	now := time.Now()
+	ready := r.db.Model(&QueueModel{}).
+		Select("queue.*, ROW_NUMBER() OVER (PARTITION BY user_id, priority ORDER BY created_at ASC, id ASC) AS user_turn").
 		Where("processed_at IS NULL").
-		Order("created_at ASC")
+		Where("(next_attempt_at IS NULL OR next_attempt_at <= ?)", now).
+		Where("(claimed_at IS NULL OR claimed_at <= ?)", now.UTC().Add(-queueClaimLease))
+	query := r.db.Preload("User").
+		Table("(?) AS queue", ready).
+		Order("priority DESC").
+		Order("user_turn ASC").
+		Order("created_at ASC").
+		Order("id ASC")
 	if limit > 0 {
 		query = query.Limit(limit)
 	}
@@ -776,6 +1187,96 @@ func (r *RecipeRepository) FetchPendingQueue(limit int) ([]QueueModel, error) {
 	return items, nil
 }
 
+// ClaimPendingQueue claims up to limit ready items for worker, in the order
+// FetchPendingQueue returns them. Until the claim's lease runs out no other
+// worker takes the item, so replicas sharing the database import each item
+// once; the claim is conditional, so of two workers racing for an item
+// only one gets it.
+func (r *RecipeRepository) ClaimPendingQueue(worker string, limit int) ([]QueueModel, error) {
+	candidates, err := r.FetchPendingQueue(limit)
+	if err != nil || len(candidates) == 0 {
+		return nil, err
+	}
+	ids := make([]uint, len(candidates))
+	for i, item := range candidates {
+		ids[i] = item.ID
+	}
+
+	now := time.Now().UTC()
+	if err := r.db.Model(&QueueModel{}).
+		Where("id IN ?", ids).
+		Where("processed_at IS NULL").
+		Where("(claimed_at IS NULL OR claimed_at <= ?)", now.Add(-queueClaimLease)).
+		UpdateColumns(map[string]any{"claimed_by": worker, "claimed_at": now}).Error; err != nil {
+		return nil, fmt.Errorf("claim queue items: %w", err)
+	}
+
+	// Another worker may have claimed some since they were fetched
+	var claimed []uint
+	if err := r.db.Model(&QueueModel{}).
+		Where("id IN ? AND claimed_by = ?", ids, worker).
+		Pluck("id", &claimed).Error; err != nil {
+		return nil, fmt.Errorf("read queue claims: %w", err)
+	}
+	ours := make(map[uint]bool, len(claimed))
+	for _, id := range claimed {
+		ours[id] = true
+	}
+	items := candidates[:0]
+	for _, item := range candidates {
+		if ours[item.ID] {
+			claimedAt := now
+			item.ClaimedBy, item.ClaimedAt = &worker, &claimedAt
+			items = append(items, item)
+		}
+	}
+	return items, nil
+}
+
+// ReleaseQueueClaim gives up worker's claim on item id, once the worker is
+// done with it or is leaving it pending for another.
+func (r *RecipeRepository) ReleaseQueueClaim(id uint, worker string) error {
+	if err := r.db.Model(&QueueModel{}).
+		Where("id = ? AND claimed_by = ?", id, worker).
+		UpdateColumns(map[string]any{"claimed_by": nil, "claimed_at": nil}).Error; err != nil {
+		return fmt.Errorf("release queue claim: %w", err)
+	}
+	return nil
+}
+
+// GetQueueItem returns queue item id with its user.
+func (r *RecipeRepository) GetQueueItem(id uint) (QueueModel, error) {
+	var item QueueModel
+	if err := r.db.Preload("User").First(&item, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return QueueModel{}, sql.ErrNoRows
+		}
+		return QueueModel{}, fmt.Errorf("get queue item: %w", err)
+	}
+	return item, nil
+}
+
+// QueueDepth counts the unprocessed queue items: those ready to run and
+// those waiting out a retry backoff.
+func (r *RecipeRepository) QueueDepth() (ready, backoff int64, err error) {
+	var counts struct {
+		Ready   int64
+		Backoff int64
+	}
+	now := time.Now()
+	if err := r.db.Model(&QueueModel{}).
+		Select("COALESCE(SUM(CASE WHEN next_attempt_at IS NULL OR next_attempt_at <= ? THEN 1 ELSE 0 END), 0) AS ready, "+
+			"COALESCE(SUM(CASE WHEN next_attempt_at > ? THEN 1 ELSE 0 END), 0) AS backoff", now, now).
+		Where("processed_at IS NULL").
+		Scan(&counts).Error; err != nil {
+		return 0, 0, fmt.Errorf("count queue: %w", err)
+	}
+	return counts.Ready, counts.Backoff, nil
+}
+
+// MarkQueueItemResult records an attempt at a queue item: success finishes
+// it, and a failure retrying can't fix, or on the last attempt, moves it to
+// the failed items.
 func (r *RecipeRepository) MarkQueueItemResult(id uint, processErr error) error {
 	updates := map[string]any{
 		"attempts":   gorm.Expr("attempts + 1"),
@@ -784,28 +1285,48 @@ func (r *RecipeRepository) MarkQueueItemResult(id uint, processErr error) error
 
 	if processErr == nil {
 		updates["processed_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+		setQueueStage(updates, queueStageDone)
 		updates["last_error"] = nil
+		updates["error_code"] = nil
+		// Captured pages can be large; they are not needed once processed
+		updates["page_html"] = nil
 	} else {
-		msg := processErr.Error()
-		if len(msg) > 1024 {
-			msg = msg[:1024]
+		setQueueFailure(updates, processErr)
+		if queueErrorIsPermanent(processErr) {
+			updates["processed_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+			updates["failed_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+			setQueueStage(updates, queueStageFailed)
+		} else {
+			// Back in the queue, unless that was the last attempt
+			setQueueStage(updates, queueStageQueued)
 		}
-		updates["last_error"] = msg
 	}
 
-	if err := r.db.Model(&QueueModel{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+	if err := r.db.Model(&QueueModel{}).Where("id = ? AND cancelled_at IS NULL", id).Updates(updates).Error; err != nil {
 		return fmt.Errorf("update queue item: %w", err)
 	}
 
 	if processErr != nil {
 		var item QueueModel
-		if err := r.db.First(&item, id).Error; err == nil {
-			if item.Attempts >= 5 && item.ProcessedAt == nil {
+		if err := r.db.First(&item, id).Error; err == nil && item.ProcessedAt == nil && item.CancelledAt == nil {
+			if item.Attempts >= queueSettings.MaxAttempts {
+				finalize := map[string]any{
+					"processed_at": gorm.Expr("CURRENT_TIMESTAMP"),
+					"failed_at":    gorm.Expr("CURRENT_TIMESTAMP"),
+				}
+				setQueueStage(finalize, queueStageFailed)
 				if err := r.db.Model(&QueueModel{}).
 					Where("id = ?", id).
-					Update("processed_at", gorm.Expr("CURRENT_TIMESTAMP")).Error; err != nil {
+					Updates(finalize).Error; err != nil {
 					return fmt.Errorf("finalize queue item: %w", err)
 				}
+			} else {
+				next := time.Now().Add(queueRetryPolicy().delay(item.Attempts))
+				if err := r.db.Model(&QueueModel{}).
+					Where("id = ?", id).
+					Update("next_attempt_at", next).Error; err != nil {
+					return fmt.Errorf("schedule queue retry: %w", err)
+				}
 			}
 		}
 	}
@@ -813,90 +1334,473 @@ func (r *RecipeRepository) MarkQueueItemResult(id uint, processErr error) error
 	return nil
 }
 
-func (r *RecipeRepository) SaveRecipeForUser(username, slug string, recipe Recipe) (err error) {
+// setQueueStage adds a move to stage to a queue update.
+func setQueueStage(updates map[string]any, stage string) {
+	updates["stage"] = stage
+	updates["stage_changed_at"] = gorm.Expr("CURRENT_TIMESTAMP")
+}
+
+// SetQueueItemStage records how far a worker has got with an unfinished
+// queue item.
+func (r *RecipeRepository) SetQueueItemStage(id uint, stage string) error {
+	updates := map[string]any{"updated_at": gorm.Expr("CURRENT_TIMESTAMP")}
+	setQueueStage(updates, stage)
+	if err := r.db.Model(&QueueModel{}).Where("id = ? AND processed_at IS NULL", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("update queue item stage: %w", err)
+	}
+	return nil
+}
+
+// setQueueFailure adds processErr and its classification to a queue update.
+func setQueueFailure(updates map[string]any, processErr error) {
+	msg := processErr.Error()
+	if len(msg) > 1024 {
+		msg = msg[:1024]
+	}
+	updates["last_error"] = msg
+	if code := queueErrorCode(processErr); code != "" {
+		updates["error_code"] = code
+	} else {
+		updates["error_code"] = nil
+	}
+}
+
+// MarkQueueItemFailed moves a queue item to the failed items after a
+// placeholder recipe was saved for it under slug.
+func (r *RecipeRepository) MarkQueueItemFailed(id uint, slug string, processErr error) error {
+	updates := map[string]any{
+		"attempts":     gorm.Expr("attempts + 1"),
+		"recipe_slug":  slug,
+		"processed_at": gorm.Expr("CURRENT_TIMESTAMP"),
+		"failed_at":    gorm.Expr("CURRENT_TIMESTAMP"),
+		"updated_at":   gorm.Expr("CURRENT_TIMESTAMP"),
+	}
+	setQueueStage(updates, queueStageFailed)
+	setQueueFailure(updates, processErr)
+	if err := r.db.Model(&QueueModel{}).Where("id = ? AND cancelled_at IS NULL", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("update queue item: %w", err)
+	}
+	return nil
+}
+
+// MarkQueueItemSaved finalizes a queue item whose URL was saved as the
+// user's recipe slug, which may be a placeholder.
+func (r *RecipeRepository) MarkQueueItemSaved(id uint, slug string) error {
+	if err := r.db.Model(&QueueModel{}).Where("id = ? AND cancelled_at IS NULL", id).Update("recipe_slug", slug).Error; err != nil {
+		return fmt.Errorf("update queue item: %w", err)
+	}
+	return r.MarkQueueItemResult(id, nil)
+}
+
+// Queue item stages. An item is queued until a worker takes it, moves
+// through fetching, extracting and saving, and ends done, failed or
+// cancelled. A failed attempt that will be retried goes back to queued.
+const (
+	queueStageQueued     = "queued"
+	queueStageFetching   = "fetching"
+	queueStageExtracting = "extracting"
+	queueStageSaving     = "saving"
+	queueStageDone       = "done"
+	queueStageFailed     = "failed"
+	queueStageCancelled  = "cancelled"
+)
+
+// QueueItem is the API view of a queued URL.
+type QueueItem struct {
+	ID             uint       `json:"id"`
+	URL            string     `json:"url"`
+	Status         string     `json:"status"`
+	Stage          string     `json:"stage"`
+	StageChangedAt *time.Time `json:"stageChangedAt,omitempty"`
+	Attempts       int        `json:"attempts"`
+	LastError      *string    `json:"lastError,omitempty"`
+	ErrorCode      string     `json:"errorCode,omitempty"`
+	RecipeSlug     string     `json:"recipeSlug,omitempty"`
+	Priority       int        `json:"priority"`
+	NextAttemptAt  *time.Time `json:"nextAttemptAt,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+	ProcessedAt    *time.Time `json:"processedAt,omitempty"`
+	FailedAt       *time.Time `json:"failedAt,omitempty"`
+	CancelledAt    *time.Time `json:"cancelledAt,omitempty"`
+}
+
+// ListQueueItems returns the user's most recent queue entries. Status is
+// "pending" until processed_at is set, then "processed", "failed" for a
+// dead-lettered item or "cancelled"; Stage says how far a pending item's
+// import has got. ErrorCode classifies some failures, see queueErrorCode.
+func (r *RecipeRepository) ListQueueItems(username string, limit int) ([]QueueItem, error) {
 	userID, err := r.getUserID(username)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	query := r.db.Where("user_id = ?", userID).Order("created_at DESC").Order("id DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
 	}
 
+	var models []QueueModel
+	if err := query.Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("list queue: %w", err)
+	}
+
+	items := make([]QueueItem, 0, len(models))
+	for _, model := range models {
+		items = append(items, model.toQueueItem())
+	}
+	return items, nil
+}
+
+// QueueEventCursor returns the user's newest queue item id and the ids still
+// pending, where a stream of queue events starts watching.
+func (r *RecipeRepository) QueueEventCursor(username string) (uint, []uint, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return 0, nil, err
+	}
+	var lastID uint
+	if err := r.db.Model(&QueueModel{}).Where("user_id = ?", userID).
+		Select("COALESCE(MAX(id), 0)").Scan(&lastID).Error; err != nil {
+		return 0, nil, fmt.Errorf("find last queue item: %w", err)
+	}
+	var pending []uint
+	if err := r.db.Model(&QueueModel{}).Where("user_id = ? AND processed_at IS NULL", userID).
+		Pluck("id", &pending).Error; err != nil {
+		return 0, nil, fmt.Errorf("find pending queue items: %w", err)
+	}
+	return lastID, pending, nil
+}
+
+// QueueItemsAfter returns the user's queue items newer than afterID together
+// with those in ids, oldest first.
+func (r *RecipeRepository) QueueItemsAfter(username string, afterID uint, ids []uint) ([]QueueItem, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+	query := r.db.Where("user_id = ?", userID)
+	if len(ids) > 0 {
+		query = query.Where("(id > ? OR id IN ?)", afterID, ids)
+	} else {
+		query = query.Where("id > ?", afterID)
+	}
+	var models []QueueModel
+	if err := query.Order("id ASC").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("list queue: %w", err)
+	}
+	items := make([]QueueItem, 0, len(models))
+	for _, model := range models {
+		items = append(items, model.toQueueItem())
+	}
+	return items, nil
+}
+
+func (m QueueModel) toQueueItem() QueueItem {
+	status := "pending"
+	switch {
+	case m.CancelledAt != nil:
+		status = "cancelled"
+	case m.FailedAt != nil:
+		status = "failed"
+	case m.ProcessedAt != nil:
+		status = "processed"
+	}
+	var nextAttempt *time.Time
+	if m.ProcessedAt == nil {
+		nextAttempt = m.NextAttempt
+	}
+	var errorCode, recipeSlug string
+	if m.ErrorCode != nil {
+		errorCode = *m.ErrorCode
+	}
+	if m.RecipeSlug != nil {
+		recipeSlug = *m.RecipeSlug
+	}
+	return QueueItem{
+		ID:             m.ID,
+		URL:            m.URL,
+		Status:         status,
+		Stage:          m.Stage,
+		StageChangedAt: m.StageAt,
+		Attempts:       m.Attempts,
+		LastError:      m.LastError,
+		ErrorCode:      errorCode,
+		RecipeSlug:     recipeSlug,
+		Priority:       m.Priority,
+		NextAttemptAt:  nextAttempt,
+		CreatedAt:      m.CreatedAt,
+		ProcessedAt:    m.ProcessedAt,
+		FailedAt:       m.FailedAt,
+		CancelledAt:    m.CancelledAt,
+	}
+}
+
+var (
+	errQueueItemNotFailed = errors.New("queue item has not failed")
+	errQueueItemPending   = errors.New("url is already queued")
+	errQueueItemFinished  = errors.New("queue item has already been processed")
+)
+
+// ListFailedQueueItems returns the user's dead-lettered queue items, most
+// recently failed first.
+func (r *RecipeRepository) ListFailedQueueItems(username string, limit, offset int) ([]QueueItem, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+	var models []QueueModel
+	if err := r.db.Where("user_id = ? AND failed_at IS NOT NULL", userID).
+		Order("failed_at DESC").Order("id DESC").
+		Limit(limit).Offset(offset).
+		Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("list failed queue items: %w", err)
+	}
+	items := make([]QueueItem, 0, len(models))
+	for _, model := range models {
+		items = append(items, model.toQueueItem())
+	}
+	return items, nil
+}
+
+// RetryQueueItem puts one of the user's failed queue items back in the
+// queue with fresh attempts, ahead of bulk work. It returns sql.ErrNoRows
+// for an item that isn't theirs, errQueueItemNotFailed for one that hasn't
+// failed and errQueueItemPending when the URL was queued again since.
+func (r *RecipeRepository) RetryQueueItem(username string, id uint) (QueueItem, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return QueueItem{}, err
+	}
+	var item QueueModel
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return QueueItem{}, sql.ErrNoRows
+		}
+		return QueueItem{}, fmt.Errorf("get queue item: %w", err)
+	}
+	if item.FailedAt == nil {
+		return QueueItem{}, errQueueItemNotFailed
+	}
+	var pending int64
+	if err := r.db.Model(&QueueModel{}).
+		Where("user_id = ? AND url = ? AND processed_at IS NULL", userID, item.URL).
+		Count(&pending).Error; err != nil {
+		return QueueItem{}, fmt.Errorf("check pending queue item: %w", err)
+	}
+	if pending > 0 {
+		return QueueItem{}, errQueueItemPending
+	}
+
+	updates := map[string]any{
+		"attempts":        0,
+		"last_error":      nil,
+		"error_code":      nil,
+		"next_attempt_at": nil,
+		"processed_at":    nil,
+		"failed_at":       nil,
+		"priority":        max(item.Priority, queuePriorityInteractive),
+		"updated_at":      gorm.Expr("CURRENT_TIMESTAMP"),
+	}
+	setQueueStage(updates, queueStageQueued)
+	if err := r.db.Model(&QueueModel{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return QueueItem{}, fmt.Errorf("retry queue item: %w", err)
+	}
+	var retried QueueModel
+	if err := r.db.First(&retried, id).Error; err != nil {
+		return QueueItem{}, fmt.Errorf("get queue item: %w", err)
+	}
+	return retried.toQueueItem(), nil
+}
+
+// CancelQueueItem cancels one of the user's pending queue items. A worker
+// importing it notices through CancelledQueueItems and abandons the import.
+// It returns sql.ErrNoRows for an item that isn't theirs and
+// errQueueItemFinished for one already processed.
+func (r *RecipeRepository) CancelQueueItem(username string, id uint) (QueueItem, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return QueueItem{}, err
+	}
+	updates := map[string]any{
+		"cancelled_at":    gorm.Expr("CURRENT_TIMESTAMP"),
+		"processed_at":    gorm.Expr("CURRENT_TIMESTAMP"),
+		"next_attempt_at": nil,
+		"page_html":       nil,
+		"updated_at":      gorm.Expr("CURRENT_TIMESTAMP"),
+	}
+	setQueueStage(updates, queueStageCancelled)
+	result := r.db.Model(&QueueModel{}).
+		Where("id = ? AND user_id = ? AND processed_at IS NULL", id, userID).
+		Updates(updates)
+	if result.Error != nil {
+		return QueueItem{}, fmt.Errorf("cancel queue item: %w", result.Error)
+	}
+
+	var item QueueModel
+	if err := r.db.Where("id = ? AND user_id = ?", id, userID).First(&item).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return QueueItem{}, sql.ErrNoRows
+		}
+		return QueueItem{}, fmt.Errorf("get queue item: %w", err)
+	}
+	if result.RowsAffected == 0 {
+		return QueueItem{}, errQueueItemFinished
+	}
+	return item.toQueueItem(), nil
+}
+
+// CancelledQueueItems returns which of ids have been cancelled.
+func (r *RecipeRepository) CancelledQueueItems(ids []uint) ([]uint, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	var cancelled []uint
+	if err := r.db.Model(&QueueModel{}).
+		Where("id IN ? AND cancelled_at IS NOT NULL", ids).
+		Pluck("id", &cancelled).Error; err != nil {
+		return nil, fmt.Errorf("find cancelled queue items: %w", err)
+	}
+	return cancelled, nil
+}
+
+// newRecipeModel builds the row SaveRecipeForUser writes for recipe.
+func newRecipeModel(userID uint, slug string, recipe Recipe) (RecipeModel, error) {
+	if len(recipe.Instructions) == 0 && len(recipe.InstructionSections) > 0 {
+		recipe.Instructions = flattenInstructionSections(recipe.InstructionSections)
+	}
 	instructionsBytes, err := json.Marshal(recipe.Instructions)
 	if err != nil {
-		return fmt.Errorf("marshal instructions: %w", err)
+		return RecipeModel{}, fmt.Errorf("marshal instructions: %w", err)
+	}
+	structured := ""
+	if len(recipe.InstructionSections) > 0 {
+		structuredBytes, err := json.Marshal(recipe.InstructionSections)
+		if err != nil {
+			return RecipeModel{}, fmt.Errorf("marshal instruction sections: %w", err)
+		}
+		structured = string(structuredBytes)
 	}
 	ingredientsBytes, err := json.Marshal(recipe.Ingredients)
 	if err != nil {
-		return fmt.Errorf("marshal ingredients: %w", err)
+		return RecipeModel{}, fmt.Errorf("marshal ingredients: %w", err)
 	}
 	parsedBytes, err := json.Marshal(recipe.ParsedIngredients)
 	if err != nil {
-		return fmt.Errorf("marshal parsed ingredients: %w", err)
+		return RecipeModel{}, fmt.Errorf("marshal parsed ingredients: %w", err)
 	}
-
-	tx := r.db.Begin()
-	if err := tx.Error; err != nil {
-		return err
+	equipmentBytes, err := json.Marshal(normalizeTerms(recipe.Equipment))
+	if err != nil {
+		return RecipeModel{}, fmt.Errorf("marshal equipment: %w", err)
 	}
-	defer func() {
+	tagsBytes, err := json.Marshal(normalizeTerms(recipe.Tags))
+	if err != nil {
+		return RecipeModel{}, fmt.Errorf("marshal tags: %w", err)
+	}
+	diets, err := recipeDietsJSON(recipe)
+	if err != nil {
+		return RecipeModel{}, err
+	}
+	nutrition := ""
+	if n := cleanNutrition(recipe.Nutrition); n != nil {
+		nutritionBytes, err := json.Marshal(n)
 		if err != nil {
-			tx.Rollback()
-		} else {
-			tx.Commit()
+			return RecipeModel{}, fmt.Errorf("marshal nutrition: %w", err)
 		}
-	}()
-
-	model := RecipeModel{
-		UserID:       userID,
-		Slug:         slug,
-		Title:        recipe.Title,
-		Category:     normalizeCategoryOrOther(recipe.Category),
-		CookTime:     recipe.CookTime,
-		Date:         recipe.Date,
-		Image:        recipe.Image,
-		Instructions: string(instructionsBytes),
-		Ingredients:  string(ingredientsBytes),
-		ParsedJSON:   string(parsedBytes),
-		PrepTime:     recipe.PrepTime,
-		Servings:     recipe.Servings,
-		TotalTime:    recipe.TotalTime,
-		Link:         recipe.Link,
-		OriginalURL:  recipe.OriginalURL,
-	}
-
-	assignments := clause.Assignments(map[string]any{
-		"title":              recipe.Title,
-		"category":           normalizeCategoryOrOther(recipe.Category),
-		"cook_time":          recipe.CookTime,
-		"date":               recipe.Date,
-		"image":              recipe.Image,
-		"instructions":       string(instructionsBytes),
-		"ingredients":        string(ingredientsBytes),
-		"parsed_ingredients": string(parsedBytes),
-		"prep_time":          recipe.PrepTime,
-		"servings":           recipe.Servings,
-		"total_time":         recipe.TotalTime,
-		"link":               recipe.Link,
-		"original_url":       recipe.OriginalURL,
-		"updated_at":         gorm.Expr("CURRENT_TIMESTAMP"),
-	})
+		nutrition = string(nutritionBytes)
+	}
+	var schemaVersion *int
+	if recipe.SchemaVersion > 0 {
+		schemaVersion = &recipe.SchemaVersion
+	}
+
+	return RecipeModel{
+		UserID:         userID,
+		Slug:           slug,
+		Title:          recipe.Title,
+		Category:       normalizeCategoryOrOther(recipe.Category),
+		CookTime:       recipe.CookTime,
+		Date:           recipe.Date,
+		Image:          recipe.Image,
+		Instructions:   string(instructionsBytes),
+		StructuredJSON: structured,
+		Ingredients:    string(ingredientsBytes),
+		ParsedJSON:     string(parsedBytes),
+		EquipmentJSON:  string(equipmentBytes),
+		TagsJSON:       string(tagsBytes),
+		NutritionJSON:  nutrition,
+		DietsJSON:      &diets,
+		PrepTime:       recipe.PrepTime,
+		Servings:       recipe.Servings,
+		TotalTime:      recipe.TotalTime,
+		Link:           recipe.Link,
+		OriginalURL:    recipe.OriginalURL,
+		SourceKey:      recipe.SourceKey,
+		SchemaVersion:  schemaVersion,
+	}, nil
+}
 
-	if err = tx.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "user_id"}, {Name: "slug"}},
-		DoUpdates: assignments,
-	}).Create(&model).Error; err != nil {
-		return fmt.Errorf("save recipe: %w", err)
+func (r *RecipeRepository) SaveRecipeForUser(username, slug string, recipe Recipe) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
 	}
 
-	if model.ID == 0 {
-		if err = tx.Where("user_id = ? AND slug = ?", userID, slug).First(&model).Error; err != nil {
-			return fmt.Errorf("fetch recipe id: %w", err)
-		}
+	model, err := newRecipeModel(userID, slug, recipe)
+	if err != nil {
+		return err
 	}
 
-	// Ingredients now persisted on the recipe row as JSON
+	updateColumns := map[string]any{
+		"title":                   model.Title,
+		"category":                model.Category,
+		"cook_time":               model.CookTime,
+		"date":                    model.Date,
+		"image":                   model.Image,
+		"instructions":            model.Instructions,
+		"structured_instructions": model.StructuredJSON,
+		"ingredients":             model.Ingredients,
+		"parsed_ingredients":      model.ParsedJSON,
+		"equipment":               model.EquipmentJSON,
+		"diets":                   model.DietsJSON,
+		"prep_time":               model.PrepTime,
+		"servings":                model.Servings,
+		"total_time":              model.TotalTime,
+		"link":                    model.Link,
+		"original_url":            model.OriginalURL,
+		"schema_version":          model.SchemaVersion,
+		"updated_at":              gorm.Expr("CURRENT_TIMESTAMP"),
+	}
+	// Re-saving a scraped recipe must not wipe tags the user added, and a
+	// placeholder must not drop an archived page
+	if len(recipe.Tags) > 0 {
+		updateColumns["tags"] = model.TagsJSON
+	}
+	if model.SourceKey != "" {
+		updateColumns["source_key"] = model.SourceKey
+	}
+	if model.NutritionJSON != "" {
+		updateColumns["nutrition"] = model.NutritionJSON
+	}
+	assignments := clause.Assignments(updateColumns)
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "slug"}},
+			DoUpdates: assignments,
+		}).Create(&model).Error; err != nil {
+			return fmt.Errorf("save recipe: %w", err)
+		}
 
-	// Legacy user_recipes link omitted in user-owned model
+		if model.ID == 0 {
+			if err := tx.Where("user_id = ? AND slug = ?", userID, slug).First(&model).Error; err != nil {
+				return fmt.Errorf("fetch recipe id: %w", err)
+			}
+		}
 
-	return nil
+		// The indexes are written on the same transaction, so a recipe is
+		// never saved without them
+		return indexRecipe(tx, model)
+	})
 }
 
 func (r *RecipeRepository) GetRecipe(username, slug string) (Recipe, error) {
@@ -931,7 +1835,7 @@ func (r *RecipeRepository) GetRecipe(username, slug string) (Recipe, error) {
 	return recipe, nil
 }
 
-func (r *RecipeRepository) ListRecipes(username, category string) ([]Recipe, error) {
+func (r *RecipeRepository) ListRecipes(username string, filters RecipeFilters) ([]Recipe, error) {
 	if username == "" {
 		return nil, errors.New("username is required")
 	}
@@ -941,71 +1845,73 @@ func (r *RecipeRepository) ListRecipes(username, category string) ([]Recipe, err
 		return nil, err
 	}
 
-	var models []RecipeModel
-	if err := r.db.Table("recipes").
+	query := r.db.Table("recipes").
 		Select("recipes.*").
-		Where("recipes.user_id = ?", userID).
-		Order("recipes.created_at DESC").
-		Find(&models).Error; err != nil {
+		Where("recipes.user_id = ?", userID)
+	query = applyRecipeFilters(query, userID, filters)
+
+	var models []RecipeModel
+	if err := query.Order("recipes.created_at DESC").Find(&models).Error; err != nil {
 		return nil, fmt.Errorf("list recipes: %w", err)
 	}
 
+	favorites, err := r.favoriteRecipeIDs(userID, recipeModelIDs(models))
+	if err != nil {
+		return nil, err
+	}
+
 	recipes := make([]Recipe, 0, len(models))
 	for _, model := range models {
 		recipe, err := model.toRecipe()
 		if err != nil {
 			return nil, err
 		}
-
-		if fav, favErr := r.isFavorite(userID, model.ID); favErr != nil {
-			return nil, favErr
-		} else {
-			recipe.IsFavorite = fav
-		}
+		recipe.IsFavorite = favorites[model.ID]
 		recipes = append(recipes, recipe)
 	}
 
 	return recipes, nil
 }
 
-func (r *RecipeRepository) SearchRecipes(username, term string) ([]Recipe, error) {
+// SearchRecipes returns the page of matches opts.Page asks for, best first,
+// and how many recipes match in all.
+func (r *RecipeRepository) SearchRecipes(username string, opts RecipeSearchOptions) ([]Recipe, int64, error) {
 	if username == "" {
-		return nil, errors.New("username is required")
+		return nil, 0, errors.New("username is required")
 	}
 
 	userID, err := r.getUserID(username)
 	if err != nil {
-		return nil, err
+		return nil, 0, err
 	}
 
-	likeTerm := fmt.Sprintf("%%%s%%", strings.ToLower(term))
+	models, total, err := r.findSearchResults(userID, opts)
+	if err != nil {
+		return nil, 0, err
+	}
 
-	var models []RecipeModel
-	if err := r.db.Table("recipes").
-		Select("recipes.*").
-		Where("recipes.user_id = ?", userID).
-		Where("LOWER(recipes.title) LIKE ?", likeTerm).
-		Order("recipes.created_at DESC").
-		Find(&models).Error; err != nil {
-		return nil, fmt.Errorf("search recipes: %w", err)
+	ids := make([]uint, 0, len(models))
+	for _, model := range models {
+		ids = append(ids, model.ID)
+	}
+	favorites, err := r.favoriteRecipeIDs(userID, ids)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	recipes := make([]Recipe, 0, len(models))
 	for _, model := range models {
-		recipe, err := model.toRecipe()
+		recipe, err := model.RecipeModel.toRecipe()
 		if err != nil {
-			return nil, err
-		}
-
-		if fav, favErr := r.isFavorite(userID, model.ID); favErr != nil {
-			return nil, favErr
-		} else {
-			recipe.IsFavorite = fav
+			return nil, 0, err
 		}
+		recipe.IsFavorite = favorites[model.ID]
+		recipe.Score = model.Score
+		recipe.Match = findSearchMatch(recipe, opts)
 		recipes = append(recipes, recipe)
 	}
 
-	return recipes, nil
+	return recipes, total, nil
 }
 
 func (r *RecipeRepository) ListFavoriteRecipes(username string) ([]Recipe, error) {
@@ -1126,30 +2032,7 @@ func (r *RecipeRepository) SetFavoriteByID(username string, recipeID uint, favor
 }
 
 func (r *RecipeRepository) DeleteRecipeByID(username string, recipeID uint) error {
-	if username == "" {
-		return errors.New("username is required")
-	}
-
-	userID, err := r.getUserID(username)
-	if err != nil {
-		return err
-	}
-	var model RecipeModel
-	if err := r.db.Where("user_id = ? AND id = ?", userID, recipeID).First(&model).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
-		}
-		return fmt.Errorf("lookup recipe: %w", err)
-	}
-	if err := r.db.Where("user_id = ? AND recipe_id = ?", userID, model.ID).Delete(&FavoriteModel{}).Error; err != nil {
-		if !isNoSuchTableError(err) {
-			return fmt.Errorf("delete favorites: %w", err)
-		}
-	}
-	if err := r.db.Delete(&RecipeModel{}, model.ID).Error; err != nil {
-		return fmt.Errorf("delete recipe: %w", err)
-	}
-	return nil
+	return r.deleteRecipe(username, "id = ?", recipeID)
 }
 
 // composeDisplayWithUnit builds a display string from amount, unit, and description.
@@ -1252,13 +2135,18 @@ func parseIngredientString(input string) (*float64, string, string) {
 	amountTokens := make([]string, 0, len(fields))
 	idx := 0
 	for idx < len(fields) {
-		token := strings.Trim(fields[idx], ",()")
+		// "1 (14 oz) can": the parenthetical is part of the description
+		if strings.HasPrefix(fields[idx], "(") {
+			break
+		}
+		token := strings.Trim(fields[idx], ",")
 		if token == "" {
 			idx++
 			continue
 		}
-		if token == "-" && len(amountTokens) > 0 {
-			break
+		if (token == "-" || strings.EqualFold(token, "to")) && len(amountTokens) > 0 {
+			// A range such as "2 - 3" or "2 to 3" has no single amount
+			return nil, "", trimmed
 		}
 		if containsNumeric(token) {
 			amountTokens = append(amountTokens, token)
@@ -1283,18 +2171,104 @@ func parseIngredientString(input string) (*float64, string, string) {
 	return nil, "", trimmed
 }
 
-// Deprecated parsing helpers retained for potential future use
-func containsNumeric(token string) bool { return false }
+// containsNumeric reports whether a token holds a digit or a fraction
+// character such as "½".
+func containsNumeric(token string) bool {
+	for _, r := range token {
+		if unicode.IsDigit(r) || isUnicodeFraction(r) {
+			return true
+		}
+	}
+	return false
+}
 
-func isUnicodeFraction(r rune) bool { return false }
+func isUnicodeFraction(r rune) bool {
+	_, ok := unicodeFractionToFloat(r)
+	return ok
+}
 
-func parseAmountTokens(tokens []string) (float64, bool) { return 0, false }
+// parseAmountTokens adds up the parts of an amount such as "1 1/2"; any
+// part that isn't a number fails the whole amount.
+func parseAmountTokens(tokens []string) (float64, bool) {
+	total := 0.0
+	for _, token := range tokens {
+		value, ok := parseSingleToken(token)
+		if !ok {
+			return 0, false
+		}
+		total += value
+	}
+	return total, total > 0
+}
 
-func parseSingleToken(token string) (float64, bool) { return 0, false }
+// parseSingleToken reads "2", "1.5", "3/4", "½" or "1½".
+func parseSingleToken(token string) (float64, bool) {
+	if token == "" {
+		return 0, false
+	}
+	runes := []rune(token)
+	if frac, ok := unicodeFractionToFloat(runes[len(runes)-1]); ok {
+		if len(runes) == 1 {
+			return frac, true
+		}
+		whole, err := strconv.ParseFloat(string(runes[:len(runes)-1]), 64)
+		if err != nil {
+			return 0, false
+		}
+		return whole + frac, true
+	}
+	if num, den, found := strings.Cut(token, "/"); found {
+		n, errN := strconv.ParseFloat(num, 64)
+		d, errD := strconv.ParseFloat(den, 64)
+		if errN != nil || errD != nil || d == 0 {
+			return 0, false
+		}
+		return n / d, true
+	}
+	value, err := strconv.ParseFloat(token, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0, false
+	}
+	return value, true
+}
 
-func unicodeFractionToFloat(r rune) (float64, bool) { return 0, false }
+func unicodeFractionToFloat(r rune) (float64, bool) {
+	switch r {
+	case '¼':
+		return 0.25, true
+	case '½':
+		return 0.5, true
+	case '¾':
+		return 0.75, true
+	case '⅓':
+		return 1.0 / 3, true
+	case '⅔':
+		return 2.0 / 3, true
+	case '⅛':
+		return 0.125, true
+	case '⅜':
+		return 0.375, true
+	case '⅝':
+		return 0.625, true
+	case '⅞':
+		return 0.875, true
+	case '⅕':
+		return 0.2, true
+	case '⅙':
+		return 1.0 / 6, true
+	}
+	return 0, false
+}
 
 func (r *RecipeRepository) DeleteRecipe(username, slug string) error {
+	return r.deleteRecipe(username, "slug = ?", slug)
+}
+
+// deleteRecipe deletes the user's recipe matching where, if there is one.
+// Its favorites, planned meals, chat messages and ingredient rows go with it
+// through their ON DELETE CASCADE foreign keys; the search row, which can't
+// have one, is removed in the same transaction.
+func (r *RecipeRepository) deleteRecipe(username, where string, arg any) error {
 	if username == "" {
 		return errors.New("username is required")
 	}
@@ -1303,26 +2277,19 @@ func (r *RecipeRepository) DeleteRecipe(username, slug string) error {
 	if err != nil {
 		return err
 	}
-	// Find recipe id first
-	var model RecipeModel
-	if err := r.db.Where("user_id = ? AND slug = ?", userID, slug).First(&model).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var model RecipeModel
+		if err := tx.Where("user_id = ? AND "+where, userID, arg).First(&model).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return fmt.Errorf("lookup recipe: %w", err)
 		}
-		return fmt.Errorf("lookup recipe: %w", err)
-	}
-	// Delete favorites and notes scoped to this user and recipe
-	if err := r.db.Where("user_id = ? AND recipe_id = ?", userID, model.ID).Delete(&FavoriteModel{}).Error; err != nil {
-		if !isNoSuchTableError(err) {
-			return fmt.Errorf("delete favorites: %w", err)
+		if err := tx.Delete(&RecipeModel{}, model.ID).Error; err != nil {
+			return fmt.Errorf("delete recipe: %w", err)
 		}
-	}
-
-	// Delete recipe row (ingredients cascade via FK in SQL)
-	if err := r.db.Delete(&RecipeModel{}, model.ID).Error; err != nil {
-		return fmt.Errorf("delete recipe: %w", err)
-	}
-	return nil
+		return unindexRecipe(tx, model.ID)
+	})
 }
 
 func (r *RecipeRepository) CountRecipes(username string) (int64, error) {
@@ -1374,12 +2341,24 @@ func (m RecipeModel) toRecipe() (Recipe, error) {
 	recipe.TotalTime = m.TotalTime
 	recipe.Link = m.Link
 	recipe.OriginalURL = m.OriginalURL
+	recipe.LastCookedAt = m.LastCookedAt
+	recipe.IsPublic = m.IsPublic
+	recipe.SourceKey = m.SourceKey
+	recipe.HasSource = m.SourceKey != ""
+	if m.SchemaVersion != nil {
+		recipe.SchemaVersion = *m.SchemaVersion
+	}
 
 	if len(m.Instructions) > 0 {
 		if err := json.Unmarshal([]byte(m.Instructions), &recipe.Instructions); err != nil {
 			return Recipe{}, fmt.Errorf("unmarshal instructions: %w", err)
 		}
 	}
+	if strings.TrimSpace(m.StructuredJSON) != "" {
+		if err := json.Unmarshal([]byte(m.StructuredJSON), &recipe.InstructionSections); err != nil {
+			return Recipe{}, fmt.Errorf("unmarshal instruction sections: %w", err)
+		}
+	}
 	if strings.TrimSpace(m.Ingredients) != "" {
 		if err := json.Unmarshal([]byte(m.Ingredients), &recipe.Ingredients); err != nil {
 			return Recipe{}, fmt.Errorf("unmarshal ingredients: %w", err)
@@ -1389,6 +2368,44 @@ func (m RecipeModel) toRecipe() (Recipe, error) {
 		if err := json.Unmarshal([]byte(m.ParsedJSON), &recipe.ParsedIngredients); err != nil {
 			return Recipe{}, fmt.Errorf("unmarshal parsed ingredients: %w", err)
 		}
+		// The stored amounts are what scaling starts from
+		for i := range recipe.ParsedIngredients {
+			detail := &recipe.ParsedIngredients[i]
+			if detail.AmountValue != nil {
+				detail.BaseAmountValue = floatPtr(*detail.AmountValue)
+			}
+			detail.BaseAmountText = detail.AmountText
+		}
+	}
+	recipe.IngredientGroups = groupIngredients(recipe.ParsedIngredients)
+	if strings.TrimSpace(m.EquipmentJSON) != "" {
+		if err := json.Unmarshal([]byte(m.EquipmentJSON), &recipe.Equipment); err != nil {
+			return Recipe{}, fmt.Errorf("unmarshal equipment: %w", err)
+		}
+	}
+	if strings.TrimSpace(m.TagsJSON) != "" {
+		if err := json.Unmarshal([]byte(m.TagsJSON), &recipe.Tags); err != nil {
+			return Recipe{}, fmt.Errorf("unmarshal tags: %w", err)
+		}
+	}
+	if strings.TrimSpace(m.NutritionJSON) != "" {
+		var nutrition Nutrition
+		if err := json.Unmarshal([]byte(m.NutritionJSON), &nutrition); err != nil {
+			return Recipe{}, fmt.Errorf("unmarshal nutrition: %w", err)
+		}
+		recipe.Nutrition = &nutrition
+	}
+	if m.DietsJSON != nil && strings.TrimSpace(*m.DietsJSON) != "" {
+		if err := json.Unmarshal([]byte(*m.DietsJSON), &recipe.Diets); err != nil {
+			return Recipe{}, fmt.Errorf("unmarshal diets: %w", err)
+		}
+	}
+	if strings.TrimSpace(m.USDAJSON) != "" {
+		var usda USDANutrition
+		if err := json.Unmarshal([]byte(m.USDAJSON), &usda); err != nil {
+			return Recipe{}, fmt.Errorf("unmarshal usda nutrition: %w", err)
+		}
+		recipe.USDANutrition = &usda
 	}
 
 	return recipe, nil
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AIExtractionModel struct {
+	ContentHash string    `gorm:"column:content_hash;primaryKey"`
+	Model       string    `gorm:"column:model;not null"`
+	Response    string    `gorm:"column:response;not null"`
+	Hits        int       `gorm:"column:hits"`
+	CreatedAt   time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (AIExtractionModel) TableName() string {
+	return "ai_extractions"
+}
+
+// GetCachedExtraction returns the AI response stored for hash, or
+// sql.ErrNoRows when there is none newer than maxAge.
+func (r *RecipeRepository) GetCachedExtraction(hash string, maxAge time.Duration) (*Response, error) {
+	var row AIExtractionModel
+	err := r.db.Where("content_hash = ? AND created_at > ?", hash, time.Now().Add(-maxAge)).First(&row).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("lookup ai extraction: %w", err)
+	}
+
+	var response Response
+	if err := json.Unmarshal([]byte(row.Response), &response); err != nil {
+		return nil, fmt.Errorf("unmarshal ai extraction: %w", err)
+	}
+	if err := r.db.Model(&AIExtractionModel{}).Where("content_hash = ?", hash).
+		UpdateColumn("hits", gorm.Expr("hits + 1")).Error; err != nil {
+		return nil, fmt.Errorf("count ai extraction hit: %w", err)
+	}
+	return &response, nil
+}
+
+// SaveCachedExtraction stores response under hash, replacing an expired
+// entry.
+func (r *RecipeRepository) SaveCachedExtraction(hash, model string, response *Response) error {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return fmt.Errorf("marshal ai extraction: %w", err)
+	}
+	row := AIExtractionModel{ContentHash: hash, Model: model, Response: string(data), CreatedAt: time.Now()}
+	err = r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "content_hash"}},
+		DoUpdates: clause.AssignmentColumns([]string{"model", "response", "hits", "created_at"}),
+	}).Create(&row).Error
+	if err != nil {
+		return fmt.Errorf("save ai extraction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AIUsageModel struct {
+	ID               uint      `gorm:"primaryKey"`
+	UserID           *uint     `gorm:"column:user_id;index"`
+	Operation        string    `gorm:"column:operation;not null"`
+	Provider         string    `gorm:"column:provider;not null"`
+	Model            string    `gorm:"column:model;not null"`
+	URL              *string   `gorm:"column:url"`
+	PromptTokens     int       `gorm:"column:prompt_tokens"`
+	CompletionTokens int       `gorm:"column:completion_tokens"`
+	TotalTokens      int       `gorm:"column:total_tokens"`
+	CostUSD          float64   `gorm:"column:cost_usd"`
+	CreatedAt        time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (AIUsageModel) TableName() string {
+	return "ai_usage"
+}
+
+// AIUsageEntry is one AI call to record. Username and URL may be empty.
+type AIUsageEntry struct {
+	Username  string
+	Operation string
+	Provider  string
+	Model     string
+	URL       string
+	Usage     Usage
+	CostUSD   float64
+}
+
+// AIUsageTotals sums a set of ai_usage rows.
+type AIUsageTotals struct {
+	Calls            int64   `json:"calls"`
+	PromptTokens     int64   `json:"prompt_tokens"`
+	CompletionTokens int64   `json:"completion_tokens"`
+	TotalTokens      int64   `json:"total_tokens"`
+	CostUSD          float64 `json:"cost_usd"`
+}
+
+// AIUsageGroup is the usage of one user, URL, operation or model.
+type AIUsageGroup struct {
+	Key string `json:"key" gorm:"column:group_key"`
+	AIUsageTotals
+}
+
+type AIUsageSummary struct {
+	Totals      AIUsageTotals  `json:"totals"`
+	ByOperation []AIUsageGroup `json:"by_operation"`
+	ByModel     []AIUsageGroup `json:"by_model"`
+}
+
+// AIUsageRollup is the usage of every user, with the heaviest users and
+// pages first.
+type AIUsageRollup struct {
+	Totals  AIUsageTotals  `json:"totals"`
+	ByUser  []AIUsageGroup `json:"by_user"`
+	ByURL   []AIUsageGroup `json:"by_url"`
+	ByModel []AIUsageGroup `json:"by_model"`
+}
+
+const aiUsageSums = `COUNT(*) AS calls, COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens,
+	COALESCE(SUM(completion_tokens), 0) AS completion_tokens, COALESCE(SUM(total_tokens), 0) AS total_tokens,
+	COALESCE(SUM(cost_usd), 0) AS cost_usd`
+
+func (r *RecipeRepository) RecordAIUsage(entry AIUsageEntry) error {
+	row := AIUsageModel{
+		Operation:        entry.Operation,
+		Provider:         entry.Provider,
+		Model:            entry.Model,
+		PromptTokens:     entry.Usage.PromptTokens,
+		CompletionTokens: entry.Usage.CompletionTokens,
+		TotalTokens:      entry.Usage.TotalTokens,
+		CostUSD:          entry.CostUSD,
+	}
+	if row.TotalTokens == 0 {
+		row.TotalTokens = row.PromptTokens + row.CompletionTokens
+	}
+	if entry.URL != "" {
+		row.URL = &entry.URL
+	}
+	if entry.Username != "" {
+		userID, err := r.getUserID(entry.Username)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+		if err == nil {
+			row.UserID = &userID
+		} else {
+			log.Printf("AI usage for unknown user %s recorded without one", entry.Username)
+		}
+	}
+	if err := r.db.Create(&row).Error; err != nil {
+		return fmt.Errorf("record ai usage: %w", err)
+	}
+	return nil
+}
+
+// GetUserAIUsage sums a user's AI usage since the given time.
+func (r *RecipeRepository) GetUserAIUsage(username string, since time.Time) (AIUsageSummary, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return AIUsageSummary{}, err
+	}
+
+	var summary AIUsageSummary
+	scope := func() *gorm.DB {
+		return r.db.Model(&AIUsageModel{}).Where("user_id = ? AND created_at >= ?", userID, since)
+	}
+	if err := scope().Select(aiUsageSums).Scan(&summary.Totals).Error; err != nil {
+		return AIUsageSummary{}, fmt.Errorf("sum ai usage: %w", err)
+	}
+	if summary.ByOperation, err = aiUsageGroups(scope(), "operation", 0); err != nil {
+		return AIUsageSummary{}, err
+	}
+	if summary.ByModel, err = aiUsageGroups(scope(), "model", 0); err != nil {
+		return AIUsageSummary{}, err
+	}
+	return summary, nil
+}
+
+// GetAIUsageRollup sums everyone's AI usage since the given time, listing
+// at most limit users and URLs.
+func (r *RecipeRepository) GetAIUsageRollup(since time.Time, limit int) (AIUsageRollup, error) {
+	var rollup AIUsageRollup
+	scope := func() *gorm.DB {
+		return r.db.Model(&AIUsageModel{}).Where("ai_usage.created_at >= ?", since)
+	}
+	if err := scope().Select(aiUsageSums).Scan(&rollup.Totals).Error; err != nil {
+		return AIUsageRollup{}, fmt.Errorf("sum ai usage: %w", err)
+	}
+
+	var err error
+	byUser := scope().Joins("LEFT JOIN users ON users.id = ai_usage.user_id")
+	if rollup.ByUser, err = aiUsageGroups(byUser, "COALESCE(users.username, '')", limit); err != nil {
+		return AIUsageRollup{}, err
+	}
+	if rollup.ByURL, err = aiUsageGroups(scope().Where("url IS NOT NULL"), "url", limit); err != nil {
+		return AIUsageRollup{}, err
+	}
+	if rollup.ByModel, err = aiUsageGroups(scope(), "model", 0); err != nil {
+		return AIUsageRollup{}, err
+	}
+	return rollup, nil
+}
+
+// aiUsageGroups sums query's rows by the key expression, costliest first.
+func aiUsageGroups(query *gorm.DB, key string, limit int) ([]AIUsageGroup, error) {
+	query = query.Select(key + " AS group_key, " + aiUsageSums).
+		Group(key).
+		Order("cost_usd DESC, total_tokens DESC")
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	groups := []AIUsageGroup{}
+	if err := query.Scan(&groups).Error; err != nil {
+		return nil, fmt.Errorf("group ai usage by %s: %w", strings.TrimSpace(key), err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+)
+
+type AccountAuditModel struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    *uint     `gorm:"column:user_id;index"`
+	Username  string    `gorm:"column:username;not null"`
+	Action    string    `gorm:"column:action;not null"`
+	Detail    string    `gorm:"column:detail"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (AccountAuditModel) TableName() string {
+	return "account_audit_log"
+}
+
+// RecordAccountAudit appends an entry to the account audit log. The username is
+// stored alongside the id so entries stay readable after the user is purged.
+func (r *RecipeRepository) RecordAccountAudit(userID uint, username, action, detail string) error {
+	entry := AccountAuditModel{
+		Username: username,
+		Action:   action,
+		Detail:   detail,
+	}
+	if userID != 0 {
+		entry.UserID = &userID
+	}
+
+	log.Printf("[Audit] user=%s action=%s %s", username, action, detail)
+
+	if err := r.db.Create(&entry).Error; err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+var errBackupUnsupported = errors.New("database backups need SQLite; back up MySQL with its own tools")
+
+// SnapshotDatabase writes a consistent copy of the SQLite database to path,
+// which must not exist. VACUUM INTO reads inside one transaction, so the
+// copy is whole even while requests write, and it comes out compacted.
+func (r *RecipeRepository) SnapshotDatabase(path string) error {
+	if isMySQL(r.db) {
+		return errBackupUnsupported
+	}
+	if err := r.db.Exec("VACUUM INTO ?", path).Error; err != nil {
+		return fmt.Errorf("snapshot database: %w", err)
+	}
+	return nil
+}
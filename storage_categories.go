@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// CategoryDetail is a category with what GET /categories?details=true adds
+// to its count. AverageTotalTime, in minutes, covers the recipes that have
+// a total time and is null when none do.
+type CategoryDetail struct {
+	Category         string          `json:"category"`
+	Count            int64           `json:"count"`
+	Favorites        int64           `json:"favorites"`
+	AverageTotalTime *float64        `json:"averageTotalTime"`
+	Latest           *CategoryRecipe `json:"latest"`
+}
+
+// CategoryRecipe is the most recently added recipe of a category.
+type CategoryRecipe struct {
+	ID    uint   `json:"id"`
+	Slug  string `json:"slug"`
+	Title string `json:"title"`
+	Image string `json:"image"`
+}
+
+type categoryDetailRow struct {
+	Category         string
+	Count            int64
+	Favorites        int64
+	AverageTotalTime *float64
+	LatestID         *uint
+	LatestSlug       string
+	LatestTitle      string
+	LatestImage      string
+}
+
+// CategoryDetails is CategoryCounts with favorites, average total time and
+// the newest recipe of each category, in one query: the newest is picked by
+// a window function over the user's recipes.
+func (r *RecipeRepository) CategoryDetails(username string) ([]CategoryDetail, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []categoryDetailRow
+	if err := r.db.Raw(`WITH ranked AS (
+			SELECT r.id, r.slug, r.title, r.image, COALESCE(r.category, '') AS category, r.total_time,
+				ROW_NUMBER() OVER (PARTITION BY COALESCE(r.category, '') ORDER BY r.created_at DESC, r.id DESC) AS newest,
+				CASE WHEN f.recipe_id IS NULL THEN 0 ELSE 1 END AS favorite
+			FROM recipes r
+			LEFT JOIN (SELECT DISTINCT recipe_id FROM favorites WHERE user_id = ?) f ON f.recipe_id = r.id
+			WHERE r.user_id = ?
+		)
+		SELECT category, COUNT(*) AS count, SUM(favorite) AS favorites,
+			AVG(CASE WHEN total_time > 0 THEN total_time END) AS average_total_time,
+			MAX(CASE WHEN newest = 1 THEN id END) AS latest_id,
+			MAX(CASE WHEN newest = 1 THEN slug END) AS latest_slug,
+			MAX(CASE WHEN newest = 1 THEN title END) AS latest_title,
+			MAX(CASE WHEN newest = 1 THEN image END) AS latest_image
+		FROM ranked
+		GROUP BY category
+		ORDER BY LOWER(category)`, userID, userID).
+		Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("category details: %w", err)
+	}
+
+	details := make([]CategoryDetail, 0, len(rows))
+	for _, row := range rows {
+		detail := CategoryDetail{
+			Category:         row.Category,
+			Count:            row.Count,
+			Favorites:        row.Favorites,
+			AverageTotalTime: row.AverageTotalTime,
+		}
+		if row.LatestID != nil {
+			detail.Latest = &CategoryRecipe{ID: *row.LatestID, Slug: row.LatestSlug, Title: row.LatestTitle, Image: row.LatestImage}
+		}
+		details = append(details, detail)
+	}
+	return details, nil
+}
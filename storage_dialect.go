@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// The repository runs on SQLite or MySQL/MariaDB (see InitDatabase). Most
+// queries are written in SQL both accept; these cover the rest. MySQL has
+// no FTS5, so search there always uses LIKE matching.
+
+func isMySQL(db *gorm.DB) bool {
+	return db.Dialector.Name() == dbDriverMySQL
+}
+
+// jsonArrayContains is a condition, taking one argument, that the JSON
+// array of strings in column holds the argument.
+func jsonArrayContains(db *gorm.DB, column string) string {
+	if isMySQL(db) {
+		return fmt.Sprintf("JSON_CONTAINS(COALESCE(%s, '[]'), JSON_QUOTE(?))", column)
+	}
+	return fmt.Sprintf("EXISTS (SELECT 1 FROM json_each(COALESCE(%s, '[]')) WHERE json_each.value = ?)", column)
+}
+
+// randomOrder orders rows randomly.
+func randomOrder(db *gorm.DB) string {
+	if isMySQL(db) {
+		return "RAND()"
+	}
+	return "RANDOM()"
+}
+
+// yearMonth formats the time in column as "2006-01".
+func yearMonth(db *gorm.DB, column string) string {
+	if isMySQL(db) {
+		return fmt.Sprintf("DATE_FORMAT(%s, '%%Y-%%m')", column)
+	}
+	return fmt.Sprintf("strftime('%%Y-%%m', %s)", column)
+}
+
+// upsertIf is an insert's conflict clause that overwrites the existing
+// row's columns with the inserted values only when cond, with args, holds
+// for the existing row. MySQL has no conditional upsert, so there each
+// column is set through IF(); it assigns left to right and later columns
+// see the earlier ones' new values, so columns cond reads must come last.
+func upsertIf(db *gorm.DB, conflict, columns []string, cond string, args ...any) clause.OnConflict {
+	onConflict := clause.OnConflict{}
+	for _, name := range conflict {
+		onConflict.Columns = append(onConflict.Columns, clause.Column{Name: name})
+	}
+	if !isMySQL(db) {
+		onConflict.DoUpdates = clause.AssignmentColumns(columns)
+		onConflict.Where = clause.Where{Exprs: []clause.Expression{gorm.Expr(cond, args...)}}
+		return onConflict
+	}
+	for _, name := range columns {
+		onConflict.DoUpdates = append(onConflict.DoUpdates, clause.Assignment{
+			Column: clause.Column{Name: name},
+			Value:  gorm.Expr(fmt.Sprintf("IF(%s, VALUES(`%s`), `%s`)", cond, name, name), args...),
+		})
+	}
+	return onConflict
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// dialectTestRepos returns a SQLite repository, and a MySQL one when
+// MYSQL_TEST_DSN names an empty database to create the schema in, e.g.
+// root:secret@tcp(localhost:3306)/recipes_test.
+func dialectTestRepos(t *testing.T) map[string]*RecipeRepository {
+	t.Helper()
+	repos := map[string]*RecipeRepository{dbDriverSQLite: newTestRepo(t)}
+	if dsn := os.Getenv("MYSQL_TEST_DSN"); dsn != "" {
+		repos[dbDriverMySQL] = newMySQLTestRepo(t, dsn)
+	}
+	return repos
+}
+
+func newMySQLTestRepo(t *testing.T, dsn string) *RecipeRepository {
+	t.Helper()
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		t.Fatalf("MYSQL_TEST_DSN: %v", err)
+	}
+	cfg.MultiStatements = true
+	dbConfig := appConfig.Database
+	dbConfig.DSN = cfg.FormatDSN()
+	db, err := openMySQL(dbConfig)
+	if err != nil {
+		t.Fatalf("open mysql: %v", err)
+	}
+	db.Logger = logger.Default.LogMode(logger.Silent)
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("db instance: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	tables := []string{
+		"idempotency_keys", "recipe_chat_messages", "ai_usage", "scrape_claims", "ai_extractions",
+		"webhooks", "planned_meals", "recipe_ingredients", "account_audit_log", "favorites",
+		"password_resets", "queue", "recipes", "users",
+	}
+	for _, table := range tables {
+		if err := db.Exec("DROP TABLE IF EXISTS " + table).Error; err != nil {
+			t.Fatalf("drop %s: %v", table, err)
+		}
+	}
+	files, err := filepath.Glob(filepath.Join("SQL", "mysql", "*.sql"))
+	if err != nil {
+		t.Fatalf("list migrations: %v", err)
+	}
+	sort.Strings(files)
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatalf("read %s: %v", file, err)
+		}
+		if err := db.Exec(string(script)).Error; err != nil {
+			t.Fatalf("apply %s: %v", file, err)
+		}
+	}
+	return NewRecipeRepository(db)
+}
+
+func TestClaimScrapeUpsert(t *testing.T) {
+	for name, repo := range dialectTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			const page = "https://example.com/soup"
+			claim := func(owner string, ttl time.Duration, want bool) {
+				t.Helper()
+				got, err := repo.ClaimScrape(page, owner, ttl)
+				if err != nil || got != want {
+					t.Fatalf("%s claims = %t (err %v), want %t", owner, got, err, want)
+				}
+			}
+			claim("worker-1", time.Minute, true)
+			claim("worker-2", time.Minute, false)
+			// The owner renews its own claim
+			claim("worker-1", -time.Minute, true)
+			// and an expired one is taken over
+			claim("worker-2", time.Minute, true)
+			claim("worker-1", time.Minute, false)
+		})
+	}
+}
+
+func TestBeginIdempotentRequestUpsert(t *testing.T) {
+	for name, repo := range dialectTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			createTestUser(t, repo, "cook@example.com")
+			begin := func(hash string, ttl time.Duration) (*IdempotentResponse, error) {
+				return repo.BeginIdempotentRequest("cook@example.com", "key-1", hash, ttl)
+			}
+
+			if replay, err := begin("hash-a", time.Hour); err != nil || replay != nil {
+				t.Fatalf("first begin = %+v, %v; want the claim", replay, err)
+			}
+			if _, err := begin("hash-a", time.Hour); err != errIdempotencyKeyInProgress {
+				t.Fatalf("begin while running: err = %v, want errIdempotencyKeyInProgress", err)
+			}
+			stored := IdempotentResponse{Status: 202, ContentType: "application/json", Body: []byte(`{"ok":true}`)}
+			if err := repo.FinishIdempotentRequest("cook@example.com", "key-1", stored); err != nil {
+				t.Fatalf("finish: %v", err)
+			}
+			replay, err := begin("hash-a", time.Hour)
+			if err != nil || replay == nil || replay.Status != 202 || string(replay.Body) != `{"ok":true}` {
+				t.Fatalf("replay = %+v, %v; want the stored response", replay, err)
+			}
+			if _, err := begin("hash-b", time.Hour); err != errIdempotencyKeyReused {
+				t.Fatalf("begin with another request: err = %v, want errIdempotencyKeyReused", err)
+			}
+			// Once expired the key is claimed afresh, for any request
+			if replay, err := begin("hash-b", -time.Minute); err != nil || replay != nil {
+				t.Fatalf("begin after expiry = %+v, %v; want the claim", replay, err)
+			}
+		})
+	}
+}
+
+func TestTimestampsRoundTripInUTC(t *testing.T) {
+	for name, repo := range dialectTestRepos(t) {
+		t.Run(name, func(t *testing.T) {
+			createTestUser(t, repo, "cook@example.com")
+			if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/soup", queuePriorityInteractive); err != nil {
+				t.Fatalf("enqueue: %v", err)
+			}
+			items, err := repo.FetchPendingQueue(10)
+			if err != nil || len(items) != 1 {
+				t.Fatalf("pending = %d items (err %v), want 1", len(items), err)
+			}
+			// CURRENT_TIMESTAMP defaults and Go times compare as the same clock
+			if age := time.Since(items[0].CreatedAt); age < -time.Minute || age > time.Minute {
+				t.Fatalf("created_at = %s, %s from now; want UTC now", items[0].CreatedAt, age)
+			}
+
+			// A failed attempt's backoff is a Go time compared in SQL
+			if err := repo.MarkQueueItemResult(items[0].ID, errPageBlocked); err != nil {
+				t.Fatalf("mark failure: %v", err)
+			}
+			if items, err := repo.FetchPendingQueue(10); err != nil || len(items) != 0 {
+				t.Fatalf("pending during backoff = %d items (err %v), want 0", len(items), err)
+			}
+
+			if err := repo.MarkQueueItemResult(items[0].ID, nil); err != nil {
+				t.Fatalf("mark success: %v", err)
+			}
+			item, err := repo.GetQueueItem(items[0].ID)
+			if err != nil {
+				t.Fatalf("get item: %v", err)
+			}
+			if item.ProcessedAt == nil {
+				t.Fatal("processed_at not set")
+			}
+			if age := time.Since(*item.ProcessedAt); age < -time.Minute || age > time.Minute {
+				t.Fatalf("processed_at = %s, %s from now; want UTC now", item.ProcessedAt, age)
+			}
+		})
+	}
+}
+
+func TestUpsertIfOnMySQL(t *testing.T) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "user@tcp(localhost:3306)/recipes", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	claim := ScrapeClaimModel{URL: "https://example.com/soup", Owner: "worker-1", ExpiresAt: time.Now()}
+	stmt := db.Clauses(upsertIf(db, []string{"url"}, []string{"owner", "expires_at"},
+		"scrape_claims.expires_at < ? OR scrape_claims.owner = ?", time.Now(), "worker-1",
+	)).Create(&claim).Statement
+	if stmt.Error != nil {
+		t.Fatalf("build: %v", stmt.Error)
+	}
+	sql := stmt.SQL.String()
+	want := "ON DUPLICATE KEY UPDATE `owner`=IF(scrape_claims.expires_at < ? OR scrape_claims.owner = ?, VALUES(`owner`), `owner`)," +
+		"`expires_at`=IF(scrape_claims.expires_at < ? OR scrape_claims.owner = ?, VALUES(`expires_at`), `expires_at`)"
+	if !strings.Contains(sql, want) {
+		t.Fatalf("sql = %s\nwant it to contain %s", sql, want)
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const (
+	accountExportFormat  = "cooking.bronson.dev/export"
+	accountExportVersion = 1
+)
+
+// AccountExport is the portable backup produced by GET /export. Bump
+// accountExportVersion whenever a field changes meaning or is removed.
+type AccountExport struct {
+	Format      string           `json:"format"`
+	Version     int              `json:"version"`
+	ExportedAt  time.Time        `json:"exportedAt"`
+	Username    string           `json:"username"`
+	Preferences UserPreferences  `json:"preferences"`
+	Recipes     []ExportedRecipe `json:"recipes"`
+}
+
+// ExportedRecipe is a recipe plus the bookkeeping fields the API doesn't
+// normally expose. IsFavorite carries the favorite flag.
+type ExportedRecipe struct {
+	Slug string `json:"slug"`
+	Recipe
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ExportAccount gathers everything needed to restore the user's collection.
+func (r *RecipeRepository) ExportAccount(username string) (AccountExport, error) {
+	if username == "" {
+		return AccountExport{}, errors.New("username is required")
+	}
+
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	prefs, err := r.GetUserPreferences(username)
+	if err != nil {
+		return AccountExport{}, err
+	}
+
+	var models []RecipeModel
+	if err := r.db.Where("user_id = ?", userID).Order("created_at ASC").Find(&models).Error; err != nil {
+		return AccountExport{}, fmt.Errorf("list recipes: %w", err)
+	}
+
+	var favoriteIDs []uint
+	if err := r.db.Model(&FavoriteModel{}).Where("user_id = ?", userID).Pluck("recipe_id", &favoriteIDs).Error; err != nil {
+		return AccountExport{}, fmt.Errorf("list favorites: %w", err)
+	}
+	favorites := make(map[uint]bool, len(favoriteIDs))
+	for _, id := range favoriteIDs {
+		favorites[id] = true
+	}
+
+	recipes := make([]ExportedRecipe, 0, len(models))
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err != nil {
+			return AccountExport{}, err
+		}
+		recipe.IsFavorite = favorites[model.ID]
+		recipes = append(recipes, ExportedRecipe{
+			Slug:      model.Slug,
+			Recipe:    recipe,
+			CreatedAt: model.CreatedAt,
+			UpdatedAt: model.UpdatedAt,
+		})
+	}
+
+	return AccountExport{
+		Format:      accountExportFormat,
+		Version:     accountExportVersion,
+		ExportedAt:  time.Now().UTC(),
+		Username:    username,
+		Preferences: prefs,
+		Recipes:     recipes,
+	}, nil
+}
+
+// RecentRecipes returns the user's most recently added recipes, newest first.
+func (r *RecipeRepository) RecentRecipes(username string, limit int) ([]ExportedRecipe, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []RecipeModel
+	if err := r.db.Where("user_id = ?", userID).Order("created_at DESC, id DESC").Limit(limit).
+		Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("list recent recipes: %w", err)
+	}
+
+	recipes := make([]ExportedRecipe, 0, len(models))
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err != nil {
+			return nil, err
+		}
+		recipes = append(recipes, ExportedRecipe{
+			Slug:      model.Slug,
+			Recipe:    recipe,
+			CreatedAt: model.CreatedAt,
+			UpdatedAt: model.UpdatedAt,
+		})
+	}
+	return recipes, nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Ping runs a trivial query, so it fails when the database can't answer
+// one rather than only when the connection is gone.
+func (r *RecipeRepository) Ping(ctx context.Context) error {
+	var one int
+	if err := r.db.WithContext(ctx).Raw("SELECT 1").Scan(&one).Error; err != nil {
+		return fmt.Errorf("ping database: %w", err)
+	}
+	return nil
+}
+
+// schemaModels are the tables the repository maps, whose columns must all
+// exist for the schema to be current.
+var schemaModels = []any{
+	&UserModel{},
+	&RecipeModel{},
+	&QueueModel{},
+	&FavoriteModel{},
+	&PasswordResetModel{},
+	&AIExtractionModel{},
+	&AIUsageModel{},
+	&AccountAuditModel{},
+	&IdempotencyKeyModel{},
+	&RecipeIngredientModel{},
+	&PlannedMealModel{},
+	&RecipeChatMessageModel{},
+	&ScrapeClaimModel{},
+	&StarterRecipeModel{},
+	&WebhookModel{},
+}
+
+// SchemaCurrent checks that the migrations in SQL/ have been applied: every
+// table and column the models map exists.
+func (r *RecipeRepository) SchemaCurrent(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	for _, model := range schemaModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return fmt.Errorf("parse %T: %w", model, err)
+		}
+		table := stmt.Schema.Table
+		if !db.Migrator().HasTable(table) {
+			return fmt.Errorf("table %s is missing; apply the migrations in SQL/", table)
+		}
+		columns, err := db.Migrator().ColumnTypes(model)
+		if err != nil {
+			return fmt.Errorf("read %s columns: %w", table, err)
+		}
+		present := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			present[strings.ToLower(column.Name())] = true
+		}
+		var missing []string
+		for _, name := range stmt.Schema.DBNames {
+			if !present[strings.ToLower(name)] {
+				missing = append(missing, name)
+			}
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf("%s is missing %s; apply the migrations in SQL/", table, strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// RecentlyActiveUsernames returns up to limit users, most recently active
+// first.
+func (r *RecipeRepository) RecentlyActiveUsernames(limit int) ([]string, error) {
+	var usernames []string
+	err := r.db.Model(&UserModel{}).
+		Where("last_active_at IS NOT NULL AND frozen_at IS NULL").
+		Order("last_active_at DESC").
+		Limit(limit).
+		Pluck("username", &usernames).Error
+	if err != nil {
+		return nil, fmt.Errorf("recently active users: %w", err)
+	}
+	return usernames, nil
+}
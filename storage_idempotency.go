@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type IdempotencyKeyModel struct {
+	UserID      uint      `gorm:"column:user_id;primaryKey"`
+	Key         string    `gorm:"column:key;primaryKey"`
+	RequestHash string    `gorm:"column:request_hash;not null"`
+	Status      int       `gorm:"column:status;not null"`
+	ContentType *string   `gorm:"column:content_type"`
+	Body        []byte    `gorm:"column:body"`
+	CreatedAt   time.Time `gorm:"column:created_at;not null"`
+}
+
+func (IdempotencyKeyModel) TableName() string {
+	return "idempotency_keys"
+}
+
+// IdempotentResponse is a stored response replayed for a repeated key.
+type IdempotentResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+var (
+	errIdempotencyKeyInProgress = errors.New("a request with this Idempotency-Key is still in progress")
+	errIdempotencyKeyReused     = errors.New("this Idempotency-Key was already used for a different request")
+)
+
+// BeginIdempotentRequest claims key for the user's request with
+// requestHash. It returns nil when the request should run, the stored
+// response when it already ran, errIdempotencyKeyInProgress while it is
+// running and errIdempotencyKeyReused when the key came with another
+// request. Keys older than ttl are claimed afresh.
+func (r *RecipeRepository) BeginIdempotentRequest(username, key, requestHash string, ttl time.Duration) (*IdempotentResponse, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	claim := IdempotencyKeyModel{UserID: userID, Key: key, RequestHash: requestHash, CreatedAt: now}
+	// The insert carries the values a fresh claim resets the row to
+	result := r.db.Clauses(upsertIf(r.db,
+		[]string{"user_id", "key"},
+		[]string{"request_hash", "status", "content_type", "body", "created_at"},
+		"idempotency_keys.created_at < ?", now.Add(-ttl),
+	)).Create(&claim)
+	if result.Error != nil {
+		return nil, fmt.Errorf("claim idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		return nil, nil
+	}
+
+	var existing IdempotencyKeyModel
+	if err := r.db.Where("user_id = ? AND `key` = ?", userID, key).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Released between the insert and now; the client can retry
+			return nil, errIdempotencyKeyInProgress
+		}
+		return nil, fmt.Errorf("get idempotency key: %w", err)
+	}
+	switch {
+	case existing.RequestHash != requestHash:
+		return nil, errIdempotencyKeyReused
+	case existing.Status == 0:
+		return nil, errIdempotencyKeyInProgress
+	}
+	response := &IdempotentResponse{Status: existing.Status, Body: existing.Body}
+	if existing.ContentType != nil {
+		response.ContentType = *existing.ContentType
+	}
+	return response, nil
+}
+
+// FinishIdempotentRequest stores the response to replay for key.
+func (r *RecipeRepository) FinishIdempotentRequest(username, key string, response IdempotentResponse) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Model(&IdempotencyKeyModel{}).
+		Where("user_id = ? AND `key` = ?", userID, key).
+		Updates(map[string]any{
+			"status":       response.Status,
+			"content_type": response.ContentType,
+			"body":         response.Body,
+		}).Error; err != nil {
+		return fmt.Errorf("store idempotent response: %w", err)
+	}
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets key so a retry runs the request again,
+// after a failure that shouldn't be replayed.
+func (r *RecipeRepository) ReleaseIdempotencyKey(username, key string) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Where("user_id = ? AND `key` = ?", userID, key).Delete(&IdempotencyKeyModel{}).Error; err != nil {
+		return fmt.Errorf("release idempotency key: %w", err)
+	}
+	return nil
+}
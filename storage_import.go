@@ -0,0 +1,101 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// findImportedRecipe returns the slug of a recipe the user already has that
+// matches an imported one, either by source URL or by title and slug. Returns
+// sql.ErrNoRows when the recipe is new.
+func (r *RecipeRepository) findImportedRecipe(userID uint, slug string, recipe Recipe) (string, error) {
+	var model RecipeModel
+	query := r.db.Select("slug").Where("user_id = ?", userID)
+	// A previous import may have stored it under a suffixed slug
+	sameSlug := "(title = ? AND (slug = ? OR slug LIKE ?))"
+	if url := strings.TrimSpace(recipe.OriginalURL); url != "" {
+		query = query.Where("original_url = ? OR "+sameSlug, url, recipe.Title, slug, slug+"-%")
+	} else {
+		query = query.Where(sameSlug, recipe.Title, slug, slug+"-%")
+	}
+	if err := query.First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", sql.ErrNoRows
+		}
+		return "", fmt.Errorf("find imported recipe: %w", err)
+	}
+	return model.Slug, nil
+}
+
+// availableSlug returns base, or base-2, base-3... if the user already has it.
+func (r *RecipeRepository) availableSlug(userID uint, base string) (string, error) {
+	var taken []string
+	if err := r.db.Model(&RecipeModel{}).
+		Where("user_id = ? AND (slug = ? OR slug LIKE ?)", userID, base, base+"-%").
+		Pluck("slug", &taken).Error; err != nil {
+		return "", fmt.Errorf("check slug: %w", err)
+	}
+	used := make(map[string]bool, len(taken))
+	for _, slug := range taken {
+		used[slug] = true
+	}
+	if !used[base] {
+		return base, nil
+	}
+	for n := 2; ; n++ {
+		candidate := fmt.Sprintf("%s-%d", base, n)
+		if !used[candidate] {
+			return candidate, nil
+		}
+	}
+}
+
+// ResolveImportSlug decides where an imported recipe goes. It returns the
+// existing slug and isNew=false when the recipe was imported before, so
+// repeating an import is harmless; otherwise a free slug based on slug.
+func (r *RecipeRepository) ResolveImportSlug(username, slug string, recipe Recipe) (string, bool, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return "", false, err
+	}
+
+	existing, err := r.findImportedRecipe(userID, slug, recipe)
+	if err == nil {
+		return existing, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return "", false, err
+	}
+
+	slug, err = r.availableSlug(userID, slug)
+	if err != nil {
+		return "", false, err
+	}
+	return slug, true, nil
+}
+
+// SaveImportedRecipe stores an imported recipe and restores its favorite flag.
+func (r *RecipeRepository) SaveImportedRecipe(username, slug string, recipe Recipe) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	recipe.Link = fmt.Sprintf("/recipes/%s/%s", recipe.Category, slug)
+	if err := r.SaveRecipeForUser(username, slug, recipe); err != nil {
+		return err
+	}
+	if !recipe.IsFavorite {
+		return nil
+	}
+
+	var saved RecipeModel
+	if err := r.db.Select("id").Where("user_id = ? AND slug = ?", userID, slug).First(&saved).Error; err != nil {
+		return fmt.Errorf("fetch imported recipe: %w", err)
+	}
+	return r.SetFavoriteByID(username, saved.ID, true)
+}
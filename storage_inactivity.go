@@ -0,0 +1,149 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// TouchUserActivity records that the user was active now and resets any pending
+// inactivity warning. When reactivate is set (on login) a frozen account is unfrozen.
+func (r *RecipeRepository) TouchUserActivity(username string, reactivate bool) error {
+	var user UserModel
+	if err := r.db.Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return sql.ErrNoRows
+		}
+		return fmt.Errorf("lookup user: %w", err)
+	}
+
+	updates := map[string]any{
+		"last_active_at":       time.Now(),
+		"inactivity_warned_at": nil,
+	}
+	if reactivate {
+		updates["frozen_at"] = nil
+	}
+
+	if err := r.db.Model(&UserModel{}).Where("id = ?", user.ID).Updates(updates).Error; err != nil {
+		return fmt.Errorf("touch user activity: %w", err)
+	}
+
+	if reactivate && user.FrozenAt != nil {
+		if err := r.RecordAccountAudit(user.ID, user.Username, "account_reactivated", "user signed in"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *RecipeRepository) IsUserFrozen(username string) (bool, error) {
+	var user UserModel
+	if err := r.db.Select("id", "frozen_at").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, sql.ErrNoRows
+		}
+		return false, fmt.Errorf("lookup user: %w", err)
+	}
+	return user.FrozenAt != nil, nil
+}
+
+// inactiveUsers returns users whose last activity (or signup, if never active)
+// is older than cutoff, narrowed by an additional condition.
+func (r *RecipeRepository) inactiveUsers(cutoff time.Time, condition string, args ...any) ([]UserModel, error) {
+	query := r.db.Where("COALESCE(last_active_at, created_at) < ?", cutoff)
+	if condition != "" {
+		query = query.Where(condition, args...)
+	}
+
+	var users []UserModel
+	if err := query.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("find inactive users: %w", err)
+	}
+	return users, nil
+}
+
+func (r *RecipeRepository) UsersToWarnForInactivity(cutoff time.Time) ([]UserModel, error) {
+	return r.inactiveUsers(cutoff, "inactivity_warned_at IS NULL")
+}
+
+// UsersToFreezeForInactivity, like UsersToPurgeForInactivity, only returns
+// users who were warned before warnedBefore.
+func (r *RecipeRepository) UsersToFreezeForInactivity(cutoff, warnedBefore time.Time) ([]UserModel, error) {
+	return r.inactiveUsers(cutoff, "frozen_at IS NULL AND inactivity_warned_at IS NOT NULL AND inactivity_warned_at < ?", warnedBefore)
+}
+
+// UsersToPurgeForInactivity only returns users who were warned before warnedBefore,
+// so nobody is purged without notice.
+func (r *RecipeRepository) UsersToPurgeForInactivity(cutoff, warnedBefore time.Time) ([]UserModel, error) {
+	return r.inactiveUsers(cutoff, "inactivity_warned_at IS NOT NULL AND inactivity_warned_at < ?", warnedBefore)
+}
+
+func (r *RecipeRepository) MarkInactivityWarned(userID uint) error {
+	if err := r.db.Model(&UserModel{}).Where("id = ?", userID).
+		Update("inactivity_warned_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("mark inactivity warned: %w", err)
+	}
+	return nil
+}
+
+func (r *RecipeRepository) FreezeUser(userID uint) error {
+	if err := r.db.Model(&UserModel{}).Where("id = ?", userID).
+		Update("frozen_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("freeze user: %w", err)
+	}
+	return nil
+}
+
+// PurgeUser deletes a user and everything they own in a single transaction.
+func (r *RecipeRepository) PurgeUser(userID uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&FavoriteModel{}).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete favorites: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&PlannedMealModel{}).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete planned meals: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&RecipeChatMessageModel{}).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete recipe chat: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&WebhookModel{}).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete webhooks: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&IdempotencyKeyModel{}).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete idempotency keys: %w", err)
+		}
+		// Claims are keyed by URL; release the ones on this user's imports
+		if err := tx.Exec("DELETE FROM scrape_claims WHERE url IN (SELECT url FROM queue WHERE user_id = ?)", userID).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete scrape claims: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&QueueModel{}).Error; err != nil {
+			return fmt.Errorf("delete queue items: %w", err)
+		}
+		// SQLite doesn't enforce the ON DELETE SET NULL on ai_usage, and the
+		// metering rows name the URLs the user imported, so they go too
+		if err := tx.Where("user_id = ?", userID).Delete(&AIUsageModel{}).Error; err != nil && !isNoSuchTableError(err) {
+			return fmt.Errorf("delete ai usage: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&PasswordResetModel{}).Error; err != nil {
+			return fmt.Errorf("delete password resets: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&RecipeIngredientModel{}).Error; err != nil && !isIngredientTableMissing(err) {
+			return fmt.Errorf("delete recipe ingredients: %w", err)
+		}
+		if err := tx.Exec("DELETE FROM recipe_search WHERE rowid IN (SELECT id FROM recipes WHERE user_id = ?)", userID).Error; err != nil && !isSearchIndexUnavailable(err) {
+			return fmt.Errorf("delete search index rows: %w", err)
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&RecipeModel{}).Error; err != nil {
+			return fmt.Errorf("delete recipes: %w", err)
+		}
+		if err := tx.Delete(&UserModel{}, userID).Error; err != nil {
+			return fmt.Errorf("delete user: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPurgeUserDeletesEverythingTheyOwn(t *testing.T) {
+	repo := newTestRepo(t)
+	userID := createTestUser(t, repo, "gone@example.com")
+	createTestUser(t, repo, "stays@example.com")
+
+	recipe := Recipe{Title: "Soup", Category: "dinner", Ingredients: []string{"water"}, Instructions: []string{"Boil."}}
+	if err := repo.SaveRecipeForUser("gone@example.com", "soup", recipe); err != nil {
+		t.Fatalf("save recipe: %v", err)
+	}
+	if _, err := repo.SetPublicHandle("gone@example.com", "gone-cook"); err != nil {
+		t.Fatalf("set handle: %v", err)
+	}
+	if err := repo.EnqueueRecipe("gone@example.com", "https://example.com/soup", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if _, err := repo.ClaimScrape("https://example.com/soup", "worker-1", time.Minute); err != nil {
+		t.Fatalf("claim: %v", err)
+	}
+	for _, username := range []string{"gone@example.com", "stays@example.com"} {
+		if err := repo.RecordAIUsage(AIUsageEntry{Username: username, Operation: "extract", Provider: "openai", Model: "gpt", URL: "https://example.com/soup"}); err != nil {
+			t.Fatalf("record usage: %v", err)
+		}
+	}
+
+	if err := repo.PurgeUser(userID); err != nil {
+		t.Fatalf("purge: %v", err)
+	}
+
+	for _, check := range []struct {
+		table string
+		where string
+		arg   any
+	}{
+		{"users", "id = ?", userID},
+		{"recipes", "user_id = ?", userID},
+		{"queue", "user_id = ?", userID},
+		{"ai_usage", "user_id = ?", userID},
+		{"scrape_claims", "url = ?", "https://example.com/soup"},
+		{"users", "public_handle = ?", "gone-cook"},
+	} {
+		var count int64
+		if err := repo.db.Table(check.table).Where(check.where, check.arg).Count(&count).Error; err != nil {
+			t.Fatalf("count %s: %v", check.table, err)
+		}
+		if count != 0 {
+			t.Errorf("%d %s rows left where %s", count, check.table, check.where)
+		}
+	}
+
+	var kept int64
+	if err := repo.db.Table("ai_usage").Count(&kept).Error; err != nil {
+		t.Fatalf("count ai usage: %v", err)
+	}
+	if kept != 1 {
+		t.Fatalf("ai_usage rows = %d, want the other user's row kept", kept)
+	}
+}
+
+func TestUsersToFreezeForInactivityNeedsAnEarlyWarning(t *testing.T) {
+	repo := newTestRepo(t)
+	now := time.Now()
+	idle := now.AddDate(0, -7, 0)
+	for username, warnedAt := range map[string]any{
+		"unwarned@example.com":    nil,
+		"just-warned@example.com": now.Add(-time.Hour),
+		"warned@example.com":      now.AddDate(0, 0, -20),
+	} {
+		userID := createTestUser(t, repo, username)
+		if err := repo.db.Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+			"last_active_at":       idle,
+			"inactivity_warned_at": warnedAt,
+		}).Error; err != nil {
+			t.Fatalf("set activity for %s: %v", username, err)
+		}
+	}
+
+	users, err := repo.UsersToFreezeForInactivity(now.AddDate(0, -6, 0), now.AddDate(0, 0, -14))
+	if err != nil {
+		t.Fatalf("freeze lookup: %v", err)
+	}
+	if len(users) != 1 || users[0].Username != "warned@example.com" {
+		names := make([]string, 0, len(users))
+		for _, user := range users {
+			names = append(names, user.Username)
+		}
+		t.Fatalf("users to freeze = %v, want only the one warned 20 days ago", names)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// RecipeIngredientModel is a normalized ingredient name per recipe, used for
+// "what can I make with..." lookups without scanning the JSON columns.
+type RecipeIngredientModel struct {
+	ID       uint   `gorm:"primaryKey"`
+	RecipeID uint   `gorm:"column:recipe_id;not null;index"`
+	UserID   uint   `gorm:"column:user_id;not null;index:idx_recipe_ingredients_user_name"`
+	Name     string `gorm:"column:name;not null;index:idx_recipe_ingredients_user_name"`
+}
+
+func (RecipeIngredientModel) TableName() string {
+	return "recipe_ingredients"
+}
+
+func isIngredientTableMissing(err error) bool {
+	return isNoSuchTableError(err) && strings.Contains(strings.ToLower(err.Error()), "recipe_ingredients")
+}
+
+// normalizeIngredientName reduces an ingredient line such as
+// "2 cups chopped onion (about 1 large), divided" to "chopped onion".
+func normalizeIngredientName(raw string) string {
+	s := strings.ToLower(strings.TrimSpace(raw))
+	if s == "" {
+		return ""
+	}
+
+	// Drop parentheticals
+	var b strings.Builder
+	depth := 0
+	for _, r := range s {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')':
+			if depth > 0 {
+				depth--
+			}
+		case depth == 0:
+			b.WriteRune(r)
+		}
+	}
+	s = b.String()
+
+	if idx := strings.Index(s, ","); idx >= 0 {
+		s = s[:idx]
+	}
+
+	// Strip leading quantities like "1", "1/2", "1.5", "½", "2-3"
+	fields := strings.Fields(s)
+	start := 0
+	for start < len(fields) && isQuantityToken(fields[start]) {
+		start++
+	}
+	s = strings.Join(fields[start:], " ")
+
+	if _, remain := extractUnitFromDescription(s); remain != "" {
+		s = remain
+	}
+
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func isQuantityToken(token string) bool {
+	if token == "" {
+		return false
+	}
+	for _, r := range token {
+		if unicode.IsDigit(r) || r == '/' || r == '.' || r == '-' || unicode.Is(unicode.No, r) {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// ingredientNamesForModel returns the de-duplicated normalized ingredient names
+// of a stored recipe, preferring parsed descriptions over raw lines.
+func ingredientNamesForModel(model RecipeModel) []string {
+	sources := make([]string, 0)
+	if strings.TrimSpace(model.ParsedJSON) != "" {
+		var parsed []IngredientDetail
+		if err := json.Unmarshal([]byte(model.ParsedJSON), &parsed); err == nil {
+			for _, d := range parsed {
+				sources = append(sources, d.Description)
+			}
+		}
+	}
+	if len(sources) == 0 && strings.TrimSpace(model.Ingredients) != "" {
+		var raw []string
+		if err := json.Unmarshal([]byte(model.Ingredients), &raw); err == nil {
+			sources = append(sources, raw...)
+		}
+	}
+
+	seen := make(map[string]struct{}, len(sources))
+	names := make([]string, 0, len(sources))
+	for _, src := range sources {
+		name := normalizeIngredientName(src)
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		names = append(names, name)
+	}
+	return names
+}
+
+func replaceRecipeIngredients(db *gorm.DB, model RecipeModel) error {
+	if err := db.Where("recipe_id = ?", model.ID).Delete(&RecipeIngredientModel{}).Error; err != nil {
+		return err
+	}
+	names := ingredientNamesForModel(model)
+	if len(names) == 0 {
+		return nil
+	}
+	rows := make([]RecipeIngredientModel, 0, len(names))
+	for _, name := range names {
+		rows = append(rows, RecipeIngredientModel{RecipeID: model.ID, UserID: model.UserID, Name: name})
+	}
+	return db.Create(&rows).Error
+}
+
+func indexRecipeIngredients(db *gorm.DB, model RecipeModel) error {
+	if model.ID == 0 {
+		return nil
+	}
+	if err := replaceRecipeIngredients(db, model); err != nil && !isIngredientTableMissing(err) {
+		return fmt.Errorf("index ingredients for recipe %d: %w", model.ID, err)
+	}
+	return nil
+}
+
+func (r *RecipeRepository) rebuildIngredientIndex() error {
+	var models []RecipeModel
+	return r.db.Select("id", "user_id", "ingredients", "parsed_ingredients").
+		FindInBatches(&models, 200, func(tx *gorm.DB, batch int) error {
+			for _, model := range models {
+				if err := replaceRecipeIngredients(r.db, model); err != nil {
+					return fmt.Errorf("index ingredients for recipe %d: %w", model.ID, err)
+				}
+			}
+			return nil
+		}).Error
+}
+
+// parseIngredientFilter splits "chicken, Thyme" into normalized terms.
+func parseIngredientFilter(raw string) []string {
+	terms := make([]string, 0)
+	for _, part := range strings.Split(raw, ",") {
+		if term := strings.ToLower(strings.TrimSpace(part)); term != "" {
+			terms = append(terms, term)
+		}
+	}
+	return terms
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const planDateLayout = "2006-01-02"
+
+var mealSlots = []string{"breakfast", "lunch", "dinner", "snack"}
+
+// PlannedMealModel schedules one of the user's recipes on a day.
+type PlannedMealModel struct {
+	ID         uint      `gorm:"primaryKey"`
+	UserID     uint      `gorm:"column:user_id;not null;index"`
+	RecipeID   uint      `gorm:"column:recipe_id;not null;index"`
+	PlannedFor string    `gorm:"column:planned_for;not null"`
+	Meal       string    `gorm:"column:meal"`
+	Note       string    `gorm:"column:note"`
+	CreatedAt  time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (PlannedMealModel) TableName() string {
+	return "planned_meals"
+}
+
+type PlannedMeal struct {
+	ID       uint   `json:"id"`
+	RecipeID uint   `json:"recipeId"`
+	Title    string `json:"title"`
+	Image    string `json:"image,omitempty"`
+	Date     string `json:"date"`
+	Meal     string `json:"meal,omitempty"`
+	Note     string `json:"note,omitempty"`
+	// OriginalURL is carried for calendar events
+	OriginalURL string `json:"originalURL,omitempty"`
+}
+
+// normalizeMealSlot accepts an empty slot or one of mealSlots.
+func normalizeMealSlot(meal string) (string, bool) {
+	meal = strings.ToLower(strings.TrimSpace(meal))
+	if meal == "" {
+		return "", true
+	}
+	for _, slot := range mealSlots {
+		if meal == slot {
+			return meal, true
+		}
+	}
+	return "", false
+}
+
+func (r *RecipeRepository) AddPlannedMeal(username string, recipeID uint, date time.Time, meal, note string) (PlannedMeal, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return PlannedMeal{}, err
+	}
+
+	var recipe RecipeModel
+	if err := r.db.Select("id", "title", "image", "original_url").
+		Where("user_id = ? AND id = ?", userID, recipeID).First(&recipe).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return PlannedMeal{}, sql.ErrNoRows
+		}
+		return PlannedMeal{}, fmt.Errorf("lookup recipe: %w", err)
+	}
+
+	model := PlannedMealModel{
+		UserID:     userID,
+		RecipeID:   recipe.ID,
+		PlannedFor: date.Format(planDateLayout),
+		Meal:       meal,
+		Note:       strings.TrimSpace(note),
+	}
+	if err := r.db.Create(&model).Error; err != nil {
+		return PlannedMeal{}, fmt.Errorf("create planned meal: %w", err)
+	}
+
+	return PlannedMeal{
+		ID:          model.ID,
+		RecipeID:    recipe.ID,
+		Title:       recipe.Title,
+		Image:       recipe.Image,
+		Date:        model.PlannedFor,
+		Meal:        model.Meal,
+		Note:        model.Note,
+		OriginalURL: recipe.OriginalURL,
+	}, nil
+}
+
+// ListPlannedMeals returns the user's planned meals between from and to
+// (inclusive, by day), ordered by date and meal slot.
+func (r *RecipeRepository) ListPlannedMeals(username string, from, to time.Time) ([]PlannedMeal, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []PlannedMeal
+	err = r.db.Table("planned_meals").
+		Select("planned_meals.id, planned_meals.recipe_id, recipes.title, recipes.image, planned_meals.planned_for AS date, planned_meals.meal, planned_meals.note, recipes.original_url").
+		Joins("JOIN recipes ON recipes.id = planned_meals.recipe_id").
+		Where("planned_meals.user_id = ? AND planned_meals.planned_for BETWEEN ? AND ?",
+			userID, from.Format(planDateLayout), to.Format(planDateLayout)).
+		Order("planned_meals.planned_for, CASE planned_meals.meal WHEN 'breakfast' THEN 1 WHEN 'lunch' THEN 2 WHEN 'dinner' THEN 3 WHEN 'snack' THEN 4 ELSE 5 END, planned_meals.id").
+		Scan(&rows).Error
+	if err != nil {
+		if isNoSuchTableError(err) {
+			return []PlannedMeal{}, nil
+		}
+		return nil, fmt.Errorf("list planned meals: %w", err)
+	}
+	if rows == nil {
+		rows = []PlannedMeal{}
+	}
+	return rows, nil
+}
+
+func (r *RecipeRepository) DeletePlannedMeal(username string, id uint) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	result := r.db.Where("user_id = ? AND id = ?", userID, id).Delete(&PlannedMealModel{})
+	if result.Error != nil {
+		return fmt.Errorf("delete planned meal: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// RotateFeedToken issues a new secret for the user's subscribable feeds,
+// replacing any earlier one. Only its hash is stored, so the caller must show
+// it to the user now.
+func (r *RecipeRepository) RotateFeedToken(username string) (string, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return "", err
+	}
+
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", fmt.Errorf("generate token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(tokenBytes)
+
+	if err := r.db.Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+		"feed_token_hash":    feedTokenHash(token),
+		"feed_token_used_at": time.Now().UTC(),
+	}).Error; err != nil {
+		return "", fmt.Errorf("save feed token: %w", err)
+	}
+	return token, nil
+}
+
+func (r *RecipeRepository) RevokeFeedToken(username string) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+	if err := r.db.Model(&UserModel{}).Where("id = ?", userID).Updates(map[string]any{
+		"feed_token_hash":    nil,
+		"feed_token_used_at": nil,
+	}).Error; err != nil {
+		return fmt.Errorf("revoke feed token: %w", err)
+	}
+	return nil
+}
+
+// UsernameForFeedToken resolves a feed token to its owner, or sql.ErrNoRows.
+// The token's last use is recorded at most once a day, for the retention
+// cleanup of unused tokens.
+func (r *RecipeRepository) UsernameForFeedToken(token string) (string, error) {
+	if strings.TrimSpace(token) == "" {
+		return "", sql.ErrNoRows
+	}
+
+	var user UserModel
+	if err := r.db.Select("id", "username", "feed_token_used_at").Where("feed_token_hash = ?", feedTokenHash(token)).
+		First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", sql.ErrNoRows
+		}
+		return "", fmt.Errorf("lookup feed token: %w", err)
+	}
+	now := time.Now().UTC()
+	if user.FeedTokenUsedAt == nil || now.Sub(*user.FeedTokenUsedAt) > feedTokenUseInterval {
+		if err := r.db.Model(&UserModel{}).Where("id = ?", user.ID).
+			Update("feed_token_used_at", now).Error; err != nil {
+			log.Printf("Feed: failed to record token use for %s: %v", user.Username, err)
+		}
+	}
+	return user.Username, nil
+}
+
+func feedTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Every query is timed under the RecipeRepository method that ran it, so
+// recipes_db_query_duration_seconds shows which repository calls dominate
+// latency. Queries slower than DB_SLOW_QUERY are logged and counted.
+
+var (
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "recipes_db_query_duration_seconds",
+		Help:    "Latency of database queries, by repository method and result.",
+		Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5},
+	}, []string{"call", "result"})
+	dbSlowQueriesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "recipes_db_slow_queries_total",
+		Help: "Database queries slower than DB_SLOW_QUERY, by repository method.",
+	}, []string{"call"})
+)
+
+// gormConfig is the configuration both database drivers open with.
+// Statements are prepared once per connection and reused unless
+// DB_PREPARE_STMT=false, and queries are traced under the span that made
+// them.
+func gormConfig(cfg DatabaseConfig) *gorm.Config {
+	return &gorm.Config{
+		PrepareStmt: cfg.PrepareStmt,
+		Logger:      newQueryLogger(cfg.SlowQuery),
+		Plugins:     map[string]gorm.Plugin{queryTracing{}.Name(): queryTracing{}},
+	}
+}
+
+// queryLogger is gorm's logger with query metrics. A slow threshold of 0
+// turns the slow query log and counter off.
+type queryLogger struct {
+	logger.Interface
+	slow time.Duration
+}
+
+func newQueryLogger(slow time.Duration) queryLogger {
+	return queryLogger{
+		Interface: logger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), logger.Config{
+			SlowThreshold:             slow,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+			Colorful:                  true,
+		}),
+		slow: slow,
+	}
+}
+
+func (l queryLogger) LogMode(level logger.LogLevel) logger.Interface {
+	l.Interface = l.Interface.LogMode(level)
+	return l
+}
+
+func (l queryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	elapsed := time.Since(begin)
+	call := repositoryCall()
+	result := "ok"
+	if err != nil && !errors.Is(err, logger.ErrRecordNotFound) {
+		result = "error"
+	}
+	dbQueryDuration.WithLabelValues(call, result).Observe(elapsed.Seconds())
+	if l.slow > 0 && elapsed > l.slow {
+		dbSlowQueriesTotal.WithLabelValues(call).Inc()
+	}
+	l.Interface.Trace(ctx, begin, fc, err)
+}
+
+// repositoryCall names the outermost RecipeRepository method running the
+// current query, so a helper's queries count toward the call that used
+// it, or "other" for queries made outside the repository.
+func repositoryCall() string {
+	var pcs [64]uintptr
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs[:])])
+	call := "other"
+	for {
+		frame, more := frames.Next()
+		if _, method, ok := strings.Cut(frame.Function, ".(*RecipeRepository)."); ok {
+			// Closures, such as a transaction body, belong to their method
+			call, _, _ = strings.Cut(method, ".")
+		}
+		if !more {
+			return call
+		}
+	}
+}
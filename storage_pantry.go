@@ -0,0 +1,69 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// PantryRecipes returns up to limit of the user's recipes with at least
+// minCoverage of their ingredients on hand and at least one of them from
+// the pantry rather than the staples, most complete first.
+func (r *RecipeRepository) PantryRecipes(username string, p pantry, minCoverage float64, limit int) ([]PantrySuggestion, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	names, err := r.userIngredientNames(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := make(map[uint]PantrySuggestion)
+	ids := make([]uint, 0)
+	for id, recipeNames := range names {
+		match := p.match(recipeNames)
+		if len(match.Matched) == 0 || match.Coverage < minCoverage {
+			continue
+		}
+		matches[id] = match
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return []PantrySuggestion{}, nil
+	}
+
+	var models []RecipeModel
+	if err := r.db.Select("id", "title", "image").
+		Where("id IN ? AND user_id = ?", ids, userID).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("load pantry recipes: %w", err)
+	}
+
+	suggestions := make([]PantrySuggestion, 0, len(models))
+	for _, model := range models {
+		suggestion := matches[model.ID]
+		suggestion.Source = "collection"
+		suggestion.RecipeID = model.ID
+		suggestion.Title = model.Title
+		suggestion.Image = model.Image
+		suggestions = append(suggestions, suggestion)
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		if suggestions[i].Coverage != suggestions[j].Coverage {
+			return suggestions[i].Coverage > suggestions[j].Coverage
+		}
+		if len(suggestions[i].Missing) != len(suggestions[j].Missing) {
+			return len(suggestions[i].Missing) < len(suggestions[j].Missing)
+		}
+		return suggestions[i].RecipeID > suggestions[j].RecipeID
+	})
+	if limit > 0 && len(suggestions) > limit {
+		suggestions = suggestions[:limit]
+	}
+	return suggestions, nil
+}
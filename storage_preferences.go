@@ -0,0 +1,137 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// UserPreferences is stored as JSON on the users row.
+type UserPreferences struct {
+	Equipment []string `json:"equipment"`
+	// Allergens are excluded from list and search results automatically
+	Allergens []string `json:"allergens"`
+}
+
+// PreferencesUpdate is a PUT /profile/preferences body; a field left out
+// keeps its saved value, while an empty list clears it.
+type PreferencesUpdate struct {
+	Equipment *[]string `json:"equipment"`
+	Allergens *[]string `json:"allergens"`
+}
+
+func (r *RecipeRepository) GetUserPreferences(username string) (UserPreferences, error) {
+	if username == "" {
+		return UserPreferences{}, errors.New("username is required")
+	}
+
+	var user UserModel
+	if err := r.db.Select("id", "preferences").Where("username = ?", username).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return UserPreferences{}, sql.ErrNoRows
+		}
+		return UserPreferences{}, fmt.Errorf("lookup user: %w", err)
+	}
+
+	prefs := UserPreferences{Equipment: []string{}, Allergens: []string{}}
+	if strings.TrimSpace(user.PreferencesJSON) != "" {
+		if err := json.Unmarshal([]byte(user.PreferencesJSON), &prefs); err != nil {
+			return UserPreferences{}, fmt.Errorf("unmarshal preferences: %w", err)
+		}
+	}
+	return prefs, nil
+}
+
+// SaveUserPreferences merges the fields set in update into the saved
+// preferences and returns the result.
+func (r *RecipeRepository) SaveUserPreferences(username string, update PreferencesUpdate) (UserPreferences, error) {
+	var prefs UserPreferences
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		saved, err := (&RecipeRepository{db: tx}).GetUserPreferences(username)
+		if err != nil {
+			return err
+		}
+		prefs = saved
+		if update.Equipment != nil {
+			prefs.Equipment = *update.Equipment
+		}
+		if update.Allergens != nil {
+			prefs.Allergens = *update.Allergens
+		}
+		prefs.Equipment = normalizeTerms(prefs.Equipment)
+		prefs.Allergens = normalizeTerms(prefs.Allergens)
+
+		data, err := json.Marshal(prefs)
+		if err != nil {
+			return fmt.Errorf("marshal preferences: %w", err)
+		}
+		if err := tx.Model(&UserModel{}).Where("username = ?", username).
+			Update("preferences", string(data)).Error; err != nil {
+			return fmt.Errorf("save preferences: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return UserPreferences{}, err
+	}
+	return prefs, nil
+}
+
+// normalizeTerms lowercases, trims, and de-duplicates a list of names.
+func normalizeTerms(items []string) []string {
+	seen := make(map[string]struct{}, len(items))
+	out := make([]string, 0, len(items))
+	for _, item := range items {
+		name := strings.ToLower(strings.TrimSpace(item))
+		if name == "" {
+			continue
+		}
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+		out = append(out, name)
+	}
+	return out
+}
+
+// filterRecipesByEquipment keeps recipes whose required equipment is all owned.
+// Owned equipment covers a need when the names are equal or the needed name
+// appears as whole words in the owned one: a "stand mixer" covers "mixer",
+// but owning a "mixer" doesn't cover a "stand mixer".
+func filterRecipesByEquipment(recipes []Recipe, owned []string) []Recipe {
+	owned = normalizeTerms(owned)
+	filtered := make([]Recipe, 0, len(recipes))
+	for _, recipe := range recipes {
+		ok := true
+		for _, needed := range normalizeTerms(recipe.Equipment) {
+			if !equipmentOwned(needed, owned) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			filtered = append(filtered, recipe)
+		}
+	}
+	return filtered
+}
+
+func equipmentOwned(needed string, owned []string) bool {
+	for _, have := range owned {
+		if have == needed || containsWords(have, needed) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsWords reports whether the words of part appear consecutively in
+// whole, so "pan" matches "frying pan" but not "saucepan".
+func containsWords(whole, part string) bool {
+	return strings.Contains(" "+strings.Join(strings.Fields(whole), " ")+" ", " "+strings.Join(strings.Fields(part), " ")+" ")
+}
@@ -0,0 +1,68 @@
+package main
+
+import "testing"
+
+func TestEquipmentOwnedMatchesWholeWordsOneWay(t *testing.T) {
+	cases := []struct {
+		needed string
+		owned  []string
+		want   bool
+	}{
+		{"mixer", []string{"mixer"}, true},
+		{"mixer", []string{"stand mixer"}, true},
+		{"stand mixer", []string{"mixer"}, false},
+		{"pan", []string{"frying pan"}, true},
+		{"pan", []string{"saucepan"}, false},
+		{"dutch oven", []string{"cast iron dutch oven"}, true},
+		{"oven", []string{}, false},
+	}
+	for _, tc := range cases {
+		if got := equipmentOwned(tc.needed, tc.owned); got != tc.want {
+			t.Errorf("equipmentOwned(%q, %q) = %v, want %v", tc.needed, tc.owned, got, tc.want)
+		}
+	}
+}
+
+func TestFilterRecipesByEquipment(t *testing.T) {
+	recipes := []Recipe{
+		{Title: "Meringue", Equipment: []string{"Mixer"}},
+		{Title: "Bread", Equipment: []string{"Stand Mixer", "Oven"}},
+	}
+
+	got := filterRecipesByEquipment(recipes, []string{"stand mixer", "oven"})
+	if len(got) != 2 {
+		t.Fatalf("with a stand mixer kept %d recipes, want 2", len(got))
+	}
+
+	got = filterRecipesByEquipment(recipes, []string{"mixer", "oven"})
+	if len(got) != 1 || got[0].Title != "Meringue" {
+		t.Fatalf("with a hand mixer kept %+v, want only Meringue", got)
+	}
+}
+
+func TestSaveUserPreferencesKeepsFieldsLeftOut(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	allergens := []string{"Peanuts"}
+	if _, err := repo.SaveUserPreferences("cook@example.com", PreferencesUpdate{Allergens: &allergens}); err != nil {
+		t.Fatalf("save allergens: %v", err)
+	}
+
+	equipment := []string{"Stand Mixer"}
+	prefs, err := repo.SaveUserPreferences("cook@example.com", PreferencesUpdate{Equipment: &equipment})
+	if err != nil {
+		t.Fatalf("save equipment: %v", err)
+	}
+	if len(prefs.Allergens) != 1 || prefs.Allergens[0] != "peanuts" || len(prefs.Equipment) != 1 || prefs.Equipment[0] != "stand mixer" {
+		t.Fatalf("after equipment update = %+v, want the allergens kept", prefs)
+	}
+	if saved, err := repo.GetUserPreferences("cook@example.com"); err != nil || len(saved.Allergens) != 1 {
+		t.Fatalf("saved = %+v, %v, want the allergens kept", saved, err)
+	}
+
+	// An empty list clears the field
+	none := []string{}
+	if prefs, err = repo.SaveUserPreferences("cook@example.com", PreferencesUpdate{Allergens: &none}); err != nil || len(prefs.Allergens) != 0 || len(prefs.Equipment) != 1 {
+		t.Fatalf("after clearing allergens = %+v, %v", prefs, err)
+	}
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+var (
+	publicHandlePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,28}[a-z0-9]$`)
+
+	errInvalidHandle = errors.New("handle must be 3-30 characters of a-z, 0-9 and inner hyphens")
+	errHandleTaken   = errors.New("handle is already taken")
+)
+
+// SetPublicHandle opts the user into a public cookbook under handle, or out
+// of it when handle is empty. Handles are case-insensitive and unique.
+func (r *RecipeRepository) SetPublicHandle(username, handle string) (string, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return "", err
+	}
+
+	handle = strings.ToLower(strings.TrimSpace(handle))
+	var value any
+	if handle != "" {
+		if !publicHandlePattern.MatchString(handle) {
+			return "", errInvalidHandle
+		}
+		value = handle
+	}
+
+	if err := r.db.Model(&UserModel{}).Where("id = ?", userID).
+		Update("public_handle", value).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(strings.ToLower(err.Error()), "unique") {
+			return "", errHandleTaken
+		}
+		return "", fmt.Errorf("save public handle: %w", err)
+	}
+	return handle, nil
+}
+
+func (r *RecipeRepository) SetRecipePublicByID(username string, recipeID uint, public bool) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	result := r.db.Model(&RecipeModel{}).Where("id = ? AND user_id = ?", recipeID, userID).
+		Update("is_public", public)
+	if result.Error != nil {
+		return fmt.Errorf("update recipe visibility: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *RecipeRepository) userIDForHandle(handle string) (uint, error) {
+	handle = strings.ToLower(strings.TrimSpace(handle))
+	if handle == "" {
+		return 0, sql.ErrNoRows
+	}
+
+	var user UserModel
+	if err := r.db.Select("id").Where("public_handle = ?", handle).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, sql.ErrNoRows
+		}
+		return 0, fmt.Errorf("lookup public handle: %w", err)
+	}
+	return user.ID, nil
+}
+
+// PublicRecipes lists the recipes published under handle, newest first,
+// with the total count for pagination.
+func (r *RecipeRepository) PublicRecipes(handle string, page Pagination) ([]Recipe, int64, error) {
+	userID, err := r.userIDForHandle(handle)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	query := r.db.Model(&RecipeModel{}).Where("user_id = ? AND is_public = ?", userID, true)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("count public recipes: %w", err)
+	}
+
+	var models []RecipeModel
+	if err := query.Order("created_at DESC, id DESC").Limit(page.Limit).Offset(page.Offset).
+		Find(&models).Error; err != nil {
+		return nil, 0, fmt.Errorf("list public recipes: %w", err)
+	}
+
+	recipes := make([]Recipe, 0, len(models))
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err != nil {
+			return nil, 0, err
+		}
+		recipes = append(recipes, publicRecipe(recipe))
+	}
+	return recipes, total, nil
+}
+
+func (r *RecipeRepository) PublicRecipeByID(handle string, recipeID uint) (Recipe, error) {
+	userID, err := r.userIDForHandle(handle)
+	if err != nil {
+		return Recipe{}, err
+	}
+
+	var model RecipeModel
+	if err := r.db.Where("id = ? AND user_id = ? AND is_public = ?", recipeID, userID, true).
+		First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Recipe{}, sql.ErrNoRows
+		}
+		return Recipe{}, fmt.Errorf("lookup public recipe: %w", err)
+	}
+
+	recipe, err := model.toRecipe()
+	if err != nil {
+		return Recipe{}, err
+	}
+	return publicRecipe(recipe), nil
+}
+
+// publicRecipe drops the owner's personal fields before a recipe is shown
+// to anonymous visitors.
+func publicRecipe(recipe Recipe) Recipe {
+	recipe.IsFavorite = false
+	recipe.LastCookedAt = nil
+	recipe.Link = ""
+	return recipe
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RecipeChatMessageModel is one question or answer of a conversation about
+// a recipe.
+type RecipeChatMessageModel struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"column:user_id;not null;index"`
+	RecipeID  uint      `gorm:"column:recipe_id;not null;index"`
+	Role      string    `gorm:"column:role;not null"`
+	Content   string    `gorm:"column:content;not null"`
+	CreatedAt time.Time `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (RecipeChatMessageModel) TableName() string {
+	return "recipe_chat_messages"
+}
+
+type RecipeChatMessage struct {
+	ID        uint      `json:"id"`
+	Role      string    `json:"role"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// GetRecipeChat returns the latest limit messages about the user's recipe,
+// oldest first.
+func (r *RecipeRepository) GetRecipeChat(username string, recipeID uint, limit int) ([]RecipeChatMessage, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []RecipeChatMessageModel
+	if err := r.db.Where("user_id = ? AND recipe_id = ?", userID, recipeID).
+		Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		if isNoSuchTableError(err) {
+			return []RecipeChatMessage{}, nil
+		}
+		return nil, fmt.Errorf("list recipe chat: %w", err)
+	}
+
+	messages := make([]RecipeChatMessage, len(rows))
+	for i, row := range rows {
+		messages[len(rows)-1-i] = RecipeChatMessage{ID: row.ID, Role: row.Role, Content: row.Content, CreatedAt: row.CreatedAt}
+	}
+	return messages, nil
+}
+
+// AppendRecipeChat stores a question and its answer about the user's recipe.
+func (r *RecipeRepository) AppendRecipeChat(username string, recipeID uint, question, answer string) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var recipe RecipeModel
+		if err := tx.Select("id").Where("user_id = ? AND id = ?", userID, recipeID).First(&recipe).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return sql.ErrNoRows
+			}
+			return fmt.Errorf("lookup recipe: %w", err)
+		}
+		rows := []RecipeChatMessageModel{
+			{UserID: userID, RecipeID: recipeID, Role: "user", Content: question},
+			{UserID: userID, RecipeID: recipeID, Role: "assistant", Content: answer},
+		}
+		if err := tx.Create(&rows).Error; err != nil {
+			return fmt.Errorf("save recipe chat: %w", err)
+		}
+		return nil
+	})
+}
+
+// ClearRecipeChat forgets the conversation about the user's recipe.
+func (r *RecipeRepository) ClearRecipeChat(username string, recipeID uint) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	if err := r.db.Where("user_id = ? AND recipe_id = ?", userID, recipeID).
+		Delete(&RecipeChatMessageModel{}).Error; err != nil && !isNoSuchTableError(err) {
+		return fmt.Errorf("clear recipe chat: %w", err)
+	}
+	return nil
+}
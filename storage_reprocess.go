@@ -0,0 +1,94 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// IncompleteRecipe is a recipe due to be imported again from its page.
+type IncompleteRecipe struct {
+	ID       uint
+	Username string
+	URL      string
+	Attempts int
+}
+
+// IncompleteRecipesDue returns up to limit recipes failing recipeIsComplete
+// that came from a web page, belong to an active account, have had fewer
+// than maxAttempts reprocessing attempts and whose backoff has passed (or
+// any backoff, with force).
+func (r *RecipeRepository) IncompleteRecipesDue(now time.Time, maxAttempts, limit int, force bool) ([]IncompleteRecipe, error) {
+	query := r.db.Model(&RecipeModel{}).
+		Select("recipes.*").
+		Joins("JOIN users u ON u.id = recipes.user_id").
+		Where("u.frozen_at IS NULL").
+		Where("(recipes.original_url LIKE 'http://%' OR recipes.original_url LIKE 'https://%')").
+		Where("recipes.reprocess_attempts < ?", maxAttempts).
+		// A cheap filter; recipeIsComplete has the last word
+		Where("(COALESCE(recipes.ingredients, '') IN ('', '[]', 'null') OR recipes.instructions IN ('', '[]', 'null') OR TRIM(recipes.title) = '')")
+	if !force {
+		query = query.Where("(recipes.reprocess_after IS NULL OR recipes.reprocess_after <= ?)", now)
+	}
+
+	var models []RecipeModel
+	if err := query.Order("recipes.reprocess_attempts ASC").Order("recipes.id ASC").Limit(limit).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("find incomplete recipes: %w", err)
+	}
+
+	usernames := make(map[uint]string)
+	due := make([]IncompleteRecipe, 0, len(models))
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err == nil && recipeIsComplete(recipe) {
+			continue
+		}
+		username, ok := usernames[model.UserID]
+		if !ok {
+			var user UserModel
+			if err := r.db.Select("username").First(&user, model.UserID).Error; err != nil {
+				return nil, fmt.Errorf("get recipe owner: %w", err)
+			}
+			username = user.Username
+			usernames[model.UserID] = username
+		}
+		due = append(due, IncompleteRecipe{ID: model.ID, Username: username, URL: model.OriginalURL, Attempts: model.ReprocessAttempts})
+	}
+	return due, nil
+}
+
+// ScheduleRecipeReprocess records a reprocessing attempt and when the next
+// may be.
+func (r *RecipeRepository) ScheduleRecipeReprocess(recipeID uint, attempts int, next time.Time) error {
+	if err := r.db.Model(&RecipeModel{}).Where("id = ?", recipeID).
+		UpdateColumns(map[string]any{"reprocess_attempts": attempts, "reprocess_after": next}).Error; err != nil {
+		return fmt.Errorf("schedule recipe reprocess: %w", err)
+	}
+	return nil
+}
+
+// DeleteIncompleteRecipesForURL removes the user's incomplete recipes from
+// recipeURL other than keepSlug, once a later import of the page has
+// succeeded under another slug. It returns how many were removed.
+func (r *RecipeRepository) DeleteIncompleteRecipesForURL(username, recipeURL, keepSlug string) (int, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return 0, err
+	}
+	var models []RecipeModel
+	if err := r.db.Where("user_id = ? AND original_url = ? AND slug <> ?", userID, recipeURL, keepSlug).
+		Find(&models).Error; err != nil {
+		return 0, fmt.Errorf("find recipes for url: %w", err)
+	}
+	removed := 0
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err == nil && recipeIsComplete(recipe) {
+			continue
+		}
+		if err := r.DeleteRecipeByID(username, model.ID); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
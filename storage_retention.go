@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// deleteInBatches deletes the rows of table matching where, a batch at a
+// time, and returns how many it deleted. idColumn picks the batch on
+// SQLite; MySQL can limit a DELETE itself.
+func (r *RecipeRepository) deleteInBatches(table, idColumn, where string, args ...any) (int64, error) {
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s IN (SELECT %s FROM %s WHERE %s LIMIT %d)",
+		table, idColumn, idColumn, table, where, retentionDeleteBatch)
+	if isMySQL(r.db) {
+		query = fmt.Sprintf("DELETE FROM %s WHERE %s LIMIT %d", table, where, retentionDeleteBatch)
+	}
+	var total int64
+	for {
+		result := r.db.Exec(query, args...)
+		if result.Error != nil {
+			return total, fmt.Errorf("prune %s: %w", table, result.Error)
+		}
+		total += result.RowsAffected
+		if result.RowsAffected < retentionDeleteBatch {
+			return total, nil
+		}
+	}
+}
+
+// PruneFinishedQueue deletes queue items imported or cancelled before
+// before. Failed items stay listed under /queue/failed.
+func (r *RecipeRepository) PruneFinishedQueue(before time.Time) (int64, error) {
+	return r.deleteInBatches("queue", "id", "processed_at IS NOT NULL AND failed_at IS NULL AND processed_at < ?", before.UTC())
+}
+
+// PruneFailedQueue deletes queue items that failed for good before before.
+func (r *RecipeRepository) PruneFailedQueue(before time.Time) (int64, error) {
+	return r.deleteInBatches("queue", "id", "failed_at IS NOT NULL AND failed_at < ?", before.UTC())
+}
+
+// FailedQueueBefore returns up to limit queue items that failed for good
+// before before, oldest first, without the saved page HTML.
+func (r *RecipeRepository) FailedQueueBefore(before time.Time, limit int) ([]QueueModel, error) {
+	var items []QueueModel
+	if err := r.db.Omit("page_html").
+		Where("failed_at IS NOT NULL AND failed_at < ?", before.UTC()).
+		Order("failed_at, id").Limit(limit).Find(&items).Error; err != nil {
+		return nil, fmt.Errorf("find failed queue items: %w", err)
+	}
+	return items, nil
+}
+
+// DeleteQueueItems deletes the queue items with the given ids.
+func (r *RecipeRepository) DeleteQueueItems(ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.Where("id IN ?", ids).Delete(&QueueModel{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("delete queue items: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// PruneExpiredPasswordResets deletes reset tokens used or expired before
+// before, and any left behind by a deleted account.
+func (r *RecipeRepository) PruneExpiredPasswordResets(before time.Time) (int64, error) {
+	return r.deleteInBatches("password_resets", "id",
+		"used_at < ? OR expires_at < ? OR user_id NOT IN (SELECT id FROM users)", before.UTC(), before.UTC())
+}
+
+// UnusedAccounts returns the accounts registered before before that were
+// never signed in to.
+func (r *RecipeRepository) UnusedAccounts(before time.Time) ([]UserModel, error) {
+	var users []UserModel
+	if err := r.db.Where("last_active_at IS NULL AND created_at < ?", before.UTC()).
+		Order("id").Find(&users).Error; err != nil {
+		return nil, fmt.Errorf("find unused accounts: %w", err)
+	}
+	return users, nil
+}
+
+// RevokeUnusedFeedTokens revokes the feed tokens last issued or used
+// before before.
+func (r *RecipeRepository) RevokeUnusedFeedTokens(before time.Time) (int64, error) {
+	result := r.db.Model(&UserModel{}).
+		Where("feed_token_hash IS NOT NULL AND (feed_token_used_at IS NULL OR feed_token_used_at < ?)", before.UTC()).
+		Updates(map[string]any{"feed_token_hash": nil, "feed_token_used_at": nil})
+	if result.Error != nil {
+		return 0, fmt.Errorf("revoke unused feed tokens: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// PruneExpiredIdempotencyKeys deletes stored responses created before
+// before, which are no longer replayed.
+func (r *RecipeRepository) PruneExpiredIdempotencyKeys(before time.Time) (int64, error) {
+	return r.deleteInBatches("idempotency_keys", "rowid", "created_at < ?", before.UTC())
+}
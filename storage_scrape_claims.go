@@ -0,0 +1,40 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+type ScrapeClaimModel struct {
+	URL       string    `gorm:"column:url;primaryKey"`
+	Owner     string    `gorm:"column:owner;not null"`
+	ExpiresAt time.Time `gorm:"column:expires_at;not null"`
+}
+
+func (ScrapeClaimModel) TableName() string {
+	return "scrape_claims"
+}
+
+// ClaimScrape takes the claim on pageURL for owner unless another owner
+// holds an unexpired one. It reports whether owner now holds the claim.
+func (r *RecipeRepository) ClaimScrape(pageURL, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now().UTC()
+	claim := ScrapeClaimModel{URL: pageURL, Owner: owner, ExpiresAt: now.Add(ttl)}
+	result := r.db.Clauses(upsertIf(r.db,
+		[]string{"url"},
+		[]string{"owner", "expires_at"},
+		"scrape_claims.expires_at < ? OR scrape_claims.owner = ?", now, owner,
+	)).Create(&claim)
+	if result.Error != nil {
+		return false, fmt.Errorf("claim scrape: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseScrape drops owner's claim on pageURL.
+func (r *RecipeRepository) ReleaseScrape(pageURL, owner string) error {
+	if err := r.db.Where("url = ? AND owner = ?", pageURL, owner).Delete(&ScrapeClaimModel{}).Error; err != nil {
+		return fmt.Errorf("release scrape: %w", err)
+	}
+	return nil
+}
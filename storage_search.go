@@ -0,0 +1,316 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// recipe_search is an FTS5 table keyed by recipe id (rowid). It is maintained
+// by the repository on every recipe write rather than by triggers, so a binary
+// built without FTS5 still saves recipes and search falls back to LIKE, as it
+// always does on MySQL.
+
+func isSearchIndexUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := strings.ToLower(err.Error())
+	return isNoSuchTableError(err) || strings.Contains(msg, "no such module") || strings.Contains(msg, "fts5")
+}
+
+// indexRecipe replaces the search rows for a recipe. It runs on the
+// transaction that wrote the recipe, so a failure is returned and rolls the
+// write back; a database without the index tables is not a failure.
+func indexRecipe(db *gorm.DB, model RecipeModel) error {
+	if model.ID == 0 {
+		return nil
+	}
+	if err := indexRecipeIngredients(db, model); err != nil {
+		return err
+	}
+	if isMySQL(db) {
+		return nil
+	}
+	if err := db.Exec("DELETE FROM recipe_search WHERE rowid = ?", model.ID).Error; err != nil {
+		if isSearchIndexUnavailable(err) {
+			return nil
+		}
+		return fmt.Errorf("clear search index for recipe %d: %w", model.ID, err)
+	}
+	if err := db.Exec(
+		"INSERT INTO recipe_search(rowid, title, ingredients, instructions) VALUES (?, ?, ?, ?)",
+		model.ID, model.Title, model.Ingredients, model.Instructions,
+	).Error; err != nil {
+		return fmt.Errorf("index recipe %d: %w", model.ID, err)
+	}
+	return nil
+}
+
+// unindexRecipe removes a deleted recipe from the FTS table, which can't
+// take part in the foreign keys that clear the recipe's other rows.
+func unindexRecipe(db *gorm.DB, recipeID uint) error {
+	if isMySQL(db) {
+		return nil
+	}
+	if err := db.Exec("DELETE FROM recipe_search WHERE rowid = ?", recipeID).Error; err != nil && !isSearchIndexUnavailable(err) {
+		return fmt.Errorf("remove recipe %d from search index: %w", recipeID, err)
+	}
+	return nil
+}
+
+// EnsureSearchIndex rebuilds the indexes when they are empty but recipes exist,
+// e.g. right after the tables were first created.
+func (r *RecipeRepository) EnsureSearchIndex() error {
+	var recipes int64
+	if err := r.db.Model(&RecipeModel{}).Count(&recipes).Error; err != nil {
+		return fmt.Errorf("count recipes: %w", err)
+	}
+	if recipes == 0 {
+		return nil
+	}
+
+	var ingredientRows int64
+	if err := r.db.Model(&RecipeIngredientModel{}).Count(&ingredientRows).Error; err != nil {
+		if !isIngredientTableMissing(err) {
+			return fmt.Errorf("count ingredient index: %w", err)
+		}
+	} else if ingredientRows == 0 {
+		if err := r.rebuildIngredientIndex(); err != nil {
+			return err
+		}
+	}
+
+	if isMySQL(r.db) {
+		return nil
+	}
+	var indexed int64
+	if err := r.db.Raw("SELECT COUNT(*) FROM recipe_search").Scan(&indexed).Error; err != nil {
+		if isSearchIndexUnavailable(err) {
+			log.Println("Search: FTS5 index unavailable; search will use LIKE matching")
+			return nil
+		}
+		return fmt.Errorf("count search index: %w", err)
+	}
+	if indexed > 0 {
+		return nil
+	}
+	return r.RebuildSearchIndex()
+}
+
+func (r *RecipeRepository) RebuildSearchIndex() error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM recipe_search").Error; err != nil {
+			return fmt.Errorf("clear search index: %w", err)
+		}
+		if err := tx.Exec(`INSERT INTO recipe_search(rowid, title, ingredients, instructions)
+			SELECT id, title, COALESCE(ingredients, ''), instructions FROM recipes`).Error; err != nil {
+			return fmt.Errorf("rebuild search index: %w", err)
+		}
+		return nil
+	})
+}
+
+// buildSearchMatch turns free text into an FTS5 query where every word must
+// match as a prefix, quoting tokens so user input can't inject FTS syntax.
+func buildSearchMatch(term string) string {
+	tokens := searchTokens(term)
+	parts := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		parts = append(parts, fmt.Sprintf("\"%s\"*", token))
+	}
+	return strings.Join(parts, " ")
+}
+
+func isSearchWordRune(r rune) bool {
+	return r == '\'' || r == '-' || (r >= '0' && r <= '9') || (r >= 'a' && r <= 'z') || r > 127
+}
+
+// searchTokens lowercases free text and splits it into the words search matches on.
+func searchTokens(term string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(term), func(r rune) bool {
+		return !isSearchWordRune(r)
+	})
+	tokens := make([]string, 0, len(fields))
+	for _, field := range fields {
+		field = strings.Trim(field, "'-")
+		if field == "" {
+			continue
+		}
+		tokens = append(tokens, field)
+	}
+	return tokens
+}
+
+// RecipeSearchOptions are the filters accepted by SearchRecipes.
+type RecipeSearchOptions struct {
+	Term        string
+	Ingredients []string
+	Filters     RecipeFilters
+	// Page is the slice of the results to return; a zero Limit returns
+	// every match
+	Page Pagination
+}
+
+// RecipeFilters are the structured filters shared by list and search. Zero
+// values mean "no filter".
+type RecipeFilters struct {
+	Category      string
+	FavoritesOnly bool
+	MaxTotalTime  int
+	Tags          []string
+	Diets         []string
+	MinServings   int
+	MaxServings   int
+	// NotCookedSince drops recipes cooked at or after this time
+	NotCookedSince time.Time
+}
+
+func (f RecipeFilters) IsZero() bool {
+	return f.Category == "" && !f.FavoritesOnly && f.MaxTotalTime == 0 &&
+		len(f.Tags) == 0 && len(f.Diets) == 0 && f.MinServings == 0 && f.MaxServings == 0 && f.NotCookedSince.IsZero()
+}
+
+// CacheKey is a stable encoding of the filters for list cache keys.
+func (f RecipeFilters) CacheKey() string {
+	if f.IsZero() {
+		return "all"
+	}
+	return fmt.Sprintf("c=%s;f=%t;t=%d;tags=%s;d=%s;s=%d-%d;nc=%d",
+		f.Category, f.FavoritesOnly, f.MaxTotalTime, strings.Join(f.Tags, ","), strings.Join(f.Diets, ","),
+		f.MinServings, f.MaxServings, f.NotCookedSince.Unix())
+}
+
+// applyRecipeFilters adds the filter conditions to a query over recipes.
+func applyRecipeFilters(query *gorm.DB, userID uint, f RecipeFilters) *gorm.DB {
+	if f.Category != "" {
+		query = query.Where("recipes.category = ?", f.Category)
+	}
+	if f.FavoritesOnly {
+		query = query.Where("EXISTS (SELECT 1 FROM favorites fav WHERE fav.recipe_id = recipes.id AND fav.user_id = ?)", userID)
+	}
+	if f.MaxTotalTime > 0 {
+		query = query.Where("recipes.total_time > 0 AND recipes.total_time <= ?", f.MaxTotalTime)
+	}
+	for _, tag := range f.Tags {
+		query = query.Where(jsonArrayContains(query, "recipes.tags"), tag)
+	}
+	for _, diet := range f.Diets {
+		query = query.Where(jsonArrayContains(query, "recipes.diets"), diet)
+	}
+	if f.MinServings > 0 {
+		query = query.Where("recipes.servings >= ?", f.MinServings)
+	}
+	if f.MaxServings > 0 {
+		query = query.Where("recipes.servings <= ?", f.MaxServings)
+	}
+	if !f.NotCookedSince.IsZero() {
+		query = query.Where("(recipes.last_cooked_at IS NULL OR recipes.last_cooked_at < ?)", f.NotCookedSince.UTC())
+	}
+	return query
+}
+
+// searchMode records which optional index tables a search may use.
+type searchMode struct {
+	fts             bool
+	ingredientTable bool
+}
+
+// scoredRecipeModel is a search row with its relevance score (higher is better).
+type scoredRecipeModel struct {
+	RecipeModel
+	Score float64 `gorm:"column:score"`
+}
+
+// searchRecipeModels runs the search query for the page opts asks for, best
+// match first.
+func (r *RecipeRepository) searchRecipeModels(userID uint, opts RecipeSearchOptions, mode searchMode) ([]scoredRecipeModel, error) {
+	query := r.searchQuery(userID, opts, mode)
+	if opts.Page.Limit > 0 {
+		query = query.Offset(opts.Page.Offset).Limit(opts.Page.Limit)
+	}
+	var models []scoredRecipeModel
+	err := query.Order("recipes.created_at DESC").Find(&models).Error
+	return models, err
+}
+
+// countSearchResults counts every match of the search, ignoring opts.Page.
+func (r *RecipeRepository) countSearchResults(userID uint, opts RecipeSearchOptions, mode searchMode) (int64, error) {
+	var total int64
+	err := r.db.Table("(?) AS matches", r.searchQuery(userID, opts, mode)).Count(&total).Error
+	return total, err
+}
+
+// searchQuery builds the search query, ordered by score. With FTS, bm25
+// weights favour title hits over ingredients, and ingredients over
+// instructions; the LIKE fallback scores by the same field order.
+func (r *RecipeRepository) searchQuery(userID uint, opts RecipeSearchOptions, mode searchMode) *gorm.DB {
+	query := r.db.Table("recipes").
+		Where("recipes.user_id = ?", userID)
+	query = applyRecipeFilters(query, userID, opts.Filters)
+
+	if match := buildSearchMatch(opts.Term); match != "" && mode.fts {
+		// bm25 is negative, more negative for better matches
+		query = query.
+			Select("recipes.*, -bm25(recipe_search, 10.0, 4.0, 1.0) AS score").
+			Joins("JOIN recipe_search ON recipe_search.rowid = recipes.id").
+			Where("recipe_search MATCH ?", match).
+			Order("score DESC")
+	} else if term := strings.TrimSpace(opts.Term); term != "" {
+		like := likeContains(strings.ToLower(term))
+		query = query.
+			Select(`recipes.*, CASE
+				WHEN LOWER(recipes.title) LIKE ? ESCAPE '!' THEN 3
+				WHEN LOWER(COALESCE(recipes.ingredients, '')) LIKE ? ESCAPE '!' THEN 2
+				ELSE 1 END AS score`, like, like).
+			Where(`(LOWER(recipes.title) LIKE ? ESCAPE '!' OR LOWER(COALESCE(recipes.ingredients, '')) LIKE ? ESCAPE '!' OR LOWER(recipes.instructions) LIKE ? ESCAPE '!')`, like, like, like).
+			Order("score DESC")
+	} else {
+		query = query.Select("recipes.*, 0 AS score")
+	}
+
+	for _, ingredient := range opts.Ingredients {
+		like := likeContains(ingredient)
+		if mode.ingredientTable {
+			query = query.Where(`EXISTS (SELECT 1 FROM recipe_ingredients ri WHERE ri.recipe_id = recipes.id AND ri.name LIKE ? ESCAPE '!')`, like)
+		} else {
+			query = query.Where(`(LOWER(recipes.ingredients) LIKE ? ESCAPE '!' OR LOWER(recipes.parsed_ingredients) LIKE ? ESCAPE '!')`, like, like)
+		}
+	}
+	return query
+}
+
+// likeContains builds a LIKE pattern matching term anywhere, escaping the
+// wildcards so "50%" or "snake_case" match literally. Pair it with
+// ESCAPE '!', which unlike a backslash means the same to SQLite and MySQL.
+func likeContains(term string) string {
+	escaped := strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(term)
+	return "%" + escaped + "%"
+}
+
+// findSearchResults runs the search and counts every match, degrading to
+// plain LIKE matching when the FTS or ingredient index tables are
+// unavailable.
+func (r *RecipeRepository) findSearchResults(userID uint, opts RecipeSearchOptions) ([]scoredRecipeModel, int64, error) {
+	mode := searchMode{fts: !isMySQL(r.db), ingredientTable: true}
+	for {
+		models, err := r.searchRecipeModels(userID, opts, mode)
+		total := int64(len(models))
+		if err == nil && opts.Page.Limit > 0 {
+			total, err = r.countSearchResults(userID, opts, mode)
+		}
+		switch {
+		case err == nil:
+			return models, total, nil
+		case mode.ingredientTable && isIngredientTableMissing(err):
+			mode.ingredientTable = false
+		case mode.fts && isSearchIndexUnavailable(err):
+			mode.fts = false
+		default:
+			return nil, 0, fmt.Errorf("search recipes: %w", err)
+		}
+	}
+}
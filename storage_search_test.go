@@ -0,0 +1,93 @@
+package main
+
+import "testing"
+
+func TestLikeSearchTreatsWildcardsLiterally(t *testing.T) {
+	repo := newTestRepo(t)
+	userID := createTestUser(t, repo, "cook@example.com")
+	for slug, title := range map[string]string{
+		"half":  "50% Whole Wheat Bread",
+		"fifty": "500 Calorie Bread",
+	} {
+		recipe := Recipe{Title: title, Category: "baking", Ingredients: []string{"flour"}, Instructions: []string{"Bake."}}
+		if err := repo.SaveRecipeForUser("cook@example.com", slug, recipe); err != nil {
+			t.Fatalf("save %s: %v", slug, err)
+		}
+	}
+
+	models, err := repo.searchRecipeModels(userID, RecipeSearchOptions{Term: "50%"}, searchMode{ingredientTable: true})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(models) != 1 || models[0].Title != "50% Whole Wheat Bread" {
+		t.Fatalf("search for 50%% matched %d recipes, want only the 50%% one", len(models))
+	}
+}
+
+func TestIngredientSearchTreatsWildcardsLiterally(t *testing.T) {
+	repo := newTestRepo(t)
+	userID := createTestUser(t, repo, "cook@example.com")
+	recipe := Recipe{Title: "Toast", Category: "breakfast", Ingredients: []string{"1 slice bread", "butter"}, Instructions: []string{"Toast."}}
+	if err := repo.SaveRecipeForUser("cook@example.com", "toast", recipe); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	for _, mode := range []searchMode{{ingredientTable: true}, {}} {
+		opts := RecipeSearchOptions{Ingredients: []string{"b_tter"}}
+		models, err := repo.searchRecipeModels(userID, opts, mode)
+		if err != nil {
+			t.Fatalf("search (%+v): %v", mode, err)
+		}
+		if len(models) != 0 {
+			t.Errorf("ingredient b_tter matched %d recipes (%+v), want none", len(models), mode)
+		}
+
+		opts.Ingredients = []string{"butter"}
+		models, err = repo.searchRecipeModels(userID, opts, mode)
+		if err != nil {
+			t.Fatalf("search (%+v): %v", mode, err)
+		}
+		if len(models) != 1 {
+			t.Errorf("ingredient butter matched %d recipes (%+v), want 1", len(models), mode)
+		}
+	}
+}
+
+func TestSearchRecipesPagesInSQL(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	for _, slug := range []string{"rye", "spelt", "sourdough", "brioche", "focaccia"} {
+		recipe := Recipe{Title: slug + " bread", Category: "baking", Ingredients: []string{"flour"}, Instructions: []string{"Bake."}}
+		if err := repo.SaveRecipeForUser("cook@example.com", slug, recipe); err != nil {
+			t.Fatalf("save %s: %v", slug, err)
+		}
+	}
+	other := Recipe{Title: "Salad", Category: "sides", Ingredients: []string{"lettuce"}, Instructions: []string{"Toss."}}
+	if err := repo.SaveRecipeForUser("cook@example.com", "salad", other); err != nil {
+		t.Fatalf("save salad: %v", err)
+	}
+
+	seen := map[string]bool{}
+	for offset := 0; offset < 6; offset += 2 {
+		opts := RecipeSearchOptions{Term: "bread", Page: Pagination{Limit: 2, Offset: offset}}
+		found, total, err := repo.SearchRecipes("cook@example.com", opts)
+		if err != nil {
+			t.Fatalf("search at offset %d: %v", offset, err)
+		}
+		if total != 5 {
+			t.Fatalf("total at offset %d = %d, want 5", offset, total)
+		}
+		if want := min(2, 5-offset); len(found) != want {
+			t.Fatalf("page at offset %d has %d recipes, want %d", offset, len(found), want)
+		}
+		for _, recipe := range found {
+			if seen[recipe.Title] {
+				t.Errorf("%q appeared on two pages", recipe.Title)
+			}
+			seen[recipe.Title] = true
+		}
+	}
+	if len(seen) != 5 {
+		t.Errorf("paging saw %d recipes, want 5", len(seen))
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const (
+	defaultSimilarLimit = 10
+	maxSimilarLimit     = 50
+
+	// sameCategoryBonus nudges ties towards recipes of the same kind
+	sameCategoryBonus = 0.1
+)
+
+// ingredientDescriptors are words that say how an ingredient is prepared
+// rather than what it is, so they don't count towards similarity.
+var ingredientDescriptors = map[string]struct{}{
+	"chopped": {}, "diced": {}, "minced": {}, "sliced": {}, "grated": {}, "fresh": {},
+	"large": {}, "small": {}, "medium": {}, "finely": {}, "roughly": {}, "ground": {},
+	"whole": {}, "softened": {}, "melted": {}, "room": {}, "temperature": {}, "and": {},
+	"for": {}, "the": {}, "optional": {}, "taste": {}, "divided": {}, "peeled": {},
+}
+
+// ingredientKeywords reduces normalized ingredient names to the set of words
+// that identify them, e.g. "finely chopped red onion" -> {red, onion}.
+func ingredientKeywords(names []string) map[string]struct{} {
+	keywords := make(map[string]struct{})
+	for _, name := range names {
+		for _, word := range strings.Fields(name) {
+			word = strings.Trim(word, "'-")
+			if len(word) < 3 {
+				continue
+			}
+			if _, skip := ingredientDescriptors[word]; skip {
+				continue
+			}
+			keywords[strings.TrimSuffix(word, "s")] = struct{}{}
+		}
+	}
+	return keywords
+}
+
+func jaccard(a, b map[string]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	shared := 0
+	for word := range a {
+		if _, ok := b[word]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// userIngredientNames returns the normalized ingredient names of every recipe
+// the user has, from recipe_ingredients or, if that table is missing, from
+// the recipes themselves.
+func (r *RecipeRepository) userIngredientNames(userID uint) (map[uint][]string, error) {
+	names := make(map[uint][]string)
+
+	var rows []RecipeIngredientModel
+	err := r.db.Select("recipe_id", "name").Where("user_id = ?", userID).Find(&rows).Error
+	if err == nil {
+		for _, row := range rows {
+			names[row.RecipeID] = append(names[row.RecipeID], row.Name)
+		}
+		return names, nil
+	}
+	if !isIngredientTableMissing(err) {
+		return nil, fmt.Errorf("load ingredient index: %w", err)
+	}
+
+	var models []RecipeModel
+	if err := r.db.Select("id", "ingredients", "parsed_ingredients").
+		Where("user_id = ?", userID).
+		Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("load recipe ingredients: %w", err)
+	}
+	for _, model := range models {
+		names[model.ID] = ingredientNamesForModel(model)
+	}
+	return names, nil
+}
+
+// SimilarRecipes returns up to limit of the user's other recipes ranked by
+// shared ingredients, best first. Recipes with nothing in common are left out.
+func (r *RecipeRepository) SimilarRecipes(username string, recipeID uint, limit int) ([]Recipe, error) {
+	if username == "" {
+		return nil, errors.New("username is required")
+	}
+
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var base RecipeModel
+	if err := r.db.Select("id", "category").Where("id = ? AND user_id = ?", recipeID, userID).First(&base).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, sql.ErrNoRows
+		}
+		return nil, fmt.Errorf("get recipe: %w", err)
+	}
+
+	names, err := r.userIngredientNames(userID)
+	if err != nil {
+		return nil, err
+	}
+	baseKeywords := ingredientKeywords(names[base.ID])
+	if len(baseKeywords) == 0 {
+		return []Recipe{}, nil
+	}
+
+	type candidate struct {
+		id    uint
+		score float64
+	}
+	candidates := make([]candidate, 0)
+	for id, recipeNames := range names {
+		if id == base.ID {
+			continue
+		}
+		if score := jaccard(baseKeywords, ingredientKeywords(recipeNames)); score > 0 {
+			candidates = append(candidates, candidate{id: id, score: score})
+		}
+	}
+	if len(candidates) == 0 {
+		return []Recipe{}, nil
+	}
+
+	ids := make([]uint, 0, len(candidates))
+	for _, cand := range candidates {
+		ids = append(ids, cand.id)
+	}
+	var models []RecipeModel
+	if err := r.db.Where("id IN ? AND user_id = ?", ids, userID).Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("load similar recipes: %w", err)
+	}
+
+	scores := make(map[uint]float64, len(candidates))
+	for _, cand := range candidates {
+		scores[cand.id] = cand.score
+	}
+	for _, model := range models {
+		if model.Category == base.Category {
+			scores[model.ID] += sameCategoryBonus
+		}
+	}
+	sort.SliceStable(models, func(i, j int) bool {
+		if scores[models[i].ID] != scores[models[j].ID] {
+			return scores[models[i].ID] > scores[models[j].ID]
+		}
+		return models[i].CreatedAt.After(models[j].CreatedAt)
+	})
+	if limit > 0 && len(models) > limit {
+		models = models[:limit]
+	}
+
+	favorites, err := r.favoriteRecipeIDs(userID, recipeModelIDs(models))
+	if err != nil {
+		return nil, err
+	}
+
+	recipes := make([]Recipe, 0, len(models))
+	for _, model := range models {
+		recipe, err := model.toRecipe()
+		if err != nil {
+			return nil, err
+		}
+		recipe.IsFavorite = favorites[model.ID]
+		recipe.Score = scores[model.ID]
+		recipes = append(recipes, recipe)
+	}
+
+	return recipes, nil
+}
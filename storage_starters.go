@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type StarterRecipeModel struct {
+	ID         uint   `gorm:"primaryKey"`
+	Slug       string `gorm:"column:slug;not null;uniqueIndex"`
+	Position   int    `gorm:"column:position"`
+	RecipeJSON string `gorm:"column:recipe;not null"`
+}
+
+func (StarterRecipeModel) TableName() string {
+	return "starter_recipes"
+}
+
+// StarterRecipe is a recipe in the starter set, in the shape of a recipe in
+// an account export.
+type StarterRecipe struct {
+	Slug string `json:"slug"`
+	Recipe
+}
+
+// starterRecipe keeps what a new account should get: the recipe, not the
+// account it was taken from.
+func starterRecipe(slug string, recipe Recipe) StarterRecipe {
+	return StarterRecipe{Slug: slug, Recipe: Recipe{
+		Category:            recipe.Category,
+		CookTime:            recipe.CookTime,
+		Date:                recipe.Date,
+		Image:               recipe.Image,
+		Ingredients:         recipe.Ingredients,
+		ParsedIngredients:   recipe.ParsedIngredients,
+		Instructions:        recipe.Instructions,
+		InstructionSections: recipe.InstructionSections,
+		Equipment:           recipe.Equipment,
+		Tags:                recipe.Tags,
+		Nutrition:           recipe.Nutrition,
+		PrepTime:            recipe.PrepTime,
+		Servings:            recipe.Servings,
+		Title:               recipe.Title,
+		TotalTime:           recipe.TotalTime,
+		Link:                recipe.Link,
+		OriginalURL:         recipe.OriginalURL,
+		SchemaVersion:       recipe.SchemaVersion,
+	}}
+}
+
+// StarterRecipes lists the starter set in the order new accounts get it.
+func (r *RecipeRepository) StarterRecipes() ([]StarterRecipe, error) {
+	var models []StarterRecipeModel
+	if err := r.db.Order("position, id").Find(&models).Error; err != nil {
+		return nil, fmt.Errorf("list starter recipes: %w", err)
+	}
+	starters := make([]StarterRecipe, 0, len(models))
+	for _, model := range models {
+		var recipe Recipe
+		if err := json.Unmarshal([]byte(model.RecipeJSON), &recipe); err != nil {
+			return nil, fmt.Errorf("starter recipe %s: %w", model.Slug, err)
+		}
+		starters = append(starters, StarterRecipe{Slug: model.Slug, Recipe: recipe})
+	}
+	return starters, nil
+}
+
+// ReplaceStarterRecipes makes starters the whole starter set, in order.
+// Recipes without a slug get one from their title.
+func (r *RecipeRepository) ReplaceStarterRecipes(starters []StarterRecipe) error {
+	models := make([]StarterRecipeModel, 0, len(starters))
+	seen := map[string]bool{}
+	for i, starter := range starters {
+		if strings.TrimSpace(starter.Title) == "" {
+			return fmt.Errorf("starter recipe %d has no title", i+1)
+		}
+		slug := strings.TrimSpace(starter.Slug)
+		if slug == "" {
+			slug = slugify(starter.Title)
+		}
+		if seen[slug] {
+			return fmt.Errorf("starter recipe slug %q is used twice", slug)
+		}
+		seen[slug] = true
+		data, err := json.Marshal(starterRecipe(slug, starter.Recipe).Recipe)
+		if err != nil {
+			return fmt.Errorf("marshal starter recipe %s: %w", slug, err)
+		}
+		models = append(models, StarterRecipeModel{Slug: slug, Position: i, RecipeJSON: string(data)})
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&StarterRecipeModel{}).Error; err != nil {
+			return fmt.Errorf("clear starter recipes: %w", err)
+		}
+		if len(models) == 0 {
+			return nil
+		}
+		if err := tx.Create(&models).Error; err != nil {
+			return fmt.Errorf("save starter recipes: %w", err)
+		}
+		return nil
+	})
+}
+
+// copyStarterRecipes gives a new user their own copy of each starter
+// recipe, inside the registration transaction, and returns how many.
+func copyStarterRecipes(tx *gorm.DB, userID uint) (int, error) {
+	var starters []StarterRecipeModel
+	if err := tx.Order("position, id").Find(&starters).Error; err != nil {
+		// Do not fail registration on a database without the table
+		if isNoSuchTableError(err) {
+			log.Println("[Registration] starter_recipes table missing; skipping starter recipes")
+			return 0, nil
+		}
+		return 0, fmt.Errorf("fetch starter recipes: %w", err)
+	}
+
+	for _, starter := range starters {
+		var recipe Recipe
+		if err := json.Unmarshal([]byte(starter.RecipeJSON), &recipe); err != nil {
+			return 0, fmt.Errorf("starter recipe %s: %w", starter.Slug, err)
+		}
+		model, err := newRecipeModel(userID, starter.Slug, recipe)
+		if err != nil {
+			return 0, err
+		}
+		if err := tx.Create(&model).Error; err != nil {
+			return 0, fmt.Errorf("copy starter recipe %s: %w", starter.Slug, err)
+		}
+		if err := indexRecipe(tx, model); err != nil {
+			return 0, err
+		}
+	}
+	return len(starters), nil
+}
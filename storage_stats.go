@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// ProfileStats summarises a user's collection for the profile dashboard.
+type ProfileStats struct {
+	Recipes      int64          `json:"recipes"`
+	Favorites    int64          `json:"favorites"`
+	Categories   []CategoryStat `json:"categories"`
+	AddedByMonth []MonthlyCount `json:"addedByMonth"`
+	Imports      ImportStats    `json:"imports"`
+}
+
+type CategoryStat struct {
+	Category string `json:"category"`
+	Count    int64  `json:"count"`
+}
+
+// MonthlyCount counts the recipes added in a month, such as "2026-10".
+type MonthlyCount struct {
+	Month string `json:"month"`
+	Count int64  `json:"count"`
+}
+
+// ImportStats counts the URLs queued in the last profileStatsImportDays.
+// SuccessRate is imported over imported and failed, null before any have
+// finished; cancelled and pending imports don't count.
+type ImportStats struct {
+	Days        int      `json:"days"`
+	Imported    int64    `json:"imported"`
+	Failed      int64    `json:"failed"`
+	Pending     int64    `json:"pending"`
+	Cancelled   int64    `json:"cancelled"`
+	SuccessRate *float64 `json:"successRate"`
+}
+
+// ProfileStats gathers the dashboard numbers as of now: the last
+// profileStatsMonths months of additions, oldest first and including
+// months with none, and the last profileStatsImportDays of imports.
+func (r *RecipeRepository) ProfileStats(username string, now time.Time) (ProfileStats, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return ProfileStats{}, err
+	}
+	stats := ProfileStats{Categories: []CategoryStat{}, AddedByMonth: []MonthlyCount{}}
+
+	if stats.Recipes, err = r.CountRecipes(username); err != nil {
+		return ProfileStats{}, err
+	}
+	if err := r.db.Model(&FavoriteModel{}).Where("user_id = ?", userID).Count(&stats.Favorites).Error; err != nil && !isNoSuchTableError(err) {
+		return ProfileStats{}, fmt.Errorf("count favorites: %w", err)
+	}
+	categories, err := r.CategoryCounts(username)
+	if err != nil {
+		return ProfileStats{}, err
+	}
+	for _, category := range categories {
+		stats.Categories = append(stats.Categories, CategoryStat{Category: category.Category, Count: category.Count})
+	}
+
+	now = now.UTC()
+	firstMonth := time.Date(now.Year(), now.Month()-profileStatsMonths+1, 1, 0, 0, 0, 0, time.UTC)
+	var months []MonthlyCount
+	if err := r.db.Table("recipes").
+		Select(yearMonth(r.db, "created_at")+" AS month, COUNT(*) AS count").
+		Where("user_id = ? AND created_at >= ?", userID, firstMonth).
+		Group("month").
+		Scan(&months).Error; err != nil {
+		return ProfileStats{}, fmt.Errorf("count recipes by month: %w", err)
+	}
+	added := make(map[string]int64, len(months))
+	for _, month := range months {
+		added[month.Month] = month.Count
+	}
+	for month := firstMonth; !month.After(now); month = month.AddDate(0, 1, 0) {
+		key := month.Format("2006-01")
+		stats.AddedByMonth = append(stats.AddedByMonth, MonthlyCount{Month: key, Count: added[key]})
+	}
+
+	stats.Imports.Days = profileStatsImportDays
+	if err := r.db.Model(&QueueModel{}).
+		Select(`COALESCE(SUM(CASE WHEN cancelled_at IS NULL AND failed_at IS NULL AND processed_at IS NOT NULL THEN 1 ELSE 0 END), 0) AS imported,
+			COALESCE(SUM(CASE WHEN cancelled_at IS NULL AND failed_at IS NOT NULL THEN 1 ELSE 0 END), 0) AS failed,
+			COALESCE(SUM(CASE WHEN cancelled_at IS NULL AND processed_at IS NULL THEN 1 ELSE 0 END), 0) AS pending,
+			COALESCE(SUM(CASE WHEN cancelled_at IS NOT NULL THEN 1 ELSE 0 END), 0) AS cancelled`).
+		Where("user_id = ? AND created_at >= ?", userID, now.AddDate(0, 0, -profileStatsImportDays)).
+		Scan(&stats.Imports).Error; err != nil {
+		return ProfileStats{}, fmt.Errorf("count imports: %w", err)
+	}
+	if finished := stats.Imports.Imported + stats.Imports.Failed; finished > 0 {
+		rate := float64(stats.Imports.Imported) / float64(finished)
+		stats.Imports.SuccessRate = &rate
+	}
+	return stats, nil
+}
@@ -0,0 +1,609 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func TestQueueItemDeadLetterAndRetry(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/soup", queuePriorityBulk); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	var item QueueModel
+	if err := repo.db.First(&item).Error; err != nil {
+		t.Fatalf("load item: %v", err)
+	}
+
+	failure := &httpStatusError{StatusCode: 403}
+	for i := 0; i < queueSettings.MaxAttempts; i++ {
+		if err := repo.MarkQueueItemResult(item.ID, failure); err != nil {
+			t.Fatalf("mark attempt %d: %v", i+1, err)
+		}
+	}
+
+	failed, err := repo.ListFailedQueueItems("cook@example.com", 10, 0)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(failed) != 1 || failed[0].Status != "failed" || failed[0].ErrorCode != queueErrorBlocked || failed[0].FailedAt == nil {
+		t.Fatalf("failed items = %+v, want one blocked item", failed)
+	}
+
+	retried, err := repo.RetryQueueItem("cook@example.com", item.ID)
+	if err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if retried.Status != "pending" || retried.Attempts != 0 || retried.Priority != queuePriorityInteractive {
+		t.Fatalf("retried item = %+v, want pending at interactive priority", retried)
+	}
+	if failed, _ := repo.ListFailedQueueItems("cook@example.com", 10, 0); len(failed) != 0 {
+		t.Fatalf("failed items after retry = %+v, want none", failed)
+	}
+	if _, err := repo.RetryQueueItem("cook@example.com", item.ID); !errors.Is(err, errQueueItemNotFailed) {
+		t.Fatalf("retry pending item: err = %v, want errQueueItemNotFailed", err)
+	}
+}
+
+func TestRetryQueueItemChecksOwner(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "owner@example.com")
+	createTestUser(t, repo, "other@example.com")
+	if err := repo.EnqueueRecipe("owner@example.com", "https://example.com/stew", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	var item QueueModel
+	if err := repo.db.First(&item).Error; err != nil {
+		t.Fatalf("load item: %v", err)
+	}
+	if err := repo.MarkQueueItemResult(item.ID, errPaywalled); err != nil {
+		t.Fatalf("mark: %v", err)
+	}
+
+	if _, err := repo.RetryQueueItem("other@example.com", item.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("retry by other user: err = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestCancelQueueItem(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	createTestUser(t, repo, "other@example.com")
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/soup", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	var item QueueModel
+	if err := repo.db.First(&item).Error; err != nil {
+		t.Fatalf("load item: %v", err)
+	}
+
+	if _, err := repo.CancelQueueItem("other@example.com", item.ID); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("cancel by other user: err = %v, want sql.ErrNoRows", err)
+	}
+	cancelled, err := repo.CancelQueueItem("cook@example.com", item.ID)
+	if err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if cancelled.Status != "cancelled" || cancelled.CancelledAt == nil {
+		t.Fatalf("cancelled item = %+v, want status cancelled", cancelled)
+	}
+	if _, err := repo.CancelQueueItem("cook@example.com", item.ID); !errors.Is(err, errQueueItemFinished) {
+		t.Fatalf("cancel twice: err = %v, want errQueueItemFinished", err)
+	}
+
+	if pending, err := repo.FetchPendingQueue(10); err != nil || len(pending) != 0 {
+		t.Fatalf("pending after cancel = %d items (err %v), want none", len(pending), err)
+	}
+	ids, err := repo.CancelledQueueItems([]uint{item.ID})
+	if err != nil || len(ids) != 1 {
+		t.Fatalf("cancelled ids = %v (err %v), want [%d]", ids, err, item.ID)
+	}
+
+	// A worker finishing the import afterwards doesn't revive it
+	if err := repo.MarkQueueItemSaved(item.ID, "soup"); err != nil {
+		t.Fatalf("mark saved: %v", err)
+	}
+	items, err := repo.ListQueueItems("cook@example.com", 10)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(items) != 1 || items[0].Status != "cancelled" || items[0].RecipeSlug != "" {
+		t.Fatalf("items = %+v, want the cancelled item untouched", items)
+	}
+}
+
+func TestFetchPendingQueueTakesTurnsBetweenUsers(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "bulk@example.com")
+	createTestUser(t, repo, "cook@example.com")
+	for _, url := range []string{"https://example.com/1", "https://example.com/2", "https://example.com/3"} {
+		if err := repo.EnqueueRecipe("bulk@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/pie", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/newsletter", queuePriorityBulk); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+
+	items, err := repo.FetchPendingQueue(3)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	var got []string
+	for _, item := range items {
+		got = append(got, item.User.Username+" "+item.URL)
+	}
+	want := []string{
+		"bulk@example.com https://example.com/1",
+		"cook@example.com https://example.com/pie",
+		"bulk@example.com https://example.com/2",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("fetched %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("fetched %v, want %v", got, want)
+		}
+	}
+}
+
+func TestClaimPendingQueueTakesEachItemOnce(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	for _, url := range []string{"https://example.com/a", "https://example.com/b", "https://example.com/c"} {
+		if err := repo.EnqueueRecipe("cook@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+	}
+
+	first, err := repo.ClaimPendingQueue("worker-1", 2)
+	if err != nil || len(first) != 2 {
+		t.Fatalf("worker-1 claimed %d items (err %v), want 2", len(first), err)
+	}
+	second, err := repo.ClaimPendingQueue("worker-2", 10)
+	if err != nil || len(second) != 1 || second[0].URL != "https://example.com/c" {
+		t.Fatalf("worker-2 claimed %+v (err %v), want only the third item", second, err)
+	}
+	if rest, err := repo.ClaimPendingQueue("worker-2", 10); err != nil || len(rest) != 0 {
+		t.Fatalf("claimed %d more items (err %v), want none", len(rest), err)
+	}
+
+	// Another worker's release is a no-op; the owner's frees the item
+	if err := repo.ReleaseQueueClaim(first[0].ID, "worker-2"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	if pending, err := repo.FetchPendingQueue(10); err != nil || len(pending) != 0 {
+		t.Fatalf("pending = %d items (err %v), want none", len(pending), err)
+	}
+	if err := repo.ReleaseQueueClaim(first[0].ID, "worker-1"); err != nil {
+		t.Fatalf("release: %v", err)
+	}
+	again, err := repo.ClaimPendingQueue("worker-2", 10)
+	if err != nil || len(again) != 1 || again[0].ID != first[0].ID {
+		t.Fatalf("reclaimed %+v (err %v), want the released item", again, err)
+	}
+
+	// A lapsed lease is taken over, as when a worker dies mid-import
+	lapsed := time.Now().UTC().Add(-queueClaimLease - time.Minute)
+	if err := repo.db.Model(&QueueModel{}).Where("id = ?", first[1].ID).
+		UpdateColumn("claimed_at", lapsed).Error; err != nil {
+		t.Fatalf("age claim: %v", err)
+	}
+	taken, err := repo.ClaimPendingQueue("worker-2", 10)
+	if err != nil || len(taken) != 1 || taken[0].ID != first[1].ID {
+		t.Fatalf("took over %+v (err %v), want the lapsed item", taken, err)
+	}
+}
+
+func TestQueueItemStages(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.EnqueueRecipe("cook@example.com", "https://example.com/soup", queuePriorityInteractive); err != nil {
+		t.Fatalf("enqueue: %v", err)
+	}
+	stage := func() QueueItem {
+		t.Helper()
+		items, err := repo.ListQueueItems("cook@example.com", 10)
+		if err != nil || len(items) != 1 {
+			t.Fatalf("list = %d items (err %v), want 1", len(items), err)
+		}
+		return items[0]
+	}
+	item := stage()
+	if item.Stage != queueStageQueued {
+		t.Fatalf("new item stage = %q, want queued", item.Stage)
+	}
+
+	if err := repo.SetQueueItemStage(item.ID, queueStageFetching); err != nil {
+		t.Fatalf("set stage: %v", err)
+	}
+	if got := stage(); got.Stage != queueStageFetching || got.StageChangedAt == nil || got.Status != "pending" {
+		t.Fatalf("item = %+v, want pending and fetching since now", got)
+	}
+
+	// A failed attempt that will be retried waits in the queue again
+	if err := repo.MarkQueueItemResult(item.ID, errors.New("connection reset")); err != nil {
+		t.Fatalf("mark result: %v", err)
+	}
+	if got := stage(); got.Stage != queueStageQueued {
+		t.Fatalf("stage after a failed attempt = %q, want queued", got.Stage)
+	}
+
+	if err := repo.MarkQueueItemSaved(item.ID, "soup"); err != nil {
+		t.Fatalf("mark saved: %v", err)
+	}
+	// A late progress report doesn't reopen a finished item
+	if err := repo.SetQueueItemStage(item.ID, queueStageSaving); err != nil {
+		t.Fatalf("set stage: %v", err)
+	}
+	if got := stage(); got.Stage != queueStageDone || got.Status != "processed" {
+		t.Fatalf("item = %+v, want processed and done", got)
+	}
+}
+
+// seedRecipes saves n recipes for username, favoriting every other one.
+func seedRecipes(t testing.TB, repo *RecipeRepository, username string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		slug := fmt.Sprintf("bread-%d", i)
+		recipe := Recipe{Title: fmt.Sprintf("Bread %d", i), Category: "baking", Ingredients: []string{"flour", "water"}, Instructions: []string{"Bake."}}
+		if err := repo.SaveRecipeForUser(username, slug, recipe); err != nil {
+			t.Fatalf("save %s: %v", slug, err)
+		}
+		if i%2 == 0 {
+			if err := repo.SetFavorite(username, slug, true); err != nil {
+				t.Fatalf("favorite %s: %v", slug, err)
+			}
+		}
+	}
+}
+
+func TestListAndSearchMarkFavorites(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	seedRecipes(t, repo, "cook@example.com", 6)
+	// Another user's favorite of the same recipe doesn't count
+	createTestUser(t, repo, "other@example.com")
+	otherID, _ := repo.getUserID("other@example.com")
+	var bread RecipeModel
+	if err := repo.db.Where("slug = ?", "bread-1").First(&bread).Error; err != nil {
+		t.Fatalf("find recipe: %v", err)
+	}
+	if err := repo.db.Create(&FavoriteModel{UserID: otherID, RecipeID: bread.ID}).Error; err != nil {
+		t.Fatalf("favorite as other user: %v", err)
+	}
+
+	listed, err := repo.ListRecipes("cook@example.com", RecipeFilters{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	found, _, err := repo.SearchRecipes("cook@example.com", RecipeSearchOptions{Term: "bread"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	for name, recipes := range map[string][]Recipe{"list": listed, "search": found} {
+		if len(recipes) != 6 {
+			t.Fatalf("%s returned %d recipes, want 6", name, len(recipes))
+		}
+		for _, recipe := range recipes {
+			var i int
+			fmt.Sscanf(recipe.Title, "Bread %d", &i)
+			if recipe.IsFavorite != (i%2 == 0) {
+				t.Errorf("%s: %s favorite = %t", name, recipe.Title, recipe.IsFavorite)
+			}
+		}
+	}
+}
+
+func benchmarkRepo(b *testing.B) *RecipeRepository {
+	b.Helper()
+	repo := newTestRepo(b)
+	createTestUser(b, repo, "cook@example.com")
+	seedRecipes(b, repo, "cook@example.com", 300)
+	b.ResetTimer()
+	return repo
+}
+
+func BenchmarkListRecipes(b *testing.B) {
+	repo := benchmarkRepo(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.ListRecipes("cook@example.com", RecipeFilters{}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearchRecipes(b *testing.B) {
+	repo := benchmarkRepo(b)
+	for i := 0; i < b.N; i++ {
+		if _, _, err := repo.SearchRecipes("cook@example.com", RecipeSearchOptions{Term: "bread"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkFavoriteLookups compares marking 300 recipes' favorites one
+// query per recipe, as list and search used to, with a single query.
+func BenchmarkFavoriteLookups(b *testing.B) {
+	repo := benchmarkRepo(b)
+	userID, err := repo.getUserID("cook@example.com")
+	if err != nil {
+		b.Fatal(err)
+	}
+	var ids []uint
+	if err := repo.db.Model(&RecipeModel{}).Where("user_id = ?", userID).Pluck("id", &ids).Error; err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("per-recipe", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			for _, id := range ids {
+				if _, err := repo.isFavorite(userID, id); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("one-query", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := repo.favoriteRecipeIDs(userID, ids); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestWithContextStopsQueries(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := repo.WithContext(ctx).ListRecipes("cook@example.com", RecipeFilters{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("list with a cancelled context: err = %v, want context.Canceled", err)
+	}
+	if _, err := repo.ListRecipes("cook@example.com", RecipeFilters{}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+}
+
+func TestPatchRecipeIfMatch(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	if err := repo.SaveRecipeForUser("cook@example.com", "toast", Recipe{Title: "Toast", Category: "breakfast"}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	read, err := repo.GetRecipe("cook@example.com", "toast")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	etag := recipeETag(read)
+
+	// Both members send the tag they read; only the first edit lands
+	title := "French Toast"
+	updated, err := repo.UpdateRecipeTitleAndInstructionsByID("cook@example.com", read.ID, RecipePatch{Title: &title, IfMatch: etag})
+	if err != nil {
+		t.Fatalf("first patch: %v", err)
+	}
+	again, _ := repo.GetRecipe("cook@example.com", "toast")
+	if recipeETag(updated) != recipeETag(again) {
+		t.Fatal("the patched recipe's ETag differs from a fresh read")
+	}
+	other := "Cinnamon Toast"
+	if _, err := repo.UpdateRecipeTitleAndInstructions("cook@example.com", "toast", RecipePatch{Title: &other, IfMatch: etag}); !errors.Is(err, ErrRecipeChanged) {
+		t.Fatalf("stale patch = %v, want ErrRecipeChanged", err)
+	}
+	if _, err := repo.UpdateRecipeTitleAndInstructions("cook@example.com", "toast", RecipePatch{Title: &other, IfMatch: `"stale", ` + recipeETag(again)}); err != nil {
+		t.Fatalf("patch with the current tag listed: %v", err)
+	}
+	if _, err := repo.UpdateRecipeTitleAndInstructions("cook@example.com", "missing", RecipePatch{Title: &other, IfMatch: etag}); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("missing recipe = %v, want sql.ErrNoRows", err)
+	}
+}
+
+func TestProfileStats(t *testing.T) {
+	repo := newTestRepo(t)
+	userID := createTestUser(t, repo, "cook@example.com")
+	seedRecipes(t, repo, "cook@example.com", 4)
+	now := time.Date(2026, 10, 17, 12, 0, 0, 0, time.UTC)
+	// Backdate two recipes: one to August, one past the twelve months
+	for slug, created := range map[string]time.Time{
+		"bread-1": time.Date(2026, 8, 3, 9, 0, 0, 0, time.UTC),
+		"bread-2": time.Date(2025, 9, 30, 9, 0, 0, 0, time.UTC),
+	} {
+		if err := repo.db.Model(&RecipeModel{}).Where("slug = ?", slug).Update("created_at", created).Error; err != nil {
+			t.Fatalf("backdate %s: %v", slug, err)
+		}
+	}
+	if err := repo.db.Model(&RecipeModel{}).Where("slug NOT IN ?", []string{"bread-1", "bread-2"}).Update("created_at", now.Add(-time.Hour)).Error; err != nil {
+		t.Fatalf("date recipes: %v", err)
+	}
+	for url, updates := range map[string]map[string]any{
+		"https://example.com/a":      {"processed_at": now},
+		"https://example.com/b":      {"processed_at": now},
+		"https://example.com/c":      {"processed_at": now},
+		"https://example.com/failed": {"processed_at": now, "failed_at": now},
+		"https://example.com/gone":   {"processed_at": now, "cancelled_at": now},
+		"https://example.com/wait":   {},
+		"https://example.com/old":    {"processed_at": now, "failed_at": now, "created_at": now.AddDate(0, -2, 0)},
+	} {
+		if err := repo.EnqueueRecipe("cook@example.com", url, queuePriorityInteractive); err != nil {
+			t.Fatalf("enqueue: %v", err)
+		}
+		if len(updates) > 0 {
+			if err := repo.db.Model(&QueueModel{}).Where("user_id = ? AND url = ?", userID, url).Updates(updates).Error; err != nil {
+				t.Fatalf("update %s: %v", url, err)
+			}
+		}
+	}
+
+	stats, err := repo.ProfileStats("cook@example.com", now)
+	if err != nil {
+		t.Fatalf("stats: %v", err)
+	}
+	if stats.Recipes != 4 || stats.Favorites != 2 {
+		t.Errorf("recipes %d, favorites %d, want 4 and 2", stats.Recipes, stats.Favorites)
+	}
+	if len(stats.Categories) != 1 || stats.Categories[0].Count != 4 {
+		t.Errorf("categories = %+v", stats.Categories)
+	}
+	if len(stats.AddedByMonth) != 12 || stats.AddedByMonth[0].Month != "2025-11" || stats.AddedByMonth[11].Month != "2026-10" {
+		t.Fatalf("months = %+v", stats.AddedByMonth)
+	}
+	if august, october := stats.AddedByMonth[9], stats.AddedByMonth[11]; august.Count != 1 || october.Count != 2 {
+		t.Errorf("August %+v, October %+v, want 1 and 2", august, october)
+	}
+	imports := stats.Imports
+	if imports.Imported != 3 || imports.Failed != 1 || imports.Pending != 1 || imports.Cancelled != 1 || imports.SuccessRate == nil || *imports.SuccessRate != 0.75 {
+		t.Errorf("imports = %+v", imports)
+	}
+
+	createTestUser(t, repo, "new@example.com")
+	if stats, err := repo.ProfileStats("new@example.com", now); err != nil || stats.Imports.SuccessRate != nil || stats.Recipes != 0 {
+		t.Errorf("new user's stats = %+v, %v", stats, err)
+	}
+}
+
+func TestCategoryDetails(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	createTestUser(t, repo, "other@example.com")
+	start := time.Date(2026, 10, 1, 9, 0, 0, 0, time.UTC)
+	for i, recipe := range []struct {
+		slug, category string
+		totalTime      int
+		favorite       bool
+	}{
+		{"pancakes", "breakfast", 20, true},
+		{"omelette", "breakfast", 0, false},
+		{"waffles", "breakfast", 40, true},
+		{"stew", "dinner", 0, false},
+	} {
+		if err := repo.SaveRecipeForUser("cook@example.com", recipe.slug, Recipe{Title: recipe.slug, Category: recipe.category, TotalTime: recipe.totalTime}); err != nil {
+			t.Fatalf("save: %v", err)
+		}
+		if err := repo.db.Model(&RecipeModel{}).Where("slug = ?", recipe.slug).Update("created_at", start.AddDate(0, 0, i)).Error; err != nil {
+			t.Fatalf("date %s: %v", recipe.slug, err)
+		}
+		if recipe.favorite {
+			if err := repo.SetFavorite("cook@example.com", recipe.slug, true); err != nil {
+				t.Fatalf("favorite: %v", err)
+			}
+		}
+	}
+	// Someone else's recipes and favorites don't count
+	if err := repo.SaveRecipeForUser("other@example.com", "stew", Recipe{Title: "stew", Category: "dinner", TotalTime: 90}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := repo.SetFavorite("other@example.com", "stew", true); err != nil {
+		t.Fatalf("favorite: %v", err)
+	}
+
+	details, err := repo.CategoryDetails("cook@example.com")
+	if err != nil {
+		t.Fatalf("details: %v", err)
+	}
+	if len(details) != 2 {
+		t.Fatalf("details = %+v, want breakfast and dinner", details)
+	}
+	breakfast, dinner := details[0], details[1]
+	if breakfast.Category != "breakfast" || breakfast.Count != 3 || breakfast.Favorites != 2 ||
+		breakfast.AverageTotalTime == nil || *breakfast.AverageTotalTime != 30 ||
+		breakfast.Latest == nil || breakfast.Latest.Slug != "waffles" {
+		t.Errorf("breakfast = %+v, latest %+v", breakfast, breakfast.Latest)
+	}
+	if dinner.Count != 1 || dinner.Favorites != 0 || dinner.AverageTotalTime != nil || dinner.Latest == nil || dinner.Latest.Slug != "stew" {
+		t.Errorf("dinner = %+v, latest %+v", dinner, dinner.Latest)
+	}
+}
+
+func TestSaveRecipeRollsBackOnIndexFailure(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	failIndex := func() {
+		t.Helper()
+		if err := repo.db.Exec(`CREATE TRIGGER fail_ingredient_index BEFORE INSERT ON recipe_ingredients
+			BEGIN SELECT RAISE(ABORT, 'index unavailable'); END`).Error; err != nil {
+			t.Fatalf("create trigger: %v", err)
+		}
+	}
+	toast := Recipe{Title: "Toast", Category: "breakfast", Ingredients: []string{"2 slices bread"}}
+
+	failIndex()
+	if err := repo.SaveRecipeForUser("cook@example.com", "toast", toast); err == nil {
+		t.Fatal("save with a failing index: want an error")
+	}
+	if _, err := repo.GetRecipe("cook@example.com", "toast"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("recipe after failed save: err %v, want no rows", err)
+	}
+
+	if err := repo.db.Exec("DROP TRIGGER fail_ingredient_index").Error; err != nil {
+		t.Fatalf("drop trigger: %v", err)
+	}
+	if err := repo.SaveRecipeForUser("cook@example.com", "toast", toast); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	failIndex()
+	burnt := toast
+	burnt.Title = "Burnt Toast"
+	if err := repo.SaveRecipeForUser("cook@example.com", "toast", burnt); err == nil {
+		t.Fatal("update with a failing index: want an error")
+	}
+	if recipe, err := repo.GetRecipe("cook@example.com", "toast"); err != nil || recipe.Title != "Toast" {
+		t.Fatalf("recipe after failed update: %q, %v", recipe.Title, err)
+	}
+	var ingredients int64
+	repo.db.Model(&RecipeIngredientModel{}).Count(&ingredients)
+	if ingredients != 1 {
+		t.Fatalf("ingredient rows after failed update = %d, want 1", ingredients)
+	}
+}
+
+func TestDeleteRecipeCascades(t *testing.T) {
+	repo := newTestRepo(t)
+	createTestUser(t, repo, "cook@example.com")
+	ids := map[string]uint{}
+	for _, slug := range []string{"toast", "bread"} {
+		if err := repo.SaveRecipeForUser("cook@example.com", slug, Recipe{Title: slug, Ingredients: []string{"flour", "water"}}); err != nil {
+			t.Fatalf("save %s: %v", slug, err)
+		}
+		recipe, err := repo.GetRecipe("cook@example.com", slug)
+		if err != nil {
+			t.Fatalf("get %s: %v", slug, err)
+		}
+		ids[slug] = recipe.ID
+		if err := repo.SetFavorite("cook@example.com", slug, true); err != nil {
+			t.Fatalf("favorite %s: %v", slug, err)
+		}
+		if _, err := repo.AddPlannedMeal("cook@example.com", recipe.ID, time.Now(), "dinner", ""); err != nil {
+			t.Fatalf("plan %s: %v", slug, err)
+		}
+		if err := repo.AppendRecipeChat("cook@example.com", recipe.ID, "Crusts?", "Keep them."); err != nil {
+			t.Fatalf("chat %s: %v", slug, err)
+		}
+	}
+
+	if err := repo.DeleteRecipe("cook@example.com", "toast"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	for _, model := range []any{&FavoriteModel{}, &PlannedMealModel{}, &RecipeChatMessageModel{}, &RecipeIngredientModel{}} {
+		for slug, want := range map[string]bool{"toast": false, "bread": true} {
+			var count int64
+			if err := repo.db.Model(model).Where("recipe_id = ?", ids[slug]).Count(&count).Error; err != nil || (count > 0) != want {
+				t.Errorf("%T rows of %s after deleting toast = %d, %v", model, slug, count, err)
+			}
+		}
+	}
+	var indexed int64
+	if err := repo.db.Raw("SELECT COUNT(*) FROM recipe_search WHERE rowid = ?", ids["toast"]).Scan(&indexed).Error; err == nil && indexed != 0 {
+		t.Errorf("search rows of toast after delete = %d, want 0", indexed)
+	}
+}
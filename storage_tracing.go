@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// queryTracing is a gorm plugin that traces each query as a child of the
+// span in its context. Queries made without one, such as background
+// bookkeeping, aren't traced: on their own they'd each be a trace.
+type queryTracing struct{}
+
+const queryTracingKey = "tracing:span"
+
+// querySpan is what a query's before callback leaves for its after callback.
+type querySpan struct {
+	span   trace.Span
+	parent context.Context
+}
+
+func (queryTracing) Name() string {
+	return "recipes:tracing"
+}
+
+func (queryTracing) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().Before("gorm:create").Register("tracing:before_create", startQuerySpan("create")),
+		callbacks.Create().After("gorm:create").Register("tracing:after_create", endQuerySpan),
+		callbacks.Query().Before("gorm:query").Register("tracing:before_query", startQuerySpan("query")),
+		callbacks.Query().After("gorm:query").Register("tracing:after_query", endQuerySpan),
+		callbacks.Update().Before("gorm:update").Register("tracing:before_update", startQuerySpan("update")),
+		callbacks.Update().After("gorm:update").Register("tracing:after_update", endQuerySpan),
+		callbacks.Delete().Before("gorm:delete").Register("tracing:before_delete", startQuerySpan("delete")),
+		callbacks.Delete().After("gorm:delete").Register("tracing:after_delete", endQuerySpan),
+		callbacks.Row().Before("gorm:row").Register("tracing:before_row", startQuerySpan("row")),
+		callbacks.Row().After("gorm:row").Register("tracing:after_row", endQuerySpan),
+		callbacks.Raw().Before("gorm:raw").Register("tracing:before_raw", startQuerySpan("raw")),
+		callbacks.Raw().After("gorm:raw").Register("tracing:after_raw", endQuerySpan),
+	)
+}
+
+func startQuerySpan(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		parent := db.Statement.Context
+		if parent == nil || !trace.SpanContextFromContext(parent).IsValid() {
+			return
+		}
+		ctx, span := startSpan(parent, "db."+operation,
+			attribute.String("db.system", db.Dialector.Name()),
+			attribute.String("db.operation", operation),
+			attribute.String("recipes.repository_call", repositoryCall()),
+		)
+		db.Statement.Context = ctx
+		db.InstanceSet(queryTracingKey, querySpan{span: span, parent: parent})
+	}
+}
+
+func endQuerySpan(db *gorm.DB) {
+	value, ok := db.InstanceGet(queryTracingKey)
+	if !ok {
+		return
+	}
+	started := value.(querySpan)
+	db.Statement.Context = started.parent
+	started.span.SetAttributes(
+		attribute.String("db.statement", db.Statement.SQL.String()),
+		attribute.String("db.sql.table", db.Statement.Table),
+		attribute.Int64("db.rows_affected", db.RowsAffected),
+	)
+	err := db.Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err = nil
+	}
+	endSpan(started.span, err)
+}
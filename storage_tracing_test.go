@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider that keeps every span ended
+// during the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+func TestQueryTracingNestsQueriesUnderTheirSpan(t *testing.T) {
+	recorder := recordSpans(t)
+	repo := newTestRepo(t)
+	if err := repo.db.Use(queryTracing{}); err != nil {
+		t.Fatalf("use tracing: %v", err)
+	}
+	createTestUser(t, repo, "cook@example.com")
+
+	// Untraced work doesn't start a trace of its own
+	if _, err := repo.ListRecipes("cook@example.com", RecipeFilters{}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Fatalf("untraced queries recorded %d spans", len(spans))
+	}
+
+	ctx, span := startSpan(context.Background(), "test")
+	if _, err := repo.WithContext(ctx).ListRecipes("cook@example.com", RecipeFilters{}); err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	span.End()
+
+	parent := span.SpanContext().SpanID()
+	queries := 0
+	for _, ended := range recorder.Ended() {
+		if ended.Name() == "test" {
+			continue
+		}
+		queries++
+		if ended.Parent().SpanID() != parent {
+			t.Errorf("%s is not under the test span", ended.Name())
+		}
+		attrs := map[string]string{}
+		for _, attr := range ended.Attributes() {
+			attrs[string(attr.Key)] = attr.Value.Emit()
+		}
+		if attrs["recipes.repository_call"] != "ListRecipes" {
+			t.Errorf("%s: repository call %q, want ListRecipes", ended.Name(), attrs["recipes.repository_call"])
+		}
+		if attrs["db.statement"] == "" {
+			t.Errorf("%s has no statement", ended.Name())
+		}
+	}
+	if queries == 0 {
+		t.Fatal("no query spans recorded")
+	}
+}
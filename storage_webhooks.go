@@ -0,0 +1,204 @@
+package main
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var errTooManyWebhooks = fmt.Errorf("at most %d webhooks per account", maxWebhooksPerUser)
+
+type WebhookModel struct {
+	ID             uint       `gorm:"primaryKey"`
+	UserID         uint       `gorm:"column:user_id;not null;index"`
+	URL            string     `gorm:"column:url;not null"`
+	Secret         string     `gorm:"column:secret;not null"`
+	Events         string     `gorm:"column:events"`
+	LastDeliveryAt *time.Time `gorm:"column:last_delivery_at"`
+	LastStatus     *int       `gorm:"column:last_status"`
+	LastError      *string    `gorm:"column:last_error"`
+	CreatedAt      time.Time  `gorm:"column:created_at;autoCreateTime"`
+}
+
+func (WebhookModel) TableName() string {
+	return "webhooks"
+}
+
+// Webhook is the API view of a registration. Secret is only filled in when
+// the webhook is created.
+type Webhook struct {
+	ID             uint       `json:"id"`
+	URL            string     `json:"url"`
+	Events         []string   `json:"events"`
+	Secret         string     `json:"secret,omitempty"`
+	LastDeliveryAt *time.Time `json:"lastDeliveryAt,omitempty"`
+	LastStatus     *int       `json:"lastStatus,omitempty"`
+	LastError      *string    `json:"lastError,omitempty"`
+	CreatedAt      time.Time  `json:"createdAt"`
+}
+
+func (m WebhookModel) toWebhook() Webhook {
+	events := []string{}
+	if strings.TrimSpace(m.Events) != "" {
+		events = strings.Split(m.Events, ",")
+	}
+	return Webhook{
+		ID:             m.ID,
+		URL:            m.URL,
+		Events:         events,
+		LastDeliveryAt: m.LastDeliveryAt,
+		LastStatus:     m.LastStatus,
+		LastError:      m.LastError,
+		CreatedAt:      m.CreatedAt,
+	}
+}
+
+// subscribes reports whether the webhook wants event; no events means all.
+func (m WebhookModel) subscribes(event string) bool {
+	if strings.TrimSpace(m.Events) == "" {
+		return true
+	}
+	for _, name := range strings.Split(m.Events, ",") {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *RecipeRepository) CreateWebhook(username, hookURL string, events []string) (Webhook, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return Webhook{}, err
+	}
+
+	var count int64
+	if err := r.db.Model(&WebhookModel{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+		return Webhook{}, fmt.Errorf("count webhooks: %w", err)
+	}
+	if count >= maxWebhooksPerUser {
+		return Webhook{}, errTooManyWebhooks
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return Webhook{}, fmt.Errorf("generate secret: %w", err)
+	}
+
+	model := WebhookModel{
+		UserID: userID,
+		URL:    hookURL,
+		Secret: "whsec_" + hex.EncodeToString(secretBytes),
+		Events: strings.Join(events, ","),
+	}
+	if err := r.db.Create(&model).Error; err != nil {
+		return Webhook{}, fmt.Errorf("create webhook: %w", err)
+	}
+
+	hook := model.toWebhook()
+	hook.Secret = model.Secret
+	return hook, nil
+}
+
+func (r *RecipeRepository) ListWebhooks(username string) ([]Webhook, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []WebhookModel
+	if err := r.db.Where("user_id = ?", userID).Order("id").Find(&models).Error; err != nil {
+		if isNoSuchTableError(err) {
+			return []Webhook{}, nil
+		}
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+
+	hooks := make([]Webhook, 0, len(models))
+	for _, model := range models {
+		hooks = append(hooks, model.toWebhook())
+	}
+	return hooks, nil
+}
+
+func (r *RecipeRepository) DeleteWebhook(username string, id uint) error {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return err
+	}
+
+	result := r.db.Where("user_id = ? AND id = ?", userID, id).Delete(&WebhookModel{})
+	if result.Error != nil {
+		return fmt.Errorf("delete webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// webhookByID returns one of the user's webhooks including its secret.
+func (r *RecipeRepository) webhookByID(username string, id uint) (WebhookModel, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return WebhookModel{}, err
+	}
+
+	var model WebhookModel
+	if err := r.db.Where("user_id = ? AND id = ?", userID, id).First(&model).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return WebhookModel{}, sql.ErrNoRows
+		}
+		return WebhookModel{}, fmt.Errorf("lookup webhook: %w", err)
+	}
+	return model, nil
+}
+
+// webhooksForEvent returns the user's webhooks subscribed to event.
+func (r *RecipeRepository) webhooksForEvent(username, event string) ([]WebhookModel, error) {
+	userID, err := r.getUserID(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var models []WebhookModel
+	if err := r.db.Where("user_id = ?", userID).Find(&models).Error; err != nil {
+		if isNoSuchTableError(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("list webhooks: %w", err)
+	}
+
+	subscribed := models[:0]
+	for _, model := range models {
+		if model.subscribes(event) {
+			subscribed = append(subscribed, model)
+		}
+	}
+	return subscribed, nil
+}
+
+// RecordWebhookDelivery stores the outcome of the latest delivery attempt.
+func (r *RecipeRepository) RecordWebhookDelivery(id uint, status int, deliveryErr error) error {
+	updates := map[string]any{
+		"last_delivery_at": time.Now().UTC(),
+		"last_status":      nil,
+		"last_error":       nil,
+	}
+	if status > 0 {
+		updates["last_status"] = status
+	}
+	if deliveryErr != nil {
+		updates["last_error"] = deliveryErr.Error()
+	}
+	if err := r.db.Model(&WebhookModel{}).Where("id = ?", id).Updates(updates).Error; err != nil {
+		return fmt.Errorf("record webhook delivery: %w", err)
+	}
+	return nil
+}
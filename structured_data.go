@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// structuredRecipe reads a schema.org Recipe embedded in the page, as
+// JSON-LD or microdata, and maps it without calling the AI. It reports false
+// when there is none or it lacks a title, ingredients or instructions, so
+// the caller can fall back to AI extraction.
+func structuredRecipe(doc *goquery.Document) (Recipe, bool) {
+	schemas := jsonLDRecipes(doc)
+	schemas = append(schemas, microdataRecipes(doc)...)
+
+	for _, schema := range schemas {
+		recipe := unescapeRecipeText(schema.toRecipe())
+		if recipe.Title != "" && len(recipe.Ingredients) > 0 && len(recipe.Instructions) > 0 {
+			return recipe, true
+		}
+	}
+	return Recipe{}, false
+}
+
+// unescapeRecipeText decodes HTML entities, which many sites leave in their
+// JSON-LD strings ("Mom&#8217;s Chili").
+func unescapeRecipeText(recipe Recipe) Recipe {
+	recipe.Title = html.UnescapeString(recipe.Title)
+	for i := range recipe.Ingredients {
+		recipe.Ingredients[i] = html.UnescapeString(recipe.Ingredients[i])
+	}
+	for i := range recipe.Instructions {
+		recipe.Instructions[i] = html.UnescapeString(recipe.Instructions[i])
+	}
+	for i := range recipe.InstructionSections {
+		section := &recipe.InstructionSections[i]
+		section.Name = html.UnescapeString(section.Name)
+		for j := range section.Steps {
+			section.Steps[j].Text = html.UnescapeString(section.Steps[j].Text)
+		}
+	}
+	for i := range recipe.Tags {
+		recipe.Tags[i] = html.UnescapeString(recipe.Tags[i])
+	}
+	return recipe
+}
+
+// jsonLDRecipes collects Recipe nodes from every ld+json script, looking
+// inside arrays and @graph containers.
+func jsonLDRecipes(doc *goquery.Document) []schemaRecipe {
+	var recipes []schemaRecipe
+	doc.Find(`script[type="application/ld+json"]`).Each(func(_ int, s *goquery.Selection) {
+		var node any
+		if err := json.Unmarshal([]byte(strings.TrimSpace(s.Text())), &node); err != nil {
+			return
+		}
+		recipes = append(recipes, findJSONLDRecipes(node, 0)...)
+	})
+	return recipes
+}
+
+func findJSONLDRecipes(node any, depth int) []schemaRecipe {
+	if depth > 5 {
+		return nil
+	}
+	switch v := node.(type) {
+	case []any:
+		var recipes []schemaRecipe
+		for _, item := range v {
+			recipes = append(recipes, findJSONLDRecipes(item, depth+1)...)
+		}
+		return recipes
+	case map[string]any:
+		if graph, ok := v["@graph"]; ok {
+			return findJSONLDRecipes(graph, depth+1)
+		}
+		if _, typed := v["@type"]; !typed {
+			return nil
+		}
+		raw, err := json.Marshal(v)
+		if err != nil {
+			return nil
+		}
+		var schema schemaRecipe
+		if err := json.Unmarshal(raw, &schema); err != nil || !schema.isRecipe() {
+			return nil
+		}
+		return []schemaRecipe{schema}
+	}
+	return nil
+}
+
+// microdataRecipes reads itemscope elements typed schema.org/Recipe into the
+// same shape as JSON-LD so both share toRecipe.
+func microdataRecipes(doc *goquery.Document) []schemaRecipe {
+	var recipes []schemaRecipe
+	doc.Find(`[itemscope][itemtype*="schema.org/Recipe"]`).Each(func(_ int, scope *goquery.Selection) {
+		props := map[string][]string{}
+		var steps []string
+		scope.Find("[itemprop]").Each(func(_ int, s *goquery.Selection) {
+			// Properties of nested items belong to them, except the text of
+			// HowToStep items under recipeInstructions
+			if owner := s.Parent().Closest("[itemscope]"); !owner.IsSelection(scope) {
+				if s.AttrOr("itemprop", "") == "text" && owner.AttrOr("itemprop", "") == "recipeInstructions" {
+					if text := microdataValue(s); text != "" {
+						steps = append(steps, text)
+					}
+				}
+				return
+			}
+			if _, nested := s.Attr("itemscope"); nested {
+				return
+			}
+			value := microdataValue(s)
+			if value == "" {
+				return
+			}
+			for _, name := range strings.Fields(s.AttrOr("itemprop", "")) {
+				if name == "recipeInstructions" {
+					// One block of text with a step per line
+					value = s.Text()
+				}
+				props[name] = append(props[name], value)
+			}
+		})
+
+		first := func(name string) string {
+			if values := props[name]; len(values) > 0 {
+				return values[0]
+			}
+			return ""
+		}
+		list := func(values []string) json.RawMessage {
+			raw, _ := json.Marshal(values)
+			return raw
+		}
+
+		instructions := steps
+		if len(instructions) == 0 {
+			for _, text := range props["recipeInstructions"] {
+				instructions = append(instructions, splitLines(text)...)
+			}
+		}
+
+		recipes = append(recipes, schemaRecipe{
+			Type:               json.RawMessage(`"Recipe"`),
+			Name:               first("name"),
+			RecipeIngredient:   list(props["recipeIngredient"]),
+			Ingredients:        list(props["ingredients"]),
+			RecipeInstructions: list(instructions),
+			RecipeYield:        list(props["recipeYield"]),
+			PrepTime:           first("prepTime"),
+			CookTime:           first("cookTime"),
+			TotalTime:          first("totalTime"),
+			RecipeCategory:     list(props["recipeCategory"]),
+			Keywords:           list(props["keywords"]),
+			Image:              list(props["image"]),
+		})
+	})
+	return recipes
+}
+
+// microdataValue follows the microdata rules for where a property's value
+// lives: content for meta, src/href for media and links, datetime for time.
+func microdataValue(s *goquery.Selection) string {
+	if content, ok := s.Attr("content"); ok {
+		return strings.TrimSpace(content)
+	}
+	switch goquery.NodeName(s) {
+	case "img", "source", "video", "audio":
+		return strings.TrimSpace(s.AttrOr("src", ""))
+	case "a", "link":
+		return strings.TrimSpace(s.AttrOr("href", ""))
+	case "time":
+		if datetime, ok := s.Attr("datetime"); ok {
+			return strings.TrimSpace(datetime)
+		}
+	}
+	return strings.Join(strings.Fields(s.Text()), " ")
+}
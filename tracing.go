@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Traces are exported over OTLP/HTTP when OTEL_EXPORTER_OTLP_ENDPOINT (or
+// OTEL_EXPORTER_OTLP_TRACES_ENDPOINT) is set, and not recorded otherwise.
+// The exporter, sampler and resource take the standard OTEL_* variables;
+// the service name defaults to recipes-api.
+//
+// Requests, queue items, the scraper, AI extraction and the database
+// queries made under them are traced, so a slow save shows which step
+// its time went to.
+
+const (
+	tracingServiceName = "recipes-api"
+	tracerName         = "cooking.bronson.dev"
+)
+
+// initTracing installs the OTLP tracer provider. The returned function
+// flushes spans still buffered; call it on the way out.
+func initTracing(ctx context.Context, cfg ReportingConfig) (func(context.Context) error, error) {
+	if !cfg.Tracing {
+		log.Println("Tracing: disabled (OTEL_EXPORTER_OTLP_ENDPOINT not set)")
+		return func(context.Context) error { return nil }, nil
+	}
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// Later options win, so OTEL_SERVICE_NAME overrides the default
+	res, err := resource.New(ctx,
+		resource.WithAttributes(attribute.String("service.name", tracingServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, err
+	}
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	log.Println("Tracing: exporting over OTLP")
+	return provider.Shutdown, nil
+}
+
+// tracingMiddleware starts a span for each request, continuing the
+// caller's trace. Scrapes and probes aren't traced.
+func tracingMiddleware() gin.HandlerFunc {
+	return otelgin.Middleware(tracingServiceName, otelgin.WithFilter(func(r *http.Request) bool {
+		return r.URL.Path != "/metrics" && !isProbePath(r.URL.Path)
+	}))
+}
+
+// startSpan starts a span under whatever span ctx carries. The tracer is
+// looked up on each call, so it follows the provider initTracing installs.
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it failed when err is set.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Parsed ingredient amounts can be shown in metric or US customary units
+// (?units=metric|imperial). Conversion is by table, not by the AI: weights
+// convert exactly, and volumes of common dry ingredients (flour, sugar,
+// butter...) go to and from grams by their usual cup weight. Other volumes
+// stay volumes. Teaspoons and tablespoons are used in both systems, and
+// units without a counterpart (cloves, cans, pinches) are left alone.
+
+const (
+	unitsMetric   = "metric"
+	unitsImperial = "imperial"
+)
+
+// parseUnitSystem reads a ?units= value; ok is false for anything else.
+func parseUnitSystem(raw string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case unitsMetric:
+		return unitsMetric, true
+	case unitsImperial, "us", "customary":
+		return unitsImperial, true
+	}
+	return "", false
+}
+
+// Canonical units, and the millilitres or grams in one of each
+var (
+	volumeUnitML = map[string]float64{
+		"tsp": 4.929, "tbsp": 14.787, "fl oz": 29.574, "cup": 236.588,
+		"pint": 473.176, "quart": 946.353, "gallon": 3785.41,
+		"ml": 1, "l": 1000,
+	}
+	weightUnitG = map[string]float64{
+		"oz": 28.3495, "lb": 453.592, "g": 1, "kg": 1000,
+		// A US stick of butter
+		"stick": 113.4,
+	}
+	unitAliases = map[string]string{
+		"teaspoon": "tsp", "teaspoons": "tsp",
+		"tablespoon": "tbsp", "tablespoons": "tbsp", "tbs": "tbsp", "tbl": "tbsp",
+		"fluid ounce": "fl oz", "fluid ounces": "fl oz", "fl. oz": "fl oz", "fl. oz.": "fl oz",
+		"cups": "cup", "c": "cup",
+		"pints": "pint", "pt": "pint",
+		"quarts": "quart", "qt": "quart",
+		"gallons": "gallon", "gal": "gallon",
+		"milliliter": "ml", "milliliters": "ml", "millilitre": "ml", "millilitres": "ml",
+		"liter": "l", "liters": "l", "litre": "l", "litres": "l",
+		"ounce": "oz", "ounces": "oz",
+		"pound": "lb", "pounds": "lb", "lbs": "lb",
+		"gram": "g", "grams": "g",
+		"kilogram": "kg", "kilograms": "kg",
+		"sticks": "stick",
+	}
+)
+
+// ingredientCupGrams is the weight of one US cup of common dry and solid
+// ingredients. Longer names are matched first, so "brown sugar" wins over
+// "sugar".
+var ingredientCupGrams = map[string]float64{
+	"flour":             120,
+	"bread flour":       127,
+	"whole wheat flour": 113,
+	"almond flour":      96,
+	"sugar":             200,
+	"brown sugar":       213,
+	"powdered sugar":    120,
+	"icing sugar":       120,
+	"confectioners":     120,
+	"butter":            227,
+	"rice":              185,
+	"oats":              90,
+	"rolled oats":       90,
+	"cocoa":             85,
+	"cornstarch":        128,
+	"cornmeal":          138,
+	"breadcrumbs":       108,
+	"panko":             50,
+	"parmesan":          100,
+	"shredded cheese":   113,
+	"chocolate chips":   170,
+	"raisins":           150,
+	"walnuts":           120,
+	"pecans":            120,
+	"almonds":           143,
+	"peanut butter":     258,
+	"honey":             340,
+	"maple syrup":       322,
+	"salt":              288,
+	"kosher salt":       140,
+	"baking soda":       220,
+	"baking powder":     192,
+}
+
+var ingredientCupGramKeys = func() []string {
+	keys := make([]string, 0, len(ingredientCupGrams))
+	for key := range ingredientCupGrams {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+	return keys
+}()
+
+// cupGramsFor returns the weight of a cup of the ingredient description,
+// or 0 when it isn't in the table.
+func cupGramsFor(description string) float64 {
+	text := " " + strings.Join(strings.FieldsFunc(strings.ToLower(description), func(r rune) bool {
+		return !(r >= 'a' && r <= 'z')
+	}), " ") + " "
+	for _, key := range ingredientCupGramKeys {
+		if strings.Contains(text, " "+key+" ") {
+			return ingredientCupGrams[key]
+		}
+	}
+	return 0
+}
+
+func canonicalUnit(unit string) string {
+	unit = strings.ToLower(strings.TrimSpace(unit))
+	if alias, ok := unitAliases[unit]; ok {
+		return alias
+	}
+	return unit
+}
+
+// convertIngredientUnits converts one ingredient's amount to system. It
+// returns the new amount and unit, and false when the ingredient is left
+// as it is.
+func convertIngredientUnits(amount float64, unit, description, system string) (float64, string, bool) {
+	unit = canonicalUnit(unit)
+	if amount <= 0 || unit == "tsp" || unit == "tbsp" {
+		return 0, "", false
+	}
+	cupGrams := cupGramsFor(description)
+
+	switch system {
+	case unitsMetric:
+		if ml, ok := volumeUnitML[unit]; ok {
+			if unit == "ml" || unit == "l" {
+				return 0, "", false
+			}
+			if cupGrams > 0 {
+				return metricAmount(amount*ml/volumeUnitML["cup"]*cupGrams, "g", "kg")
+			}
+			return metricAmount(amount*ml, "ml", "l")
+		}
+		if g, ok := weightUnitG[unit]; ok {
+			if unit == "g" || unit == "kg" {
+				return 0, "", false
+			}
+			return metricAmount(amount*g, "g", "kg")
+		}
+
+	case unitsImperial:
+		if g, ok := weightUnitG[unit]; ok {
+			if unit != "g" && unit != "kg" {
+				return 0, "", false
+			}
+			grams := amount * g
+			if cupGrams > 0 {
+				return imperialVolume(grams / cupGrams * volumeUnitML["cup"])
+			}
+			ounces := grams / weightUnitG["oz"]
+			if ounces >= 16 {
+				return roundToEighth(ounces / 16), "lb", true
+			}
+			return roundToQuarter(ounces), "oz", true
+		}
+		if ml, ok := volumeUnitML[unit]; ok {
+			if unit != "ml" && unit != "l" {
+				return 0, "", false
+			}
+			return imperialVolume(amount * ml)
+		}
+	}
+	return 0, "", false
+}
+
+// metricAmount rounds grams or millilitres to what a metric recipe would
+// say, switching to kilograms or litres from 1000.
+func metricAmount(value float64, small, large string) (float64, string, bool) {
+	switch {
+	case value >= 1000:
+		return math.Round(value/10) / 100, large, true
+	case value >= 100:
+		return math.Round(value/5) * 5, small, true
+	case value >= 10:
+		return math.Round(value), small, true
+	}
+	return math.Round(value*10) / 10, small, true
+}
+
+// imperialVolume expresses millilitres in cups, or tablespoons and
+// teaspoons for small amounts.
+func imperialVolume(ml float64) (float64, string, bool) {
+	switch cups := ml / volumeUnitML["cup"]; {
+	case cups >= 0.25:
+		return roundToEighth(cups), "cup", true
+	case ml >= volumeUnitML["tbsp"]:
+		return roundToQuarter(ml / volumeUnitML["tbsp"]), "tbsp", true
+	}
+	return roundToQuarter(ml / volumeUnitML["tsp"]), "tsp", true
+}
+
+func roundToEighth(value float64) float64 {
+	return max(math.Round(value*8)/8, 0.125)
+}
+
+func roundToQuarter(value float64) float64 {
+	return max(math.Round(value*4)/4, 0.25)
+}
+
+// formatConvertedAmount renders a converted amount: decimals for metric
+// units, fractions for US ones.
+func formatConvertedAmount(value float64, system string) string {
+	if system == unitsMetric {
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+	return formatAmount(value)
+}
+
+// displayUnit pluralizes cups; the other canonical units are abbreviations.
+func displayUnit(unit string, amount float64) string {
+	if unit == "cup" && amount > 1 {
+		return "cups"
+	}
+	return unit
+}
+
+// convertRecipeUnits converts the parsed ingredient amounts of recipe to
+// system, updating their display and the ingredient lines.
+func convertRecipeUnits(recipe *Recipe, system string) {
+	if len(recipe.ParsedIngredients) == 0 {
+		return
+	}
+	for i := range recipe.ParsedIngredients {
+		detail := &recipe.ParsedIngredients[i]
+		if detail.AmountValue == nil {
+			continue
+		}
+		amount, unit, ok := convertIngredientUnits(*detail.AmountValue, detail.Unit, detail.Description, system)
+		if !ok {
+			continue
+		}
+		detail.AmountValue = floatPtr(amount)
+		detail.AmountText = formatConvertedAmount(amount, system)
+		detail.Unit = displayUnit(unit, amount)
+		detail.Display = composeDisplayWithUnit(detail.AmountText, detail.Unit, detail.Description)
+	}
+	recipe.Ingredients = make([]string, len(recipe.ParsedIngredients))
+	for i, detail := range recipe.ParsedIngredients {
+		recipe.Ingredients[i] = strings.TrimSpace(detail.Display)
+	}
+}
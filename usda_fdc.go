@@ -0,0 +1,303 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Nutrition can be computed from a recipe's parsed ingredients with USDA
+// FoodData Central (USDA_FDC_API_KEY): each ingredient is matched to its
+// closest SR Legacy or Foundation food, whose values are per 100 g, and its
+// amount converted to grams. Weights convert exactly, counted items ("2
+// eggs") use the food's first portion weight, and volumes assume the
+// density of water, so a recipe of mostly cups gets lower confidence.
+
+const defaultFDCBaseURL = "https://api.nal.usda.gov/fdc/v1"
+
+var errFDCNotConfigured = errors.New("USDA_FDC_API_KEY is not set")
+
+// USDANutrition is nutrition computed from USDA data, per serving.
+type USDANutrition struct {
+	Nutrition
+	// Confidence is high, medium or low, from how many ingredients were
+	// matched and how exactly their amounts converted to grams
+	Confidence         string    `json:"confidence"`
+	MatchedIngredients int       `json:"matchedIngredients"`
+	TotalIngredients   int       `json:"totalIngredients"`
+	ComputedAt         time.Time `json:"computedAt"`
+}
+
+const (
+	nutritionConfidenceHigh   = "high"
+	nutritionConfidenceMedium = "medium"
+	nutritionConfidenceLow    = "low"
+)
+
+// Grams per unit for weights, and per unit of volume at the density of
+// water. Sticks, cloves and cans are their usual sizes.
+var (
+	weightUnitGrams = map[string]float64{
+		"g": 1, "gram": 1, "grams": 1,
+		"kg": 1000, "kilogram": 1000, "kilograms": 1000,
+		"oz": 28.35, "ounce": 28.35, "ounces": 28.35,
+		"lb": 453.6, "lbs": 453.6, "pound": 453.6, "pounds": 453.6,
+		"stick": 113, "sticks": 113,
+		"clove": 5, "cloves": 5,
+		"can": 400, "cans": 400,
+		"pinch": 0.4, "dash": 0.6,
+	}
+	volumeUnitGrams = map[string]float64{
+		"tsp": 4.93, "teaspoon": 4.93, "teaspoons": 4.93,
+		"tbsp": 14.79, "tablespoon": 14.79, "tablespoons": 14.79,
+		"cup": 236.6, "cups": 236.6, "c": 236.6,
+		"pint": 473.2, "pints": 473.2, "pt": 473.2,
+		"quart": 946.4, "quarts": 946.4, "qt": 946.4,
+		"gallon": 3785, "gallons": 3785, "gal": 3785,
+		"ml": 1, "milliliter": 1, "milliliters": 1,
+		"l": 1000, "liter": 1000, "liters": 1000,
+		"fl oz": 29.57, "fluid ounce": 29.57, "fluid ounces": 29.57,
+	}
+)
+
+// FDC nutrient numbers; Foundation foods report energy as Atwater factors
+var (
+	fdcEnergyNutrients   = []string{"208", "957", "958"}
+	fdcProteinNutrient   = "203"
+	fdcFatNutrient       = "204"
+	fdcCarbsNutrient     = "205"
+	fdcQueryNoisePattern = regexp.MustCompile(`\([^)]*\)|,.*$`)
+)
+
+type fdcFood struct {
+	FDCID         int    `json:"fdcId"`
+	Description   string `json:"description"`
+	FoodNutrients []struct {
+		NutrientNumber string  `json:"nutrientNumber"`
+		Value          float64 `json:"value"`
+	} `json:"foodNutrients"`
+}
+
+// per100g returns the food's nutrition per 100 g.
+func (f fdcFood) per100g() Nutrition {
+	values := map[string]float64{}
+	for _, nutrient := range f.FoodNutrients {
+		if _, seen := values[nutrient.NutrientNumber]; !seen {
+			values[nutrient.NutrientNumber] = nutrient.Value
+		}
+	}
+	var n Nutrition
+	for _, number := range fdcEnergyNutrients {
+		if value, ok := values[number]; ok && value > 0 {
+			n.Calories = value
+			break
+		}
+	}
+	n.ProteinGrams = values[fdcProteinNutrient]
+	n.FatGrams = values[fdcFatNutrient]
+	n.CarbsGrams = values[fdcCarbsNutrient]
+	return n
+}
+
+type fdcClient struct {
+	apiKey  string
+	baseURL string
+	http    *http.Client
+}
+
+func newFDCClient() (*fdcClient, error) {
+	cfg := appConfig.Nutrition
+	if cfg.FDCAPIKey == "" {
+		return nil, errFDCNotConfigured
+	}
+	return &fdcClient{apiKey: cfg.FDCAPIKey, baseURL: cfg.FDCURL, http: &http.Client{Timeout: fdcRequestTimeout}}, nil
+}
+
+func (f *fdcClient) get(ctx context.Context, path string, query url.Values, out any) error {
+	query.Set("api_key", f.apiKey)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, f.baseURL+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return fmt.Errorf("build fdc request: %w", err)
+	}
+	resp, err := f.http.Do(req)
+	if err != nil {
+		return fmt.Errorf("fdc request: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 500))
+		return fmt.Errorf("fdc status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode fdc response: %w", err)
+	}
+	return nil
+}
+
+// searchFood returns the best match for an ingredient, or false when there
+// is none. Results are cached since the same ingredients come up often.
+func (f *fdcClient) searchFood(ctx context.Context, ingredient string) (fdcFood, bool, error) {
+	query := fdcQuery(ingredient)
+	if query == "" {
+		return fdcFood{}, false, nil
+	}
+	cacheKey := "search:" + query
+	if cached, ok := fdcCache.Get(cacheKey); ok {
+		observeCacheLookup(cacheFDC, true)
+		food, found := cached.(*fdcFood)
+		if !found || food == nil {
+			return fdcFood{}, false, nil
+		}
+		return *food, true, nil
+	}
+	observeCacheLookup(cacheFDC, false)
+
+	var result struct {
+		Foods []fdcFood `json:"foods"`
+	}
+	params := url.Values{"query": {query}, "dataType": {"SR Legacy,Foundation"}, "pageSize": {"1"}}
+	if err := f.get(ctx, "/foods/search", params, &result); err != nil {
+		return fdcFood{}, false, err
+	}
+	if len(result.Foods) == 0 {
+		fdcCache.SetDefault(cacheKey, (*fdcFood)(nil))
+		return fdcFood{}, false, nil
+	}
+	food := result.Foods[0]
+	fdcCache.SetDefault(cacheKey, &food)
+	return food, true, nil
+}
+
+// portionGrams is the weight of one of the food's first portion ("1 large
+// egg"), or 0 when the food lists none.
+func (f *fdcClient) portionGrams(ctx context.Context, fdcID int) (float64, error) {
+	cacheKey := "portion:" + strconv.Itoa(fdcID)
+	if cached, ok := fdcCache.Get(cacheKey); ok {
+		observeCacheLookup(cacheFDC, true)
+		return cached.(float64), nil
+	}
+	observeCacheLookup(cacheFDC, false)
+
+	var detail struct {
+		FoodPortions []struct {
+			Amount     float64 `json:"amount"`
+			GramWeight float64 `json:"gramWeight"`
+		} `json:"foodPortions"`
+	}
+	if err := f.get(ctx, "/food/"+strconv.Itoa(fdcID), url.Values{}, &detail); err != nil {
+		return 0, err
+	}
+	grams := 0.0
+	for _, portion := range detail.FoodPortions {
+		if portion.GramWeight > 0 {
+			amount := portion.Amount
+			if amount <= 0 {
+				amount = 1
+			}
+			grams = portion.GramWeight / amount
+			break
+		}
+	}
+	fdcCache.SetDefault(cacheKey, grams)
+	return grams, nil
+}
+
+// fdcQuery trims preparation notes from an ingredient ("onion, finely
+// chopped (about 1 cup)" searches for "onion").
+func fdcQuery(description string) string {
+	return strings.Join(strings.Fields(fdcQueryNoisePattern.ReplaceAllString(description, "")), " ")
+}
+
+// computeUSDANutrition totals the recipe's parsed ingredients and divides
+// by its servings (taking 1 when unknown, at low confidence).
+func computeUSDANutrition(ctx context.Context, client *fdcClient, recipe Recipe) (*USDANutrition, error) {
+	var total Nutrition
+	result := USDANutrition{TotalIngredients: len(recipe.ParsedIngredients)}
+	exactness := 0.0
+	for _, ingredient := range recipe.ParsedIngredients {
+		if ingredient.BaseAmountValue == nil && ingredient.AmountValue == nil {
+			continue
+		}
+		amount := ingredient.AmountValue
+		if ingredient.BaseAmountValue != nil {
+			amount = ingredient.BaseAmountValue
+		}
+		food, found, err := client.searchFood(ctx, ingredient.Description)
+		if err != nil {
+			return nil, err
+		}
+		if !found {
+			continue
+		}
+
+		unit := strings.ToLower(strings.TrimSpace(ingredient.Unit))
+		grams, weight := 0.0, 1.0
+		if perUnit, ok := weightUnitGrams[unit]; ok {
+			grams = *amount * perUnit
+		} else if perUnit, ok := volumeUnitGrams[unit]; ok {
+			grams, weight = *amount*perUnit, 0.5
+		} else if unit == "" {
+			portion, err := client.portionGrams(ctx, food.FDCID)
+			if err != nil {
+				return nil, err
+			}
+			grams = *amount * portion
+		}
+		if grams <= 0 {
+			continue
+		}
+
+		per100g := food.per100g()
+		total.Calories += per100g.Calories * grams / 100
+		total.ProteinGrams += per100g.ProteinGrams * grams / 100
+		total.CarbsGrams += per100g.CarbsGrams * grams / 100
+		total.FatGrams += per100g.FatGrams * grams / 100
+		result.MatchedIngredients++
+		exactness += weight
+	}
+
+	servings := recipe.OriginalServings
+	if servings <= 0 {
+		servings = recipe.Servings
+	}
+	score := 0.0
+	if result.TotalIngredients > 0 {
+		score = exactness / float64(result.TotalIngredients)
+	}
+	switch {
+	case servings <= 0 || score < 0.6:
+		result.Confidence = nutritionConfidenceLow
+	case score < 0.85:
+		result.Confidence = nutritionConfidenceMedium
+	default:
+		result.Confidence = nutritionConfidenceHigh
+	}
+	if servings <= 0 {
+		servings = 1
+	}
+
+	perServing := scaleNutrition(&total, 1/float64(servings))
+	if perServing == nil {
+		perServing = &Nutrition{}
+	}
+	result.Nutrition = *perServing
+	result.ComputedAt = time.Now().UTC()
+	return &result, nil
+}
+
+// preferredNutrition is the USDA nutrition unless it is low confidence and
+// an estimate exists.
+func preferredNutrition(recipe *Recipe) *Nutrition {
+	if recipe.USDANutrition != nil && (recipe.USDANutrition.Confidence != nutritionConfidenceLow || recipe.Nutrition == nil) {
+		return &recipe.USDANutrition.Nutrition
+	}
+	return recipe.Nutrition
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+const (
+	webhookEventRecipeProcessed = "recipe.processed"
+	webhookEventRecipeFailed    = "recipe.failed"
+	webhookEventRecipeDeleted   = "recipe.deleted"
+	// webhookEventPing is only sent by POST /webhooks/:id/ping
+	webhookEventPing = "ping"
+)
+
+var webhookEvents = []string{webhookEventRecipeProcessed, webhookEventRecipeFailed, webhookEventRecipeDeleted}
+
+// webhookRetryDelays are the pauses before the second and third attempts.
+var webhookRetryDelays = []time.Duration{5 * time.Second, 30 * time.Second}
+
+var errPrivateWebhookAddress = errors.New("webhook address is not publicly routable")
+
+// webhookClient refuses to connect to loopback, private and link-local
+// addresses (checked after DNS resolution) unless WEBHOOK_ALLOW_PRIVATE=true,
+// which self-hosted setups posting to a LAN service need.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				if appConfig.Server.AllowPrivateWebhooks {
+					return nil
+				}
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				ip := net.ParseIP(host)
+				if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+					ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() {
+					return errPrivateWebhookAddress
+				}
+				return nil
+			},
+		}).DialContext,
+		MaxIdleConns:        10,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		// The signature covers the original URL only
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookPayload is the JSON body of every delivery.
+type webhookPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"createdAt"`
+	Data      any       `json:"data"`
+}
+
+// queueEventData describes a queued URL that finished processing.
+type queueEventData struct {
+	QueueItemID uint   `json:"queueItemId"`
+	URL         string `json:"url"`
+	RecipeID    uint   `json:"recipeId,omitempty"`
+	Slug        string `json:"slug,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Error       string `json:"error,omitempty"`
+	ErrorCode   string `json:"errorCode,omitempty"`
+}
+
+// fireWebhookEvent delivers event to the user's subscribed webhooks in the
+// background. Failures are recorded on the webhook, never returned.
+func fireWebhookEvent(username, event string, data any) {
+	if recipeRepo == nil {
+		return
+	}
+	hooks, err := recipeRepo.webhooksForEvent(username, event)
+	if err != nil {
+		log.Printf("Webhooks: lookup for %s %s failed: %v", username, event, err)
+		return
+	}
+	if len(hooks) == 0 {
+		return
+	}
+
+	payload, err := newWebhookPayload(event, data)
+	if err != nil {
+		log.Printf("Webhooks: encode %s for %s failed: %v", event, username, err)
+		return
+	}
+	for _, hook := range hooks {
+		go deliverWebhookWithRetry(hook, event, payload)
+	}
+}
+
+func newWebhookPayload(event string, data any) ([]byte, error) {
+	idBytes := make([]byte, 12)
+	if _, err := rand.Read(idBytes); err != nil {
+		return nil, fmt.Errorf("generate event id: %w", err)
+	}
+	return json.Marshal(webhookPayload{
+		ID:        "evt_" + hex.EncodeToString(idBytes),
+		Event:     event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+}
+
+func deliverWebhookWithRetry(hook WebhookModel, event string, payload []byte) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("Webhooks: delivery to %d panicked: %v", hook.ID, r)
+		}
+	}()
+
+	var (
+		status int
+		err    error
+	)
+	for attempt := 0; ; attempt++ {
+		status, err = deliverWebhook(hook, event, payload)
+		if err == nil || attempt >= len(webhookRetryDelays) || !retryableWebhookStatus(status, err) {
+			break
+		}
+		time.Sleep(webhookRetryDelays[attempt])
+	}
+
+	if err != nil {
+		log.Printf("Webhooks: %s to webhook %d failed: %v", event, hook.ID, err)
+	}
+	if recErr := recipeRepo.RecordWebhookDelivery(hook.ID, status, err); recErr != nil {
+		log.Printf("Webhooks: %v", recErr)
+	}
+}
+
+// deliverWebhook makes one signed POST. The signature header is
+// "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body)), with the
+// timestamp sent in X-Webhook-Timestamp so receivers can reject replays.
+func deliverWebhook(hook WebhookModel, event string, payload []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(hook.Secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+
+	ctx, cancel := context.WithTimeout(context.Background(), webhookClient.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, fmt.Errorf("build request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "cooking.bronson.dev-webhooks/1")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// retryableWebhookStatus retries network errors, 429 and 5xx.
+func retryableWebhookStatus(status int, err error) bool {
+	if errors.Is(err, errPrivateWebhookAddress) {
+		return false
+	}
+	return status == 0 || status == http.StatusTooManyRequests || status >= 500
+}
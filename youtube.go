@@ -0,0 +1,283 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// YouTube videos are imported from their title, description and captions
+// instead of the watch page, which holds no recipe. The recipe links back to
+// the video and uses its thumbnail.
+
+var youTubeIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{11}$`)
+
+// youTubeVideoID returns the video id of a YouTube watch, share, shorts,
+// embed or live URL, or "" for anything else.
+func youTubeVideoID(raw string) string {
+	parsed, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return ""
+	}
+	host := strings.ToLower(parsed.Hostname())
+	segments := strings.Split(strings.Trim(parsed.Path, "/"), "/")
+
+	var id string
+	switch host {
+	case "youtu.be":
+		id = segments[0]
+	case "youtube.com", "www.youtube.com", "m.youtube.com", "music.youtube.com", "youtube-nocookie.com", "www.youtube-nocookie.com":
+		switch {
+		case segments[0] == "watch":
+			id = parsed.Query().Get("v")
+		case len(segments) >= 2 && (segments[0] == "shorts" || segments[0] == "embed" || segments[0] == "live" || segments[0] == "v"):
+			id = segments[1]
+		}
+	}
+	if !youTubeIDPattern.MatchString(id) {
+		return ""
+	}
+	return id
+}
+
+func youTubeWatchURL(videoID string) string {
+	return "https://www.youtube.com/watch?v=" + videoID
+}
+
+// canonicalImportURL rewrites the many forms of a YouTube link to its watch
+// URL so each video is queued and stored once. Other URLs are only trimmed.
+func canonicalImportURL(raw string) string {
+	if id := youTubeVideoID(raw); id != "" {
+		return youTubeWatchURL(id)
+	}
+	return strings.TrimSpace(raw)
+}
+
+type youTubeVideo struct {
+	ID          string
+	Title       string
+	Description string
+	Transcript  string
+	Thumbnail   string
+}
+
+// getYouTubeRecipe builds a recipe from a video with the same AI prompt used
+// for web pages.
+func getYouTubeRecipe(videoID string, opts importOptions) (Recipe, string, error) {
+	watchURL := youTubeWatchURL(videoID)
+	video, err := fetchWithRetry(opts.importContext(), watchURL, func(ctx context.Context, _ string) (youTubeVideo, error) {
+		return fetchYouTubeVideo(ctx, videoID)
+	})
+	if err != nil {
+		return Recipe{}, "", err
+	}
+	if video.Transcript == "" && strings.TrimSpace(video.Description) == "" {
+		return Recipe{}, "", fmt.Errorf("youtube video %s has no description or captions", videoID)
+	}
+
+	opts.setStage(queueStageExtracting)
+	before := time.Now()
+	ai := aiProviderForImport(opts, watchURL)
+	recipe, err := aiExtractRecipe(opts.importContext(), ai, video.promptText())
+	if err != nil {
+		return Recipe{}, "", err
+	}
+	log.Println("Time to extract recipe: ", time.Since(before).String())
+
+	if recipe.Title == "" {
+		recipe.Title = video.Title
+	}
+	slug := strings.ToLower(strings.ReplaceAll(recipe.Title, " ", "-"))
+	if storedImage := storeRecipeImage(ai, video.Thumbnail, recipe.Title, slug); storedImage != "" {
+		recipe.Image = storedImage
+	}
+	recipe.OriginalURL = watchURL
+	return recipe, slug, nil
+}
+
+// promptText lays the video out as the page text the recipe prompt expects.
+func (v youTubeVideo) promptText() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Video title: %s\n\nVideo description:\n%s\n", v.Title, v.Description)
+	if v.Transcript != "" {
+		fmt.Fprintf(&b, "\nVideo transcript (spoken, may lack punctuation):\n%s\n", v.Transcript)
+	}
+	return b.String()
+}
+
+// youTubePlayerResponse is the part of ytInitialPlayerResponse used here.
+type youTubePlayerResponse struct {
+	VideoDetails struct {
+		Title            string `json:"title"`
+		ShortDescription string `json:"shortDescription"`
+		Thumbnail        struct {
+			Thumbnails []struct {
+				URL   string `json:"url"`
+				Width int    `json:"width"`
+			} `json:"thumbnails"`
+		} `json:"thumbnail"`
+	} `json:"videoDetails"`
+	Captions struct {
+		Renderer struct {
+			CaptionTracks []youTubeCaptionTrack `json:"captionTracks"`
+		} `json:"playerCaptionsTracklistRenderer"`
+	} `json:"captions"`
+}
+
+type youTubeCaptionTrack struct {
+	BaseURL      string `json:"baseUrl"`
+	LanguageCode string `json:"languageCode"`
+	// Kind is "asr" for automatic captions
+	Kind string `json:"kind"`
+}
+
+// fetchYouTubeVideo reads the watch page's player data. Captions are best
+// effort: without them the recipe comes from the description alone.
+func fetchYouTubeVideo(ctx context.Context, videoID string) (youTubeVideo, error) {
+	body, err := fetchYouTube(ctx, youTubeWatchURL(videoID)+"&hl=en")
+	if err != nil {
+		return youTubeVideo{}, fmt.Errorf("fetch youtube video: %w", err)
+	}
+
+	player, err := parseYouTubePlayerResponse(body)
+	if err != nil {
+		return youTubeVideo{}, fmt.Errorf("youtube video %s: %w", videoID, err)
+	}
+
+	video := youTubeVideo{
+		ID:          videoID,
+		Title:       player.VideoDetails.Title,
+		Description: player.VideoDetails.ShortDescription,
+		Thumbnail:   fmt.Sprintf("https://i.ytimg.com/vi/%s/hqdefault.jpg", videoID),
+	}
+	widest := 0
+	for _, thumb := range player.VideoDetails.Thumbnail.Thumbnails {
+		if thumb.Width > widest && thumb.URL != "" {
+			widest, video.Thumbnail = thumb.Width, thumb.URL
+		}
+	}
+
+	if track, ok := pickCaptionTrack(player.Captions.Renderer.CaptionTracks); ok {
+		captions, err := fetchYouTube(ctx, track.BaseURL)
+		if err != nil {
+			log.Printf("YouTube: captions for %s: %v", videoID, err)
+		} else {
+			video.Transcript = parseCaptionTrack(captions)
+		}
+	}
+	if len(video.Transcript) > maxTranscriptChars {
+		video.Transcript = video.Transcript[:maxTranscriptChars]
+	}
+	return video, nil
+}
+
+var errNoPlayerResponse = errors.New("no player data on watch page")
+
+// parseYouTubePlayerResponse decodes the ytInitialPlayerResponse object
+// assigned in a script on the watch page.
+func parseYouTubePlayerResponse(page string) (youTubePlayerResponse, error) {
+	var player youTubePlayerResponse
+	idx := strings.Index(page, "ytInitialPlayerResponse = ")
+	if idx < 0 {
+		return player, errNoPlayerResponse
+	}
+	decoder := json.NewDecoder(strings.NewReader(page[idx+len("ytInitialPlayerResponse = "):]))
+	if err := decoder.Decode(&player); err != nil {
+		return player, fmt.Errorf("decode player data: %w", err)
+	}
+	if player.VideoDetails.Title == "" {
+		return player, errNoPlayerResponse
+	}
+	return player, nil
+}
+
+// pickCaptionTrack prefers uploaded captions over automatic ones, and
+// English among each.
+func pickCaptionTrack(tracks []youTubeCaptionTrack) (youTubeCaptionTrack, bool) {
+	best, bestScore := youTubeCaptionTrack{}, -1
+	for _, track := range tracks {
+		if track.BaseURL == "" {
+			continue
+		}
+		score := 0
+		if track.Kind != "asr" {
+			score += 2
+		}
+		if strings.HasPrefix(track.LanguageCode, "en") {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = track, score
+		}
+	}
+	return best, bestScore >= 0
+}
+
+// parseCaptionTrack flattens a timedtext document, in either the legacy
+// <text> or the srv3 <p> format, into one line per cue.
+func parseCaptionTrack(doc string) string {
+	decoder := xml.NewDecoder(strings.NewReader(doc))
+	var lines []string
+	var current strings.Builder
+	flush := func() {
+		if text := strings.Join(strings.Fields(html.UnescapeString(current.String())), " "); text != "" {
+			lines = append(lines, text)
+		}
+		current.Reset()
+	}
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			break
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if t.Name.Local == "text" || t.Name.Local == "p" {
+				flush()
+			}
+		case xml.CharData:
+			current.Write(t)
+			current.WriteByte(' ')
+		}
+	}
+	flush()
+	return strings.Join(lines, "\n")
+}
+
+// fetchYouTube GETs a youtube.com URL with the consent cookie set, so EU
+// requests aren't redirected to the consent page.
+func fetchYouTube(ctx context.Context, target string) (string, error) {
+	timeout := appConfig.Scraper.HTTPTimeout
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := newScraperRequest(ctx, target)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Cookie", "SOCS=CAI; CONSENT=YES+")
+	req.Header.Set("Accept-Language", "en")
+
+	resp, err := scraperHTTPClient(target, timeout).Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", &httpStatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageHTMLBytes))
+	if err != nil {
+		return "", fmt.Errorf("read body: %w", err)
+	}
+	return string(body), nil
+}